@@ -11,17 +11,27 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
 	"github.com/rdhawladar/viva-rate-limiter/internal/cache"
 	"github.com/rdhawladar/viva-rate-limiter/internal/config"
 	"github.com/rdhawladar/viva-rate-limiter/internal/controllers"
+	"github.com/rdhawladar/viva-rate-limiter/internal/invoice"
+	"github.com/rdhawladar/viva-rate-limiter/internal/metering"
 	"github.com/rdhawladar/viva-rate-limiter/internal/metrics"
 	"github.com/rdhawladar/viva-rate-limiter/internal/middleware"
+	"github.com/rdhawladar/viva-rate-limiter/internal/migrate"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
+	"github.com/rdhawladar/viva-rate-limiter/internal/queue"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
 	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+	"github.com/rdhawladar/viva-rate-limiter/internal/storage"
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 func main() {
@@ -54,10 +64,11 @@ func main() {
 	// Run migrations if enabled
 	if cfg.Database.AutoMigrate {
 		logger.Info("Running database migrations...")
-		if err := models.AutoMigrate(); err != nil {
+		applied, err := migrate.Apply(context.Background(), models.GetDB(), migrations.FS)
+		if err != nil {
 			logger.Fatal("Failed to run migrations", zap.Error(err))
 		}
-		logger.Info("Database migrations completed")
+		logger.Info("Database migrations completed", zap.Int("applied", len(applied)))
 	}
 
 	// Initialize Prometheus metrics
@@ -84,32 +95,182 @@ func main() {
 	apiKeyRepo := repositories.NewAPIKeyRepository(db)
 	usageLogRepo := repositories.NewUsageLogRepository(db)
 	alertRepo := repositories.NewAlertRepository(db)
+	alertRuleRepo := repositories.NewAlertRuleRepository(db)
 	violationRepo := repositories.NewRateLimitViolationRepository(db)
-	_ = repositories.NewBillingRecordRepository(db) // billingRepo - will be used later
+	accessRuleRepo := repositories.NewAccessRuleRepository(db)
+	partitionRepo := repositories.NewPartitionRepository(db)
+	usageExportRepo := repositories.NewUsageExportRepository(db)
+	billingRepo := repositories.NewBillingRecordRepository(db)
+	pricingPlanRepo := repositories.NewPricingPlanRepository(db)
+	paymentRepo := repositories.NewPaymentRepository(db)
+	usageRollupRepo := repositories.NewUsageRollupRepository(db)
+	meteringRepo := repositories.NewMeteringRepository(db)
+	creditRepo := repositories.NewCreditRepository(db)
+	orgRepo := repositories.NewOrganizationRepository(db)
 
 	logger.Info("Repositories initialized")
 
 	// Initialize services
+	clock := ratelimit.NewSystemClock()
 	apiKeyService := services.NewAPIKeyService(apiKeyRepo, usageLogRepo)
-	rateLimitService := services.NewRateLimitService(apiKeyRepo, violationRepo, usageLogRepo, cacheService)
-	usageTrackingService := services.NewUsageTrackingService(usageLogRepo, apiKeyRepo, cacheService)
-	alertService := services.NewAlertService(alertRepo, apiKeyRepo, usageLogRepo, violationRepo, nil) // nil for notification service
+	accessControlService := services.NewAccessControlService(accessRuleRepo, cfg.Security.AutoBanDuration, clock)
+	penaltyPolicy := services.PenaltyPolicy{
+		Enabled:            cfg.RateLimit.Penalty.Enabled,
+		ViolationThreshold: cfg.RateLimit.Penalty.ViolationThreshold,
+		ViolationWindow:    cfg.RateLimit.Penalty.ViolationWindow,
+		BaseCooldown:       cfg.RateLimit.Penalty.BaseCooldown,
+		MaxCooldown:        cfg.RateLimit.Penalty.MaxCooldown,
+		Multiplier:         cfg.RateLimit.Penalty.Multiplier,
+		LimitFactor:        cfg.RateLimit.Penalty.LimitFactor,
+		MaxLevel:           cfg.RateLimit.Penalty.MaxLevel,
+		DecayInterval:      cfg.RateLimit.Penalty.DecayInterval,
+	}
+	usageTrackingService := services.NewUsageTrackingService(usageLogRepo, apiKeyRepo, cacheService, clock)
+	anomalyPolicy := services.AnomalyPolicy{
+		Enabled:             cfg.Alerts.Anomaly.Enabled,
+		Smoothing:           cfg.Alerts.Anomaly.Smoothing,
+		SpikeSigmas:         cfg.Alerts.Anomaly.SpikeSigmas,
+		MinSpikeRequests:    cfg.Alerts.Anomaly.MinSpikeRequests,
+		MinBaselineDays:     cfg.Alerts.Anomaly.MinBaselineDays,
+		MinBaselineRequests: cfg.Alerts.Anomaly.MinBaselineRequests,
+		MaxEvidence:         cfg.Alerts.Anomaly.MaxEvidence,
+	}
+	partitionManager := services.NewPartitionManager(partitionRepo, services.PartitionPolicy{
+		Enabled:         cfg.Database.Partitions.Enabled,
+		PremakeMonths:   cfg.Database.Partitions.PremakeMonths,
+		RetentionAction: cfg.Database.Partitions.RetentionAction,
+		ArchiveSchema:   cfg.Database.Partitions.ArchiveSchema,
+		RetentionDays:   cfg.Database.Partitions.RetentionDays,
+	}, clock)
+
+	// Create Asynq client for work handed to the worker
+	asynqClient := asynq.NewClient(asynq.RedisClientOpt{
+		Addr:     cfg.Asynq.RedisAddr,
+		Password: cfg.Asynq.RedisPassword,
+		DB:       cfg.Asynq.RedisDB,
+	})
+	defer asynqClient.Close()
+
+	// Alerts raised through the API are delivered by the worker
+	notificationRoutes, err := notify.RoutesFromConfig(&cfg.Alerts.Notifications)
+	if err != nil {
+		logger.Fatal("Invalid notification config", zap.Error(err))
+	}
+	notificationRenderer, err := notify.RendererFromConfig(&cfg.Alerts.Notifications)
+	if err != nil {
+		logger.Fatal("Invalid notification templates", zap.Error(err))
+	}
+	notificationService := services.NewNotificationService(
+		alertRepo,
+		notificationRoutes,
+		notificationRenderer,
+		queue.NewNotificationEnqueuer(asynqClient, cfg.Alerts.Notifications.Queue, cfg.Alerts.Notifications.MaxRetries),
+		clock,
+	)
+	alertService := services.NewAlertService(alertRepo, alertRuleRepo, apiKeyRepo, usageLogRepo, violationRepo, notificationService, anomalyPolicy, clock)
+
+	// Requests of keys with a prepaid credit account are charged as they are checked
+	creditPolicy := services.CreditPolicy{
+		Enabled:          cfg.Billing.Credits.Enabled,
+		RequestCost:      cfg.Billing.Credits.RequestCost,
+		TierRequestCosts: cfg.Billing.Credits.TierRequestCosts,
+		AlertThresholds:  cfg.Billing.Credits.AlertThresholds,
+	}
+	creditService := services.NewCreditService(creditRepo, apiKeyRepo, alertService, creditPolicy, clock)
+	rateLimitService := services.NewRateLimitService(apiKeyRepo, violationRepo, usageLogRepo, cacheService, accessControlService, creditService, penaltyPolicy, clock)
+
+	// Write small usage exports in the request and hand large ones to the worker
+	exportStore, err := storage.NewLocalBlobStore(cfg.Exports.StorageDir)
+	if err != nil {
+		logger.Fatal("Failed to initialize export storage", zap.Error(err))
+	}
+	usageExportService := services.NewUsageExportService(
+		usageExportRepo,
+		usageLogRepo,
+		exportStore,
+		queue.NewUsageExportEnqueuer(asynqClient, cfg.Exports.Queue),
+		services.UsageExportPolicy{
+			SyncMaxRange:   cfg.Exports.SyncMaxRange,
+			SyncMaxRecords: cfg.Exports.SyncMaxRecords,
+			BatchSize:      cfg.Exports.BatchSize,
+		},
+		clock,
+	)
+
+	// Buffer usage logs and write them in batches, either directly or through the worker
+	var usageLogSink services.UsageLogSink
+	if cfg.UsageLogging.Sink == "queue" {
+		usageLogSink = queue.NewUsageLogTaskSink(asynqClient, cfg.UsageLogging.Queue)
+	} else {
+		usageLogSink = services.NewUsageTrackingSink(usageTrackingService)
+	}
+	usageLogBuffer := services.NewUsageLogBuffer(usageLogSink, services.UsageLogBufferConfig{
+		Capacity:      cfg.UsageLogging.Capacity,
+		BatchSize:     cfg.UsageLogging.BatchSize,
+		FlushInterval: cfg.UsageLogging.FlushInterval,
+		FlushTimeout:  cfg.UsageLogging.FlushTimeout,
+		Overflow:      services.UsageLogOverflowPolicy(cfg.UsageLogging.Overflow),
+		BlockTimeout:  cfg.UsageLogging.BlockTimeout,
+	}, prometheusMetrics, logger)
+
+	billingService := services.NewBillingService(billingRepo, pricingPlanRepo, apiKeyRepo, usageLogRepo, clock)
+	invoiceRenderer, err := invoice.RendererFromConfig(&cfg.Billing.Invoice)
+	if err != nil {
+		logger.Fatal("Failed to initialize invoice renderer", zap.Error(err))
+	}
+	paymentProvider, err := payment.ProviderFromConfig(&cfg.Billing.Payments)
+	if err != nil {
+		logger.Fatal("Invalid payments config", zap.Error(err))
+	}
+	paymentService := services.NewPaymentService(billingRepo, paymentRepo, apiKeyRepo, paymentProvider, services.DunningPolicy{
+		PaymentTermsDays: cfg.Billing.PaymentTermsDays,
+		SuspendAfterDays: cfg.Billing.Dunning.SuspendAfterDays,
+	}, clock)
+	meteringSinks, err := metering.SinksFromConfig(&cfg.Metering)
+	if err != nil {
+		logger.Fatal("Invalid metering config", zap.Error(err))
+	}
+	meteringService := services.NewMeteringService(meteringRepo, usageRollupRepo, billingRepo, meteringSinks, services.MeteringPolicy{
+		Enabled:        cfg.Metering.Enabled,
+		SigningSecret:  cfg.Metering.SigningSecret,
+		BatchSize:      cfg.Metering.BatchSize,
+		MaxHoursPerRun: cfg.Metering.MaxHoursPerRun,
+	})
+
+	authzService := services.NewAuthorizationService(orgRepo, apiKeyRepo)
+	orgService := services.NewOrganizationService(orgRepo)
+	adminAuthService, err := services.NewAdminAuthService(apiKeyService, apiKeyRepo, cfg.Security.JWTSecret, cfg.Security.JWTExpiry, clock)
+	if err != nil {
+		logger.Fatal("Invalid admin API config", zap.Error(err))
+	}
 
 	logger.Info("Services initialized")
 
 	// Initialize controllers
-	healthController := controllers.NewHealthController(redisClient)
-	apiKeyController := controllers.NewAPIKeyController(apiKeyService)
-	rateLimitController := controllers.NewRateLimitController(rateLimitService, apiKeyService)
+	healthController := controllers.NewHealthController(redisClient, partitionManager)
+	apiKeyController := controllers.NewAPIKeyController(apiKeyService, authzService)
+	rateLimitController := controllers.NewRateLimitController(rateLimitService, apiKeyService, authzService)
 	swaggerController := controllers.NewSwaggerController()
+	accessControlController := controllers.NewAccessControlController(accessControlService, authzService)
+	alertRuleController := controllers.NewAlertRuleController(alertService)
+	alertController := controllers.NewAlertController(alertService, authzService)
+	exportController := controllers.NewExportController(usageExportService, authzService)
+	usageController := controllers.NewUsageController(usageTrackingService, authzService)
+	billingController := controllers.NewBillingController(billingService, invoiceRenderer, invoice.TermsFromConfig(&cfg.Billing))
+	paymentController := controllers.NewPaymentController(billingService, paymentService)
+	meteringController := controllers.NewMeteringController(meteringService)
+	creditController := controllers.NewCreditController(creditService)
+	orgController := controllers.NewOrganizationController(orgService, authzService)
+	adminAuthController := controllers.NewAdminAuthController(adminAuthService)
 
 	logger.Info("Controllers initialized")
 
-	// Setup Gin router
+	// Setup Gin routers
 	if cfg.App.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
+	// The data plane serves rate limit checks authenticated with API keys
 	router := gin.New()
 
 	// Add middleware
@@ -123,6 +284,7 @@ func main() {
 	router.GET("/health", healthController.Health)
 	router.GET("/ready", healthController.Ready)
 	router.GET("/live", healthController.Live)
+	router.GET("/health/partitions", healthController.Partitions)
 
 	// Swagger documentation endpoints (no rate limiting)
 	router.GET("/swagger", swaggerController.ServeSwaggerRedirect)
@@ -138,47 +300,199 @@ func main() {
 
 	// API routes with rate limiting
 	v1 := router.Group("/api/v1")
-	v1.Use(middleware.RateLimitMiddleware(apiKeyService, rateLimitService, usageTrackingService))
+	v1.Use(middleware.RateLimitMiddleware(apiKeyService, rateLimitService, usageLogBuffer, accessControlService))
+	v1.Use(middleware.LoadPrincipal(authzService))
 	{
-		// API key management
-		apiKeys := v1.Group("/api-keys")
-		{
-			apiKeys.POST("/", apiKeyController.CreateAPIKey)
-			apiKeys.GET("/:id", apiKeyController.GetAPIKey)
-			apiKeys.PUT("/:id", apiKeyController.UpdateAPIKey)
-			apiKeys.DELETE("/:id", apiKeyController.DeleteAPIKey)
-			apiKeys.GET("/", apiKeyController.ListAPIKeys)
-			apiKeys.POST("/:id/rotate", apiKeyController.RotateAPIKey)
-			apiKeys.GET("/:id/stats", apiKeyController.GetAPIKeyStats)
-		}
-
-		// Rate limiting
-		rateLimit := v1.Group("/rate-limit")
-		{
-			rateLimit.POST("/check", rateLimitController.CheckRateLimit)
-			rateLimit.GET("/:api_key_id/info", rateLimitController.GetRateLimitInfo)
-			rateLimit.POST("/:api_key_id/reset", rateLimitController.ResetRateLimit)
-			rateLimit.PUT("/:api_key_id", rateLimitController.UpdateRateLimit)
-			rateLimit.GET("/:api_key_id/violations", rateLimitController.GetViolationHistory)
-		}
+		v1.POST("/rate-limit/check", middleware.RequireScope(models.ScopeRateLimitCheck), rateLimitController.CheckRateLimit)
 	}
 
+	// Payment provider webhooks are authenticated by their signature
+	router.POST("/webhooks/payments", paymentController.HandleWebhook)
+
 	// Public rate limit endpoints (no authentication required)
 	public := router.Group("/api/public/v1")
 	{
 		public.POST("/rate-limit/validate", rateLimitController.ValidateAPIKey)
 	}
 
-	// Start HTTP server
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:      router,
-		ReadTimeout:  time.Duration(cfg.Server.ReadTimeout) * time.Second,
-		WriteTimeout: time.Duration(cfg.Server.WriteTimeout) * time.Second,
-		IdleTimeout:  time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	// The admin API serves the management endpoints on its own port. Callers
+	// exchange an API key for a token and keep the key's scopes and role, but
+	// management requests are neither rate limited nor counted as usage.
+	adminRouter := gin.New()
+	adminRouter.Use(middleware.LoggingMiddleware(logger))
+	adminRouter.Use(middleware.MetricsMiddleware(prometheusMetrics))
+	adminRouter.Use(middleware.CORSMiddleware())
+	adminRouter.Use(middleware.SecurityHeadersMiddleware())
+	adminRouter.Use(gin.Recovery())
+
+	adminRouter.GET("/health", healthController.Health)
+	adminRouter.GET("/ready", healthController.Ready)
+	adminRouter.GET("/live", healthController.Live)
+
+	// Sign-in takes an API key, so each address gets a few attempts a minute.
+	// The limiter owns its Redis connection so closing it leaves redisClient open.
+	tokenRateLimit := cfg.Security.TokenRateLimit
+	if tokenRateLimit <= 0 {
+		tokenRateLimit = 10
+	}
+	tokenBackend, err := ratelimit.NewRedisBackend(ratelimit.RedisConfig{
+		Addresses:    cfg.Redis.Addresses,
+		Password:     cfg.Redis.Password,
+		DB:           cfg.Redis.DB,
+		PoolSize:     cfg.Redis.PoolSize,
+		MinIdleConns: cfg.Redis.MinIdleConns,
+		MaxRetries:   cfg.Redis.MaxRetries,
+		DialTimeout:  cfg.Redis.DialTimeout,
+		ReadTimeout:  cfg.Redis.ReadTimeout,
+		WriteTimeout: cfg.Redis.WriteTimeout,
+		Clock:        clock,
+	})
+	if err != nil {
+		logger.Fatal("Failed to connect the sign-in rate limiter to Redis", zap.Error(err))
+	}
+	tokenLimiter := ratelimit.New(ratelimit.Options{
+		Backend:       tokenBackend,
+		DefaultLimit:  tokenRateLimit,
+		DefaultWindow: time.Minute,
+		KeyPrefix:     "ratelimit:auth_token:",
+		Clock:         clock,
+	})
+	defer tokenLimiter.Close()
+	adminRouter.POST("/api/v1/auth/token", middleware.IPRateLimitMiddleware(tokenLimiter), adminAuthController.IssueToken)
+
+	adminV1 := adminRouter.Group("/api/v1")
+	adminV1.Use(middleware.AdminAuthMiddleware(adminAuthService))
+	adminV1.Use(middleware.LoadPrincipal(authzService))
+	{
+		readKeys := middleware.RequireScope(models.ScopeKeysRead)
+		writeKeys := middleware.RequireScope(models.ScopeKeysWrite)
+
+		// API key management
+		apiKeys := adminV1.Group("/api-keys")
+		{
+			apiKeys.POST("/", writeKeys, apiKeyController.CreateAPIKey)
+			apiKeys.GET("/:id", readKeys, apiKeyController.GetAPIKey)
+			apiKeys.PUT("/:id", writeKeys, apiKeyController.UpdateAPIKey)
+			apiKeys.DELETE("/:id", writeKeys, apiKeyController.DeleteAPIKey)
+			apiKeys.GET("/", readKeys, apiKeyController.ListAPIKeys)
+			apiKeys.POST("/:id/rotate", writeKeys, apiKeyController.RotateAPIKey)
+			apiKeys.GET("/:id/stats", readKeys, apiKeyController.GetAPIKeyStats)
+		}
+
+		// Rate limits of keys
+		rateLimit := adminV1.Group("/rate-limit")
+		{
+			rateLimit.GET("/:api_key_id/info", readKeys, rateLimitController.GetRateLimitInfo)
+			rateLimit.POST("/:api_key_id/reset", writeKeys, rateLimitController.ResetRateLimit)
+			rateLimit.PUT("/:api_key_id", writeKeys, rateLimitController.UpdateRateLimit)
+			rateLimit.GET("/:api_key_id/violations", readKeys, rateLimitController.GetViolationHistory)
+		}
+
+		// Usage analytics
+		usage := adminV1.Group("/usage", readKeys)
+		{
+			usage.GET("/keys/:api_key_id/stats", usageController.GetUsageStats)
+			usage.GET("/keys/:api_key_id/endpoints", usageController.GetKeyEndpoints)
+			usage.GET("/keys/:api_key_id/timeseries", usageController.GetUsageTimeSeries)
+			usage.GET("/keys/:api_key_id/trends", usageController.GetUsageTrends)
+			usage.GET("/keys/:api_key_id/slo", usageController.GetSLOReport)
+			usage.GET("/top-api-keys", usageController.GetTopAPIKeys)
+			usage.GET("/endpoints", usageController.GetEndpointStats)
+		}
+
+		// Usage exports
+		exports := adminV1.Group("/exports", readKeys)
+		{
+			exports.POST("", exportController.CreateExport)
+			exports.GET("/:id", exportController.GetExport)
+			exports.GET("/:id/download", exportController.DownloadExport)
+		}
+
+		// Organization, users and teams of the caller
+		readOrg := middleware.RequireScope(models.ScopeOrgRead)
+		writeOrg := middleware.RequireScope(models.ScopeOrgWrite)
+		org := adminV1.Group("/org")
+		{
+			org.GET("", readOrg, orgController.GetCurrentOrganization)
+			org.GET("/users", readOrg, orgController.ListUsers)
+			org.POST("/users", writeOrg, orgController.CreateUser)
+			org.GET("/users/:id", readOrg, orgController.GetUser)
+			org.PUT("/users/:id", writeOrg, orgController.UpdateUser)
+			org.DELETE("/users/:id", writeOrg, orgController.DeleteUser)
+			org.GET("/teams", readOrg, orgController.ListTeams)
+			org.POST("/teams", writeOrg, orgController.CreateTeam)
+			org.GET("/teams/:id", readOrg, orgController.GetTeam)
+			org.DELETE("/teams/:id", writeOrg, orgController.DeleteTeam)
+			org.POST("/teams/:id/members", writeOrg, orgController.AddTeamMember)
+			org.DELETE("/teams/:id/members/:user_id", writeOrg, orgController.RemoveTeamMember)
+		}
+
+		// Administration
+		admin := adminV1.Group("/admin")
+		{
+			requireAdmin := middleware.RequireScope(models.ScopeAdmin)
+			admin.POST("/access-rules", requireAdmin, accessControlController.CreateAccessRule)
+			admin.GET("/access-rules", requireAdmin, accessControlController.ListAccessRules)
+			admin.GET("/access-rules/:id", requireAdmin, accessControlController.GetAccessRule)
+			admin.DELETE("/access-rules/:id", requireAdmin, accessControlController.DeleteAccessRule)
+
+			admin.POST("/orgs", requireAdmin, orgController.CreateOrganization)
+			admin.GET("/orgs", requireAdmin, orgController.ListOrganizations)
+
+			admin.POST("/alert-rules", requireAdmin, alertRuleController.CreateAlertRule)
+			admin.GET("/alert-rules", requireAdmin, alertRuleController.ListAlertRules)
+			admin.GET("/alert-rules/:id", requireAdmin, alertRuleController.GetAlertRule)
+			admin.PUT("/alert-rules/:id", requireAdmin, alertRuleController.UpdateAlertRule)
+			admin.DELETE("/alert-rules/:id", requireAdmin, alertRuleController.DeleteAlertRule)
+
+			// Alerts
+			readAlerts := middleware.RequireScope(models.ScopeAlertsRead)
+			writeAlerts := middleware.RequireScope(models.ScopeAlertsWrite)
+			admin.GET("/alerts", readAlerts, alertController.ListAlerts)
+			admin.GET("/alerts/summary", readAlerts, alertController.GetAlertsSummary)
+			admin.GET("/alerts/keys/:api_key_id", readAlerts, alertController.GetAPIKeyAlerts)
+			admin.POST("/alerts/resolve", writeAlerts, alertController.BulkResolveAlerts)
+			admin.GET("/alerts/:id", readAlerts, alertController.GetAlert)
+			admin.POST("/alerts/:id/resolve", writeAlerts, alertController.ResolveAlert)
+			admin.POST("/alerts/:id/acknowledge", writeAlerts, alertController.AcknowledgeAlert)
+			admin.POST("/alerts/:id/snooze", writeAlerts, alertController.SnoozeAlert)
+
+			// Billing
+			admin.GET("/billing/summary", requireAdmin, billingController.GetRevenueSummary)
+			admin.GET("/billing/overdue", requireAdmin, billingController.GetOverdueBilling)
+			admin.GET("/billing/invoices", requireAdmin, billingController.ExportInvoices)
+			admin.GET("/billing/keys/:api_key_id/records", requireAdmin, billingController.GetBillingHistory)
+			admin.GET("/billing/keys/:api_key_id/current", requireAdmin, billingController.GetCurrentBilling)
+			admin.GET("/billing/records/:id", requireAdmin, billingController.GetBillingRecord)
+			admin.GET("/billing/records/:id/invoice", requireAdmin, billingController.GetInvoice)
+			admin.POST("/billing/records/:id/charge", requireAdmin, paymentController.ChargeBillingRecord)
+			admin.POST("/billing/records/:id/refund", requireAdmin, paymentController.RefundBillingRecord)
+			admin.PUT("/billing/records/:id/payment-status", requireAdmin, paymentController.UpdatePaymentStatus)
+			admin.POST("/billing/dunning", requireAdmin, paymentController.RunDunning)
+
+			// Metering
+			admin.GET("/metering", requireAdmin, meteringController.GetMeteringStatus)
+			admin.POST("/metering/run", requireAdmin, meteringController.RunMetering)
+			admin.POST("/metering/sinks/:name/replay", requireAdmin, meteringController.ReplayMetering)
+			admin.GET("/metering/reconciliation", requireAdmin, meteringController.GetMeteringReconciliation)
+
+			// Prepaid credits
+			admin.POST("/credits/accounts", requireAdmin, creditController.CreateCreditAccount)
+			admin.GET("/credits/accounts/:id", requireAdmin, creditController.GetCreditAccount)
+			admin.PUT("/credits/accounts/:id", requireAdmin, creditController.UpdateCreditAccount)
+			admin.POST("/credits/accounts/:id/topups", requireAdmin, creditController.TopUpCredits)
+			admin.GET("/credits/accounts/:id/ledger", requireAdmin, creditController.GetCreditLedger)
+			admin.GET("/credits/keys/:api_key_id", requireAdmin, creditController.GetAPIKeyCreditAccount)
+		}
 	}
 
-	// Start server in a goroutine
+	// Start HTTP servers
+	// The admin API listens on server.host only, so it can be kept off
+	// public interfaces
+	server := newHTTPServer(fmt.Sprintf(":%d", cfg.Server.Port), router, &cfg.Server)
+	adminServer := newHTTPServer(cfg.Server.GetAdminAddress(), adminRouter, &cfg.Server)
+
+	// Start servers in goroutines
 	go func() {
 		logger.Info("Starting HTTP server",
 			zap.Int("port", cfg.Server.Port),
@@ -190,8 +504,17 @@ func main() {
 		}
 	}()
 
+	go func() {
+		logger.Info("Starting admin API server", zap.String("address", adminServer.Addr))
+
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Fatal("Failed to start admin API server", zap.Error(err))
+		}
+	}()
+
 	logger.Info("API server started successfully",
 		zap.String("address", fmt.Sprintf("http://localhost:%d", cfg.Server.Port)),
+		zap.String("admin_address", fmt.Sprintf("http://localhost:%d", cfg.Server.AdminPort)),
 	)
 
 	// Start background alert processing
@@ -220,10 +543,18 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	// Shutdown HTTP server
+	// Shutdown HTTP servers
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Error("Server forced to shutdown", zap.Error(err))
 	}
+	if err := adminServer.Shutdown(ctx); err != nil {
+		logger.Error("Admin API server forced to shutdown", zap.Error(err))
+	}
+
+	// Flush buffered usage logs while the database and Redis are still open
+	if err := usageLogBuffer.Close(ctx); err != nil {
+		logger.Error("Failed to flush usage logs", zap.Error(err))
+	}
 
 	// Close Redis connection
 	if err := redisClient.Close(); err != nil {
@@ -234,4 +565,16 @@ func main() {
 	models.CloseDB()
 
 	logger.Info("Server shutdown complete")
-}
\ No newline at end of file
+}
+
+// newHTTPServer creates an HTTP server listening on addr with the configured
+// timeouts
+func newHTTPServer(addr string, handler http.Handler, cfg *config.ServerConfig) *http.Server {
+	return &http.Server{
+		Addr:         addr,
+		Handler:      handler,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+}
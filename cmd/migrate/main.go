@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/migrate"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+)
+
+const usage = `Usage: migrate [flags] <command>
+
+Commands:
+  up        apply pending migrations
+  down      revert the most recent migrations (one unless -steps is set)
+  status    list migrations and whether they are applied
+  baseline  mark migrations up to -version as applied without running them
+
+Flags:
+`
+
+func main() {
+	dir := flag.String("dir", "", "read migrations from this directory instead of the embedded ones")
+	steps := flag.Int("steps", 0, "number of migrations to apply or revert")
+	dryRun := flag.Bool("dry-run", false, "print the SQL that would run without executing it")
+	version := flag.Int64("version", 0, "last migration version to record with baseline")
+	lockTimeout := flag.Duration("lock-timeout", 5*time.Minute, "how long to wait for another replica's migration lock")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	command := flag.Arg(0)
+
+	// Initialize logger
+	logger, err := zap.NewDevelopment()
+	if err != nil {
+		log.Fatalf("Failed to initialize logger: %v", err)
+	}
+	defer logger.Sync()
+
+	// Load configuration
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Fatal("Failed to load configuration", zap.Error(err))
+	}
+
+	var source fs.FS = migrations.FS
+	if *dir != "" {
+		source = os.DirFS(*dir)
+	}
+
+	all, err := migrate.Load(source)
+	if err != nil {
+		logger.Fatal("Failed to load migrations", zap.Error(err))
+	}
+
+	// Initialize database
+	if err := models.InitDB(&cfg.Database); err != nil {
+		logger.Fatal("Failed to initialize database", zap.Error(err))
+	}
+	defer models.CloseDB()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *lockTimeout)
+	defer cancel()
+
+	migrator := migrate.New(models.GetDB(), all)
+
+	switch command {
+	case "up", "down":
+		direction := migrate.Direction(command)
+		if *dryRun {
+			plan, err := migrator.Plan(ctx, direction, *steps)
+			if err != nil {
+				logger.Fatal("Failed to plan migrations", zap.Error(err))
+			}
+			printPlan(plan, direction)
+			return
+		}
+
+		var done []*migrate.Migration
+		if direction == migrate.DirectionUp {
+			done, err = migrator.Up(ctx, *steps)
+		} else {
+			done, err = migrator.Down(ctx, *steps)
+		}
+		for _, migration := range done {
+			logger.Info("Migrated",
+				zap.String("migration", migration.String()),
+				zap.String("direction", command),
+			)
+		}
+		if err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		logger.Info("Migrations completed", zap.Int("count", len(done)))
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Fatal("Failed to read migration status", zap.Error(err))
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Modified {
+				state += " (modified since applied)"
+			}
+			fmt.Printf("%-40s %s\n", status.Migration, state)
+		}
+
+	case "baseline":
+		if *version <= 0 {
+			logger.Fatal("baseline requires -version")
+		}
+		if *dryRun {
+			fmt.Printf("-- would record migrations up to version %d as applied\n", *version)
+			return
+		}
+		recorded, err := migrator.Baseline(ctx, *version)
+		if err != nil {
+			logger.Fatal("Baseline failed", zap.Error(err))
+		}
+		logger.Info("Baseline recorded", zap.Int("count", len(recorded)))
+
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// printPlan writes the SQL of every planned migration to stdout
+func printPlan(plan []*migrate.Migration, direction migrate.Direction) {
+	if len(plan) == 0 {
+		fmt.Println("-- nothing to migrate")
+		return
+	}
+	for _, migration := range plan {
+		fmt.Printf("-- %s (%s)\n%s\n", migration, direction, migration.SQL(direction))
+	}
+}
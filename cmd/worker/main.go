@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"os"
 	"os/signal"
@@ -14,10 +13,17 @@ import (
 
 	"github.com/rdhawladar/viva-rate-limiter/internal/cache"
 	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/metering"
+	"github.com/rdhawladar/viva-rate-limiter/internal/migrate"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
 	"github.com/rdhawladar/viva-rate-limiter/internal/queue"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
 	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+	"github.com/rdhawladar/viva-rate-limiter/internal/storage"
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 func main() {
@@ -47,6 +53,16 @@ func main() {
 
 	logger.Info("Database connected successfully")
 
+	// Run migrations if enabled; the migration lock keeps this safe alongside the API
+	if cfg.Database.AutoMigrate {
+		logger.Info("Running database migrations...")
+		applied, err := migrate.Apply(context.Background(), models.GetDB(), migrations.FS)
+		if err != nil {
+			logger.Fatal("Failed to run migrations", zap.Error(err))
+		}
+		logger.Info("Database migrations completed", zap.Int("applied", len(applied)))
+	}
+
 	// Initialize Redis
 	logger.Info("Connecting to Redis...")
 	redisClient, err := cache.NewRedisClient(&cfg.Redis)
@@ -67,20 +83,52 @@ func main() {
 	apiKeyRepo := repositories.NewAPIKeyRepository(db)
 	usageLogRepo := repositories.NewUsageLogRepository(db)
 	alertRepo := repositories.NewAlertRepository(db)
+	alertRuleRepo := repositories.NewAlertRuleRepository(db)
 	violationRepo := repositories.NewRateLimitViolationRepository(db)
 	billingRepo := repositories.NewBillingRecordRepository(db)
+	pricingPlanRepo := repositories.NewPricingPlanRepository(db)
+	usageRollupRepo := repositories.NewUsageRollupRepository(db)
+	partitionRepo := repositories.NewPartitionRepository(db)
+	usageExportRepo := repositories.NewUsageExportRepository(db)
+	paymentRepo := repositories.NewPaymentRepository(db)
+	meteringRepo := repositories.NewMeteringRepository(db)
+	creditRepo := repositories.NewCreditRepository(db)
 
 	logger.Info("Repositories initialized")
 
 	// Initialize services
+	clock := ratelimit.NewSystemClock()
 	apiKeyService := services.NewAPIKeyService(apiKeyRepo, usageLogRepo)
-	rateLimitService := services.NewRateLimitService(apiKeyRepo, violationRepo, usageLogRepo, cacheService)
-	usageTrackingService := services.NewUsageTrackingService(usageLogRepo, apiKeyRepo, cacheService)
-	alertService := services.NewAlertService(alertRepo, apiKeyRepo, usageLogRepo, violationRepo, nil)
-	billingService := services.NewBillingService(billingRepo, apiKeyRepo, usageLogRepo)
-
-	logger.Info("Services initialized")
-
+	penaltyPolicy := services.PenaltyPolicy{
+		Enabled:            cfg.RateLimit.Penalty.Enabled,
+		ViolationThreshold: cfg.RateLimit.Penalty.ViolationThreshold,
+		ViolationWindow:    cfg.RateLimit.Penalty.ViolationWindow,
+		BaseCooldown:       cfg.RateLimit.Penalty.BaseCooldown,
+		MaxCooldown:        cfg.RateLimit.Penalty.MaxCooldown,
+		Multiplier:         cfg.RateLimit.Penalty.Multiplier,
+		LimitFactor:        cfg.RateLimit.Penalty.LimitFactor,
+		MaxLevel:           cfg.RateLimit.Penalty.MaxLevel,
+		DecayInterval:      cfg.RateLimit.Penalty.DecayInterval,
+	}
+	rateLimitService := services.NewRateLimitService(apiKeyRepo, violationRepo, usageLogRepo, cacheService, nil, nil, penaltyPolicy, clock)
+	usageTrackingService := services.NewUsageTrackingService(usageLogRepo, apiKeyRepo, cacheService, clock)
+	usageRollupService := services.NewUsageRollupService(usageRollupRepo, cfg.UsageLogging.RollupLateness, cfg.UsageLogging.RollupRestatement, clock)
+	partitionManager := services.NewPartitionManager(partitionRepo, services.PartitionPolicy{
+		Enabled:         cfg.Database.Partitions.Enabled,
+		PremakeMonths:   cfg.Database.Partitions.PremakeMonths,
+		RetentionAction: cfg.Database.Partitions.RetentionAction,
+		ArchiveSchema:   cfg.Database.Partitions.ArchiveSchema,
+		RetentionDays:   cfg.Database.Partitions.RetentionDays,
+	}, clock)
+	anomalyPolicy := services.AnomalyPolicy{
+		Enabled:             cfg.Alerts.Anomaly.Enabled,
+		Smoothing:           cfg.Alerts.Anomaly.Smoothing,
+		SpikeSigmas:         cfg.Alerts.Anomaly.SpikeSigmas,
+		MinSpikeRequests:    cfg.Alerts.Anomaly.MinSpikeRequests,
+		MinBaselineDays:     cfg.Alerts.Anomaly.MinBaselineDays,
+		MinBaselineRequests: cfg.Alerts.Anomaly.MinBaselineRequests,
+		MaxEvidence:         cfg.Alerts.Anomaly.MaxEvidence,
+	}
 	// Create Asynq client for task enqueueing
 	asynqClient := asynq.NewClient(asynq.RedisClientOpt{
 		Addr:     cfg.Asynq.RedisAddr,
@@ -89,13 +137,74 @@ func main() {
 	})
 	defer asynqClient.Close()
 
+	// Alerts raised by the worker are delivered by the worker, with retries
+	notificationRoutes, err := notify.RoutesFromConfig(&cfg.Alerts.Notifications)
+	if err != nil {
+		logger.Fatal("Invalid notification config", zap.Error(err))
+	}
+	notificationRenderer, err := notify.RendererFromConfig(&cfg.Alerts.Notifications)
+	if err != nil {
+		logger.Fatal("Invalid notification templates", zap.Error(err))
+	}
+	notificationService := services.NewNotificationService(
+		alertRepo,
+		notificationRoutes,
+		notificationRenderer,
+		queue.NewNotificationEnqueuer(asynqClient, cfg.Alerts.Notifications.Queue, cfg.Alerts.Notifications.MaxRetries),
+		clock,
+	)
+	alertService := services.NewAlertService(alertRepo, alertRuleRepo, apiKeyRepo, usageLogRepo, violationRepo, notificationService, anomalyPolicy, clock)
+	billingService := services.NewBillingService(billingRepo, pricingPlanRepo, apiKeyRepo, usageLogRepo, clock)
+	paymentProvider, err := payment.ProviderFromConfig(&cfg.Billing.Payments)
+	if err != nil {
+		logger.Fatal("Invalid payments config", zap.Error(err))
+	}
+	paymentService := services.NewPaymentService(billingRepo, paymentRepo, apiKeyRepo, paymentProvider, services.DunningPolicy{
+		PaymentTermsDays: cfg.Billing.PaymentTermsDays,
+		SuspendAfterDays: cfg.Billing.Dunning.SuspendAfterDays,
+	}, clock)
+	meteringSinks, err := metering.SinksFromConfig(&cfg.Metering)
+	if err != nil {
+		logger.Fatal("Invalid metering config", zap.Error(err))
+	}
+	meteringService := services.NewMeteringService(meteringRepo, usageRollupRepo, billingRepo, meteringSinks, services.MeteringPolicy{
+		Enabled:        cfg.Metering.Enabled,
+		SigningSecret:  cfg.Metering.SigningSecret,
+		BatchSize:      cfg.Metering.BatchSize,
+		MaxHoursPerRun: cfg.Metering.MaxHoursPerRun,
+	})
+	creditService := services.NewCreditService(creditRepo, apiKeyRepo, alertService, services.CreditPolicy{
+		Enabled:          cfg.Billing.Credits.Enabled,
+		RequestCost:      cfg.Billing.Credits.RequestCost,
+		TierRequestCosts: cfg.Billing.Credits.TierRequestCosts,
+		AlertThresholds:  cfg.Billing.Credits.AlertThresholds,
+	}, clock)
+
+	logger.Info("Services initialized")
+
+	// The worker writes the exports the API enqueued, so it never enqueues them itself
+	exportStore, err := storage.NewLocalBlobStore(cfg.Exports.StorageDir)
+	if err != nil {
+		logger.Fatal("Failed to initialize export storage", zap.Error(err))
+	}
+	usageExportService := services.NewUsageExportService(usageExportRepo, usageLogRepo, exportStore, nil, services.UsageExportPolicy{
+		BatchSize: cfg.Exports.BatchSize,
+	}, clock)
+
 	// Create task handlers
 	taskHandlers := queue.NewTaskHandlers(
 		apiKeyService,
 		rateLimitService,
 		usageTrackingService,
+		usageRollupService,
+		partitionManager,
+		usageExportService,
 		alertService,
+		notificationService,
 		billingService,
+		paymentService,
+		meteringService,
+		creditService,
 		logger,
 	)
 
@@ -111,6 +220,9 @@ func main() {
 			Queues:      cfg.Asynq.Queues,
 			StrictPriority: cfg.Asynq.StrictPriority,
 			RetryDelayFunc: func(n int, e error, t *asynq.Task) time.Duration {
+				if t.Type() == queue.TaskTypeDeliverNotification {
+					return queue.NotificationRetryDelay(n)
+				}
 				return time.Duration(n) * time.Minute
 			},
 			ErrorHandler: asynq.ErrorHandlerFunc(func(ctx context.Context, task *asynq.Task, err error) {
@@ -135,12 +247,24 @@ func main() {
 	mux.HandleFunc(queue.TaskTypeProcessUsageLogs, taskHandlers.ProcessUsageLogs)
 	mux.HandleFunc(queue.TaskTypeCheckRateLimit, taskHandlers.CheckRateLimit)
 	mux.HandleFunc(queue.TaskTypeGenerateBilling, taskHandlers.GenerateBilling)
+	mux.HandleFunc(queue.TaskTypeRunDunning, taskHandlers.RunDunning)
 	mux.HandleFunc(queue.TaskTypeProcessAlerts, taskHandlers.ProcessAlerts)
 	mux.HandleFunc(queue.TaskTypeCleanupExpiredData, taskHandlers.CleanupExpiredData)
 	mux.HandleFunc(queue.TaskTypeSyncCacheWithDB, taskHandlers.SyncCacheWithDB)
+	mux.HandleFunc(queue.TaskTypeMaintainPartitions, taskHandlers.MaintainPartitions)
+	mux.HandleFunc(queue.TaskTypeExportUsage, taskHandlers.ExportUsage)
+	mux.HandleFunc(queue.TaskTypeDeliverNotification, taskHandlers.DeliverNotification)
+	mux.HandleFunc(queue.TaskTypeExportMetering, taskHandlers.ExportMetering)
+	mux.HandleFunc(queue.TaskTypeExpireCredits, taskHandlers.ExpireCredits)
 
 	logger.Info("Task handlers registered")
 
+	// Make sure partitions for the current month exist before logs arrive
+	partitionTask := asynq.NewTask(queue.TaskTypeMaintainPartitions, nil)
+	if _, err := asynqClient.Enqueue(partitionTask, asynq.Queue("default")); err != nil {
+		logger.Error("Failed to enqueue partition maintenance", zap.Error(err))
+	}
+
 	// Start periodic tasks scheduler
 	go startPeriodicTasks(asynqClient, logger)
 
@@ -211,6 +335,39 @@ func startPeriodicTasks(client *asynq.Client, logger *zap.Logger) {
 				}
 			}
 
+			// Schedule dunning daily at 1 AM, after the day's billing
+			if time.Now().Hour() == 1 && time.Now().Minute() == 0 {
+				dunningTask := asynq.NewTask(queue.TaskTypeRunDunning, nil)
+				if _, err := client.Enqueue(dunningTask, asynq.Queue("low")); err != nil {
+					logger.Error("Failed to enqueue dunning", zap.Error(err))
+				}
+			}
+
+			// Export metering records every 15 minutes, a few minutes after
+			// the hourly rollups have had a chance to close the last hour
+			if time.Now().Minute()%15 == 5 {
+				meteringTask := asynq.NewTask(queue.TaskTypeExportMetering, nil)
+				if _, err := client.Enqueue(meteringTask, asynq.Queue("low")); err != nil {
+					logger.Error("Failed to enqueue metering export", zap.Error(err))
+				}
+			}
+
+			// Expire prepaid credits hourly
+			if time.Now().Minute() == 10 {
+				creditTask := asynq.NewTask(queue.TaskTypeExpireCredits, nil)
+				if _, err := client.Enqueue(creditTask, asynq.Queue("low")); err != nil {
+					logger.Error("Failed to enqueue credit expiry", zap.Error(err))
+				}
+			}
+
+			// Schedule partition maintenance daily at 2 AM
+			if time.Now().Hour() == 2 && time.Now().Minute() == 0 {
+				partitionTask := asynq.NewTask(queue.TaskTypeMaintainPartitions, nil)
+				if _, err := client.Enqueue(partitionTask, asynq.Queue("default")); err != nil {
+					logger.Error("Failed to enqueue partition maintenance", zap.Error(err))
+				}
+			}
+
 			// Schedule cleanup daily at 3 AM
 			if time.Now().Hour() == 3 && time.Now().Minute() == 0 {
 				cleanupTask := asynq.NewTask(queue.TaskTypeCleanupExpiredData, nil)
@@ -34,6 +34,11 @@ func (c *cacheService) IncrementCounter(ctx context.Context, key string, delta i
 	return c.redis.IncrementCounter(ctx, key, delta, expiration)
 }
 
+// IncrementWindowCounter increments a counter whose expiration is fixed when it is created
+func (c *cacheService) IncrementWindowCounter(ctx context.Context, key string, delta int64, window time.Duration) (int64, error) {
+	return c.redis.IncrementWindowCounter(ctx, key, delta, window)
+}
+
 // DeleteKey removes a key
 func (c *cacheService) DeleteKey(ctx context.Context, key string) error {
 	return c.redis.DeleteKey(ctx, key)
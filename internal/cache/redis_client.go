@@ -127,6 +127,22 @@ func (r *RedisClient) IncrementCounter(ctx context.Context, key string, delta in
 	return incrCmd.Val(), nil
 }
 
+// IncrementWindowCounter increments a counter and returns the new value. The
+// expiration is only set when the counter is created, so the counter covers a
+// fixed window from its first increment.
+func (r *RedisClient) IncrementWindowCounter(ctx context.Context, key string, delta int64, window time.Duration) (int64, error) {
+	pipe := r.client.TxPipeline()
+	incrCmd := pipe.IncrBy(ctx, key, delta)
+	pipe.ExpireNX(ctx, key, window)
+
+	_, err := pipe.Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to increment counter: %w", err)
+	}
+
+	return incrCmd.Val(), nil
+}
+
 // DecrementCounter decrements a counter and returns the new value
 func (r *RedisClient) DecrementCounter(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
 	return r.IncrementCounter(ctx, key, -delta, expiration)
@@ -11,18 +11,22 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	App        AppConfig        `mapstructure:"app"`
-	Server     ServerConfig     `mapstructure:"server"`
-	Worker     WorkerConfig     `mapstructure:"worker"`
-	Database   DatabaseConfig   `mapstructure:"database"`
-	Redis      RedisConfig      `mapstructure:"redis"`
-	RateLimit  RateLimitConfig  `mapstructure:"rate_limiter"`
-	Asynq      AsynqConfig      `mapstructure:"asynq"`
-	Metrics    MetricsConfig    `mapstructure:"metrics"`
-	Logging    LoggingConfig    `mapstructure:"logging"`
-	Security   SecurityConfig   `mapstructure:"security"`
-	Alerts     AlertsConfig     `mapstructure:"alerts"`
-	Monitoring MonitoringConfig `mapstructure:"monitoring"`
+	App          AppConfig          `mapstructure:"app"`
+	Server       ServerConfig       `mapstructure:"server"`
+	Worker       WorkerConfig       `mapstructure:"worker"`
+	Database     DatabaseConfig     `mapstructure:"database"`
+	Redis        RedisConfig        `mapstructure:"redis"`
+	RateLimit    RateLimitConfig    `mapstructure:"rate_limiter"`
+	Asynq        AsynqConfig        `mapstructure:"asynq"`
+	UsageLogging UsageLoggingConfig `mapstructure:"usage_logging"`
+	Exports      ExportsConfig      `mapstructure:"exports"`
+	Metrics      MetricsConfig      `mapstructure:"metrics"`
+	Logging      LoggingConfig      `mapstructure:"logging"`
+	Security     SecurityConfig     `mapstructure:"security"`
+	Alerts       AlertsConfig       `mapstructure:"alerts"`
+	Billing      BillingConfig      `mapstructure:"billing"`
+	Metering     MeteringConfig     `mapstructure:"metering"`
+	Monitoring   MonitoringConfig   `mapstructure:"monitoring"`
 }
 
 type AppConfig struct {
@@ -32,9 +36,13 @@ type AppConfig struct {
 	Debug       bool   `mapstructure:"debug"`
 }
 
+// ServerConfig configures the API listeners. Port serves the data plane, the
+// rate limit check and validate endpoints; AdminPort serves the admin API,
+// which holds the management endpoints and authenticates with JWTs.
 type ServerConfig struct {
 	Host            string        `mapstructure:"host"`
 	Port            int           `mapstructure:"port"`
+	AdminPort       int           `mapstructure:"admin_port"`
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
@@ -49,19 +57,33 @@ type WorkerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Host              string        `mapstructure:"host"`
-	Port              int           `mapstructure:"port"`
-	User              string        `mapstructure:"user"`
-	Password          string        `mapstructure:"password"`
-	Name              string        `mapstructure:"name"`
-	SSLMode           string        `mapstructure:"sslmode"`
-	Timezone          string        `mapstructure:"timezone"`
-	MaxOpenConns      int           `mapstructure:"max_open_conns"`
-	MaxIdleConns      int           `mapstructure:"max_idle_conns"`
-	ConnMaxLifetime   time.Duration `mapstructure:"conn_max_lifetime"`
-	ConnMaxIdleTime   time.Duration `mapstructure:"conn_max_idle_time"`
-	AutoMigrate       bool          `mapstructure:"auto_migrate"`
-	LogLevel          string        `mapstructure:"log_level"`
+	Host              string          `mapstructure:"host"`
+	Port              int             `mapstructure:"port"`
+	User              string          `mapstructure:"user"`
+	Password          string          `mapstructure:"password"`
+	Name              string          `mapstructure:"name"`
+	SSLMode           string          `mapstructure:"sslmode"`
+	Timezone          string          `mapstructure:"timezone"`
+	MaxOpenConns      int             `mapstructure:"max_open_conns"`
+	MaxIdleConns      int             `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime   time.Duration   `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime   time.Duration   `mapstructure:"conn_max_idle_time"`
+	AutoMigrate       bool            `mapstructure:"auto_migrate"`
+	LogLevel          string          `mapstructure:"log_level"`
+	Partitions        PartitionConfig `mapstructure:"partitions"`
+}
+
+// PartitionConfig controls monthly partition maintenance for the partitioned
+// usage_logs and rate_limit_violations tables. PremakeMonths is how many months
+// ahead partitions are created. Partitions entirely older than the table's
+// RetentionDays are detached and then dropped, or moved to ArchiveSchema when
+// RetentionAction is "archive".
+type PartitionConfig struct {
+	Enabled         bool           `mapstructure:"enabled"`
+	PremakeMonths   int            `mapstructure:"premake_months"`
+	RetentionAction string         `mapstructure:"retention_action"`
+	ArchiveSchema   string         `mapstructure:"archive_schema"`
+	RetentionDays   map[string]int `mapstructure:"retention_days"`
 }
 
 type RedisConfig struct {
@@ -85,6 +107,20 @@ type RateLimitConfig struct {
 	DefaultLimits    map[string]RateLimitTier  `mapstructure:"default_limits"`
 	KeyPrefix        string                    `mapstructure:"key_prefix"`
 	CleanupInterval  time.Duration             `mapstructure:"cleanup_interval"`
+	Penalty          PenaltyConfig             `mapstructure:"penalty"`
+}
+
+// PenaltyConfig configures escalating cooldowns for repeat violators
+type PenaltyConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`
+	ViolationThreshold int           `mapstructure:"violation_threshold"`
+	ViolationWindow    time.Duration `mapstructure:"violation_window"`
+	BaseCooldown       time.Duration `mapstructure:"base_cooldown"`
+	MaxCooldown        time.Duration `mapstructure:"max_cooldown"`
+	Multiplier         float64       `mapstructure:"multiplier"`
+	LimitFactor        float64       `mapstructure:"limit_factor"`
+	MaxLevel           int           `mapstructure:"max_level"`
+	DecayInterval      time.Duration `mapstructure:"decay_interval"`
 }
 
 type RateLimitTier struct {
@@ -104,6 +140,36 @@ type AsynqConfig struct {
 	HealthCheckInterval time.Duration     `mapstructure:"healthcheck_interval"`
 }
 
+// UsageLoggingConfig configures the buffered usage logging pipeline.
+// Sink is "database" to write batches directly or "queue" to hand them to the
+// worker as asynq tasks. Overflow is "drop" or "block". RollupLateness is how
+// long the worker waits before a minute of usage is rolled up, and
+// RollupRestatement how far back each run rolls usage up again to count
+// events that arrived late, such as batches retried on the task queue.
+type UsageLoggingConfig struct {
+	Sink              string        `mapstructure:"sink"`
+	Capacity          int           `mapstructure:"capacity"`
+	BatchSize         int           `mapstructure:"batch_size"`
+	FlushInterval     time.Duration `mapstructure:"flush_interval"`
+	FlushTimeout      time.Duration `mapstructure:"flush_timeout"`
+	Overflow          string        `mapstructure:"overflow"`
+	BlockTimeout      time.Duration `mapstructure:"block_timeout"`
+	Queue             string        `mapstructure:"queue"`
+	RollupLateness    time.Duration `mapstructure:"rollup_lateness"`
+	RollupRestatement time.Duration `mapstructure:"rollup_restatement"`
+}
+
+// ExportsConfig configures usage log exports. Files are written to the
+// StorageDir of the local blob store. Exports covering more than SyncMaxRange
+// or SyncMaxRecords logs run on the worker from Queue instead of in the request.
+type ExportsConfig struct {
+	StorageDir     string        `mapstructure:"storage_dir"`
+	SyncMaxRange   time.Duration `mapstructure:"sync_max_range"`
+	SyncMaxRecords int64         `mapstructure:"sync_max_records"`
+	BatchSize      int           `mapstructure:"batch_size"`
+	Queue          string        `mapstructure:"queue"`
+}
+
 type MetricsConfig struct {
 	Enabled   bool   `mapstructure:"enabled"`
 	Path      string `mapstructure:"path"`
@@ -129,6 +195,12 @@ type SecurityConfig struct {
 	JWTSecret    string     `mapstructure:"jwt_secret"`
 	JWTExpiry    time.Duration `mapstructure:"jwt_expiry"`
 	CORS         CORSConfig `mapstructure:"cors"`
+
+	// AutoBanDuration is how long an API key is banned after a critical rate limit violation
+	AutoBanDuration time.Duration `mapstructure:"auto_ban_duration"`
+
+	// TokenRateLimit is how many admin token requests each client IP may make per minute
+	TokenRateLimit int `mapstructure:"token_rate_limit"`
 }
 
 type CORSConfig struct {
@@ -142,6 +214,19 @@ type AlertsConfig struct {
 	Enabled       bool              `mapstructure:"enabled"`
 	Thresholds    AlertThresholds   `mapstructure:"thresholds"`
 	Notifications NotificationConfig `mapstructure:"notifications"`
+	Anomaly       AnomalyConfig      `mapstructure:"anomaly"`
+}
+
+// AnomalyConfig tunes the detector behind the traffic spike and new access
+// alert rules
+type AnomalyConfig struct {
+	Enabled             bool    `mapstructure:"enabled"`
+	Smoothing           float64 `mapstructure:"smoothing"`
+	SpikeSigmas         float64 `mapstructure:"spike_sigmas"`
+	MinSpikeRequests    int64   `mapstructure:"min_spike_requests"`
+	MinBaselineDays     int     `mapstructure:"min_baseline_days"`
+	MinBaselineRequests int64   `mapstructure:"min_baseline_requests"`
+	MaxEvidence         int     `mapstructure:"max_evidence"`
 }
 
 type AlertThresholds struct {
@@ -150,10 +235,142 @@ type AlertThresholds struct {
 	RateLimitViolations  int `mapstructure:"rate_limit_violations"`
 }
 
+// NotificationConfig configures the channels alerts are delivered to. The
+// webhook channel is enabled by WebhookURL, Slack and email by their flags and
+// incidents by Incident.Enabled. Each channel receives alerts of at least its
+// MinSeverity. The worker delivers notifications from Queue, retrying failed
+// deliveries up to MaxRetries times.
 type NotificationConfig struct {
 	WebhookURL   string `mapstructure:"webhook_url"`
 	EmailEnabled bool   `mapstructure:"email_enabled"`
 	SlackEnabled bool   `mapstructure:"slack_enabled"`
+
+	Queue      string                `mapstructure:"queue"`
+	MaxRetries int                   `mapstructure:"max_retries"`
+	Timeout    time.Duration         `mapstructure:"timeout"`
+	Templates  NotificationTemplates `mapstructure:"templates"`
+	Webhook    WebhookChannelConfig  `mapstructure:"webhook"`
+	Slack      SlackChannelConfig    `mapstructure:"slack"`
+	Email      EmailChannelConfig    `mapstructure:"email"`
+	Incident   IncidentChannelConfig `mapstructure:"incident"`
+}
+
+// NotificationTemplates are text/template sources for alert messages; empty
+// templates use the built-in ones
+type NotificationTemplates struct {
+	Subject string `mapstructure:"subject"`
+	Body    string `mapstructure:"body"`
+}
+
+// WebhookChannelConfig configures the generic webhook. Requests are signed
+// with Secret when it is set.
+type WebhookChannelConfig struct {
+	Secret      string `mapstructure:"secret"`
+	MinSeverity string `mapstructure:"min_severity"`
+}
+
+// SlackChannelConfig configures the Slack incoming webhook
+type SlackChannelConfig struct {
+	WebhookURL  string `mapstructure:"webhook_url"`
+	Channel     string `mapstructure:"channel"`
+	MinSeverity string `mapstructure:"min_severity"`
+}
+
+// EmailChannelConfig configures SMTP email
+type EmailChannelConfig struct {
+	SMTPHost    string   `mapstructure:"smtp_host"`
+	SMTPPort    int      `mapstructure:"smtp_port"`
+	Username    string   `mapstructure:"username"`
+	Password    string   `mapstructure:"password"`
+	From        string   `mapstructure:"from"`
+	To          []string `mapstructure:"to"`
+	MinSeverity string   `mapstructure:"min_severity"`
+}
+
+// IncidentChannelConfig configures an Events-API-style incident service such
+// as PagerDuty
+type IncidentChannelConfig struct {
+	Enabled     bool   `mapstructure:"enabled"`
+	URL         string `mapstructure:"url"`
+	RoutingKey  string `mapstructure:"routing_key"`
+	Source      string `mapstructure:"source"`
+	MinSeverity string `mapstructure:"min_severity"`
+}
+
+// BillingConfig configures billing. Invoices are due PaymentTermsDays after
+// their billing period ends.
+type BillingConfig struct {
+	PaymentTermsDays int            `mapstructure:"payment_terms_days"`
+	Invoice          InvoiceConfig  `mapstructure:"invoice"`
+	Payments         PaymentsConfig `mapstructure:"payments"`
+	Dunning          DunningConfig  `mapstructure:"dunning"`
+	Credits          CreditsConfig  `mapstructure:"credits"`
+}
+
+// InvoiceConfig configures rendered invoices. TemplateFile is an html/template
+// file for HTML invoices; empty uses the built-in template.
+type InvoiceConfig struct {
+	IssuerName    string `mapstructure:"issuer_name"`
+	IssuerAddress string `mapstructure:"issuer_address"`
+	TemplateFile  string `mapstructure:"template_file"`
+}
+
+// PaymentsConfig configures the payment provider: "stripe" for a
+// Stripe-compatible API at APIURL, "fake" for an in-memory provider, or empty
+// to disable payments. Webhooks are signed with WebhookSecret and rejected
+// when older than WebhookTolerance.
+type PaymentsConfig struct {
+	Provider         string        `mapstructure:"provider"`
+	APIURL           string        `mapstructure:"api_url"`
+	SecretKey        string        `mapstructure:"secret_key"`
+	WebhookSecret    string        `mapstructure:"webhook_secret"`
+	WebhookTolerance time.Duration `mapstructure:"webhook_tolerance"`
+	Timeout          time.Duration `mapstructure:"timeout"`
+}
+
+// DunningConfig configures the handling of unpaid invoices. API keys are
+// suspended once an invoice is SuspendAfterDays past its due date, and
+// reinstated when their overdue invoices are settled; zero never suspends.
+type DunningConfig struct {
+	SuspendAfterDays int `mapstructure:"suspend_after_days"`
+}
+
+// CreditsConfig configures prepaid credits. Each request of an API key with a
+// credit account, or whose team has one, costs RequestCost credits, or the
+// cost of its tier in TierRequestCosts. Requests are rejected once the balance
+// or the account's monthly spend cap cannot cover them. Billing overage
+// alerts are raised as the balance falls to each of AlertThresholds, unless
+// the account sets its own. While the credit store is unavailable, requests
+// are let through uncharged.
+type CreditsConfig struct {
+	Enabled          bool               `mapstructure:"enabled"`
+	RequestCost      float64            `mapstructure:"request_cost"`
+	TierRequestCosts map[string]float64 `mapstructure:"tier_request_costs"`
+	AlertThresholds  []float64          `mapstructure:"alert_thresholds"`
+}
+
+// MeteringConfig configures the metering event stream exporting hourly usage
+// records to external billing systems. Every record is signed with
+// SigningSecret. Each sink receives records in batches of up to BatchSize;
+// one run generates and delivers at most MaxHoursPerRun hours.
+type MeteringConfig struct {
+	Enabled        bool                 `mapstructure:"enabled"`
+	SigningSecret  string               `mapstructure:"signing_secret"`
+	BatchSize      int                  `mapstructure:"batch_size"`
+	MaxHoursPerRun int                  `mapstructure:"max_hours_per_run"`
+	Timeout        time.Duration        `mapstructure:"timeout"`
+	Sinks          []MeteringSinkConfig `mapstructure:"sinks"`
+}
+
+// MeteringSinkConfig configures one metering sink. Type is "webhook" to POST
+// batches to URL, "kafka" to produce to Topic through the Kafka REST proxy at
+// URL, or "file" to write JSON lines files under Directory.
+type MeteringSinkConfig struct {
+	Name      string `mapstructure:"name"`
+	Type      string `mapstructure:"type"`
+	URL       string `mapstructure:"url"`
+	Topic     string `mapstructure:"topic"`
+	Directory string `mapstructure:"directory"`
 }
 
 type MonitoringConfig struct {
@@ -229,6 +446,14 @@ func validateConfig(cfg *Config) error {
 		return fmt.Errorf("rate_limiter.key_prefix is required")
 	}
 
+	if cfg.Server.AdminPort <= 0 || cfg.Server.AdminPort > 65535 {
+		return fmt.Errorf("server.admin_port must be between 1 and 65535")
+	}
+
+	if cfg.Server.AdminPort == cfg.Server.Port {
+		return fmt.Errorf("server.admin_port must differ from server.port")
+	}
+
 	return nil
 }
 
@@ -253,6 +478,11 @@ func (s *ServerConfig) GetServerAddress() string {
 	return fmt.Sprintf("%s:%d", s.Host, s.Port)
 }
 
+// GetAdminAddress returns the full admin API address
+func (s *ServerConfig) GetAdminAddress() string {
+	return fmt.Sprintf("%s:%d", s.Host, s.AdminPort)
+}
+
 // GetWorkerAddress returns the full worker address
 func (w *WorkerConfig) GetWorkerAddress() string {
 	return fmt.Sprintf(":%d", w.Port)
@@ -146,6 +146,16 @@ func TestServerConfig_GetServerAddress(t *testing.T) {
 	assert.Equal(t, expected, actual)
 }
 
+func TestServerConfig_GetAdminAddress(t *testing.T) {
+	serverConfig := &ServerConfig{
+		Host:      "0.0.0.0",
+		Port:      8080,
+		AdminPort: 8082,
+	}
+
+	assert.Equal(t, "0.0.0.0:8082", serverConfig.GetAdminAddress())
+}
+
 func TestWorkerConfig_GetWorkerAddress(t *testing.T) {
 	workerConfig := &WorkerConfig{
 		Port: 8081,
@@ -164,7 +174,8 @@ func TestValidateConfig(t *testing.T) {
 				Name: "test-app",
 			},
 			Server: ServerConfig{
-				Port: 8080,
+				Port:      8080,
+				AdminPort: 8082,
 			},
 			Database: DatabaseConfig{
 				Host: "localhost",
@@ -270,4 +281,30 @@ func TestValidateConfig(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "rate_limiter.key_prefix is required")
 	})
-}
\ No newline at end of file
+	t.Run("invalid admin port", func(t *testing.T) {
+		for _, adminPort := range []int{0, 70000, 8080} {
+			cfg := &Config{
+				App: AppConfig{
+					Name: "test-app",
+				},
+				Server: ServerConfig{
+					Port:      8080,
+					AdminPort: adminPort,
+				},
+				Database: DatabaseConfig{
+					Host: "localhost",
+				},
+				Redis: RedisConfig{
+					Addresses: []string{"localhost:6379"},
+				},
+				RateLimit: RateLimitConfig{
+					KeyPrefix: "test:",
+				},
+			}
+
+			err := validateConfig(cfg)
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), "server.admin_port")
+		}
+	})
+}
@@ -0,0 +1,265 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// AccessControlController handles allowlist, blocklist and ban endpoints.
+// Rules apply across every organization, so only platform operators may use
+// them.
+type AccessControlController struct {
+	accessControlService services.AccessControlService
+	authz                *services.AuthorizationService
+}
+
+// NewAccessControlController creates a new access control controller
+func NewAccessControlController(accessControlService services.AccessControlService, authz *services.AuthorizationService) *AccessControlController {
+	return &AccessControlController{
+		accessControlService: accessControlService,
+		authz:                authz,
+	}
+}
+
+// CreateAccessRule creates an allow or deny rule
+// @Summary Create access rule
+// @Description Add an API key, IP, CIDR, user agent or country to the allow or deny list. Set duration_seconds for a temporary ban or exemption.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body CreateAccessRuleRequest true "Create access rule request"
+// @Success 201 {object} models.AccessRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/access-rules [post]
+func (ctrl *AccessControlController) CreateAccessRule(c *gin.Context) {
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	var req CreateAccessRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	serviceReq := &services.CreateAccessRuleRequest{
+		ListType:  req.ListType,
+		MatchType: req.MatchType,
+		Value:     req.Value,
+		Reason:    req.Reason,
+		Duration:  time.Duration(req.DurationSeconds) * time.Second,
+	}
+	if apiKeyID, exists := c.Get("api_key_id"); exists {
+		serviceReq.CreatedBy = fmt.Sprintf("%v", apiKeyID)
+	}
+
+	rule, err := ctrl.accessControlService.CreateRule(c.Request.Context(), serviceReq)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to create access rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// GetAccessRule retrieves an access rule by ID
+// @Summary Get access rule
+// @Description Get an allow or deny rule by ID
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Access rule ID"
+// @Success 200 {object} models.AccessRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/access-rules/{id} [get]
+func (ctrl *AccessControlController) GetAccessRule(c *gin.Context) {
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid access rule ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rule, err := ctrl.accessControlService.GetRule(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "access rule not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Access rule not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get access rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAccessRule removes an access rule, lifting a ban immediately
+// @Summary Delete access rule
+// @Description Remove an allow or deny rule. Deleting an automatic ban lifts it immediately.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Access rule ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/access-rules/{id} [delete]
+func (ctrl *AccessControlController) DeleteAccessRule(c *gin.Context) {
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid access rule ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.accessControlService.DeleteRule(c.Request.Context(), id); err != nil {
+		if err.Error() == "access rule not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Access rule not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete access rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Access rule deleted successfully",
+	})
+}
+
+// ListAccessRules lists access rules with filtering and pagination
+// @Summary List access rules
+// @Description List allow and deny rules, including automatic bans
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param list_type query string false "Filter by list type (allow, deny)"
+// @Param match_type query string false "Filter by match type (api_key, ip, cidr, user_agent, country)"
+// @Param source query string false "Filter by source (manual, automatic)"
+// @Param active query bool false "Only rules that have not expired"
+// @Param search query string false "Search value and reason"
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/access-rules [get]
+func (ctrl *AccessControlController) ListAccessRules(c *gin.Context) {
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	// Parse pagination parameters
+	page := 1
+	pageSize := 20
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 && ps <= 100 {
+			pageSize = ps
+		}
+	}
+
+	pagination := &repositories.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+		OrderBy:  "created_at",
+		Order:    c.DefaultQuery("order", "desc"),
+	}
+
+	// Parse filter parameters
+	filter := &repositories.AccessRuleFilter{
+		Search: c.Query("search"),
+	}
+
+	if listType := c.Query("list_type"); listType != "" {
+		lt := models.AccessListType(listType)
+		filter.ListType = &lt
+	}
+
+	if matchType := c.Query("match_type"); matchType != "" {
+		mt := models.AccessMatchType(matchType)
+		filter.MatchType = &mt
+	}
+
+	if source := c.Query("source"); source != "" {
+		src := models.AccessRuleSource(source)
+		filter.Source = &src
+	}
+
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil && active {
+		now := time.Now()
+		filter.ActiveAt = &now
+	}
+
+	result, err := ctrl.accessControlService.ListRules(c.Request.Context(), filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list access rules",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Request/Response types
+
+// CreateAccessRuleRequest represents a create access rule request
+type CreateAccessRuleRequest struct {
+	ListType        models.AccessListType  `json:"list_type" binding:"required,oneof=allow deny"`
+	MatchType       models.AccessMatchType `json:"match_type" binding:"required,oneof=api_key ip cidr user_agent country"`
+	Value           string                 `json:"value" binding:"required"`
+	Reason          string                 `json:"reason"`
+	DurationSeconds int                    `json:"duration_seconds" binding:"min=0"`
+}
@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// AdminAuthController handles sign-in to the admin API
+type AdminAuthController struct {
+	adminAuthService *services.AdminAuthService
+}
+
+// NewAdminAuthController creates a new admin authentication controller
+func NewAdminAuthController(adminAuthService *services.AdminAuthService) *AdminAuthController {
+	return &AdminAuthController{
+		adminAuthService: adminAuthService,
+	}
+}
+
+// IssueToken exchanges an API key for an admin API token
+// @Summary Issue admin token
+// @Description Sign in to the admin API with an API key and receive a bearer token for it. The token carries no permissions of its own: requests made with it have the scopes, role and organization of the key, which is checked again on every request.
+// @Tags admin-auth
+// @Produce json
+// @Param Authorization header string true "Bearer API_KEY"
+// @Success 200 {object} services.AdminToken
+// @Failure 401 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/token [post]
+func (ctrl *AdminAuthController) IssueToken(c *gin.Context) {
+	apiKey, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || apiKey == "" {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Missing API key",
+			Message: "Authorization header must be in format 'Bearer API_KEY'",
+		})
+		return
+	}
+
+	token, err := ctrl.adminAuthService.IssueToken(c.Request.Context(), apiKey)
+	if err != nil {
+		writeAdminAuthError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, token)
+}
+
+// writeAdminAuthError maps errors from the admin authentication service to
+// HTTP status codes
+func writeAdminAuthError(c *gin.Context, err error) {
+	switch err.Error() {
+	case "invalid api key format", "invalid api key", "api key is not active", "api key has expired":
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Invalid API key",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to issue token",
+			Message: err.Error(),
+		})
+	}
+}
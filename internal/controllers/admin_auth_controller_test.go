@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminAuthController_IssueTokenRequiresAPIKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewAdminAuthController(nil)
+	router := gin.New()
+	router.POST("/auth/token", ctrl.IssueToken)
+
+	for _, authorization := range []string{"", "Bearer ", "Basic dXNlcjpwYXNz"} {
+		req := httptest.NewRequest(http.MethodPost, "/auth/token", nil)
+		req.Header.Set("Authorization", authorization)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, authorization)
+	}
+}
+
+func TestWriteAdminAuthError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{fmt.Errorf("invalid api key"), http.StatusUnauthorized},
+		{fmt.Errorf("api key is not active"), http.StatusUnauthorized},
+		{fmt.Errorf("api key has expired"), http.StatusUnauthorized},
+		{fmt.Errorf("failed to sign admin token: boom"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			writeAdminAuthError(c, tt.err)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
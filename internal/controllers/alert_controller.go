@@ -0,0 +1,593 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// alertSortFields are the columns alerts can be ordered by
+var alertSortFields = map[string]bool{
+	"created_at":   true,
+	"updated_at":   true,
+	"last_seen_at": true,
+	"severity":     true,
+	"occurrences":  true,
+}
+
+// maxBulkResolve is the most alerts one bulk resolve request may name
+const maxBulkResolve = 100
+
+// maxSummaryHours is the longest window an alert summary may cover
+const maxSummaryHours = 24 * 90
+
+// AlertController handles alert endpoints
+type AlertController struct {
+	alertService services.AlertService
+	authz        *services.AuthorizationService
+}
+
+// NewAlertController creates a new alert controller
+func NewAlertController(alertService services.AlertService, authz *services.AuthorizationService) *AlertController {
+	return &AlertController{
+		alertService: alertService,
+		authz:        authz,
+	}
+}
+
+// BulkResolveRequest names the alerts to resolve
+type BulkResolveRequest struct {
+	IDs []uuid.UUID `json:"ids" binding:"required,min=1"`
+}
+
+// SnoozeAlertRequest sets how long an alert is snoozed. Exactly one of until
+// and duration_minutes is given.
+type SnoozeAlertRequest struct {
+	Until           *time.Time `json:"until"`
+	DurationMinutes int        `json:"duration_minutes"`
+}
+
+// ListAlerts lists alerts with filtering and pagination
+// @Summary List alerts
+// @Description List alerts, newest first by default. Listing the alerts of every API key requires an operator key; other callers filter by one of their organization's keys.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param order_by query string false "Sort field: created_at, updated_at, last_seen_at, severity or occurrences" default(created_at)
+// @Param order query string false "Sort order: asc or desc" default(desc)
+// @Param api_key_id query string false "Filter by API key ID"
+// @Param rule_id query string false "Filter by alert rule ID"
+// @Param type query string false "Filter by alert type"
+// @Param severity query string false "Filter by severity"
+// @Param status query string false "Filter by status: open, acknowledged, snoozed or resolved"
+// @Param resolved query bool false "Filter by resolution"
+// @Param start_time query string false "Only alerts created at or after this time (RFC3339)"
+// @Param end_time query string false "Only alerts created at or before this time (RFC3339)"
+// @Param search query string false "Search alert messages"
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts [get]
+func (ctrl *AlertController) ListAlerts(c *gin.Context) {
+	pagination, ok := parseAlertPagination(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseAlertFilter(c)
+	if !ok {
+		return
+	}
+
+	if apiKeyID := c.Query("api_key_id"); apiKeyID != "" {
+		id, err := uuid.Parse(apiKeyID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid API key ID",
+				Message: err.Error(),
+			})
+			return
+		}
+		if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionRead); !ok {
+			return
+		}
+		filter.APIKeyID = &id
+	} else if !requirePlatform(c, ctrl.authz) {
+		// Alerts of every API key span every organization
+		return
+	}
+
+	result, err := ctrl.alertService.ListAlerts(c.Request.Context(), filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list alerts",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAPIKeyAlerts lists the alerts raised for one API key
+// @Summary Get API key alerts
+// @Description List the alerts raised for an API key, newest first by default. Takes the same filters as the alert list.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param order_by query string false "Sort field: created_at, updated_at, last_seen_at, severity or occurrences" default(created_at)
+// @Param order query string false "Sort order: asc or desc" default(desc)
+// @Param rule_id query string false "Filter by alert rule ID"
+// @Param type query string false "Filter by alert type"
+// @Param severity query string false "Filter by severity"
+// @Param status query string false "Filter by status"
+// @Param resolved query bool false "Filter by resolution"
+// @Param start_time query string false "Only alerts created at or after this time (RFC3339)"
+// @Param end_time query string false "Only alerts created at or before this time (RFC3339)"
+// @Param search query string false "Search alert messages"
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/keys/{api_key_id} [get]
+func (ctrl *AlertController) GetAPIKeyAlerts(c *gin.Context) {
+	apiKeyID, err := uuid.Parse(c.Param("api_key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid API key ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	pagination, ok := parseAlertPagination(c)
+	if !ok {
+		return
+	}
+
+	filter, ok := parseAlertFilter(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionRead); !ok {
+		return
+	}
+	filter.APIKeyID = &apiKeyID
+
+	result, err := ctrl.alertService.ListAlerts(c.Request.Context(), filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list alerts",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAlert retrieves an alert by ID
+// @Summary Get alert
+// @Description Get an alert by ID, including its notification deliveries
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} services.AlertResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/{id} [get]
+func (ctrl *AlertController) GetAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	alert, ok := ctrl.authorizeAlert(c, id, services.KeyActionRead, "Failed to get alert")
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// GetAlertsSummary retrieves alert statistics for a dashboard
+// @Summary Get alerts summary
+// @Description Count the alerts raised in the last hours by severity, type and status, list the API keys raising the most, and report the average time to acknowledge and resolve. Requires an operator key.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param hours query int false "Hours to summarize, at most 2160" default(24)
+// @Success 200 {object} repositories.AlertsSummary
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/summary [get]
+func (ctrl *AlertController) GetAlertsSummary(c *gin.Context) {
+	hours := 24
+	if hoursStr := c.Query("hours"); hoursStr != "" {
+		h, err := strconv.Atoi(hoursStr)
+		if err != nil || h <= 0 || h > maxSummaryHours {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid hours",
+				Message: fmt.Sprintf("hours must be between 1 and %d", maxSummaryHours),
+			})
+			return
+		}
+		hours = h
+	}
+
+	// The summary covers the alerts of every organization
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	summary, err := ctrl.alertService.GetAlertsSummary(c.Request.Context(), hours)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get alerts summary",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// ResolveAlert resolves an alert
+// @Summary Resolve alert
+// @Description Resolve an alert. The caller's API key is recorded as the resolver.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/{id}/resolve [post]
+func (ctrl *AlertController) ResolveAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := ctrl.authorizeAlert(c, id, services.KeyActionWrite, "Failed to resolve alert"); !ok {
+		return
+	}
+
+	if err := ctrl.alertService.ResolveAlert(c.Request.Context(), id, callerID(c)); err != nil {
+		writeAlertError(c, "Failed to resolve alert", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Alert resolved successfully",
+	})
+}
+
+// BulkResolveAlerts resolves several alerts at once
+// @Summary Bulk resolve alerts
+// @Description Resolve up to 100 alerts of any API key. Alerts already resolved, or that do not exist, are skipped. Requires an operator key.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body BulkResolveRequest true "Alerts to resolve"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/resolve [post]
+func (ctrl *AlertController) BulkResolveAlerts(c *gin.Context) {
+	var req BulkResolveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+	if len(req.IDs) > maxBulkResolve {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Too many alerts",
+			Message: fmt.Sprintf("at most %d alerts can be resolved at once", maxBulkResolve),
+		})
+		return
+	}
+
+	// The alerts may belong to any organization
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	if err := ctrl.alertService.ResolveAlerts(c.Request.Context(), req.IDs, callerID(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to resolve alerts",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Alerts resolved successfully",
+	})
+}
+
+// AcknowledgeAlert acknowledges an alert
+// @Summary Acknowledge alert
+// @Description Mark an alert as being handled, which stops its escalation. The caller's API key is recorded as acknowledging it.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {object} services.AlertResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/{id}/acknowledge [post]
+func (ctrl *AlertController) AcknowledgeAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	if _, ok := ctrl.authorizeAlert(c, id, services.KeyActionWrite, "Failed to acknowledge alert"); !ok {
+		return
+	}
+
+	alert, err := ctrl.alertService.AcknowledgeAlert(c.Request.Context(), id, callerID(c))
+	if err != nil {
+		writeAlertError(c, "Failed to acknowledge alert", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// SnoozeAlert snoozes an alert
+// @Summary Snooze alert
+// @Description Silence an alert until a time, or for a number of minutes. It opens again and is notified anew when the snooze ends.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param request body SnoozeAlertRequest true "Snooze request"
+// @Success 200 {object} services.AlertResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alerts/{id}/snooze [post]
+func (ctrl *AlertController) SnoozeAlert(c *gin.Context) {
+	id, ok := parseAlertID(c)
+	if !ok {
+		return
+	}
+
+	var req SnoozeAlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var until time.Time
+	switch {
+	case req.Until != nil && req.DurationMinutes == 0:
+		until = *req.Until
+	case req.Until == nil && req.DurationMinutes > 0:
+		until = time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+	default:
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid snooze",
+			Message: "give either until or a positive duration_minutes",
+		})
+		return
+	}
+
+	if _, ok := ctrl.authorizeAlert(c, id, services.KeyActionWrite, "Failed to snooze alert"); !ok {
+		return
+	}
+
+	alert, err := ctrl.alertService.SnoozeAlert(c.Request.Context(), id, until)
+	if err != nil {
+		writeAlertError(c, "Failed to snooze alert", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// authorizeAlert loads an alert and checks that the caller may perform action
+// on its API key, responding with the error if not. Alerts of keys outside
+// the caller's organization are reported as not found.
+func (ctrl *AlertController) authorizeAlert(c *gin.Context, id uuid.UUID, action services.KeyAction, message string) (*services.AlertResponse, bool) {
+	principal, ok := principalFrom(c)
+	if !ok {
+		return nil, false
+	}
+
+	alert, err := ctrl.alertService.GetAlert(c.Request.Context(), id)
+	if err != nil {
+		writeAlertError(c, message, err)
+		return nil, false
+	}
+
+	if _, err := ctrl.authz.AuthorizeKey(c.Request.Context(), principal, alert.APIKeyID, action); err != nil {
+		if err.Error() == "api key not found" {
+			writeAlertError(c, message, errors.New("alert not found"))
+		} else {
+			writeAuthorizationError(c, err)
+		}
+		return nil, false
+	}
+	return alert, true
+}
+
+// parseAlertID parses the alert ID path parameter, writing a 400 response
+// when it is invalid
+func parseAlertID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid alert ID",
+			Message: err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseAlertPagination parses pagination and ordering, writing a 400
+// response when the sort field is unknown
+func parseAlertPagination(c *gin.Context) (*repositories.PaginationParams, bool) {
+	pagination := parseUsagePagination(c)
+	pagination.OrderBy = c.DefaultQuery("order_by", "created_at")
+	pagination.Order = c.DefaultQuery("order", "desc")
+
+	// OrderBy is written into the query, so only known columns are allowed
+	if !alertSortFields[pagination.OrderBy] {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid order_by",
+			Message: "order_by must be one of created_at, updated_at, last_seen_at, severity or occurrences",
+		})
+		return nil, false
+	}
+	return pagination, true
+}
+
+// parseAlertFilter parses the alert filter query parameters other than the
+// API key, writing a 400 response when one is invalid
+func parseAlertFilter(c *gin.Context) (*repositories.AlertFilter, bool) {
+	filter := &repositories.AlertFilter{
+		Search: c.Query("search"),
+	}
+
+	if ruleID := c.Query("rule_id"); ruleID != "" {
+		id, err := uuid.Parse(ruleID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid alert rule ID",
+				Message: err.Error(),
+			})
+			return nil, false
+		}
+		filter.RuleID = &id
+	}
+
+	if alertType := c.Query("type"); alertType != "" {
+		t := models.AlertType(alertType)
+		filter.Type = &t
+	}
+
+	if severity := c.Query("severity"); severity != "" {
+		sev := models.AlertSeverity(severity)
+		if !sev.IsValid() {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid severity",
+				Message: fmt.Sprintf("unknown severity: %s", severity),
+			})
+			return nil, false
+		}
+		filter.Severity = &sev
+	}
+
+	if status := c.Query("status"); status != "" {
+		st := models.AlertStatus(status)
+		if !st.IsValid() {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid status",
+				Message: fmt.Sprintf("unknown status: %s", status),
+			})
+			return nil, false
+		}
+		filter.Status = &st
+	}
+
+	if resolved, err := strconv.ParseBool(c.Query("resolved")); err == nil {
+		filter.Resolved = &resolved
+	}
+
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"start_time", &filter.StartTime},
+		{"end_time", &filter.EndTime},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid " + param.name,
+				Message: err.Error(),
+			})
+			return nil, false
+		}
+		*param.dst = &t
+	}
+
+	return filter, true
+}
+
+// callerID returns the ID of the API key making the request
+func callerID(c *gin.Context) string {
+	if apiKeyID, exists := c.Get("api_key_id"); exists {
+		return fmt.Sprintf("%v", apiKeyID)
+	}
+	return ""
+}
+
+// writeAlertError writes the response for an error from an alert operation
+func writeAlertError(c *gin.Context, message string, err error) {
+	switch err.Error() {
+	case "alert not found":
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Alert not found",
+			Message: err.Error(),
+		})
+	case "alert is already resolved":
+		c.JSON(http.StatusConflict, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+		})
+	case "snooze time must be in the future":
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+		})
+	}
+}
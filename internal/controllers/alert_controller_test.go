@@ -0,0 +1,226 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// alertKeyRepository finds API keys by ID
+type alertKeyRepository struct {
+	repositories.APIKeyRepository
+	apiKeys map[uuid.UUID]*models.APIKey
+}
+
+func (r *alertKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	apiKey, ok := r.apiKeys[id]
+	if !ok {
+		return nil, fmt.Errorf("api key not found")
+	}
+	return apiKey, nil
+}
+
+// fakeAlertService stores alerts in memory and records which were resolved
+type fakeAlertService struct {
+	services.AlertService
+	alerts   map[uuid.UUID]*services.AlertResponse
+	resolved []uuid.UUID
+}
+
+func (s *fakeAlertService) GetAlert(ctx context.Context, id uuid.UUID) (*services.AlertResponse, error) {
+	alert, ok := s.alerts[id]
+	if !ok {
+		return nil, fmt.Errorf("alert not found")
+	}
+	return alert, nil
+}
+
+func (s *fakeAlertService) ResolveAlert(ctx context.Context, id uuid.UUID, resolvedBy string) error {
+	s.resolved = append(s.resolved, id)
+	return nil
+}
+
+func (s *fakeAlertService) ListAlerts(ctx context.Context, filter *repositories.AlertFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	return &repositories.PaginatedResult{}, nil
+}
+
+func TestParseAlertFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/alerts?status=acknowledged&severity=high&resolved=false&start_time=2024-01-01T00:00:00Z&search=spike", nil)
+
+	filter, ok := parseAlertFilter(c)
+	require.True(t, ok)
+	require.NotNil(t, filter.Status)
+	assert.Equal(t, models.AlertStatusAcknowledged, *filter.Status)
+	require.NotNil(t, filter.Severity)
+	assert.Equal(t, models.AlertSeverityHigh, *filter.Severity)
+	require.NotNil(t, filter.Resolved)
+	assert.False(t, *filter.Resolved)
+	require.NotNil(t, filter.StartTime)
+	assert.Nil(t, filter.EndTime)
+	assert.Equal(t, "spike", filter.Search)
+}
+
+func TestParseAlertFilter_Invalid(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, query := range []string{
+		"status=closed",
+		"severity=urgent",
+		"rule_id=not-a-uuid",
+		"end_time=yesterday",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/admin/alerts?"+query, nil)
+
+		_, ok := parseAlertFilter(c)
+		assert.False(t, ok, query)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestParseAlertPagination_RejectsUnknownSortField(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/alerts?order_by=message+desc,+id", nil)
+
+	_, ok := parseAlertPagination(c)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/alerts?order_by=severity&order=asc&page=2", nil)
+
+	pagination, ok := parseAlertPagination(c)
+	require.True(t, ok)
+	assert.Equal(t, "severity asc", pagination.GetOrderBy())
+	assert.Equal(t, 2, pagination.Page)
+}
+
+func TestAlertController_BulkResolveLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ids := make([]string, maxBulkResolve+1)
+	for i := range ids {
+		ids[i] = `"00000000-0000-0000-0000-000000000001"`
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/admin/alerts/resolve", strings.NewReader(`{"ids":[`+strings.Join(ids, ",")+`]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	(&AlertController{}).BulkResolveAlerts(c)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestAlertController_SnoozeNeedsOneDuration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, body := range []string{
+		`{}`,
+		`{"duration_minutes":-5}`,
+		`{"until":"2030-01-01T00:00:00Z","duration_minutes":30}`,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "00000000-0000-0000-0000-000000000001"}}
+		c.Request = httptest.NewRequest("POST", "/admin/alerts/x/snooze", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+
+		(&AlertController{}).SnoozeAlert(c)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestAlertController_AuthorizesAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	orgID, otherOrg := uuid.New(), uuid.New()
+	ownKey := &models.APIKey{ID: uuid.New(), OrganizationID: &orgID}
+	foreignKey := &models.APIKey{ID: uuid.New(), OrganizationID: &otherOrg}
+	ownAlert := &services.AlertResponse{ID: uuid.New(), APIKeyID: ownKey.ID}
+	foreignAlert := &services.AlertResponse{ID: uuid.New(), APIKeyID: foreignKey.ID}
+
+	alertService := &fakeAlertService{alerts: map[uuid.UUID]*services.AlertResponse{
+		ownAlert.ID:     ownAlert,
+		foreignAlert.ID: foreignAlert,
+	}}
+	apiKeys := &alertKeyRepository{apiKeys: map[uuid.UUID]*models.APIKey{ownKey.ID: ownKey, foreignKey.ID: foreignKey}}
+	ctrl := NewAlertController(alertService, services.NewAuthorizationService(nil, apiKeys))
+
+	member := &services.Principal{
+		APIKey: ownKey,
+		User:   &models.User{ID: uuid.New(), OrganizationID: orgID, Role: models.UserRoleAdmin},
+	}
+	operator := &services.Principal{APIKey: &models.APIKey{ID: uuid.New(), IsOperator: true}, Platform: true}
+
+	tests := []struct {
+		name      string
+		principal *services.Principal
+		method    string
+		path      string
+		body      string
+		expected  int
+	}{
+		{"member reads own alert", member, http.MethodGet, "/admin/alerts/" + ownAlert.ID.String(), "", http.StatusOK},
+		{"member cannot read foreign alert", member, http.MethodGet, "/admin/alerts/" + foreignAlert.ID.String(), "", http.StatusNotFound},
+		{"member resolves own alert", member, http.MethodPost, "/admin/alerts/" + ownAlert.ID.String() + "/resolve", "", http.StatusOK},
+		{"member cannot resolve foreign alert", member, http.MethodPost, "/admin/alerts/" + foreignAlert.ID.String() + "/resolve", "", http.StatusNotFound},
+		{"member lists own key alerts", member, http.MethodGet, "/admin/alerts/keys/" + ownKey.ID.String(), "", http.StatusOK},
+		{"member cannot list foreign key alerts", member, http.MethodGet, "/admin/alerts/keys/" + foreignKey.ID.String(), "", http.StatusNotFound},
+		{"member filters list by own key", member, http.MethodGet, "/admin/alerts?api_key_id=" + ownKey.ID.String(), "", http.StatusOK},
+		{"member cannot filter list by foreign key", member, http.MethodGet, "/admin/alerts?api_key_id=" + foreignKey.ID.String(), "", http.StatusNotFound},
+		{"member cannot list every alert", member, http.MethodGet, "/admin/alerts", "", http.StatusForbidden},
+		{"member cannot summarize alerts", member, http.MethodGet, "/admin/alerts/summary", "", http.StatusForbidden},
+		{"member cannot bulk resolve", member, http.MethodPost, "/admin/alerts/resolve", `{"ids":["` + foreignAlert.ID.String() + `"]}`, http.StatusForbidden},
+		{"operator reads foreign alert", operator, http.MethodGet, "/admin/alerts/" + foreignAlert.ID.String(), "", http.StatusOK},
+		{"operator lists every alert", operator, http.MethodGet, "/admin/alerts", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alertService.resolved = nil
+
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Set("principal", tt.principal)
+			})
+			router.GET("/admin/alerts", ctrl.ListAlerts)
+			router.GET("/admin/alerts/summary", ctrl.GetAlertsSummary)
+			router.GET("/admin/alerts/keys/:api_key_id", ctrl.GetAPIKeyAlerts)
+			router.POST("/admin/alerts/resolve", ctrl.BulkResolveAlerts)
+			router.GET("/admin/alerts/:id", ctrl.GetAlert)
+			router.POST("/admin/alerts/:id/resolve", ctrl.ResolveAlert)
+
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected != http.StatusOK {
+				assert.Empty(t, alertService.resolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// AlertRuleController handles alert rule endpoints
+type AlertRuleController struct {
+	alertService services.AlertService
+}
+
+// NewAlertRuleController creates a new alert rule controller
+func NewAlertRuleController(alertService services.AlertService) *AlertRuleController {
+	return &AlertRuleController{
+		alertService: alertService,
+	}
+}
+
+// CreateAlertRule creates an alert rule
+// @Summary Create alert rule
+// @Description Create a rule that raises an alert when its conditions hold for an API key within its scope. Window based conditions look at the last time_window_minutes minutes.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body services.CreateAlertRuleRequest true "Create alert rule request"
+// @Success 201 {object} models.AlertRule
+// @Failure 400 {object} ErrorResponse
+// @Router /admin/alert-rules [post]
+func (ctrl *AlertRuleController) CreateAlertRule(c *gin.Context) {
+	var req services.CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// The creator is the caller, not whatever the body claims
+	req.CreatedBy = ""
+	if apiKeyID, exists := c.Get("api_key_id"); exists {
+		req.CreatedBy = fmt.Sprintf("%v", apiKeyID)
+	}
+
+	rule, err := ctrl.alertService.CreateRule(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to create alert rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// GetAlertRule retrieves an alert rule by ID
+// @Summary Get alert rule
+// @Description Get an alert rule by ID
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert rule ID"
+// @Success 200 {object} models.AlertRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alert-rules/{id} [get]
+func (ctrl *AlertRuleController) GetAlertRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid alert rule ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rule, err := ctrl.alertService.GetRule(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "alert rule not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Alert rule not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get alert rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// UpdateAlertRule updates an alert rule
+// @Summary Update alert rule
+// @Description Update an alert rule. Omitted fields are left unchanged; conditions and scope are replaced as a whole.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert rule ID"
+// @Param request body services.UpdateAlertRuleRequest true "Update alert rule request"
+// @Success 200 {object} models.AlertRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/alert-rules/{id} [put]
+func (ctrl *AlertRuleController) UpdateAlertRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid alert rule ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	var req services.UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	rule, err := ctrl.alertService.UpdateRule(c.Request.Context(), id, &req)
+	if err != nil {
+		if err.Error() == "alert rule not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Alert rule not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to update alert rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteAlertRule deletes an alert rule
+// @Summary Delete alert rule
+// @Description Delete an alert rule. Alerts it raised are kept.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert rule ID"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alert-rules/{id} [delete]
+func (ctrl *AlertRuleController) DeleteAlertRule(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid alert rule ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := ctrl.alertService.DeleteRule(c.Request.Context(), id); err != nil {
+		if err.Error() == "alert rule not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Alert rule not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete alert rule",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SuccessResponse{
+		Message: "Alert rule deleted successfully",
+	})
+}
+
+// ListAlertRules lists alert rules with filtering and pagination
+// @Summary List alert rules
+// @Description List alert rules, including the built-in defaults
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param type query string false "Filter by alert type"
+// @Param severity query string false "Filter by severity"
+// @Param enabled query bool false "Filter by enabled state"
+// @Param api_key_id query string false "Filter by API key scope"
+// @Param tier query string false "Filter by tier scope"
+// @Param team_id query string false "Filter by team scope"
+// @Param search query string false "Search name and description"
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/alert-rules [get]
+func (ctrl *AlertRuleController) ListAlertRules(c *gin.Context) {
+	// Parse pagination parameters
+	page := 1
+	pageSize := 20
+
+	if pageStr := c.Query("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if pageSizeStr := c.Query("page_size"); pageSizeStr != "" {
+		if ps, err := strconv.Atoi(pageSizeStr); err == nil && ps > 0 && ps <= 100 {
+			pageSize = ps
+		}
+	}
+
+	pagination := &repositories.PaginationParams{
+		Page:     page,
+		PageSize: pageSize,
+		OrderBy:  "created_at",
+		Order:    c.DefaultQuery("order", "desc"),
+	}
+
+	// Parse filter parameters
+	filter := &repositories.AlertRuleFilter{
+		Search: c.Query("search"),
+	}
+
+	if alertType := c.Query("type"); alertType != "" {
+		t := models.AlertType(alertType)
+		filter.Type = &t
+	}
+
+	if severity := c.Query("severity"); severity != "" {
+		sev := models.AlertSeverity(severity)
+		filter.Severity = &sev
+	}
+
+	if enabled, err := strconv.ParseBool(c.Query("enabled")); err == nil {
+		filter.Enabled = &enabled
+	}
+
+	if tier := c.Query("tier"); tier != "" {
+		t := models.APIKeyTier(tier)
+		filter.Tier = &t
+	}
+
+	if apiKeyID := c.Query("api_key_id"); apiKeyID != "" {
+		id, err := uuid.Parse(apiKeyID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid API key ID",
+				Message: err.Error(),
+			})
+			return
+		}
+		filter.APIKeyID = &id
+	}
+
+	if teamID := c.Query("team_id"); teamID != "" {
+		id, err := uuid.Parse(teamID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid team ID",
+				Message: err.Error(),
+			})
+			return
+		}
+		filter.TeamID = &id
+	}
+
+	result, err := ctrl.alertService.ListRules(c.Request.Context(), filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list alert rules",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,11 +1,13 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
 	"github.com/rdhawladar/viva-rate-limiter/internal/services"
 )
@@ -13,12 +15,14 @@ import (
 // APIKeyController handles API key related endpoints
 type APIKeyController struct {
 	apiKeyService services.APIKeyService
+	authz         *services.AuthorizationService
 }
 
 // NewAPIKeyController creates a new API key controller
-func NewAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController {
+func NewAPIKeyController(apiKeyService services.APIKeyService, authz *services.AuthorizationService) *APIKeyController {
 	return &APIKeyController{
 		apiKeyService: apiKeyService,
+		authz:         authz,
 	}
 }
 
@@ -31,6 +35,7 @@ func NewAPIKeyController(apiKeyService services.APIKeyService) *APIKeyController
 // @Param request body services.CreateAPIKeyRequest true "Create API key request"
 // @Success 201 {object} services.APIKeyResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys [post]
 func (ctrl *APIKeyController) CreateAPIKey(c *gin.Context) {
@@ -43,6 +48,25 @@ func (ctrl *APIKeyController) CreateAPIKey(c *gin.Context) {
 		return
 	}
 
+	for _, scope := range req.Scopes {
+		if !models.IsValidScope(scope) {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid scope",
+				Message: fmt.Sprintf("unknown scope %q", scope),
+			})
+			return
+		}
+	}
+
+	principal, ok := principalFrom(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.authz.AuthorizeCreateKey(c.Request.Context(), principal, &req); err != nil {
+		writeAuthorizationError(c, err)
+		return
+	}
+
 	apiKey, err := ctrl.apiKeyService.CreateAPIKey(c.Request.Context(), &req)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -64,6 +88,7 @@ func (ctrl *APIKeyController) CreateAPIKey(c *gin.Context) {
 // @Param id path string true "API Key ID"
 // @Success 200 {object} services.APIKeyResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Router /api-keys/{id} [get]
 func (ctrl *APIKeyController) GetAPIKey(c *gin.Context) {
@@ -77,6 +102,10 @@ func (ctrl *APIKeyController) GetAPIKey(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionRead); !ok {
+		return
+	}
+
 	apiKey, err := ctrl.apiKeyService.GetAPIKey(c.Request.Context(), id)
 	if err != nil {
 		c.JSON(http.StatusNotFound, ErrorResponse{
@@ -99,6 +128,7 @@ func (ctrl *APIKeyController) GetAPIKey(c *gin.Context) {
 // @Param request body services.UpdateAPIKeyRequest true "Update API key request"
 // @Success 200 {object} services.APIKeyResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys/{id} [put]
@@ -122,6 +152,18 @@ func (ctrl *APIKeyController) UpdateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if req.Tier != nil && !req.Tier.IsValid() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid tier",
+			Message: "tier must be free, pro or enterprise",
+		})
+		return
+	}
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionForUpdate(&req)); !ok {
+		return
+	}
+
 	apiKey, err := ctrl.apiKeyService.UpdateAPIKey(c.Request.Context(), id, &req)
 	if err != nil {
 		if err.Error() == "api key not found" {
@@ -150,6 +192,7 @@ func (ctrl *APIKeyController) UpdateAPIKey(c *gin.Context) {
 // @Param id path string true "API Key ID"
 // @Success 204
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys/{id} [delete]
@@ -164,6 +207,10 @@ func (ctrl *APIKeyController) DeleteAPIKey(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionWrite); !ok {
+		return
+	}
+
 	if err := ctrl.apiKeyService.DeleteAPIKey(c.Request.Context(), id); err != nil {
 		if err.Error() == "api key not found" {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -195,6 +242,7 @@ func (ctrl *APIKeyController) DeleteAPIKey(c *gin.Context) {
 // @Param user_id query string false "Filter by user ID"
 // @Success 200 {object} repositories.PaginatedResult
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys [get]
 func (ctrl *APIKeyController) ListAPIKeys(c *gin.Context) {
@@ -245,6 +293,16 @@ func (ctrl *APIKeyController) ListAPIKeys(c *gin.Context) {
 		filter.Search = search
 	}
 
+	// Callers only see the keys of their own organization
+	principal, ok := principalFrom(c)
+	if !ok {
+		return
+	}
+	if err := ctrl.authz.ScopeAPIKeyFilter(principal, filter); err != nil {
+		writeAuthorizationError(c, err)
+		return
+	}
+
 	result, err := ctrl.apiKeyService.ListAPIKeys(c.Request.Context(), filter, pagination)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -266,6 +324,7 @@ func (ctrl *APIKeyController) ListAPIKeys(c *gin.Context) {
 // @Param id path string true "API Key ID"
 // @Success 200 {object} services.APIKeyResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys/{id}/rotate [post]
@@ -280,6 +339,10 @@ func (ctrl *APIKeyController) RotateAPIKey(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionWrite); !ok {
+		return
+	}
+
 	apiKey, err := ctrl.apiKeyService.RotateAPIKey(c.Request.Context(), id)
 	if err != nil {
 		if err.Error() == "api key not found" {
@@ -308,6 +371,7 @@ func (ctrl *APIKeyController) RotateAPIKey(c *gin.Context) {
 // @Param id path string true "API Key ID"
 // @Success 200 {object} services.APIKeyStats
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /api-keys/{id}/stats [get]
@@ -322,6 +386,10 @@ func (ctrl *APIKeyController) GetAPIKeyStats(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, id, services.KeyActionRead); !ok {
+		return
+	}
+
 	stats, err := ctrl.apiKeyService.GetAPIKeyStats(c.Request.Context(), id)
 	if err != nil {
 		if err.Error() == "api key not found" {
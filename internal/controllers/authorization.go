@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// principalFrom returns the caller stored by the LoadPrincipal middleware,
+// responding 401 if there is none
+func principalFrom(c *gin.Context) (*services.Principal, bool) {
+	value, exists := c.Get("principal")
+	principal, ok := value.(*services.Principal)
+	if !exists || !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{
+			Error:   "Missing API key",
+			Message: "This endpoint requires an authenticated API key",
+		})
+		return nil, false
+	}
+	return principal, true
+}
+
+// authorizeAPIKey checks that the caller may perform action on an API key,
+// responding with the error if not
+func authorizeAPIKey(c *gin.Context, authz *services.AuthorizationService, apiKeyID uuid.UUID, action services.KeyAction) (*models.APIKey, bool) {
+	principal, ok := principalFrom(c)
+	if !ok {
+		return nil, false
+	}
+	apiKey, err := authz.AuthorizeKey(c.Request.Context(), principal, apiKeyID, action)
+	if err != nil {
+		writeAuthorizationError(c, err)
+		return nil, false
+	}
+	return apiKey, true
+}
+
+// requireRole checks that the caller has at least a role in its
+// organization, responding 403 if not
+func requireRole(c *gin.Context, authz *services.AuthorizationService, role models.UserRole) (*services.Principal, bool) {
+	principal, ok := principalFrom(c)
+	if !ok {
+		return nil, false
+	}
+	if err := authz.RequireRole(principal, role); err != nil {
+		writeAuthorizationError(c, err)
+		return nil, false
+	}
+	return principal, true
+}
+
+// requirePlatform checks that the caller operates the platform, responding
+// 403 if not
+func requirePlatform(c *gin.Context, authz *services.AuthorizationService) bool {
+	principal, ok := principalFrom(c)
+	if !ok {
+		return false
+	}
+	if err := authz.RequirePlatform(principal); err != nil {
+		writeAuthorizationError(c, err)
+		return false
+	}
+	return true
+}
+
+// writeAuthorizationError responds to a failed authorization: 403 for a
+// role that is not allowed and 404 for resources outside the caller's
+// organization
+func writeAuthorizationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Message: err.Error(),
+		})
+	case strings.HasSuffix(err.Error(), "not found"):
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "Not found",
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to authorize request",
+			Message: err.Error(),
+		})
+	}
+}
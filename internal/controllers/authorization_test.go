@@ -0,0 +1,184 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/middleware"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// userRepository finds users by ID
+type userRepository struct {
+	repositories.OrganizationRepository
+	users map[uuid.UUID]*models.User
+}
+
+func (r *userRepository) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+func TestWriteAuthorizationError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{fmt.Errorf("%w: requires the admin role or higher", services.ErrForbidden), http.StatusForbidden},
+		{fmt.Errorf("api key not found"), http.StatusNotFound},
+		{fmt.Errorf("team not found"), http.StatusNotFound},
+		{fmt.Errorf("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			writeAuthorizationError(c, tt.err)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestOrganizationController_RequiresOrganizationMember(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	authz := services.NewAuthorizationService(nil, nil)
+	ctrl := NewOrganizationController(services.NewOrganizationService(nil), authz)
+
+	tests := []struct {
+		name      string
+		principal *services.Principal
+		expected  int
+	}{
+		{"no principal", nil, http.StatusUnauthorized},
+		{"key without user", &services.Principal{APIKey: &models.APIKey{}}, http.StatusForbidden},
+		{"platform key without user", &services.Principal{APIKey: &models.APIKey{}, Platform: true}, http.StatusForbidden},
+		{"viewer", &services.Principal{APIKey: &models.APIKey{}, User: &models.User{Role: models.UserRoleViewer}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.principal != nil {
+					c.Set("principal", tt.principal)
+				}
+			})
+			router.DELETE("/org/teams/:id", ctrl.DeleteTeam)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/org/teams/8d3f3b52-7a55-4d38-9f3c-3c1c9b3b8a10", nil))
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+func TestAccessControlController_RequiresPlatform(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewAccessControlController(nil, services.NewAuthorizationService(nil, nil))
+
+	tests := []struct {
+		name      string
+		principal *services.Principal
+		expected  int
+	}{
+		{"no principal", nil, http.StatusUnauthorized},
+		{"key without user", &services.Principal{APIKey: &models.APIKey{}}, http.StatusForbidden},
+		{"organization owner", &services.Principal{APIKey: &models.APIKey{}, User: &models.User{Role: models.UserRoleOwner}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.principal != nil {
+					c.Set("principal", tt.principal)
+				}
+			})
+			router.GET("/admin/access-rules", ctrl.ListAccessRules)
+			router.POST("/admin/access-rules", ctrl.CreateAccessRule)
+			router.DELETE("/admin/access-rules/:id", ctrl.DeleteAccessRule)
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/admin/access-rules", nil),
+				httptest.NewRequest(http.MethodPost, "/admin/access-rules", nil),
+				httptest.NewRequest(http.MethodDelete, "/admin/access-rules/8d3f3b52-7a55-4d38-9f3c-3c1c9b3b8a10", nil),
+			} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				assert.Equal(t, tt.expected, w.Code, req.Method)
+			}
+		})
+	}
+}
+
+func TestMigratedCustomerKeyStaysInItsOrganization(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	orgID, otherOrg := uuid.New(), uuid.New()
+	owner := &models.User{ID: uuid.New(), OrganizationID: orgID, Role: models.UserRoleOwner}
+	foreignKey := &models.APIKey{ID: uuid.New(), OrganizationID: &otherOrg}
+
+	// Scopes of a key created before scopes existed after migrations 011 and
+	// 017, and of one that still holds the admin scope 011 used to grant
+	migrated := []string{
+		models.ScopeAlertsRead, models.ScopeAlertsWrite, models.ScopeRateLimitCheck,
+		models.ScopeKeysRead, models.ScopeKeysWrite, models.ScopeOrgRead, models.ScopeOrgWrite,
+	}
+	customerKeys := map[string]*models.APIKey{
+		"migrated scopes": {ID: uuid.New(), OrganizationID: &orgID, UserID: &owner.ID, Scopes: migrated},
+		"leftover admin":  {ID: uuid.New(), OrganizationID: &orgID, UserID: &owner.ID, Scopes: []string{models.ScopeAdmin}},
+	}
+
+	apiKeys := &alertKeyRepository{apiKeys: map[uuid.UUID]*models.APIKey{foreignKey.ID: foreignKey}}
+	for _, apiKey := range customerKeys {
+		apiKeys.apiKeys[apiKey.ID] = apiKey
+	}
+	authz := services.NewAuthorizationService(&userRepository{users: map[uuid.UUID]*models.User{owner.ID: owner}}, apiKeys)
+	alertController := NewAlertController(&fakeAlertService{}, authz)
+	accessControlController := NewAccessControlController(nil, authz)
+
+	for name, apiKey := range customerKeys {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				c.Set("api_key", apiKey)
+				c.Set("api_key_id", apiKey.ID)
+			})
+			router.Use(middleware.LoadPrincipal(authz))
+			requireAdmin := middleware.RequireScope(models.ScopeAdmin)
+			router.GET("/admin/access-rules", requireAdmin, accessControlController.ListAccessRules)
+			router.GET("/admin/billing/summary", requireAdmin, func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+			router.GET("/admin/alerts/keys/:api_key_id", alertController.GetAPIKeyAlerts)
+
+			for path, expected := range map[string]int{
+				"/admin/access-rules":                          http.StatusForbidden,
+				"/admin/billing/summary":                       http.StatusForbidden,
+				"/admin/alerts/keys/" + foreignKey.ID.String(): http.StatusNotFound,
+				"/admin/alerts/keys/" + apiKey.ID.String():     http.StatusOK,
+			} {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+				assert.Equal(t, expected, w.Code, path)
+			}
+		})
+	}
+}
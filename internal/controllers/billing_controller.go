@@ -0,0 +1,455 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/invoice"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// billingSortFields are the columns billing records can be ordered by
+var billingSortFields = map[string]bool{
+	"period_start": true,
+	"total_amount": true,
+	"created_at":   true,
+}
+
+// Limits of the revenue summary's top API keys
+const (
+	defaultBillingTopKeys = 10
+	maxBillingTopKeys     = 100
+)
+
+// BillingController handles billing endpoints
+type BillingController struct {
+	billingService *services.BillingService
+	renderer       *invoice.Renderer
+	terms          invoice.Terms
+}
+
+// NewBillingController creates a new billing controller. Invoices are
+// rendered with renderer and carry terms.
+func NewBillingController(billingService *services.BillingService, renderer *invoice.Renderer, terms invoice.Terms) *BillingController {
+	return &BillingController{
+		billingService: billingService,
+		renderer:       renderer,
+		terms:          terms,
+	}
+}
+
+// GetBillingHistory lists the billing records of an API key
+// @Summary Get API key billing history
+// @Description List the billing records of an API key, newest period first by default, with the total the key owes across all unpaid records
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Param order_by query string false "Sort field: period_start, total_amount or created_at" default(period_start)
+// @Param order query string false "Sort order: asc or desc" default(desc)
+// @Param payment_status query string false "Filter by payment status"
+// @Param start_date query string false "Only periods starting at or after this time (RFC3339)"
+// @Param end_date query string false "Only periods ending at or before this time (RFC3339)"
+// @Success 200 {object} services.BillingHistory
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/keys/{api_key_id}/records [get]
+func (ctrl *BillingController) GetBillingHistory(c *gin.Context) {
+	apiKeyID, ok := parseBillingAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	pagination, ok := parseBillingPagination(c)
+	if !ok {
+		return
+	}
+
+	filter := &repositories.BillingFilter{}
+	if status := c.Query("payment_status"); status != "" {
+		paymentStatus := models.PaymentStatus(status)
+		if !paymentStatus.IsValid() {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid payment status",
+				Message: fmt.Sprintf("unknown payment status: %s", status),
+			})
+			return
+		}
+		filter.PaymentStatus = &paymentStatus
+	}
+	if !parseBillingDates(c, &filter.StartDate, &filter.EndDate) {
+		return
+	}
+
+	history, err := ctrl.billingService.GetBillingHistory(c.Request.Context(), apiKeyID, filter, pagination)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get billing history",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// GetCurrentBilling estimates the bill of an API key for the current period
+// @Summary Get current billing period estimate
+// @Description Price the usage of an API key so far in the current monthly billing period with its tier's plan, prorated across tier changes. The estimate is not saved.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Success 200 {object} models.BillingRecord
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/keys/{api_key_id}/current [get]
+func (ctrl *BillingController) GetCurrentBilling(c *gin.Context) {
+	apiKeyID, ok := parseBillingAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	record, err := ctrl.billingService.GetCurrentBillingPeriod(c.Request.Context(), apiKeyID)
+	if err != nil {
+		writeBillingError(c, "Failed to estimate current billing period", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// GetRevenueSummary summarizes revenue
+// @Summary Get revenue summary
+// @Description Total the billing records of periods within a range by payment status, and list the API keys bringing in the most revenue. Without dates every record is summarized.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param start_date query string false "Only periods starting at or after this time (RFC3339)"
+// @Param end_date query string false "Only periods ending at or before this time (RFC3339)"
+// @Param top query int false "Number of top API keys, at most 100" default(10)
+// @Success 200 {object} services.BillingSummary
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/summary [get]
+func (ctrl *BillingController) GetRevenueSummary(c *gin.Context) {
+	var start, end *time.Time
+	if !parseBillingDates(c, &start, &end) {
+		return
+	}
+
+	top := defaultBillingTopKeys
+	if topStr := c.Query("top"); topStr != "" {
+		n, err := strconv.Atoi(topStr)
+		if err != nil || n <= 0 || n > maxBillingTopKeys {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid top",
+				Message: fmt.Sprintf("top must be between 1 and %d", maxBillingTopKeys),
+			})
+			return
+		}
+		top = n
+	}
+
+	var periodStart, periodEnd time.Time
+	if start != nil {
+		periodStart = *start
+	}
+	if end != nil {
+		periodEnd = *end
+	}
+
+	summary, err := ctrl.billingService.GetBillingSummary(c.Request.Context(), periodStart, periodEnd, top)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get revenue summary",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetOverdueBilling lists overdue billing records
+// @Summary List overdue billing records
+// @Description List unpaid billing records whose period ended more than a number of days ago, oldest first. Pending records among them are marked overdue.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param days query int false "Days past the end of the period; defaults to the payment terms"
+// @Success 200 {object} services.OverdueBilling
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/overdue [get]
+func (ctrl *BillingController) GetOverdueBilling(c *gin.Context) {
+	days := ctrl.terms.PaymentTermsDays
+	if daysStr := c.Query("days"); daysStr != "" {
+		d, err := strconv.Atoi(daysStr)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid days",
+				Message: "days must be a non-negative number",
+			})
+			return
+		}
+		days = d
+	}
+
+	overdue, err := ctrl.billingService.GetOverdueBilling(c.Request.Context(), days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to list overdue billing records",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, overdue)
+}
+
+// GetBillingRecord retrieves a billing record by ID
+// @Summary Get billing record
+// @Description Get a billing record by ID, including its line items
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param id path string true "Billing record ID"
+// @Success 200 {object} models.BillingRecord
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/records/{id} [get]
+func (ctrl *BillingController) GetBillingRecord(c *gin.Context) {
+	id, ok := parseBillingRecordID(c)
+	if !ok {
+		return
+	}
+
+	record, err := ctrl.billingService.GetBillingRecord(c.Request.Context(), id)
+	if err != nil {
+		writeBillingError(c, "Failed to get billing record", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// GetInvoice renders the invoice of a billing record
+// @Summary Get invoice
+// @Description Render the invoice of a billing record as PDF or HTML
+// @Tags billing
+// @Produce application/pdf
+// @Produce text/html
+// @Param id path string true "Billing record ID"
+// @Param format query string false "Invoice format: pdf or html" default(pdf)
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/records/{id}/invoice [get]
+func (ctrl *BillingController) GetInvoice(c *gin.Context) {
+	id, ok := parseBillingRecordID(c)
+	if !ok {
+		return
+	}
+
+	format, ok := parseInvoiceFormat(c)
+	if !ok {
+		return
+	}
+
+	record, err := ctrl.billingService.GetBillingRecord(c.Request.Context(), id)
+	if err != nil {
+		writeBillingError(c, "Failed to get billing record", err)
+		return
+	}
+
+	inv := invoice.New(record, ctrl.terms)
+	var buf bytes.Buffer
+	if err := ctrl.renderer.Render(&buf, inv, format); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to render invoice",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", inv.Number+"."+string(format)))
+	c.Data(http.StatusOK, format.ContentType(), buf.Bytes())
+}
+
+// ExportInvoices downloads every invoice of a billing month
+// @Summary Export invoices
+// @Description Download the invoices of every billing record of a month as a ZIP archive of PDF or HTML files
+// @Tags billing
+// @Produce application/zip
+// @Param month query string true "Billing month (YYYY-MM)"
+// @Param format query string false "Invoice format: pdf or html" default(pdf)
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/invoices [get]
+func (ctrl *BillingController) ExportInvoices(c *gin.Context) {
+	periodStart, err := time.Parse("2006-01", c.Query("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid month",
+			Message: "month must be given as YYYY-MM",
+		})
+		return
+	}
+	periodEnd := periodStart.AddDate(0, 1, 0)
+
+	format, ok := parseInvoiceFormat(c)
+	if !ok {
+		return
+	}
+
+	records, err := ctrl.billingService.GetPeriodRecords(c.Request.Context(), periodStart, periodEnd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get billing records",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invoices := make([]*invoice.Invoice, len(records))
+	for i, record := range records {
+		invoices[i] = invoice.New(record, ctrl.terms)
+	}
+
+	// The archive is built before responding so a failure is still reported
+	var buf bytes.Buffer
+	if err := ctrl.renderer.WriteArchive(&buf, invoices, format); err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to export invoices",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "invoices-"+periodStart.Format("2006-01")+".zip"))
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+// parseBillingAPIKeyID parses the API key ID path parameter, writing a 400
+// response when it is invalid
+func parseBillingAPIKeyID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("api_key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid API key ID",
+			Message: err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseBillingRecordID parses the billing record ID path parameter, writing
+// a 400 response when it is invalid
+func parseBillingRecordID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid billing record ID",
+			Message: err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// parseBillingPagination parses pagination and ordering, writing a 400
+// response when the sort field is unknown
+func parseBillingPagination(c *gin.Context) (*repositories.PaginationParams, bool) {
+	pagination := parseUsagePagination(c)
+	pagination.OrderBy = c.DefaultQuery("order_by", "period_start")
+	pagination.Order = c.DefaultQuery("order", "desc")
+
+	// OrderBy is written into the query, so only known columns are allowed
+	if !billingSortFields[pagination.OrderBy] {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid order_by",
+			Message: "order_by must be one of period_start, total_amount or created_at",
+		})
+		return nil, false
+	}
+	return pagination, true
+}
+
+// parseBillingDates parses the optional start_date and end_date query
+// parameters, writing a 400 response when one is invalid
+func parseBillingDates(c *gin.Context, start, end **time.Time) bool {
+	for _, param := range []struct {
+		name string
+		dst  **time.Time
+	}{
+		{"start_date", start},
+		{"end_date", end},
+	} {
+		value := c.Query(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid " + param.name,
+				Message: err.Error(),
+			})
+			return false
+		}
+		*param.dst = &t
+	}
+	return true
+}
+
+// parseInvoiceFormat parses the invoice format query parameter, writing a 400
+// response when it is unsupported
+func parseInvoiceFormat(c *gin.Context) (invoice.Format, bool) {
+	format := invoice.Format(c.DefaultQuery("format", string(invoice.FormatPDF)))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid format",
+			Message: "format must be pdf or html",
+		})
+		return "", false
+	}
+	return format, true
+}
+
+// writeBillingError writes the response for an error from a billing operation
+func writeBillingError(c *gin.Context, message string, err error) {
+	switch err.Error() {
+	case "billing record not found", "api key not found":
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/invoice"
+)
+
+func TestParseBillingPagination(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/billing/keys/x/records?page=2", nil)
+
+	pagination, ok := parseBillingPagination(c)
+	require.True(t, ok)
+	assert.Equal(t, 2, pagination.Page)
+	assert.Equal(t, "period_start desc", pagination.GetOrderBy())
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/admin/billing/keys/x/records?order_by=metadata", nil)
+
+	_, ok = parseBillingPagination(c)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// The billing endpoints validate their input before calling the service
+func TestBillingController_RejectsInvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewBillingController(nil, nil, invoice.Terms{PaymentTermsDays: 30})
+	router := gin.New()
+	router.GET("/billing/summary", ctrl.GetRevenueSummary)
+	router.GET("/billing/overdue", ctrl.GetOverdueBilling)
+	router.GET("/billing/invoices", ctrl.ExportInvoices)
+	router.GET("/billing/keys/:api_key_id/records", ctrl.GetBillingHistory)
+	router.GET("/billing/keys/:api_key_id/current", ctrl.GetCurrentBilling)
+	router.GET("/billing/records/:id/invoice", ctrl.GetInvoice)
+
+	id := uuid.New().String()
+	for _, path := range []string{
+		"/billing/summary?top=0",
+		"/billing/summary?start_date=2025-04-01",
+		"/billing/overdue?days=-1",
+		"/billing/invoices",
+		"/billing/invoices?month=2025-13",
+		"/billing/invoices?month=2025-04&format=docx",
+		"/billing/keys/not-a-uuid/records",
+		"/billing/keys/" + id + "/records?payment_status=unpaid",
+		"/billing/keys/" + id + "/records?end_date=yesterday",
+		"/billing/keys/not-a-uuid/current",
+		"/billing/records/not-a-uuid/invoice",
+		"/billing/records/" + id + "/invoice?format=docx",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+	}
+}
@@ -0,0 +1,259 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// CreditController handles prepaid credit account endpoints
+type CreditController struct {
+	creditService *services.CreditService
+}
+
+// NewCreditController creates a new credit controller
+func NewCreditController(creditService *services.CreditService) *CreditController {
+	return &CreditController{
+		creditService: creditService,
+	}
+}
+
+// TopUpCreditsResponse reports a top-up and the resulting account
+type TopUpCreditsResponse struct {
+	Applied bool                  `json:"applied"`
+	Account *models.CreditAccount `json:"account"`
+}
+
+// CreateCreditAccount opens a credit account
+// @Summary Create credit account
+// @Description Open a prepaid credit account with a zero balance for an API key or a team. Once it exists, each request of the key, or of the team's keys, is charged to it and rejected when it cannot be paid for.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param request body services.CreateCreditAccountRequest true "Credit account"
+// @Success 201 {object} models.CreditAccount
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/credits/accounts [post]
+func (ctrl *CreditController) CreateCreditAccount(c *gin.Context) {
+	var req services.CreateCreditAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	account, err := ctrl.creditService.CreateAccount(c.Request.Context(), &req)
+	if err != nil {
+		writeCreditError(c, "Failed to create credit account", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, account)
+}
+
+// GetCreditAccount retrieves a credit account
+// @Summary Get credit account
+// @Description Get the balance, monthly spend and settings of a prepaid credit account
+// @Tags credits
+// @Produce json
+// @Param id path string true "Credit account ID"
+// @Success 200 {object} models.CreditAccount
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/credits/accounts/{id} [get]
+func (ctrl *CreditController) GetCreditAccount(c *gin.Context) {
+	id, ok := parseCreditAccountID(c)
+	if !ok {
+		return
+	}
+
+	account, err := ctrl.creditService.GetAccount(c.Request.Context(), id)
+	if err != nil {
+		writeCreditError(c, "Failed to get credit account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// UpdateCreditAccount replaces the settings of a credit account
+// @Summary Update credit account
+// @Description Replace the monthly spend cap and alert thresholds of a prepaid credit account. Omitting the cap removes it; omitting the thresholds uses the configured ones.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param id path string true "Credit account ID"
+// @Param request body services.UpdateCreditAccountRequest true "Credit account settings"
+// @Success 200 {object} models.CreditAccount
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/credits/accounts/{id} [put]
+func (ctrl *CreditController) UpdateCreditAccount(c *gin.Context) {
+	id, ok := parseCreditAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req services.UpdateCreditAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	account, err := ctrl.creditService.UpdateAccount(c.Request.Context(), id, &req)
+	if err != nil {
+		writeCreditError(c, "Failed to update credit account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// TopUpCredits adds purchased credits to an account
+// @Summary Top up credits
+// @Description Add purchased credits to a prepaid credit account, optionally expiring at a given time. A top-up whose reference was already used is not applied again.
+// @Tags credits
+// @Accept json
+// @Produce json
+// @Param id path string true "Credit account ID"
+// @Param request body services.TopUpCreditsRequest true "Top-up"
+// @Success 200 {object} TopUpCreditsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/credits/accounts/{id}/topups [post]
+func (ctrl *CreditController) TopUpCredits(c *gin.Context) {
+	id, ok := parseCreditAccountID(c)
+	if !ok {
+		return
+	}
+
+	var req services.TopUpCreditsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	account, applied, err := ctrl.creditService.TopUp(c.Request.Context(), id, &req)
+	if err != nil {
+		writeCreditError(c, "Failed to top up credits", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, TopUpCreditsResponse{
+		Applied: applied,
+		Account: account,
+	})
+}
+
+// GetCreditLedger lists the ledger entries of a credit account
+// @Summary Get credit ledger
+// @Description List the top-ups, hourly debits and expiries of a prepaid credit account, newest first
+// @Tags credits
+// @Produce json
+// @Param id path string true "Credit account ID"
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/credits/accounts/{id}/ledger [get]
+func (ctrl *CreditController) GetCreditLedger(c *gin.Context) {
+	id, ok := parseCreditAccountID(c)
+	if !ok {
+		return
+	}
+
+	result, err := ctrl.creditService.GetLedger(c.Request.Context(), id, parseUsagePagination(c))
+	if err != nil {
+		writeCreditError(c, "Failed to get credit ledger", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetAPIKeyCreditAccount retrieves the credit account an API key is charged to
+// @Summary Get API key credit account
+// @Description Get the prepaid credit account an API key's requests are charged to: its own, or else its team's
+// @Tags credits
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Success 200 {object} models.CreditAccount
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/credits/keys/{api_key_id} [get]
+func (ctrl *CreditController) GetAPIKeyCreditAccount(c *gin.Context) {
+	apiKeyID, ok := parseBillingAPIKeyID(c)
+	if !ok {
+		return
+	}
+
+	account, err := ctrl.creditService.GetAccountForKey(c.Request.Context(), apiKeyID)
+	if err != nil {
+		writeCreditError(c, "Failed to get credit account", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, account)
+}
+
+// parseCreditAccountID parses the credit account ID path parameter, writing
+// a 400 response when it is invalid
+func parseCreditAccountID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid credit account ID",
+			Message: err.Error(),
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// writeCreditError writes the response for an error from a credit operation
+func writeCreditError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrCreditsDisabled):
+		status = http.StatusServiceUnavailable
+	default:
+		switch err.Error() {
+		case "credit account not found", "api key not found":
+			status = http.StatusNotFound
+		case "credit account already exists":
+			status = http.StatusConflict
+		case "exactly one of api_key_id and team_id is required", "monthly_spend_cap must not be negative",
+			"alert thresholds must not be negative", "amount must be positive", "expires_at must be in the future":
+			status = http.StatusBadRequest
+		}
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:   message,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,40 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+func TestCreditController_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewCreditController(services.NewCreditService(nil, nil, nil, services.CreditPolicy{}, nil))
+	router := gin.New()
+	router.POST("/credits/accounts", ctrl.CreateCreditAccount)
+	router.GET("/credits/accounts/:id", ctrl.GetCreditAccount)
+	router.POST("/credits/accounts/:id/topups", ctrl.TopUpCredits)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/credits/accounts", strings.NewReader(`{"api_key_id":"`+uuid.NewString()+`"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/credits/accounts/"+uuid.NewString()+"/topups", strings.NewReader(`{"amount":10}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/credits/accounts/"+uuid.NewString()+"/topups", strings.NewReader(`{"amount":-5}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/credits/accounts/not-a-uuid", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,200 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// ExportController handles usage export endpoints
+type ExportController struct {
+	usageExportService services.UsageExportService
+	authz              *services.AuthorizationService
+}
+
+// NewExportController creates a new export controller
+func NewExportController(usageExportService services.UsageExportService, authz *services.AuthorizationService) *ExportController {
+	return &ExportController{
+		usageExportService: usageExportService,
+		authz:              authz,
+	}
+}
+
+// CreateExportRequest represents the request body for exporting usage logs
+type CreateExportRequest struct {
+	APIKeyID  string                 `json:"api_key_id"`
+	StartTime time.Time              `json:"start_time" binding:"required"`
+	EndTime   time.Time              `json:"end_time" binding:"required"`
+	Format    models.ExportFormat    `json:"format"`
+	Filters   map[string]interface{} `json:"filters"`
+}
+
+// CreateExport starts an export of usage logs
+// @Summary Create usage export
+// @Description Export the usage logs of an API key in a time range as CSV, NDJSON or Parquet. Small exports complete before the response; large ones are written by the worker and return 202 while pending. The API key defaults to the caller's.
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param request body CreateExportRequest true "Create export request"
+// @Success 201 {object} services.ExportResult
+// @Success 202 {object} services.ExportResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /exports [post]
+func (ctrl *ExportController) CreateExport(c *gin.Context) {
+	var req CreateExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	serviceReq := &services.ExportRequest{
+		StartTime: req.StartTime,
+		EndTime:   req.EndTime,
+		Format:    req.Format,
+		Filters:   req.Filters,
+	}
+	if req.APIKeyID != "" {
+		apiKeyID, err := uuid.Parse(req.APIKeyID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid API key ID",
+				Message: err.Error(),
+			})
+			return
+		}
+		serviceReq.APIKeyID = apiKeyID
+	} else if apiKeyID, exists := c.Get("api_key_id"); exists {
+		serviceReq.APIKeyID, _ = apiKeyID.(uuid.UUID)
+	}
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, serviceReq.APIKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
+	result, err := ctrl.usageExportService.CreateExport(c.Request.Context(), serviceReq)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to create export",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status := http.StatusCreated
+	if !result.IsFinished() {
+		status = http.StatusAccepted
+	}
+	c.JSON(status, result)
+}
+
+// GetExport retrieves the status of a usage export
+// @Summary Get usage export
+// @Description Get the status of a usage export and, once completed, its record count, size and download URL
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param id path string true "Export ID"
+// @Success 200 {object} services.ExportResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /exports/{id} [get]
+func (ctrl *ExportController) GetExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid export ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	result, err := ctrl.usageExportService.GetExport(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == "usage export not found" {
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Export not found",
+				Message: err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get export",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, result.APIKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// DownloadExport streams the file of a completed usage export
+// @Summary Download usage export
+// @Description Download the file of a completed usage export
+// @Tags exports
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Produce application/vnd.apache.parquet
+// @Param id path string true "Export ID"
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /exports/{id}/download [get]
+func (ctrl *ExportController) DownloadExport(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid export ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	export, file, err := ctrl.usageExportService.OpenExport(c.Request.Context(), id)
+	if err != nil {
+		switch err.Error() {
+		case "usage export not found", "blob not found":
+			c.JSON(http.StatusNotFound, ErrorResponse{
+				Error:   "Export not found",
+				Message: err.Error(),
+			})
+		case "usage export not ready":
+			c.JSON(http.StatusConflict, ErrorResponse{
+				Error:   "Export not ready",
+				Message: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to download export",
+				Message: err.Error(),
+			})
+		}
+		return
+	}
+	defer file.Close()
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, export.APIKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
+	c.DataFromReader(http.StatusOK, export.FileSize, export.Format.ContentType(), file, map[string]string{
+		"Content-Disposition": fmt.Sprintf("attachment; filename=%q", export.FileName),
+	})
+}
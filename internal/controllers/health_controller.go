@@ -7,17 +7,20 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/rdhawladar/viva-rate-limiter/internal/cache"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
 )
 
 // HealthController handles health check endpoints
 type HealthController struct {
-	redisClient *cache.RedisClient
+	redisClient      *cache.RedisClient
+	partitionManager services.PartitionManager
 }
 
 // NewHealthController creates a new health controller
-func NewHealthController(redisClient *cache.RedisClient) *HealthController {
+func NewHealthController(redisClient *cache.RedisClient, partitionManager services.PartitionManager) *HealthController {
 	return &HealthController{
-		redisClient: redisClient,
+		redisClient:      redisClient,
+		partitionManager: partitionManager,
 	}
 }
 
@@ -121,4 +124,32 @@ func (h *HealthController) Live(c *gin.Context) {
 		"status":    "alive",
 		"timestamp": time.Now(),
 	})
-}
\ No newline at end of file
+}
+
+// Partitions reports the partition state of the partitioned tables
+// @Summary Partition health
+// @Description Report monthly partition coverage and retention of usage_logs and rate_limit_violations
+// @Tags health
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.PartitionStatus
+// @Failure 503 {object} services.PartitionStatus
+// @Failure 500 {object} ErrorResponse
+// @Router /health/partitions [get]
+func (h *HealthController) Partitions(c *gin.Context) {
+	status, err := h.partitionManager.Status(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get partition status",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	statusCode := http.StatusOK
+	if status.Status == services.PartitionStatusUnhealthy {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	c.JSON(statusCode, status)
+}
@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// MeteringController handles the metering event stream endpoints
+type MeteringController struct {
+	meteringService *services.MeteringService
+}
+
+// NewMeteringController creates a new metering controller
+func NewMeteringController(meteringService *services.MeteringService) *MeteringController {
+	return &MeteringController{
+		meteringService: meteringService,
+	}
+}
+
+// ReplayMeteringRequest moves the checkpoint of a metering sink back
+type ReplayMeteringRequest struct {
+	From time.Time `json:"from" binding:"required"`
+}
+
+// GetMeteringStatus reports how far metering records have been generated and
+// delivered
+// @Summary Get metering status
+// @Description Report how far metering records have been generated from the hourly usage rollups, and delivered to each sink
+// @Tags metering
+// @Produce json
+// @Success 200 {object} services.MeteringStatus
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/metering [get]
+func (ctrl *MeteringController) GetMeteringStatus(c *gin.Context) {
+	status, err := ctrl.meteringService.Status(c.Request.Context())
+	if err != nil {
+		writeMeteringError(c, "Failed to get metering status", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// RunMetering generates and delivers metering records now rather than
+// waiting for the worker
+// @Summary Run metering
+// @Description Generate metering records for the hours completed since the last run and deliver undelivered records to every sink. Sink failures are reported per sink.
+// @Tags metering
+// @Produce json
+// @Success 200 {object} services.MeteringStatus
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/metering/run [post]
+func (ctrl *MeteringController) RunMetering(c *gin.Context) {
+	result, err := ctrl.meteringService.Run(c.Request.Context())
+	if err != nil {
+		writeMeteringError(c, "Failed to run metering", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ReplayMetering moves a sink's checkpoint back so its records are delivered
+// again
+// @Summary Replay metering sink
+// @Description Move the checkpoint of a sink back to the hour containing from. The next run delivers every record from that hour on again; receivers can drop records they already have by ID.
+// @Tags metering
+// @Accept json
+// @Produce json
+// @Param name path string true "Sink name"
+// @Param request body ReplayMeteringRequest true "Hour to replay from"
+// @Success 200 {object} services.MeteringSinkStatus
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/metering/sinks/{name}/replay [post]
+func (ctrl *MeteringController) ReplayMetering(c *gin.Context) {
+	var req ReplayMeteringRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	status, err := ctrl.meteringService.Replay(c.Request.Context(), c.Param("name"), req.From)
+	if err != nil {
+		writeMeteringError(c, "Failed to replay metering sink", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// GetMeteringReconciliation compares emitted metering totals with billing
+// records
+// @Summary Reconcile metering
+// @Description Compare the requests, bandwidth and rate limited requests emitted for each API key during a billing month with the totals of its billing records. Mismatched keys are listed first.
+// @Tags metering
+// @Produce json
+// @Param month query string true "Billing month (YYYY-MM)"
+// @Success 200 {object} services.MeteringReconciliation
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/metering/reconciliation [get]
+func (ctrl *MeteringController) GetMeteringReconciliation(c *gin.Context) {
+	periodStart, err := time.Parse("2006-01", c.Query("month"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid month",
+			Message: "month must be given as YYYY-MM",
+		})
+		return
+	}
+
+	report, err := ctrl.meteringService.Reconcile(c.Request.Context(), periodStart, periodStart.AddDate(0, 1, 0))
+	if err != nil {
+		writeMeteringError(c, "Failed to reconcile metering", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// writeMeteringError writes the response for an error from a metering
+// operation
+func writeMeteringError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrMeteringDisabled):
+		status = http.StatusServiceUnavailable
+	case err.Error() == "metering sink not found":
+		status = http.StatusNotFound
+	case err.Error() == "replay must start at or before the sink checkpoint":
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:   message,
+		Message: err.Error(),
+	})
+}
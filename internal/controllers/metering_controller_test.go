@@ -0,0 +1,39 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+func TestMeteringController_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewMeteringController(services.NewMeteringService(nil, nil, nil, nil, services.MeteringPolicy{}))
+	router := gin.New()
+	router.POST("/metering/run", ctrl.RunMetering)
+	router.POST("/metering/sinks/:name/replay", ctrl.ReplayMetering)
+	router.GET("/metering/reconciliation", ctrl.GetMeteringReconciliation)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/metering/run", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/metering/sinks/files/replay", strings.NewReader(`{"from":"2026-03-01T00:00:00Z"}`)))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/metering/sinks/files/replay", strings.NewReader(`{}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/metering/reconciliation?month=March", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,535 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// OrganizationController handles organization, user and team endpoints
+type OrganizationController struct {
+	orgService *services.OrganizationService
+	authz      *services.AuthorizationService
+}
+
+// NewOrganizationController creates a new organization controller
+func NewOrganizationController(orgService *services.OrganizationService, authz *services.AuthorizationService) *OrganizationController {
+	return &OrganizationController{
+		orgService: orgService,
+		authz:      authz,
+	}
+}
+
+// AddTeamMemberRequest represents a request adding a user to a team
+type AddTeamMemberRequest struct {
+	UserID uuid.UUID `json:"user_id" binding:"required"`
+}
+
+// CreateOrganization creates an organization with its first owner
+// @Summary Create organization
+// @Description Create an organization together with the user who owns it. API keys created for the owner act within the organization.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body services.CreateOrganizationRequest true "Organization"
+// @Success 201 {object} services.OrganizationResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /admin/orgs [post]
+func (ctrl *OrganizationController) CreateOrganization(c *gin.Context) {
+	var req services.CreateOrganizationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	org, err := ctrl.orgService.CreateOrganization(c.Request.Context(), &req)
+	if err != nil {
+		writeOrganizationError(c, "Failed to create organization", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, org)
+}
+
+// ListOrganizations lists every organization
+// @Summary List organizations
+// @Description List every organization, oldest first
+// @Tags organizations
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/orgs [get]
+func (ctrl *OrganizationController) ListOrganizations(c *gin.Context) {
+	result, err := ctrl.orgService.ListOrganizations(c.Request.Context(), parseUsagePagination(c))
+	if err != nil {
+		writeOrganizationError(c, "Failed to list organizations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetCurrentOrganization retrieves the caller's organization
+// @Summary Get current organization
+// @Description Get the organization of the user the caller's API key belongs to
+// @Tags organizations
+// @Produce json
+// @Success 200 {object} models.Organization
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org [get]
+func (ctrl *OrganizationController) GetCurrentOrganization(c *gin.Context) {
+	principal, ok := ctrl.orgMember(c, models.UserRoleViewer)
+	if !ok {
+		return
+	}
+
+	org, err := ctrl.orgService.GetOrganization(c.Request.Context(), principal.User.OrganizationID)
+	if err != nil {
+		writeOrganizationError(c, "Failed to get organization", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, org)
+}
+
+// ListUsers lists the users of the caller's organization
+// @Summary List users
+// @Description List the users of the caller's organization by email
+// @Tags organizations
+// @Produce json
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /org/users [get]
+func (ctrl *OrganizationController) ListUsers(c *gin.Context) {
+	principal, ok := ctrl.orgMember(c, models.UserRoleViewer)
+	if !ok {
+		return
+	}
+
+	result, err := ctrl.orgService.ListUsers(c.Request.Context(), principal.User.OrganizationID, parseUsagePagination(c))
+	if err != nil {
+		writeOrganizationError(c, "Failed to list users", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateUser adds a user to the caller's organization
+// @Summary Create user
+// @Description Add a user to the caller's organization. The role defaults to viewer; only owners may add owners. Requires the admin role.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body services.CreateUserRequest true "User"
+// @Success 201 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /org/users [post]
+func (ctrl *OrganizationController) CreateUser(c *gin.Context) {
+	var req services.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	principal, ok := ctrl.orgMember(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.orgService.CreateUser(c.Request.Context(), principal.User.OrganizationID, actorRole(principal), &req)
+	if err != nil {
+		writeOrganizationError(c, "Failed to create user", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// GetUser retrieves a user of the caller's organization
+// @Summary Get user
+// @Description Get a user of the caller's organization
+// @Tags organizations
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org/users/{id} [get]
+func (ctrl *OrganizationController) GetUser(c *gin.Context) {
+	user, _, ok := ctrl.orgUser(c, models.UserRoleViewer)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// UpdateUser renames a user or changes their role
+// @Summary Update user
+// @Description Rename a user of the caller's organization or change their role. Only owners may grant or revoke the owner role, and the last owner cannot be demoted. Requires the admin role.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body services.UpdateUserRequest true "User changes"
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /org/users/{id} [put]
+func (ctrl *OrganizationController) UpdateUser(c *gin.Context) {
+	var req services.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	user, principal, ok := ctrl.orgUser(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	user, err := ctrl.orgService.UpdateUser(c.Request.Context(), actorRole(principal), user, &req)
+	if err != nil {
+		writeOrganizationError(c, "Failed to update user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser removes a user from the caller's organization
+// @Summary Delete user
+// @Description Remove a user from the caller's organization and its teams. Only owners may remove owners, and the last owner cannot be removed. Requires the admin role.
+// @Tags organizations
+// @Param id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /org/users/{id} [delete]
+func (ctrl *OrganizationController) DeleteUser(c *gin.Context) {
+	user, principal, ok := ctrl.orgUser(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.orgService.DeleteUser(c.Request.Context(), actorRole(principal), user); err != nil {
+		writeOrganizationError(c, "Failed to delete user", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// ListTeams lists the teams of the caller's organization
+// @Summary List teams
+// @Description List the teams of the caller's organization by name
+// @Tags organizations
+// @Produce json
+// @Success 200 {array} models.Team
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /org/teams [get]
+func (ctrl *OrganizationController) ListTeams(c *gin.Context) {
+	principal, ok := ctrl.orgMember(c, models.UserRoleViewer)
+	if !ok {
+		return
+	}
+
+	teams, err := ctrl.orgService.ListTeams(c.Request.Context(), principal.User.OrganizationID)
+	if err != nil {
+		writeOrganizationError(c, "Failed to list teams", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// CreateTeam creates a team in the caller's organization
+// @Summary Create team
+// @Description Create a team in the caller's organization. Developers may manage the API keys of their teams. Requires the admin role.
+// @Tags organizations
+// @Accept json
+// @Produce json
+// @Param request body services.CreateTeamRequest true "Team"
+// @Success 201 {object} models.Team
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /org/teams [post]
+func (ctrl *OrganizationController) CreateTeam(c *gin.Context) {
+	var req services.CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	principal, ok := ctrl.orgMember(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	team, err := ctrl.orgService.CreateTeam(c.Request.Context(), principal.User.OrganizationID, &req)
+	if err != nil {
+		writeOrganizationError(c, "Failed to create team", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// GetTeam retrieves a team of the caller's organization with its members
+// @Summary Get team
+// @Description Get a team of the caller's organization with its members
+// @Tags organizations
+// @Produce json
+// @Param id path string true "Team ID"
+// @Success 200 {object} services.TeamResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org/teams/{id} [get]
+func (ctrl *OrganizationController) GetTeam(c *gin.Context) {
+	team, _, ok := ctrl.orgTeam(c, models.UserRoleViewer)
+	if !ok {
+		return
+	}
+
+	response, err := ctrl.orgService.GetTeam(c.Request.Context(), team)
+	if err != nil {
+		writeOrganizationError(c, "Failed to get team", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteTeam deletes a team of the caller's organization
+// @Summary Delete team
+// @Description Delete a team of the caller's organization. Its API keys keep working. Requires the admin role.
+// @Tags organizations
+// @Param id path string true "Team ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org/teams/{id} [delete]
+func (ctrl *OrganizationController) DeleteTeam(c *gin.Context) {
+	team, _, ok := ctrl.orgTeam(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.orgService.DeleteTeam(c.Request.Context(), team); err != nil {
+		writeOrganizationError(c, "Failed to delete team", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// AddTeamMember adds a user to a team
+// @Summary Add team member
+// @Description Add a user of the caller's organization to a team. Adding an existing member does nothing. Requires the admin role.
+// @Tags organizations
+// @Accept json
+// @Param id path string true "Team ID"
+// @Param request body AddTeamMemberRequest true "Member"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org/teams/{id}/members [post]
+func (ctrl *OrganizationController) AddTeamMember(c *gin.Context) {
+	var req AddTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	team, principal, ok := ctrl.orgTeam(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+	user, err := ctrl.authz.GetOrgUser(c.Request.Context(), principal, req.UserID)
+	if err != nil {
+		writeOrganizationError(c, "Failed to add team member", err)
+		return
+	}
+
+	if err := ctrl.orgService.AddTeamMember(c.Request.Context(), team, user); err != nil {
+		writeOrganizationError(c, "Failed to add team member", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RemoveTeamMember removes a user from a team
+// @Summary Remove team member
+// @Description Remove a user from a team of the caller's organization. Requires the admin role.
+// @Tags organizations
+// @Param id path string true "Team ID"
+// @Param user_id path string true "User ID"
+// @Success 204
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /org/teams/{id}/members/{user_id} [delete]
+func (ctrl *OrganizationController) RemoveTeamMember(c *gin.Context) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	team, _, ok := ctrl.orgTeam(c, models.UserRoleAdmin)
+	if !ok {
+		return
+	}
+
+	if err := ctrl.orgService.RemoveTeamMember(c.Request.Context(), team, userID); err != nil {
+		writeOrganizationError(c, "Failed to remove team member", err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// orgMember checks that the caller is a user with at least role in an
+// organization, responding with the error if not
+func (ctrl *OrganizationController) orgMember(c *gin.Context, role models.UserRole) (*services.Principal, bool) {
+	principal, ok := requireRole(c, ctrl.authz, role)
+	if !ok {
+		return nil, false
+	}
+	if principal.User == nil {
+		c.JSON(http.StatusForbidden, ErrorResponse{
+			Error:   "Forbidden",
+			Message: "the API key does not belong to a user of an organization",
+		})
+		return nil, false
+	}
+	return principal, true
+}
+
+// orgUser reads the user ID path parameter and retrieves the user from the
+// caller's organization
+func (ctrl *OrganizationController) orgUser(c *gin.Context, role models.UserRole) (*models.User, *services.Principal, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid user ID",
+			Message: err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	principal, ok := ctrl.orgMember(c, role)
+	if !ok {
+		return nil, nil, false
+	}
+	user, err := ctrl.authz.GetOrgUser(c.Request.Context(), principal, id)
+	if err != nil {
+		writeOrganizationError(c, "Failed to get user", err)
+		return nil, nil, false
+	}
+	return user, principal, true
+}
+
+// orgTeam reads the team ID path parameter and retrieves the team from the
+// caller's organization
+func (ctrl *OrganizationController) orgTeam(c *gin.Context, role models.UserRole) (*models.Team, *services.Principal, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid team ID",
+			Message: err.Error(),
+		})
+		return nil, nil, false
+	}
+
+	principal, ok := ctrl.orgMember(c, role)
+	if !ok {
+		return nil, nil, false
+	}
+	team, err := ctrl.authz.GetOrgTeam(c.Request.Context(), principal, id)
+	if err != nil {
+		writeOrganizationError(c, "Failed to get team", err)
+		return nil, nil, false
+	}
+	return team, principal, true
+}
+
+// actorRole returns the role the caller acts with; platform callers act as
+// owners
+func actorRole(principal *services.Principal) models.UserRole {
+	if principal.Platform {
+		return models.UserRoleOwner
+	}
+	return principal.Role()
+}
+
+// writeOrganizationError maps organization service errors to status codes
+func writeOrganizationError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrForbidden):
+		status = http.StatusForbidden
+	default:
+		switch err.Error() {
+		case "organization not found", "user not found", "team not found", "team member not found":
+			status = http.StatusNotFound
+		case "organization already exists", "user already exists", "team already exists",
+			"organization must keep at least one owner":
+			status = http.StatusConflict
+		case "slug must contain only lowercase letters, digits and hyphens",
+			"role must be owner, admin, developer or viewer":
+			status = http.StatusBadRequest
+		}
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:   message,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// maxWebhookBody bounds the size of payment webhook requests
+const maxWebhookBody = 1 << 20
+
+// PaymentController handles payment collection endpoints and provider
+// webhooks
+type PaymentController struct {
+	billingService *services.BillingService
+	paymentService *services.PaymentService
+}
+
+// NewPaymentController creates a new payment controller
+func NewPaymentController(billingService *services.BillingService, paymentService *services.PaymentService) *PaymentController {
+	return &PaymentController{
+		billingService: billingService,
+		paymentService: paymentService,
+	}
+}
+
+// UpdatePaymentStatusRequest sets the payment status of a billing record
+type UpdatePaymentStatusRequest struct {
+	Status models.PaymentStatus `json:"status" binding:"required"`
+}
+
+// RefundRequest refunds a paid billing record. A zero amount refunds all of
+// it.
+type RefundRequest struct {
+	Amount float64 `json:"amount" binding:"gte=0"`
+}
+
+// ChargeBillingRecord charges the invoice of a billing record
+// @Summary Charge billing record
+// @Description Charge the invoice of an unpaid billing record to its API key's customer at the payment provider. A declined charge marks the record failed.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param id path string true "Billing record ID"
+// @Success 200 {object} models.BillingRecord
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/billing/records/{id}/charge [post]
+func (ctrl *PaymentController) ChargeBillingRecord(c *gin.Context) {
+	id, ok := parseBillingRecordID(c)
+	if !ok {
+		return
+	}
+
+	record, err := ctrl.paymentService.ChargeBillingRecord(c.Request.Context(), id)
+	if err != nil {
+		writePaymentError(c, "Failed to charge billing record", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// RefundBillingRecord refunds a paid billing record
+// @Summary Refund billing record
+// @Description Refund part or all of the charge paying a billing record. A full refund marks the record refunded.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param id path string true "Billing record ID"
+// @Param request body RefundRequest false "Amount to refund"
+// @Success 200 {object} models.BillingRecord
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /admin/billing/records/{id}/refund [post]
+func (ctrl *PaymentController) RefundBillingRecord(c *gin.Context) {
+	id, ok := parseBillingRecordID(c)
+	if !ok {
+		return
+	}
+
+	var req RefundRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+
+	record, err := ctrl.paymentService.RefundBillingRecord(c.Request.Context(), id, req.Amount)
+	if err != nil {
+		writePaymentError(c, "Failed to refund billing record", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// UpdatePaymentStatus sets the payment status of a billing record by hand
+// @Summary Update payment status
+// @Description Set the payment status of a billing record, such as for an invoice paid outside the payment provider. Settling a record reinstates its API key if dunning suspended it.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param id path string true "Billing record ID"
+// @Param request body UpdatePaymentStatusRequest true "New payment status"
+// @Success 200 {object} models.BillingRecord
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /admin/billing/records/{id}/payment-status [put]
+func (ctrl *PaymentController) UpdatePaymentStatus(c *gin.Context) {
+	id, ok := parseBillingRecordID(c)
+	if !ok {
+		return
+	}
+
+	var req UpdatePaymentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+		})
+		return
+	}
+	if !req.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid payment status",
+			Message: "status must be one of pending, paid, overdue, failed or refunded",
+		})
+		return
+	}
+
+	ctx := c.Request.Context()
+	record, err := ctrl.billingService.UpdateBillingStatus(ctx, id, req.Status)
+	if err != nil {
+		writePaymentError(c, "Failed to update payment status", err)
+		return
+	}
+	if !record.PaymentStatus.IsUnpaid() {
+		if _, err := ctrl.paymentService.ReinstateIfSettled(ctx, record.APIKeyID); err != nil {
+			writePaymentError(c, "Failed to reinstate API key", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, record)
+}
+
+// RunDunning runs dunning now rather than waiting for the daily run
+// @Summary Run dunning
+// @Description Mark invoices past their due date overdue, suspend the API keys of invoices unpaid past the suspension threshold, and reinstate keys whose invoices have been settled
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.DunningResult
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/billing/dunning [post]
+func (ctrl *PaymentController) RunDunning(c *gin.Context) {
+	result, err := ctrl.paymentService.RunDunning(c.Request.Context())
+	if err != nil {
+		writePaymentError(c, "Failed to run dunning", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// HandleWebhook applies a signed payment provider webhook
+// @Summary Payment provider webhook
+// @Description Receive payment outcomes from the payment provider. Requests must carry a valid signature; each event is applied once, so redeliveries are acknowledged without effect.
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Router /webhooks/payments [post]
+func (ctrl *PaymentController) HandleWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody+1))
+	if err != nil || len(payload) > maxWebhookBody {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid webhook",
+			Message: "request body is unreadable or too large",
+		})
+		return
+	}
+
+	applied, err := ctrl.paymentService.HandleWebhook(c.Request.Context(), payload, c.Request.Header)
+	if err != nil {
+		writePaymentError(c, "Failed to handle webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"received": true,
+		"applied":  applied,
+	})
+}
+
+// writePaymentError writes the response for an error from a payment
+// operation
+func writePaymentError(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrPaymentsDisabled):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, payment.ErrInvalidSignature), errors.Is(err, payment.ErrInvalidPayload):
+		status = http.StatusBadRequest
+	default:
+		switch err.Error() {
+		case "billing record not found", "api key not found":
+			status = http.StatusNotFound
+		case "billing record is already settled", "billing record is not paid",
+			"billing record has no provider charge", "billing record payment status changed concurrently":
+			status = http.StatusConflict
+		case "invalid refund amount":
+			status = http.StatusBadRequest
+		}
+	}
+
+	c.JSON(status, ErrorResponse{
+		Error:   message,
+		Message: err.Error(),
+	})
+}
@@ -0,0 +1,60 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+func TestPaymentController_Webhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	provider := payment.NewFakeProvider("whsec_test")
+	ctrl := NewPaymentController(nil, services.NewPaymentService(nil, nil, nil, provider, services.DunningPolicy{}, nil))
+	router := gin.New()
+	router.POST("/webhooks/payments", ctrl.HandleWebhook)
+
+	payload, header, err := provider.Webhook(&payment.Event{ID: "evt_1", Type: payment.EventChargeSucceeded, ChargeID: "ch_1"})
+	assert.NoError(t, err)
+	header.Set(payment.StripeSignatureHeader, "t=1,v1=forged")
+
+	req := httptest.NewRequest("POST", "/webhooks/payments", strings.NewReader(string(payload)))
+	req.Header = header
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid webhook signature")
+}
+
+func TestPaymentController_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ctrl := NewPaymentController(nil, services.NewPaymentService(nil, nil, nil, nil, services.DunningPolicy{}, nil))
+	router := gin.New()
+	router.POST("/webhooks/payments", ctrl.HandleWebhook)
+	router.POST("/billing/records/:id/charge", ctrl.ChargeBillingRecord)
+	router.POST("/billing/records/:id/refund", ctrl.RefundBillingRecord)
+
+	id := uuid.New().String()
+	for _, path := range []string{
+		"/webhooks/payments",
+		"/billing/records/" + id + "/charge",
+		"/billing/records/" + id + "/refund",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", "/billing/records/not-a-uuid/charge", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -15,16 +15,19 @@ import (
 type RateLimitController struct {
 	rateLimitService services.RateLimitService
 	apiKeyService    services.APIKeyService
+	authz            *services.AuthorizationService
 }
 
 // NewRateLimitController creates a new rate limit controller
 func NewRateLimitController(
 	rateLimitService services.RateLimitService,
 	apiKeyService services.APIKeyService,
+	authz *services.AuthorizationService,
 ) *RateLimitController {
 	return &RateLimitController{
 		rateLimitService: rateLimitService,
 		apiKeyService:    apiKeyService,
+		authz:            authz,
 	}
 }
 
@@ -37,6 +40,8 @@ func NewRateLimitController(
 // @Param request body RateLimitCheckRequest true "Rate limit check request"
 // @Success 200 {object} services.RateLimitResult
 // @Failure 400 {object} ErrorResponse
+// @Failure 402 {object} CreditsExhaustedResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 429 {object} RateLimitExceededResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /rate-limit/check [post]
@@ -50,6 +55,10 @@ func (ctrl *RateLimitController) CheckRateLimit(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, req.APIKeyID, services.KeyActionUse); !ok {
+		return
+	}
+
 	// Convert to service request
 	serviceReq := &services.RateLimitRequest{
 		APIKeyID:  req.APIKeyID,
@@ -71,12 +80,29 @@ func (ctrl *RateLimitController) CheckRateLimit(c *gin.Context) {
 	}
 
 	// Set rate limit headers
-	c.Header("X-RateLimit-Limit", string(rune(result.Limit)))
-	c.Header("X-RateLimit-Remaining", string(rune(result.Remaining)))
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
 	c.Header("X-RateLimit-Reset", result.ResetTime.Format(time.RFC3339))
+	if result.CreditBalance != nil {
+		c.Header("X-Credits-Balance", strconv.FormatFloat(*result.CreditBalance, 'f', -1, 64))
+	}
+
+	if result.CreditsExhausted || result.SpendCapReached {
+		message := "Prepaid credits exhausted. Top up the credit account to continue."
+		if result.SpendCapReached {
+			message = "Monthly spend cap reached."
+		}
+		c.JSON(http.StatusPaymentRequired, CreditsExhaustedResponse{
+			Error:           "Payment required",
+			Message:         message,
+			CreditBalance:   result.CreditBalance,
+			SpendCapReached: result.SpendCapReached,
+		})
+		return
+	}
 
 	if !result.Allowed {
-		c.Header("Retry-After", string(rune(result.RetryAfter)))
+		c.Header("Retry-After", strconv.Itoa(result.RetryAfter))
 		c.JSON(http.StatusTooManyRequests, RateLimitExceededResponse{
 			Error:             "Rate limit exceeded",
 			Message:           "Too many requests. Please try again later.",
@@ -85,6 +111,8 @@ func (ctrl *RateLimitController) CheckRateLimit(c *gin.Context) {
 			ResetTime:         result.ResetTime,
 			RetryAfter:        result.RetryAfter,
 			ViolationRecorded: result.ViolationRecorded,
+			PenaltyLevel:      result.PenaltyLevel,
+			CooldownUntil:     result.CooldownUntil,
 		})
 		return
 	}
@@ -101,6 +129,7 @@ func (ctrl *RateLimitController) CheckRateLimit(c *gin.Context) {
 // @Param api_key_id path string true "API Key ID"
 // @Success 200 {object} services.RateLimitInfo
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /rate-limit/{api_key_id}/info [get]
@@ -115,6 +144,10 @@ func (ctrl *RateLimitController) GetRateLimitInfo(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
 	info, err := ctrl.rateLimitService.GetRateLimitInfo(c.Request.Context(), apiKeyID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -136,6 +169,7 @@ func (ctrl *RateLimitController) GetRateLimitInfo(c *gin.Context) {
 // @Param api_key_id path string true "API Key ID"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /rate-limit/{api_key_id}/reset [post]
@@ -150,6 +184,10 @@ func (ctrl *RateLimitController) ResetRateLimit(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionManage); !ok {
+		return
+	}
+
 	if err := ctrl.rateLimitService.ResetRateLimit(c.Request.Context(), apiKeyID); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to reset rate limit",
@@ -173,6 +211,7 @@ func (ctrl *RateLimitController) ResetRateLimit(c *gin.Context) {
 // @Param request body UpdateRateLimitRequest true "Update rate limit request"
 // @Success 200 {object} SuccessResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /rate-limit/{api_key_id} [put]
@@ -196,6 +235,10 @@ func (ctrl *RateLimitController) UpdateRateLimit(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionManage); !ok {
+		return
+	}
+
 	if err := ctrl.rateLimitService.UpdateRateLimit(c.Request.Context(), apiKeyID, req.NewLimit); err != nil {
 		if err.Error() == "api key not found" {
 			c.JSON(http.StatusNotFound, ErrorResponse{
@@ -226,6 +269,7 @@ func (ctrl *RateLimitController) UpdateRateLimit(c *gin.Context) {
 // @Param hours query int false "Hours to look back" default(24)
 // @Success 200 {object} ViolationHistoryResponse
 // @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
 // @Router /rate-limit/{api_key_id}/violations [get]
 func (ctrl *RateLimitController) GetViolationHistory(c *gin.Context) {
@@ -239,6 +283,10 @@ func (ctrl *RateLimitController) GetViolationHistory(c *gin.Context) {
 		return
 	}
 
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
 	hours := 24 // default
 	if hoursStr := c.Query("hours"); hoursStr != "" {
 		if h, err := strconv.Atoi(hoursStr); err == nil && h > 0 && h <= 168 { // max 1 week
@@ -315,21 +363,23 @@ func (ctrl *RateLimitController) ValidateAPIKey(c *gin.Context) {
 	}
 	
 	response := &services.APIKeyResponse{
-		ID:          validatedKey.ID,
-		Name:        validatedKey.Name,
-		Description: validatedKey.Description,
-		Status:      validatedKey.Status,
-		Tier:        validatedKey.Tier,
-		UserID:      userID,
-		TeamID:      validatedKey.TeamID,
-		Tags:        validatedKey.Tags,
-		RateLimit:   validatedKey.RateLimit,
-		QuotaLimit:  validatedKey.QuotaLimit,
-		TotalUsage:  validatedKey.TotalUsage,
-		LastUsedAt:  validatedKey.LastUsedAt,
-		ExpiresAt:   validatedKey.ExpiresAt,
-		CreatedAt:   validatedKey.CreatedAt,
-		UpdatedAt:   validatedKey.UpdatedAt,
+		ID:             validatedKey.ID,
+		Name:           validatedKey.Name,
+		Description:    validatedKey.Description,
+		Status:         validatedKey.Status,
+		Tier:           validatedKey.Tier,
+		UserID:         userID,
+		TeamID:         validatedKey.TeamID,
+		OrganizationID: validatedKey.OrganizationID,
+		Tags:           validatedKey.Tags,
+		Scopes:         validatedKey.Scopes,
+		RateLimit:      validatedKey.RateLimit,
+		QuotaLimit:     validatedKey.QuotaLimit,
+		TotalUsage:     validatedKey.TotalUsage,
+		LastUsedAt:     validatedKey.LastUsedAt,
+		ExpiresAt:      validatedKey.ExpiresAt,
+		CreatedAt:      validatedKey.CreatedAt,
+		UpdatedAt:      validatedKey.UpdatedAt,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -349,13 +399,24 @@ type RateLimitCheckRequest struct {
 
 // RateLimitExceededResponse represents a rate limit exceeded response
 type RateLimitExceededResponse struct {
-	Error             string    `json:"error"`
-	Message           string    `json:"message"`
-	Limit             int       `json:"limit"`
-	Remaining         int       `json:"remaining"`
-	ResetTime         time.Time `json:"reset_time"`
-	RetryAfter        int       `json:"retry_after"`
-	ViolationRecorded bool      `json:"violation_recorded"`
+	Error             string     `json:"error"`
+	Message           string     `json:"message"`
+	Limit             int        `json:"limit"`
+	Remaining         int        `json:"remaining"`
+	ResetTime         time.Time  `json:"reset_time"`
+	RetryAfter        int        `json:"retry_after"`
+	ViolationRecorded bool       `json:"violation_recorded"`
+	PenaltyLevel      int        `json:"penalty_level"`
+	CooldownUntil     *time.Time `json:"cooldown_until,omitempty"`
+}
+
+// CreditsExhaustedResponse represents a request rejected because the key's
+// prepaid credits cannot pay for it
+type CreditsExhaustedResponse struct {
+	Error           string   `json:"error"`
+	Message         string   `json:"message"`
+	CreditBalance   *float64 `json:"credit_balance,omitempty"`
+	SpendCapReached bool     `json:"spend_cap_reached"`
 }
 
 // UpdateRateLimitRequest represents an update rate limit request
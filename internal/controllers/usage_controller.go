@@ -0,0 +1,378 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// UsageController handles usage analytics endpoints
+type UsageController struct {
+	usageTrackingService services.UsageTrackingService
+	authz                *services.AuthorizationService
+}
+
+// NewUsageController creates a new usage controller
+func NewUsageController(usageTrackingService services.UsageTrackingService, authz *services.AuthorizationService) *UsageController {
+	return &UsageController{
+		usageTrackingService: usageTrackingService,
+		authz:                authz,
+	}
+}
+
+// GetUsageStats retrieves aggregated usage of an API key
+// @Summary Get usage statistics
+// @Description Get request counts, success and error rates, bandwidth and status code and country breakdowns of an API key over the last hour, day, week, month or year
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Success 200 {object} services.UsageStatistics
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/keys/{api_key_id}/stats [get]
+func (ctrl *UsageController) GetUsageStats(c *gin.Context) {
+	apiKeyID, period, ok := parseUsageKeyParams(c, ctrl.authz)
+	if !ok {
+		return
+	}
+
+	stats, err := ctrl.usageTrackingService.GetUsageStats(c.Request.Context(), apiKeyID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get usage statistics",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetKeyEndpoints lists the endpoints an API key called, busiest first
+// @Summary Get API key endpoints
+// @Description List the endpoints an API key called in the period with request counts, latency, error rate and bandwidth, busiest first
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/keys/{api_key_id}/endpoints [get]
+func (ctrl *UsageController) GetKeyEndpoints(c *gin.Context) {
+	apiKeyID, period, ok := parseUsageKeyParams(c, ctrl.authz)
+	if !ok {
+		return
+	}
+
+	endpoints, err := ctrl.usageTrackingService.GetTopEndpoints(c.Request.Context(), apiKeyID, period, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get endpoint usage",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginate(endpoints, parseUsagePagination(c)))
+}
+
+// GetUsageTimeSeries retrieves the usage of an API key per hour or day
+// @Summary Get usage time series
+// @Description Get the requests, errors, rate limited requests, latency and bandwidth of an API key per hour or per day. Buckets are aligned to the tz time zone and empty buckets are included.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Param granularity query string false "Bucket size (hour, day); hour for periods up to a day, day otherwise"
+// @Param tz query string false "IANA time zone, e.g. Europe/Amsterdam" default(UTC)
+// @Success 200 {array} services.UsageTimeBucket
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/keys/{api_key_id}/timeseries [get]
+func (ctrl *UsageController) GetUsageTimeSeries(c *gin.Context) {
+	apiKeyID, period, ok := parseUsageKeyParams(c, ctrl.authz)
+	if !ok {
+		return
+	}
+
+	granularity := services.UsageGranularity(c.Query("granularity"))
+	if granularity == "" {
+		granularity = services.UsageGranularityDay
+		if period == services.TimePeriodHour || period == services.TimePeriodDay {
+			granularity = services.UsageGranularityHour
+		}
+	}
+	if !granularity.IsValid() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid granularity",
+			Message: fmt.Sprintf("granularity must be hour or day, got %q", granularity),
+		})
+		return
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid time zone",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	buckets, err := ctrl.usageTrackingService.GetUsageTimeSeries(c.Request.Context(), apiKeyID, period, granularity, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get usage time series",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, buckets)
+}
+
+// GetUsageTrends retrieves the usage trend of an API key
+// @Summary Get usage trends
+// @Description Compare the usage of an API key with the previous period and find its peak hour (UTC)
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Success 200 {object} services.UsageTrends
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/keys/{api_key_id}/trends [get]
+func (ctrl *UsageController) GetUsageTrends(c *gin.Context) {
+	apiKeyID, period, ok := parseUsageKeyParams(c, ctrl.authz)
+	if !ok {
+		return
+	}
+
+	trends, err := ctrl.usageTrackingService.GetUsageTrends(c.Request.Context(), apiKeyID, period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get usage trends",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, trends)
+}
+
+// GetSLOReport retrieves latency percentiles, status classes and bandwidth of
+// an API key over a time range
+// @Summary Get SLO report
+// @Description Get the p50, p95 and p99 response time, 2xx/3xx/4xx/5xx request counts and request and response bytes of an API key in [start, end), overall and per endpoint. Percentiles are estimated within 1% of the exact values. The range defaults to the last 24 hours.
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param api_key_id path string true "API key ID"
+// @Param start query string false "Range start (RFC 3339)"
+// @Param end query string false "Range end (RFC 3339), defaults to now"
+// @Success 200 {object} services.SLOReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/keys/{api_key_id}/slo [get]
+func (ctrl *UsageController) GetSLOReport(c *gin.Context) {
+	apiKeyID, err := uuid.Parse(c.Param("api_key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid API key ID",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if _, ok := authorizeAPIKey(c, ctrl.authz, apiKeyID, services.KeyActionRead); !ok {
+		return
+	}
+
+	endTime := time.Now()
+	if value := c.Query("end"); value != "" {
+		if endTime, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid end time",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	startTime := endTime.Add(-24 * time.Hour)
+	if value := c.Query("start"); value != "" {
+		if startTime, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid start time",
+				Message: err.Error(),
+			})
+			return
+		}
+	}
+	if !startTime.Before(endTime) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid time range",
+			Message: "start must be before end",
+		})
+		return
+	}
+
+	report, err := ctrl.usageTrackingService.GetSLOReport(c.Request.Context(), apiKeyID, startTime, endTime)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get SLO report",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetTopAPIKeys lists the API keys with the most requests
+// @Summary Get top API keys
+// @Description List API keys by request count across the system, busiest first
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/top-api-keys [get]
+func (ctrl *UsageController) GetTopAPIKeys(c *gin.Context) {
+	// Usage across all API keys spans every organization
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	period, ok := parseUsagePeriod(c)
+	if !ok {
+		return
+	}
+
+	usage, err := ctrl.usageTrackingService.GetTopAPIKeys(c.Request.Context(), period, 0)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get top API keys",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginate(usage, parseUsagePagination(c)))
+}
+
+// GetEndpointStats lists usage of every endpoint across all API keys
+// @Summary Get endpoint statistics
+// @Description List every endpoint with request counts, latency, error rate and bandwidth across all API keys, busiest first
+// @Tags usage
+// @Accept json
+// @Produce json
+// @Param period query string false "Time period (hour, day, week, month, year)" default(day)
+// @Param page query int false "Page number" default(1)
+// @Param page_size query int false "Page size" default(20)
+// @Success 200 {object} repositories.PaginatedResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /usage/endpoints [get]
+func (ctrl *UsageController) GetEndpointStats(c *gin.Context) {
+	// Usage across all API keys spans every organization
+	if !requirePlatform(c, ctrl.authz) {
+		return
+	}
+
+	period, ok := parseUsagePeriod(c)
+	if !ok {
+		return
+	}
+
+	endpoints, err := ctrl.usageTrackingService.GetEndpointStats(c.Request.Context(), period)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to get endpoint statistics",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, paginate(endpoints, parseUsagePagination(c)))
+}
+
+// parseUsageKeyParams reads the API key ID path parameter and the period
+// query parameter, writing a 400 response if either is invalid, and checks
+// that the caller may read the key
+func parseUsageKeyParams(c *gin.Context, authz *services.AuthorizationService) (uuid.UUID, services.TimePeriod, bool) {
+	apiKeyID, err := uuid.Parse(c.Param("api_key_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid API key ID",
+			Message: err.Error(),
+		})
+		return uuid.Nil, "", false
+	}
+	if _, ok := authorizeAPIKey(c, authz, apiKeyID, services.KeyActionRead); !ok {
+		return uuid.Nil, "", false
+	}
+
+	period, ok := parseUsagePeriod(c)
+	return apiKeyID, period, ok
+}
+
+// parseUsagePeriod reads the period query parameter, defaulting to a day
+func parseUsagePeriod(c *gin.Context) (services.TimePeriod, bool) {
+	period := services.TimePeriod(c.DefaultQuery("period", string(services.TimePeriodDay)))
+	if !period.IsValid() {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid period",
+			Message: fmt.Sprintf("period must be hour, day, week, month or year, got %q", period),
+		})
+		return "", false
+	}
+	return period, true
+}
+
+// parseUsagePagination reads the page and page_size query parameters
+func parseUsagePagination(c *gin.Context) *repositories.PaginationParams {
+	pagination := &repositories.PaginationParams{
+		Page:     1,
+		PageSize: 20,
+	}
+
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		pagination.Page = p
+	}
+	if ps, err := strconv.Atoi(c.Query("page_size")); err == nil && ps > 0 && ps <= 100 {
+		pagination.PageSize = ps
+	}
+	return pagination
+}
+
+// paginate returns one page of items, which are already in result order
+func paginate[T any](items []T, pagination *repositories.PaginationParams) *repositories.PaginatedResult {
+	start := min(pagination.GetOffset(), len(items))
+	end := min(start+pagination.GetLimit(), len(items))
+	return repositories.NewPaginatedResult(items[start:end], int64(len(items)), pagination)
+}
@@ -0,0 +1,205 @@
+// Package invoice renders billing records as invoices in HTML and PDF, and
+// bundles the invoices of a billing period into ZIP archives.
+package invoice
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// Format is a format invoices are rendered in
+type Format string
+
+const (
+	FormatPDF  Format = "pdf"
+	FormatHTML Format = "html"
+)
+
+// IsValid returns true for a supported format
+func (f Format) IsValid() bool {
+	return f == FormatPDF || f == FormatHTML
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == FormatHTML {
+		return "text/html; charset=utf-8"
+	}
+	return "application/pdf"
+}
+
+// Terms are the details every invoice carries besides its billing record:
+// who issues it and how many days after its period ends it is due
+type Terms struct {
+	IssuerName       string
+	IssuerAddress    string
+	PaymentTermsDays int
+}
+
+// TermsFromConfig builds invoice terms from the billing configuration
+func TermsFromConfig(cfg *config.BillingConfig) Terms {
+	return Terms{
+		IssuerName:       cfg.Invoice.IssuerName,
+		IssuerAddress:    cfg.Invoice.IssuerAddress,
+		PaymentTermsDays: cfg.PaymentTermsDays,
+	}
+}
+
+// Customer is the API key an invoice bills
+type Customer struct {
+	APIKeyID uuid.UUID
+	Name     string
+	Tier     models.APIKeyTier
+}
+
+// LineItem is one charge of an invoice. Quantity is formatted with its unit.
+type LineItem struct {
+	Description string
+	Quantity    string
+	Amount      float64
+}
+
+// Invoice is a billing record laid out for the customer. PeriodEnd is
+// exclusive; LastDay is the last day the invoice covers.
+type Invoice struct {
+	RecordID      uuid.UUID
+	Number        string
+	IssuerName    string
+	IssuerAddress []string
+	Customer      Customer
+	IssuedAt      time.Time
+	DueAt         time.Time
+	PeriodStart   time.Time
+	PeriodEnd     time.Time
+	Currency      string
+	LineItems     []LineItem
+	Total         float64
+	PaymentStatus models.PaymentStatus
+	PaidAt        *time.Time
+}
+
+// New builds the invoice of a billing record. The record's APIKey relation
+// names the customer. The invoice is issued when the period ends and is due
+// after the payment terms.
+func New(record *models.BillingRecord, terms Terms) *Invoice {
+	invoice := &Invoice{
+		RecordID:   record.ID,
+		Number:     Number(record),
+		IssuerName: terms.IssuerName,
+		Customer: Customer{
+			APIKeyID: record.APIKeyID,
+			Name:     record.APIKey.Name,
+			Tier:     models.APIKeyTier(record.TierAtEnd),
+		},
+		IssuedAt:      record.PeriodEnd,
+		DueAt:         record.PeriodEnd.AddDate(0, 0, terms.PaymentTermsDays),
+		PeriodStart:   record.PeriodStart,
+		PeriodEnd:     record.PeriodEnd,
+		Currency:      record.Currency,
+		Total:         record.TotalAmount,
+		PaymentStatus: record.PaymentStatus,
+		PaidAt:        record.PaidAt,
+	}
+	for _, line := range strings.Split(terms.IssuerAddress, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			invoice.IssuerAddress = append(invoice.IssuerAddress, line)
+		}
+	}
+
+	for _, item := range record.LineItems {
+		invoice.LineItems = append(invoice.LineItems, LineItem{
+			Description: item.Description,
+			Quantity:    formatQuantity(item),
+			Amount:      item.Amount,
+		})
+	}
+
+	// Records calculated before line items were kept only have their amounts
+	if len(record.LineItems) == 0 {
+		if record.BaseAmount != 0 || record.OverageAmount == 0 {
+			invoice.LineItems = append(invoice.LineItems, LineItem{
+				Description: "Base fee",
+				Quantity:    "1 period",
+				Amount:      record.BaseAmount,
+			})
+		}
+		if record.OverageAmount != 0 {
+			invoice.LineItems = append(invoice.LineItems, LineItem{
+				Description: "Overage",
+				Quantity:    formatInteger(record.OverageRequests) + " requests",
+				Amount:      record.OverageAmount,
+			})
+		}
+	}
+
+	return invoice
+}
+
+// Number returns the invoice number of a billing record: the month its period
+// starts in and the start of the record ID
+func Number(record *models.BillingRecord) string {
+	id := strings.ToUpper(strings.ReplaceAll(record.ID.String(), "-", ""))
+	return fmt.Sprintf("INV-%s-%s", record.PeriodStart.UTC().Format("200601"), id[:8])
+}
+
+// LastDay returns the last day the invoice covers
+func (i *Invoice) LastDay() time.Time {
+	return i.PeriodEnd.Add(-time.Nanosecond)
+}
+
+// IsPaid returns true once the invoice is paid
+func (i *Invoice) IsPaid() bool {
+	return i.PaymentStatus == models.PaymentStatusPaid && i.PaidAt != nil
+}
+
+// formatQuantity formats the quantity of a line item with its unit
+func formatQuantity(item models.BillingLineItem) string {
+	switch item.Kind {
+	case models.BillingLineItemBaseFee:
+		if item.Quantity >= 1 {
+			return "1 period"
+		}
+		return strconv.FormatFloat(math.Round(item.Quantity*10000)/100, 'f', -1, 64) + "% of period"
+	case models.BillingLineItemOverage:
+		return formatInteger(int64(item.Quantity)) + " requests"
+	case models.BillingLineItemBandwidth:
+		return strconv.FormatFloat(item.Quantity, 'f', -1, 64) + " GB"
+	}
+	return strconv.FormatFloat(item.Quantity, 'f', -1, 64)
+}
+
+// FormatAmount formats an amount of money with its currency, to the cent
+func FormatAmount(amount float64, currency string) string {
+	cents := int64(math.Round(math.Abs(amount) * 100))
+	formatted := fmt.Sprintf("%s.%02d", formatInteger(cents/100), cents%100)
+	if amount < 0 && cents != 0 {
+		formatted = "-" + formatted
+	}
+	return currency + " " + formatted
+}
+
+// formatInteger formats a non-negative integer with thousands separators
+func formatInteger(n int64) string {
+	digits := strconv.FormatInt(n, 10)
+	var b strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(digit)
+	}
+	return b.String()
+}
+
+// formatDate formats the day of a time in UTC
+func formatDate(t time.Time) string {
+	return t.UTC().Format("January 2, 2006")
+}
@@ -0,0 +1,227 @@
+package invoice
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+func testRecord() *models.BillingRecord {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	return &models.BillingRecord{
+		ID:            uuid.MustParse("1a2b3c4d-0000-4000-8000-000000000001"),
+		APIKeyID:      uuid.MustParse("00000000-0000-4000-8000-0000000000aa"),
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		Currency:      "USD",
+		TierAtEnd:     "pro",
+		BaseAmount:    49,
+		OverageAmount: 1234.5,
+		TotalAmount:   1283.5,
+		PaymentStatus: models.PaymentStatusPending,
+		LineItems: []models.BillingLineItem{
+			{Kind: models.BillingLineItemBaseFee, Description: "Pro plan, prorated <billing>", Quantity: 0.4839, Amount: 24.5},
+			{Kind: models.BillingLineItemBaseFee, Description: "Pro plan", Quantity: 1, Amount: 24.5},
+			{Kind: models.BillingLineItemOverage, Description: "Pro plan requests beyond the included", Quantity: 2469000, Amount: 1234.5},
+		},
+		APIKey: models.APIKey{Name: "Acme (production)"},
+	}
+}
+
+func TestNew(t *testing.T) {
+	terms := Terms{IssuerName: "Viva", IssuerAddress: "1 Main Street\n\n Springfield ", PaymentTermsDays: 30}
+	invoice := New(testRecord(), terms)
+
+	assert.Equal(t, "INV-202601-1A2B3C4D", invoice.Number)
+	assert.Equal(t, []string{"1 Main Street", "Springfield"}, invoice.IssuerAddress)
+	assert.Equal(t, "Acme (production)", invoice.Customer.Name)
+	assert.Equal(t, models.APIKeyTierPro, invoice.Customer.Tier)
+	assert.Equal(t, time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC), invoice.DueAt)
+	assert.Equal(t, time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC), invoice.LastDay().Truncate(24*time.Hour))
+
+	require.Len(t, invoice.LineItems, 3)
+	assert.Equal(t, "48.39% of period", invoice.LineItems[0].Quantity)
+	assert.Equal(t, "1 period", invoice.LineItems[1].Quantity)
+	assert.Equal(t, "2,469,000 requests", invoice.LineItems[2].Quantity)
+}
+
+func TestNew_RecordWithoutLineItems(t *testing.T) {
+	record := testRecord()
+	record.LineItems = nil
+	record.OverageRequests = 2469000
+
+	invoice := New(record, Terms{})
+	require.Len(t, invoice.LineItems, 2)
+	assert.Equal(t, LineItem{Description: "Base fee", Quantity: "1 period", Amount: 49}, invoice.LineItems[0])
+	assert.Equal(t, "2,469,000 requests", invoice.LineItems[1].Quantity)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "USD 0.00", FormatAmount(0, "USD"))
+	assert.Equal(t, "USD 1,234.57", FormatAmount(1234.5678, "USD"))
+	assert.Equal(t, "EUR 1,000,000.10", FormatAmount(1000000.1, "EUR"))
+	assert.Equal(t, "USD -5.25", FormatAmount(-5.25, "USD"))
+}
+
+func TestRenderHTML(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, renderer.Render(&out, New(testRecord(), Terms{IssuerName: "Viva"}), FormatHTML))
+
+	html := out.String()
+	assert.Contains(t, html, "Invoice INV-202601-1A2B3C4D")
+	assert.Contains(t, html, "January 1, 2026 to January 31, 2026")
+	assert.Contains(t, html, "Pro plan, prorated &lt;billing&gt;")
+	assert.Contains(t, html, "USD 1,283.50")
+	assert.NotContains(t, html, "Paid on")
+
+	record := testRecord()
+	paidAt := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	record.PaymentStatus, record.PaidAt = models.PaymentStatusPaid, &paidAt
+	out.Reset()
+	require.NoError(t, renderer.RenderHTML(&out, New(record, Terms{})))
+	assert.Contains(t, out.String(), "Paid on February 10, 2026")
+}
+
+func TestRenderHTML_CustomTemplate(t *testing.T) {
+	renderer, err := NewRenderer(`{{.Number}}{{range .LineItems}};{{money .Amount $.Currency}}{{end}}`)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, renderer.RenderHTML(&out, New(testRecord(), Terms{})))
+	assert.Equal(t, "INV-202601-1A2B3C4D;USD 24.50;USD 24.50;USD 1,234.50", out.String())
+
+	_, err = NewRenderer(`{{.Number`)
+	assert.Error(t, err)
+}
+
+// pdfStreams checks the cross-reference table of a PDF and returns its
+// decompressed page contents
+func pdfStreams(t *testing.T, pdf []byte) []string {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+
+	match := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(pdf)
+	require.NotNil(t, match)
+	xref, err := strconv.Atoi(string(match[1]))
+	require.NoError(t, err)
+	require.True(t, bytes.HasPrefix(pdf[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n \n`).FindAllSubmatch(pdf[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, entry := range entries {
+		offset, err := strconv.Atoi(string(entry[1]))
+		require.NoError(t, err)
+		assert.True(t, bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+
+	var streams []string
+	for _, stream := range regexp.MustCompile(`(?s)/Length (\d+) /Filter /FlateDecode >>\nstream\n`).FindAllSubmatchIndex(pdf, -1) {
+		length, err := strconv.Atoi(string(pdf[stream[2]:stream[3]]))
+		require.NoError(t, err)
+		zr, err := zlib.NewReader(bytes.NewReader(pdf[stream[1] : stream[1]+length]))
+		require.NoError(t, err)
+		content, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		streams = append(streams, string(content))
+	}
+	return streams
+}
+
+func TestRenderPDF(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, renderer.Render(&out, New(testRecord(), Terms{IssuerName: "Viva – Billing"}), FormatPDF))
+
+	pages := pdfStreams(t, out.Bytes())
+	require.Len(t, pages, 1)
+	assert.Contains(t, pages[0], "(INV-202601-1A2B3C4D) Tj")
+	assert.Contains(t, pages[0], `(Acme \(production\)) Tj`)
+	assert.Contains(t, pages[0], `(Viva \226 Billing) Tj`)
+	assert.Contains(t, pages[0], "(USD 1,283.50) Tj")
+	assert.Contains(t, out.String(), "/Count 1")
+}
+
+func TestRenderPDF_Pages(t *testing.T) {
+	record := testRecord()
+	record.LineItems = nil
+	for i := 0; i < 80; i++ {
+		record.LineItems = append(record.LineItems, models.BillingLineItem{
+			Kind:        models.BillingLineItemBandwidth,
+			Description: fmt.Sprintf("Item %d with a description long enough to wrap onto a second line of the table", i),
+			Quantity:    1.5,
+			Amount:      1,
+		})
+	}
+
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+	var out bytes.Buffer
+	require.NoError(t, renderer.RenderPDF(&out, New(record, Terms{})))
+
+	pages := pdfStreams(t, out.Bytes())
+	require.Greater(t, len(pages), 1)
+	assert.Contains(t, out.String(), fmt.Sprintf("/Count %d", len(pages)))
+	for i, page := range pages {
+		assert.Contains(t, page, fmt.Sprintf("(Page %d of %d) Tj", i+1, len(pages)))
+		assert.Contains(t, page, "(Description) Tj")
+	}
+	assert.Contains(t, pages[len(pages)-1], "(Total) Tj")
+	assert.Contains(t, pages[len(pages)-1], "(Item 79 with")
+	assert.Equal(t, 80, strings.Count(strings.Join(pages, ""), "(1.5 GB) Tj"))
+}
+
+func TestWriteArchive(t *testing.T) {
+	renderer, err := NewRenderer("")
+	require.NoError(t, err)
+
+	first := New(testRecord(), Terms{})
+	record := testRecord()
+	record.ID = uuid.MustParse("1a2b3c4d-0000-4000-8000-000000000002")
+	second := New(record, Terms{})
+	record = testRecord()
+	record.ID = uuid.MustParse("99999999-0000-4000-8000-000000000003")
+	third := New(record, Terms{})
+
+	var out bytes.Buffer
+	require.NoError(t, renderer.WriteArchive(&out, []*Invoice{first, second, third}, FormatPDF))
+
+	archive, err := zip.NewReader(bytes.NewReader(out.Bytes()), int64(out.Len()))
+	require.NoError(t, err)
+
+	var names []string
+	for _, file := range archive.File {
+		names = append(names, file.Name)
+		r, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(r)
+		require.NoError(t, err)
+		pdfStreams(t, content)
+	}
+	assert.Equal(t, []string{
+		"INV-202601-1A2B3C4D.pdf",
+		"INV-202601-1A2B3C4D-1a2b3c4d-0000-4000-8000-000000000002.pdf",
+		"INV-202601-99999999.pdf",
+	}, names)
+
+	assert.Error(t, renderer.WriteArchive(io.Discard, nil, Format("docx")))
+}
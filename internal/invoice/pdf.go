@@ -0,0 +1,333 @@
+package invoice
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Page geometry in points; pages are A4
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+)
+
+// Fonts of the PDF resource dictionary
+const (
+	pdfFontRegular = "F1"
+	pdfFontBold    = "F2"
+)
+
+// Columns of the line item table
+const (
+	pdfDescriptionWidth = 300
+	pdfQuantityRight    = 440
+	pdfAmountRight      = pdfPageWidth - pdfMargin
+)
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, in thousandths of the font size. Helvetica-Bold text is measured
+// with them too, which is close enough for laying out an invoice.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// winAnsi maps the characters outside Latin-1 that WinAnsiEncoding has
+var winAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e, '‘': 0x91,
+	'’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98,
+	'™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// pdfEncode encodes text in WinAnsiEncoding, replacing characters it lacks
+func pdfEncode(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r >= ' ' && r <= '~', r >= 0xa0 && r <= 0xff:
+			encoded = append(encoded, byte(r))
+		case winAnsi[r] != 0:
+			encoded = append(encoded, winAnsi[r])
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// pdfTextWidth returns the width of text in points
+func pdfTextWidth(s string, size float64) float64 {
+	var width int
+	for _, c := range pdfEncode(s) {
+		if c >= ' ' && c <= '~' {
+			width += helveticaWidths[c-' ']
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// pdfLiteral returns text as a PDF literal string
+func pdfLiteral(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range pdfEncode(s) {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// wrapText splits text into lines no wider than width
+func wrapText(s string, size, width float64) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		candidate := word
+		if line != "" {
+			candidate = line + " " + word
+		}
+		if line != "" && pdfTextWidth(candidate, size) > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" || len(lines) == 0 {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfLayout lays text out on pages top to bottom
+type pdfLayout struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+func newPDFLayout() *pdfLayout {
+	l := &pdfLayout{}
+	l.newPage()
+	return l
+}
+
+// newPage starts a page with the cursor at its top margin
+func (l *pdfLayout) newPage() {
+	l.page = &bytes.Buffer{}
+	l.pages = append(l.pages, l.page)
+	l.y = pdfPageHeight - pdfMargin
+}
+
+// ensure starts a new page unless the given height fits on the current one,
+// and returns true when it did
+func (l *pdfLayout) ensure(height float64) bool {
+	if l.y-height < pdfMargin+20 {
+		l.newPage()
+		return true
+	}
+	return false
+}
+
+// advance moves the cursor down a line of the given height, starting a new
+// page when the line would not fit
+func (l *pdfLayout) advance(height float64) {
+	l.ensure(height)
+	l.y -= height
+}
+
+// text draws text with its left edge at x on the current line
+func (l *pdfLayout) text(font string, size, x float64, s string) {
+	fmt.Fprintf(l.page, "BT /%s %s Tf %s %s Td %s Tj ET\n",
+		font, pdfNumber(size), pdfNumber(x), pdfNumber(l.y), pdfLiteral(s))
+}
+
+// textRight draws text with its right edge at right on the current line
+func (l *pdfLayout) textRight(font string, size, right float64, s string) {
+	l.text(font, size, right-pdfTextWidth(s, size), s)
+}
+
+// rule draws a horizontal line just below the current line
+func (l *pdfLayout) rule(width float64) {
+	y := pdfNumber(l.y - 4)
+	fmt.Fprintf(l.page, "%s w %d %s m %d %s l S\n", pdfNumber(width), pdfMargin, y, pdfAmountRight, y)
+}
+
+// content returns the content stream of every page, numbered at the foot
+func (l *pdfLayout) content() [][]byte {
+	content := make([][]byte, len(l.pages))
+	for i, page := range l.pages {
+		if len(l.pages) > 1 {
+			l.page, l.y = page, pdfMargin-10
+			l.textRight(pdfFontRegular, 8, pdfAmountRight, fmt.Sprintf("Page %d of %d", i+1, len(l.pages)))
+		}
+		content[i] = page.Bytes()
+	}
+	return content
+}
+
+// pdfNumber formats a number for a content stream
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// layoutInvoice lays an invoice out on pages
+func layoutInvoice(invoice *Invoice) [][]byte {
+	l := newPDFLayout()
+
+	l.advance(20)
+	l.text(pdfFontBold, 20, pdfMargin, "Invoice")
+	l.textRight(pdfFontBold, 12, pdfAmountRight, invoice.Number)
+
+	l.advance(16)
+	if invoice.IssuerName != "" {
+		l.advance(14)
+		l.text(pdfFontBold, 11, pdfMargin, invoice.IssuerName)
+	}
+	for _, line := range invoice.IssuerAddress {
+		l.advance(13)
+		l.text(pdfFontRegular, 10, pdfMargin, line)
+	}
+
+	l.advance(24)
+	l.text(pdfFontBold, 10, pdfMargin, "Bill to")
+	customer := []string{}
+	if invoice.Customer.Name != "" {
+		customer = append(customer, invoice.Customer.Name)
+	}
+	customer = append(customer, "API key "+invoice.Customer.APIKeyID.String())
+	if invoice.Customer.Tier != "" {
+		customer = append(customer, "Tier: "+string(invoice.Customer.Tier))
+	}
+	for _, line := range customer {
+		l.advance(13)
+		l.text(pdfFontRegular, 10, pdfMargin, line)
+	}
+
+	l.advance(10)
+	details := [][2]string{
+		{"Issued", formatDate(invoice.IssuedAt)},
+		{"Due", formatDate(invoice.DueAt)},
+		{"Period", formatDate(invoice.PeriodStart) + " to " + formatDate(invoice.LastDay())},
+		{"Status", string(invoice.PaymentStatus)},
+	}
+	for _, detail := range details {
+		l.advance(13)
+		l.text(pdfFontBold, 10, pdfMargin, detail[0])
+		l.text(pdfFontRegular, 10, pdfMargin+60, detail[1])
+	}
+
+	header := func() {
+		l.advance(16)
+		l.text(pdfFontBold, 10, pdfMargin, "Description")
+		l.textRight(pdfFontBold, 10, pdfQuantityRight, "Quantity")
+		l.textRight(pdfFontBold, 10, pdfAmountRight, "Amount")
+		l.rule(0.8)
+		l.advance(4)
+	}
+	l.advance(14)
+	header()
+
+	for _, item := range invoice.LineItems {
+		// Items are not split across pages; a new page repeats the header
+		lines := wrapText(item.Description, 10, pdfDescriptionWidth)
+		if l.ensure(float64(14*len(lines) + 4)) {
+			header()
+		}
+		for i, line := range lines {
+			l.advance(14)
+			l.text(pdfFontRegular, 10, pdfMargin, line)
+			if i == 0 {
+				l.textRight(pdfFontRegular, 10, pdfQuantityRight, item.Quantity)
+				l.textRight(pdfFontRegular, 10, pdfAmountRight, FormatAmount(item.Amount, invoice.Currency))
+			}
+		}
+		l.rule(0.3)
+		l.advance(4)
+	}
+
+	l.advance(18)
+	l.text(pdfFontBold, 11, pdfMargin, "Total")
+	l.textRight(pdfFontBold, 11, pdfAmountRight, FormatAmount(invoice.Total, invoice.Currency))
+
+	if invoice.IsPaid() {
+		l.advance(20)
+		l.text(pdfFontBold, 10, pdfMargin, "Paid on "+formatDate(*invoice.PaidAt))
+	}
+
+	return l.content()
+}
+
+// writePDF writes a PDF document of pages with the given content streams.
+// Pages use Helvetica and Helvetica-Bold, which every PDF reader provides, so
+// no fonts are embedded.
+func writePDF(w io.Writer, title string, pages [][]byte) error {
+	var doc bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, doc.Len())
+		fmt.Fprintf(&doc, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 5 are fixed; each page adds a page object and its contents
+	const firstPage = 6
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+
+	doc.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (viva-rate-limiter) >>", pdfLiteral(title)))
+
+	for i, content := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, pdfFontRegular, pdfFontBold, firstPage+2*i+1))
+
+		var compressed bytes.Buffer
+		zw := zlib.NewWriter(&compressed)
+		if _, err := zw.Write(content); err != nil {
+			return fmt.Errorf("failed to compress pdf page: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("failed to compress pdf page: %w", err)
+		}
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream",
+			compressed.Len(), compressed.Bytes()))
+	}
+
+	xref := doc.Len()
+	fmt.Fprintf(&doc, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&doc, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&doc, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	if _, err := w.Write(doc.Bytes()); err != nil {
+		return fmt.Errorf("failed to write pdf: %w", err)
+	}
+	return nil
+}
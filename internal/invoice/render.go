@@ -0,0 +1,180 @@
+package invoice
+
+import (
+	"archive/zip"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+)
+
+// DefaultHTMLTemplate is the built-in HTML invoice template. Templates execute
+// on the *Invoice and may use the functions money (amount and currency) and
+// date (the day in UTC).
+const DefaultHTMLTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Invoice {{.Number}}</title>
+<style>
+body { font-family: Helvetica, Arial, sans-serif; color: #222; margin: 40px; }
+h1 { margin: 0 0 24px; }
+.parties { display: flex; justify-content: space-between; margin-bottom: 24px; }
+.details td { padding: 2px 16px 2px 0; }
+table.items { width: 100%; border-collapse: collapse; margin-top: 24px; }
+table.items th, table.items td { padding: 6px 4px; border-bottom: 1px solid #ddd; text-align: left; }
+table.items .number { text-align: right; white-space: nowrap; }
+table.items tfoot td { font-weight: bold; border-bottom: none; }
+.paid { color: #2a7d2a; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Invoice {{.Number}}</h1>
+<div class="parties">
+  <div>
+    {{- with .IssuerName}}<strong>{{.}}</strong><br>{{end}}
+    {{- range .IssuerAddress}}{{.}}<br>{{end}}
+  </div>
+  <div>
+    <strong>Bill to</strong><br>
+    {{with .Customer.Name}}{{.}}<br>{{end}}
+    API key {{.Customer.APIKeyID}}<br>
+    {{with .Customer.Tier}}Tier: {{.}}{{end}}
+  </div>
+</div>
+<table class="details">
+  <tr><td>Issued</td><td>{{date .IssuedAt}}</td></tr>
+  <tr><td>Due</td><td>{{date .DueAt}}</td></tr>
+  <tr><td>Period</td><td>{{date .PeriodStart}} to {{date .LastDay}}</td></tr>
+  <tr><td>Status</td><td>{{.PaymentStatus}}</td></tr>
+</table>
+<table class="items">
+  <thead>
+    <tr><th>Description</th><th class="number">Quantity</th><th class="number">Amount</th></tr>
+  </thead>
+  <tbody>
+  {{- range .LineItems}}
+    <tr><td>{{.Description}}</td><td class="number">{{.Quantity}}</td><td class="number">{{money .Amount $.Currency}}</td></tr>
+  {{- end}}
+  </tbody>
+  <tfoot>
+    <tr><td colspan="2">Total</td><td class="number">{{money .Total .Currency}}</td></tr>
+  </tfoot>
+</table>
+{{- if .IsPaid}}
+<p class="paid">Paid on {{date .PaidAt}}</p>
+{{- end}}
+</body>
+</html>
+`
+
+var htmlFuncs = template.FuncMap{
+	"money": FormatAmount,
+	"date": func(t interface{}) string {
+		switch t := t.(type) {
+		case *time.Time:
+			if t == nil {
+				return ""
+			}
+			return formatDate(*t)
+		case time.Time:
+			return formatDate(t)
+		}
+		return fmt.Sprint(t)
+	},
+}
+
+// Renderer renders invoices
+type Renderer struct {
+	html *template.Template
+}
+
+// NewRenderer parses an HTML invoice template. An empty template falls back
+// to DefaultHTMLTemplate.
+func NewRenderer(htmlTemplate string) (*Renderer, error) {
+	if htmlTemplate == "" {
+		htmlTemplate = DefaultHTMLTemplate
+	}
+
+	html, err := template.New("invoice").Funcs(htmlFuncs).Parse(htmlTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid invoice template: %w", err)
+	}
+	return &Renderer{html: html}, nil
+}
+
+// RendererFromConfig builds a renderer using the configured template file
+func RendererFromConfig(cfg *config.InvoiceConfig) (*Renderer, error) {
+	if cfg.TemplateFile == "" {
+		return NewRenderer("")
+	}
+
+	source, err := os.ReadFile(cfg.TemplateFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read invoice template: %w", err)
+	}
+	return NewRenderer(string(source))
+}
+
+// Render writes an invoice in a format
+func (r *Renderer) Render(w io.Writer, invoice *Invoice, format Format) error {
+	switch format {
+	case FormatHTML:
+		return r.RenderHTML(w, invoice)
+	case FormatPDF:
+		return r.RenderPDF(w, invoice)
+	}
+	return fmt.Errorf("unsupported invoice format: %s", format)
+}
+
+// RenderHTML writes an invoice as HTML from the renderer's template
+func (r *Renderer) RenderHTML(w io.Writer, invoice *Invoice) error {
+	if err := r.html.Execute(w, invoice); err != nil {
+		return fmt.Errorf("failed to render invoice: %w", err)
+	}
+	return nil
+}
+
+// RenderPDF writes an invoice as a PDF document
+func (r *Renderer) RenderPDF(w io.Writer, invoice *Invoice) error {
+	return writePDF(w, "Invoice "+invoice.Number, layoutInvoice(invoice))
+}
+
+// WriteArchive writes invoices in a format into a ZIP archive, one file per
+// invoice named after its number
+func (r *Renderer) WriteArchive(w io.Writer, invoices []*Invoice, format Format) error {
+	if !format.IsValid() {
+		return fmt.Errorf("unsupported invoice format: %s", format)
+	}
+
+	archive := zip.NewWriter(w)
+	names := make(map[string]bool, len(invoices))
+	for _, invoice := range invoices {
+		// Numbers only carry the start of the record ID, so they may collide
+		name := invoice.Number
+		if names[name] {
+			name += "-" + invoice.RecordID.String()
+		}
+		names[name] = true
+
+		file, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     name + "." + string(format),
+			Method:   zip.Deflate,
+			Modified: invoice.IssuedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to add invoice %s to archive: %w", invoice.Number, err)
+		}
+		if err := r.Render(file, invoice, format); err != nil {
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write invoice archive: %w", err)
+	}
+	return nil
+}
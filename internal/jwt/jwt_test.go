@@ -0,0 +1,96 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testNow = time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+
+func testClaims() *Claims {
+	return &Claims{
+		ID:        "7f1c1a52-58f4-4a8e-9d8b-2f61b1a0c001",
+		Subject:   "0d5a4f7e-1c2b-4a3d-8e9f-000000000001",
+		IssuedAt:  testNow.Unix(),
+		ExpiresAt: testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	token, err := Sign("s3cret", testClaims())
+	require.NoError(t, err)
+	assert.Len(t, strings.Split(token, "."), 3)
+
+	claims, err := Verify("s3cret", token, testNow)
+	require.NoError(t, err)
+	assert.Equal(t, testClaims(), claims)
+
+	_, err = Verify("other", token, testNow)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyKnownToken(t *testing.T) {
+	// Signed independently of this package
+	token := "eyJhbGciOiJIUzI1NiIsInR5cCI6IkpXVCJ9." +
+		"eyJzdWIiOiJrZXktMSIsImlhdCI6MTc3MjM3MzYwMCwiZXhwIjoxNzcyMzc3MjAwfQ." +
+		"B95OYOrEyCS0hlJt8rvMfJO4jmt9vTdfSSF7WzRfkIw"
+
+	claims, err := Verify("s3cret", token, testNow)
+	require.NoError(t, err)
+	assert.Equal(t, "key-1", claims.Subject)
+	assert.Equal(t, testNow.Add(time.Hour).Unix(), claims.ExpiresAt)
+}
+
+func TestVerifyExpiry(t *testing.T) {
+	token, err := Sign("s3cret", testClaims())
+	require.NoError(t, err)
+
+	_, err = Verify("s3cret", token, testNow.Add(59*time.Minute))
+	assert.NoError(t, err)
+
+	_, err = Verify("s3cret", token, testNow.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrExpiredToken)
+
+	// Tokens must expire
+	claims := testClaims()
+	claims.ExpiresAt = 0
+	token, err = Sign("s3cret", claims)
+	require.NoError(t, err)
+	_, err = Verify("s3cret", token, testNow)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+}
+
+func TestVerifyRejectsTamperedTokens(t *testing.T) {
+	token, err := Sign("s3cret", testClaims())
+	require.NoError(t, err)
+	parts := strings.Split(token, ".")
+
+	otherSubject := testClaims()
+	otherSubject.Subject = "0d5a4f7e-1c2b-4a3d-8e9f-000000000002"
+	forged, err := Sign("other", otherSubject)
+	require.NoError(t, err)
+	forgedParts := strings.Split(forged, ".")
+
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`))
+
+	tests := map[string]string{
+		"empty":             "",
+		"two segments":      parts[0] + "." + parts[1],
+		"swapped payload":   parts[0] + "." + forgedParts[1] + "." + parts[2],
+		"missing signature": parts[0] + "." + parts[1] + ".",
+		"alg none":          unsigned + "." + parts[1] + ".",
+		"bad encoding":      parts[0] + "." + parts[1] + ".!!!",
+	}
+
+	for name, token := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Verify("s3cret", token, testNow)
+			assert.ErrorIs(t, err, ErrInvalidToken)
+		})
+	}
+}
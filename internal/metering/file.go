@@ -0,0 +1,81 @@
+package metering
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileSink writes each batch as a JSON lines file, one event per line, under
+// a directory per day: <directory>/<YYYY-MM-DD>/metering-<YYYYMMDD>T<HH>-<batch ID>.jsonl,
+// named after the hour of the batch's first event. Files are written to a
+// temporary name and renamed into place, so a resent batch replaces its file
+// rather than duplicating it.
+type FileSink struct {
+	name      string
+	directory string
+}
+
+// NewFileSink creates a file sink writing under directory
+func NewFileSink(name, directory string) *FileSink {
+	return &FileSink{
+		name:      name,
+		directory: directory,
+	}
+}
+
+// Name returns the configured sink name
+func (s *FileSink) Name() string {
+	return s.name
+}
+
+// Send writes a batch to its file
+func (s *FileSink) Send(ctx context.Context, batch *Batch) error {
+	if len(batch.Events) == 0 {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	hour := batch.Events[0].PeriodStart.UTC()
+	dir := filepath.Join(s.directory, hour.Format("2006-01-02"))
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create metering directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("metering-%s-%s.jsonl", hour.Format("20060102T15"), batch.ID))
+	tmp, err := os.CreateTemp(dir, ".metering-*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create metering file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, event := range batch.Events {
+		if err := encoder.Encode(event); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write metering file: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metering file: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to sync metering file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close metering file: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to move metering file into place: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Kafka REST proxy v2 content types
+const (
+	kafkaJSONContentType = "application/vnd.kafka.json.v2+json"
+	kafkaAcceptType      = "application/vnd.kafka.v2+json"
+)
+
+// KafkaSink produces events to a Kafka topic through a Kafka REST proxy,
+// such as the Confluent REST Proxy or the Redpanda HTTP proxy. Each event is
+// one message keyed by its API key ID, so the events of a key stay in order
+// on one partition.
+type KafkaSink struct {
+	name    string
+	baseURL string
+	topic   string
+	client  *http.Client
+}
+
+// NewKafkaSink creates a sink producing to topic through the REST proxy at
+// baseURL
+func NewKafkaSink(name, baseURL, topic string, timeout time.Duration) *KafkaSink {
+	return &KafkaSink{
+		name:    name,
+		baseURL: strings.TrimRight(baseURL, "/"),
+		topic:   topic,
+		client:  newHTTPClient(timeout),
+	}
+}
+
+// Name returns the configured sink name
+func (s *KafkaSink) Name() string {
+	return s.name
+}
+
+// kafkaRecord is a message of a REST proxy produce request
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value *Event `json:"value"`
+}
+
+// kafkaOffset is the outcome of producing one message
+type kafkaOffset struct {
+	Partition int     `json:"partition"`
+	Offset    int64   `json:"offset"`
+	ErrorCode *int    `json:"error_code"`
+	Error     *string `json:"error"`
+}
+
+// Send produces every event of a batch to the topic
+func (s *KafkaSink) Send(ctx context.Context, batch *Batch) error {
+	records := make([]kafkaRecord, len(batch.Events))
+	for i, event := range batch.Events {
+		records[i] = kafkaRecord{Key: event.APIKeyID.String(), Value: event}
+	}
+	body, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	respBody, err := post(ctx, s.client, s.baseURL+"/topics/"+url.PathEscape(s.topic), body, map[string]string{
+		"Content-Type": kafkaJSONContentType,
+		"Accept":       kafkaAcceptType,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to produce to %s: %w", s.topic, err)
+	}
+
+	var resp struct {
+		Offsets []kafkaOffset `json:"offsets"`
+	}
+	if err := json.Unmarshal(respBody, &resp); err != nil {
+		return fmt.Errorf("failed to decode produce response: %w", err)
+	}
+	for _, offset := range resp.Offsets {
+		if offset.Error != nil || offset.ErrorCode != nil {
+			message := "unknown error"
+			if offset.Error != nil {
+				message = *offset.Error
+			}
+			return fmt.Errorf("failed to produce to %s: %s", s.topic, message)
+		}
+	}
+	return nil
+}
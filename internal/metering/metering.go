@@ -0,0 +1,182 @@
+// Package metering exports hourly usage records to external billing systems.
+// Records are signed so receivers can check where they came from, and carry
+// stable IDs so receivers can drop the duplicates a replay or retry delivers.
+// Sinks deliver batches to an HTTP webhook, a Kafka topic through the Kafka
+// REST proxy, or JSON lines files.
+package metering
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// defaultTimeout bounds a single delivery when none is configured
+const defaultTimeout = 10 * time.Second
+
+// Event is a metering record as delivered to sinks. Signature is the hex
+// HMAC-SHA256 of "<id>|<api_key_id>|<metric>|<quantity>|<period_start>|<period_end>",
+// with both times in RFC 3339 UTC.
+type Event struct {
+	ID          string    `json:"id"`
+	APIKeyID    uuid.UUID `json:"api_key_id"`
+	Metric      string    `json:"metric"`
+	Quantity    int64     `json:"quantity"`
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	Signature   string    `json:"signature"`
+}
+
+// NewEvent converts a metering record to a signed event
+func NewEvent(record *models.MeteringRecord, secret string) *Event {
+	event := &Event{
+		ID:          record.ID,
+		APIKeyID:    record.APIKeyID,
+		Metric:      string(record.Metric),
+		Quantity:    record.Quantity,
+		PeriodStart: record.PeriodStart.UTC(),
+		PeriodEnd:   record.PeriodEnd.UTC(),
+	}
+	event.Signature = SignEvent(secret, event)
+	return event
+}
+
+// SignEvent returns the signature of an event
+func SignEvent(secret string, event *Event) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{
+		event.ID,
+		event.APIKeyID.String(),
+		event.Metric,
+		strconv.FormatInt(event.Quantity, 10),
+		event.PeriodStart.UTC().Format(time.RFC3339),
+		event.PeriodEnd.UTC().Format(time.RFC3339),
+	}, "|")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyEvent returns true if an event carries a valid signature
+func VerifyEvent(secret string, event *Event) bool {
+	return hmac.Equal([]byte(SignEvent(secret, event)), []byte(event.Signature))
+}
+
+// Batch is a group of events delivered together. Its ID is derived from the
+// IDs of its events, so redelivering the same batch repeats the ID.
+type Batch struct {
+	ID     string   `json:"batch_id"`
+	Events []*Event `json:"records"`
+}
+
+// NewBatch groups events into a batch
+func NewBatch(events []*Event) *Batch {
+	hash := sha256.New()
+	for _, event := range events {
+		hash.Write([]byte(event.ID))
+		hash.Write([]byte{'\n'})
+	}
+	return &Batch{
+		ID:     "mb_" + hex.EncodeToString(hash.Sum(nil)[:16]),
+		Events: events,
+	}
+}
+
+// Sink delivers metering batches to one destination
+type Sink interface {
+	// Name identifies the sink in checkpoints and reports
+	Name() string
+	// Send delivers a batch. A batch may be sent again after a failure or a
+	// replay.
+	Send(ctx context.Context, batch *Batch) error
+}
+
+// SinksFromConfig builds every configured sink
+func SinksFromConfig(cfg *config.MeteringConfig) ([]Sink, error) {
+	var sinks []Sink
+	seen := make(map[string]bool)
+	for _, sinkCfg := range cfg.Sinks {
+		name := sinkCfg.Name
+		if name == "" {
+			return nil, fmt.Errorf("metering sinks need a name")
+		}
+		if name == models.MeteringGeneratedCheckpoint {
+			return nil, fmt.Errorf("metering sink name %q is reserved", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate metering sink: %s", name)
+		}
+		seen[name] = true
+
+		switch sinkCfg.Type {
+		case "webhook":
+			if sinkCfg.URL == "" {
+				return nil, fmt.Errorf("metering sink %s: url is required for webhooks", name)
+			}
+			sinks = append(sinks, NewWebhookSink(name, sinkCfg.URL, cfg.SigningSecret, cfg.Timeout))
+		case "kafka":
+			if sinkCfg.URL == "" || sinkCfg.Topic == "" {
+				return nil, fmt.Errorf("metering sink %s: url and topic are required for kafka", name)
+			}
+			sinks = append(sinks, NewKafkaSink(name, sinkCfg.URL, sinkCfg.Topic, cfg.Timeout))
+		case "file":
+			if sinkCfg.Directory == "" {
+				return nil, fmt.Errorf("metering sink %s: directory is required for files", name)
+			}
+			sinks = append(sinks, NewFileSink(name, sinkCfg.Directory))
+		default:
+			return nil, fmt.Errorf("metering sink %s: unknown type: %s", name, sinkCfg.Type)
+		}
+	}
+	return sinks, nil
+}
+
+// newHTTPClient returns a client whose requests time out after timeout
+func newHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// post posts a body to url and returns the response body. Responses other
+// than 2xx are errors.
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		detail := respBody
+		if len(detail) > 512 {
+			detail = detail[:512]
+		}
+		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return respBody, nil
+}
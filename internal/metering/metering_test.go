@@ -0,0 +1,226 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+)
+
+var testHour = time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC)
+
+func testRecord(apiKeyID uuid.UUID, metric models.MeteringMetric, quantity int64) *models.MeteringRecord {
+	return &models.MeteringRecord{
+		ID:          models.MeteringRecordID(apiKeyID, metric, testHour),
+		APIKeyID:    apiKeyID,
+		Metric:      metric,
+		Quantity:    quantity,
+		PeriodStart: testHour,
+		PeriodEnd:   testHour.Add(time.Hour),
+	}
+}
+
+func testBatch() *Batch {
+	apiKeyID := uuid.MustParse("0d5a4f7e-1c2b-4a3d-8e9f-000000000001")
+	return NewBatch([]*Event{
+		NewEvent(testRecord(apiKeyID, models.MeteringMetricRequests, 120), "s3cret"),
+		NewEvent(testRecord(apiKeyID, models.MeteringMetricBandwidth, 4096), "s3cret"),
+	})
+}
+
+func TestEventSignature(t *testing.T) {
+	event := NewEvent(testRecord(uuid.New(), models.MeteringMetricRequests, 120), "s3cret")
+
+	assert.True(t, VerifyEvent("s3cret", event))
+	assert.False(t, VerifyEvent("other", event))
+
+	tampered := *event
+	tampered.Quantity = 1
+	assert.False(t, VerifyEvent("s3cret", &tampered))
+}
+
+func TestBatchIDIsStable(t *testing.T) {
+	first := testBatch()
+	again := testBatch()
+	assert.Equal(t, first.ID, again.ID)
+	assert.True(t, strings.HasPrefix(first.ID, "mb_"))
+
+	other := NewBatch(first.Events[:1])
+	assert.NotEqual(t, first.ID, other.ID)
+}
+
+func TestWebhookSink_SignsBatch(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink("billing", server.URL, "s3cret", time.Second)
+	now := time.Date(2026, 3, 1, 15, 5, 0, 0, time.UTC)
+	sink.now = func() time.Time { return now }
+
+	batch := testBatch()
+	require.NoError(t, sink.Send(context.Background(), batch))
+
+	assert.Equal(t, WebhookEvent, header.Get(notify.WebhookEventHeader))
+	assert.Equal(t, batch.ID, header.Get(notify.WebhookDeliveryHeader))
+	assert.Equal(t, batch.ID, header.Get("Idempotency-Key"))
+	expected := "t=" + strconv.FormatInt(now.Unix(), 10) + ",v1=" + notify.SignWebhook("s3cret", now.Unix(), body)
+	assert.Equal(t, expected, header.Get(notify.WebhookSignatureHeader))
+
+	var received Batch
+	require.NoError(t, json.Unmarshal(body, &received))
+	assert.Equal(t, batch.ID, received.ID)
+	require.Len(t, received.Events, 2)
+	assert.True(t, VerifyEvent("s3cret", received.Events[0]))
+}
+
+func TestWebhookSink_ErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := NewWebhookSink("billing", server.URL, "", time.Second).Send(context.Background(), testBatch())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status 503")
+}
+
+func TestKafkaSink_ProducesKeyedRecords(t *testing.T) {
+	var path, contentType string
+	var produced struct {
+		Records []struct {
+			Key   string `json:"key"`
+			Value *Event `json:"value"`
+		} `json:"records"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		contentType = r.Header.Get("Content-Type")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&produced))
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":10},{"partition":0,"offset":11}]}`))
+	}))
+	defer server.Close()
+
+	batch := testBatch()
+	require.NoError(t, NewKafkaSink("kafka", server.URL+"/", "usage-metering", time.Second).Send(context.Background(), batch))
+
+	assert.Equal(t, "/topics/usage-metering", path)
+	assert.Equal(t, kafkaJSONContentType, contentType)
+	require.Len(t, produced.Records, 2)
+	assert.Equal(t, batch.Events[0].APIKeyID.String(), produced.Records[0].Key)
+	assert.Equal(t, batch.Events[0].ID, produced.Records[0].Value.ID)
+}
+
+func TestKafkaSink_OffsetError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"offsets":[{"partition":0,"offset":10},{"error_code":50003,"error":"leader not available"}]}`))
+	}))
+	defer server.Close()
+
+	err := NewKafkaSink("kafka", server.URL, "usage-metering", time.Second).Send(context.Background(), testBatch())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "leader not available")
+}
+
+func TestFileSink_ResendReplacesFile(t *testing.T) {
+	dir := t.TempDir()
+	sink := NewFileSink("files", dir)
+	batch := testBatch()
+
+	require.NoError(t, sink.Send(context.Background(), batch))
+	require.NoError(t, sink.Send(context.Background(), batch))
+
+	files, err := filepath.Glob(filepath.Join(dir, "2026-03-01", "*"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "metering-20260301T14-"+batch.ID+".jsonl", filepath.Base(files[0]))
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+	var event Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(t, batch.Events[1].ID, event.ID)
+}
+
+func TestSinksFromConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		sinks   []config.MeteringSinkConfig
+		wantErr string
+	}{
+		{
+			name: "all types",
+			sinks: []config.MeteringSinkConfig{
+				{Name: "billing", Type: "webhook", URL: "http://billing.local/metering"},
+				{Name: "kafka", Type: "kafka", URL: "http://localhost:8082", Topic: "usage-metering"},
+				{Name: "files", Type: "file", Directory: "/tmp/metering"},
+			},
+		},
+		{
+			name:    "missing name",
+			sinks:   []config.MeteringSinkConfig{{Type: "file", Directory: "/tmp/metering"}},
+			wantErr: "need a name",
+		},
+		{
+			name:    "reserved name",
+			sinks:   []config.MeteringSinkConfig{{Name: models.MeteringGeneratedCheckpoint, Type: "file", Directory: "/tmp/metering"}},
+			wantErr: "reserved",
+		},
+		{
+			name: "duplicate name",
+			sinks: []config.MeteringSinkConfig{
+				{Name: "files", Type: "file", Directory: "/tmp/a"},
+				{Name: "files", Type: "file", Directory: "/tmp/b"},
+			},
+			wantErr: "duplicate metering sink",
+		},
+		{
+			name:    "kafka without topic",
+			sinks:   []config.MeteringSinkConfig{{Name: "kafka", Type: "kafka", URL: "http://localhost:8082"}},
+			wantErr: "url and topic are required",
+		},
+		{
+			name:    "unknown type",
+			sinks:   []config.MeteringSinkConfig{{Name: "s3", Type: "s3"}},
+			wantErr: "unknown type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sinks, err := SinksFromConfig(&config.MeteringConfig{Sinks: tt.sinks})
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, sinks, len(tt.sinks))
+			for i, sink := range sinks {
+				assert.Equal(t, tt.sinks[i].Name, sink.Name())
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package metering
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+)
+
+// WebhookEvent is the X-Viva-Event header of metering webhooks
+const WebhookEvent = "metering.records"
+
+// WebhookSink posts batches as JSON to a URL. Requests are signed like alert
+// webhooks: the X-Viva-Signature header is "t=<unix time>,v1=<signature>",
+// where the signature is the hex HMAC-SHA256 of "<unix time>.<body>". The
+// batch ID is sent as X-Viva-Delivery and Idempotency-Key.
+type WebhookSink struct {
+	name   string
+	url    string
+	secret string
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhookSink creates a webhook sink
+func NewWebhookSink(name, url, secret string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		name:   name,
+		url:    url,
+		secret: secret,
+		client: newHTTPClient(timeout),
+		now:    time.Now,
+	}
+}
+
+// Name returns the configured sink name
+func (s *WebhookSink) Name() string {
+	return s.name
+}
+
+// Send posts a batch to the webhook URL
+func (s *WebhookSink) Send(ctx context.Context, batch *Batch) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to encode batch: %w", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type":               "application/json",
+		"Idempotency-Key":            batch.ID,
+		notify.WebhookEventHeader:    WebhookEvent,
+		notify.WebhookDeliveryHeader: batch.ID,
+	}
+	if s.secret != "" {
+		timestamp := s.now().Unix()
+		headers[notify.WebhookSignatureHeader] = fmt.Sprintf("t=%d,v1=%s", timestamp, notify.SignWebhook(s.secret, timestamp, body))
+	}
+
+	if _, err := post(ctx, s.client, s.url, body, headers); err != nil {
+		return fmt.Errorf("metering webhook delivery failed: %w", err)
+	}
+	return nil
+}
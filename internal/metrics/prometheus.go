@@ -41,6 +41,11 @@ type PrometheusMetrics struct {
 	BillingRecordsTotal      *prometheus.CounterVec
 	AlertsTriggeredTotal     *prometheus.CounterVec
 	UsageLogsProcessedTotal  prometheus.Counter
+
+	// Usage log buffer metrics
+	UsageLogQueueDepth       prometheus.Gauge
+	UsageLogsDroppedTotal    *prometheus.CounterVec
+	UsageLogFlushesTotal     *prometheus.CounterVec
 }
 
 // NewPrometheusMetrics creates and registers all Prometheus metrics
@@ -247,6 +252,34 @@ func NewPrometheusMetrics(namespace, subsystem string) *PrometheusMetrics {
 				Help:      "Total number of usage logs processed",
 			},
 		),
+
+		// Usage log buffer metrics
+		UsageLogQueueDepth: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "usage_log_queue_depth",
+				Help:      "Number of usage events waiting to be flushed",
+			},
+		),
+		UsageLogsDroppedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "usage_logs_dropped_total",
+				Help:      "Total number of usage events dropped before reaching storage",
+			},
+			[]string{"reason"},
+		),
+		UsageLogFlushesTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace: namespace,
+				Subsystem: subsystem,
+				Name:      "usage_log_flushes_total",
+				Help:      "Total number of usage log batches flushed",
+			},
+			[]string{"result"},
+		),
 	}
 }
 
@@ -346,4 +379,21 @@ func (m *PrometheusMetrics) RecordAlert(alertType, severity, apiKeyID string) {
 // RecordUsageLogProcessed records usage log processing
 func (m *PrometheusMetrics) RecordUsageLogProcessed() {
 	m.UsageLogsProcessedTotal.Inc()
-}
\ No newline at end of file
+}
+// SetUsageLogQueueDepth updates the number of buffered usage events
+func (m *PrometheusMetrics) SetUsageLogQueueDepth(depth float64) {
+	m.UsageLogQueueDepth.Set(depth)
+}
+
+// RecordUsageLogDropped records usage events dropped by the buffer
+func (m *PrometheusMetrics) RecordUsageLogDropped(reason string, count int) {
+	m.UsageLogsDroppedTotal.WithLabelValues(reason).Add(float64(count))
+}
+
+// RecordUsageLogFlush records a usage log batch flush
+func (m *PrometheusMetrics) RecordUsageLogFlush(result string, size int) {
+	m.UsageLogFlushesTotal.WithLabelValues(result).Inc()
+	if result == "success" {
+		m.UsageLogsProcessedTotal.Add(float64(size))
+	}
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// AdminAuthMiddleware creates a middleware that authenticates admin API
+// requests with a bearer token from the admin token endpoint. Like
+// RateLimitMiddleware it stores the caller's key as "api_key" and
+// "api_key_id", so LoadPrincipal and RequireScope work unchanged, but admin
+// requests are neither rate limited nor logged as usage.
+func AdminAuthMiddleware(adminAuth *services.AdminAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Missing token",
+				"message": "Authorization header is required",
+			})
+			c.Abort()
+			return
+		}
+
+		token, ok := strings.CutPrefix(authHeader, "Bearer ")
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Invalid authorization format",
+				"message": "Authorization header must be in format 'Bearer TOKEN'",
+			})
+			c.Abort()
+			return
+		}
+
+		apiKey, err := adminAuth.Authenticate(c.Request.Context(), token)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidAdminToken) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error":   "Invalid token",
+					"message": err.Error(),
+				})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Failed to authenticate",
+					"message": err.Error(),
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key", apiKey)
+		c.Set("api_key_id", apiKey.ID)
+		c.Next()
+	}
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/jwt"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// tokenKeyRepository finds a single API key by ID
+type tokenKeyRepository struct {
+	repositories.APIKeyRepository
+	apiKey *models.APIKey
+}
+
+func (r *tokenKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	if id != r.apiKey.ID {
+		return nil, fmt.Errorf("api key not found")
+	}
+	return r.apiKey, nil
+}
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	now := time.Now()
+	apiKey := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Scopes: []string{models.ScopeKeysRead}}
+	adminAuth, err := services.NewAdminAuthService(nil, &tokenKeyRepository{apiKey: apiKey}, "s3cret", time.Hour, ratelimit.NewFakeClock(now))
+	require.NoError(t, err)
+
+	sign := func(secret string, subject uuid.UUID, expiresAt time.Time) string {
+		token, err := jwt.Sign(secret, &jwt.Claims{Subject: subject.String(), IssuedAt: now.Unix(), ExpiresAt: expiresAt.Unix()})
+		require.NoError(t, err)
+		return token
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expected      int
+	}{
+		{"missing header", "", http.StatusUnauthorized},
+		{"not bearer", "Basic " + sign("s3cret", apiKey.ID, now.Add(time.Hour)), http.StatusUnauthorized},
+		{"api key", "Bearer viva_0123456789abcdef", http.StatusUnauthorized},
+		{"other secret", "Bearer " + sign("other", apiKey.ID, now.Add(time.Hour)), http.StatusUnauthorized},
+		{"expired", "Bearer " + sign("s3cret", apiKey.ID, now.Add(-time.Minute)), http.StatusUnauthorized},
+		{"unknown key", "Bearer " + sign("s3cret", uuid.New(), now.Add(time.Hour)), http.StatusUnauthorized},
+		{"valid", "Bearer " + sign("s3cret", apiKey.ID, now.Add(time.Hour)), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(AdminAuthMiddleware(adminAuth))
+
+			var authenticated *models.APIKey
+			router.GET("/keys", RequireScope(models.ScopeKeysRead), func(c *gin.Context) {
+				authenticated = c.MustGet("api_key").(*models.APIKey)
+				c.Status(http.StatusOK)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/keys", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusOK {
+				assert.Equal(t, apiKey, authenticated)
+			} else {
+				assert.Nil(t, authenticated)
+			}
+		})
+	}
+}
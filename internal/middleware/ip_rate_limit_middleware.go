@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// IPRateLimitMiddleware creates a middleware that limits requests per client
+// IP. It guards endpoints that take an API key as a credential, such as the
+// admin token endpoint, where RateLimitMiddleware does not apply and every
+// failed attempt would otherwise be free.
+func IPRateLimitMiddleware(limiter ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if limiter.Allow(c.Request.Context(), ip) {
+			c.Next()
+			return
+		}
+
+		response := gin.H{
+			"error":   "Rate limit exceeded",
+			"message": "Too many requests from this address. Please try again later.",
+		}
+		if info, err := limiter.Info(c.Request.Context(), ip); err == nil && info.RetryAfter > 0 {
+			retryAfter := int((info.RetryAfter + time.Second - 1) / time.Second)
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			response["retry_after"] = retryAfter
+		}
+		c.JSON(http.StatusTooManyRequests, response)
+		c.Abort()
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+func TestIPRateLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	clock := ratelimit.NewFakeClock(time.Now())
+	limiter := ratelimit.New(ratelimit.Options{
+		Backend:       ratelimit.NewMemoryBackendWithClock(clock, 0),
+		DefaultLimit:  2,
+		DefaultWindow: time.Minute,
+		Clock:         clock,
+	})
+	defer limiter.Close()
+
+	router := gin.New()
+	router.POST("/api/v1/auth/token", IPRateLimitMiddleware(limiter), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"token": "ok"})
+	})
+
+	issue := func(remoteAddr string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/api/v1/auth/token", nil)
+		req.RemoteAddr = remoteAddr
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, issue("192.0.2.1:1000").Code)
+	assert.Equal(t, http.StatusOK, issue("192.0.2.1:1001").Code)
+
+	w := issue("192.0.2.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "Rate limit exceeded")
+
+	// Other addresses have their own allowance
+	assert.Equal(t, http.StatusOK, issue("192.0.2.2:1000").Code)
+
+	clock.Advance(time.Minute + time.Second)
+	assert.Equal(t, http.StatusOK, issue("192.0.2.1:1003").Code)
+}
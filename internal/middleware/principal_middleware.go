@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// LoadPrincipal creates a middleware that resolves the user and organization
+// of the authenticated API key and stores them in the context as "principal".
+// It runs after RateLimitMiddleware; requests without a key pass through and
+// are rejected by the handlers that need a principal.
+func LoadPrincipal(authz *services.AuthorizationService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("api_key")
+		apiKey, ok := value.(*models.APIKey)
+		if !exists || !ok {
+			c.Next()
+			return
+		}
+
+		principal, err := authz.ResolvePrincipal(c.Request.Context(), apiKey)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to resolve caller",
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("principal", principal)
+		c.Next()
+	}
+}
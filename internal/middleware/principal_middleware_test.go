@@ -0,0 +1,64 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+func TestLoadPrincipal(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Keys without a user resolve without touching the repositories
+	authz := services.NewAuthorizationService(nil, nil)
+
+	tests := []struct {
+		name     string
+		apiKey   *models.APIKey
+		platform bool
+	}{
+		{"no api key", nil, false},
+		{"key without user", &models.APIKey{}, false},
+		{"operator key", &models.APIKey{Scopes: []string{models.ScopeAdmin}, IsOperator: true}, true},
+		{"admin scope without operator", &models.APIKey{Scopes: []string{models.ScopeAdmin}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.apiKey != nil {
+					c.Set("api_key", tt.apiKey)
+				}
+			})
+			router.Use(LoadPrincipal(authz))
+
+			var principal *services.Principal
+			router.GET("/keys", func(c *gin.Context) {
+				if value, exists := c.Get("principal"); exists {
+					principal = value.(*services.Principal)
+				}
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/keys", nil))
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			if tt.apiKey == nil {
+				assert.Nil(t, principal)
+				return
+			}
+			if assert.NotNil(t, principal) {
+				assert.Equal(t, tt.apiKey, principal.APIKey)
+				assert.Equal(t, tt.platform, principal.Platform)
+			}
+		})
+	}
+}
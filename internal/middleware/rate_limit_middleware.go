@@ -2,18 +2,26 @@ package middleware
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rdhawladar/viva-rate-limiter/internal/services"
 )
 
-// RateLimitMiddleware creates a middleware for rate limiting
+// RateLimitMiddleware creates a middleware for rate limiting.
+// Requests matching a deny rule are rejected and requests matching an allow rule
+// skip the rate limit check, though they are still charged to the key's prepaid
+// credits. accessControlService may be nil to disable both.
+// Usage is recorded through usageLogs, which may be nil to disable usage
+// logging, so logging never delays the response.
 func RateLimitMiddleware(
 	apiKeyService services.APIKeyService,
 	rateLimitService services.RateLimitService,
-	usageService services.UsageTrackingService,
+	usageLogs *services.UsageLogBuffer,
+	accessControlService services.AccessControlService,
 ) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		startTime := time.Now()
@@ -60,45 +68,47 @@ func RateLimitMiddleware(
 			return
 		}
 
-		// Check rate limit
-		rateLimitReq := &services.RateLimitRequest{
-			APIKeyID:  validatedKey.ID,
-			Endpoint:  c.Request.URL.Path,
-			Method:    c.Request.Method,
-			IPAddress: c.ClientIP(),
-			UserAgent: c.GetHeader("User-Agent"),
-			Country:   c.GetHeader("X-Country"), // Assuming you have geolocation middleware
-			Timestamp: time.Now(),
-		}
-
-		rateLimitResult, err := rateLimitService.CheckRateLimit(c.Request.Context(), rateLimitReq)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Rate limit check failed",
-				"message": err.Error(),
+		// Check allow and deny lists before the rate limit
+		exempt := false
+		if accessControlService != nil {
+			decision, err := accessControlService.CheckAccess(c.Request.Context(), &services.AccessCheckRequest{
+				APIKeyID:  validatedKey.ID,
+				IPAddress: c.ClientIP(),
+				UserAgent: c.GetHeader("User-Agent"),
+				Country:   c.GetHeader("X-Country"),
 			})
-			c.Abort()
-			return
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   "Access check failed",
+					"message": err.Error(),
+				})
+				c.Abort()
+				return
+			}
+
+			if decision.Denied {
+				response := gin.H{
+					"error":   "Access denied",
+					"message": decision.Rule.Reason,
+				}
+				if decision.RetryAfter > 0 {
+					c.Header("Retry-After", strconv.Itoa(decision.RetryAfter))
+					response["retry_after"] = decision.RetryAfter
+					response["banned_until"] = decision.Rule.ExpiresAt
+				}
+				c.JSON(http.StatusForbidden, response)
+				c.Abort()
+				return
+			}
+			exempt = decision.Exempt
 		}
 
-		// Set rate limit headers
-		c.Header("X-RateLimit-Limit", string(rune(rateLimitResult.Limit)))
-		c.Header("X-RateLimit-Remaining", string(rune(rateLimitResult.Remaining)))
-		c.Header("X-RateLimit-Reset", rateLimitResult.ResetTime.Format(time.RFC3339))
-
-		// Check if rate limit exceeded
-		if !rateLimitResult.Allowed {
-			c.Header("Retry-After", string(rune(rateLimitResult.RetryAfter)))
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":             "Rate limit exceeded",
-				"message":           "Too many requests. Please try again later.",
-				"limit":             rateLimitResult.Limit,
-				"remaining":         rateLimitResult.Remaining,
-				"reset_time":        rateLimitResult.ResetTime,
-				"retry_after":       rateLimitResult.RetryAfter,
-				"violation_recorded": rateLimitResult.ViolationRecorded,
-			})
-			c.Abort()
+		// Check rate limit unless the request is allowlisted
+		if exempt {
+			if !enforceCredits(c, rateLimitService, validatedKey.ID) {
+				return
+			}
+		} else if !enforceRateLimit(c, rateLimitService, validatedKey.ID) {
 			return
 		}
 
@@ -109,31 +119,121 @@ func RateLimitMiddleware(
 		// Continue to next handler
 		c.Next()
 
-		// Log usage after request completion
-		responseTime := int(time.Since(startTime).Milliseconds())
-		statusCode := c.Writer.Status()
-
-		// Log usage asynchronously
-		go func() {
-			usageReq := &services.UsageLogRequest{
-				APIKeyID:     validatedKey.ID,
-				Endpoint:     c.Request.URL.Path,
-				Method:       c.Request.Method,
-				StatusCode:   statusCode,
-				ResponseTime: responseTime,
-				RequestSize:  int(c.Request.ContentLength),
-				ResponseSize: c.Writer.Size(),
-				IPAddress:    c.ClientIP(),
-				UserAgent:    c.GetHeader("User-Agent"),
-				Country:      c.GetHeader("X-Country"),
-				Timestamp:    startTime,
-			}
+		// Queue usage for batched logging. The event is built here because the
+		// gin context is recycled once the handler chain returns.
+		if usageLogs == nil {
+			return
+		}
+		usageLogs.Enqueue(&services.UsageLogRequest{
+			APIKeyID:     validatedKey.ID,
+			Endpoint:     c.Request.URL.Path,
+			Method:       c.Request.Method,
+			StatusCode:   c.Writer.Status(),
+			ResponseTime: int(time.Since(startTime).Milliseconds()),
+			RequestSize:  int(c.Request.ContentLength),
+			ResponseSize: c.Writer.Size(),
+			IPAddress:    c.ClientIP(),
+			UserAgent:    c.GetHeader("User-Agent"),
+			Country:      c.GetHeader("X-Country"),
+			Timestamp:    startTime,
+		})
+	}
+}
+
+// enforceRateLimit checks the rate limit for a validated API key and sets the
+// rate limit headers. It aborts the request and returns false if the limit is
+// exceeded or cannot be checked.
+func enforceRateLimit(c *gin.Context, rateLimitService services.RateLimitService, apiKeyID uuid.UUID) bool {
+	rateLimitResult, err := rateLimitService.CheckRateLimit(c.Request.Context(), newRateLimitRequest(c, apiKeyID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Rate limit check failed",
+			"message": err.Error(),
+		})
+		c.Abort()
+		return false
+	}
 
-			if err := usageService.LogUsage(c.Request.Context(), usageReq); err != nil {
-				// Log error but don't fail the request
-				// In production, you'd want proper logging here
-				// logger.Error("Failed to log usage", zap.Error(err))
-			}
-		}()
+	// Set rate limit headers
+	c.Header("X-RateLimit-Limit", strconv.Itoa(rateLimitResult.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(rateLimitResult.Remaining))
+	c.Header("X-RateLimit-Reset", rateLimitResult.ResetTime.Format(time.RFC3339))
+	if !requireCredits(c, rateLimitResult) {
+		return false
+	}
+
+	// Check if rate limit exceeded
+	if !rateLimitResult.Allowed {
+		c.Header("Retry-After", strconv.Itoa(rateLimitResult.RetryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error":              "Rate limit exceeded",
+			"message":            "Too many requests. Please try again later.",
+			"limit":              rateLimitResult.Limit,
+			"remaining":          rateLimitResult.Remaining,
+			"reset_time":         rateLimitResult.ResetTime,
+			"retry_after":        rateLimitResult.RetryAfter,
+			"violation_recorded": rateLimitResult.ViolationRecorded,
+			"penalty_level":      rateLimitResult.PenaltyLevel,
+			"cooldown_until":     rateLimitResult.CooldownUntil,
+		})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// enforceCredits charges an allowlisted request to the key's prepaid credits.
+// It aborts the request and returns false if the credits cannot pay for it.
+func enforceCredits(c *gin.Context, rateLimitService services.RateLimitService, apiKeyID uuid.UUID) bool {
+	result, err := rateLimitService.ChargeCredits(c.Request.Context(), newRateLimitRequest(c, apiKeyID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Credit charge failed",
+			"message": err.Error(),
+		})
+		c.Abort()
+		return false
+	}
+
+	return requireCredits(c, result)
+}
+
+// requireCredits sets the credit balance header and rejects a request the
+// key's prepaid credits cannot pay for. It returns false if it aborted.
+func requireCredits(c *gin.Context, result *services.RateLimitResult) bool {
+	if result.CreditBalance != nil {
+		c.Header("X-Credits-Balance", strconv.FormatFloat(*result.CreditBalance, 'f', -1, 64))
+	}
+
+	// Requests the key's prepaid credits cannot pay for need payment, not a retry
+	if result.CreditsExhausted || result.SpendCapReached {
+		message := "Prepaid credits exhausted. Top up the credit account to continue."
+		if result.SpendCapReached {
+			message = "Monthly spend cap reached."
+		}
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"error":             "Payment required",
+			"message":           message,
+			"credit_balance":    result.CreditBalance,
+			"spend_cap_reached": result.SpendCapReached,
+		})
+		c.Abort()
+		return false
+	}
+
+	return true
+}
+
+// newRateLimitRequest describes the current request for a rate limit check
+func newRateLimitRequest(c *gin.Context, apiKeyID uuid.UUID) *services.RateLimitRequest {
+	return &services.RateLimitRequest{
+		APIKeyID:  apiKeyID,
+		Endpoint:  c.Request.URL.Path,
+		Method:    c.Request.Method,
+		IPAddress: c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Country:   c.GetHeader("X-Country"), // Assuming you have geolocation middleware
+		Timestamp: time.Now(),
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// staticAPIKeyService accepts any key as the same API key
+type staticAPIKeyService struct {
+	services.APIKeyService
+	apiKey *models.APIKey
+}
+
+func (s *staticAPIKeyService) ValidateAPIKey(ctx context.Context, key string) (*models.APIKey, error) {
+	return s.apiKey, nil
+}
+
+// allowlistingAccessControl exempts every request from rate limiting
+type allowlistingAccessControl struct {
+	services.AccessControlService
+}
+
+func (allowlistingAccessControl) CheckAccess(ctx context.Context, req *services.AccessCheckRequest) (*services.AccessDecision, error) {
+	return &services.AccessDecision{Exempt: true}, nil
+}
+
+// exhaustedCredits reports that no request can be paid for and records which
+// checks were made
+type exhaustedCredits struct {
+	services.RateLimitService
+	checked, charged int
+}
+
+func (s *exhaustedCredits) CheckRateLimit(ctx context.Context, req *services.RateLimitRequest) (*services.RateLimitResult, error) {
+	s.checked++
+	return &services.RateLimitResult{Allowed: true}, nil
+}
+
+func (s *exhaustedCredits) ChargeCredits(ctx context.Context, req *services.RateLimitRequest) (*services.RateLimitResult, error) {
+	s.charged++
+	balance := 0.0
+	return &services.RateLimitResult{CreditBalance: &balance, CreditsExhausted: true}, nil
+}
+
+func TestRateLimitMiddleware_ChargesAllowlistedRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	rateLimits := &exhaustedCredits{}
+	router := gin.New()
+	router.Use(RateLimitMiddleware(
+		&staticAPIKeyService{apiKey: &models.APIKey{ID: uuid.New()}},
+		rateLimits,
+		nil,
+		allowlistingAccessControl{},
+	))
+	router.GET("/test", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "test"})
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.Header.Set("Authorization", "Bearer vrl_test")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPaymentRequired, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Credits-Balance"))
+	assert.Equal(t, 0, rateLimits.checked, "allowlisted requests skip the rate limit")
+	assert.Equal(t, 1, rateLimits.charged)
+}
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// RequireScope creates a middleware that rejects requests whose API key was
+// not granted the scope. It runs after RateLimitMiddleware, which stores the
+// authenticated key in the context.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, exists := c.Get("api_key")
+		apiKey, ok := value.(*models.APIKey)
+		if !exists || !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error":   "Missing API key",
+				"message": "This endpoint requires an authenticated API key",
+			})
+			c.Abort()
+			return
+		}
+
+		if !apiKey.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":          "Insufficient scope",
+				"message":        "This endpoint requires the " + scope + " scope",
+				"required_scope": scope,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		apiKey   *models.APIKey
+		expected int
+	}{
+		{"no api key", nil, http.StatusUnauthorized},
+		{"no scopes", &models.APIKey{}, http.StatusForbidden},
+		{"other scope", &models.APIKey{Scopes: []string{models.ScopeAlertsWrite}}, http.StatusForbidden},
+		{"granted scope", &models.APIKey{Scopes: []string{models.ScopeAlertsRead}}, http.StatusOK},
+		{"admin scope", &models.APIKey{Scopes: []string{models.ScopeAdmin}, IsOperator: true}, http.StatusOK},
+		{"admin scope without operator", &models.APIKey{Scopes: []string{models.ScopeAdmin}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) {
+				if tt.apiKey != nil {
+					c.Set("api_key", tt.apiKey)
+				}
+			})
+			router.GET("/alerts", RequireScope(models.ScopeAlertsRead), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/alerts", nil))
+
+			assert.Equal(t, tt.expected, w.Code)
+			if tt.expected == http.StatusForbidden {
+				var body map[string]string
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, models.ScopeAlertsRead, body["required_scope"])
+			}
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// Package migrate applies the numbered SQL migrations in migrations/ and
+// records them in the schema_migrations table.
+package migrate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// lockKey identifies the advisory lock held while migrating, so replicas
+// starting at the same time apply each migration once
+const lockKey int64 = 7_262_105_318
+
+// Direction selects whether migrations are applied or reverted
+type Direction string
+
+const (
+	DirectionUp   Direction = "up"
+	DirectionDown Direction = "down"
+)
+
+// Migration is one numbered schema change with its up and down SQL
+type Migration struct {
+	Version  int64  `json:"version"`
+	Name     string `json:"name"`
+	Up       string `json:"-"`
+	Down     string `json:"-"`
+	Checksum string `json:"checksum"` // SHA-256 of the up SQL
+}
+
+// String returns the migration file prefix, e.g. 003_usage_rollups
+func (m *Migration) String() string {
+	return fmt.Sprintf("%03d_%s", m.Version, m.Name)
+}
+
+// SQL returns the statements run in the given direction
+func (m *Migration) SQL(direction Direction) string {
+	if direction == DirectionDown {
+		return m.Down
+	}
+	return m.Up
+}
+
+// AppliedMigration is a row of the schema_migrations table
+type AppliedMigration struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:255;not null"`
+	Checksum  string    `gorm:"size:64;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName returns the table name for AppliedMigration
+func (AppliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationStatus reports whether a migration has been applied
+type MigrationStatus struct {
+	*Migration
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Modified  bool       `json:"modified"` // file changed after it was applied
+}
+
+// createMigrationsTable creates the table recording applied migrations
+const createMigrationsTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+	version BIGINT PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	checksum VARCHAR(64) NOT NULL,
+	applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+)`
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads <version>_<name>.up.sql and .down.sql files from the root of
+// fsys and returns the migrations ordered by version. Every migration needs
+// both files.
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+
+		matches := fileNamePattern.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", entry.Name())
+		}
+
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version in %s: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: matches[2]}
+			byVersion[version] = migration
+		} else if migration.Name != matches[2] {
+			return nil, fmt.Errorf("duplicate migration version %d: %s and %s", version, migration.Name, matches[2])
+		}
+
+		if matches[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %s needs both an up and a down file", migration)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, migration)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies and reverts migrations against a database
+type Migrator struct {
+	db         *gorm.DB
+	migrations []*Migration
+}
+
+// New creates a new migrator for migrations ordered by version
+func New(db *gorm.DB, migrations []*Migration) *Migrator {
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}
+}
+
+// Apply loads the migrations in fsys and applies every pending one
+func Apply(ctx context.Context, db *gorm.DB, fsys fs.FS) ([]*Migration, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, migrations).Up(ctx, 0)
+}
+
+// Status reports every known migration and whether it has been applied
+func (m *Migrator) Status(ctx context.Context) ([]*MigrationStatus, error) {
+	var statuses []*MigrationStatus
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		applied, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			status := &MigrationStatus{Migration: migration}
+			if row, ok := applied[migration.Version]; ok {
+				appliedAt := row.AppliedAt
+				status.AppliedAt = &appliedAt
+				status.Modified = row.Checksum != migration.Checksum
+			}
+			statuses = append(statuses, status)
+		}
+		return nil
+	})
+	return statuses, err
+}
+
+// Plan returns the migrations Up or Down would run for steps without running
+// them or writing to the database. A steps value of zero means all pending migrations for up and one
+// migration for down.
+func (m *Migrator) Plan(ctx context.Context, direction Direction, steps int) ([]*Migration, error) {
+	var plan []*Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		var err error
+		plan, err = m.plan(conn, direction, steps)
+		return err
+	})
+	return plan, err
+}
+
+// Up applies pending migrations in version order, each in its own transaction
+func (m *Migrator) Up(ctx context.Context, steps int) ([]*Migration, error) {
+	return m.run(ctx, DirectionUp, steps)
+}
+
+// Down reverts the most recently applied migrations, newest first
+func (m *Migrator) Down(ctx context.Context, steps int) ([]*Migration, error) {
+	return m.run(ctx, DirectionDown, steps)
+}
+
+// Baseline records every migration up to version as applied without running
+// it, for databases whose schema was created before schema_migrations existed
+func (m *Migrator) Baseline(ctx context.Context, version int64) ([]*Migration, error) {
+	var recorded []*Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		if err := ensureMigrationsTable(conn); err != nil {
+			return err
+		}
+
+		applied, err := loadApplied(conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version > version {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := conn.Create(newAppliedMigration(migration)).Error; err != nil {
+				return fmt.Errorf("failed to record migration %s: %w", migration, err)
+			}
+			recorded = append(recorded, migration)
+		}
+		return nil
+	})
+	return recorded, err
+}
+
+// run executes the planned migrations in direction while holding the lock
+func (m *Migrator) run(ctx context.Context, direction Direction, steps int) ([]*Migration, error) {
+	var done []*Migration
+	err := m.withLock(ctx, func(conn *gorm.DB) error {
+		if err := ensureMigrationsTable(conn); err != nil {
+			return err
+		}
+
+		plan, err := m.plan(conn, direction, steps)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range plan {
+			err := conn.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Exec(migration.SQL(direction)).Error; err != nil {
+					return err
+				}
+				if direction == DirectionDown {
+					return tx.Delete(&AppliedMigration{}, migration.Version).Error
+				}
+				return tx.Create(newAppliedMigration(migration)).Error
+			})
+			if err != nil {
+				return fmt.Errorf("failed to migrate %s %s: %w", migration, direction, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// plan works out which migrations to run. Applied migrations whose file has
+// changed, or that have no file at all, stop the plan.
+func (m *Migrator) plan(conn *gorm.DB, direction Direction, steps int) ([]*Migration, error) {
+	applied, err := loadApplied(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]bool, len(m.migrations))
+	for _, migration := range m.migrations {
+		known[migration.Version] = true
+		if row, ok := applied[migration.Version]; ok && row.Checksum != migration.Checksum {
+			return nil, fmt.Errorf("migration %s was modified after it was applied", migration)
+		}
+	}
+	for version := range applied {
+		if !known[version] {
+			return nil, fmt.Errorf("applied migration %d has no migration file", version)
+		}
+	}
+
+	return planMigrations(m.migrations, applied, direction, steps), nil
+}
+
+// planMigrations selects the migrations to run given the applied versions
+func planMigrations(migrations []*Migration, applied map[int64]*AppliedMigration, direction Direction, steps int) []*Migration {
+	var plan []*Migration
+	if direction == DirectionDown {
+		if steps <= 0 {
+			steps = 1
+		}
+		for i := len(migrations) - 1; i >= 0 && len(plan) < steps; i-- {
+			if _, ok := applied[migrations[i].Version]; ok {
+				plan = append(plan, migrations[i])
+			}
+		}
+		return plan
+	}
+
+	for _, migration := range migrations {
+		if steps > 0 && len(plan) >= steps {
+			break
+		}
+		if _, ok := applied[migration.Version]; !ok {
+			plan = append(plan, migration)
+		}
+	}
+	return plan
+}
+
+// withLock runs fn on a single connection holding the migration advisory lock.
+// Waiting for the lock respects ctx, so a deadline bounds how long a replica
+// waits for another one to finish.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *gorm.DB) error) error {
+	return m.db.WithContext(ctx).Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("SELECT pg_advisory_lock(?)", lockKey).Error; err != nil {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+		defer conn.WithContext(context.Background()).Exec("SELECT pg_advisory_unlock(?)", lockKey)
+
+		return fn(conn)
+	})
+}
+
+// ensureMigrationsTable creates schema_migrations if it does not exist yet
+func ensureMigrationsTable(conn *gorm.DB) error {
+	if err := conn.Exec(createMigrationsTable).Error; err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+// loadApplied returns the applied migrations keyed by version. A database
+// without schema_migrations has none applied.
+func loadApplied(conn *gorm.DB) (map[int64]*AppliedMigration, error) {
+	if !conn.Migrator().HasTable(&AppliedMigration{}) {
+		return map[int64]*AppliedMigration{}, nil
+	}
+
+	var rows []*AppliedMigration
+	if err := conn.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+
+	applied := make(map[int64]*AppliedMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// newAppliedMigration builds the schema_migrations row for migration
+func newAppliedMigration(migration *Migration) *AppliedMigration {
+	return &AppliedMigration{
+		Version:   migration.Version,
+		Name:      migration.Name,
+		Checksum:  migration.Checksum,
+		AppliedAt: time.Now().UTC(),
+	}
+}
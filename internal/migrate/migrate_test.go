@@ -0,0 +1,106 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"002_second.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_second.down.sql": {Data: []byte("DROP TABLE b;")},
+		"001_first.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"001_first.down.sql":  {Data: []byte("DROP TABLE a;")},
+		"migrations.go":       {Data: []byte("package migrations")},
+	}
+
+	loaded, err := Load(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	assert.Equal(t, int64(1), loaded[0].Version)
+	assert.Equal(t, "first", loaded[0].Name)
+	assert.Equal(t, "001_first", loaded[0].String())
+	assert.Equal(t, "CREATE TABLE a (id INT);", loaded[0].SQL(DirectionUp))
+	assert.Equal(t, "DROP TABLE a;", loaded[0].SQL(DirectionDown))
+	assert.Len(t, loaded[0].Checksum, 64)
+	assert.Equal(t, int64(2), loaded[1].Version)
+}
+
+func TestLoadRejectsBrokenSets(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing down file",
+			fsys: fstest.MapFS{"001_first.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"001_first.up.sql":    {Data: []byte("SELECT 1;")},
+				"001_first.down.sql":  {Data: []byte("SELECT 1;")},
+				"001_second.up.sql":   {Data: []byte("SELECT 1;")},
+				"001_second.down.sql": {Data: []byte("SELECT 1;")},
+			},
+		},
+		{
+			name: "unnumbered file",
+			fsys: fstest.MapFS{"first.up.sql": {Data: []byte("SELECT 1;")}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestLoadEmbeddedMigrations(t *testing.T) {
+	loaded, err := Load(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, migration := range loaded {
+		assert.Equal(t, int64(i+1), migration.Version, "migrations are numbered without gaps")
+	}
+}
+
+func TestPlanMigrations(t *testing.T) {
+	all := []*Migration{
+		{Version: 1, Name: "one"},
+		{Version: 2, Name: "two"},
+		{Version: 3, Name: "three"},
+		{Version: 4, Name: "four"},
+	}
+	applied := map[int64]*AppliedMigration{
+		1: {Version: 1},
+		2: {Version: 2},
+	}
+
+	versions := func(plan []*Migration) []int64 {
+		var result []int64
+		for _, migration := range plan {
+			result = append(result, migration.Version)
+		}
+		return result
+	}
+
+	assert.Equal(t, []int64{3, 4}, versions(planMigrations(all, applied, DirectionUp, 0)))
+	assert.Equal(t, []int64{3}, versions(planMigrations(all, applied, DirectionUp, 1)))
+	assert.Equal(t, []int64{2}, versions(planMigrations(all, applied, DirectionDown, 0)))
+	assert.Equal(t, []int64{2, 1}, versions(planMigrations(all, applied, DirectionDown, 5)))
+	assert.Empty(t, planMigrations(all, map[int64]*AppliedMigration{}, DirectionDown, 1))
+
+	// A migration added below the newest applied version is still applied
+	applied = map[int64]*AppliedMigration{1: {Version: 1}, 3: {Version: 3}}
+	assert.Equal(t, []int64{2, 4}, versions(planMigrations(all, applied, DirectionUp, 0)))
+}
@@ -0,0 +1,110 @@
+package models
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AccessListType represents whether a rule allows or denies matching requests
+type AccessListType string
+
+const (
+	AccessListAllow AccessListType = "allow"
+	AccessListDeny  AccessListType = "deny"
+)
+
+// AccessMatchType represents the request attribute an access rule matches on
+type AccessMatchType string
+
+const (
+	AccessMatchAPIKey    AccessMatchType = "api_key"
+	AccessMatchIP        AccessMatchType = "ip"
+	AccessMatchCIDR      AccessMatchType = "cidr"
+	AccessMatchUserAgent AccessMatchType = "user_agent"
+	AccessMatchCountry   AccessMatchType = "country"
+)
+
+// AccessRuleSource represents how an access rule was created
+type AccessRuleSource string
+
+const (
+	AccessRuleSourceManual    AccessRuleSource = "manual"
+	AccessRuleSourceAutomatic AccessRuleSource = "automatic"
+)
+
+// AccessRule represents an allowlist or blocklist entry. Rules with an
+// expiry are temporary bans or exemptions.
+type AccessRule struct {
+	ID        uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	ListType  AccessListType  `json:"list_type" gorm:"type:varchar(10);not null;index"`
+	MatchType AccessMatchType `json:"match_type" gorm:"type:varchar(20);not null;index"`
+
+	// Value is the API key ID, IP address, CIDR block, user agent substring
+	// or ISO country code, depending on MatchType
+	Value  string           `json:"value" gorm:"not null;size:500"`
+	Reason string           `json:"reason" gorm:"size:500"`
+	Source AccessRuleSource `json:"source" gorm:"type:varchar(20);not null;default:'manual'"`
+
+	// Set when the rule was created in response to a violation
+	ViolationID *uint64 `json:"violation_id,omitempty"`
+
+	// Expiration
+	ExpiresAt *time.Time `json:"expires_at,omitempty" gorm:"index"`
+
+	// Audit fields
+	CreatedBy string         `json:"created_by" gorm:"size:255"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName returns the table name for AccessRule
+func (AccessRule) TableName() string {
+	return "access_rules"
+}
+
+// BeforeCreate is called before creating an access rule
+func (r *AccessRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.Source == "" {
+		r.Source = AccessRuleSourceManual
+	}
+	return nil
+}
+
+// IsExpired returns true if the rule has an expiry in the past
+func (r *AccessRule) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !now.Before(*r.ExpiresAt)
+}
+
+// IsTemporary returns true if the rule expires
+func (r *AccessRule) IsTemporary() bool {
+	return r.ExpiresAt != nil
+}
+
+// Matches returns true if the rule applies to a request with the given attributes
+func (r *AccessRule) Matches(apiKeyID uuid.UUID, ip, userAgent, country string) bool {
+	switch r.MatchType {
+	case AccessMatchAPIKey:
+		return apiKeyID != uuid.Nil && strings.EqualFold(r.Value, apiKeyID.String())
+	case AccessMatchIP:
+		ruleIP, requestIP := net.ParseIP(r.Value), net.ParseIP(ip)
+		return ruleIP != nil && requestIP != nil && ruleIP.Equal(requestIP)
+	case AccessMatchCIDR:
+		_, network, err := net.ParseCIDR(r.Value)
+		requestIP := net.ParseIP(ip)
+		return err == nil && requestIP != nil && network.Contains(requestIP)
+	case AccessMatchUserAgent:
+		return userAgent != "" && strings.Contains(strings.ToLower(userAgent), strings.ToLower(r.Value))
+	case AccessMatchCountry:
+		return country != "" && strings.EqualFold(r.Value, country)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessRule_Matches(t *testing.T) {
+	apiKeyID := uuid.New()
+
+	tests := []struct {
+		name string
+		rule AccessRule
+		ip   string
+		ua   string
+		cc   string
+		want bool
+	}{
+		{"api key", AccessRule{MatchType: AccessMatchAPIKey, Value: apiKeyID.String()}, "", "", "", true},
+		{"other api key", AccessRule{MatchType: AccessMatchAPIKey, Value: uuid.New().String()}, "", "", "", false},
+		{"ip", AccessRule{MatchType: AccessMatchIP, Value: "203.0.113.7"}, "203.0.113.7", "", "", true},
+		{"ip mismatch", AccessRule{MatchType: AccessMatchIP, Value: "203.0.113.7"}, "203.0.113.8", "", "", false},
+		{"cidr", AccessRule{MatchType: AccessMatchCIDR, Value: "10.0.0.0/8"}, "10.20.30.40", "", "", true},
+		{"cidr mismatch", AccessRule{MatchType: AccessMatchCIDR, Value: "10.0.0.0/8"}, "192.168.1.1", "", "", false},
+		{"user agent", AccessRule{MatchType: AccessMatchUserAgent, Value: "badbot"}, "", "Mozilla/5.0 (BadBot/1.0)", "", true},
+		{"country", AccessRule{MatchType: AccessMatchCountry, Value: "US"}, "", "", "us", true},
+		{"missing country", AccessRule{MatchType: AccessMatchCountry, Value: "US"}, "", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.Matches(apiKeyID, tt.ip, tt.ua, tt.cc))
+		})
+	}
+}
+
+func TestAccessRule_IsExpired(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Minute)
+	future := now.Add(time.Minute)
+
+	assert.False(t, (&AccessRule{}).IsExpired(now))
+	assert.True(t, (&AccessRule{ExpiresAt: &past}).IsExpired(now))
+	assert.False(t, (&AccessRule{ExpiresAt: &future}).IsExpired(now))
+}
@@ -34,25 +34,118 @@ const (
 	AlertSeverityInfo     AlertSeverity = "info"
 )
 
-// AlertStatus represents the status of an alert
+// IsValid returns true for a known alert type
+func (t AlertType) IsValid() bool {
+	switch t {
+	case AlertTypeUsageThreshold, AlertTypeRateLimitExceeded, AlertTypeBillingOverage,
+		AlertTypeSecurityAlert, AlertTypeSystemHealth, AlertTypeRateLimit, AlertTypeQuotaExceeded,
+		AlertTypeAbnormalUsage, AlertTypeSystemError, AlertTypeSecurityIssue:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsValid returns true for a known alert severity
+func (s AlertSeverity) IsValid() bool {
+	switch s {
+	case AlertSeverityLow, AlertSeverityMedium, AlertSeverityHigh, AlertSeverityCritical, AlertSeverityInfo:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rank orders severities from info (0) to critical (4); unknown severities rank -1
+func (s AlertSeverity) Rank() int {
+	switch s {
+	case AlertSeverityInfo:
+		return 0
+	case AlertSeverityLow:
+		return 1
+	case AlertSeverityMedium:
+		return 2
+	case AlertSeverityHigh:
+		return 3
+	case AlertSeverityCritical:
+		return 4
+	default:
+		return -1
+	}
+}
+
+// AtLeast returns true if s is as severe as min or more
+func (s AlertSeverity) AtLeast(min AlertSeverity) bool {
+	return s.Rank() >= min.Rank()
+}
+
+// AlertDeliveryStatus represents the state of an alert notification on one channel
+type AlertDeliveryStatus string
+
+const (
+	AlertDeliveryPending  AlertDeliveryStatus = "pending"  // queued, not attempted yet
+	AlertDeliveryRetrying AlertDeliveryStatus = "retrying" // the last attempt failed and will be retried
+	AlertDeliverySent     AlertDeliveryStatus = "sent"
+	AlertDeliveryFailed   AlertDeliveryStatus = "failed" // given up
+)
+
+// AlertDelivery tracks the notification of an alert on one channel
+type AlertDelivery struct {
+	Status        AlertDeliveryStatus `json:"status"`
+	Attempts      int                 `json:"attempts"`
+	LastError     string              `json:"last_error,omitempty"`
+	LastAttemptAt *time.Time          `json:"last_attempt_at,omitempty"`
+	SentAt        *time.Time          `json:"sent_at,omitempty"`
+}
+
+// AlertStatus represents the lifecycle state of an alert
 type AlertStatus string
 
 const (
-	AlertStatusActive    AlertStatus = "active"
-	AlertStatusResolved  AlertStatus = "resolved"
-	AlertStatusSuppressed AlertStatus = "suppressed"
+	AlertStatusOpen         AlertStatus = "open"         // raised and not yet handled; escalates
+	AlertStatusAcknowledged AlertStatus = "acknowledged" // someone is on it; no longer escalates
+	AlertStatusSnoozed      AlertStatus = "snoozed"      // silenced until SnoozedUntil, then open again
+	AlertStatusResolved     AlertStatus = "resolved"
 )
 
+// IsValid returns true for a known alert status
+func (s AlertStatus) IsValid() bool {
+	switch s {
+	case AlertStatusOpen, AlertStatusAcknowledged, AlertStatusSnoozed, AlertStatusResolved:
+		return true
+	default:
+		return false
+	}
+}
+
 // Alert represents an alert in the system
 type Alert struct {
 	ID          uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	APIKeyID    uuid.UUID     `json:"api_key_id" gorm:"type:uuid;not null;index"`
+	RuleID      *uuid.UUID    `json:"rule_id,omitempty" gorm:"type:uuid;index"`
 	Type        AlertType     `json:"type" gorm:"type:varchar(50);not null;index"`
 	Severity    AlertSeverity `json:"severity" gorm:"type:varchar(20);not null;index"`
 	Message     string        `json:"message" gorm:"not null;size:1000"`
+	Status      AlertStatus   `json:"status" gorm:"type:varchar(20);not null;default:'open';index"`
+	
+	// Deduplication: while an alert is unresolved, conditions with the same
+	// key update it instead of raising another alert
+	DedupKey    *string    `json:"dedup_key,omitempty" gorm:"size:255"`
+	Occurrences int        `json:"occurrences" gorm:"not null;default:1"`
+	LastSeenAt  *time.Time `json:"last_seen_at,omitempty"`
+	
+	// Lifecycle
+	AcknowledgedAt  *time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy  *string    `json:"acknowledged_by,omitempty" gorm:"size:255"`
+	SnoozedUntil    *time.Time `json:"snoozed_until,omitempty"`
+	EscalationLevel int        `json:"escalation_level" gorm:"not null;default:0"`
+	EscalatedAt     *time.Time `json:"escalated_at,omitempty"`
 	
 	// Additional data
-	Metadata map[string]interface{} `json:"metadata" gorm:"type:jsonb"`
+	Metadata map[string]interface{} `json:"metadata" gorm:"type:jsonb;serializer:json"`
+	
+	// Notification state by channel name
+	Deliveries map[string]*AlertDelivery `json:"deliveries,omitempty" gorm:"type:jsonb;serializer:json"`
 	
 	// Resolution info
 	Resolved   bool       `json:"resolved" gorm:"default:false;index"`
@@ -77,6 +170,12 @@ func (a *Alert) BeforeCreate(tx *gorm.DB) error {
 	if a.ID == uuid.Nil {
 		a.ID = uuid.New()
 	}
+	if a.Status == "" {
+		a.Status = AlertStatusOpen
+	}
+	if a.Occurrences == 0 {
+		a.Occurrences = 1
+	}
 	return nil
 }
 
@@ -87,10 +186,38 @@ func (a *Alert) IsResolved() bool {
 
 // Resolve marks the alert as resolved
 func (a *Alert) Resolve(resolvedBy string) {
+	a.ResolveAt(resolvedBy, time.Now())
+}
+
+// ResolveAt marks the alert as resolved at the given time
+func (a *Alert) ResolveAt(resolvedBy string, at time.Time) {
+	a.Status = AlertStatusResolved
 	a.Resolved = true
-	now := time.Now()
-	a.ResolvedAt = &now
+	a.ResolvedAt = &at
 	a.ResolvedBy = &resolvedBy
+	a.SnoozedUntil = nil
+}
+
+// Acknowledge marks the alert as being handled, which stops its escalation
+func (a *Alert) Acknowledge(acknowledgedBy string, at time.Time) {
+	a.Status = AlertStatusAcknowledged
+	a.AcknowledgedAt = &at
+	a.AcknowledgedBy = &acknowledgedBy
+	a.SnoozedUntil = nil
+}
+
+// Snooze silences the alert until the given time
+func (a *Alert) Snooze(until time.Time) {
+	a.Status = AlertStatusSnoozed
+	a.SnoozedUntil = &until
+}
+
+// Reopen returns a snoozed or acknowledged alert to open
+func (a *Alert) Reopen() {
+	a.Status = AlertStatusOpen
+	a.SnoozedUntil = nil
+	a.AcknowledgedAt = nil
+	a.AcknowledgedBy = nil
 }
 
 // GetDurationActive returns how long the alert has been active
@@ -0,0 +1,192 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AlertMatchMode represents how the conditions of an alert rule combine
+type AlertMatchMode string
+
+const (
+	AlertMatchAll AlertMatchMode = "all" // every condition must hold
+	AlertMatchAny AlertMatchMode = "any" // one condition is enough
+)
+
+// IsValid returns true for a known match mode
+func (m AlertMatchMode) IsValid() bool {
+	return m == AlertMatchAll || m == AlertMatchAny
+}
+
+// AlertRule is a condition on the usage of API keys that raises an alert when
+// it holds. A rule applies to every active API key within its scope.
+type AlertRule struct {
+	ID          uuid.UUID     `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name        string        `json:"name" gorm:"not null;size:255"`
+	Description string        `json:"description" gorm:"size:500"`
+	Type        AlertType     `json:"type" gorm:"type:varchar(50);not null"`
+	Severity    AlertSeverity `json:"severity" gorm:"type:varchar(20);not null"`
+
+	Conditions AlertConditions `json:"conditions" gorm:"type:jsonb;serializer:json;not null"`
+	MatchMode  AlertMatchMode  `json:"match_mode" gorm:"type:varchar(10);not null;default:'all'"`
+	Scope      AlertRuleScope  `json:"scope" gorm:"embedded"`
+
+	Enabled         bool `json:"enabled" gorm:"not null;default:true"`
+	CooldownMinutes int  `json:"cooldown_minutes" gorm:"not null;default:0"`
+
+	// Escalation re-notifies open alerts of the rule at higher severities
+	Escalation *AlertEscalationPolicy `json:"escalation,omitempty" gorm:"type:jsonb;serializer:json"`
+
+	// Audit fields
+	CreatedBy string         `json:"created_by" gorm:"size:255"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName returns the table name for AlertRule
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// BeforeCreate is called before creating an alert rule
+func (r *AlertRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == uuid.Nil {
+		r.ID = uuid.New()
+	}
+	if r.MatchMode == "" {
+		r.MatchMode = AlertMatchAll
+	}
+	return nil
+}
+
+// AppliesTo returns true if the API key is within the rule's scope
+func (r *AlertRule) AppliesTo(apiKey *APIKey) bool {
+	return r.Scope.Contains(apiKey)
+}
+
+// AlertEscalationPolicy raises the severity of an alert that stays open, one
+// step at a time, and notifies again at every step
+type AlertEscalationPolicy struct {
+	Steps []AlertEscalationStep `json:"steps"`
+}
+
+// AlertEscalationStep escalates an alert still open AfterMinutes after it was
+// raised to Severity
+type AlertEscalationStep struct {
+	AfterMinutes int           `json:"after_minutes"`
+	Severity     AlertSeverity `json:"severity"`
+}
+
+// Due returns the next step an alert at the given escalation level has
+// reached after being open for the given time
+func (p *AlertEscalationPolicy) Due(level int, open time.Duration) (AlertEscalationStep, bool) {
+	if p == nil || level < 0 || level >= len(p.Steps) {
+		return AlertEscalationStep{}, false
+	}
+	step := p.Steps[level]
+	if open < time.Duration(step.AfterMinutes)*time.Minute {
+		return AlertEscalationStep{}, false
+	}
+	return step, true
+}
+
+// AlertRuleScope limits an alert rule to one API key, tier or team. Unset
+// fields match every key; a scope with several fields set matches keys that
+// satisfy all of them.
+type AlertRuleScope struct {
+	APIKeyID *uuid.UUID  `json:"api_key_id,omitempty" gorm:"type:uuid;index"`
+	Tier     *APIKeyTier `json:"tier,omitempty" gorm:"type:varchar(20)"`
+	TeamID   *uuid.UUID  `json:"team_id,omitempty" gorm:"type:uuid;index"`
+}
+
+// Contains returns true if the API key is within the scope
+func (s AlertRuleScope) Contains(apiKey *APIKey) bool {
+	if s.APIKeyID != nil && *s.APIKeyID != apiKey.ID {
+		return false
+	}
+	if s.Tier != nil && *s.Tier != APIKeyTierAll && *s.Tier != apiKey.Tier {
+		return false
+	}
+	if s.TeamID != nil && (apiKey.TeamID == nil || *s.TeamID != *apiKey.TeamID) {
+		return false
+	}
+	return true
+}
+
+// AlertConditions defines conditions that trigger alerts. Window based
+// conditions look at the last TimeWindowMinutes minutes.
+type AlertConditions struct {
+	RateLimitViolations *ThresholdCondition `json:"rate_limit_violations,omitempty"`
+	QuotaUsagePercent   *ThresholdCondition `json:"quota_usage_percent,omitempty"`
+	ErrorRatePercent    *ThresholdCondition `json:"error_rate_percent,omitempty"`
+	ResponseTimeMs      *ThresholdCondition `json:"response_time_ms,omitempty"`
+	UnusualTrafficSpike *SpikeCondition     `json:"unusual_traffic_spike,omitempty"`
+	NewAccessPattern    *NewAccessCondition `json:"new_access_pattern,omitempty"`
+	ConsecutiveFailures *CountCondition     `json:"consecutive_failures,omitempty"`
+	TimeWindowMinutes   int                 `json:"time_window_minutes"`
+}
+
+// IsEmpty returns true if no condition is set
+func (c AlertConditions) IsEmpty() bool {
+	return c.RateLimitViolations == nil && c.QuotaUsagePercent == nil && c.ErrorRatePercent == nil &&
+		c.ResponseTimeMs == nil && c.UnusualTrafficSpike == nil &&
+		c.NewAccessPattern == nil && c.ConsecutiveFailures == nil
+}
+
+// ThresholdCondition defines a threshold-based condition
+type ThresholdCondition struct {
+	Operator string  `json:"operator"` // "gt", "gte", "lt", "lte", "eq"
+	Value    float64 `json:"value"`
+}
+
+// Evaluate returns true if value satisfies the condition
+func (c *ThresholdCondition) Evaluate(value float64) bool {
+	switch c.Operator {
+	case "gt":
+		return value > c.Value
+	case "gte":
+		return value >= c.Value
+	case "lt":
+		return value < c.Value
+	case "lte":
+		return value <= c.Value
+	case "eq":
+		return value == c.Value
+	default:
+		return false
+	}
+}
+
+// IsValid returns true if the operator is known
+func (c *ThresholdCondition) IsValid() bool {
+	switch c.Operator {
+	case "gt", "gte", "lt", "lte", "eq":
+		return true
+	default:
+		return false
+	}
+}
+
+// SpikeCondition defines a traffic spike condition
+type SpikeCondition struct {
+	Multiplier      float64 `json:"multiplier"` // e.g., 3.0 for 3x normal traffic
+	BaselineMinutes int     `json:"baseline_minutes"`
+}
+
+// NewAccessCondition defines a condition on countries, IP addresses or user
+// agents an API key did not use during the baseline. A minimum of zero
+// ignores that attribute.
+type NewAccessCondition struct {
+	BaselineMinutes  int `json:"baseline_minutes"`
+	MinNewCountries  int `json:"min_new_countries"`
+	MinNewIPs        int `json:"min_new_ips"`
+	MinNewUserAgents int `json:"min_new_user_agents"`
+}
+
+// CountCondition defines a count-based condition
+type CountCondition struct {
+	Count int `json:"count"`
+}
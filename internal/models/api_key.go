@@ -17,6 +17,9 @@ const (
 	APIKeyStatusExpired   APIKeyStatus = "expired"
 )
 
+// SuspendedReasonBilling marks API keys suspended for overdue invoices
+const SuspendedReasonBilling = "billing"
+
 // APIKeyTier represents the tier of an API key
 type APIKeyTier string
 
@@ -27,6 +30,46 @@ const (
 	APIKeyTierAll        APIKeyTier = "all" // Special value for querying all tiers
 )
 
+// IsValid returns true for a tier a key can be on
+func (t APIKeyTier) IsValid() bool {
+	switch t {
+	case APIKeyTierFree, APIKeyTierPro, APIKeyTierEnterprise:
+		return true
+	default:
+		return false
+	}
+}
+
+// API key scopes grant access to management endpoints. The admin scope
+// grants every other scope.
+const (
+	ScopeAdmin          = "admin"
+	ScopeAlertsRead     = "alerts:read"
+	ScopeAlertsWrite    = "alerts:write"
+	ScopeRateLimitCheck = "ratelimit:check"
+	ScopeKeysRead       = "keys:read"
+	ScopeKeysWrite      = "keys:write"
+	ScopeOrgRead        = "org:read"
+	ScopeOrgWrite       = "org:write"
+)
+
+// IsValidScope returns true for a known scope
+func IsValidScope(scope string) bool {
+	switch scope {
+	case ScopeAdmin, ScopeAlertsRead, ScopeAlertsWrite, ScopeRateLimitCheck,
+		ScopeKeysRead, ScopeKeysWrite, ScopeOrgRead, ScopeOrgWrite:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultScopes returns the scopes of keys created without any: checking
+// rate limits and reading keys and usage
+func DefaultScopes() []string {
+	return []string{ScopeRateLimitCheck, ScopeKeysRead}
+}
+
 // APIKey represents an API key in the system
 type APIKey struct {
 	ID          uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
@@ -38,11 +81,23 @@ type APIKey struct {
 	RateWindow  int          `json:"rate_window" gorm:"not null;default:3600"` // in seconds
 	Status      APIKeyStatus `json:"status" gorm:"type:varchar(20);not null;default:'active'"`
 	
+	// Why a suspended key was suspended
+	SuspendedReason string `json:"suspended_reason,omitempty" gorm:"size:20"`
+	
 	// Metadata
 	Metadata   map[string]interface{} `json:"metadata" gorm:"type:jsonb"`
 	Tags       []string               `json:"tags" gorm:"type:text[]"`
 	
+	// Scopes granted to the key
+	Scopes     []string               `json:"scopes" gorm:"type:jsonb;serializer:json"`
+	
+	// Operator keys run the service for every organization and are the only
+	// keys the admin scope applies to. Keys are made operators in the
+	// database, never through the API.
+	IsOperator bool `json:"is_operator" gorm:"not null;default:false"`
+	
 	// Ownership
+	OrganizationID *uuid.UUID `json:"organization_id,omitempty" gorm:"type:uuid;index"`
 	UserID     *uuid.UUID `json:"user_id,omitempty" gorm:"type:uuid;index"`
 	TeamID     *uuid.UUID `json:"team_id,omitempty" gorm:"type:uuid;index"`
 	
@@ -74,6 +129,9 @@ func (a *APIKey) BeforeCreate(tx *gorm.DB) error {
 	if a.ID == uuid.Nil {
 		a.ID = uuid.New()
 	}
+	if a.Scopes == nil {
+		a.Scopes = []string{}
+	}
 	return nil
 }
 
@@ -101,4 +159,21 @@ func (a *APIKey) UpdateLastUsed() {
 // IncrementUsage increments the total usage counter
 func (a *APIKey) IncrementUsage(count int64) {
 	a.TotalUsage += count
-}
\ No newline at end of file
+}
+
+// HasScope returns true if the key was granted the scope or the admin scope.
+// The admin scope is ignored on keys that are not operators.
+func (a *APIKey) HasScope(scope string) bool {
+	for _, granted := range a.Scopes {
+		if granted == ScopeAdmin {
+			if a.IsOperator {
+				return true
+			}
+			continue
+		}
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyStatusChange records an API key moving from one status to another.
+// Rows are written by a database trigger whenever api_keys.status changes, so
+// every code path that changes a status is covered.
+type APIKeyStatusChange struct {
+	ID         uint64       `json:"id" gorm:"primaryKey;autoIncrement"`
+	APIKeyID   uuid.UUID    `json:"api_key_id" gorm:"type:uuid;not null;index"`
+	FromStatus APIKeyStatus `json:"from_status" gorm:"type:varchar(20);not null"`
+	ToStatus   APIKeyStatus `json:"to_status" gorm:"type:varchar(20);not null"`
+	ChangedAt  time.Time    `json:"changed_at" gorm:"not null"`
+}
+
+// TableName returns the table name for APIKeyStatusChange
+func (APIKeyStatusChange) TableName() string {
+	return "api_key_status_changes"
+}
@@ -152,4 +152,36 @@ func TestAPIKeyTier_Constants(t *testing.T) {
 	assert.Equal(t, APIKeyTier("pro"), APIKeyTierPro)
 	assert.Equal(t, APIKeyTier("enterprise"), APIKeyTierEnterprise)
 	assert.Equal(t, APIKeyTier("all"), APIKeyTierAll)
-}
\ No newline at end of file
+}
+func TestAPIKey_HasScope(t *testing.T) {
+	reader := &APIKey{Scopes: []string{ScopeAlertsRead}}
+	assert.True(t, reader.HasScope(ScopeAlertsRead))
+	assert.False(t, reader.HasScope(ScopeAlertsWrite))
+	assert.False(t, reader.HasScope(ScopeAdmin))
+
+	admin := &APIKey{Scopes: []string{ScopeAdmin}, IsOperator: true}
+	assert.True(t, admin.HasScope(ScopeAdmin))
+	assert.True(t, admin.HasScope(ScopeAlertsRead))
+	assert.True(t, admin.HasScope(ScopeAlertsWrite))
+
+	// The admin scope only applies to operator keys
+	customer := &APIKey{Scopes: []string{ScopeAdmin, ScopeKeysRead}}
+	assert.False(t, customer.HasScope(ScopeAdmin))
+	assert.False(t, customer.HasScope(ScopeAlertsRead))
+	assert.True(t, customer.HasScope(ScopeKeysRead))
+
+	assert.False(t, (&APIKey{}).HasScope(ScopeAlertsRead))
+}
+
+func TestIsValidScope(t *testing.T) {
+	for _, scope := range []string{ScopeAdmin, ScopeRateLimitCheck, ScopeKeysRead, ScopeKeysWrite, ScopeOrgRead, ScopeOrgWrite} {
+		assert.True(t, IsValidScope(scope), scope)
+	}
+	assert.False(t, IsValidScope("keys:delete"))
+	assert.False(t, IsValidScope(""))
+
+	for _, scope := range DefaultScopes() {
+		assert.True(t, IsValidScope(scope), scope)
+	}
+	assert.NotContains(t, DefaultScopes(), ScopeKeysWrite)
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// APIKeyTierChange records an API key moving from one tier to another. Rows
+// are written by a database trigger whenever api_keys.tier changes, so every
+// code path that changes a tier is covered.
+type APIKeyTierChange struct {
+	ID        uint64     `json:"id" gorm:"primaryKey;autoIncrement"`
+	APIKeyID  uuid.UUID  `json:"api_key_id" gorm:"type:uuid;not null;index"`
+	FromTier  APIKeyTier `json:"from_tier" gorm:"type:varchar(20);not null"`
+	ToTier    APIKeyTier `json:"to_tier" gorm:"type:varchar(20);not null"`
+	ChangedAt time.Time  `json:"changed_at" gorm:"not null"`
+}
+
+// TableName returns the table name for APIKeyTierChange
+func (APIKeyTierChange) TableName() string {
+	return "api_key_tier_changes"
+}
@@ -27,7 +27,43 @@ const (
 	PaymentStatusRefunded PaymentStatus = "refunded"
 )
 
-// BillingRecord represents a billing record for an API key
+// IsValid returns true for a known payment status
+func (s PaymentStatus) IsValid() bool {
+	switch s {
+	case PaymentStatusPending, PaymentStatusPaid, PaymentStatusOverdue, PaymentStatusFailed, PaymentStatusRefunded:
+		return true
+	}
+	return false
+}
+
+// IsUnpaid returns true for a status that still has an amount to collect
+func (s PaymentStatus) IsUnpaid() bool {
+	return s == PaymentStatusPending || s == PaymentStatusOverdue || s == PaymentStatusFailed
+}
+
+// BillingLineItemKind represents what a billing line item charges for
+type BillingLineItemKind string
+
+const (
+	BillingLineItemBaseFee   BillingLineItemKind = "base_fee"
+	BillingLineItemOverage   BillingLineItemKind = "request_overage"
+	BillingLineItemBandwidth BillingLineItemKind = "bandwidth"
+)
+
+// BillingLineItem is one charge of a billing record. Quantity is the share of
+// the period for base fees, requests for overage and gigabytes for bandwidth.
+type BillingLineItem struct {
+	Kind        BillingLineItemKind `json:"kind"`
+	Description string              `json:"description"`
+	Tier        APIKeyTier          `json:"tier"`
+	PeriodStart time.Time           `json:"period_start"`
+	PeriodEnd   time.Time           `json:"period_end"`
+	Quantity    float64             `json:"quantity"`
+	Amount      float64             `json:"amount"`
+}
+
+// BillingRecord represents a billing record for an API key. The period is
+// [PeriodStart, PeriodEnd).
 type BillingRecord struct {
 	ID          uuid.UUID           `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
 	APIKeyID    uuid.UUID           `json:"api_key_id" gorm:"type:uuid;not null;index"`
@@ -59,10 +95,18 @@ type BillingRecord struct {
 	PaymentStatus PaymentStatus `json:"payment_status" gorm:"type:varchar(20);default:'pending'"`
 	PaidAt        *time.Time    `json:"paid_at,omitempty"`
 	
+	// The provider charge paying the record, and why its last charge failed
+	PaymentProvider      string `json:"payment_provider,omitempty" gorm:"size:20"`
+	PaymentChargeID      string `json:"payment_charge_id,omitempty" gorm:"size:255;index"`
+	PaymentFailureReason string `json:"payment_failure_reason,omitempty" gorm:"size:500"`
+	
 	// Tier information
 	TierAtStart string `json:"tier_at_start" gorm:"size:20"`
 	TierAtEnd   string `json:"tier_at_end" gorm:"size:20"`
 	
+	// Charges making up the amounts, one set per tier held during the period
+	LineItems []BillingLineItem `json:"line_items" gorm:"type:jsonb;serializer:json"`
+	
 	// Additional data
 	Metadata map[string]interface{} `json:"metadata" gorm:"type:jsonb"`
 	
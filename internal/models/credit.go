@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CreditAccount holds the prepaid credits of an API key or a team. Exactly
+// one of APIKeyID and TeamID is set; a key's own account is charged before
+// its team's. PeriodSpend is what was spent during the month starting at
+// PeriodStart, which MonthlySpendCap limits when set.
+type CreditAccount struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	APIKeyID        *uuid.UUID `json:"api_key_id,omitempty" gorm:"type:uuid;uniqueIndex"`
+	TeamID          *uuid.UUID `json:"team_id,omitempty" gorm:"type:uuid;uniqueIndex"`
+	Balance         float64    `json:"balance" gorm:"type:decimal(18,6);not null;default:0"`
+	MonthlySpendCap *float64   `json:"monthly_spend_cap,omitempty" gorm:"type:decimal(18,6)"`
+	PeriodStart     time.Time  `json:"period_start"`
+	PeriodSpend     float64    `json:"period_spend" gorm:"type:decimal(18,6);not null;default:0"`
+
+	// Balances at which billing overage alerts are raised; empty uses the
+	// configured thresholds
+	AlertThresholds []float64 `json:"alert_thresholds" gorm:"type:jsonb;serializer:json;not null"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for CreditAccount
+func (CreditAccount) TableName() string {
+	return "credit_accounts"
+}
+
+// BeforeCreate is called before creating a credit account
+func (a *CreditAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.AlertThresholds == nil {
+		a.AlertThresholds = []float64{}
+	}
+	return nil
+}
+
+// CreditEntryType represents the kind of a credit ledger entry
+type CreditEntryType string
+
+const (
+	// CreditEntryTopUp adds purchased credits, which may expire
+	CreditEntryTopUp CreditEntryType = "topup"
+	// CreditEntryDebit draws credits for the requests of one hour
+	CreditEntryDebit CreditEntryType = "debit"
+	// CreditEntryExpiry removes the unspent part of an expired top-up
+	CreditEntryExpiry CreditEntryType = "expiry"
+)
+
+// CreditLedgerEntry is one change to the balance of a credit account. Amount
+// is positive for top-ups and negative for debits and expiries. Debits are
+// kept per hour, counting the requests they paid for, rather than per request.
+type CreditLedgerEntry struct {
+	ID          uuid.UUID       `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	AccountID   uuid.UUID       `json:"account_id" gorm:"type:uuid;not null;index"`
+	Type        CreditEntryType `json:"type" gorm:"type:varchar(20);not null"`
+	Amount      float64         `json:"amount" gorm:"type:decimal(18,6);not null"`
+	Description string          `json:"description,omitempty" gorm:"size:500"`
+
+	// Top-ups: the purchase reference, which makes a top-up idempotent, and
+	// when the credits expire
+	Reference string     `json:"reference,omitempty" gorm:"size:255"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	ExpiredAt *time.Time `json:"expired_at,omitempty"`
+
+	// Debits: the hour charged and the number of requests
+	PeriodStart *time.Time `json:"period_start,omitempty"`
+	Requests    int64      `json:"requests,omitempty" gorm:"not null;default:0"`
+
+	// Expiries: the top-up that expired
+	TopUpID *uuid.UUID `json:"topup_id,omitempty" gorm:"column:topup_id;type:uuid"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for CreditLedgerEntry
+func (CreditLedgerEntry) TableName() string {
+	return "credit_ledger_entries"
+}
+
+// BeforeCreate is called before creating a credit ledger entry
+func (e *CreditLedgerEntry) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
@@ -71,55 +71,6 @@ func InitDB(cfg *config.DatabaseConfig) error {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	// Auto migrate if enabled
-	if cfg.AutoMigrate {
-		if err := AutoMigrate(); err != nil {
-			return fmt.Errorf("failed to auto migrate: %w", err)
-		}
-	}
-
-	return nil
-}
-
-// AutoMigrate runs database migrations
-func AutoMigrate() error {
-	err := DB.AutoMigrate(
-		&APIKey{},
-		&UsageLog{},
-		&Alert{},
-		&RateLimitViolation{},
-		&BillingRecord{},
-	)
-	if err != nil {
-		return fmt.Errorf("failed to auto migrate: %w", err)
-	}
-
-	// Create indexes for better performance
-	if err := createIndexes(); err != nil {
-		return fmt.Errorf("failed to create indexes: %w", err)
-	}
-
-	return nil
-}
-
-// createIndexes creates additional database indexes
-func createIndexes() error {
-	// Create indexes one by one to handle any missing columns gracefully
-	indexes := []string{
-		`CREATE INDEX IF NOT EXISTS idx_usage_logs_api_key_timestamp ON usage_logs (api_key_id, timestamp DESC)`,
-		`CREATE INDEX IF NOT EXISTS idx_rate_violations_processed ON rate_limit_violations (processed_at) WHERE processed_at IS NULL`,
-		`CREATE INDEX IF NOT EXISTS idx_billing_records_period ON billing_records (api_key_id, period_start, period_end)`,
-		`CREATE INDEX IF NOT EXISTS idx_alerts_severity_status ON alerts (severity, created_at DESC)`,
-	}
-	
-	for _, idx := range indexes {
-		// Try to create each index, but don't fail if one fails
-		if err := DB.Exec(idx).Error; err != nil {
-			// Log the error but continue
-			fmt.Printf("Warning: Could not create index: %v\n", err)
-		}
-	}
-
 	return nil
 }
 
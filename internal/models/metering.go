@@ -0,0 +1,65 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MeteringMetric is a usage quantity exported to external billing systems
+type MeteringMetric string
+
+const (
+	MeteringMetricRequests    MeteringMetric = "requests"
+	MeteringMetricBandwidth   MeteringMetric = "bandwidth_bytes"
+	MeteringMetricRateLimited MeteringMetric = "rate_limited_requests"
+)
+
+// MeteringMetrics lists every exported metric
+var MeteringMetrics = []MeteringMetric{MeteringMetricRequests, MeteringMetricBandwidth, MeteringMetricRateLimited}
+
+// MeteringGeneratedCheckpoint names the checkpoint of metering record
+// generation. Sink checkpoints are named after their sink.
+const MeteringGeneratedCheckpoint = "generated"
+
+// MeteringRecord is the usage of one metric by an API key during one hour, as
+// exported to external billing systems. Records are immutable once generated;
+// their ID is derived from the key, metric and period, so a record delivered
+// twice can be recognized by its receiver.
+type MeteringRecord struct {
+	ID          string         `json:"id" gorm:"size:64;primaryKey"`
+	APIKeyID    uuid.UUID      `json:"api_key_id" gorm:"type:uuid;not null"`
+	Metric      MeteringMetric `json:"metric" gorm:"size:40;not null"`
+	Quantity    int64          `json:"quantity" gorm:"not null"`
+	PeriodStart time.Time      `json:"period_start" gorm:"not null;index:idx_metering_records_period_start"`
+	PeriodEnd   time.Time      `json:"period_end" gorm:"not null"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+// TableName returns the table name for MeteringRecord
+func (MeteringRecord) TableName() string {
+	return "metering_records"
+}
+
+// MeteringRecordID returns the ID of the record of a metric used by an API key
+// in the period starting at periodStart
+func MeteringRecordID(apiKeyID uuid.UUID, metric MeteringMetric, periodStart time.Time) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%d", apiKeyID, metric, periodStart.Unix())))
+	return "mr_" + hex.EncodeToString(sum[:16])
+}
+
+// MeteringCheckpoint records how far metering records have been generated, or
+// delivered to a sink. Every hour before Through is done.
+type MeteringCheckpoint struct {
+	Name      string    `json:"name" gorm:"size:50;primaryKey"`
+	Through   time.Time `json:"through" gorm:"not null"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for MeteringCheckpoint
+func (MeteringCheckpoint) TableName() string {
+	return "metering_checkpoints"
+}
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Organization owns users, teams and API keys. Callers only see and manage
+// what belongs to their own organization.
+type Organization struct {
+	ID        uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Name      string         `json:"name" gorm:"not null;size:255"`
+	Slug      string         `json:"slug" gorm:"uniqueIndex;not null;size:100"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName returns the table name for Organization
+func (Organization) TableName() string {
+	return "organizations"
+}
+
+// BeforeCreate is called before creating an organization
+func (o *Organization) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == uuid.Nil {
+		o.ID = uuid.New()
+	}
+	return nil
+}
+
+// UserRole is the role of a user in their organization
+type UserRole string
+
+const (
+	// UserRoleOwner can do everything, including managing other owners
+	UserRoleOwner UserRole = "owner"
+	// UserRoleAdmin manages users, teams, every API key and rate limits
+	UserRoleAdmin UserRole = "admin"
+	// UserRoleDeveloper creates API keys and manages their own and their teams'
+	UserRoleDeveloper UserRole = "developer"
+	// UserRoleViewer can only read
+	UserRoleViewer UserRole = "viewer"
+)
+
+// IsValid returns true for a known role
+func (r UserRole) IsValid() bool {
+	switch r {
+	case UserRoleOwner, UserRoleAdmin, UserRoleDeveloper, UserRoleViewer:
+		return true
+	default:
+		return false
+	}
+}
+
+// Rank orders roles by privilege, viewer lowest; unknown roles rank 0
+func (r UserRole) Rank() int {
+	switch r {
+	case UserRoleViewer:
+		return 1
+	case UserRoleDeveloper:
+		return 2
+	case UserRoleAdmin:
+		return 3
+	case UserRoleOwner:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// AtLeast returns true if the role has the privileges of min
+func (r UserRole) AtLeast(min UserRole) bool {
+	return r.Rank() >= min.Rank() && r.Rank() > 0
+}
+
+// User is a member of an organization. API keys act as the user they belong
+// to, with the user's role.
+type User struct {
+	ID             uuid.UUID      `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID      `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Email          string         `json:"email" gorm:"uniqueIndex;not null;size:255"`
+	Name           string         `json:"name" gorm:"size:255"`
+	Role           UserRole       `json:"role" gorm:"type:varchar(20);not null;default:'viewer'"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName returns the table name for User
+func (User) TableName() string {
+	return "users"
+}
+
+// BeforeCreate is called before creating a user
+func (u *User) BeforeCreate(tx *gorm.DB) error {
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+	return nil
+}
+
+// Team groups users of an organization. API keys of a team can be managed by
+// its members.
+type Team struct {
+	ID             uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	OrganizationID uuid.UUID `json:"organization_id" gorm:"type:uuid;not null;index"`
+	Name           string    `json:"name" gorm:"not null;size:255"`
+	Description    string    `json:"description" gorm:"size:500"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// TableName returns the table name for Team
+func (Team) TableName() string {
+	return "teams"
+}
+
+// BeforeCreate is called before creating a team
+func (t *Team) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	return nil
+}
+
+// TeamMember records that a user belongs to a team
+type TeamMember struct {
+	TeamID    uuid.UUID `json:"team_id" gorm:"type:uuid;primaryKey"`
+	UserID    uuid.UUID `json:"user_id" gorm:"type:uuid;primaryKey;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for TeamMember
+func (TeamMember) TableName() string {
+	return "team_members"
+}
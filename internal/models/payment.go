@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PaymentCustomer links an API key to its customer at a payment provider
+type PaymentCustomer struct {
+	ID         uuid.UUID `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	APIKeyID   uuid.UUID `json:"api_key_id" gorm:"type:uuid;not null;uniqueIndex:idx_payment_customers_api_key_provider"`
+	Provider   string    `json:"provider" gorm:"size:20;not null;uniqueIndex:idx_payment_customers_api_key_provider"`
+	CustomerID string    `json:"customer_id" gorm:"size:255;not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TableName returns the table name for PaymentCustomer
+func (PaymentCustomer) TableName() string {
+	return "payment_customers"
+}
+
+// BeforeCreate is called before creating a payment customer
+func (c *PaymentCustomer) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == uuid.Nil {
+		c.ID = uuid.New()
+	}
+	return nil
+}
+
+// PaymentEvent records a payment provider webhook event that was applied, so
+// a redelivered event is not applied twice
+type PaymentEvent struct {
+	ID              uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Provider        string     `json:"provider" gorm:"size:20;not null;uniqueIndex:idx_payment_events_provider_event_id"`
+	EventID         string     `json:"event_id" gorm:"size:255;not null;uniqueIndex:idx_payment_events_provider_event_id"`
+	Type            string     `json:"type" gorm:"size:50;not null"`
+	ChargeID        string     `json:"charge_id,omitempty" gorm:"size:255"`
+	BillingRecordID *uuid.UUID `json:"billing_record_id,omitempty" gorm:"type:uuid"`
+	ReceivedAt      time.Time  `json:"received_at" gorm:"not null"`
+}
+
+// TableName returns the table name for PaymentEvent
+func (PaymentEvent) TableName() string {
+	return "payment_events"
+}
+
+// BeforeCreate is called before creating a payment event
+func (e *PaymentEvent) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == uuid.Nil {
+		e.ID = uuid.New()
+	}
+	return nil
+}
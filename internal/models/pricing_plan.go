@@ -0,0 +1,198 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BytesPerGB is the number of bytes in a billed gigabyte of bandwidth
+const BytesPerGB = 1_000_000_000
+
+// OverageMode represents how the overage price tiers of a plan apply
+type OverageMode string
+
+const (
+	// OverageGraduated prices every overage request at the rate of the tier
+	// it falls in
+	OverageGraduated OverageMode = "graduated"
+	// OverageVolume prices all overage requests at the rate of the tier the
+	// total falls in
+	OverageVolume OverageMode = "volume"
+)
+
+// IsValid returns true for a known overage mode
+func (m OverageMode) IsValid() bool {
+	return m == OverageGraduated || m == OverageVolume
+}
+
+// PriceTier is one step of an overage price schedule. UpTo is the number of
+// overage requests the tier reaches; zero means unbounded, which only the
+// last tier may be.
+type PriceTier struct {
+	UpTo      int64   `json:"up_to"`
+	UnitPrice float64 `json:"unit_price"` // per 1,000 requests
+}
+
+// PricingPlan is the price of an API key tier for one billing period: a base
+// fee covering the included requests and bandwidth, and overage pricing
+// beyond them
+type PricingPlan struct {
+	ID       uuid.UUID  `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	Tier     APIKeyTier `json:"tier" gorm:"type:varchar(20);not null;uniqueIndex"`
+	Name     string     `json:"name" gorm:"not null;size:100"`
+	Currency string     `json:"currency" gorm:"size:3;not null;default:'USD'"`
+
+	BaseFee          float64 `json:"base_fee" gorm:"type:decimal(10,4);not null;default:0"`
+	IncludedRequests int64   `json:"included_requests" gorm:"not null;default:0"`
+
+	OverageMode  OverageMode `json:"overage_mode" gorm:"type:varchar(20);not null;default:'graduated'"`
+	OverageTiers []PriceTier `json:"overage_tiers" gorm:"type:jsonb;serializer:json;not null"`
+
+	IncludedBandwidth   int64   `json:"included_bandwidth" gorm:"not null;default:0"` // in bytes
+	BandwidthPricePerGB float64 `json:"bandwidth_price_per_gb" gorm:"type:decimal(10,4);not null;default:0"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// PlanCharge is what a plan charges for the usage of one billing period
+type PlanCharge struct {
+	BaseFee           float64 `json:"base_fee"`
+	OverageRequests   int64   `json:"overage_requests"`
+	OverageAmount     float64 `json:"overage_amount"`
+	BillableBandwidth int64   `json:"billable_bandwidth"` // in bytes
+	BandwidthAmount   float64 `json:"bandwidth_amount"`
+}
+
+// Total returns the sum of every part of the charge
+func (c PlanCharge) Total() float64 {
+	return c.BaseFee + c.OverageAmount + c.BandwidthAmount
+}
+
+// TableName returns the table name for PricingPlan
+func (PricingPlan) TableName() string {
+	return "pricing_plans"
+}
+
+// BeforeCreate is called before creating a pricing plan
+func (p *PricingPlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	if p.Currency == "" {
+		p.Currency = "USD"
+	}
+	if p.OverageMode == "" {
+		p.OverageMode = OverageGraduated
+	}
+	if p.OverageTiers == nil {
+		p.OverageTiers = []PriceTier{}
+	}
+	return nil
+}
+
+// Validate checks that the plan can price usage. A plan without overage tiers
+// charges nothing beyond its base fee for requests.
+func (p *PricingPlan) Validate() error {
+	if len(p.Currency) != 3 {
+		return fmt.Errorf("currency must be a three letter code")
+	}
+	if p.BaseFee < 0 || p.IncludedRequests < 0 || p.IncludedBandwidth < 0 || p.BandwidthPricePerGB < 0 {
+		return fmt.Errorf("fees, prices and included amounts must not be negative")
+	}
+	if !p.OverageMode.IsValid() {
+		return fmt.Errorf("invalid overage mode: %s", p.OverageMode)
+	}
+
+	var previous int64
+	for i, tier := range p.OverageTiers {
+		if tier.UnitPrice < 0 {
+			return fmt.Errorf("overage tier %d: unit price must not be negative", i+1)
+		}
+		last := i == len(p.OverageTiers)-1
+		switch {
+		case tier.UpTo == 0 && !last:
+			return fmt.Errorf("overage tier %d: only the last tier may be unbounded", i+1)
+		case tier.UpTo == 0:
+		case tier.UpTo <= previous:
+			return fmt.Errorf("overage tier %d: up_to must be greater than the tier before", i+1)
+		case last:
+			return fmt.Errorf("overage tier %d: the last tier must be unbounded", i+1)
+		}
+		previous = tier.UpTo
+	}
+	return nil
+}
+
+// Prorate returns the plan scaled to a fraction of a billing period. The base
+// fee, the included requests and bandwidth, and the bounds of the overage
+// tiers all shrink by the fraction, so a plan held for half a period costs
+// half as much for half the usage.
+func (p *PricingPlan) Prorate(fraction float64) *PricingPlan {
+	prorated := *p
+	prorated.BaseFee = p.BaseFee * fraction
+	prorated.IncludedRequests = int64(math.Round(float64(p.IncludedRequests) * fraction))
+	prorated.IncludedBandwidth = int64(math.Round(float64(p.IncludedBandwidth) * fraction))
+
+	prorated.OverageTiers = make([]PriceTier, len(p.OverageTiers))
+	var previous int64
+	for i, tier := range p.OverageTiers {
+		if tier.UpTo > 0 {
+			// Keep the tiers ascending however small the fraction
+			tier.UpTo = max(int64(math.Round(float64(tier.UpTo)*fraction)), previous+1)
+			previous = tier.UpTo
+		}
+		prorated.OverageTiers[i] = tier
+	}
+	return &prorated
+}
+
+// Charge prices the requests and bandwidth of a billing period
+func (p *PricingPlan) Charge(requests, bandwidth int64) PlanCharge {
+	charge := PlanCharge{
+		BaseFee:           p.BaseFee,
+		OverageRequests:   max(requests-p.IncludedRequests, 0),
+		BillableBandwidth: max(bandwidth-p.IncludedBandwidth, 0),
+	}
+	charge.OverageAmount = p.overageAmount(charge.OverageRequests)
+	charge.BandwidthAmount = float64(charge.BillableBandwidth) / BytesPerGB * p.BandwidthPricePerGB
+	return charge
+}
+
+// overageAmount prices overage requests with the plan's tiers
+func (p *PricingPlan) overageAmount(requests int64) float64 {
+	if requests <= 0 || len(p.OverageTiers) == 0 {
+		return 0
+	}
+
+	if p.OverageMode == OverageVolume {
+		for _, tier := range p.OverageTiers {
+			if tier.UpTo == 0 || requests <= tier.UpTo {
+				return float64(requests) / 1000 * tier.UnitPrice
+			}
+		}
+		last := p.OverageTiers[len(p.OverageTiers)-1]
+		return float64(requests) / 1000 * last.UnitPrice
+	}
+
+	var amount float64
+	var floor int64
+	for _, tier := range p.OverageTiers {
+		ceiling := tier.UpTo
+		if ceiling == 0 || ceiling > requests {
+			ceiling = requests
+		}
+		if ceiling > floor {
+			amount += float64(ceiling-floor) / 1000 * tier.UnitPrice
+			floor = ceiling
+		}
+		if floor >= requests {
+			break
+		}
+	}
+	return amount
+}
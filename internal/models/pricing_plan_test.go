@@ -0,0 +1,106 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPricingPlan(mode OverageMode) *PricingPlan {
+	return &PricingPlan{
+		Tier:             APIKeyTierPro,
+		Name:             "Pro",
+		Currency:         "USD",
+		BaseFee:          50,
+		IncludedRequests: 1000,
+		OverageMode:      mode,
+		OverageTiers: []PriceTier{
+			{UpTo: 1000, UnitPrice: 2},
+			{UpTo: 0, UnitPrice: 1},
+		},
+		IncludedBandwidth:   BytesPerGB,
+		BandwidthPricePerGB: 0.1,
+	}
+}
+
+func TestPricingPlan_ChargeGraduated(t *testing.T) {
+	plan := testPricingPlan(OverageGraduated)
+
+	// Within the included requests and bandwidth only the base fee is due
+	charge := plan.Charge(800, BytesPerGB/2)
+	assert.Equal(t, PlanCharge{BaseFee: 50}, charge)
+
+	// 2,500 overage requests: 1,000 at 2 and 1,500 at 1 per thousand
+	charge = plan.Charge(3500, 3*BytesPerGB)
+	assert.Equal(t, int64(2500), charge.OverageRequests)
+	assert.InDelta(t, 3.5, charge.OverageAmount, 1e-9)
+	assert.Equal(t, int64(2*BytesPerGB), charge.BillableBandwidth)
+	assert.InDelta(t, 0.2, charge.BandwidthAmount, 1e-9)
+	assert.InDelta(t, 53.7, charge.Total(), 1e-9)
+}
+
+func TestPricingPlan_ChargeVolume(t *testing.T) {
+	plan := testPricingPlan(OverageVolume)
+
+	// Within the first tier every request is priced at its rate
+	charge := plan.Charge(1800, 0)
+	assert.InDelta(t, 1.6, charge.OverageAmount, 1e-9)
+
+	// Past it, every request is priced at the second tier's rate
+	charge = plan.Charge(3500, 0)
+	assert.InDelta(t, 2.5, charge.OverageAmount, 1e-9)
+}
+
+func TestPricingPlan_ChargeWithoutOverageTiers(t *testing.T) {
+	plan := &PricingPlan{BaseFee: 0, IncludedRequests: 100, OverageMode: OverageGraduated}
+
+	charge := plan.Charge(500, 0)
+	assert.Equal(t, int64(400), charge.OverageRequests)
+	assert.Zero(t, charge.OverageAmount)
+}
+
+func TestPricingPlan_Prorate(t *testing.T) {
+	plan := testPricingPlan(OverageGraduated)
+
+	half := plan.Prorate(0.5)
+	assert.InDelta(t, 25, half.BaseFee, 1e-9)
+	assert.Equal(t, int64(500), half.IncludedRequests)
+	assert.Equal(t, int64(BytesPerGB/2), half.IncludedBandwidth)
+	assert.Equal(t, []PriceTier{{UpTo: 500, UnitPrice: 2}, {UpTo: 0, UnitPrice: 1}}, half.OverageTiers)
+
+	// The plan itself is unchanged
+	assert.Equal(t, int64(1000), plan.OverageTiers[0].UpTo)
+
+	// Tier bounds stay ascending for tiny fractions
+	tiny := (&PricingPlan{OverageTiers: []PriceTier{{UpTo: 10, UnitPrice: 3}, {UpTo: 20, UnitPrice: 2}, {UpTo: 0, UnitPrice: 1}}}).Prorate(0.001)
+	assert.Equal(t, int64(1), tiny.OverageTiers[0].UpTo)
+	assert.Equal(t, int64(2), tiny.OverageTiers[1].UpTo)
+	assert.Equal(t, int64(0), tiny.OverageTiers[2].UpTo)
+}
+
+func TestPricingPlan_Validate(t *testing.T) {
+	assert.NoError(t, testPricingPlan(OverageGraduated).Validate())
+	assert.NoError(t, (&PricingPlan{Currency: "EUR", OverageMode: OverageVolume}).Validate())
+
+	tests := []struct {
+		name   string
+		modify func(p *PricingPlan)
+	}{
+		{"currency", func(p *PricingPlan) { p.Currency = "dollars" }},
+		{"negative base fee", func(p *PricingPlan) { p.BaseFee = -1 }},
+		{"overage mode", func(p *PricingPlan) { p.OverageMode = "stairstep" }},
+		{"negative unit price", func(p *PricingPlan) { p.OverageTiers[1].UnitPrice = -1 }},
+		{"unbounded tier before the last", func(p *PricingPlan) { p.OverageTiers[0].UpTo = 0 }},
+		{"bounded last tier", func(p *PricingPlan) { p.OverageTiers[1].UpTo = 5000 }},
+		{"descending tiers", func(p *PricingPlan) {
+			p.OverageTiers = []PriceTier{{UpTo: 1000, UnitPrice: 2}, {UpTo: 500, UnitPrice: 1}, {UpTo: 0, UnitPrice: 1}}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := testPricingPlan(OverageGraduated)
+			tt.modify(plan)
+			assert.Error(t, plan.Validate())
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/migrate"
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+)
+
+// TestModelsMatchMigratedSchema applies every SQL migration to a scratch
+// schema and checks that each gorm model can read and write its table: every
+// model column exists, and every NOT NULL column without a default is set by
+// the model. It then reverts all migrations. It needs a PostgreSQL database in
+// TEST_DATABASE_DSN and is skipped otherwise.
+func TestModelsMatchMigratedSchema(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	ctx := context.Background()
+	gormConfig := &gorm.Config{Logger: logger.Discard}
+
+	admin, err := gorm.Open(postgres.Open(dsn), gormConfig)
+	require.NoError(t, err)
+
+	schemaName := fmt.Sprintf("schema_test_%d", time.Now().UnixNano())
+	require.NoError(t, admin.Exec("CREATE SCHEMA "+schemaName).Error)
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schemaName + " CASCADE")
+	})
+
+	db, err := gorm.Open(postgres.Open(withSearchPath(dsn, schemaName+",public")), gormConfig)
+	require.NoError(t, err)
+
+	all, err := migrate.Load(migrations.FS)
+	require.NoError(t, err)
+	migrator := migrate.New(db, all)
+
+	applied, err := migrator.Up(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, applied, len(all))
+
+	tables := map[string]interface{}{
+		"api_keys":                 &APIKey{},
+		"usage_logs":               &UsageLog{},
+		"alerts":                   &Alert{},
+		"alert_rules":              &AlertRule{},
+		"rate_limit_violations":    &RateLimitViolation{},
+		"billing_records":          &BillingRecord{},
+		"access_rules":             &AccessRule{},
+		"usage_rollup_checkpoints": &UsageRollupCheckpoint{},
+		"usage_exports":            &UsageExport{},
+		"pricing_plans":            &PricingPlan{},
+		"api_key_tier_changes":     &APIKeyTierChange{},
+		"api_key_status_changes":   &APIKeyStatusChange{},
+		"payment_customers":        &PaymentCustomer{},
+		"payment_events":           &PaymentEvent{},
+		"metering_records":         &MeteringRecord{},
+		"metering_checkpoints":     &MeteringCheckpoint{},
+		"credit_accounts":          &CreditAccount{},
+		"credit_ledger_entries":    &CreditLedgerEntry{},
+		"organizations":            &Organization{},
+		"users":                    &User{},
+		"teams":                    &Team{},
+		"team_members":             &TeamMember{},
+	}
+	for _, granularity := range RollupGranularities {
+		tables[granularity.TableName()] = &UsageRollup{}
+		tables[granularity.LatencyTableName()] = &UsageLatencyBin{}
+	}
+
+	for table, model := range tables {
+		t.Run(table, func(t *testing.T) {
+			parsed, err := schema.Parse(model, &sync.Map{}, db.NamingStrategy)
+			require.NoError(t, err)
+
+			var columns []struct {
+				ColumnName    string
+				IsNullable    string
+				ColumnDefault *string
+			}
+			err = db.Raw(`
+				SELECT column_name, is_nullable, column_default
+				FROM information_schema.columns
+				WHERE table_schema = ? AND table_name = ?
+			`, schemaName, table).Scan(&columns).Error
+			require.NoError(t, err)
+			require.NotEmpty(t, columns, "table %s does not exist", table)
+
+			existing := make(map[string]bool, len(columns))
+			for _, column := range columns {
+				existing[column.ColumnName] = true
+			}
+
+			modelColumns := make(map[string]bool)
+			for _, field := range parsed.Fields {
+				if field.DBName == "" {
+					continue
+				}
+				modelColumns[field.DBName] = true
+				assert.True(t, existing[field.DBName], "column %s.%s is missing", table, field.DBName)
+			}
+
+			for _, column := range columns {
+				if column.IsNullable == "NO" && column.ColumnDefault == nil {
+					assert.True(t, modelColumns[column.ColumnName],
+						"column %s.%s is NOT NULL without a default but not set by the model", table, column.ColumnName)
+				}
+			}
+		})
+	}
+
+	reverted, err := migrator.Down(ctx, len(all))
+	require.NoError(t, err)
+	assert.Len(t, reverted, len(all))
+
+	var remaining []string
+	err = db.Raw(`
+		SELECT table_name FROM information_schema.tables
+		WHERE table_schema = ? AND table_name <> 'schema_migrations'
+	`, schemaName).Scan(&remaining).Error
+	require.NoError(t, err)
+	assert.Empty(t, remaining, "down migrations left tables behind")
+}
+
+// withSearchPath adds a search_path connection parameter to a URL or
+// keyword/value DSN
+func withSearchPath(dsn, searchPath string) string {
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		return dsn + separator + "search_path=" + url.QueryEscape(searchPath)
+	}
+	return dsn + " search_path=" + searchPath
+}
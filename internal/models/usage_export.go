@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExportFormat represents the file format of a usage export
+type ExportFormat string
+
+const (
+	ExportFormatCSV     ExportFormat = "csv"
+	ExportFormatNDJSON  ExportFormat = "ndjson"
+	ExportFormatParquet ExportFormat = "parquet"
+)
+
+// IsValid returns true if the format is supported
+func (f ExportFormat) IsValid() bool {
+	switch f {
+	case ExportFormatCSV, ExportFormatNDJSON, ExportFormatParquet:
+		return true
+	}
+	return false
+}
+
+// ContentType returns the MIME type of files in this format
+func (f ExportFormat) ContentType() string {
+	switch f {
+	case ExportFormatCSV:
+		return "text/csv"
+	case ExportFormatNDJSON:
+		return "application/x-ndjson"
+	default:
+		return "application/vnd.apache.parquet"
+	}
+}
+
+// ExportStatus represents the state of a usage export
+type ExportStatus string
+
+const (
+	ExportStatusPending   ExportStatus = "pending"
+	ExportStatusRunning   ExportStatus = "running"
+	ExportStatusCompleted ExportStatus = "completed"
+	ExportStatusFailed    ExportStatus = "failed"
+)
+
+// UsageExport represents a request to export usage logs of an API key and
+// the file it produced
+type UsageExport struct {
+	ID        uuid.UUID    `json:"id" gorm:"type:uuid;primary_key;default:gen_random_uuid()"`
+	APIKeyID  uuid.UUID    `json:"api_key_id" gorm:"type:uuid;not null;index"`
+	Format    ExportFormat `json:"format" gorm:"type:varchar(10);not null"`
+	Status    ExportStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	StartTime time.Time    `json:"start_time" gorm:"not null"`
+	EndTime   time.Time    `json:"end_time" gorm:"not null"`
+
+	// Filters holds the ExportRequest filters applied to the usage logs
+	Filters map[string]interface{} `json:"filters,omitempty" gorm:"type:jsonb;serializer:json"`
+
+	// Result
+	FileName    string `json:"file_name,omitempty" gorm:"size:255"`
+	StorageKey  string `json:"-" gorm:"size:500"`
+	RecordCount int64  `json:"record_count"`
+	FileSize    int64  `json:"file_size"`
+	Error       string `json:"error,omitempty" gorm:"size:1000"`
+
+	// Timestamps
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// TableName returns the table name for UsageExport
+func (UsageExport) TableName() string {
+	return "usage_exports"
+}
+
+// IsFinished returns true if the export completed or failed
+func (e *UsageExport) IsFinished() bool {
+	return e.Status == ExportStatusCompleted || e.Status == ExportStatusFailed
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RollupGranularity is the bucket size of a usage rollup table
+type RollupGranularity string
+
+const (
+	RollupMinute RollupGranularity = "minute"
+	RollupHour   RollupGranularity = "hour"
+	RollupDay    RollupGranularity = "day"
+)
+
+// RollupGranularities lists every granularity from finest to coarsest. Each
+// granularity is built from the one before it; minute rollups are built from
+// raw usage logs.
+var RollupGranularities = []RollupGranularity{RollupMinute, RollupHour, RollupDay}
+
+// Duration returns the bucket size
+func (g RollupGranularity) Duration() time.Duration {
+	switch g {
+	case RollupMinute:
+		return time.Minute
+	case RollupHour:
+		return time.Hour
+	case RollupDay:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// Truncate returns the start of the UTC bucket containing t
+func (g RollupGranularity) Truncate(t time.Time) time.Time {
+	return t.UTC().Truncate(g.Duration())
+}
+
+// Ceil returns the start of the first UTC bucket at or after t
+func (g RollupGranularity) Ceil(t time.Time) time.Time {
+	truncated := g.Truncate(t)
+	if truncated.Equal(t) {
+		return truncated
+	}
+	return truncated.Add(g.Duration())
+}
+
+// TableName returns the rollup table holding buckets of this granularity
+func (g RollupGranularity) TableName() string {
+	return fmt.Sprintf("usage_rollups_%s", g)
+}
+
+// LatencyTableName returns the table holding the response time bins of
+// buckets of this granularity
+func (g RollupGranularity) LatencyTableName() string {
+	return fmt.Sprintf("usage_latency_%s", g)
+}
+
+// IsValid returns true for a known granularity
+func (g RollupGranularity) IsValid() bool {
+	return g.Duration() > 0
+}
+
+// UsageRollup is the pre-aggregated usage of one API key, endpoint, method,
+// status code and country within a time bucket. The same structure backs the
+// minute, hour and day tables.
+type UsageRollup struct {
+	APIKeyID    uuid.UUID `json:"api_key_id" gorm:"type:uuid;primaryKey"`
+	BucketStart time.Time `json:"bucket_start" gorm:"primaryKey"`
+	Endpoint    string    `json:"endpoint" gorm:"size:255;primaryKey"`
+	Method      string    `json:"method" gorm:"size:10;primaryKey"`
+	StatusCode  int       `json:"status_code" gorm:"primaryKey;autoIncrement:false"`
+	Country     string    `json:"country" gorm:"size:2;primaryKey"`
+
+	RequestCount      int64 `json:"request_count" gorm:"not null;default:0"`
+	TotalResponseTime int64 `json:"total_response_time" gorm:"not null;default:0"` // sum in milliseconds
+	MaxResponseTime   int   `json:"max_response_time" gorm:"not null;default:0"`   // in milliseconds
+	RequestBytes      int64 `json:"request_bytes" gorm:"not null;default:0"`
+	ResponseBytes     int64 `json:"response_bytes" gorm:"not null;default:0"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AvgResponseTime returns the average response time in milliseconds
+func (u *UsageRollup) AvgResponseTime() float64 {
+	if u.RequestCount == 0 {
+		return 0
+	}
+	return float64(u.TotalResponseTime) / float64(u.RequestCount)
+}
+
+// UsageLatencyBin counts the requests of one API key, endpoint and method
+// within a time bucket whose response time falls in one bin of a latency
+// sketch. Together, the bins of a bucket are its response time distribution.
+type UsageLatencyBin struct {
+	APIKeyID     uuid.UUID `json:"api_key_id" gorm:"type:uuid;primaryKey"`
+	BucketStart  time.Time `json:"bucket_start" gorm:"primaryKey"`
+	Endpoint     string    `json:"endpoint" gorm:"size:255;primaryKey"`
+	Method       string    `json:"method" gorm:"size:10;primaryKey"`
+	Bin          int       `json:"bin" gorm:"primaryKey;autoIncrement:false"`
+	RequestCount int64     `json:"request_count" gorm:"not null;default:0"`
+}
+
+// UsageRollupCheckpoint records how far a rollup table is complete. Every
+// bucket before RolledUpTo is final; later usage must be read from the finer
+// granularity or from raw logs.
+type UsageRollupCheckpoint struct {
+	Granularity RollupGranularity `json:"granularity" gorm:"size:10;primaryKey"`
+	RolledUpTo  time.Time         `json:"rolled_up_to" gorm:"not null"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// TableName returns the table name for UsageRollupCheckpoint
+func (UsageRollupCheckpoint) TableName() string {
+	return "usage_rollup_checkpoints"
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// Default minimum severities by channel
+const (
+	defaultWebhookSeverity  = models.AlertSeverityInfo
+	defaultSlackSeverity    = models.AlertSeverityMedium
+	defaultEmailSeverity    = models.AlertSeverityHigh
+	defaultIncidentSeverity = models.AlertSeverityCritical
+)
+
+// RoutesFromConfig builds a route for every enabled channel
+func RoutesFromConfig(cfg *config.NotificationConfig) ([]Route, error) {
+	var routes []Route
+	add := func(channel Channel, minSeverity string, fallback models.AlertSeverity) error {
+		severity := fallback
+		if minSeverity != "" {
+			severity = models.AlertSeverity(minSeverity)
+			if !severity.IsValid() {
+				return fmt.Errorf("invalid min_severity for %s: %s", channel.Name(), minSeverity)
+			}
+		}
+		routes = append(routes, Route{Channel: channel, MinSeverity: severity})
+		return nil
+	}
+
+	if cfg.WebhookURL != "" {
+		channel := NewWebhookChannel(cfg.WebhookURL, cfg.Webhook.Secret, cfg.Timeout)
+		if err := add(channel, cfg.Webhook.MinSeverity, defaultWebhookSeverity); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.SlackEnabled {
+		if cfg.Slack.WebhookURL == "" {
+			return nil, fmt.Errorf("slack.webhook_url is required when slack is enabled")
+		}
+		channel := NewSlackChannel(cfg.Slack.WebhookURL, cfg.Slack.Channel, cfg.Timeout)
+		if err := add(channel, cfg.Slack.MinSeverity, defaultSlackSeverity); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.EmailEnabled {
+		email := cfg.Email
+		if email.SMTPHost == "" || email.From == "" || len(email.To) == 0 {
+			return nil, fmt.Errorf("email.smtp_host, email.from and email.to are required when email is enabled")
+		}
+		channel := NewEmailChannel(email.SMTPHost, email.SMTPPort, email.Username, email.Password, email.From, email.To, cfg.Timeout)
+		if err := add(channel, email.MinSeverity, defaultEmailSeverity); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Incident.Enabled {
+		if cfg.Incident.RoutingKey == "" {
+			return nil, fmt.Errorf("incident.routing_key is required when incidents are enabled")
+		}
+		channel := NewIncidentChannel(cfg.Incident.URL, cfg.Incident.RoutingKey, cfg.Incident.Source, cfg.Timeout)
+		if err := add(channel, cfg.Incident.MinSeverity, defaultIncidentSeverity); err != nil {
+			return nil, err
+		}
+	}
+
+	return routes, nil
+}
+
+// RendererFromConfig parses the configured message templates
+func RendererFromConfig(cfg *config.NotificationConfig) (*Renderer, error) {
+	return NewRenderer(Templates{
+		Subject: cfg.Templates.Subject,
+		Body:    cfg.Templates.Body,
+	})
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sendMailFunc matches smtp.SendMail
+type sendMailFunc func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+
+// EmailChannel sends alerts as plain text email through an SMTP server.
+// Connections use STARTTLS when the server offers it.
+type EmailChannel struct {
+	addr     string
+	host     string
+	auth     smtp.Auth
+	from     string
+	to       []string
+	timeout  time.Duration
+	sendMail sendMailFunc
+	now      func() time.Time
+}
+
+// NewEmailChannel creates an email channel. Without a username, mail is sent
+// unauthenticated.
+func NewEmailChannel(host string, port int, username, password, from string, to []string, timeout time.Duration) *EmailChannel {
+	if port <= 0 {
+		port = 587
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &EmailChannel{
+		addr:     net.JoinHostPort(host, strconv.Itoa(port)),
+		host:     host,
+		auth:     auth,
+		from:     from,
+		to:       to,
+		timeout:  timeout,
+		sendMail: smtp.SendMail,
+		now:      time.Now,
+	}
+}
+
+// Name returns "email"
+func (c *EmailChannel) Name() string {
+	return "email"
+}
+
+// Send mails the alert to every recipient
+func (c *EmailChannel) Send(ctx context.Context, msg *Message) error {
+	if len(c.to) == 0 {
+		return &PermanentError{Err: fmt.Errorf("email delivery failed: no recipients configured")}
+	}
+
+	// smtp.SendMail takes no context, so bound it by the timeout instead
+	done := make(chan error, 1)
+	go func() {
+		done <- c.sendMail(c.addr, c.auth, c.from, c.to, c.compose(msg))
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-time.After(c.timeout):
+		err = fmt.Errorf("timed out after %s", c.timeout)
+	}
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("email delivery failed: %w", err)
+	var reply *textproto.Error
+	if errors.As(err, &reply) && reply.Code >= 500 {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+// compose builds the RFC 5322 message for an alert
+func (c *EmailChannel) compose(msg *Message) []byte {
+	var buf bytes.Buffer
+	headers := [][2]string{
+		{"From", c.from},
+		{"To", strings.Join(c.to, ", ")},
+		{"Subject", mime.QEncoding.Encode("utf-8", msg.Subject)},
+		{"Date", c.now().Format(time.RFC1123Z)},
+		{"Message-ID", fmt.Sprintf("<alert-%s@%s>", msg.Alert.ID, c.host)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", `text/plain; charset="utf-8"`},
+		{"Content-Transfer-Encoding", "8bit"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header[0], header[1])
+	}
+	buf.WriteString("\r\n")
+
+	// SMTP requires CRLF line endings
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	buf.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	buf.WriteString("\r\n")
+	return buf.Bytes()
+}
@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// DefaultIncidentURL is the PagerDuty Events API v2 endpoint
+const DefaultIncidentURL = "https://events.pagerduty.com/v2/enqueue"
+
+// IncidentEvent is an Events API v2 trigger event
+type IncidentEvent struct {
+	RoutingKey  string          `json:"routing_key"`
+	EventAction string          `json:"event_action"`
+	DedupKey    string          `json:"dedup_key"`
+	Payload     IncidentPayload `json:"payload"`
+}
+
+// IncidentPayload describes the incident of an IncidentEvent
+type IncidentPayload struct {
+	Summary       string                 `json:"summary"`
+	Source        string                 `json:"source"`
+	Severity      string                 `json:"severity"` // critical, error, warning or info
+	Timestamp     string                 `json:"timestamp"`
+	Component     string                 `json:"component"`
+	Class         string                 `json:"class"`
+	CustomDetails map[string]interface{} `json:"custom_details"`
+}
+
+// IncidentChannel triggers incidents through an Events-API-style endpoint.
+// Alerts raised by the same rule for the same API key share a dedup key, so
+// repeated alerts update one open incident.
+type IncidentChannel struct {
+	url        string
+	routingKey string
+	source     string
+	client     *http.Client
+}
+
+// NewIncidentChannel creates an incident channel. An empty url uses
+// DefaultIncidentURL.
+func NewIncidentChannel(url, routingKey, source string, timeout time.Duration) *IncidentChannel {
+	if url == "" {
+		url = DefaultIncidentURL
+	}
+	if source == "" {
+		source = "viva-rate-limiter"
+	}
+
+	return &IncidentChannel{
+		url:        url,
+		routingKey: routingKey,
+		source:     source,
+		client:     newHTTPClient(timeout),
+	}
+}
+
+// Name returns "incident"
+func (c *IncidentChannel) Name() string {
+	return "incident"
+}
+
+// Send triggers an incident for the alert
+func (c *IncidentChannel) Send(ctx context.Context, msg *Message) error {
+	alert := msg.Alert
+
+	dedupKey := "alert:" + alert.ID.String()
+	if alert.RuleID != nil {
+		dedupKey = "rule:" + alert.RuleID.String() + ":" + alert.APIKeyID.String()
+	}
+
+	details := map[string]interface{}{
+		"alert_id": alert.ID.String(),
+		"message":  alert.Message,
+		"body":     msg.Body,
+	}
+	for key, value := range alert.Metadata {
+		details[key] = value
+	}
+
+	event := IncidentEvent{
+		RoutingKey:  c.routingKey,
+		EventAction: "trigger",
+		DedupKey:    dedupKey,
+		Payload: IncidentPayload{
+			Summary:       truncate(msg.Subject, 1024),
+			Source:        c.source,
+			Severity:      incidentSeverity(alert.Severity),
+			Timestamp:     alert.CreatedAt.UTC().Format(time.RFC3339),
+			Component:     "api_key:" + alert.APIKeyID.String(),
+			Class:         string(alert.Type),
+			CustomDetails: details,
+		},
+	}
+
+	if err := postJSON(ctx, c.client, c.url, event, nil); err != nil {
+		return fmt.Errorf("incident delivery failed: %w", err)
+	}
+	return nil
+}
+
+// incidentSeverity maps alert severities onto the Events API severities
+func incidentSeverity(severity models.AlertSeverity) string {
+	switch severity {
+	case models.AlertSeverityCritical:
+		return "critical"
+	case models.AlertSeverityHigh:
+		return "error"
+	case models.AlertSeverityMedium:
+		return "warning"
+	default:
+		return "info"
+	}
+}
+
+// truncate cuts s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
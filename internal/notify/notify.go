@@ -0,0 +1,115 @@
+// Package notify delivers alerts to external channels: signed webhooks, Slack
+// incoming webhooks, SMTP email and Events-API-style incident services.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// defaultTimeout bounds a single delivery attempt when none is configured
+const defaultTimeout = 10 * time.Second
+
+// Message is an alert rendered for delivery
+type Message struct {
+	Alert   *models.Alert
+	Subject string
+	Body    string
+}
+
+// Channel delivers alert messages to one destination
+type Channel interface {
+	// Name identifies the channel in routes and delivery records
+	Name() string
+	// Send delivers a message. Errors wrapped in a PermanentError will fail
+	// again on retry.
+	Send(ctx context.Context, msg *Message) error
+}
+
+// Route sends alerts of at least MinSeverity to a channel
+type Route struct {
+	Channel     Channel
+	MinSeverity models.AlertSeverity
+}
+
+// Accepts returns true if the route delivers alerts of the given severity
+func (r Route) Accepts(severity models.AlertSeverity) bool {
+	return severity.AtLeast(r.MinSeverity)
+}
+
+// PermanentError is a delivery failure that retrying cannot fix, such as a
+// rejected payload or an unknown recipient
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent returns true if err is or wraps a PermanentError
+func IsPermanent(err error) bool {
+	var permanent *PermanentError
+	return errors.As(err, &permanent)
+}
+
+// postJSON posts payload as JSON to url. Responses other than 2xx are errors;
+// 4xx responses other than 408 and 429 are permanent.
+func postJSON(ctx context.Context, client *http.Client, url string, payload interface{}, headers map[string]string) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to encode payload: %w", err)}
+	}
+	return post(ctx, client, url, body, headers)
+}
+
+// post posts a JSON body to url
+func post(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to create request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("unexpected status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+		resp.StatusCode != http.StatusRequestTimeout && resp.StatusCode != http.StatusTooManyRequests {
+		return &PermanentError{Err: err}
+	}
+	return err
+}
+
+// newHTTPClient returns a client whose requests time out after timeout
+func newHTTPClient(timeout time.Duration) *http.Client {
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	return &http.Client{Timeout: timeout}
+}
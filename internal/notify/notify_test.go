@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+func testAlert() *models.Alert {
+	ruleID := uuid.MustParse("6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e01")
+	return &models.Alert{
+		ID:        uuid.MustParse("0d5a4f7e-1c2b-4a3d-8e9f-000000000001"),
+		APIKeyID:  uuid.MustParse("0d5a4f7e-1c2b-4a3d-8e9f-000000000002"),
+		RuleID:    &ruleID,
+		Type:      models.AlertTypeUsageThreshold,
+		Severity:  models.AlertSeverityHigh,
+		Message:   "Usage at 92% of the hourly limit",
+		Metadata:  map[string]interface{}{"usage_percent": 92, "limit": 1000},
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func testMessage(t *testing.T) *Message {
+	renderer, err := NewRenderer(Templates{})
+	require.NoError(t, err)
+	msg, err := renderer.Render(testAlert())
+	require.NoError(t, err)
+	return msg
+}
+
+// recordedRequest is a request captured by a test server
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newRecordingServer(t *testing.T, status int) (*httptest.Server, *[]recordedRequest) {
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, recordedRequest{header: r.Header.Clone(), body: body})
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRenderDefaultTemplates(t *testing.T) {
+	msg := testMessage(t)
+
+	assert.Equal(t, "[HIGH] Usage Threshold alert for API key 0d5a4f7e-1c2b-4a3d-8e9f-000000000002", msg.Subject)
+	assert.Contains(t, msg.Body, "Usage at 92% of the hourly limit")
+	assert.Contains(t, msg.Body, "Raised at: 2026-03-01T12:00:00Z")
+	assert.Contains(t, msg.Body, "limit: 1000\nusage_percent: 92")
+}
+
+func TestRenderCustomTemplates(t *testing.T) {
+	renderer, err := NewRenderer(Templates{
+		Subject: "{{.Severity}}\n{{.Type}}",
+		Body:    "{{.Message}} ({{index .Metadata \"usage_percent\"}})",
+	})
+	require.NoError(t, err)
+
+	msg, err := renderer.Render(testAlert())
+	require.NoError(t, err)
+	assert.Equal(t, "high usage_threshold", msg.Subject)
+	assert.Equal(t, "Usage at 92% of the hourly limit (92)", msg.Body)
+
+	_, err = NewRenderer(Templates{Subject: "{{.Severity"})
+	assert.Error(t, err)
+}
+
+func TestRouteAccepts(t *testing.T) {
+	route := Route{MinSeverity: models.AlertSeverityHigh}
+
+	assert.False(t, route.Accepts(models.AlertSeverityInfo))
+	assert.False(t, route.Accepts(models.AlertSeverityMedium))
+	assert.True(t, route.Accepts(models.AlertSeverityHigh))
+	assert.True(t, route.Accepts(models.AlertSeverityCritical))
+}
+
+func TestWebhookSignsPayload(t *testing.T) {
+	server, requests := newRecordingServer(t, http.StatusOK)
+	channel := NewWebhookChannel(server.URL, "s3cret", time.Second)
+	now := time.Date(2026, 3, 1, 12, 0, 5, 0, time.UTC)
+	channel.now = func() time.Time { return now }
+
+	require.NoError(t, channel.Send(context.Background(), testMessage(t)))
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+
+	assert.Equal(t, "alert.created", req.header.Get(WebhookEventHeader))
+	assert.Equal(t, "0d5a4f7e-1c2b-4a3d-8e9f-000000000001", req.header.Get(WebhookDeliveryHeader))
+
+	timestamp := now.Unix()
+	expected := "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + SignWebhook("s3cret", timestamp, req.body)
+	assert.Equal(t, expected, req.header.Get(WebhookSignatureHeader))
+
+	var payload WebhookPayload
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	assert.Equal(t, "usage_threshold", payload.Type)
+	assert.Equal(t, "high", payload.Severity)
+	assert.NotNil(t, payload.RuleID)
+}
+
+func TestWebhookWithoutSecretIsUnsigned(t *testing.T) {
+	server, requests := newRecordingServer(t, http.StatusNoContent)
+	channel := NewWebhookChannel(server.URL, "", time.Second)
+
+	require.NoError(t, channel.Send(context.Background(), testMessage(t)))
+	require.Len(t, *requests, 1)
+	assert.Empty(t, (*requests)[0].header.Get(WebhookSignatureHeader))
+}
+
+func TestHTTPStatusClassification(t *testing.T) {
+	tests := []struct {
+		status    int
+		permanent bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusNotFound, true},
+		{http.StatusRequestTimeout, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusInternalServerError, false},
+		{http.StatusBadGateway, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			server, _ := newRecordingServer(t, tt.status)
+			err := NewWebhookChannel(server.URL, "", time.Second).Send(context.Background(), testMessage(t))
+
+			require.Error(t, err)
+			assert.Equal(t, tt.permanent, IsPermanent(err))
+			assert.Contains(t, err.Error(), strconv.Itoa(tt.status))
+		})
+	}
+}
+
+func TestSlackPayload(t *testing.T) {
+	server, requests := newRecordingServer(t, http.StatusOK)
+	channel := NewSlackChannel(server.URL, "#alerts", time.Second)
+
+	msg := testMessage(t)
+	require.NoError(t, channel.Send(context.Background(), msg))
+	require.Len(t, *requests, 1)
+
+	var payload SlackPayload
+	require.NoError(t, json.Unmarshal((*requests)[0].body, &payload))
+	assert.Equal(t, "#alerts", payload.Channel)
+	assert.Equal(t, msg.Subject, payload.Text)
+	require.Len(t, payload.Attachments, 1)
+	assert.Equal(t, slackColors[models.AlertSeverityHigh], payload.Attachments[0].Color)
+	assert.Equal(t, msg.Body, payload.Attachments[0].Text)
+	assert.Equal(t, msg.Alert.CreatedAt.Unix(), payload.Attachments[0].Ts)
+}
+
+func TestIncidentPayload(t *testing.T) {
+	server, requests := newRecordingServer(t, http.StatusAccepted)
+	channel := NewIncidentChannel(server.URL, "routing-key", "", time.Second)
+
+	msg := testMessage(t)
+	require.NoError(t, channel.Send(context.Background(), msg))
+
+	// Alerts without a rule are deduplicated by alert
+	msg.Alert.RuleID = nil
+	msg.Alert.Severity = models.AlertSeverityCritical
+	require.NoError(t, channel.Send(context.Background(), msg))
+	require.Len(t, *requests, 2)
+
+	var ruled, unruled IncidentEvent
+	require.NoError(t, json.Unmarshal((*requests)[0].body, &ruled))
+	require.NoError(t, json.Unmarshal((*requests)[1].body, &unruled))
+
+	assert.Equal(t, "routing-key", ruled.RoutingKey)
+	assert.Equal(t, "trigger", ruled.EventAction)
+	assert.Equal(t, "rule:6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e01:0d5a4f7e-1c2b-4a3d-8e9f-000000000002", ruled.DedupKey)
+	assert.Equal(t, "error", ruled.Payload.Severity)
+	assert.Equal(t, "viva-rate-limiter", ruled.Payload.Source)
+	assert.Equal(t, "2026-03-01T12:00:00Z", ruled.Payload.Timestamp)
+	assert.EqualValues(t, 92, ruled.Payload.CustomDetails["usage_percent"])
+
+	assert.Equal(t, "alert:0d5a4f7e-1c2b-4a3d-8e9f-000000000001", unruled.DedupKey)
+	assert.Equal(t, "critical", unruled.Payload.Severity)
+}
+
+func TestEmailCompose(t *testing.T) {
+	channel := NewEmailChannel("smtp.example.com", 0, "", "", "alerts@example.com", []string{"ops@example.com", "oncall@example.com"}, time.Second)
+	channel.now = func() time.Time { return time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC) }
+
+	var sent []byte
+	var sentTo []string
+	channel.sendMail = func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error {
+		assert.Equal(t, "smtp.example.com:587", addr)
+		assert.Nil(t, auth)
+		assert.Equal(t, "alerts@example.com", from)
+		sentTo = to
+		sent = msg
+		return nil
+	}
+
+	msg := testMessage(t)
+	require.NoError(t, channel.Send(context.Background(), msg))
+	assert.Equal(t, []string{"ops@example.com", "oncall@example.com"}, sentTo)
+
+	raw := string(sent)
+	headers, body, found := strings.Cut(raw, "\r\n\r\n")
+	require.True(t, found)
+	assert.Contains(t, headers, "To: ops@example.com, oncall@example.com\r\n")
+	assert.Contains(t, headers, "Subject: "+msg.Subject+"\r\n")
+	assert.Contains(t, headers, "Date: Sun, 01 Mar 2026 12:00:00 +0000\r\n")
+	assert.Contains(t, headers, "Message-ID: <alert-0d5a4f7e-1c2b-4a3d-8e9f-000000000001@smtp.example.com>")
+	assert.NotContains(t, strings.ReplaceAll(body, "\r\n", ""), "\n")
+}
+
+func TestEmailErrorClassification(t *testing.T) {
+	channel := NewEmailChannel("smtp.example.com", 25, "", "", "alerts@example.com", []string{"ops@example.com"}, time.Second)
+
+	channel.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return &textproto.Error{Code: 550, Msg: "mailbox unavailable"}
+	}
+	err := channel.Send(context.Background(), testMessage(t))
+	require.Error(t, err)
+	assert.True(t, IsPermanent(err))
+
+	channel.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		return &textproto.Error{Code: 421, Msg: "try again later"}
+	}
+	err = channel.Send(context.Background(), testMessage(t))
+	require.Error(t, err)
+	assert.False(t, IsPermanent(err))
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// slackColors are the attachment colors by severity
+var slackColors = map[models.AlertSeverity]string{
+	models.AlertSeverityInfo:     "#439FE0",
+	models.AlertSeverityLow:      "#36A64F",
+	models.AlertSeverityMedium:   "#ECB22E",
+	models.AlertSeverityHigh:     "#E8793B",
+	models.AlertSeverityCritical: "#D0021B",
+}
+
+// SlackPayload is the JSON body of a Slack incoming webhook message
+type SlackPayload struct {
+	Channel     string            `json:"channel,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []SlackAttachment `json:"attachments"`
+}
+
+// SlackAttachment is a colored block of a Slack message
+type SlackAttachment struct {
+	Color    string       `json:"color"`
+	Title    string       `json:"title"`
+	Text     string       `json:"text"`
+	Fields   []SlackField `json:"fields"`
+	Footer   string       `json:"footer"`
+	Ts       int64        `json:"ts"`
+	Fallback string       `json:"fallback"`
+}
+
+// SlackField is a short key and value shown in an attachment
+type SlackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// SlackChannel posts alerts to a Slack incoming webhook. Channel overrides the
+// webhook's default channel when set.
+type SlackChannel struct {
+	url     string
+	channel string
+	client  *http.Client
+}
+
+// NewSlackChannel creates a Slack channel
+func NewSlackChannel(url, channel string, timeout time.Duration) *SlackChannel {
+	return &SlackChannel{
+		url:     url,
+		channel: channel,
+		client:  newHTTPClient(timeout),
+	}
+}
+
+// Name returns "slack"
+func (c *SlackChannel) Name() string {
+	return "slack"
+}
+
+// Send posts the alert to the Slack webhook
+func (c *SlackChannel) Send(ctx context.Context, msg *Message) error {
+	alert := msg.Alert
+	payload := SlackPayload{
+		Channel: c.channel,
+		Text:    msg.Subject,
+		Attachments: []SlackAttachment{{
+			Color: slackColors[alert.Severity],
+			Title: alert.Message,
+			Text:  msg.Body,
+			Fields: []SlackField{
+				{Title: "Severity", Value: string(alert.Severity), Short: true},
+				{Title: "Type", Value: string(alert.Type), Short: true},
+				{Title: "API key", Value: alert.APIKeyID.String(), Short: false},
+			},
+			Footer:   "Alert " + alert.ID.String(),
+			Ts:       alert.CreatedAt.Unix(),
+			Fallback: msg.Subject,
+		}},
+	}
+
+	if err := postJSON(ctx, c.client, c.url, payload, nil); err != nil {
+		return fmt.Errorf("slack delivery failed: %w", err)
+	}
+	return nil
+}
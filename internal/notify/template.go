@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// Templates are text/template sources for the subject and body of alert
+// messages. Templates execute on the *models.Alert and may use the functions
+// upper, title, time (RFC3339 in UTC) and metadata (sorted "key: value" lines).
+type Templates struct {
+	Subject string
+	Body    string
+}
+
+// DefaultTemplates returns the built-in alert message templates
+func DefaultTemplates() Templates {
+	return Templates{
+		Subject: `[{{upper (print .Severity)}}] {{title (print .Type)}} alert for API key {{.APIKeyID}}`,
+		Body: `{{.Message}}
+
+Alert: {{.ID}}
+Severity: {{.Severity}}
+Raised at: {{time .CreatedAt}}
+{{- with metadata .Metadata}}
+
+{{.}}{{end}}`,
+	}
+}
+
+// Renderer renders alerts into messages
+type Renderer struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// NewRenderer parses templates. Empty templates fall back to DefaultTemplates.
+func NewRenderer(templates Templates) (*Renderer, error) {
+	defaults := DefaultTemplates()
+	if templates.Subject == "" {
+		templates.Subject = defaults.Subject
+	}
+	if templates.Body == "" {
+		templates.Body = defaults.Body
+	}
+
+	subject, err := template.New("subject").Funcs(templateFuncs).Parse(templates.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject template: %w", err)
+	}
+	body, err := template.New("body").Funcs(templateFuncs).Parse(templates.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	return &Renderer{subject: subject, body: body}, nil
+}
+
+// Render renders an alert into a message
+func (r *Renderer) Render(alert *models.Alert) (*Message, error) {
+	var subject, body bytes.Buffer
+	if err := r.subject.Execute(&subject, alert); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := r.body.Execute(&body, alert); err != nil {
+		return nil, fmt.Errorf("failed to render body: %w", err)
+	}
+
+	return &Message{
+		Alert: alert,
+		// Subjects end up in mail headers, which must be a single line
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Body:    strings.TrimSpace(body.String()),
+	}, nil
+}
+
+var templateFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"title": func(s string) string {
+		words := strings.Fields(strings.ReplaceAll(s, "_", " "))
+		for i, word := range words {
+			words[i] = strings.ToUpper(word[:1]) + word[1:]
+		}
+		return strings.Join(words, " ")
+	},
+	"time": func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	},
+	"metadata": func(metadata map[string]interface{}) string {
+		keys := make([]string, 0, len(metadata))
+		for key := range metadata {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		lines := make([]string, len(keys))
+		for i, key := range keys {
+			lines[i] = fmt.Sprintf("%s: %v", key, metadata[key])
+		}
+		return strings.Join(lines, "\n")
+	},
+}
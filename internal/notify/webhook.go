@@ -0,0 +1,114 @@
+package notify
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook headers
+const (
+	WebhookEventHeader     = "X-Viva-Event"
+	WebhookDeliveryHeader  = "X-Viva-Delivery"
+	WebhookSignatureHeader = "X-Viva-Signature"
+)
+
+// WebhookPayload is the JSON body of a generic webhook
+type WebhookPayload struct {
+	Event     string                 `json:"event"` // alert.created or alert.escalated
+	AlertID   uuid.UUID              `json:"alert_id"`
+	APIKeyID  uuid.UUID              `json:"api_key_id"`
+	RuleID    *uuid.UUID             `json:"rule_id,omitempty"`
+	Type      string                 `json:"type"`
+	Severity  string                 `json:"severity"`
+	Status    string                 `json:"status"`
+	Subject   string                 `json:"subject"`
+	Message   string                 `json:"message"`
+	Body      string                 `json:"body"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+// WebhookChannel posts alerts as JSON to a URL. With a secret, every request
+// is signed: the X-Viva-Signature header is "t=<unix time>,v1=<signature>",
+// where the signature is the hex HMAC-SHA256 of "<unix time>.<body>".
+type WebhookChannel struct {
+	url    string
+	secret string
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewWebhookChannel creates a webhook channel
+func NewWebhookChannel(url, secret string, timeout time.Duration) *WebhookChannel {
+	return &WebhookChannel{
+		url:    url,
+		secret: secret,
+		client: newHTTPClient(timeout),
+		now:    time.Now,
+	}
+}
+
+// Name returns "webhook"
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+// Send posts the alert to the webhook URL
+func (c *WebhookChannel) Send(ctx context.Context, msg *Message) error {
+	alert := msg.Alert
+	event := "alert.created"
+	if alert.EscalationLevel > 0 {
+		event = "alert.escalated"
+	}
+
+	body, err := json.Marshal(WebhookPayload{
+		Event:     event,
+		AlertID:   alert.ID,
+		APIKeyID:  alert.APIKeyID,
+		RuleID:    alert.RuleID,
+		Type:      string(alert.Type),
+		Severity:  string(alert.Severity),
+		Status:    string(alert.Status),
+		Subject:   msg.Subject,
+		Message:   alert.Message,
+		Body:      msg.Body,
+		Metadata:  alert.Metadata,
+		CreatedAt: alert.CreatedAt.UTC(),
+	})
+	if err != nil {
+		return &PermanentError{Err: fmt.Errorf("failed to encode payload: %w", err)}
+	}
+
+	headers := map[string]string{
+		WebhookEventHeader:    event,
+		WebhookDeliveryHeader: alert.ID.String(),
+	}
+	if c.secret != "" {
+		timestamp := c.now().Unix()
+		headers[WebhookSignatureHeader] = fmt.Sprintf("t=%d,v1=%s", timestamp, SignWebhook(c.secret, timestamp, body))
+	}
+
+	if err := post(ctx, c.client, c.url, body, headers); err != nil {
+		return fmt.Errorf("webhook delivery failed: %w", err)
+	}
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 signature of a webhook body sent at
+// timestamp
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
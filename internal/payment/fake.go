@@ -0,0 +1,218 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FakeCharge is a charge made through the fake provider
+type FakeCharge struct {
+	ID             string
+	CustomerID     string
+	RecordID       uuid.UUID
+	Amount         float64
+	AmountRefunded float64
+	Currency       string
+	Status         ChargeStatus
+	FailureReason  string
+}
+
+// FakeProvider is an in-memory provider for tests and local development.
+// Charges succeed unless their customer was set to decline. Every charge and
+// refund queues the webhook event a real provider would send; Webhook signs
+// one the way the Stripe provider expects.
+type FakeProvider struct {
+	mu            sync.Mutex
+	webhookSecret string
+	now           func() time.Time
+	nextID        int
+	customers     map[string]*Customer
+	charges       map[string]*FakeCharge
+	chargeKeys    map[string]string
+	declines      map[string]string
+	events        []*Event
+}
+
+// NewFakeProvider creates a fake provider signing webhooks with a secret
+func NewFakeProvider(webhookSecret string) *FakeProvider {
+	return &FakeProvider{
+		webhookSecret: webhookSecret,
+		now:           time.Now,
+		customers:     make(map[string]*Customer),
+		charges:       make(map[string]*FakeCharge),
+		chargeKeys:    make(map[string]string),
+		declines:      make(map[string]string),
+	}
+}
+
+// SetClock replaces the time source of events and signatures
+func (p *FakeProvider) SetClock(now func() time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.now = now
+}
+
+// Name returns "fake"
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// CreateCustomer stores a customer
+func (p *FakeProvider) CreateCustomer(ctx context.Context, customer *Customer) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	id := p.newID("cus")
+	stored := *customer
+	p.customers[id] = &stored
+	return id, nil
+}
+
+// Decline makes every later charge of a customer fail with a reason. An empty
+// reason lets charges succeed again.
+func (p *FakeProvider) Decline(customerID, reason string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if reason == "" {
+		delete(p.declines, customerID)
+		return
+	}
+	p.declines[customerID] = reason
+}
+
+// ChargeInvoice charges a known customer. A repeated idempotency key returns
+// the charge it made.
+func (p *FakeProvider) ChargeInvoice(ctx context.Context, req *ChargeRequest) (*Charge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.customers[req.CustomerID]; !ok {
+		return nil, fmt.Errorf("failed to charge invoice %s: no such customer: %s", req.InvoiceNumber, req.CustomerID)
+	}
+	if id, ok := p.chargeKeys[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		charge := p.charges[id]
+		return &Charge{ID: charge.ID, Status: charge.Status, FailureReason: charge.FailureReason}, nil
+	}
+
+	charge := &FakeCharge{
+		ID:         p.newID("ch"),
+		CustomerID: req.CustomerID,
+		RecordID:   req.RecordID,
+		Amount:     req.Amount,
+		Currency:   strings.ToUpper(req.Currency),
+		Status:     ChargeStatusSucceeded,
+	}
+	eventType := EventChargeSucceeded
+	if reason, ok := p.declines[req.CustomerID]; ok {
+		charge.Status = ChargeStatusFailed
+		charge.FailureReason = reason
+		eventType = EventChargeFailed
+	}
+	p.charges[charge.ID] = charge
+	if req.IdempotencyKey != "" {
+		p.chargeKeys[req.IdempotencyKey] = charge.ID
+	}
+	p.queueEvent(eventType, charge)
+
+	return &Charge{ID: charge.ID, Status: charge.Status, FailureReason: charge.FailureReason}, nil
+}
+
+// Refund refunds part or all of a succeeded charge
+func (p *FakeProvider) Refund(ctx context.Context, chargeID string, amount float64, currency string) (*Refund, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charge, ok := p.charges[chargeID]
+	if !ok {
+		return nil, fmt.Errorf("failed to refund charge %s: no such charge", chargeID)
+	}
+	if charge.Status != ChargeStatusSucceeded {
+		return nil, fmt.Errorf("failed to refund charge %s: charge %s", chargeID, charge.Status)
+	}
+	if toMinorUnits(charge.AmountRefunded+amount, charge.Currency) > toMinorUnits(charge.Amount, charge.Currency) {
+		return nil, fmt.Errorf("failed to refund charge %s: amount exceeds the unrefunded %.2f", chargeID, charge.Amount-charge.AmountRefunded)
+	}
+
+	charge.AmountRefunded += amount
+	p.queueEvent(EventChargeRefunded, charge)
+	return &Refund{ID: p.newID("re"), ChargeID: chargeID, Amount: amount, Status: "succeeded"}, nil
+}
+
+// ParseWebhook verifies and decodes a webhook signed by Webhook
+func (p *FakeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	p.mu.Lock()
+	now := p.now()
+	p.mu.Unlock()
+
+	if err := verifyStripeSignature(p.webhookSecret, header.Get(StripeSignatureHeader), payload, now, defaultWebhookTolerance); err != nil {
+		return nil, err
+	}
+	return parseStripeEvent(payload)
+}
+
+// Webhook encodes an event as a signed webhook request body and headers
+func (p *FakeProvider) Webhook(event *Event) ([]byte, http.Header, error) {
+	payload, err := encodeStripeEvent(event)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p.mu.Lock()
+	now := p.now()
+	p.mu.Unlock()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(StripeSignatureHeader, SignStripePayload(p.webhookSecret, now, payload))
+	return payload, header, nil
+}
+
+// Events returns and clears the events queued since the last call
+func (p *FakeProvider) Events() []*Event {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	events := p.events
+	p.events = nil
+	return events
+}
+
+// Charges returns a copy of every charge made
+func (p *FakeProvider) Charges() []FakeCharge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	charges := make([]FakeCharge, 0, len(p.charges))
+	for _, charge := range p.charges {
+		charges = append(charges, *charge)
+	}
+	return charges
+}
+
+// queueEvent records the event a provider would send about a charge
+func (p *FakeProvider) queueEvent(eventType EventType, charge *FakeCharge) {
+	p.events = append(p.events, &Event{
+		ID:             p.newID("evt"),
+		Type:           eventType,
+		ChargeID:       charge.ID,
+		RecordID:       charge.RecordID,
+		Amount:         charge.Amount,
+		AmountRefunded: charge.AmountRefunded,
+		Currency:       charge.Currency,
+		FailureReason:  charge.FailureReason,
+		CreatedAt:      p.now().UTC().Truncate(time.Second),
+	})
+}
+
+// newID returns a provider-style ID with a prefix
+func (p *FakeProvider) newID(prefix string) string {
+	p.nextID++
+	return fmt.Sprintf("%s_fake%06d", prefix, p.nextID)
+}
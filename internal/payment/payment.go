@@ -0,0 +1,170 @@
+// Package payment collects invoice payments through a payment provider: a
+// Stripe-compatible API or an in-memory fake. Providers create customers,
+// charge and refund invoices, and report payment outcomes through signed
+// webhooks.
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+)
+
+// defaultTimeout bounds a single provider request when none is configured
+const defaultTimeout = 30 * time.Second
+
+// defaultWebhookTolerance is how old a webhook signature may be when none is
+// configured
+const defaultWebhookTolerance = 5 * time.Minute
+
+// ErrInvalidSignature is returned for webhooks whose signature is missing,
+// wrong or too old
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrInvalidPayload is returned for webhooks whose body is not an event
+var ErrInvalidPayload = errors.New("invalid webhook payload")
+
+// ChargeStatus represents the outcome of a charge
+type ChargeStatus string
+
+const (
+	ChargeStatusSucceeded ChargeStatus = "succeeded"
+	ChargeStatusPending   ChargeStatus = "pending"
+	ChargeStatusFailed    ChargeStatus = "failed"
+)
+
+// EventType represents what a webhook event reports
+type EventType string
+
+const (
+	EventChargeSucceeded EventType = "charge.succeeded"
+	EventChargeFailed    EventType = "charge.failed"
+	EventChargeRefunded  EventType = "charge.refunded"
+)
+
+// Customer is the payer of an API key's invoices
+type Customer struct {
+	APIKeyID uuid.UUID
+	Name     string
+	Email    string
+}
+
+// ChargeRequest charges a customer for the invoice of a billing record.
+// Requests with the same IdempotencyKey charge at most once.
+type ChargeRequest struct {
+	CustomerID     string
+	RecordID       uuid.UUID
+	InvoiceNumber  string
+	Amount         float64
+	Currency       string
+	IdempotencyKey string
+}
+
+// Charge is the result of charging an invoice. A pending charge is settled
+// later by a webhook event.
+type Charge struct {
+	ID            string
+	Status        ChargeStatus
+	FailureReason string
+}
+
+// Refund is the result of refunding a charge
+type Refund struct {
+	ID       string
+	ChargeID string
+	Amount   float64
+	Status   string
+}
+
+// Event is a payment outcome reported by a provider webhook. RecordID is the
+// billing record the charge was made for, or uuid.Nil when the charge was not
+// made by this service. AmountRefunded is the total refunded of the charge so
+// far.
+type Event struct {
+	ID             string
+	Type           EventType
+	ChargeID       string
+	RecordID       uuid.UUID
+	Amount         float64
+	AmountRefunded float64
+	Currency       string
+	FailureReason  string
+	CreatedAt      time.Time
+}
+
+// FullyRefunded returns true if the whole charge has been refunded
+func (e *Event) FullyRefunded() bool {
+	return e.Amount > 0 && e.AmountRefunded >= e.Amount
+}
+
+// Provider collects payments
+type Provider interface {
+	// Name identifies the provider in stored customers and events
+	Name() string
+	// CreateCustomer registers a payer and returns the provider's customer ID
+	CreateCustomer(ctx context.Context, customer *Customer) (string, error)
+	// ChargeInvoice charges a customer. A declined charge is returned with
+	// ChargeStatusFailed rather than as an error.
+	ChargeInvoice(ctx context.Context, req *ChargeRequest) (*Charge, error)
+	// Refund refunds an amount of a charge, in the charge's currency
+	Refund(ctx context.Context, chargeID string, amount float64, currency string) (*Refund, error)
+	// ParseWebhook verifies the signature of a webhook request and decodes
+	// its event. Signature failures return ErrInvalidSignature and
+	// undecodable bodies ErrInvalidPayload.
+	ParseWebhook(payload []byte, header http.Header) (*Event, error)
+}
+
+// ProviderFromConfig builds the configured provider. It returns nil when no
+// provider is configured, which disables payments.
+func ProviderFromConfig(cfg *config.PaymentsConfig) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "stripe":
+		if cfg.SecretKey == "" || cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("payments.secret_key and payments.webhook_secret are required for stripe")
+		}
+		provider := NewStripeProvider(cfg.APIURL, cfg.SecretKey, cfg.WebhookSecret, cfg.Timeout)
+		if cfg.WebhookTolerance > 0 {
+			provider.tolerance = cfg.WebhookTolerance
+		}
+		return provider, nil
+	case "fake":
+		if cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("payments.webhook_secret is required for the fake provider")
+		}
+		return NewFakeProvider(cfg.WebhookSecret), nil
+	}
+	return nil, fmt.Errorf("unknown payment provider: %s", cfg.Provider)
+}
+
+// zeroDecimalCurrencies are charged in whole units rather than cents
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// toMinorUnits converts an amount to the smallest unit of its currency
+func toMinorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// fromMinorUnits converts an amount in the smallest unit of its currency
+func fromMinorUnits(amount int64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
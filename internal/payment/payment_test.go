@@ -0,0 +1,202 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/config"
+)
+
+func TestStripeProvider_ChargeInvoice(t *testing.T) {
+	recordID := uuid.New()
+	var form map[string]string
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		header = r.Header
+		form = map[string]string{}
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+
+		if form["customer"] == "cus_declined" {
+			w.WriteHeader(http.StatusPaymentRequired)
+			w.Write([]byte(`{"error":{"type":"card_error","code":"card_declined","message":"Your card was declined.","charge":"ch_declined"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"ch_123","status":"succeeded","amount":4999,"currency":"usd"}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider(server.URL, "sk_test", "whsec_test", time.Second)
+	req := &ChargeRequest{
+		CustomerID:     "cus_123",
+		RecordID:       recordID,
+		InvoiceNumber:  "INV-202404-ABCDEF12",
+		Amount:         49.99,
+		Currency:       "USD",
+		IdempotencyKey: "invoice-1",
+	}
+
+	charge, err := provider.ChargeInvoice(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, &Charge{ID: "ch_123", Status: ChargeStatusSucceeded}, charge)
+	assert.Equal(t, "Bearer sk_test", header.Get("Authorization"))
+	assert.Equal(t, "invoice-1", header.Get("Idempotency-Key"))
+	assert.Equal(t, "4999", form["amount"])
+	assert.Equal(t, "usd", form["currency"])
+	assert.Equal(t, recordID.String(), form["metadata[billing_record_id]"])
+
+	req.CustomerID = "cus_declined"
+	charge, err = provider.ChargeInvoice(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, &Charge{ID: "ch_declined", Status: ChargeStatusFailed, FailureReason: "Your card was declined."}, charge)
+}
+
+func TestStripeProvider_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"type":"invalid_request_error","message":"Invalid API Key provided"}}`))
+	}))
+	defer server.Close()
+
+	provider := NewStripeProvider(server.URL, "sk_wrong", "whsec_test", time.Second)
+	_, err := provider.ChargeInvoice(context.Background(), &ChargeRequest{CustomerID: "cus_123", Amount: 10, Currency: "USD"})
+	require.Error(t, err)
+
+	var stripeErr *StripeError
+	require.ErrorAs(t, err, &stripeErr)
+	assert.Equal(t, http.StatusUnauthorized, stripeErr.StatusCode)
+	assert.Equal(t, "Invalid API Key provided", stripeErr.Message)
+}
+
+func TestStripeProvider_ParseWebhook(t *testing.T) {
+	now := time.Date(2024, 4, 20, 12, 0, 0, 0, time.UTC)
+	provider := NewStripeProvider("", "sk_test", "whsec_test", 0)
+	provider.now = func() time.Time { return now }
+
+	recordID := uuid.New()
+	payload, err := json.Marshal(map[string]interface{}{
+		"id":      "evt_1",
+		"type":    "charge.refunded",
+		"created": now.Unix(),
+		"data": map[string]interface{}{
+			"object": map[string]interface{}{
+				"id":              "ch_1",
+				"amount":          5000,
+				"amount_refunded": 5000,
+				"currency":        "jpy",
+				"metadata":        map[string]string{"billing_record_id": recordID.String()},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name      string
+		signature string
+		wantErr   error
+	}{
+		{"valid", SignStripePayload("whsec_test", now, payload), nil},
+		{"rotated secret", SignStripePayload("whsec_old", now, payload) + ",v1=" + stripeSignature("whsec_test", now.Unix(), payload), nil},
+		{"wrong secret", SignStripePayload("whsec_other", now, payload), ErrInvalidSignature},
+		{"too old", SignStripePayload("whsec_test", now.Add(-6*time.Minute), payload), ErrInvalidSignature},
+		{"missing", "", ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(StripeSignatureHeader, tt.signature)
+
+			event, err := provider.ParseWebhook(payload, header)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, EventChargeRefunded, event.Type)
+			assert.Equal(t, "ch_1", event.ChargeID)
+			assert.Equal(t, recordID, event.RecordID)
+			assert.Equal(t, 5000.0, event.Amount, "JPY has no minor unit")
+			assert.True(t, event.FullyRefunded())
+		})
+	}
+
+	header := http.Header{}
+	header.Set(StripeSignatureHeader, SignStripePayload("whsec_test", now, []byte("not json")))
+	_, err = provider.ParseWebhook([]byte("not json"), header)
+	assert.ErrorIs(t, err, ErrInvalidPayload)
+}
+
+func TestFakeProvider(t *testing.T) {
+	ctx := context.Background()
+	provider := NewFakeProvider("whsec_test")
+
+	customerID, err := provider.CreateCustomer(ctx, &Customer{APIKeyID: uuid.New(), Name: "Test key"})
+	require.NoError(t, err)
+
+	req := &ChargeRequest{CustomerID: customerID, RecordID: uuid.New(), Amount: 20, Currency: "USD", IdempotencyKey: "invoice-1"}
+	charge, err := provider.ChargeInvoice(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, ChargeStatusSucceeded, charge.Status)
+
+	again, err := provider.ChargeInvoice(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, charge.ID, again.ID, "a repeated idempotency key returns the same charge")
+	assert.Len(t, provider.Charges(), 1)
+
+	_, err = provider.Refund(ctx, charge.ID, 15, "USD")
+	require.NoError(t, err)
+	_, err = provider.Refund(ctx, charge.ID, 10, "USD")
+	assert.Error(t, err, "refunds cannot exceed the charge")
+
+	provider.Decline(customerID, "insufficient funds")
+	req.IdempotencyKey = "invoice-2"
+	declined, err := provider.ChargeInvoice(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, ChargeStatusFailed, declined.Status)
+	assert.Equal(t, "insufficient funds", declined.FailureReason)
+
+	events := provider.Events()
+	require.Len(t, events, 3)
+	assert.Equal(t, EventChargeSucceeded, events[0].Type)
+	assert.Equal(t, EventChargeRefunded, events[1].Type)
+	assert.Equal(t, 15.0, events[1].AmountRefunded)
+	assert.Equal(t, EventChargeFailed, events[2].Type)
+	assert.Empty(t, provider.Events())
+
+	// Webhooks round-trip through the Stripe wire format
+	payload, header, err := provider.Webhook(events[2])
+	require.NoError(t, err)
+	parsed, err := provider.ParseWebhook(payload, header)
+	require.NoError(t, err)
+	assert.Equal(t, events[2], parsed)
+}
+
+func TestProviderFromConfig(t *testing.T) {
+	provider, err := ProviderFromConfig(&config.PaymentsConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, provider)
+
+	_, err = ProviderFromConfig(&config.PaymentsConfig{Provider: "stripe", SecretKey: "sk_test"})
+	assert.Error(t, err)
+
+	provider, err = ProviderFromConfig(&config.PaymentsConfig{Provider: "stripe", SecretKey: "sk_test", WebhookSecret: "whsec", WebhookTolerance: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, provider.(*StripeProvider).tolerance)
+
+	provider, err = ProviderFromConfig(&config.PaymentsConfig{Provider: "fake", WebhookSecret: "whsec"})
+	require.NoError(t, err)
+	assert.Equal(t, "fake", provider.Name())
+
+	_, err = ProviderFromConfig(&config.PaymentsConfig{Provider: "paypal"})
+	assert.EqualError(t, err, "unknown payment provider: paypal")
+}
@@ -0,0 +1,336 @@
+package payment
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultStripeURL is the base URL of the Stripe API
+const DefaultStripeURL = "https://api.stripe.com"
+
+// StripeSignatureHeader carries the signature of Stripe webhooks:
+// "t=<unix time>,v1=<signature>", where the signature is the hex
+// HMAC-SHA256 of "<unix time>.<body>"
+const StripeSignatureHeader = "Stripe-Signature"
+
+// Metadata keys tagging customers and charges with what they belong to
+const (
+	recordMetadataKey  = "billing_record_id"
+	invoiceMetadataKey = "invoice_number"
+	apiKeyMetadataKey  = "api_key_id"
+)
+
+// StripeError is an error response of the Stripe API. Declined charges are
+// card errors naming the failed charge.
+type StripeError struct {
+	StatusCode int    `json:"-"`
+	Type       string `json:"type"`
+	Code       string `json:"code"`
+	Message    string `json:"message"`
+	Charge     string `json:"charge"`
+}
+
+func (e *StripeError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("stripe error %d (%s): %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("stripe error %d: %s", e.StatusCode, e.Message)
+}
+
+// StripeProvider collects payments through the Stripe API, or any API
+// compatible with its customers, charges and refunds endpoints and webhooks.
+// Customers are charged with their default payment source.
+type StripeProvider struct {
+	baseURL       string
+	secretKey     string
+	webhookSecret string
+	tolerance     time.Duration
+	client        *http.Client
+	now           func() time.Time
+}
+
+// NewStripeProvider creates a Stripe provider. An empty baseURL uses
+// DefaultStripeURL.
+func NewStripeProvider(baseURL, secretKey, webhookSecret string, timeout time.Duration) *StripeProvider {
+	if baseURL == "" {
+		baseURL = DefaultStripeURL
+	}
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+
+	return &StripeProvider{
+		baseURL:       strings.TrimRight(baseURL, "/"),
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		tolerance:     defaultWebhookTolerance,
+		client:        &http.Client{Timeout: timeout},
+		now:           time.Now,
+	}
+}
+
+// Name returns "stripe"
+func (p *StripeProvider) Name() string {
+	return "stripe"
+}
+
+// CreateCustomer creates a Stripe customer tagged with the API key
+func (p *StripeProvider) CreateCustomer(ctx context.Context, customer *Customer) (string, error) {
+	form := url.Values{}
+	form.Set("name", customer.Name)
+	if customer.Email != "" {
+		form.Set("email", customer.Email)
+	}
+	form.Set("metadata["+apiKeyMetadataKey+"]", customer.APIKeyID.String())
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := p.post(ctx, "/v1/customers", form, "customer-"+customer.APIKeyID.String(), &created); err != nil {
+		return "", fmt.Errorf("failed to create customer: %w", err)
+	}
+	return created.ID, nil
+}
+
+// ChargeInvoice charges the customer's default payment source
+func (p *StripeProvider) ChargeInvoice(ctx context.Context, req *ChargeRequest) (*Charge, error) {
+	form := url.Values{}
+	form.Set("amount", strconv.FormatInt(toMinorUnits(req.Amount, req.Currency), 10))
+	form.Set("currency", strings.ToLower(req.Currency))
+	form.Set("customer", req.CustomerID)
+	form.Set("description", "Invoice "+req.InvoiceNumber)
+	form.Set("metadata["+recordMetadataKey+"]", req.RecordID.String())
+	form.Set("metadata["+invoiceMetadataKey+"]", req.InvoiceNumber)
+
+	var charge stripeCharge
+	err := p.post(ctx, "/v1/charges", form, req.IdempotencyKey, &charge)
+	if err != nil {
+		var stripeErr *StripeError
+		if errors.As(err, &stripeErr) && stripeErr.Type == "card_error" {
+			return &Charge{ID: stripeErr.Charge, Status: ChargeStatusFailed, FailureReason: stripeErr.Message}, nil
+		}
+		return nil, fmt.Errorf("failed to charge invoice %s: %w", req.InvoiceNumber, err)
+	}
+
+	result := &Charge{ID: charge.ID, Status: ChargeStatus(charge.Status), FailureReason: charge.FailureMessage}
+	if result.Status != ChargeStatusSucceeded && result.Status != ChargeStatusFailed {
+		result.Status = ChargeStatusPending
+	}
+	return result, nil
+}
+
+// Refund refunds an amount of a charge
+func (p *StripeProvider) Refund(ctx context.Context, chargeID string, amount float64, currency string) (*Refund, error) {
+	form := url.Values{}
+	form.Set("charge", chargeID)
+	form.Set("amount", strconv.FormatInt(toMinorUnits(amount, currency), 10))
+
+	var refund struct {
+		ID     string `json:"id"`
+		Charge string `json:"charge"`
+		Amount int64  `json:"amount"`
+		Status string `json:"status"`
+	}
+	// A refund of the same amount is only made once
+	key := fmt.Sprintf("refund-%s-%d", chargeID, toMinorUnits(amount, currency))
+	if err := p.post(ctx, "/v1/refunds", form, key, &refund); err != nil {
+		return nil, fmt.Errorf("failed to refund charge %s: %w", chargeID, err)
+	}
+	return &Refund{
+		ID:       refund.ID,
+		ChargeID: refund.Charge,
+		Amount:   fromMinorUnits(refund.Amount, currency),
+		Status:   refund.Status,
+	}, nil
+}
+
+// ParseWebhook verifies the Stripe-Signature header and decodes a charge
+// event
+func (p *StripeProvider) ParseWebhook(payload []byte, header http.Header) (*Event, error) {
+	if err := verifyStripeSignature(p.webhookSecret, header.Get(StripeSignatureHeader), payload, p.now(), p.tolerance); err != nil {
+		return nil, err
+	}
+	return parseStripeEvent(payload)
+}
+
+// post sends a form-encoded request and decodes the JSON response into out
+func (p *StripeProvider) post(ctx context.Context, path string, form url.Values, idempotencyKey string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.secretKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var errorBody struct {
+			Error StripeError `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errorBody); err != nil || errorBody.Error.Message == "" {
+			errorBody.Error.Message = strings.TrimSpace(string(body))
+		}
+		errorBody.Error.StatusCode = resp.StatusCode
+		return &errorBody.Error
+	}
+	return json.Unmarshal(body, out)
+}
+
+// stripeCharge is a charge object of the Stripe API
+type stripeCharge struct {
+	ID             string            `json:"id"`
+	Amount         int64             `json:"amount"`
+	AmountRefunded int64             `json:"amount_refunded"`
+	Currency       string            `json:"currency"`
+	Status         string            `json:"status"`
+	FailureMessage string            `json:"failure_message"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// stripeEvent is a webhook event of the Stripe API
+type stripeEvent struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Created int64  `json:"created"`
+	Data    struct {
+		Object stripeCharge `json:"object"`
+	} `json:"data"`
+}
+
+// parseStripeEvent decodes a Stripe webhook event. Events other than charge
+// events are returned with their type and no charge.
+func parseStripeEvent(payload []byte) (*Event, error) {
+	var raw stripeEvent
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPayload, err)
+	}
+	if raw.ID == "" || raw.Type == "" {
+		return nil, fmt.Errorf("%w: missing event id or type", ErrInvalidPayload)
+	}
+
+	event := &Event{
+		ID:        raw.ID,
+		Type:      EventType(raw.Type),
+		CreatedAt: time.Unix(raw.Created, 0).UTC(),
+	}
+	if !strings.HasPrefix(raw.Type, "charge.") {
+		return event, nil
+	}
+
+	charge := raw.Data.Object
+	currency := strings.ToUpper(charge.Currency)
+	event.ChargeID = charge.ID
+	event.Currency = currency
+	event.Amount = fromMinorUnits(charge.Amount, currency)
+	event.AmountRefunded = fromMinorUnits(charge.AmountRefunded, currency)
+	event.FailureReason = charge.FailureMessage
+	if id, err := uuid.Parse(charge.Metadata[recordMetadataKey]); err == nil {
+		event.RecordID = id
+	}
+	return event, nil
+}
+
+// encodeStripeEvent encodes an event as a Stripe webhook payload
+func encodeStripeEvent(event *Event) ([]byte, error) {
+	var raw stripeEvent
+	raw.ID = event.ID
+	raw.Type = string(event.Type)
+	raw.Created = event.CreatedAt.Unix()
+	raw.Data.Object = stripeCharge{
+		ID:             event.ChargeID,
+		Amount:         toMinorUnits(event.Amount, event.Currency),
+		AmountRefunded: toMinorUnits(event.AmountRefunded, event.Currency),
+		Currency:       strings.ToLower(event.Currency),
+		FailureMessage: event.FailureReason,
+		Metadata:       map[string]string{},
+	}
+	if event.RecordID != uuid.Nil {
+		raw.Data.Object.Metadata[recordMetadataKey] = event.RecordID.String()
+	}
+	switch event.Type {
+	case EventChargeFailed:
+		raw.Data.Object.Status = string(ChargeStatusFailed)
+	default:
+		raw.Data.Object.Status = string(ChargeStatusSucceeded)
+	}
+	return json.Marshal(raw)
+}
+
+// SignStripePayload returns the Stripe-Signature header value for a payload
+// sent at a time
+func SignStripePayload(secret string, timestamp time.Time, payload []byte) string {
+	unix := timestamp.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", unix, stripeSignature(secret, unix, payload))
+}
+
+// stripeSignature returns the hex HMAC-SHA256 of "<unix time>.<payload>"
+func stripeSignature(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyStripeSignature checks that a Stripe-Signature header signs payload
+// with secret, and that it was made within tolerance of now. Any of several
+// v1 signatures may match, which allows rotating the secret.
+func verifyStripeSignature(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp int64 = -1
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			if t, err := strconv.ParseInt(value, 10, 64); err == nil {
+				timestamp = t
+			}
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	if timestamp < 0 || len(signatures) == 0 {
+		return ErrInvalidSignature
+	}
+
+	age := now.Sub(time.Unix(timestamp, 0))
+	if age > tolerance || age < -tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected := []byte(stripeSignature(secret, timestamp, payload))
+	for _, signature := range signatures {
+		if hmac.Equal(expected, []byte(signature)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -0,0 +1,86 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+// Notification retry backoff
+const (
+	notificationRetryBase = 30 * time.Second
+	notificationRetryMax  = time.Hour
+)
+
+// AlertNotificationPayload is the payload of a DeliverNotification task
+type AlertNotificationPayload struct {
+	AlertID uuid.UUID `json:"alert_id"`
+	Channel string    `json:"channel"`
+}
+
+// NotificationEnqueuer hands alert notifications to the worker as
+// DeliverNotification tasks, one per alert and channel
+type NotificationEnqueuer struct {
+	client     *asynq.Client
+	queue      string
+	maxRetries int
+}
+
+// NewNotificationEnqueuer creates a notification enqueuer that enqueues tasks
+// on queue, each retried up to maxRetries times
+func NewNotificationEnqueuer(client *asynq.Client, queue string, maxRetries int) *NotificationEnqueuer {
+	if queue == "" {
+		queue = "critical"
+	}
+	if maxRetries <= 0 {
+		maxRetries = 8
+	}
+
+	return &NotificationEnqueuer{
+		client:     client,
+		queue:      queue,
+		maxRetries: maxRetries,
+	}
+}
+
+// EnqueueNotification enqueues the delivery of an alert on a channel. The task
+// ID is derived from both, so a delivery is never queued twice; notifying an
+// alert again while its delivery is still queued is a no-op.
+func (e *NotificationEnqueuer) EnqueueNotification(ctx context.Context, alertID uuid.UUID, channel string) error {
+	task, err := CreateTask(TaskTypeDeliverNotification, AlertNotificationPayload{AlertID: alertID, Channel: channel})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.EnqueueContext(ctx, task,
+		asynq.Queue(e.queue),
+		asynq.TaskID("alert-notification:"+alertID.String()+":"+channel),
+		asynq.MaxRetry(e.maxRetries),
+	)
+	// A notification still queued for the alert delivers its latest state
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to enqueue alert notification: %w", err)
+	}
+	return nil
+}
+
+// NotificationRetryDelay returns the delay before retry n of a notification:
+// 30s doubling up to an hour, so a channel that is down for a while is not
+// hammered
+func NotificationRetryDelay(n int) time.Duration {
+	delay := notificationRetryBase
+	for i := 0; i < n && delay < notificationRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > notificationRetryMax {
+		delay = notificationRetryMax
+	}
+	return delay
+}
@@ -3,32 +3,66 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/hibiken/asynq"
 	"go.uber.org/zap"
 
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
 	"github.com/rdhawladar/viva-rate-limiter/internal/services"
 )
 
 // Task type constants
 const (
-	TaskTypeProcessUsageLogs   = "usage:process_logs"
-	TaskTypeCheckRateLimit     = "ratelimit:check"
-	TaskTypeGenerateBilling    = "billing:generate"
-	TaskTypeProcessAlerts      = "alerts:process"
-	TaskTypeCleanupExpiredData = "cleanup:expired_data"
-	TaskTypeSyncCacheWithDB    = "cache:sync"
+	TaskTypeProcessUsageLogs    = "usage:process_logs"
+	TaskTypeCheckRateLimit      = "ratelimit:check"
+	TaskTypeGenerateBilling     = "billing:generate"
+	TaskTypeRunDunning          = "billing:dunning"
+	TaskTypeProcessAlerts       = "alerts:process"
+	TaskTypeCleanupExpiredData  = "cleanup:expired_data"
+	TaskTypeSyncCacheWithDB     = "cache:sync"
+	TaskTypeMaintainPartitions  = "partitions:maintain"
+	TaskTypeExportUsage         = "usage:export"
+	TaskTypeDeliverNotification = "alerts:notify"
+	TaskTypeExportMetering      = "metering:export"
+	TaskTypeExpireCredits       = "credits:expire"
 )
 
+// Data retention periods used by CleanupExpiredData
+const (
+	usageLogRetentionDays  = 90
+	violationRetentionDays = 180
+	alertRetention         = 365 * 24 * time.Hour
+
+	// Minute rollups live as long as the raw logs they replace; day rollups are kept
+	minuteRollupRetentionDays = usageLogRetentionDays
+	hourRollupRetentionDays   = 400
+)
+
+// UsageLogBatchPayload is the payload of a ProcessUsageLogs task carrying
+// buffered usage events from the API
+type UsageLogBatchPayload struct {
+	Logs []*services.UsageLogRequest `json:"logs"`
+}
+
 // TaskHandlers contains all task handler implementations
 type TaskHandlers struct {
 	apiKeyService        services.APIKeyService
 	rateLimitService     services.RateLimitService
 	usageTrackingService services.UsageTrackingService
+	usageRollupService   services.UsageRollupService
+	partitionManager     services.PartitionManager
+	usageExportService   services.UsageExportService
 	alertService         services.AlertService
-	billingService       services.BillingService
+	notificationService  services.NotificationService
+	billingService       *services.BillingService
+	paymentService       *services.PaymentService
+	meteringService      *services.MeteringService
+	creditService        *services.CreditService
 	logger               *zap.Logger
 }
 
@@ -37,60 +71,68 @@ func NewTaskHandlers(
 	apiKeyService services.APIKeyService,
 	rateLimitService services.RateLimitService,
 	usageTrackingService services.UsageTrackingService,
+	usageRollupService services.UsageRollupService,
+	partitionManager services.PartitionManager,
+	usageExportService services.UsageExportService,
 	alertService services.AlertService,
-	billingService services.BillingService,
+	notificationService services.NotificationService,
+	billingService *services.BillingService,
+	paymentService *services.PaymentService,
+	meteringService *services.MeteringService,
+	creditService *services.CreditService,
 	logger *zap.Logger,
 ) *TaskHandlers {
 	return &TaskHandlers{
 		apiKeyService:        apiKeyService,
 		rateLimitService:     rateLimitService,
 		usageTrackingService: usageTrackingService,
+		usageRollupService:   usageRollupService,
+		partitionManager:     partitionManager,
+		usageExportService:   usageExportService,
 		alertService:         alertService,
+		notificationService:  notificationService,
 		billingService:       billingService,
+		paymentService:       paymentService,
+		meteringService:      meteringService,
+		creditService:        creditService,
 		logger:               logger,
 	}
 }
 
-// ProcessUsageLogs aggregates and processes usage logs
+// ProcessUsageLogs stores a batch of usage events enqueued by the API. Tasks
+// without a batch are periodic ticks that roll completed usage buckets up into
+// the minute, hour and day rollup tables.
 func (h *TaskHandlers) ProcessUsageLogs(ctx context.Context, t *asynq.Task) error {
+	if len(t.Payload()) > 0 {
+		var payload UsageLogBatchPayload
+		if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+			return fmt.Errorf("failed to unmarshal payload: %w", err)
+		}
+
+		if err := h.usageTrackingService.BatchLogUsage(ctx, payload.Logs); err != nil {
+			return fmt.Errorf("failed to store usage logs: %w", err)
+		}
+
+		h.logger.Debug("Stored usage log batch",
+			zap.Int("logs", len(payload.Logs)),
+		)
+		return nil
+	}
+
 	h.logger.Info("Processing usage logs",
 		zap.String("task_id", t.ResultWriter().TaskID()),
 	)
 
 	startTime := time.Now()
 
-	// Get all active API keys
-	apiKeys, err := h.apiKeyService.ListAPIKeys(ctx, 1000, 0)
+	result, err := h.usageRollupService.RollupUsage(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to list API keys: %w", err)
-	}
-
-	processed := 0
-	for _, apiKey := range apiKeys {
-		// Aggregate usage for each API key
-		usage, err := h.usageTrackingService.GetCurrentUsage(ctx, apiKey.ID.String())
-		if err != nil {
-			h.logger.Error("Failed to get usage for API key",
-				zap.String("api_key_id", apiKey.ID.String()),
-				zap.Error(err),
-			)
-			continue
-		}
-
-		// Check if usage exceeds limits
-		if usage > int64(apiKey.RateLimit) {
-			h.logger.Warn("API key exceeded rate limit",
-				zap.String("api_key_id", apiKey.ID.String()),
-				zap.Int64("usage", usage),
-				zap.Int("limit", apiKey.RateLimit),
-			)
-		}
-
-		processed++
+		return fmt.Errorf("failed to roll up usage: %w", err)
 	}
 
 	h.logger.Info("Usage log processing completed",
-		zap.Int("processed", processed),
+		zap.Any("rows", result.Rows),
+		zap.Any("checkpoints", result.Checkpoints),
 		zap.Duration("duration", time.Since(startTime)),
 	)
 
@@ -99,28 +141,25 @@ func (h *TaskHandlers) ProcessUsageLogs(ctx context.Context, t *asynq.Task) erro
 
 // CheckRateLimit performs rate limit checks
 func (h *TaskHandlers) CheckRateLimit(ctx context.Context, t *asynq.Task) error {
-	var payload struct {
-		APIKeyID string `json:"api_key_id"`
-	}
-
+	var payload services.RateLimitRequest
 	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
 		return fmt.Errorf("failed to unmarshal payload: %w", err)
 	}
 
 	h.logger.Info("Checking rate limit",
-		zap.String("api_key_id", payload.APIKeyID),
+		zap.String("api_key_id", payload.APIKeyID.String()),
 	)
 
-	allowed, remaining, resetAt, err := h.rateLimitService.CheckRateLimit(ctx, payload.APIKeyID)
+	result, err := h.rateLimitService.CheckRateLimit(ctx, &payload)
 	if err != nil {
 		return fmt.Errorf("failed to check rate limit: %w", err)
 	}
 
 	h.logger.Info("Rate limit check completed",
-		zap.String("api_key_id", payload.APIKeyID),
-		zap.Bool("allowed", allowed),
-		zap.Int("remaining", remaining),
-		zap.Time("reset_at", resetAt),
+		zap.String("api_key_id", payload.APIKeyID.String()),
+		zap.Bool("allowed", result.Allowed),
+		zap.Int("remaining", result.Remaining),
+		zap.Time("reset_at", result.ResetTime),
 	)
 
 	return nil
@@ -134,15 +173,17 @@ func (h *TaskHandlers) GenerateBilling(ctx context.Context, t *asynq.Task) error
 
 	startTime := time.Now()
 
-	// Get billing period (previous month)
-	now := time.Now()
+	// Get billing period (previous month); the end is exclusive
+	now := time.Now().UTC()
 	periodStart := time.Date(now.Year(), now.Month()-1, 1, 0, 0, 0, 0, time.UTC)
-	periodEnd := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).Add(-time.Second)
+	periodEnd := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
 
 	// Generate billing for all API keys
 	records, err := h.billingService.GenerateBillingRecords(ctx, periodStart, periodEnd)
 	if err != nil {
-		return fmt.Errorf("failed to generate billing records: %w", err)
+		// Records of the other keys are saved, and are recalculated the same
+		// way when the task is retried
+		return fmt.Errorf("failed to generate billing records (%d saved): %w", len(records), err)
 	}
 
 	h.logger.Info("Billing generation completed",
@@ -155,6 +196,92 @@ func (h *TaskHandlers) GenerateBilling(ctx context.Context, t *asynq.Task) error
 	return nil
 }
 
+// RunDunning marks overdue invoices and suspends or reinstates the API keys
+// of unpaid ones
+func (h *TaskHandlers) RunDunning(ctx context.Context, t *asynq.Task) error {
+	h.logger.Info("Running dunning",
+		zap.String("task_id", t.ResultWriter().TaskID()),
+	)
+
+	startTime := time.Now()
+
+	result, err := h.paymentService.RunDunning(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to run dunning: %w", err)
+	}
+
+	h.logger.Info("Dunning completed",
+		zap.Int("overdue", result.Overdue),
+		zap.Int("suspended", result.Suspended),
+		zap.Int("reinstated", result.Reinstated),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+
+	return nil
+}
+
+// ExportMetering generates metering records for the completed hours and
+// delivers them to every sink
+func (h *TaskHandlers) ExportMetering(ctx context.Context, t *asynq.Task) error {
+	h.logger.Info("Exporting metering records",
+		zap.String("task_id", t.ResultWriter().TaskID()),
+	)
+
+	startTime := time.Now()
+
+	result, err := h.meteringService.Run(ctx)
+	if errors.Is(err, services.ErrMeteringDisabled) {
+		h.logger.Debug("Metering is disabled, skipping export")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to export metering records: %w", err)
+	}
+
+	// A failing sink keeps its checkpoint and catches up on the next run, so
+	// it is logged rather than retried
+	for _, sink := range result.Sinks {
+		if sink.Error != "" {
+			h.logger.Warn("Metering sink delivery failed",
+				zap.String("sink", sink.Name),
+				zap.Int("delivered", sink.Delivered),
+				zap.String("error", sink.Error),
+			)
+		}
+	}
+
+	h.logger.Info("Metering export completed",
+		zap.Int64("generated", result.Generated),
+		zap.Int("sinks", len(result.Sinks)),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+
+	return nil
+}
+
+// ExpireCredits removes the unspent part of prepaid credit top-ups that
+// have expired
+func (h *TaskHandlers) ExpireCredits(ctx context.Context, t *asynq.Task) error {
+	h.logger.Info("Expiring prepaid credits",
+		zap.String("task_id", t.ResultWriter().TaskID()),
+	)
+
+	startTime := time.Now()
+
+	result, err := h.creditService.ExpireCredits(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to expire credits: %w", err)
+	}
+
+	h.logger.Info("Credit expiry completed",
+		zap.Int("expired", result.Expired),
+		zap.Float64("amount", result.Amount),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+
+	return nil
+}
+
 // ProcessAlerts processes alert rules and sends notifications
 func (h *TaskHandlers) ProcessAlerts(ctx context.Context, t *asynq.Task) error {
 	h.logger.Info("Processing alerts",
@@ -203,6 +330,42 @@ func (h *TaskHandlers) ProcessAlerts(ctx context.Context, t *asynq.Task) error {
 	return nil
 }
 
+// DeliverNotification delivers an alert on one channel. Failures are retried
+// with backoff until the last attempt, which records the delivery as failed;
+// errors a retry cannot fix skip the remaining attempts.
+func (h *TaskHandlers) DeliverNotification(ctx context.Context, t *asynq.Task) error {
+	var payload AlertNotificationPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	retried, _ := asynq.GetRetryCount(ctx)
+	maxRetry, _ := asynq.GetMaxRetry(ctx)
+	final := retried >= maxRetry
+
+	err := h.notificationService.DeliverNotification(ctx, payload.AlertID, payload.Channel, final)
+	if err == nil {
+		h.logger.Info("Alert notification delivered",
+			zap.String("alert_id", payload.AlertID.String()),
+			zap.String("channel", payload.Channel),
+			zap.Int("attempt", retried+1),
+		)
+		return nil
+	}
+
+	h.logger.Warn("Alert notification failed",
+		zap.String("alert_id", payload.AlertID.String()),
+		zap.String("channel", payload.Channel),
+		zap.Int("attempt", retried+1),
+		zap.Error(err),
+	)
+
+	if notify.IsPermanent(err) {
+		return fmt.Errorf("failed to deliver alert notification: %v: %w", err, asynq.SkipRetry)
+	}
+	return fmt.Errorf("failed to deliver alert notification: %w", err)
+}
+
 // CleanupExpiredData removes old data from the database
 func (h *TaskHandlers) CleanupExpiredData(ctx context.Context, t *asynq.Task) error {
 	h.logger.Info("Cleaning up expired data",
@@ -211,21 +374,41 @@ func (h *TaskHandlers) CleanupExpiredData(ctx context.Context, t *asynq.Task) er
 
 	startTime := time.Now()
 
-	// Define retention periods
-	usageLogRetention := 90 * 24 * time.Hour    // 90 days
-	violationRetention := 180 * 24 * time.Hour  // 180 days
-	alertRetention := 365 * 24 * time.Hour      // 1 year
+	// Cleanup old usage logs unless whole partitions are retired instead
+	var usageLogsCleaned int64
+	if !h.partitionsManageRetention(ctx, "usage_logs") {
+		cleaned, err := h.usageTrackingService.CleanupOldLogs(ctx, usageLogRetentionDays)
+		if err != nil {
+			h.logger.Error("Failed to cleanup usage logs", zap.Error(err))
+		}
+		usageLogsCleaned = cleaned
+	}
 
-	// Cleanup old usage logs
-	usageLogsCleaned, err := h.usageTrackingService.CleanupOldLogs(ctx, time.Now().Add(-usageLogRetention))
-	if err != nil {
-		h.logger.Error("Failed to cleanup usage logs", zap.Error(err))
+	// Cleanup old violations unless whole partitions are retired instead
+	var violationsCleaned int64
+	if !h.partitionsManageRetention(ctx, "rate_limit_violations") {
+		cleaned, err := h.rateLimitService.CleanupOldViolations(ctx, violationRetentionDays)
+		if err != nil {
+			h.logger.Error("Failed to cleanup violations", zap.Error(err))
+		}
+		violationsCleaned = cleaned
 	}
 
-	// Cleanup old violations
-	violationsCleaned, err := h.rateLimitService.CleanupOldViolations(ctx, time.Now().Add(-violationRetention))
-	if err != nil {
-		h.logger.Error("Failed to cleanup violations", zap.Error(err))
+	// Cleanup old rollups
+	var rollupsCleaned int64
+	for granularity, retentionDays := range map[models.RollupGranularity]int{
+		models.RollupMinute: minuteRollupRetentionDays,
+		models.RollupHour:   hourRollupRetentionDays,
+	} {
+		cleaned, err := h.usageRollupService.CleanupOldRollups(ctx, granularity, retentionDays)
+		if err != nil {
+			h.logger.Error("Failed to cleanup usage rollups",
+				zap.String("granularity", string(granularity)),
+				zap.Error(err),
+			)
+			continue
+		}
+		rollupsCleaned += cleaned
 	}
 
 	// Cleanup old alerts
@@ -237,6 +420,7 @@ func (h *TaskHandlers) CleanupExpiredData(ctx context.Context, t *asynq.Task) er
 	h.logger.Info("Cleanup completed",
 		zap.Int64("usage_logs_cleaned", usageLogsCleaned),
 		zap.Int64("violations_cleaned", violationsCleaned),
+		zap.Int64("rollups_cleaned", rollupsCleaned),
 		zap.Int64("alerts_cleaned", alertsCleaned),
 		zap.Duration("duration", time.Since(startTime)),
 	)
@@ -244,41 +428,103 @@ func (h *TaskHandlers) CleanupExpiredData(ctx context.Context, t *asynq.Task) er
 	return nil
 }
 
-// SyncCacheWithDB synchronizes cache with database
-func (h *TaskHandlers) SyncCacheWithDB(ctx context.Context, t *asynq.Task) error {
-	h.logger.Info("Syncing cache with database",
+// partitionsManageRetention returns true if the partition manager removes
+// expired rows of table, in which case row-by-row cleanup is skipped
+func (h *TaskHandlers) partitionsManageRetention(ctx context.Context, table string) bool {
+	if h.partitionManager == nil {
+		return false
+	}
+
+	manages, err := h.partitionManager.ManagesRetention(ctx, table)
+	if err != nil {
+		h.logger.Error("Failed to check partitioning",
+			zap.String("table", table),
+			zap.Error(err),
+		)
+		return false
+	}
+	return manages
+}
+
+// MaintainPartitions creates upcoming monthly partitions and retires partitions
+// past the retention period
+func (h *TaskHandlers) MaintainPartitions(ctx context.Context, t *asynq.Task) error {
+	h.logger.Info("Maintaining partitions",
+		zap.String("task_id", t.ResultWriter().TaskID()),
+	)
+
+	startTime := time.Now()
+
+	result, err := h.partitionManager.Maintain(ctx)
+	if result != nil {
+		h.logger.Info("Partition maintenance completed",
+			zap.Strings("created", result.Created),
+			zap.Strings("dropped", result.Dropped),
+			zap.Strings("archived", result.Archived),
+			zap.Strings("skipped", result.Skipped),
+			zap.Duration("duration", time.Since(startTime)),
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to maintain partitions: %w", err)
+	}
+
+	return nil
+}
+
+// ExportUsage writes a usage export enqueued by the API. A failed export is
+// recorded on the export and retried by asynq.
+func (h *TaskHandlers) ExportUsage(ctx context.Context, t *asynq.Task) error {
+	var payload UsageExportPayload
+	if err := json.Unmarshal(t.Payload(), &payload); err != nil {
+		return fmt.Errorf("failed to unmarshal payload: %w", err)
+	}
+
+	h.logger.Info("Exporting usage",
 		zap.String("task_id", t.ResultWriter().TaskID()),
+		zap.String("export_id", payload.ExportID.String()),
 	)
 
 	startTime := time.Now()
 
-	// Get all active API keys
-	apiKeys, err := h.apiKeyService.ListAPIKeys(ctx, 1000, 0)
+	result, err := h.usageExportService.RunExport(ctx, payload.ExportID)
 	if err != nil {
-		return fmt.Errorf("failed to list API keys: %w", err)
+		return fmt.Errorf("failed to export usage: %w", err)
 	}
 
+	h.logger.Info("Usage export completed",
+		zap.String("export_id", payload.ExportID.String()),
+		zap.Int64("records", result.RecordCount),
+		zap.Int64("bytes", result.FileSize),
+		zap.Duration("duration", time.Since(startTime)),
+	)
+
+	return nil
+}
+
+// SyncCacheWithDB reloads rate limit counters missing from the cache from the
+// usage logs in the database
+func (h *TaskHandlers) SyncCacheWithDB(ctx context.Context, t *asynq.Task) error {
+	h.logger.Info("Syncing cache with database",
+		zap.String("task_id", t.ResultWriter().TaskID()),
+	)
+
+	startTime := time.Now()
+
 	synced := 0
-	for _, apiKey := range apiKeys {
-		// Sync rate limit counters
-		if err := h.rateLimitService.SyncCounterWithDB(ctx, apiKey.ID.String()); err != nil {
+	err := h.forEachActiveAPIKey(ctx, func(apiKey *services.APIKeyResponse) {
+		if _, err := h.rateLimitService.GetCurrentWindowUsage(ctx, apiKey.ID); err != nil {
 			h.logger.Error("Failed to sync counter for API key",
 				zap.String("api_key_id", apiKey.ID.String()),
 				zap.Error(err),
 			)
-			continue
-		}
-
-		// Sync usage counters
-		if err := h.usageTrackingService.SyncUsageWithDB(ctx, apiKey.ID.String()); err != nil {
-			h.logger.Error("Failed to sync usage for API key",
-				zap.String("api_key_id", apiKey.ID.String()),
-				zap.Error(err),
-			)
-			continue
+			return
 		}
 
 		synced++
+	})
+	if err != nil {
+		return err
 	}
 
 	h.logger.Info("Cache sync completed",
@@ -289,6 +535,33 @@ func (h *TaskHandlers) SyncCacheWithDB(ctx context.Context, t *asynq.Task) error
 	return nil
 }
 
+// forEachActiveAPIKey calls fn for every active API key, a page at a time
+func (h *TaskHandlers) forEachActiveAPIKey(ctx context.Context, fn func(apiKey *services.APIKeyResponse)) error {
+	status := models.APIKeyStatusActive
+	filter := &repositories.APIKeyFilter{Status: &status}
+
+	for page := 1; ; page++ {
+		result, err := h.apiKeyService.ListAPIKeys(ctx, filter, &repositories.PaginationParams{
+			Page:     page,
+			PageSize: 100,
+			OrderBy:  "created_at",
+			Order:    "asc",
+		})
+		if err != nil {
+			return fmt.Errorf("failed to list API keys: %w", err)
+		}
+
+		apiKeys, _ := result.Data.([]*services.APIKeyResponse)
+		for _, apiKey := range apiKeys {
+			fn(apiKey)
+		}
+
+		if page >= result.TotalPages {
+			return nil
+		}
+	}
+}
+
 // CreateTask creates a new task for enqueueing
 func CreateTask(taskType string, payload interface{}) (*asynq.Task, error) {
 	data, err := json.Marshal(payload)
@@ -297,4 +570,4 @@ func CreateTask(taskType string, payload interface{}) (*asynq.Task, error) {
 	}
 
 	return asynq.NewTask(taskType, data), nil
-}
\ No newline at end of file
+}
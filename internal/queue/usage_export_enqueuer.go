@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+)
+
+// UsageExportPayload is the payload of an ExportUsage task
+type UsageExportPayload struct {
+	ExportID uuid.UUID `json:"export_id"`
+}
+
+// UsageExportEnqueuer hands large usage exports to the worker as ExportUsage
+// tasks
+type UsageExportEnqueuer struct {
+	client *asynq.Client
+	queue  string
+}
+
+// NewUsageExportEnqueuer creates an export enqueuer that enqueues tasks on queue
+func NewUsageExportEnqueuer(client *asynq.Client, queue string) *UsageExportEnqueuer {
+	if queue == "" {
+		queue = "default"
+	}
+
+	return &UsageExportEnqueuer{
+		client: client,
+		queue:  queue,
+	}
+}
+
+// EnqueueExport enqueues the export with the given ID. The task ID is the
+// export ID, so an export is never queued twice.
+func (e *UsageExportEnqueuer) EnqueueExport(ctx context.Context, exportID uuid.UUID) error {
+	task, err := CreateTask(TaskTypeExportUsage, UsageExportPayload{ExportID: exportID})
+	if err != nil {
+		return err
+	}
+
+	_, err = e.client.EnqueueContext(ctx, task,
+		asynq.Queue(e.queue),
+		asynq.TaskID("usage-export:"+exportID.String()),
+		asynq.MaxRetry(3),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue usage export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hibiken/asynq"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/services"
+)
+
+// UsageLogTaskSink hands batches of usage events to the worker as
+// ProcessUsageLogs tasks instead of writing them from the API process
+type UsageLogTaskSink struct {
+	client *asynq.Client
+	queue  string
+}
+
+// NewUsageLogTaskSink creates a usage log sink that enqueues batches on queue
+func NewUsageLogTaskSink(client *asynq.Client, queue string) *UsageLogTaskSink {
+	if queue == "" {
+		queue = "default"
+	}
+
+	return &UsageLogTaskSink{
+		client: client,
+		queue:  queue,
+	}
+}
+
+// WriteBatch enqueues a batch of usage events
+func (s *UsageLogTaskSink) WriteBatch(ctx context.Context, batch []*services.UsageLogRequest) error {
+	task, err := CreateTask(TaskTypeProcessUsageLogs, UsageLogBatchPayload{Logs: batch})
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.client.EnqueueContext(ctx, task, asynq.Queue(s.queue)); err != nil {
+		return fmt.Errorf("failed to enqueue usage logs: %w", err)
+	}
+	return nil
+}
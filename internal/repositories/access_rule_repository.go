@@ -0,0 +1,147 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// AccessRuleRepository defines the interface for access rule data access
+type AccessRuleRepository interface {
+	Create(ctx context.Context, rule *models.AccessRule) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.AccessRule, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+	List(ctx context.Context, filter *AccessRuleFilter, pagination *PaginationParams) (*PaginatedResult, error)
+	GetActive(ctx context.Context, now time.Time) ([]*models.AccessRule, error)
+	FindActiveBan(ctx context.Context, matchType models.AccessMatchType, value string, now time.Time) (*models.AccessRule, error)
+}
+
+// AccessRuleFilter contains filter parameters for access rule queries
+type AccessRuleFilter struct {
+	ListType  *models.AccessListType   `json:"list_type"`
+	MatchType *models.AccessMatchType  `json:"match_type"`
+	Source    *models.AccessRuleSource `json:"source"`
+	ActiveAt  *time.Time               `json:"active_at"`
+	Search    string                   `json:"search"`
+}
+
+// accessRuleRepository implements AccessRuleRepository interface
+type accessRuleRepository struct {
+	*baseRepository
+}
+
+// NewAccessRuleRepository creates a new access rule repository
+func NewAccessRuleRepository(db *gorm.DB) AccessRuleRepository {
+	return &accessRuleRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Create creates a new access rule
+func (r *accessRuleRepository) Create(ctx context.Context, rule *models.AccessRule) error {
+	if err := r.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create access rule: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an access rule by ID
+func (r *accessRuleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AccessRule, error) {
+	var rule models.AccessRule
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("access rule not found")
+		}
+		return nil, fmt.Errorf("failed to get access rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// Delete soft deletes an access rule
+func (r *accessRuleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.AccessRule{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete access rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("access rule not found")
+	}
+	return nil
+}
+
+// List retrieves access rules with filtering and pagination
+func (r *accessRuleRepository) List(ctx context.Context, filter *AccessRuleFilter, pagination *PaginationParams) (*PaginatedResult, error) {
+	query := r.db.WithContext(ctx).Model(&models.AccessRule{})
+
+	// Apply filters
+	if filter != nil {
+		if filter.ListType != nil {
+			query = query.Where("list_type = ?", *filter.ListType)
+		}
+		if filter.MatchType != nil {
+			query = query.Where("match_type = ?", *filter.MatchType)
+		}
+		if filter.Source != nil {
+			query = query.Where("source = ?", *filter.Source)
+		}
+		if filter.ActiveAt != nil {
+			query = query.Where("expires_at IS NULL OR expires_at > ?", *filter.ActiveAt)
+		}
+		if filter.Search != "" {
+			query = query.Where("value ILIKE ? OR reason ILIKE ?", "%"+filter.Search+"%", "%"+filter.Search+"%")
+		}
+	}
+
+	// Count total records
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count access rules: %w", err)
+	}
+
+	// Apply pagination
+	var rules []models.AccessRule
+	if err := query.
+		Order(pagination.GetOrderBy()).
+		Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list access rules: %w", err)
+	}
+
+	return NewPaginatedResult(rules, total, pagination), nil
+}
+
+// GetActive retrieves every rule that has not expired
+func (r *accessRuleRepository) GetActive(ctx context.Context, now time.Time) ([]*models.AccessRule, error) {
+	var rules []*models.AccessRule
+	if err := r.db.WithContext(ctx).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("created_at ASC").
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get active access rules: %w", err)
+	}
+	return rules, nil
+}
+
+// FindActiveBan retrieves an unexpired deny rule for an exact value, if any
+func (r *accessRuleRepository) FindActiveBan(ctx context.Context, matchType models.AccessMatchType, value string, now time.Time) (*models.AccessRule, error) {
+	var rule models.AccessRule
+	err := r.db.WithContext(ctx).
+		Where("list_type = ? AND match_type = ? AND value = ?", models.AccessListDeny, matchType, value).
+		Where("expires_at IS NULL OR expires_at > ?", now).
+		Order("expires_at DESC NULLS FIRST").
+		First(&rule).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to find active ban: %w", err)
+	}
+	return &rule, nil
+}
@@ -2,6 +2,7 @@ package repositories
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
@@ -17,9 +18,13 @@ type AlertRepository interface {
 	Create(ctx context.Context, alert *models.Alert) error
 	GetByID(ctx context.Context, id uuid.UUID) (*models.Alert, error)
 	Update(ctx context.Context, alert *models.Alert) error
+	UpdateState(ctx context.Context, alert *models.Alert) error
+	UpdateDelivery(ctx context.Context, id uuid.UUID, channel string, delivery *models.AlertDelivery) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	List(ctx context.Context, filter *AlertFilter, pagination *PaginationParams) (*PaginatedResult, error)
 	GetUnresolvedByAPIKey(ctx context.Context, apiKeyID uuid.UUID) ([]*models.Alert, error)
+	GetUnresolvedByDedupKey(ctx context.Context, dedupKey string) (*models.Alert, error)
+	GetByStatus(ctx context.Context, status models.AlertStatus) ([]*models.Alert, error)
 	GetByType(ctx context.Context, alertType models.AlertType, resolved bool) ([]*models.Alert, error)
 	ResolveAlert(ctx context.Context, id uuid.UUID, resolvedBy string) error
 	BatchResolve(ctx context.Context, ids []uuid.UUID, resolvedBy string) error
@@ -31,8 +36,10 @@ type AlertRepository interface {
 // AlertFilter contains filter parameters for alert queries
 type AlertFilter struct {
 	APIKeyID    *uuid.UUID            `json:"api_key_id"`
+	RuleID      *uuid.UUID            `json:"rule_id"`
 	Type        *models.AlertType     `json:"type"`
 	Severity    *models.AlertSeverity `json:"severity"`
+	Status      *models.AlertStatus   `json:"status"`
 	Resolved    *bool                 `json:"resolved"`
 	StartTime   *time.Time            `json:"start_time"`
 	EndTime     *time.Time            `json:"end_time"`
@@ -46,9 +53,21 @@ type AlertsSummary struct {
 	UnresolvedAlerts  int64                       `json:"unresolved_alerts"`
 	BySeverity        map[string]int64            `json:"by_severity"`
 	ByType            map[string]int64            `json:"by_type"`
+	ByStatus          map[string]int64            `json:"by_status"`
+	TopAPIKeys        []*AlertCount               `json:"top_api_keys"`
+	AverageAcknowledgement float64                `json:"average_acknowledgement_hours"`
 	AverageResolution float64                     `json:"average_resolution_hours"`
 }
 
+// AlertCount is the number of alerts raised for an API key
+type AlertCount struct {
+	APIKeyID uuid.UUID `json:"api_key_id"`
+	Alerts   int64     `json:"alerts"`
+}
+
+// alertSummaryTopKeys is the number of API keys in AlertsSummary.TopAPIKeys
+const alertSummaryTopKeys = 10
+
 // alertRepository implements AlertRepository interface
 type alertRepository struct {
 	*baseRepository
@@ -93,6 +112,52 @@ func (r *alertRepository) Update(ctx context.Context, alert *models.Alert) error
 	return nil
 }
 
+// UpdateState saves the lifecycle fields of an alert: status, severity,
+// message, metadata, occurrences, acknowledgement, snooze, escalation and
+// resolution. Unlike Update it also saves cleared fields, and it leaves
+// deliveries alone.
+func (r *alertRepository) UpdateState(ctx context.Context, alert *models.Alert) error {
+	result := r.db.WithContext(ctx).
+		Model(alert).
+		Select(
+			"status", "severity", "message", "metadata", "occurrences", "last_seen_at",
+			"acknowledged_at", "acknowledged_by", "snoozed_until", "escalation_level", "escalated_at",
+			"resolved", "resolved_at", "resolved_by", "updated_at",
+		).
+		Updates(alert)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update alert: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alert not found")
+	}
+	return nil
+}
+
+// UpdateDelivery records the notification state of an alert on one channel.
+// Only that channel's entry is replaced, so deliveries on several channels can
+// be recorded concurrently.
+func (r *alertRepository) UpdateDelivery(ctx context.Context, id uuid.UUID, channel string, delivery *models.AlertDelivery) error {
+	value, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert delivery: %w", err)
+	}
+
+	result := r.db.WithContext(ctx).Exec(`
+		UPDATE alerts
+		SET deliveries = jsonb_set(COALESCE(deliveries, '{}'::jsonb), ARRAY[?]::text[], ?::jsonb),
+		    updated_at = NOW()
+		WHERE id = ?
+	`, channel, string(value), id)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update alert delivery: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alert not found")
+	}
+	return nil
+}
+
 // Delete deletes an alert
 func (r *alertRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.Alert{})
@@ -114,12 +179,18 @@ func (r *alertRepository) List(ctx context.Context, filter *AlertFilter, paginat
 		if filter.APIKeyID != nil {
 			query = query.Where("api_key_id = ?", *filter.APIKeyID)
 		}
+		if filter.RuleID != nil {
+			query = query.Where("rule_id = ?", *filter.RuleID)
+		}
 		if filter.Type != nil {
 			query = query.Where("type = ?", *filter.Type)
 		}
 		if filter.Severity != nil {
 			query = query.Where("severity = ?", *filter.Severity)
 		}
+		if filter.Status != nil {
+			query = query.Where("status = ?", *filter.Status)
+		}
 		if filter.Resolved != nil {
 			query = query.Where("resolved = ?", *filter.Resolved)
 		}
@@ -168,6 +239,32 @@ func (r *alertRepository) GetUnresolvedByAPIKey(ctx context.Context, apiKeyID uu
 	return alerts, nil
 }
 
+// GetUnresolvedByDedupKey retrieves the unresolved alert with a dedup key
+func (r *alertRepository) GetUnresolvedByDedupKey(ctx context.Context, dedupKey string) (*models.Alert, error) {
+	var alert models.Alert
+	if err := r.db.WithContext(ctx).
+		Where("dedup_key = ? AND status <> ?", dedupKey, models.AlertStatusResolved).
+		First(&alert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("alert not found")
+		}
+		return nil, fmt.Errorf("failed to get alert: %w", err)
+	}
+	return &alert, nil
+}
+
+// GetByStatus retrieves alerts in a lifecycle state, oldest first
+func (r *alertRepository) GetByStatus(ctx context.Context, status models.AlertStatus) ([]*models.Alert, error) {
+	var alerts []*models.Alert
+	if err := r.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("created_at ASC").
+		Find(&alerts).Error; err != nil {
+		return nil, fmt.Errorf("failed to get alerts by status: %w", err)
+	}
+	return alerts, nil
+}
+
 // GetByType retrieves alerts by type and resolution status
 func (r *alertRepository) GetByType(ctx context.Context, alertType models.AlertType, resolved bool) ([]*models.Alert, error) {
 	var alerts []*models.Alert
@@ -187,9 +284,11 @@ func (r *alertRepository) ResolveAlert(ctx context.Context, id uuid.UUID, resolv
 		Model(&models.Alert{}).
 		Where("id = ?", id).
 		Updates(map[string]interface{}{
-			"resolved":     true,
-			"resolved_at":  now,
-			"resolved_by":  resolvedBy,
+			"status":        models.AlertStatusResolved,
+			"resolved":      true,
+			"resolved_at":   now,
+			"resolved_by":   resolvedBy,
+			"snoozed_until": nil,
 		})
 
 	if result.Error != nil {
@@ -201,7 +300,8 @@ func (r *alertRepository) ResolveAlert(ctx context.Context, id uuid.UUID, resolv
 	return nil
 }
 
-// BatchResolve resolves multiple alerts
+// BatchResolve resolves multiple alerts. Alerts already resolved keep their
+// original resolution.
 func (r *alertRepository) BatchResolve(ctx context.Context, ids []uuid.UUID, resolvedBy string) error {
 	if len(ids) == 0 {
 		return nil
@@ -210,11 +310,13 @@ func (r *alertRepository) BatchResolve(ctx context.Context, ids []uuid.UUID, res
 	now := time.Now()
 	result := r.db.WithContext(ctx).
 		Model(&models.Alert{}).
-		Where("id IN ?", ids).
+		Where("id IN ? AND resolved = ?", ids, false).
 		Updates(map[string]interface{}{
-			"resolved":     true,
-			"resolved_at":  now,
-			"resolved_by":  resolvedBy,
+			"status":        models.AlertStatusResolved,
+			"resolved":      true,
+			"resolved_at":   now,
+			"resolved_by":   resolvedBy,
+			"snoozed_until": nil,
 		})
 
 	if result.Error != nil {
@@ -252,6 +354,8 @@ func (r *alertRepository) GetAlertsSummary(ctx context.Context, startTime time.T
 	summary := &AlertsSummary{
 		BySeverity: make(map[string]int64),
 		ByType:     make(map[string]int64),
+		ByStatus:   make(map[string]int64),
+		TopAPIKeys: []*AlertCount{},
 	}
 
 	// Get total and unresolved counts
@@ -289,25 +393,55 @@ func (r *alertRepository) GetAlertsSummary(ctx context.Context, startTime time.T
 		summary.BySeverity[string(severity)] = count
 	}
 
-	// Count by type
-	types := []models.AlertType{
-		models.AlertTypeRateLimit,
-		models.AlertTypeQuotaExceeded,
-		models.AlertTypeAbnormalUsage,
-		models.AlertTypeSystemError,
-		models.AlertTypeSecurityIssue,
+	// Count by type and status
+	groups := []struct {
+		column string
+		counts map[string]int64
+	}{
+		{"type", summary.ByType},
+		{"status", summary.ByStatus},
 	}
-
-	for _, alertType := range types {
-		var count int64
+	for _, group := range groups {
+		var rows []struct {
+			Value string
+			Count int64
+		}
 		if err := r.db.WithContext(ctx).
 			Model(&models.Alert{}).
-			Where("created_at >= ? AND type = ?", startTime, alertType).
-			Count(&count).Error; err != nil {
-			return nil, fmt.Errorf("failed to count by type %s: %w", alertType, err)
+			Select(group.column + " AS value, COUNT(*) AS count").
+			Where("created_at >= ?", startTime).
+			Group(group.column).
+			Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to count by %s: %w", group.column, err)
 		}
-		summary.ByType[string(alertType)] = count
+		for _, row := range rows {
+			group.counts[row.Value] = row.Count
+		}
+	}
+
+	// API keys raising the most alerts
+	if err := r.db.WithContext(ctx).
+		Model(&models.Alert{}).
+		Select("api_key_id, COUNT(*) AS alerts").
+		Where("created_at >= ?", startTime).
+		Group("api_key_id").
+		Order("alerts DESC").
+		Limit(alertSummaryTopKeys).
+		Scan(&summary.TopAPIKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to count alerts by api key: %w", err)
+	}
+
+	// Calculate average acknowledgement time
+	var avgAcknowledgement float64
+	ackQuery := `
+		SELECT COALESCE(AVG(EXTRACT(EPOCH FROM (acknowledged_at - created_at)) / 3600), 0) as avg_hours
+		FROM alerts
+		WHERE created_at >= ? AND acknowledged_at IS NOT NULL
+	`
+	if err := r.db.WithContext(ctx).Raw(ackQuery, startTime).Scan(&avgAcknowledgement).Error; err != nil {
+		return nil, fmt.Errorf("failed to calculate average acknowledgement time: %w", err)
 	}
+	summary.AverageAcknowledgement = avgAcknowledgement
 
 	// Calculate average resolution time
 	var avgResolution float64
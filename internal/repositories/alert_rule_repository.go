@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// AlertRuleRepository defines the interface for alert rule data access
+type AlertRuleRepository interface {
+	Create(ctx context.Context, rule *models.AlertRule) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.AlertRule, error)
+	Update(ctx context.Context, rule *models.AlertRule) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	List(ctx context.Context, filter *AlertRuleFilter, pagination *PaginationParams) (*PaginatedResult, error)
+	GetEnabled(ctx context.Context) ([]*models.AlertRule, error)
+}
+
+// AlertRuleFilter contains filter parameters for alert rule queries
+type AlertRuleFilter struct {
+	Type     *models.AlertType     `json:"type"`
+	Severity *models.AlertSeverity `json:"severity"`
+	Enabled  *bool                 `json:"enabled"`
+	APIKeyID *uuid.UUID            `json:"api_key_id"`
+	Tier     *models.APIKeyTier    `json:"tier"`
+	TeamID   *uuid.UUID            `json:"team_id"`
+	Search   string                `json:"search"`
+}
+
+// alertRuleRepository implements AlertRuleRepository interface
+type alertRuleRepository struct {
+	*baseRepository
+}
+
+// NewAlertRuleRepository creates a new alert rule repository
+func NewAlertRuleRepository(db *gorm.DB) AlertRuleRepository {
+	return &alertRuleRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Create creates a new alert rule
+func (r *alertRuleRepository) Create(ctx context.Context, rule *models.AlertRule) error {
+	if err := r.db.WithContext(ctx).Create(rule).Error; err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an alert rule by ID
+func (r *alertRuleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&rule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("alert rule not found")
+		}
+		return nil, fmt.Errorf("failed to get alert rule: %w", err)
+	}
+	return &rule, nil
+}
+
+// Update saves every field of an alert rule, so fields can be cleared
+func (r *alertRuleRepository) Update(ctx context.Context, rule *models.AlertRule) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at", "created_by").Updates(rule)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update alert rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alert rule not found")
+	}
+	return nil
+}
+
+// Delete soft deletes an alert rule
+func (r *alertRuleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("alert rule not found")
+	}
+	return nil
+}
+
+// List retrieves alert rules with filtering and pagination
+func (r *alertRuleRepository) List(ctx context.Context, filter *AlertRuleFilter, pagination *PaginationParams) (*PaginatedResult, error) {
+	query := r.db.WithContext(ctx).Model(&models.AlertRule{})
+
+	// Apply filters
+	if filter != nil {
+		if filter.Type != nil {
+			query = query.Where("type = ?", *filter.Type)
+		}
+		if filter.Severity != nil {
+			query = query.Where("severity = ?", *filter.Severity)
+		}
+		if filter.Enabled != nil {
+			query = query.Where("enabled = ?", *filter.Enabled)
+		}
+		if filter.APIKeyID != nil {
+			query = query.Where("api_key_id = ?", *filter.APIKeyID)
+		}
+		if filter.Tier != nil {
+			query = query.Where("tier = ?", *filter.Tier)
+		}
+		if filter.TeamID != nil {
+			query = query.Where("team_id = ?", *filter.TeamID)
+		}
+		if filter.Search != "" {
+			query = query.Where("name ILIKE ? OR description ILIKE ?", "%"+filter.Search+"%", "%"+filter.Search+"%")
+		}
+	}
+
+	// Count total records
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count alert rules: %w", err)
+	}
+
+	// Apply pagination
+	var rules []models.AlertRule
+	if err := query.
+		Order(pagination.GetOrderBy()).
+		Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+
+	return NewPaginatedResult(rules, total, pagination), nil
+}
+
+// GetEnabled retrieves every enabled alert rule
+func (r *alertRuleRepository) GetEnabled(ctx context.Context) ([]*models.AlertRule, error) {
+	var rules []*models.AlertRule
+	if err := r.db.WithContext(ctx).
+		Where("enabled = ?", true).
+		Order("created_at ASC").
+		Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to get enabled alert rules: %w", err)
+	}
+	return rules, nil
+}
@@ -26,10 +26,18 @@ type APIKeyRepository interface {
 	CountByStatus(ctx context.Context, status models.APIKeyStatus) (int64, error)
 	GetExpiredKeys(ctx context.Context, expiryTime time.Time) ([]*models.APIKey, error)
 	BatchUpdateStatus(ctx context.Context, ids []uuid.UUID, status models.APIKeyStatus) error
+	Suspend(ctx context.Context, ids []uuid.UUID, reason string) (int64, error)
+	Reinstate(ctx context.Context, ids []uuid.UUID, reason string) (int64, error)
+	GetSuspended(ctx context.Context, reason string) ([]*models.APIKey, error)
+	GetByIDsWithDeleted(ctx context.Context, ids []uuid.UUID) ([]*models.APIKey, error)
+	GetTierChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyTierChange, error)
+	GetStatusChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyStatusChange, error)
+	GetStatusChangedBetween(ctx context.Context, start, end time.Time) ([]*models.APIKey, error)
 }
 
 // APIKeyFilter contains filter parameters for API key queries
 type APIKeyFilter struct {
+	OrganizationID *uuid.UUID     `json:"organization_id"`
 	Status   *models.APIKeyStatus `json:"status"`
 	Tier     *models.APIKeyTier   `json:"tier"`
 	UserID   *uuid.UUID           `json:"user_id"`
@@ -114,6 +122,9 @@ func (r *apiKeyRepository) List(ctx context.Context, filter *APIKeyFilter, pagin
 
 	// Apply filters
 	if filter != nil {
+		if filter.OrganizationID != nil {
+			query = query.Where("organization_id = ?", *filter.OrganizationID)
+		}
 		if filter.Status != nil {
 			query = query.Where("status = ?", *filter.Status)
 		}
@@ -193,12 +204,15 @@ func (r *apiKeyRepository) IncrementUsage(ctx context.Context, id uuid.UUID, cou
 	return nil
 }
 
-// GetActiveByTier retrieves all active API keys for a specific tier
+// GetActiveByTier retrieves all active API keys for a specific tier, or of
+// every tier for models.APIKeyTierAll
 func (r *apiKeyRepository) GetActiveByTier(ctx context.Context, tier models.APIKeyTier) ([]*models.APIKey, error) {
 	var apiKeys []*models.APIKey
-	if err := r.db.WithContext(ctx).
-		Where("tier = ? AND status = ?", tier, models.APIKeyStatusActive).
-		Find(&apiKeys).Error; err != nil {
+	query := r.db.WithContext(ctx).Where("status = ?", models.APIKeyStatusActive)
+	if tier != models.APIKeyTierAll {
+		query = query.Where("tier = ?", tier)
+	}
+	if err := query.Find(&apiKeys).Error; err != nil {
 		return nil, fmt.Errorf("failed to get active keys by tier: %w", err)
 	}
 	return apiKeys, nil
@@ -244,4 +258,112 @@ func (r *apiKeyRepository) BatchUpdateStatus(ctx context.Context, ids []uuid.UUI
 		return fmt.Errorf("failed to batch update status: %w", result.Error)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Suspend suspends the active API keys among ids for a reason and returns how
+// many were suspended
+func (r *apiKeyRepository) Suspend(ctx context.Context, ids []uuid.UUID, reason string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id IN ? AND status = ?", ids, models.APIKeyStatusActive).
+		Updates(map[string]interface{}{
+			"status":           models.APIKeyStatusSuspended,
+			"suspended_reason": reason,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to suspend api keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// Reinstate reactivates the API keys among ids that were suspended for a
+// reason and returns how many were reactivated
+func (r *apiKeyRepository) Reinstate(ctx context.Context, ids []uuid.UUID, reason string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("id IN ? AND status = ? AND suspended_reason = ?", ids, models.APIKeyStatusSuspended, reason).
+		Updates(map[string]interface{}{
+			"status":           models.APIKeyStatusActive,
+			"suspended_reason": nil,
+		})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to reinstate api keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetSuspended retrieves the API keys suspended for a reason
+func (r *apiKeyRepository) GetSuspended(ctx context.Context, reason string) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND suspended_reason = ?", models.APIKeyStatusSuspended, reason).
+		Find(&apiKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get suspended api keys: %w", err)
+	}
+	return apiKeys, nil
+}
+
+// GetByIDsWithDeleted retrieves API keys by ID, including deleted ones
+func (r *apiKeyRepository) GetByIDsWithDeleted(ctx context.Context, ids []uuid.UUID) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	if len(ids) == 0 {
+		return apiKeys, nil
+	}
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("id IN ?", ids).
+		Find(&apiKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get api keys: %w", err)
+	}
+	return apiKeys, nil
+}
+
+// GetTierChanges retrieves the tier changes of an API key at or after since,
+// oldest first
+func (r *apiKeyRepository) GetTierChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyTierChange, error) {
+	var changes []*models.APIKeyTierChange
+	if err := r.db.WithContext(ctx).
+		Where("api_key_id = ? AND changed_at >= ?", apiKeyID, since).
+		Order("changed_at ASC, id ASC").
+		Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get tier changes: %w", err)
+	}
+	return changes, nil
+}
+
+// GetStatusChanges retrieves the status changes of an API key at or after
+// since, oldest first
+func (r *apiKeyRepository) GetStatusChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyStatusChange, error) {
+	var changes []*models.APIKeyStatusChange
+	if err := r.db.WithContext(ctx).
+		Where("api_key_id = ? AND changed_at >= ?", apiKeyID, since).
+		Order("changed_at ASC, id ASC").
+		Find(&changes).Error; err != nil {
+		return nil, fmt.Errorf("failed to get status changes: %w", err)
+	}
+	return changes, nil
+}
+
+// GetStatusChangedBetween retrieves the API keys, deleted ones included, that
+// changed status or were deleted in [start, end)
+func (r *apiKeyRepository) GetStatusChangedBetween(ctx context.Context, start, end time.Time) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	if err := r.db.WithContext(ctx).
+		Unscoped().
+		Where("(deleted_at >= ? AND deleted_at < ?) OR id IN (?)", start, end,
+			r.db.Model(&models.APIKeyStatusChange{}).
+				Select("api_key_id").
+				Where("changed_at >= ? AND changed_at < ?", start, end)).
+		Find(&apiKeys).Error; err != nil {
+		return nil, fmt.Errorf("failed to get changed api keys: %w", err)
+	}
+	return apiKeys, nil
+}
@@ -21,7 +21,12 @@ type BillingRecordRepository interface {
 	GetByAPIKey(ctx context.Context, apiKeyID uuid.UUID, startDate, endDate time.Time) ([]*models.BillingRecord, error)
 	GetUnpaidRecords(ctx context.Context, apiKeyID uuid.UUID) ([]*models.BillingRecord, error)
 	GetCurrentPeriodRecord(ctx context.Context, apiKeyID uuid.UUID) (*models.BillingRecord, error)
+	GetByPeriod(ctx context.Context, apiKeyID uuid.UUID, periodStart, periodEnd time.Time) (*models.BillingRecord, error)
+	GetAllInPeriod(ctx context.Context, startDate, endDate time.Time) ([]*models.BillingRecord, error)
 	UpdatePaymentStatus(ctx context.Context, id uuid.UUID, status models.PaymentStatus, paidAt *time.Time) error
+	UpdatePayment(ctx context.Context, record *models.BillingRecord, from []models.PaymentStatus) (bool, error)
+	GetByChargeID(ctx context.Context, provider, chargeID string) (*models.BillingRecord, error)
+	GetUnpaidEndedBefore(ctx context.Context, cutoff time.Time) ([]*models.BillingRecord, error)
 	GetRevenueSummary(ctx context.Context, startDate, endDate time.Time) (*RevenueSummary, error)
 	GetOverdueRecords(ctx context.Context, daysOverdue int) ([]*models.BillingRecord, error)
 	GetTopRevenue(ctx context.Context, startDate, endDate time.Time, limit int) ([]*RevenueByAPIKey, error)
@@ -92,9 +97,9 @@ func (r *billingRecordRepository) GetByID(ctx context.Context, id uuid.UUID) (*m
 	return &record, nil
 }
 
-// Update updates a billing record
+// Update saves every field of a billing record, so amounts can drop to zero
 func (r *billingRecordRepository) Update(ctx context.Context, record *models.BillingRecord) error {
-	result := r.db.WithContext(ctx).Model(record).Updates(record)
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at", "APIKey").Updates(record)
 	if result.Error != nil {
 		return fmt.Errorf("failed to update billing record: %w", result.Error)
 	}
@@ -130,9 +135,9 @@ func (r *billingRecordRepository) List(ctx context.Context, filter *BillingFilte
 		}
 		if filter.HasOverage != nil {
 			if *filter.HasOverage {
-				query = query.Where("overage_charges > 0")
+				query = query.Where("overage_amount > 0")
 			} else {
-				query = query.Where("overage_charges = 0")
+				query = query.Where("overage_amount = 0")
 			}
 		}
 	}
@@ -200,7 +205,7 @@ func (r *billingRecordRepository) GetCurrentPeriodRecord(ctx context.Context, ap
 	now := time.Now()
 
 	if err := r.db.WithContext(ctx).
-		Where("api_key_id = ? AND period_start <= ? AND period_end >= ?", 
+		Where("api_key_id = ? AND period_start <= ? AND period_end > ?", 
 			apiKeyID, now, now).
 		First(&record).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -211,6 +216,34 @@ func (r *billingRecordRepository) GetCurrentPeriodRecord(ctx context.Context, ap
 	return &record, nil
 }
 
+// GetByPeriod retrieves the billing record of an API key for a period. It
+// returns nil when there is none.
+func (r *billingRecordRepository) GetByPeriod(ctx context.Context, apiKeyID uuid.UUID, periodStart, periodEnd time.Time) (*models.BillingRecord, error) {
+	var record models.BillingRecord
+	if err := r.db.WithContext(ctx).
+		Where("api_key_id = ? AND period_start = ? AND period_end = ?", apiKeyID, periodStart, periodEnd).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get billing record: %w", err)
+	}
+	return &record, nil
+}
+
+// GetAllInPeriod retrieves the billing records of every API key whose period
+// lies within [startDate, endDate]
+func (r *billingRecordRepository) GetAllInPeriod(ctx context.Context, startDate, endDate time.Time) ([]*models.BillingRecord, error) {
+	var records []*models.BillingRecord
+	if err := r.db.WithContext(ctx).
+		Where("period_start >= ? AND period_end <= ?", startDate, endDate).
+		Order("period_start ASC, api_key_id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get billing records in period: %w", err)
+	}
+	return records, nil
+}
+
 // UpdatePaymentStatus updates the payment status of a billing record
 func (r *billingRecordRepository) UpdatePaymentStatus(ctx context.Context, id uuid.UUID, status models.PaymentStatus, paidAt *time.Time) error {
 	updates := map[string]interface{}{
@@ -234,104 +267,145 @@ func (r *billingRecordRepository) UpdatePaymentStatus(ctx context.Context, id uu
 	return nil
 }
 
+// UpdatePayment saves the payment fields of a billing record if its payment
+// status is still one of from, and reports whether it was saved. Concurrent
+// updates of the same record so apply at most once.
+func (r *billingRecordRepository) UpdatePayment(ctx context.Context, record *models.BillingRecord, from []models.PaymentStatus) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.BillingRecord{}).
+		Where("id = ? AND payment_status IN ?", record.ID, from).
+		Updates(map[string]interface{}{
+			"payment_status":         record.PaymentStatus,
+			"paid_at":                record.PaidAt,
+			"payment_provider":       record.PaymentProvider,
+			"payment_charge_id":      record.PaymentChargeID,
+			"payment_failure_reason": record.PaymentFailureReason,
+		})
+
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to update payment: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetByChargeID retrieves the billing record paid by a provider charge
+func (r *billingRecordRepository) GetByChargeID(ctx context.Context, provider, chargeID string) (*models.BillingRecord, error) {
+	var record models.BillingRecord
+	if err := r.db.WithContext(ctx).
+		Where("payment_provider = ? AND payment_charge_id = ?", provider, chargeID).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("billing record not found")
+		}
+		return nil, fmt.Errorf("failed to get billing record: %w", err)
+	}
+	return &record, nil
+}
+
+// GetUnpaidEndedBefore retrieves the unpaid billing records of every API key
+// whose period ended before cutoff, oldest first
+func (r *billingRecordRepository) GetUnpaidEndedBefore(ctx context.Context, cutoff time.Time) ([]*models.BillingRecord, error) {
+	var records []*models.BillingRecord
+	if err := r.db.WithContext(ctx).
+		Where("payment_status IN ? AND period_end < ?", []models.PaymentStatus{
+			models.PaymentStatusPending,
+			models.PaymentStatusOverdue,
+			models.PaymentStatusFailed,
+		}, cutoff).
+		Order("period_end ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get unpaid records: %w", err)
+	}
+	return records, nil
+}
+
 // GetRevenueSummary retrieves aggregated revenue statistics
 func (r *billingRecordRepository) GetRevenueSummary(ctx context.Context, startDate, endDate time.Time) (*RevenueSummary, error) {
 	summary := &RevenueSummary{
 		ByPaymentStatus: make(map[string]float64),
 	}
 
-	// Base query
-	baseQuery := r.db.WithContext(ctx).Model(&models.BillingRecord{})
+	query := r.db.WithContext(ctx).Model(&models.BillingRecord{})
 	if !startDate.IsZero() {
-		baseQuery = baseQuery.Where("period_start >= ?", startDate)
+		query = query.Where("period_start >= ?", startDate)
 	}
 	if !endDate.IsZero() {
-		baseQuery = baseQuery.Where("period_end <= ?", endDate)
-	}
-
-	// Total revenue and count
-	var totals struct {
-		TotalRevenue float64
-		RecordCount  int64
-	}
-	if err := baseQuery.
-		Select("COALESCE(SUM(total_amount), 0) as total_revenue, COUNT(*) as record_count").
-		Scan(&totals).Error; err != nil {
-		return nil, fmt.Errorf("failed to get total revenue: %w", err)
-	}
-	summary.TotalRevenue = totals.TotalRevenue
-	summary.RecordCount = totals.RecordCount
-
-	if summary.RecordCount > 0 {
-		summary.AverageRecordValue = summary.TotalRevenue / float64(summary.RecordCount)
+		query = query.Where("period_end <= ?", endDate)
 	}
 
-	// Revenue by payment status
-	statuses := []models.PaymentStatus{
-		models.PaymentStatusPending,
-		models.PaymentStatusPaid,
-		models.PaymentStatusOverdue,
-		models.PaymentStatusFailed,
-		models.PaymentStatusRefunded,
+	// Totals by payment status
+	var rows []struct {
+		PaymentStatus models.PaymentStatus
+		Revenue       float64
+		Overages      float64
+		RecordCount   int64
 	}
-
-	for _, status := range statuses {
-		var amount float64
-		if err := baseQuery.
-			Where("payment_status = ?", status).
-			Select("COALESCE(SUM(total_amount), 0)").
-			Scan(&amount).Error; err != nil {
-			return nil, fmt.Errorf("failed to get revenue for status %s: %w", status, err)
-		}
-		summary.ByPaymentStatus[string(status)] = amount
-
-		// Set specific status totals
-		switch status {
+	if err := query.
+		Select(`payment_status,
+			COALESCE(SUM(total_amount), 0) AS revenue,
+			COALESCE(SUM(overage_amount), 0) AS overages,
+			COUNT(*) AS record_count`).
+		Group("payment_status").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get revenue summary: %w", err)
+	}
+
+	for _, row := range rows {
+		summary.ByPaymentStatus[string(row.PaymentStatus)] = row.Revenue
+		summary.TotalRevenue += row.Revenue
+		summary.TotalOverages += row.Overages
+		summary.RecordCount += row.RecordCount
+
+		switch row.PaymentStatus {
 		case models.PaymentStatusPaid:
-			summary.PaidRevenue = amount
-		case models.PaymentStatusPending:
-			summary.UnpaidRevenue += amount
+			summary.PaidRevenue = row.Revenue
 		case models.PaymentStatusOverdue:
-			summary.OverdueRevenue = amount
-			summary.UnpaidRevenue += amount
-		case models.PaymentStatusFailed:
-			summary.UnpaidRevenue += amount
+			summary.OverdueRevenue = row.Revenue
+			summary.UnpaidRevenue += row.Revenue
+		case models.PaymentStatusPending, models.PaymentStatusFailed:
+			summary.UnpaidRevenue += row.Revenue
 		}
 	}
 
-	// Total overages
-	if err := baseQuery.
-		Select("COALESCE(SUM(overage_charges), 0)").
-		Scan(&summary.TotalOverages).Error; err != nil {
-		return nil, fmt.Errorf("failed to get total overages: %w", err)
+	if summary.RecordCount > 0 {
+		summary.AverageRecordValue = summary.TotalRevenue / float64(summary.RecordCount)
 	}
 
 	return summary, nil
 }
 
-// GetOverdueRecords retrieves billing records that are overdue
+// GetOverdueRecords retrieves unpaid billing records whose period ended more
+// than daysOverdue days ago, oldest first. Pending records among them are
+// marked overdue.
 func (r *billingRecordRepository) GetOverdueRecords(ctx context.Context, daysOverdue int) ([]*models.BillingRecord, error) {
 	var records []*models.BillingRecord
 	cutoffDate := time.Now().AddDate(0, 0, -daysOverdue)
 
 	if err := r.db.WithContext(ctx).
-		Where("payment_status = ? AND period_end < ?", 
-			models.PaymentStatusPending, cutoffDate).
+		Where("payment_status IN ? AND period_end < ?",
+			[]models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusOverdue}, cutoffDate).
 		Order("period_end ASC").
 		Find(&records).Error; err != nil {
 		return nil, fmt.Errorf("failed to get overdue records: %w", err)
 	}
 
 	// Update status to overdue if needed
-	if len(records) > 0 {
-		var ids []uuid.UUID
-		for _, record := range records {
+	var ids []uuid.UUID
+	for _, record := range records {
+		if record.PaymentStatus == models.PaymentStatusPending {
 			ids = append(ids, record.ID)
 		}
-		r.db.WithContext(ctx).
+	}
+	if len(ids) > 0 {
+		if err := r.db.WithContext(ctx).
 			Model(&models.BillingRecord{}).
-			Where("id IN ?", ids).
-			Update("payment_status", models.PaymentStatusOverdue)
+			Where("id IN ? AND payment_status = ?", ids, models.PaymentStatusPending).
+			Update("payment_status", models.PaymentStatusOverdue).Error; err != nil {
+			return nil, fmt.Errorf("failed to mark records overdue: %w", err)
+		}
+		for _, record := range records {
+			record.PaymentStatus = models.PaymentStatusOverdue
+		}
 	}
 
 	return records, nil
@@ -0,0 +1,367 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// CreditDebit is the outcome of charging a credit account. A debit that was
+// not charged says whether the balance or the monthly spend cap stopped it;
+// when both would, the balance is reported.
+type CreditDebit struct {
+	Charged          bool    `json:"charged"`
+	Balance          float64 `json:"balance"`
+	PeriodSpend      float64 `json:"period_spend"`
+	CreditsExhausted bool    `json:"credits_exhausted"`
+	SpendCapReached  bool    `json:"spend_cap_reached"`
+}
+
+// CreditRepository defines the interface for credit accounts and their ledger
+type CreditRepository interface {
+	CreateAccount(ctx context.Context, account *models.CreditAccount) error
+	GetAccount(ctx context.Context, id uuid.UUID) (*models.CreditAccount, error)
+	GetAccountForOwner(ctx context.Context, apiKeyID, teamID *uuid.UUID) (*models.CreditAccount, error)
+	ListAccountOwners(ctx context.Context) ([]uuid.UUID, error)
+	UpdateAccountSettings(ctx context.Context, account *models.CreditAccount) error
+	TopUp(ctx context.Context, entry *models.CreditLedgerEntry) (*models.CreditAccount, bool, error)
+	Debit(ctx context.Context, accountID uuid.UUID, amount float64, hour, month, now time.Time) (*CreditDebit, error)
+	GetEntries(ctx context.Context, accountID uuid.UUID, pagination *PaginationParams) (*PaginatedResult, error)
+	GetExpiringTopUps(ctx context.Context, before time.Time, limit int) ([]*models.CreditLedgerEntry, error)
+	ExpireTopUp(ctx context.Context, topUpID uuid.UUID, now time.Time) (*models.CreditLedgerEntry, error)
+}
+
+// creditRepository implements CreditRepository interface
+type creditRepository struct {
+	*baseRepository
+}
+
+// NewCreditRepository creates a new credit repository
+func NewCreditRepository(db *gorm.DB) CreditRepository {
+	return &creditRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// CreateAccount creates a new credit account
+func (r *creditRepository) CreateAccount(ctx context.Context, account *models.CreditAccount) error {
+	if err := r.db.WithContext(ctx).Create(account).Error; err != nil {
+		return fmt.Errorf("failed to create credit account: %w", err)
+	}
+	return nil
+}
+
+// GetAccount retrieves a credit account by ID
+func (r *creditRepository) GetAccount(ctx context.Context, id uuid.UUID) (*models.CreditAccount, error) {
+	var account models.CreditAccount
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("credit account not found")
+		}
+		return nil, fmt.Errorf("failed to get credit account: %w", err)
+	}
+	return &account, nil
+}
+
+// ListAccountOwners returns the IDs of the API keys and teams that have a
+// credit account
+func (r *creditRepository) ListAccountOwners(ctx context.Context) ([]uuid.UUID, error) {
+	var accounts []*models.CreditAccount
+	if err := r.db.WithContext(ctx).
+		Model(&models.CreditAccount{}).
+		Select("api_key_id", "team_id").
+		Find(&accounts).Error; err != nil {
+		return nil, fmt.Errorf("failed to list credit account owners: %w", err)
+	}
+
+	owners := make([]uuid.UUID, 0, len(accounts))
+	for _, account := range accounts {
+		if account.APIKeyID != nil {
+			owners = append(owners, *account.APIKeyID)
+		}
+		if account.TeamID != nil {
+			owners = append(owners, *account.TeamID)
+		}
+	}
+	return owners, nil
+}
+
+// GetAccountForOwner retrieves the account of an API key, or else the
+// account of a team. Either ID may be nil.
+func (r *creditRepository) GetAccountForOwner(ctx context.Context, apiKeyID, teamID *uuid.UUID) (*models.CreditAccount, error) {
+	if apiKeyID == nil && teamID == nil {
+		return nil, fmt.Errorf("credit account not found")
+	}
+
+	var account models.CreditAccount
+	if err := r.db.WithContext(ctx).
+		Where("api_key_id = ? OR team_id = ?", apiKeyID, teamID).
+		Order("api_key_id IS NULL").
+		First(&account).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("credit account not found")
+		}
+		return nil, fmt.Errorf("failed to get credit account: %w", err)
+	}
+	return &account, nil
+}
+
+// UpdateAccountSettings saves the spend cap and alert thresholds of an
+// account. The balance is only changed through the ledger.
+func (r *creditRepository) UpdateAccountSettings(ctx context.Context, account *models.CreditAccount) error {
+	result := r.db.WithContext(ctx).
+		Model(account).
+		Select("monthly_spend_cap", "alert_thresholds", "updated_at").
+		Updates(account)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update credit account: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("credit account not found")
+	}
+	return nil
+}
+
+// TopUp records a top-up and adds it to the balance of its account. A top-up
+// with the reference of an earlier one is not applied again. It returns the
+// account and whether the top-up was applied.
+func (r *creditRepository) TopUp(ctx context.Context, entry *models.CreditLedgerEntry) (*models.CreditAccount, bool, error) {
+	var account models.CreditAccount
+	applied := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", entry.AccountID).First(&account).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("credit account not found")
+			}
+			return err
+		}
+
+		if entry.Reference != "" {
+			var existing int64
+			if err := tx.Model(&models.CreditLedgerEntry{}).
+				Where("account_id = ? AND type = ? AND reference = ?", entry.AccountID, models.CreditEntryTopUp, entry.Reference).
+				Count(&existing).Error; err != nil {
+				return err
+			}
+			if existing > 0 {
+				return nil
+			}
+		}
+
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&account).
+			UpdateColumns(map[string]interface{}{
+				"balance":    gorm.Expr("balance + ?", entry.Amount),
+				"updated_at": entry.CreatedAt,
+			}).Error; err != nil {
+			return err
+		}
+		applied = true
+		return tx.Where("id = ?", account.ID).First(&account).Error
+	})
+	if err != nil {
+		if err.Error() == "credit account not found" {
+			return nil, false, err
+		}
+		return nil, false, fmt.Errorf("failed to top up credit account: %w", err)
+	}
+	return &account, applied, nil
+}
+
+// Debit charges an account amount credits if its balance and monthly spend
+// cap allow it, and adds the charge to the debit entry of the hour. month is
+// the start of the current spend cap period; the period spend restarts when
+// it changes. An account that cannot pay is left unchanged and reported with
+// its current balance and the check it failed. now stamps the account and the
+// ledger entry.
+func (r *creditRepository) Debit(ctx context.Context, accountID uuid.UUID, amount float64, hour, month, now time.Time) (*CreditDebit, error) {
+	var debit CreditDebit
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		args := map[string]interface{}{
+			"id":     accountID,
+			"amount": amount,
+			"month":  month,
+			"hour":   hour,
+			"now":    now,
+		}
+
+		// The account is locked while it is checked, so the reason a debit is
+		// refused is the one that applied when it was refused
+		var check struct {
+			Balance     float64
+			PeriodSpend float64
+			Covered     bool
+			WithinCap   bool
+		}
+		result := tx.Raw(`
+			SELECT
+				balance,
+				CASE WHEN period_start = @month THEN period_spend ELSE 0 END AS period_spend,
+				balance >= @amount AS covered,
+				(monthly_spend_cap IS NULL
+					OR (CASE WHEN period_start = @month THEN period_spend ELSE 0 END) + @amount <= monthly_spend_cap) AS within_cap
+			FROM credit_accounts
+			WHERE id = @id
+			FOR UPDATE
+		`, args).Scan(&check)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("credit account not found")
+		}
+
+		if !check.Covered || !check.WithinCap {
+			debit.Balance = check.Balance
+			debit.PeriodSpend = check.PeriodSpend
+			debit.CreditsExhausted = !check.Covered
+			debit.SpendCapReached = check.Covered && !check.WithinCap
+			return nil
+		}
+
+		if err := tx.Raw(`
+			UPDATE credit_accounts SET
+				balance = balance - @amount,
+				period_spend = CASE WHEN period_start = @month THEN period_spend + @amount ELSE @amount END,
+				period_start = @month,
+				updated_at = @now
+			WHERE id = @id
+			RETURNING balance, period_spend
+		`, args).Scan(&debit).Error; err != nil {
+			return err
+		}
+		debit.Charged = true
+
+		return tx.Exec(`
+			INSERT INTO credit_ledger_entries (id, account_id, type, amount, period_start, requests, created_at, updated_at)
+			VALUES (gen_random_uuid(), @id, 'debit', -@amount, @hour, 1, @now, @now)
+			ON CONFLICT (account_id, period_start) WHERE type = 'debit'
+			DO UPDATE SET
+				amount = credit_ledger_entries.amount + EXCLUDED.amount,
+				requests = credit_ledger_entries.requests + 1,
+				updated_at = EXCLUDED.updated_at
+		`, args).Error
+	})
+	if err != nil {
+		if err.Error() == "credit account not found" {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to debit credit account: %w", err)
+	}
+	return &debit, nil
+}
+
+// GetEntries retrieves the ledger of an account, newest first
+func (r *creditRepository) GetEntries(ctx context.Context, accountID uuid.UUID, pagination *PaginationParams) (*PaginatedResult, error) {
+	if pagination == nil {
+		pagination = DefaultPagination()
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.CreditLedgerEntry{}).Where("account_id = ?", accountID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count credit ledger entries: %w", err)
+	}
+
+	var entries []*models.CreditLedgerEntry
+	if err := query.
+		Order("created_at DESC, id DESC").
+		Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get credit ledger entries: %w", err)
+	}
+	return NewPaginatedResult(entries, total, pagination), nil
+}
+
+// GetExpiringTopUps retrieves up to limit top-ups that expire at or before
+// before and have not been expired yet, soonest first
+func (r *creditRepository) GetExpiringTopUps(ctx context.Context, before time.Time, limit int) ([]*models.CreditLedgerEntry, error) {
+	var entries []*models.CreditLedgerEntry
+	if err := r.db.WithContext(ctx).
+		Where("type = ? AND expires_at IS NOT NULL AND expires_at <= ? AND expired_at IS NULL", models.CreditEntryTopUp, before).
+		Order("expires_at ASC, id ASC").
+		Limit(limit).
+		Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to get expiring top-ups: %w", err)
+	}
+	return entries, nil
+}
+
+// ExpireTopUp removes the unspent part of a top-up from its account and
+// records it as an expiry. Credits are spent soonest expiring first, so the
+// unspent part is whatever the balance holds beyond the top-ups that expire
+// later, up to the top-up's amount. It returns the expiry entry, or nil if
+// the top-up was already expired.
+func (r *creditRepository) ExpireTopUp(ctx context.Context, topUpID uuid.UUID, now time.Time) (*models.CreditLedgerEntry, error) {
+	var expiry *models.CreditLedgerEntry
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var topUp models.CreditLedgerEntry
+		if err := tx.Where("id = ? AND type = ?", topUpID, models.CreditEntryTopUp).First(&topUp).Error; err != nil {
+			return err
+		}
+
+		// Lock the account so no debit lands between reading the balance
+		// and removing the expired credits
+		var account models.CreditAccount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", topUp.AccountID).First(&account).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", topUpID).First(&topUp).Error; err != nil {
+			return err
+		}
+		if topUp.ExpiredAt != nil || topUp.ExpiresAt == nil {
+			return nil
+		}
+
+		var later float64
+		if err := tx.Model(&models.CreditLedgerEntry{}).
+			Select("COALESCE(SUM(amount), 0)").
+			Where("account_id = ? AND type = ? AND expired_at IS NULL AND id <> ?", topUp.AccountID, models.CreditEntryTopUp, topUp.ID).
+			Where("expires_at IS NULL OR expires_at > ? OR (expires_at = ? AND id > ?)", *topUp.ExpiresAt, *topUp.ExpiresAt, topUp.ID).
+			Row().Scan(&later); err != nil {
+			return err
+		}
+
+		unspent := math.Min(topUp.Amount, math.Max(0, account.Balance-later))
+		unspent = math.Round(unspent*1e6) / 1e6
+
+		expiry = &models.CreditLedgerEntry{
+			AccountID:   topUp.AccountID,
+			Type:        models.CreditEntryExpiry,
+			Amount:      -unspent,
+			Description: "Unspent credits expired",
+			TopUpID:     &topUp.ID,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Create(expiry).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&account).UpdateColumns(map[string]interface{}{
+			"balance":    gorm.Expr("balance - ?", unspent),
+			"updated_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&topUp).UpdateColumns(map[string]interface{}{
+			"expired_at": now,
+			"updated_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to expire top-up: %w", err)
+	}
+	return expiry, nil
+}
@@ -0,0 +1,169 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// MeteredUsage contains the usage of an API key during one hour, as read for
+// metering
+type MeteredUsage struct {
+	APIKeyID            uuid.UUID `json:"api_key_id"`
+	Hour                time.Time `json:"hour"`
+	Requests            int64     `json:"requests"`
+	Bandwidth           int64     `json:"bandwidth"`
+	RateLimitedRequests int64     `json:"rate_limited_requests"`
+}
+
+// MeteringTotal contains the quantity of a metric generated for an API key
+// over a range
+type MeteringTotal struct {
+	APIKeyID uuid.UUID             `json:"api_key_id"`
+	Metric   models.MeteringMetric `json:"metric"`
+	Quantity int64                 `json:"quantity"`
+}
+
+// MeteringRepository defines the interface for metering records and their
+// checkpoints
+type MeteringRepository interface {
+	GetHourlyUsage(ctx context.Context, from, to time.Time) ([]*MeteredUsage, error)
+	CreateRecords(ctx context.Context, records []*models.MeteringRecord) (int64, error)
+	GetRecords(ctx context.Context, from, to time.Time) ([]*models.MeteringRecord, error)
+	GetNextRecordTime(ctx context.Context, from time.Time) (*time.Time, error)
+	GetTotals(ctx context.Context, from, to time.Time) ([]*MeteringTotal, error)
+	GetCheckpoints(ctx context.Context) (map[string]time.Time, error)
+	SetCheckpoint(ctx context.Context, name string, through time.Time) error
+}
+
+// meteringRepository implements MeteringRepository interface
+type meteringRepository struct {
+	*baseRepository
+}
+
+// NewMeteringRepository creates a new metering repository
+func NewMeteringRepository(db *gorm.DB) MeteringRepository {
+	return &meteringRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// GetHourlyUsage retrieves the usage of every API key in each hour of
+// [from, to) from the hourly rollups, oldest hour first
+func (r *meteringRepository) GetHourlyUsage(ctx context.Context, from, to time.Time) ([]*MeteredUsage, error) {
+	query := fmt.Sprintf(`
+		SELECT
+			api_key_id,
+			bucket_start AS hour,
+			COALESCE(SUM(request_count), 0) AS requests,
+			COALESCE(SUM(request_bytes + response_bytes), 0) AS bandwidth,
+			COALESCE(SUM(CASE WHEN status_code = 429 THEN request_count ELSE 0 END), 0) AS rate_limited_requests
+		FROM %s
+		WHERE bucket_start >= ? AND bucket_start < ?
+		GROUP BY 1, 2
+		ORDER BY 2, 1
+	`, models.RollupHour.TableName())
+
+	var usage []*MeteredUsage
+	if err := r.db.WithContext(ctx).Raw(query, from, to).Scan(&usage).Error; err != nil {
+		return nil, fmt.Errorf("failed to get hourly usage: %w", err)
+	}
+	return usage, nil
+}
+
+// CreateRecords stores metering records and returns how many were new.
+// Records already stored are left as they are.
+func (r *meteringRepository) CreateRecords(ctx context.Context, records []*models.MeteringRecord) (int64, error) {
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		CreateInBatches(records, 500)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to create metering records: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// GetRecords retrieves the records of periods starting in [from, to), in
+// delivery order
+func (r *meteringRepository) GetRecords(ctx context.Context, from, to time.Time) ([]*models.MeteringRecord, error) {
+	var records []*models.MeteringRecord
+	if err := r.db.WithContext(ctx).
+		Where("period_start >= ? AND period_start < ?", from, to).
+		Order("period_start ASC, id ASC").
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("failed to get metering records: %w", err)
+	}
+	return records, nil
+}
+
+// GetNextRecordTime retrieves the earliest period start at or after from, or
+// nil if no later record exists
+func (r *meteringRepository) GetNextRecordTime(ctx context.Context, from time.Time) (*time.Time, error) {
+	var next sql.NullTime
+	if err := r.db.WithContext(ctx).
+		Model(&models.MeteringRecord{}).
+		Select("MIN(period_start)").
+		Where("period_start >= ?", from).
+		Row().Scan(&next); err != nil {
+		return nil, fmt.Errorf("failed to get next metering record: %w", err)
+	}
+	if !next.Valid {
+		return nil, nil
+	}
+	return &next.Time, nil
+}
+
+// GetTotals sums the records of every API key and metric over periods
+// starting in [from, to)
+func (r *meteringRepository) GetTotals(ctx context.Context, from, to time.Time) ([]*MeteringTotal, error) {
+	var totals []*MeteringTotal
+	if err := r.db.WithContext(ctx).
+		Model(&models.MeteringRecord{}).
+		Select("api_key_id, metric, SUM(quantity) AS quantity").
+		Where("period_start >= ? AND period_start < ?", from, to).
+		Group("api_key_id, metric").
+		Scan(&totals).Error; err != nil {
+		return nil, fmt.Errorf("failed to get metering totals: %w", err)
+	}
+	return totals, nil
+}
+
+// GetCheckpoints retrieves every checkpoint by name
+func (r *meteringRepository) GetCheckpoints(ctx context.Context) (map[string]time.Time, error) {
+	var checkpoints []models.MeteringCheckpoint
+	if err := r.db.WithContext(ctx).Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get metering checkpoints: %w", err)
+	}
+
+	result := make(map[string]time.Time, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		result[checkpoint.Name] = checkpoint.Through
+	}
+	return result, nil
+}
+
+// SetCheckpoint records that every hour before through is done
+func (r *meteringRepository) SetCheckpoint(ctx context.Context, name string, through time.Time) error {
+	checkpoint := &models.MeteringCheckpoint{
+		Name:    name,
+		Through: through,
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"through", "updated_at"}),
+	}).Create(checkpoint).Error; err != nil {
+		return fmt.Errorf("failed to set metering checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// OrganizationRepository defines the interface for organizations and their
+// users and teams
+type OrganizationRepository interface {
+	Create(ctx context.Context, org *models.Organization, owner *models.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error)
+	GetBySlug(ctx context.Context, slug string) (*models.Organization, error)
+	List(ctx context.Context, pagination *PaginationParams) (*PaginatedResult, error)
+
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	ListUsers(ctx context.Context, orgID uuid.UUID, pagination *PaginationParams) (*PaginatedResult, error)
+	UpdateUser(ctx context.Context, user *models.User) error
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+
+	CreateTeam(ctx context.Context, team *models.Team) error
+	GetTeam(ctx context.Context, id uuid.UUID) (*models.Team, error)
+	ListTeams(ctx context.Context, orgID uuid.UUID) ([]*models.Team, error)
+	DeleteTeam(ctx context.Context, id uuid.UUID) error
+	AddTeamMember(ctx context.Context, member *models.TeamMember) error
+	RemoveTeamMember(ctx context.Context, teamID, userID uuid.UUID) error
+	ListTeamMembers(ctx context.Context, teamID uuid.UUID) ([]*models.User, error)
+	IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error)
+}
+
+// organizationRepository implements OrganizationRepository interface
+type organizationRepository struct {
+	*baseRepository
+}
+
+// NewOrganizationRepository creates a new organization repository
+func NewOrganizationRepository(db *gorm.DB) OrganizationRepository {
+	return &organizationRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Create creates an organization together with its first owner
+func (r *organizationRepository) Create(ctx context.Context, org *models.Organization, owner *models.User) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		owner.OrganizationID = org.ID
+		owner.Role = models.UserRoleOwner
+		return tx.Create(owner).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create organization: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an organization by ID
+func (r *organizationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &org, nil
+}
+
+// GetBySlug retrieves an organization by slug
+func (r *organizationRepository) GetBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	var org models.Organization
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&org).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("organization not found")
+		}
+		return nil, fmt.Errorf("failed to get organization: %w", err)
+	}
+	return &org, nil
+}
+
+// List retrieves organizations, oldest first
+func (r *organizationRepository) List(ctx context.Context, pagination *PaginationParams) (*PaginatedResult, error) {
+	if pagination == nil {
+		pagination = DefaultPagination()
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.Organization{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count organizations: %w", err)
+	}
+
+	var orgs []*models.Organization
+	if err := query.
+		Order("created_at ASC, id ASC").
+		Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Find(&orgs).Error; err != nil {
+		return nil, fmt.Errorf("failed to list organizations: %w", err)
+	}
+	return NewPaginatedResult(orgs, total, pagination), nil
+}
+
+// CreateUser creates a new user
+func (r *organizationRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if err := r.db.WithContext(ctx).Create(user).Error; err != nil {
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetUser retrieves a user by ID
+func (r *organizationRepository) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// GetUserByEmail retrieves a user by email
+func (r *organizationRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return &user, nil
+}
+
+// ListUsers retrieves the users of an organization by email
+func (r *organizationRepository) ListUsers(ctx context.Context, orgID uuid.UUID, pagination *PaginationParams) (*PaginatedResult, error) {
+	if pagination == nil {
+		pagination = DefaultPagination()
+	}
+
+	query := r.db.WithContext(ctx).Model(&models.User{}).Where("organization_id = ?", orgID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	var users []*models.User
+	if err := query.
+		Order("email ASC").
+		Offset(pagination.GetOffset()).
+		Limit(pagination.GetLimit()).
+		Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return NewPaginatedResult(users, total, pagination), nil
+}
+
+// UpdateUser saves the name and role of a user. The owners of the user's
+// organization are locked while the last of them is kept from being demoted.
+func (r *organizationRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if user.Role != models.UserRoleOwner {
+			if err := checkNotLastOwner(tx, user.OrganizationID, user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Model(user).Select("name", "role", "updated_at").Updates(user).Error
+	})
+	if err != nil {
+		if err.Error() == "organization must keep at least one owner" {
+			return err
+		}
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	return nil
+}
+
+// DeleteUser soft deletes a user and removes them from their teams. The last
+// owner of an organization cannot be deleted.
+func (r *organizationRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Where("id = ?", id).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("user not found")
+			}
+			return err
+		}
+		if err := checkNotLastOwner(tx, user.OrganizationID, id); err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.User{}).Error
+	})
+	if err != nil {
+		if err.Error() == "user not found" || err.Error() == "organization must keep at least one owner" {
+			return err
+		}
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+	return nil
+}
+
+// checkNotLastOwner locks the owners of an organization and returns an error
+// if userID is the only one of them. Concurrent demotions and deletions wait on
+// the lock, so two owners cannot each remove the other.
+func checkNotLastOwner(tx *gorm.DB, orgID, userID uuid.UUID) error {
+	var owners []uuid.UUID
+	if err := tx.Model(&models.User{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("organization_id = ? AND role = ?", orgID, models.UserRoleOwner).
+		Pluck("id", &owners).Error; err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		return fmt.Errorf("organization must keep at least one owner")
+	}
+	return nil
+}
+
+// CreateTeam creates a new team
+func (r *organizationRepository) CreateTeam(ctx context.Context, team *models.Team) error {
+	if err := r.db.WithContext(ctx).Create(team).Error; err != nil {
+		return fmt.Errorf("failed to create team: %w", err)
+	}
+	return nil
+}
+
+// GetTeam retrieves a team by ID
+func (r *organizationRepository) GetTeam(ctx context.Context, id uuid.UUID) (*models.Team, error) {
+	var team models.Team
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("team not found")
+		}
+		return nil, fmt.Errorf("failed to get team: %w", err)
+	}
+	return &team, nil
+}
+
+// ListTeams retrieves the teams of an organization by name
+func (r *organizationRepository) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*models.Team, error) {
+	var teams []*models.Team
+	if err := r.db.WithContext(ctx).
+		Where("organization_id = ?", orgID).
+		Order("name ASC").
+		Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("failed to list teams: %w", err)
+	}
+	return teams, nil
+}
+
+// DeleteTeam deletes a team and its memberships
+func (r *organizationRepository) DeleteTeam(ctx context.Context, id uuid.UUID) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", id).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&models.Team{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete team: %w", err)
+	}
+	return nil
+}
+
+// AddTeamMember adds a user to a team; adding an existing member does nothing
+func (r *organizationRepository) AddTeamMember(ctx context.Context, member *models.TeamMember) error {
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(member).Error; err != nil {
+		return fmt.Errorf("failed to add team member: %w", err)
+	}
+	return nil
+}
+
+// RemoveTeamMember removes a user from a team
+func (r *organizationRepository) RemoveTeamMember(ctx context.Context, teamID, userID uuid.UUID) error {
+	result := r.db.WithContext(ctx).Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to remove team member: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("team member not found")
+	}
+	return nil
+}
+
+// ListTeamMembers retrieves the users of a team by email
+func (r *organizationRepository) ListTeamMembers(ctx context.Context, teamID uuid.UUID) ([]*models.User, error) {
+	var users []*models.User
+	if err := r.db.WithContext(ctx).
+		Joins("JOIN team_members ON team_members.user_id = users.id").
+		Where("team_members.team_id = ?", teamID).
+		Order("users.email ASC").
+		Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list team members: %w", err)
+	}
+	return users, nil
+}
+
+// IsTeamMember returns true if a user belongs to a team
+func (r *organizationRepository) IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.TeamMember{}).
+		Where("team_id = ? AND user_id = ?", teamID, userID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check team membership: %w", err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PartitionRepository defines the interface for managing PostgreSQL range partitions
+type PartitionRepository interface {
+	IsPartitioned(ctx context.Context, table string) (bool, error)
+	ListPartitions(ctx context.Context, table string) ([]*PartitionInfo, error)
+	CreatePartition(ctx context.Context, table, partition string, from, to time.Time) error
+	DropPartition(ctx context.Context, table, partition string) error
+	ArchivePartition(ctx context.Context, table, partition, schema string) error
+}
+
+// PartitionInfo describes one partition of a partitioned table
+type PartitionInfo struct {
+	Name          string `json:"name"`
+	Bound         string `json:"bound"` // partition bound as reported by PostgreSQL
+	SizeBytes     int64  `json:"size_bytes"`
+	EstimatedRows int64  `json:"estimated_rows"`
+}
+
+// partitionRepository implements PartitionRepository interface
+type partitionRepository struct {
+	*baseRepository
+}
+
+// NewPartitionRepository creates a new partition repository
+func NewPartitionRepository(db *gorm.DB) PartitionRepository {
+	return &partitionRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// IsPartitioned returns true if table exists and is a partitioned table
+func (r *partitionRepository) IsPartitioned(ctx context.Context, table string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = ? AND c.relkind = 'p' AND n.nspname = current_schema()
+	`, table).Scan(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check partitioning of %s: %w", table, err)
+	}
+	return count > 0, nil
+}
+
+// ListPartitions retrieves the partitions attached to table, ordered by name
+func (r *partitionRepository) ListPartitions(ctx context.Context, table string) ([]*PartitionInfo, error) {
+	var partitions []*PartitionInfo
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT
+			child.relname AS name,
+			pg_get_expr(child.relpartbound, child.oid) AS bound,
+			pg_total_relation_size(child.oid) AS size_bytes,
+			GREATEST(child.reltuples, 0)::BIGINT AS estimated_rows
+		FROM pg_inherits i
+		JOIN pg_class parent ON parent.oid = i.inhparent
+		JOIN pg_class child ON child.oid = i.inhrelid
+		JOIN pg_namespace n ON n.oid = parent.relnamespace
+		WHERE parent.relname = ? AND n.nspname = current_schema()
+		ORDER BY child.relname
+	`, table).Scan(&partitions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list partitions of %s: %w", table, err)
+	}
+	return partitions, nil
+}
+
+// CreatePartition creates a partition of table covering [from, to) if it does not exist
+func (r *partitionRepository) CreatePartition(ctx context.Context, table, partition string, from, to time.Time) error {
+	query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')",
+		quoteIdent(partition), quoteIdent(table), from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	if err := r.db.WithContext(ctx).Exec(query).Error; err != nil {
+		return fmt.Errorf("failed to create partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// DropPartition detaches a partition from table and drops it
+func (r *partitionRepository) DropPartition(ctx context.Context, table, partition string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := detachPartition(tx, table, partition); err != nil {
+			return err
+		}
+		return tx.Exec("DROP TABLE " + quoteIdent(partition)).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to drop partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// ArchivePartition detaches a partition from table and moves it into schema,
+// creating the schema if needed. The archived table keeps its name.
+func (r *partitionRepository) ArchivePartition(ctx context.Context, table, partition, schema string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := detachPartition(tx, table, partition); err != nil {
+			return err
+		}
+		if err := tx.Exec("CREATE SCHEMA IF NOT EXISTS " + quoteIdent(schema)).Error; err != nil {
+			return err
+		}
+		return tx.Exec(fmt.Sprintf("ALTER TABLE %s SET SCHEMA %s", quoteIdent(partition), quoteIdent(schema))).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to archive partition %s: %w", partition, err)
+	}
+	return nil
+}
+
+// detachPartition detaches partition from table so it no longer receives rows
+func detachPartition(tx *gorm.DB, table, partition string) error {
+	return tx.Exec(fmt.Sprintf("ALTER TABLE %s DETACH PARTITION %s", quoteIdent(table), quoteIdent(partition))).Error
+}
+
+// quoteIdent quotes a PostgreSQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// PaymentRepository defines the interface for payment provider data access
+type PaymentRepository interface {
+	GetCustomer(ctx context.Context, apiKeyID uuid.UUID, provider string) (*models.PaymentCustomer, error)
+	CreateCustomer(ctx context.Context, customer *models.PaymentCustomer) (*models.PaymentCustomer, error)
+	HasEvent(ctx context.Context, provider, eventID string) (bool, error)
+	RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error)
+}
+
+// paymentRepository implements PaymentRepository interface
+type paymentRepository struct {
+	*baseRepository
+}
+
+// NewPaymentRepository creates a new payment repository
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &paymentRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// GetCustomer retrieves the provider customer of an API key
+func (r *paymentRepository) GetCustomer(ctx context.Context, apiKeyID uuid.UUID, provider string) (*models.PaymentCustomer, error) {
+	var customer models.PaymentCustomer
+	if err := r.db.WithContext(ctx).
+		Where("api_key_id = ? AND provider = ?", apiKeyID, provider).
+		First(&customer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("payment customer not found")
+		}
+		return nil, fmt.Errorf("failed to get payment customer: %w", err)
+	}
+	return &customer, nil
+}
+
+// CreateCustomer stores the provider customer of an API key. If another one
+// was stored first, that one is returned instead.
+func (r *paymentRepository) CreateCustomer(ctx context.Context, customer *models.PaymentCustomer) (*models.PaymentCustomer, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(customer)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to create payment customer: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return r.GetCustomer(ctx, customer.APIKeyID, customer.Provider)
+	}
+	return customer, nil
+}
+
+// HasEvent returns true if a provider event was already recorded
+func (r *paymentRepository) HasEvent(ctx context.Context, provider, eventID string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.PaymentEvent{}).
+		Where("provider = ? AND event_id = ?", provider, eventID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("failed to check payment event: %w", err)
+	}
+	return count > 0, nil
+}
+
+// RecordEvent records a provider event and returns false if it was already
+// recorded
+func (r *paymentRepository) RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(event)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to record payment event: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
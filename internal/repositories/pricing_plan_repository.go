@@ -0,0 +1,72 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// PricingPlanRepository defines the interface for pricing plan data access
+type PricingPlanRepository interface {
+	Create(ctx context.Context, plan *models.PricingPlan) error
+	GetByTier(ctx context.Context, tier models.APIKeyTier) (*models.PricingPlan, error)
+	Update(ctx context.Context, plan *models.PricingPlan) error
+	GetAll(ctx context.Context) ([]*models.PricingPlan, error)
+}
+
+// pricingPlanRepository implements PricingPlanRepository interface
+type pricingPlanRepository struct {
+	*baseRepository
+}
+
+// NewPricingPlanRepository creates a new pricing plan repository
+func NewPricingPlanRepository(db *gorm.DB) PricingPlanRepository {
+	return &pricingPlanRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Create creates a new pricing plan
+func (r *pricingPlanRepository) Create(ctx context.Context, plan *models.PricingPlan) error {
+	if err := r.db.WithContext(ctx).Create(plan).Error; err != nil {
+		return fmt.Errorf("failed to create pricing plan: %w", err)
+	}
+	return nil
+}
+
+// GetByTier retrieves the pricing plan of a tier
+func (r *pricingPlanRepository) GetByTier(ctx context.Context, tier models.APIKeyTier) (*models.PricingPlan, error) {
+	var plan models.PricingPlan
+	if err := r.db.WithContext(ctx).Where("tier = ?", tier).First(&plan).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("pricing plan not found")
+		}
+		return nil, fmt.Errorf("failed to get pricing plan: %w", err)
+	}
+	return &plan, nil
+}
+
+// Update saves every field of a pricing plan
+func (r *pricingPlanRepository) Update(ctx context.Context, plan *models.PricingPlan) error {
+	result := r.db.WithContext(ctx).Select("*").Omit("created_at").Updates(plan)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update pricing plan: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("pricing plan not found")
+	}
+	return nil
+}
+
+// GetAll retrieves every pricing plan
+func (r *pricingPlanRepository) GetAll(ctx context.Context) ([]*models.PricingPlan, error) {
+	var plans []*models.PricingPlan
+	if err := r.db.WithContext(ctx).Order("base_fee ASC, tier ASC").Find(&plans).Error; err != nil {
+		return nil, fmt.Errorf("failed to get pricing plans: %w", err)
+	}
+	return plans, nil
+}
@@ -0,0 +1,59 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// UsageExportRepository defines the interface for usage export data access
+type UsageExportRepository interface {
+	Create(ctx context.Context, export *models.UsageExport) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.UsageExport, error)
+	Update(ctx context.Context, export *models.UsageExport) error
+}
+
+// usageExportRepository implements UsageExportRepository interface
+type usageExportRepository struct {
+	*baseRepository
+}
+
+// NewUsageExportRepository creates a new usage export repository
+func NewUsageExportRepository(db *gorm.DB) UsageExportRepository {
+	return &usageExportRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Create creates a new usage export
+func (r *usageExportRepository) Create(ctx context.Context, export *models.UsageExport) error {
+	if err := r.db.WithContext(ctx).Create(export).Error; err != nil {
+		return fmt.Errorf("failed to create usage export: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a usage export by ID
+func (r *usageExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UsageExport, error) {
+	var export models.UsageExport
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("usage export not found")
+		}
+		return nil, fmt.Errorf("failed to get usage export: %w", err)
+	}
+	return &export, nil
+}
+
+// Update saves the status and result of a usage export
+func (r *usageExportRepository) Update(ctx context.Context, export *models.UsageExport) error {
+	if err := r.db.WithContext(ctx).Save(export).Error; err != nil {
+		return fmt.Errorf("failed to update usage export: %w", err)
+	}
+	return nil
+}
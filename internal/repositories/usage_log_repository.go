@@ -3,12 +3,14 @@ package repositories
 import (
 	"context"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/sketch"
 )
 
 // UsageLogRepository defines the interface for usage log data access
@@ -19,10 +21,16 @@ type UsageLogRepository interface {
 	List(ctx context.Context, filter *UsageLogFilter, pagination *PaginationParams) (*PaginatedResult, error)
 	GetUsageByAPIKey(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*models.UsageLog, error)
 	GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*UsageStats, error)
-	GetEndpointStats(ctx context.Context, startTime, endTime time.Time) ([]*EndpointStats, error)
+	GetEndpointStats(ctx context.Context, apiKeyID *uuid.UUID, startTime, endTime time.Time) ([]*EndpointStats, error)
 	GetTopAPIKeys(ctx context.Context, startTime, endTime time.Time, limit int) ([]*APIKeyUsage, error)
 	DeleteOldLogs(ctx context.Context, retentionDays int) (int64, error)
 	GetHourlyUsage(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*HourlyUsage, error)
+	GetUsageBreakdown(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*UsageBreakdown, error)
+	CountRequests(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (int64, error)
+	CountFiltered(ctx context.Context, filter *UsageLogFilter) (int64, error)
+	StreamUsageLogs(ctx context.Context, filter *UsageLogFilter, batchSize int, fn func(logs []*models.UsageLog) error) error
+	GetNewAttributeValues(ctx context.Context, apiKeyID uuid.UUID, attribute UsageAttribute, baselineStart, since, until time.Time, limit int) (*NewAttributeValues, error)
+	CountConsecutiveFailures(ctx context.Context, apiKeyID uuid.UUID, since time.Time) (int64, error)
 }
 
 // UsageLogFilter contains filter parameters for usage log queries
@@ -47,6 +55,12 @@ type UsageStats struct {
 	RateLimitedRequests int64  `json:"rate_limited_requests"`
 	AvgResponseTime    float64 `json:"avg_response_time"`
 	TotalBandwidth     int64   `json:"total_bandwidth"`
+
+	MaxResponseTime int64              `json:"max_response_time"`
+	Latency         LatencyPercentiles `json:"latency"`
+	StatusClasses   StatusClassCounts  `json:"status_classes"`
+	RequestBytes    int64              `json:"request_bytes"`
+	ResponseBytes   int64              `json:"response_bytes"`
 }
 
 // EndpointStats contains statistics for an endpoint
@@ -56,6 +70,30 @@ type EndpointStats struct {
 	TotalRequests  int64   `json:"total_requests"`
 	AvgResponseTime float64 `json:"avg_response_time"`
 	ErrorRate      float64 `json:"error_rate"`
+	TotalBandwidth int64   `json:"total_bandwidth"`
+
+	MaxResponseTime int64              `json:"max_response_time"`
+	Latency         LatencyPercentiles `json:"latency"`
+	StatusClasses   StatusClassCounts  `json:"status_classes"`
+	RequestBytes    int64              `json:"request_bytes"`
+	ResponseBytes   int64              `json:"response_bytes"`
+}
+
+// LatencyPercentiles contains response time percentiles in milliseconds. They
+// are estimated from latency sketches and are within sketch.RelativeAccuracy
+// of the exact values.
+type LatencyPercentiles struct {
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// StatusClassCounts contains request counts by HTTP status class
+type StatusClassCounts struct {
+	Success     int64 `json:"2xx"`
+	Redirect    int64 `json:"3xx"`
+	ClientError int64 `json:"4xx"`
+	ServerError int64 `json:"5xx"`
 }
 
 // APIKeyUsage contains usage information for an API key
@@ -67,9 +105,49 @@ type APIKeyUsage struct {
 
 // HourlyUsage contains hourly usage data
 type HourlyUsage struct {
-	Hour          time.Time `json:"hour"`
-	TotalRequests int64     `json:"total_requests"`
-	AvgResponseTime float64 `json:"avg_response_time"`
+	Hour                time.Time `json:"hour"`
+	TotalRequests       int64     `json:"total_requests"`
+	AvgResponseTime     float64   `json:"avg_response_time"`
+	TotalResponseTime   int64     `json:"total_response_time"`
+	ErrorRequests       int64     `json:"error_requests"`
+	RateLimitedRequests int64     `json:"rate_limited_requests"`
+	TotalBandwidth      int64     `json:"total_bandwidth"`
+}
+
+// UsageBreakdown contains request counts split by status code, country and endpoint
+type UsageBreakdown struct {
+	StatusCodes     map[int]int64    `json:"status_codes"`
+	Countries       map[string]int64 `json:"countries"`
+	UniqueEndpoints int64            `json:"unique_endpoints"`
+}
+
+// UsageAttribute is a client attribute recorded with every usage log
+type UsageAttribute string
+
+const (
+	UsageAttributeCountry   UsageAttribute = "country"
+	UsageAttributeIPAddress UsageAttribute = "ip_address"
+	UsageAttributeUserAgent UsageAttribute = "user_agent"
+)
+
+// IsValid returns true for a known attribute
+func (a UsageAttribute) IsValid() bool {
+	return a == UsageAttributeCountry || a == UsageAttributeIPAddress || a == UsageAttributeUserAgent
+}
+
+// NewAttributeValue is an attribute value an API key started using
+type NewAttributeValue struct {
+	Value     string    `json:"value"`
+	Requests  int64     `json:"requests"`
+	FirstSeen time.Time `json:"first_seen"`
+}
+
+// NewAttributeValues contains the attribute values an API key used in a
+// window but not during the baseline before it. Values holds the most used
+// ones; Total counts all of them.
+type NewAttributeValues struct {
+	Total  int64                `json:"total"`
+	Values []*NewAttributeValue `json:"values"`
 }
 
 // usageLogRepository implements UsageLogRepository interface
@@ -127,41 +205,7 @@ func (r *usageLogRepository) GetByID(ctx context.Context, id uint64) (*models.Us
 
 // List retrieves usage logs with filtering and pagination
 func (r *usageLogRepository) List(ctx context.Context, filter *UsageLogFilter, pagination *PaginationParams) (*PaginatedResult, error) {
-	query := r.db.WithContext(ctx).Model(&models.UsageLog{})
-
-	// Apply filters
-	if filter != nil {
-		if filter.APIKeyID != nil {
-			query = query.Where("api_key_id = ?", *filter.APIKeyID)
-		}
-		if filter.Endpoint != "" {
-			query = query.Where("endpoint = ?", filter.Endpoint)
-		}
-		if filter.Method != "" {
-			query = query.Where("method = ?", filter.Method)
-		}
-		if filter.StatusCode != nil {
-			query = query.Where("status_code = ?", *filter.StatusCode)
-		}
-		if filter.IPAddress != "" {
-			query = query.Where("ip_address = ?", filter.IPAddress)
-		}
-		if filter.Country != "" {
-			query = query.Where("country = ?", filter.Country)
-		}
-		if filter.StartTime != nil {
-			query = query.Where("timestamp >= ?", *filter.StartTime)
-		}
-		if filter.EndTime != nil {
-			query = query.Where("timestamp <= ?", *filter.EndTime)
-		}
-		if filter.MinResponse != nil {
-			query = query.Where("response_time >= ?", *filter.MinResponse)
-		}
-		if filter.MaxResponse != nil {
-			query = query.Where("response_time <= ?", *filter.MaxResponse)
-		}
-	}
+	query := applyUsageLogFilter(r.db.WithContext(ctx).Model(&models.UsageLog{}), filter)
 
 	// Count total records
 	var total int64
@@ -182,6 +226,69 @@ func (r *usageLogRepository) List(ctx context.Context, filter *UsageLogFilter, p
 	return NewPaginatedResult(logs, total, pagination), nil
 }
 
+// StreamUsageLogs calls fn with consecutive batches of the usage logs matching
+// filter, in ID order, without loading them all into memory
+func (r *usageLogRepository) StreamUsageLogs(ctx context.Context, filter *UsageLogFilter, batchSize int, fn func(logs []*models.UsageLog) error) error {
+	var batch []*models.UsageLog
+	result := applyUsageLogFilter(r.db.WithContext(ctx).Model(&models.UsageLog{}), filter).
+		FindInBatches(&batch, batchSize, func(tx *gorm.DB, _ int) error {
+			return fn(batch)
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to stream usage logs: %w", result.Error)
+	}
+	return nil
+}
+
+// CountFiltered counts the usage logs matching filter
+func (r *usageLogRepository) CountFiltered(ctx context.Context, filter *UsageLogFilter) (int64, error) {
+	var total int64
+	if err := applyUsageLogFilter(r.db.WithContext(ctx).Model(&models.UsageLog{}), filter).Count(&total).Error; err != nil {
+		return 0, fmt.Errorf("failed to count usage logs: %w", err)
+	}
+	return total, nil
+}
+
+// applyUsageLogFilter adds the conditions of filter to query
+func applyUsageLogFilter(query *gorm.DB, filter *UsageLogFilter) *gorm.DB {
+	if filter == nil {
+		return query
+	}
+
+	if filter.APIKeyID != nil {
+		query = query.Where("api_key_id = ?", *filter.APIKeyID)
+	}
+	if filter.Endpoint != "" {
+		query = query.Where("endpoint = ?", filter.Endpoint)
+	}
+	if filter.Method != "" {
+		query = query.Where("method = ?", filter.Method)
+	}
+	if filter.StatusCode != nil {
+		query = query.Where("status_code = ?", *filter.StatusCode)
+	}
+	if filter.IPAddress != "" {
+		query = query.Where("ip_address = ?", filter.IPAddress)
+	}
+	if filter.Country != "" {
+		query = query.Where("country = ?", filter.Country)
+	}
+	if filter.StartTime != nil {
+		query = query.Where("timestamp >= ?", *filter.StartTime)
+	}
+	if filter.EndTime != nil {
+		query = query.Where("timestamp <= ?", *filter.EndTime)
+	}
+	if filter.MinResponse != nil {
+		query = query.Where("response_time >= ?", *filter.MinResponse)
+	}
+	if filter.MaxResponse != nil {
+		query = query.Where("response_time <= ?", *filter.MaxResponse)
+	}
+
+	return query
+}
+
 // GetUsageByAPIKey retrieves usage logs for a specific API key within a time range
 func (r *usageLogRepository) GetUsageByAPIKey(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*models.UsageLog, error) {
 	var logs []*models.UsageLog
@@ -195,77 +302,161 @@ func (r *usageLogRepository) GetUsageByAPIKey(ctx context.Context, apiKeyID uuid
 	return logs, nil
 }
 
-// GetUsageStats retrieves aggregated usage statistics for an API key
+// GetUsageStats retrieves aggregated usage statistics for an API key. Stats
+// are served from usage rollups; only the edges of the range that no completed
+// bucket covers are read from raw logs. The range is [startTime, endTime).
 func (r *usageLogRepository) GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*UsageStats, error) {
-	var stats UsageStats
-
-	// Use raw SQL for better performance with aggregations
-	query := `
-		SELECT 
-			COUNT(*) as total_requests,
-			COUNT(CASE WHEN status_code >= 200 AND status_code < 300 THEN 1 END) as successful_requests,
-			COUNT(CASE WHEN status_code >= 400 THEN 1 END) as failed_requests,
-			COUNT(CASE WHEN status_code = 429 THEN 1 END) as rate_limited_requests,
-			COALESCE(AVG(response_time), 0) as avg_response_time,
-			COALESCE(SUM(request_size + response_size), 0) as total_bandwidth
-		FROM usage_logs
-		WHERE api_key_id = ? AND timestamp >= ? AND timestamp <= ?
-	`
-
-	if err := r.db.WithContext(ctx).Raw(query, apiKeyID, startTime, endTime).Scan(&stats).Error; err != nil {
+	q := usageQuery{
+		APIKeyID: &apiKeyID,
+		Start:    startTime,
+		End:      endTime,
+	}
+	aggregates, err := aggregateUsage(ctx, r.db, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage stats: %w", err)
+	}
+	sketches, err := aggregateLatency(ctx, r.db, q)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get usage stats: %w", err)
 	}
 
-	return &stats, nil
-}
-
-// GetEndpointStats retrieves statistics grouped by endpoint
-func (r *usageLogRepository) GetEndpointStats(ctx context.Context, startTime, endTime time.Time) ([]*EndpointStats, error) {
-	var stats []*EndpointStats
+	stats := &UsageStats{}
+	for _, aggregate := range aggregates {
+		stats.TotalRequests += aggregate.Requests
+		stats.SuccessfulRequests += aggregate.SuccessRequests
+		stats.FailedRequests += aggregate.ErrorRequests
+		stats.RateLimitedRequests += aggregate.RateLimitedRequests
+		stats.TotalBandwidth += aggregate.RequestBytes + aggregate.ResponseBytes
+		if aggregate.Requests > 0 {
+			stats.AvgResponseTime = aggregate.AvgResponseTime()
+		}
+		stats.MaxResponseTime = aggregate.MaxResponseTime
+		stats.Latency = latencyPercentiles(sketches[aggregate.key()])
+		stats.StatusClasses = statusClassCounts(aggregate)
+		stats.RequestBytes = aggregate.RequestBytes
+		stats.ResponseBytes = aggregate.ResponseBytes
+	}
 
-	query := `
-		SELECT 
-			endpoint,
-			method,
-			COUNT(*) as total_requests,
-			COALESCE(AVG(response_time), 0) as avg_response_time,
-			COALESCE(CAST(COUNT(CASE WHEN status_code >= 400 THEN 1 END) AS FLOAT) / COUNT(*) * 100, 0) as error_rate
-		FROM usage_logs
-		WHERE timestamp >= ? AND timestamp <= ?
-		GROUP BY endpoint, method
-		ORDER BY total_requests DESC
-	`
+	return stats, nil
+}
 
-	if err := r.db.WithContext(ctx).Raw(query, startTime, endTime).Scan(&stats).Error; err != nil {
+// GetEndpointStats retrieves statistics grouped by endpoint, for one API key
+// or, when apiKeyID is nil, for all of them
+func (r *usageLogRepository) GetEndpointStats(ctx context.Context, apiKeyID *uuid.UUID, startTime, endTime time.Time) ([]*EndpointStats, error) {
+	q := usageQuery{
+		APIKeyID: apiKeyID,
+		Start:    startTime,
+		End:      endTime,
+		GroupBy:  []usageDimension{usageByEndpoint, usageByMethod},
+	}
+	aggregates, err := aggregateUsage(ctx, r.db, q)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
+	}
+	sketches, err := aggregateLatency(ctx, r.db, q)
+	if err != nil {
 		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
 	}
 
+	stats := make([]*EndpointStats, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		stat := &EndpointStats{
+			Endpoint:        aggregate.Endpoint,
+			Method:          aggregate.Method,
+			TotalRequests:   aggregate.Requests,
+			AvgResponseTime: aggregate.AvgResponseTime(),
+			TotalBandwidth:  aggregate.RequestBytes + aggregate.ResponseBytes,
+			MaxResponseTime: aggregate.MaxResponseTime,
+			Latency:         latencyPercentiles(sketches[aggregate.key()]),
+			StatusClasses:   statusClassCounts(aggregate),
+			RequestBytes:    aggregate.RequestBytes,
+			ResponseBytes:   aggregate.ResponseBytes,
+		}
+		if aggregate.Requests > 0 {
+			stat.ErrorRate = float64(aggregate.ErrorRequests) / float64(aggregate.Requests) * 100
+		}
+		stats = append(stats, stat)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].TotalRequests > stats[j].TotalRequests
+	})
+
 	return stats, nil
 }
 
 // GetTopAPIKeys retrieves the top API keys by usage
 func (r *usageLogRepository) GetTopAPIKeys(ctx context.Context, startTime, endTime time.Time, limit int) ([]*APIKeyUsage, error) {
-	var usage []*APIKeyUsage
+	aggregates, err := aggregateUsage(ctx, r.db, usageQuery{
+		Start:   startTime,
+		End:     endTime,
+		GroupBy: []usageDimension{usageByAPIKey},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top api keys: %w", err)
+	}
 
-	query := `
-		SELECT 
-			api_key_id,
-			COUNT(*) as total_requests,
-			COALESCE(SUM(request_size + response_size), 0) as total_bandwidth
-		FROM usage_logs
-		WHERE timestamp >= ? AND timestamp <= ?
-		GROUP BY api_key_id
-		ORDER BY total_requests DESC
-		LIMIT ?
-	`
+	usage := make([]*APIKeyUsage, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		usage = append(usage, &APIKeyUsage{
+			APIKeyID:       aggregate.APIKeyID,
+			TotalRequests:  aggregate.Requests,
+			TotalBandwidth: aggregate.RequestBytes + aggregate.ResponseBytes,
+		})
+	}
 
-	if err := r.db.WithContext(ctx).Raw(query, startTime, endTime, limit).Scan(&usage).Error; err != nil {
-		return nil, fmt.Errorf("failed to get top api keys: %w", err)
+	sort.SliceStable(usage, func(i, j int) bool {
+		return usage[i].TotalRequests > usage[j].TotalRequests
+	})
+	if limit > 0 && len(usage) > limit {
+		usage = usage[:limit]
 	}
 
 	return usage, nil
 }
 
+// GetNewAttributeValues retrieves the values of attribute an API key used in
+// [since, until) that it never used in [baselineStart, since), most requests
+// first and at most limit of them. Empty values are ignored.
+func (r *usageLogRepository) GetNewAttributeValues(ctx context.Context, apiKeyID uuid.UUID, attribute UsageAttribute, baselineStart, since, until time.Time, limit int) (*NewAttributeValues, error) {
+	if !attribute.IsValid() {
+		return nil, fmt.Errorf("invalid usage attribute: %s", attribute)
+	}
+
+	query := fmt.Sprintf(`
+		SELECT value, requests, first_seen, COUNT(*) OVER () AS total
+		FROM (
+			SELECT w.%[1]s AS value, COUNT(*) AS requests, MIN(w.timestamp) AS first_seen
+			FROM usage_logs w
+			WHERE w.api_key_id = ? AND w.timestamp >= ? AND w.timestamp < ?
+				AND COALESCE(w.%[1]s, '') <> ''
+				AND NOT EXISTS (
+					SELECT 1 FROM usage_logs b
+					WHERE b.api_key_id = w.api_key_id AND b.timestamp >= ? AND b.timestamp < ?
+						AND b.%[1]s = w.%[1]s
+				)
+			GROUP BY w.%[1]s
+		) v
+		ORDER BY requests DESC, value
+		LIMIT ?
+	`, attribute)
+
+	var rows []struct {
+		NewAttributeValue
+		Total int64
+	}
+	if err := r.db.WithContext(ctx).Raw(query, apiKeyID, since, until, baselineStart, since, limit).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to get new %s values: %w", attribute, err)
+	}
+
+	values := &NewAttributeValues{Values: make([]*NewAttributeValue, 0, len(rows))}
+	for i := range rows {
+		values.Total = rows[i].Total
+		values.Values = append(values.Values, &rows[i].NewAttributeValue)
+	}
+	return values, nil
+}
+
 // DeleteOldLogs deletes usage logs older than the specified retention days
 func (r *usageLogRepository) DeleteOldLogs(ctx context.Context, retentionDays int) (int64, error) {
 	cutoffDate := time.Now().AddDate(0, 0, -retentionDays)
@@ -283,22 +474,125 @@ func (r *usageLogRepository) DeleteOldLogs(ctx context.Context, retentionDays in
 
 // GetHourlyUsage retrieves hourly usage statistics for an API key
 func (r *usageLogRepository) GetHourlyUsage(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*HourlyUsage, error) {
-	var usage []*HourlyUsage
+	aggregates, err := aggregateUsage(ctx, r.db, usageQuery{
+		APIKeyID:       &apiKeyID,
+		Start:          startTime,
+		End:            endTime,
+		GroupBy:        []usageDimension{usageByHour},
+		MaxGranularity: models.RollupHour,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly usage: %w", err)
+	}
+
+	usage := make([]*HourlyUsage, 0, len(aggregates))
+	for _, aggregate := range aggregates {
+		usage = append(usage, &HourlyUsage{
+			Hour:                aggregate.Hour,
+			TotalRequests:       aggregate.Requests,
+			AvgResponseTime:     aggregate.AvgResponseTime(),
+			TotalResponseTime:   aggregate.TotalResponseTime,
+			ErrorRequests:       aggregate.ErrorRequests,
+			RateLimitedRequests: aggregate.RateLimitedRequests,
+			TotalBandwidth:      aggregate.RequestBytes + aggregate.ResponseBytes,
+		})
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Hour.Before(usage[j].Hour)
+	})
+
+	return usage, nil
+}
+
+// GetUsageBreakdown retrieves request counts by status code and country, and
+// the number of distinct endpoints called
+func (r *usageLogRepository) GetUsageBreakdown(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*UsageBreakdown, error) {
+	aggregates, err := aggregateUsage(ctx, r.db, usageQuery{
+		APIKeyID: &apiKeyID,
+		Start:    startTime,
+		End:      endTime,
+		GroupBy:  []usageDimension{usageByEndpoint, usageByMethod, usageByStatus, usageByCountry},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage breakdown: %w", err)
+	}
+
+	breakdown := &UsageBreakdown{
+		StatusCodes: make(map[int]int64),
+		Countries:   make(map[string]int64),
+	}
+	endpoints := make(map[string]bool)
+	for _, aggregate := range aggregates {
+		breakdown.StatusCodes[aggregate.StatusCode] += aggregate.Requests
+		if aggregate.Country != "" {
+			breakdown.Countries[aggregate.Country] += aggregate.Requests
+		}
+		endpoints[aggregate.Endpoint+":"+aggregate.Method] = true
+	}
+	breakdown.UniqueEndpoints = int64(len(endpoints))
+
+	return breakdown, nil
+}
+
+// CountRequests counts the requests made with an API key in [startTime, endTime)
+func (r *usageLogRepository) CountRequests(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (int64, error) {
+	aggregates, err := aggregateUsage(ctx, r.db, usageQuery{
+		APIKeyID: &apiKeyID,
+		Start:    startTime,
+		End:      endTime,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count requests: %w", err)
+	}
+
+	var total int64
+	for _, aggregate := range aggregates {
+		total += aggregate.Requests
+	}
+	return total, nil
+}
 
-	query := `
-		SELECT 
-			DATE_TRUNC('hour', timestamp) as hour,
-			COUNT(*) as total_requests,
-			COALESCE(AVG(response_time), 0) as avg_response_time
+// CountConsecutiveFailures counts the failed requests (status 400 and above)
+// an API key made since its last successful request, looking no further back
+// than since
+func (r *usageLogRepository) CountConsecutiveFailures(ctx context.Context, apiKeyID uuid.UUID, since time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Raw(`
+		SELECT COUNT(*)
 		FROM usage_logs
-		WHERE api_key_id = ? AND timestamp >= ? AND timestamp <= ?
-		GROUP BY hour
-		ORDER BY hour ASC
-	`
+		WHERE api_key_id = ? AND timestamp >= ? AND status_code >= 400
+		  AND timestamp > COALESCE((
+			SELECT MAX(timestamp)
+			FROM usage_logs
+			WHERE api_key_id = ? AND timestamp >= ? AND status_code < 400
+		  ), '-infinity')
+	`, apiKeyID, since, apiKeyID, since).Scan(&count).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to count consecutive failures: %w", err)
+	}
+	return count, nil
+}
 
-	if err := r.db.WithContext(ctx).Raw(query, apiKeyID, startTime, endTime).Scan(&usage).Error; err != nil {
-		return nil, fmt.Errorf("failed to get hourly usage: %w", err)
+// latencyPercentiles reads the SLO percentiles from a latency sketch, which
+// may be nil when no latency bins cover the range
+func latencyPercentiles(s *sketch.Sketch) LatencyPercentiles {
+	if s == nil {
+		return LatencyPercentiles{}
 	}
+	return LatencyPercentiles{
+		P50: s.Quantile(0.50),
+		P95: s.Quantile(0.95),
+		P99: s.Quantile(0.99),
+	}
+}
 
-	return usage, nil
-}
\ No newline at end of file
+// statusClassCounts returns the request counts by status class of an aggregate
+func statusClassCounts(aggregate *usageAggregate) StatusClassCounts {
+	return StatusClassCounts{
+		Success:     aggregate.SuccessRequests,
+		Redirect:    aggregate.RedirectRequests,
+		ClientError: aggregate.ClientErrorRequests,
+		ServerError: aggregate.ServerErrorRequests,
+	}
+}
@@ -0,0 +1,615 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/sketch"
+)
+
+// UsageRollupRepository defines the interface for building usage rollups
+type UsageRollupRepository interface {
+	Rollup(ctx context.Context, granularity models.RollupGranularity, from, to time.Time) (int64, error)
+	GetCheckpoints(ctx context.Context) (map[models.RollupGranularity]time.Time, error)
+	SetCheckpoint(ctx context.Context, granularity models.RollupGranularity, rolledUpTo time.Time) error
+	GetEarliestSourceTime(ctx context.Context, granularity models.RollupGranularity) (*time.Time, error)
+	DeleteOldRollups(ctx context.Context, granularity models.RollupGranularity, before time.Time) (int64, error)
+}
+
+// usageRollupRepository implements UsageRollupRepository interface
+type usageRollupRepository struct {
+	*baseRepository
+}
+
+// NewUsageRollupRepository creates a new usage rollup repository
+func NewUsageRollupRepository(db *gorm.DB) UsageRollupRepository {
+	return &usageRollupRepository{
+		baseRepository: NewBaseRepository(db),
+	}
+}
+
+// Rollup recomputes every bucket of granularity in [from, to) from the next
+// finer level, together with the latency bins of those buckets. Existing
+// buckets in the range are replaced, so rolling up the same range twice is
+// harmless.
+func (r *usageRollupRepository) Rollup(ctx context.Context, granularity models.RollupGranularity, from, to time.Time) (int64, error) {
+	source, err := rollupSourceFor(granularity)
+	if err != nil {
+		return 0, err
+	}
+
+	table := granularity.TableName()
+	insert := fmt.Sprintf(`
+		INSERT INTO %s (api_key_id, bucket_start, endpoint, method, status_code, country,
+			request_count, total_response_time, max_response_time, request_bytes, response_bytes, updated_at)
+		SELECT
+			api_key_id,
+			%s AS bucket_start,
+			endpoint,
+			method,
+			status_code,
+			%s AS country,
+			SUM(%s),
+			SUM(%s),
+			MAX(%s),
+			SUM(%s),
+			SUM(%s),
+			NOW()
+		FROM %s
+		WHERE %s >= ? AND %s < ?
+		GROUP BY 1, 2, 3, 4, 5, 6
+		ON CONFLICT (api_key_id, bucket_start, endpoint, method, status_code, country) DO UPDATE SET
+			request_count = EXCLUDED.request_count,
+			total_response_time = EXCLUDED.total_response_time,
+			max_response_time = EXCLUDED.max_response_time,
+			request_bytes = EXCLUDED.request_bytes,
+			response_bytes = EXCLUDED.response_bytes,
+			updated_at = EXCLUDED.updated_at
+	`, table, bucketExpr(granularity, source.timeColumn), source.country,
+		source.requests, source.responseTime, source.maxResponseTime, source.requestBytes, source.responseBytes,
+		source.table, source.timeColumn, source.timeColumn)
+
+	latencyTable := granularity.LatencyTableName()
+	latencyInsert := fmt.Sprintf(`
+		INSERT INTO %s (api_key_id, bucket_start, endpoint, method, bin, request_count)
+		SELECT
+			api_key_id,
+			%s AS bucket_start,
+			endpoint,
+			method,
+			%s AS bin,
+			SUM(%s)
+		FROM %s
+		WHERE %s >= ? AND %s < ?
+		GROUP BY 1, 2, 3, 4, 5
+	`, latencyTable, bucketExpr(granularity, source.timeColumn), source.latencyBin, source.latencyCount,
+		source.latencyTable, source.timeColumn, source.timeColumn)
+
+	var rows int64
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Clear the range first so buckets whose source rows are gone do not linger
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE bucket_start >= ? AND bucket_start < ?", table), from, to).Error; err != nil {
+			return err
+		}
+
+		result := tx.Exec(insert, from, to)
+		if result.Error != nil {
+			return result.Error
+		}
+		rows = result.RowsAffected
+
+		if err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE bucket_start >= ? AND bucket_start < ?", latencyTable), from, to).Error; err != nil {
+			return err
+		}
+		return tx.Exec(latencyInsert, from, to).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to roll up %s usage: %w", granularity, err)
+	}
+
+	return rows, nil
+}
+
+// GetCheckpoints retrieves how far each rollup table is complete. Granularities
+// that have never been rolled up are missing from the map.
+func (r *usageRollupRepository) GetCheckpoints(ctx context.Context) (map[models.RollupGranularity]time.Time, error) {
+	return loadRollupCheckpoints(ctx, r.db)
+}
+
+// SetCheckpoint records that every bucket of granularity before rolledUpTo is final
+func (r *usageRollupRepository) SetCheckpoint(ctx context.Context, granularity models.RollupGranularity, rolledUpTo time.Time) error {
+	checkpoint := &models.UsageRollupCheckpoint{
+		Granularity: granularity,
+		RolledUpTo:  rolledUpTo,
+	}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "granularity"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rolled_up_to", "updated_at"}),
+	}).Create(checkpoint).Error; err != nil {
+		return fmt.Errorf("failed to set rollup checkpoint: %w", err)
+	}
+	return nil
+}
+
+// GetEarliestSourceTime retrieves the oldest timestamp a granularity can be
+// built from, or nil if its source is empty
+func (r *usageRollupRepository) GetEarliestSourceTime(ctx context.Context, granularity models.RollupGranularity) (*time.Time, error) {
+	source, err := rollupSourceFor(granularity)
+	if err != nil {
+		return nil, err
+	}
+
+	var earliest sql.NullTime
+	query := fmt.Sprintf("SELECT MIN(%s) FROM %s", source.timeColumn, source.table)
+	if err := r.db.WithContext(ctx).Raw(query).Row().Scan(&earliest); err != nil {
+		return nil, fmt.Errorf("failed to get earliest %s usage: %w", granularity, err)
+	}
+	if !earliest.Valid {
+		return nil, nil
+	}
+	return &earliest.Time, nil
+}
+
+// DeleteOldRollups deletes buckets, and their latency bins, that start before
+// a cutoff. Only deleted rollup rows are counted.
+func (r *usageRollupRepository) DeleteOldRollups(ctx context.Context, granularity models.RollupGranularity, before time.Time) (int64, error) {
+	if !granularity.IsValid() {
+		return 0, fmt.Errorf("invalid rollup granularity: %s", granularity)
+	}
+
+	result := r.db.WithContext(ctx).Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE bucket_start < ?", granularity.TableName()), before)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete old rollups: %w", result.Error)
+	}
+
+	if err := r.db.WithContext(ctx).Exec(
+		fmt.Sprintf("DELETE FROM %s WHERE bucket_start < ?", granularity.LatencyTableName()), before).Error; err != nil {
+		return 0, fmt.Errorf("failed to delete old latency bins: %w", err)
+	}
+
+	return result.RowsAffected, nil
+}
+
+// loadRollupCheckpoints reads the rollup checkpoints into a map
+func loadRollupCheckpoints(ctx context.Context, db *gorm.DB) (map[models.RollupGranularity]time.Time, error) {
+	var checkpoints []models.UsageRollupCheckpoint
+	if err := db.WithContext(ctx).Find(&checkpoints).Error; err != nil {
+		return nil, fmt.Errorf("failed to get rollup checkpoints: %w", err)
+	}
+
+	result := make(map[models.RollupGranularity]time.Time, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		result[checkpoint.Granularity] = checkpoint.RolledUpTo
+	}
+	return result, nil
+}
+
+// usageSource maps the aggregate columns of usage_logs or a rollup table, and
+// the latency bin columns of usage_logs or a latency table
+type usageSource struct {
+	table           string
+	timeColumn      string
+	country         string
+	requests        string
+	responseTime    string
+	maxResponseTime string
+	requestBytes    string
+	responseBytes   string
+
+	latencyTable string
+	latencyBin   string
+	latencyCount string
+}
+
+// rawUsageSource reads individual requests from usage_logs
+var rawUsageSource = usageSource{
+	table:           "usage_logs",
+	timeColumn:      "timestamp",
+	country:         "COALESCE(country, '')",
+	requests:        "1",
+	responseTime:    "COALESCE(response_time, 0)",
+	maxResponseTime: "COALESCE(response_time, 0)",
+	requestBytes:    "COALESCE(request_size, 0)",
+	responseBytes:   "COALESCE(response_size, 0)",
+
+	latencyTable: "usage_logs",
+	latencyBin:   sketch.IndexSQL("COALESCE(response_time, 0)"),
+	latencyCount: "1",
+}
+
+// rollupUsageSource reads pre-aggregated buckets from a rollup table
+func rollupUsageSource(granularity models.RollupGranularity) usageSource {
+	return usageSource{
+		table:           granularity.TableName(),
+		timeColumn:      "bucket_start",
+		country:         "country",
+		requests:        "request_count",
+		responseTime:    "total_response_time",
+		maxResponseTime: "max_response_time",
+		requestBytes:    "request_bytes",
+		responseBytes:   "response_bytes",
+
+		latencyTable: granularity.LatencyTableName(),
+		latencyBin:   "bin",
+		latencyCount: "request_count",
+	}
+}
+
+// rollupSourceFor returns the source a granularity is built from
+func rollupSourceFor(granularity models.RollupGranularity) (usageSource, error) {
+	for i, g := range models.RollupGranularities {
+		if g != granularity {
+			continue
+		}
+		if i == 0 {
+			return rawUsageSource, nil
+		}
+		return rollupUsageSource(models.RollupGranularities[i-1]), nil
+	}
+	return usageSource{}, fmt.Errorf("invalid rollup granularity: %s", granularity)
+}
+
+// bucketExpr truncates a timestamp column to a UTC bucket start
+func bucketExpr(granularity models.RollupGranularity, column string) string {
+	return fmt.Sprintf("date_trunc('%s', %s AT TIME ZONE 'UTC') AT TIME ZONE 'UTC'", granularity, column)
+}
+
+// usageRange is a time range read from one source. An empty Granularity
+// means raw usage logs.
+type usageRange struct {
+	Granularity models.RollupGranularity
+	Start       time.Time
+	End         time.Time
+}
+
+// source returns the table the range is read from
+func (r usageRange) source() usageSource {
+	if r.Granularity == "" {
+		return rawUsageSource
+	}
+	return rollupUsageSource(r.Granularity)
+}
+
+// planUsageRanges splits [start, end) into the fewest ranges that can be read
+// from completed rollup buckets, using granularities up to maxGranularity.
+// Whatever cannot be covered by a complete bucket, such as a partial minute at
+// either edge or time after the minute checkpoint, is read from raw logs.
+func planUsageRanges(start, end time.Time, checkpoints map[models.RollupGranularity]time.Time, maxGranularity models.RollupGranularity) []usageRange {
+	levels := 0
+	for i, g := range models.RollupGranularities {
+		if g == maxGranularity {
+			levels = i + 1
+			break
+		}
+	}
+
+	var ranges []usageRange
+	var cover func(from, to time.Time, level int)
+	cover = func(from, to time.Time, level int) {
+		if !from.Before(to) {
+			return
+		}
+		if level < 0 {
+			ranges = append(ranges, usageRange{Start: from, End: to})
+			return
+		}
+
+		g := models.RollupGranularities[level]
+		complete, ok := checkpoints[g]
+		if !ok {
+			cover(from, to, level-1)
+			return
+		}
+
+		limit := to
+		if complete.Before(limit) {
+			limit = complete
+		}
+		lo, hi := g.Ceil(from), g.Truncate(limit)
+		if !lo.Before(hi) {
+			cover(from, to, level-1)
+			return
+		}
+
+		cover(from, lo, level-1)
+		ranges = append(ranges, usageRange{Granularity: g, Start: lo, End: hi})
+		cover(hi, to, level-1)
+	}
+	cover(start.UTC(), end.UTC(), levels-1)
+
+	return ranges
+}
+
+// usageDimension is a column aggregated usage can be grouped by
+type usageDimension string
+
+const (
+	usageByAPIKey   usageDimension = "api_key_id"
+	usageByEndpoint usageDimension = "endpoint"
+	usageByMethod   usageDimension = "method"
+	usageByStatus   usageDimension = "status_code"
+	usageByCountry  usageDimension = "country"
+	usageByHour     usageDimension = "hour"
+)
+
+// usageQuery describes an aggregate usage query
+type usageQuery struct {
+	APIKeyID       *uuid.UUID
+	Start          time.Time
+	End            time.Time
+	GroupBy        []usageDimension
+	MaxGranularity models.RollupGranularity // coarsest rollup the grouping allows
+}
+
+// usageAggregate is one group of aggregated usage. Only the fields named in
+// the query's GroupBy are set.
+type usageAggregate struct {
+	APIKeyID   uuid.UUID
+	Hour       time.Time
+	Endpoint   string
+	Method     string
+	StatusCode int
+	Country    string
+
+	Requests            int64
+	SuccessRequests     int64 // 2xx
+	RedirectRequests    int64 // 3xx
+	ClientErrorRequests int64 // 4xx
+	ServerErrorRequests int64 // 5xx
+	ErrorRequests       int64
+	RateLimitedRequests int64
+	TotalResponseTime   int64
+	MaxResponseTime     int64
+	RequestBytes        int64
+	ResponseBytes       int64
+}
+
+// AvgResponseTime returns the average response time in milliseconds
+func (a *usageAggregate) AvgResponseTime() float64 {
+	if a.Requests == 0 {
+		return 0
+	}
+	return float64(a.TotalResponseTime) / float64(a.Requests)
+}
+
+// usageGroupKey identifies a usageAggregate group when merging ranges
+type usageGroupKey struct {
+	APIKeyID   uuid.UUID
+	Hour       int64
+	Endpoint   string
+	Method     string
+	StatusCode int
+	Country    string
+}
+
+func (a *usageAggregate) key() usageGroupKey {
+	return usageGroupKey{
+		APIKeyID:   a.APIKeyID,
+		Hour:       a.Hour.Unix(),
+		Endpoint:   a.Endpoint,
+		Method:     a.Method,
+		StatusCode: a.StatusCode,
+		Country:    a.Country,
+	}
+}
+
+// add folds another aggregate of the same group into a
+func (a *usageAggregate) add(other *usageAggregate) {
+	a.Requests += other.Requests
+	a.SuccessRequests += other.SuccessRequests
+	a.RedirectRequests += other.RedirectRequests
+	a.ClientErrorRequests += other.ClientErrorRequests
+	a.ServerErrorRequests += other.ServerErrorRequests
+	a.ErrorRequests += other.ErrorRequests
+	a.RateLimitedRequests += other.RateLimitedRequests
+	a.TotalResponseTime += other.TotalResponseTime
+	if other.MaxResponseTime > a.MaxResponseTime {
+		a.MaxResponseTime = other.MaxResponseTime
+	}
+	a.RequestBytes += other.RequestBytes
+	a.ResponseBytes += other.ResponseBytes
+}
+
+// mergeUsageAggregates combines per-range results into one aggregate per group,
+// preserving the order in which groups first appear
+func mergeUsageAggregates(parts ...[]*usageAggregate) []*usageAggregate {
+	var merged []*usageAggregate
+	index := make(map[usageGroupKey]*usageAggregate)
+
+	for _, part := range parts {
+		for _, aggregate := range part {
+			if existing, ok := index[aggregate.key()]; ok {
+				existing.add(aggregate)
+				continue
+			}
+			copied := *aggregate
+			index[aggregate.key()] = &copied
+			merged = append(merged, &copied)
+		}
+	}
+
+	return merged
+}
+
+// aggregateUsage answers q from completed rollups, reading raw usage logs only
+// for the edges no rollup bucket covers
+func aggregateUsage(ctx context.Context, db *gorm.DB, q usageQuery) ([]*usageAggregate, error) {
+	ranges, err := planUsageQuery(ctx, db, q)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := make([][]*usageAggregate, 0, len(ranges))
+	for _, r := range ranges {
+		part, err := queryUsageAggregates(ctx, db, r.source(), q, r.Start, r.End)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+
+	return mergeUsageAggregates(parts...), nil
+}
+
+// planUsageQuery splits the range of q by the current rollup checkpoints
+func planUsageQuery(ctx context.Context, db *gorm.DB, q usageQuery) ([]usageRange, error) {
+	checkpoints, err := loadRollupCheckpoints(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	maxGranularity := q.MaxGranularity
+	if maxGranularity == "" {
+		maxGranularity = models.RollupDay
+	}
+
+	return planUsageRanges(q.Start, q.End, checkpoints, maxGranularity), nil
+}
+
+// queryUsageAggregates aggregates one source over [start, end)
+func queryUsageAggregates(ctx context.Context, db *gorm.DB, source usageSource, q usageQuery, start, end time.Time) ([]*usageAggregate, error) {
+	columns := groupColumns(source, q.GroupBy)
+	selects := append(append([]string{}, columns...),
+		fmt.Sprintf("COALESCE(SUM(%s), 0) AS requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code >= 200 AND status_code < 300 THEN %s ELSE 0 END), 0) AS success_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code >= 300 AND status_code < 400 THEN %s ELSE 0 END), 0) AS redirect_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code >= 400 AND status_code < 500 THEN %s ELSE 0 END), 0) AS client_error_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code >= 500 THEN %s ELSE 0 END), 0) AS server_error_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code >= 400 THEN %s ELSE 0 END), 0) AS error_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(CASE WHEN status_code = 429 THEN %s ELSE 0 END), 0) AS rate_limited_requests", source.requests),
+		fmt.Sprintf("COALESCE(SUM(%s), 0) AS total_response_time", source.responseTime),
+		fmt.Sprintf("COALESCE(MAX(%s), 0) AS max_response_time", source.maxResponseTime),
+		fmt.Sprintf("COALESCE(SUM(%s), 0) AS request_bytes", source.requestBytes),
+		fmt.Sprintf("COALESCE(SUM(%s), 0) AS response_bytes", source.responseBytes),
+	)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= ? AND %s < ?",
+		strings.Join(selects, ", "), source.table, source.timeColumn, source.timeColumn)
+	args := []interface{}{start, end}
+	if q.APIKeyID != nil {
+		query += " AND api_key_id = ?"
+		args = append(args, *q.APIKeyID)
+	}
+	if len(q.GroupBy) > 0 {
+		query += " GROUP BY " + groupOrdinals(len(q.GroupBy))
+	}
+
+	var aggregates []*usageAggregate
+	if err := db.WithContext(ctx).Raw(query, args...).Scan(&aggregates).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate usage from %s: %w", source.table, err)
+	}
+	return aggregates, nil
+}
+
+// groupColumns returns the select expressions of the dimensions of a query
+func groupColumns(source usageSource, dimensions []usageDimension) []string {
+	columns := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		switch dimension {
+		case usageByHour:
+			columns = append(columns, bucketExpr(models.RollupHour, source.timeColumn)+" AS hour")
+		case usageByCountry:
+			columns = append(columns, source.country+" AS country")
+		default:
+			columns = append(columns, string(dimension))
+		}
+	}
+	return columns
+}
+
+// groupOrdinals returns a GROUP BY list of the first n select columns
+func groupOrdinals(n int) string {
+	groups := make([]string, n)
+	for i := range groups {
+		groups[i] = fmt.Sprintf("%d", i+1)
+	}
+	return strings.Join(groups, ", ")
+}
+
+// latencyBinCount is the number of requests of one group whose response time
+// falls in one latency bin. Only the fields named in the query's GroupBy are
+// set.
+type latencyBinCount struct {
+	APIKeyID uuid.UUID
+	Hour     time.Time
+	Endpoint string
+	Method   string
+	Bin      int
+	Requests int64
+}
+
+func (b *latencyBinCount) key() usageGroupKey {
+	return usageGroupKey{
+		APIKeyID: b.APIKeyID,
+		Hour:     b.Hour.Unix(),
+		Endpoint: b.Endpoint,
+		Method:   b.Method,
+	}
+}
+
+// aggregateLatency answers q with a latency sketch per group, keyed like the
+// results of aggregateUsage. Latency bins are not split by status code or
+// country, so q cannot be grouped by either.
+func aggregateLatency(ctx context.Context, db *gorm.DB, q usageQuery) (map[usageGroupKey]*sketch.Sketch, error) {
+	for _, dimension := range q.GroupBy {
+		if dimension == usageByStatus || dimension == usageByCountry {
+			return nil, fmt.Errorf("latency cannot be grouped by %s", dimension)
+		}
+	}
+
+	ranges, err := planUsageQuery(ctx, db, q)
+	if err != nil {
+		return nil, err
+	}
+
+	sketches := make(map[usageGroupKey]*sketch.Sketch)
+	for _, r := range ranges {
+		bins, err := queryLatencyBins(ctx, db, r.source(), q, r.Start, r.End)
+		if err != nil {
+			return nil, err
+		}
+		for _, bin := range bins {
+			s, ok := sketches[bin.key()]
+			if !ok {
+				s = sketch.New()
+				sketches[bin.key()] = s
+			}
+			s.AddBin(bin.Bin, bin.Requests)
+		}
+	}
+
+	return sketches, nil
+}
+
+// queryLatencyBins counts the requests per group and latency bin of one
+// source over [start, end)
+func queryLatencyBins(ctx context.Context, db *gorm.DB, source usageSource, q usageQuery, start, end time.Time) ([]*latencyBinCount, error) {
+	selects := append(groupColumns(source, q.GroupBy),
+		source.latencyBin+" AS bin",
+		fmt.Sprintf("SUM(%s) AS requests", source.latencyCount),
+	)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s >= ? AND %s < ?",
+		strings.Join(selects, ", "), source.latencyTable, source.timeColumn, source.timeColumn)
+	args := []interface{}{start, end}
+	if q.APIKeyID != nil {
+		query += " AND api_key_id = ?"
+		args = append(args, *q.APIKeyID)
+	}
+	query += " GROUP BY " + groupOrdinals(len(q.GroupBy)+1)
+
+	var bins []*latencyBinCount
+	if err := db.WithContext(ctx).Raw(query, args...).Scan(&bins).Error; err != nil {
+		return nil, fmt.Errorf("failed to aggregate latency from %s: %w", source.latencyTable, err)
+	}
+	return bins, nil
+}
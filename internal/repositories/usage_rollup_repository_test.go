@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"io/fs"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/migrations"
+)
+
+func TestPlanUsageRanges(t *testing.T) {
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed
+	}
+
+	checkpoints := map[models.RollupGranularity]time.Time{
+		models.RollupMinute: at("2024-03-10T12:45:00Z"),
+		models.RollupHour:   at("2024-03-10T12:00:00Z"),
+		models.RollupDay:    at("2024-03-10T00:00:00Z"),
+	}
+
+	tests := []struct {
+		name           string
+		start, end     time.Time
+		checkpoints    map[models.RollupGranularity]time.Time
+		maxGranularity models.RollupGranularity
+		want           []usageRange
+	}{
+		{
+			name:           "multi-day range uses every level and raw edges",
+			start:          at("2024-03-07T22:30:30Z"),
+			end:            at("2024-03-10T12:47:10Z"),
+			checkpoints:    checkpoints,
+			maxGranularity: models.RollupDay,
+			want: []usageRange{
+				{Start: at("2024-03-07T22:30:30Z"), End: at("2024-03-07T22:31:00Z")},
+				{Granularity: models.RollupMinute, Start: at("2024-03-07T22:31:00Z"), End: at("2024-03-07T23:00:00Z")},
+				{Granularity: models.RollupHour, Start: at("2024-03-07T23:00:00Z"), End: at("2024-03-08T00:00:00Z")},
+				{Granularity: models.RollupDay, Start: at("2024-03-08T00:00:00Z"), End: at("2024-03-10T00:00:00Z")},
+				{Granularity: models.RollupHour, Start: at("2024-03-10T00:00:00Z"), End: at("2024-03-10T12:00:00Z")},
+				{Granularity: models.RollupMinute, Start: at("2024-03-10T12:00:00Z"), End: at("2024-03-10T12:45:00Z")},
+				{Start: at("2024-03-10T12:45:00Z"), End: at("2024-03-10T12:47:10Z")},
+			},
+		},
+		{
+			name:           "max granularity stops at hours",
+			start:          at("2024-03-08T00:00:00Z"),
+			end:            at("2024-03-09T00:00:00Z"),
+			checkpoints:    checkpoints,
+			maxGranularity: models.RollupHour,
+			want: []usageRange{
+				{Granularity: models.RollupHour, Start: at("2024-03-08T00:00:00Z"), End: at("2024-03-09T00:00:00Z")},
+			},
+		},
+		{
+			name:           "range after every checkpoint is read raw",
+			start:          at("2024-03-10T12:50:00Z"),
+			end:            at("2024-03-10T12:55:00Z"),
+			checkpoints:    checkpoints,
+			maxGranularity: models.RollupDay,
+			want: []usageRange{
+				{Start: at("2024-03-10T12:50:00Z"), End: at("2024-03-10T12:55:00Z")},
+			},
+		},
+		{
+			name:           "no checkpoints reads everything raw",
+			start:          at("2024-03-08T00:00:00Z"),
+			end:            at("2024-03-09T00:00:00Z"),
+			checkpoints:    map[models.RollupGranularity]time.Time{},
+			maxGranularity: models.RollupDay,
+			want: []usageRange{
+				{Start: at("2024-03-08T00:00:00Z"), End: at("2024-03-09T00:00:00Z")},
+			},
+		},
+		{
+			name:           "empty range",
+			start:          at("2024-03-08T00:00:00Z"),
+			end:            at("2024-03-08T00:00:00Z"),
+			checkpoints:    checkpoints,
+			maxGranularity: models.RollupDay,
+			want:           nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := planUsageRanges(tt.start, tt.end, tt.checkpoints, tt.maxGranularity)
+			assert.Equal(t, tt.want, got)
+
+			// Ranges must tile [start, end) exactly
+			if len(got) > 0 {
+				assert.True(t, got[0].Start.Equal(tt.start))
+				assert.True(t, got[len(got)-1].End.Equal(tt.end))
+				for i := 1; i < len(got); i++ {
+					assert.True(t, got[i].Start.Equal(got[i-1].End))
+				}
+			}
+		})
+	}
+}
+
+func TestMergeUsageAggregates(t *testing.T) {
+	keyA, keyB := uuid.New(), uuid.New()
+
+	rollups := []*usageAggregate{
+		{APIKeyID: keyA, Requests: 10, ErrorRequests: 2, ServerErrorRequests: 2, TotalResponseTime: 100, MaxResponseTime: 40, RequestBytes: 5},
+		{APIKeyID: keyB, Requests: 4, TotalResponseTime: 40, MaxResponseTime: 15},
+	}
+	raw := []*usageAggregate{
+		{APIKeyID: keyA, Requests: 2, ErrorRequests: 1, ClientErrorRequests: 1, TotalResponseTime: 80, MaxResponseTime: 70, ResponseBytes: 7},
+	}
+
+	merged := mergeUsageAggregates(rollups, raw)
+	assert.Len(t, merged, 2)
+
+	assert.Equal(t, keyA, merged[0].APIKeyID)
+	assert.Equal(t, int64(12), merged[0].Requests)
+	assert.Equal(t, int64(3), merged[0].ErrorRequests)
+	assert.Equal(t, int64(1), merged[0].ClientErrorRequests)
+	assert.Equal(t, int64(2), merged[0].ServerErrorRequests)
+	assert.Equal(t, int64(70), merged[0].MaxResponseTime)
+	assert.Equal(t, int64(5), merged[0].RequestBytes)
+	assert.Equal(t, int64(7), merged[0].ResponseBytes)
+	assert.InDelta(t, 15.0, merged[0].AvgResponseTime(), 0.001)
+
+	assert.Equal(t, keyB, merged[1].APIKeyID)
+	assert.Equal(t, int64(4), merged[1].Requests)
+
+	// Inputs are not modified
+	assert.Equal(t, int64(10), rollups[0].Requests)
+}
+
+// The latency backfill migration bins raw usage logs itself, and must agree
+// with the bins the rollup job writes
+func TestLatencyBackfillMatchesRawSource(t *testing.T) {
+	migration, err := fs.ReadFile(migrations.FS, "007_usage_latency_sketches.up.sql")
+	require.NoError(t, err)
+	assert.Contains(t, string(migration), rawUsageSource.latencyBin)
+}
@@ -0,0 +1,314 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+const (
+	// defaultBanDuration is how long automatic bans last when none is configured
+	defaultBanDuration = time.Hour
+
+	// accessRuleRefreshInterval bounds how stale the in-memory rule set can be
+	// on instances that did not make the change themselves
+	accessRuleRefreshInterval = 30 * time.Second
+)
+
+// AccessControlService defines the interface for allowlist, blocklist and ban management
+type AccessControlService interface {
+	CheckAccess(ctx context.Context, req *AccessCheckRequest) (*AccessDecision, error)
+	CreateRule(ctx context.Context, req *CreateAccessRuleRequest) (*models.AccessRule, error)
+	GetRule(ctx context.Context, id uuid.UUID) (*models.AccessRule, error)
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	ListRules(ctx context.Context, filter *repositories.AccessRuleFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error)
+	BanForViolation(ctx context.Context, violation *models.RateLimitViolation) (*models.AccessRule, error)
+}
+
+// AccessCheckRequest contains the request attributes access rules match on
+type AccessCheckRequest struct {
+	APIKeyID  uuid.UUID `json:"api_key_id"`
+	IPAddress string    `json:"ip_address"`
+	UserAgent string    `json:"user_agent"`
+	Country   string    `json:"country"`
+}
+
+// AccessDecision contains the result of an access check
+type AccessDecision struct {
+	// Denied is true when a deny rule matched. Deny rules take precedence over allow rules.
+	Denied bool `json:"denied"`
+
+	// Exempt is true when an allow rule matched and no deny rule did.
+	// Exempt requests skip rate limiting but are still charged credits.
+	Exempt bool `json:"exempt"`
+
+	// Rule is the rule that decided the outcome, if any
+	Rule *models.AccessRule `json:"rule,omitempty"`
+
+	// RetryAfter is the number of seconds until a temporary ban expires
+	RetryAfter int `json:"retry_after,omitempty"`
+}
+
+// CreateAccessRuleRequest contains data for creating an access rule
+type CreateAccessRuleRequest struct {
+	ListType  models.AccessListType  `json:"list_type"`
+	MatchType models.AccessMatchType `json:"match_type"`
+	Value     string                 `json:"value"`
+	Reason    string                 `json:"reason"`
+	Duration  time.Duration          `json:"duration"` // zero for a permanent rule
+	CreatedBy string                 `json:"created_by"`
+}
+
+// accessControlService implements AccessControlService interface
+type accessControlService struct {
+	ruleRepo    repositories.AccessRuleRepository
+	banDuration time.Duration
+	clock       ratelimit.Clock
+
+	// rules is an in-memory snapshot of active rules, reloaded every
+	// accessRuleRefreshInterval and after every local change
+	mu       sync.RWMutex
+	rules    []*models.AccessRule
+	loadedAt time.Time
+	loaded   bool
+}
+
+// NewAccessControlService creates a new access control service
+func NewAccessControlService(
+	ruleRepo repositories.AccessRuleRepository,
+	banDuration time.Duration,
+	clock ratelimit.Clock,
+) AccessControlService {
+	if banDuration <= 0 {
+		banDuration = defaultBanDuration
+	}
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &accessControlService{
+		ruleRepo:    ruleRepo,
+		banDuration: banDuration,
+		clock:       clock,
+	}
+}
+
+// CheckAccess evaluates the allow and deny lists for a request
+func (s *accessControlService) CheckAccess(ctx context.Context, req *AccessCheckRequest) (*AccessDecision, error) {
+	rules, err := s.activeRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	decision := &AccessDecision{}
+
+	for _, rule := range rules {
+		if rule.IsExpired(now) || !rule.Matches(req.APIKeyID, req.IPAddress, req.UserAgent, req.Country) {
+			continue
+		}
+
+		switch rule.ListType {
+		case models.AccessListDeny:
+			decision.Denied = true
+			decision.Exempt = false
+			decision.Rule = rule
+			if rule.ExpiresAt != nil {
+				decision.RetryAfter = int(rule.ExpiresAt.Sub(now).Seconds()) + 1
+			}
+			return decision, nil
+		case models.AccessListAllow:
+			if decision.Rule == nil {
+				decision.Exempt = true
+				decision.Rule = rule
+			}
+		}
+	}
+
+	return decision, nil
+}
+
+// CreateRule validates and stores a new access rule
+func (s *accessControlService) CreateRule(ctx context.Context, req *CreateAccessRuleRequest) (*models.AccessRule, error) {
+	value, err := normalizeAccessRuleValue(req.MatchType, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	if req.ListType != models.AccessListAllow && req.ListType != models.AccessListDeny {
+		return nil, fmt.Errorf("invalid list type: %s", req.ListType)
+	}
+	if req.Duration < 0 {
+		return nil, fmt.Errorf("duration must not be negative")
+	}
+
+	rule := &models.AccessRule{
+		ListType:  req.ListType,
+		MatchType: req.MatchType,
+		Value:     value,
+		Reason:    req.Reason,
+		Source:    models.AccessRuleSourceManual,
+		CreatedBy: req.CreatedBy,
+	}
+	if req.Duration > 0 {
+		expiresAt := s.clock.Now().Add(req.Duration)
+		rule.ExpiresAt = &expiresAt
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	s.invalidate()
+	return rule, nil
+}
+
+// GetRule retrieves an access rule by ID
+func (s *accessControlService) GetRule(ctx context.Context, id uuid.UUID) (*models.AccessRule, error) {
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// DeleteRule removes an access rule, lifting a ban or exemption immediately
+func (s *accessControlService) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	if err := s.ruleRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	s.invalidate()
+	return nil
+}
+
+// ListRules retrieves access rules with filtering and pagination
+func (s *accessControlService) ListRules(ctx context.Context, filter *repositories.AccessRuleFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	if pagination == nil {
+		pagination = repositories.DefaultPagination()
+	}
+	return s.ruleRepo.List(ctx, filter, pagination)
+}
+
+// BanForViolation creates a temporary ban for the violating API key when the
+// violation is critical. It returns nil if no ban was created, either because
+// the violation is not critical or the key is already banned.
+func (s *accessControlService) BanForViolation(ctx context.Context, violation *models.RateLimitViolation) (*models.AccessRule, error) {
+	// Severity is meaningless without the limit the request was checked against
+	if violation.LimitValue <= 0 || violation.GetViolationSeverity() != "critical" {
+		return nil, nil
+	}
+
+	now := s.clock.Now()
+	value := violation.APIKeyID.String()
+
+	existing, err := s.ruleRepo.FindActiveBan(ctx, models.AccessMatchAPIKey, value, now)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, nil
+	}
+
+	expiresAt := now.Add(s.banDuration)
+	rule := &models.AccessRule{
+		ListType:  models.AccessListDeny,
+		MatchType: models.AccessMatchAPIKey,
+		Value:     value,
+		Reason: fmt.Sprintf("critical rate limit violation: %d requests against a limit of %d",
+			violation.CurrentCount, violation.LimitValue),
+		Source:    models.AccessRuleSourceAutomatic,
+		ExpiresAt: &expiresAt,
+		CreatedBy: "system",
+	}
+	if violation.ID != 0 {
+		rule.ViolationID = &violation.ID
+	}
+
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, fmt.Errorf("failed to create ban: %w", err)
+	}
+
+	s.invalidate()
+	return rule, nil
+}
+
+// activeRules returns the cached rule set, reloading it when stale. If a reload
+// fails the previous snapshot keeps being used so a database hiccup does not
+// lift every ban at once.
+func (s *accessControlService) activeRules(ctx context.Context) ([]*models.AccessRule, error) {
+	now := s.clock.Now()
+
+	s.mu.RLock()
+	rules, loaded, loadedAt := s.rules, s.loaded, s.loadedAt
+	s.mu.RUnlock()
+
+	if loaded && now.Sub(loadedAt) < accessRuleRefreshInterval {
+		return rules, nil
+	}
+
+	fresh, err := s.ruleRepo.GetActive(ctx, now)
+	if err != nil {
+		if loaded {
+			return rules, nil
+		}
+		return nil, fmt.Errorf("failed to load access rules: %w", err)
+	}
+
+	s.mu.Lock()
+	s.rules = fresh
+	s.loadedAt = now
+	s.loaded = true
+	s.mu.Unlock()
+
+	return fresh, nil
+}
+
+// invalidate forces the next check to reload rules from the database
+func (s *accessControlService) invalidate() {
+	s.mu.Lock()
+	s.loaded = false
+	s.mu.Unlock()
+}
+
+// normalizeAccessRuleValue validates a rule value for its match type and
+// returns it in canonical form
+func normalizeAccessRuleValue(matchType models.AccessMatchType, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("value is required")
+	}
+
+	switch matchType {
+	case models.AccessMatchAPIKey:
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid api key id: %w", err)
+		}
+		return id.String(), nil
+	case models.AccessMatchIP:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("invalid ip address: %s", value)
+		}
+		return ip.String(), nil
+	case models.AccessMatchCIDR:
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid cidr: %w", err)
+		}
+		return network.String(), nil
+	case models.AccessMatchUserAgent:
+		return value, nil
+	case models.AccessMatchCountry:
+		if len(value) != 2 {
+			return "", fmt.Errorf("country must be a two-letter ISO code")
+		}
+		return strings.ToUpper(value), nil
+	default:
+		return "", fmt.Errorf("invalid match type: %s", matchType)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeAccessRuleRepository is an in-memory AccessRuleRepository for tests
+type fakeAccessRuleRepository struct {
+	rules []*models.AccessRule
+}
+
+func (r *fakeAccessRuleRepository) Create(ctx context.Context, rule *models.AccessRule) error {
+	rule.ID = uuid.New()
+	r.rules = append(r.rules, rule)
+	return nil
+}
+
+func (r *fakeAccessRuleRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.AccessRule, error) {
+	for _, rule := range r.rules {
+		if rule.ID == id {
+			return rule, nil
+		}
+	}
+	return nil, assert.AnError
+}
+
+func (r *fakeAccessRuleRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	for i, rule := range r.rules {
+		if rule.ID == id {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return nil
+		}
+	}
+	return assert.AnError
+}
+
+func (r *fakeAccessRuleRepository) List(ctx context.Context, filter *repositories.AccessRuleFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	return repositories.NewPaginatedResult(r.rules, int64(len(r.rules)), pagination), nil
+}
+
+func (r *fakeAccessRuleRepository) GetActive(ctx context.Context, now time.Time) ([]*models.AccessRule, error) {
+	var active []*models.AccessRule
+	for _, rule := range r.rules {
+		if !rule.IsExpired(now) {
+			active = append(active, rule)
+		}
+	}
+	return active, nil
+}
+
+func (r *fakeAccessRuleRepository) FindActiveBan(ctx context.Context, matchType models.AccessMatchType, value string, now time.Time) (*models.AccessRule, error) {
+	for _, rule := range r.rules {
+		if rule.ListType == models.AccessListDeny && rule.MatchType == matchType && rule.Value == value && !rule.IsExpired(now) {
+			return rule, nil
+		}
+	}
+	return nil, nil
+}
+
+func TestAccessControlService_DenyTakesPrecedence(t *testing.T) {
+	ctx := context.Background()
+	repo := &fakeAccessRuleRepository{}
+	service := NewAccessControlService(repo, time.Hour, ratelimit.NewFakeClock(time.Now()))
+
+	_, err := service.CreateRule(ctx, &CreateAccessRuleRequest{
+		ListType: models.AccessListAllow, MatchType: models.AccessMatchCIDR, Value: "10.0.0.0/8",
+	})
+	require.NoError(t, err)
+
+	decision, err := service.CheckAccess(ctx, &AccessCheckRequest{IPAddress: "10.1.2.3"})
+	require.NoError(t, err)
+	assert.True(t, decision.Exempt)
+	assert.False(t, decision.Denied)
+
+	_, err = service.CreateRule(ctx, &CreateAccessRuleRequest{
+		ListType: models.AccessListDeny, MatchType: models.AccessMatchIP, Value: "10.1.2.3",
+	})
+	require.NoError(t, err)
+
+	decision, err = service.CheckAccess(ctx, &AccessCheckRequest{IPAddress: "10.1.2.3"})
+	require.NoError(t, err)
+	assert.True(t, decision.Denied)
+	assert.False(t, decision.Exempt)
+}
+
+func TestAccessControlService_BanForViolation(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	repo := &fakeAccessRuleRepository{}
+	service := NewAccessControlService(repo, time.Hour, clock)
+	apiKeyID := uuid.New()
+
+	// High but not critical violations do not ban
+	rule, err := service.BanForViolation(ctx, &models.RateLimitViolation{
+		APIKeyID: apiKeyID, LimitValue: 100, CurrentCount: 300,
+	})
+	require.NoError(t, err)
+	assert.Nil(t, rule)
+
+	critical := &models.RateLimitViolation{APIKeyID: apiKeyID, LimitValue: 100, CurrentCount: 600}
+	rule, err = service.BanForViolation(ctx, critical)
+	require.NoError(t, err)
+	require.NotNil(t, rule)
+	assert.Equal(t, models.AccessRuleSourceAutomatic, rule.Source)
+
+	// An existing ban is not duplicated
+	again, err := service.BanForViolation(ctx, critical)
+	require.NoError(t, err)
+	assert.Nil(t, again)
+
+	decision, err := service.CheckAccess(ctx, &AccessCheckRequest{APIKeyID: apiKeyID})
+	require.NoError(t, err)
+	assert.True(t, decision.Denied)
+	assert.Equal(t, 3601, decision.RetryAfter)
+
+	// The ban lapses on its own
+	clock.Advance(time.Hour + accessRuleRefreshInterval)
+	decision, err = service.CheckAccess(ctx, &AccessCheckRequest{APIKeyID: apiKeyID})
+	require.NoError(t, err)
+	assert.False(t, decision.Denied)
+}
+
+func TestNormalizeAccessRuleValue(t *testing.T) {
+	value, err := normalizeAccessRuleValue(models.AccessMatchCIDR, "10.1.2.3/8")
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", value)
+
+	value, err = normalizeAccessRuleValue(models.AccessMatchCountry, "de")
+	require.NoError(t, err)
+	assert.Equal(t, "DE", value)
+
+	_, err = normalizeAccessRuleValue(models.AccessMatchIP, "not-an-ip")
+	assert.Error(t, err)
+
+	_, err = normalizeAccessRuleValue(models.AccessMatchAPIKey, "not-a-uuid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/jwt"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// ErrInvalidAdminToken is returned for admin API tokens that are malformed,
+// badly signed or expired, or whose API key can no longer sign in
+var ErrInvalidAdminToken = errors.New("invalid admin token")
+
+// AdminToken is a bearer token for the admin API
+type AdminToken struct {
+	Token     string    `json:"token"`
+	TokenType string    `json:"token_type"`
+	ExpiresAt time.Time `json:"expires_at"`
+	APIKeyID  uuid.UUID `json:"api_key_id"`
+	Scopes    []string  `json:"scopes"`
+}
+
+// AdminAuthService authenticates callers of the admin API. An API key signs
+// in once and receives a JWT, which the admin API accepts in its place until
+// the token expires. The key is loaded again for every request, so
+// deactivating it or changing its scopes takes effect immediately.
+type AdminAuthService struct {
+	apiKeyService APIKeyService
+	apiKeyRepo    repositories.APIKeyRepository
+	secret        string
+	expiry        time.Duration
+	clock         ratelimit.Clock
+}
+
+// NewAdminAuthService creates a new admin authentication service issuing
+// tokens signed with secret that are valid for expiry
+func NewAdminAuthService(
+	apiKeyService APIKeyService,
+	apiKeyRepo repositories.APIKeyRepository,
+	secret string,
+	expiry time.Duration,
+	clock ratelimit.Clock,
+) (*AdminAuthService, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("security.jwt_secret is required for the admin API")
+	}
+	if expiry <= 0 {
+		return nil, fmt.Errorf("security.jwt_expiry must be positive")
+	}
+	return &AdminAuthService{
+		apiKeyService: apiKeyService,
+		apiKeyRepo:    apiKeyRepo,
+		secret:        secret,
+		expiry:        expiry,
+		clock:         clock,
+	}, nil
+}
+
+// IssueToken signs in with an API key and returns an admin API token for it.
+// The token never outlives the key.
+func (s *AdminAuthService) IssueToken(ctx context.Context, key string) (*AdminToken, error) {
+	apiKey, err := s.apiKeyService.ValidateAPIKey(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.clock.Now()
+	expiresAt := now.Add(s.expiry)
+	if apiKey.ExpiresAt != nil && apiKey.ExpiresAt.Before(expiresAt) {
+		expiresAt = *apiKey.ExpiresAt
+	}
+
+	claims := &jwt.Claims{
+		ID:        uuid.NewString(),
+		Subject:   apiKey.ID.String(),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}
+	token, err := jwt.Sign(s.secret, claims)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign admin token: %w", err)
+	}
+
+	return &AdminToken{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+		APIKeyID:  apiKey.ID,
+		Scopes:    apiKey.Scopes,
+	}, nil
+}
+
+// Authenticate verifies an admin API token and returns the API key it was
+// issued to
+func (s *AdminAuthService) Authenticate(ctx context.Context, token string) (*models.APIKey, error) {
+	now := s.clock.Now()
+	claims, err := jwt.Verify(s.secret, token, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAdminToken, err)
+	}
+
+	apiKeyID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject", ErrInvalidAdminToken)
+	}
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, apiKeyID)
+	if err != nil {
+		if err.Error() == "api key not found" {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAdminToken, err)
+		}
+		return nil, err
+	}
+
+	if !apiKey.IsActive() {
+		return nil, fmt.Errorf("%w: api key is not active", ErrInvalidAdminToken)
+	}
+	if apiKey.ExpiresAt != nil && now.After(*apiKey.ExpiresAt) {
+		return nil, fmt.Errorf("%w: api key has expired", ErrInvalidAdminToken)
+	}
+	return apiKey, nil
+}
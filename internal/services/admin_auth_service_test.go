@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/jwt"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+type adminAuthFixture struct {
+	service *AdminAuthService
+	apiKeys *fakeAPIKeyRepository
+	clock   *ratelimit.FakeClock
+	keys    APIKeyService
+}
+
+func newAdminAuthFixture(t *testing.T) *adminAuthFixture {
+	t.Helper()
+
+	f := &adminAuthFixture{
+		apiKeys: newFakeAPIKeyRepository(),
+		clock:   ratelimit.NewFakeClock(time.Now().Truncate(time.Second)),
+	}
+	f.keys = NewAPIKeyService(f.apiKeys, nil)
+	service, err := NewAdminAuthService(f.keys, f.apiKeys, "s3cret", time.Hour, f.clock)
+	require.NoError(t, err)
+	f.service = service
+	return f
+}
+
+// createKey creates an API key and returns it with its plaintext key
+func (f *adminAuthFixture) createKey(t *testing.T, req *CreateAPIKeyRequest) *APIKeyResponse {
+	t.Helper()
+	req.UserID = uuid.New()
+	created, err := f.keys.CreateAPIKey(context.Background(), req)
+	require.NoError(t, err)
+	return created
+}
+
+func TestNewAdminAuthService_RequiresSecret(t *testing.T) {
+	_, err := NewAdminAuthService(nil, nil, "", time.Hour, ratelimit.NewSystemClock())
+	assert.EqualError(t, err, "security.jwt_secret is required for the admin API")
+}
+
+func TestAdminAuthService_IssueAndAuthenticate(t *testing.T) {
+	f := newAdminAuthFixture(t)
+	ctx := context.Background()
+	created := f.createKey(t, &CreateAPIKeyRequest{Name: "ops", Scopes: []string{models.ScopeKeysRead}})
+
+	token, err := f.service.IssueToken(ctx, *created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.ScopeKeysRead}, token.Scopes)
+	assert.Equal(t, f.clock.Now().Add(time.Hour).UTC(), token.ExpiresAt)
+
+	apiKey, err := f.service.Authenticate(ctx, token.Token)
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, apiKey.ID)
+
+	// The API key itself is not a token
+	_, err = f.service.Authenticate(ctx, *created.Key)
+	assert.ErrorIs(t, err, ErrInvalidAdminToken)
+
+	f.clock.Advance(time.Hour)
+	_, err = f.service.Authenticate(ctx, token.Token)
+	assert.ErrorIs(t, err, jwt.ErrExpiredToken)
+}
+
+func TestAdminAuthService_TokenFollowsAPIKey(t *testing.T) {
+	f := newAdminAuthFixture(t)
+	ctx := context.Background()
+
+	// Tokens never outlive their key
+	keyExpiry := f.clock.Now().Add(10 * time.Minute)
+	created := f.createKey(t, &CreateAPIKeyRequest{Name: "temp", ExpiresAt: &keyExpiry})
+	token, err := f.service.IssueToken(ctx, *created.Key)
+	require.NoError(t, err)
+	assert.Equal(t, keyExpiry.UTC(), token.ExpiresAt)
+
+	// Deactivating the key revokes its tokens
+	f.apiKeys.apiKeys[created.ID].Status = models.APIKeyStatusSuspended
+	_, err = f.service.Authenticate(ctx, token.Token)
+	assert.EqualError(t, err, "invalid admin token: api key is not active")
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// AnomalyPolicy configures the anomaly detector behind the spike and new
+// access alert conditions.
+//
+// Traffic is baselined per hour of the day: for each UTC hour, the requests of
+// that hour on previous days are smoothed into an exponentially weighted
+// moving average and variance. A window is a spike when its requests exceed
+// the baseline times the rule's multiplier and the baseline plus SpikeSigmas
+// standard deviations, and number at least MinSpikeRequests. Keys with fewer
+// than MinBaselineDays days of history are not judged.
+//
+// New countries, IP addresses and user agents are only reported for keys with
+// at least MinBaselineRequests requests in the baseline, so new keys do not
+// alert on their first callers. At most MaxEvidence values per attribute are
+// stored in the alert.
+type AnomalyPolicy struct {
+	Enabled             bool    `json:"enabled"`
+	Smoothing           float64 `json:"smoothing"` // EWMA weight of the newest day
+	SpikeSigmas         float64 `json:"spike_sigmas"`
+	MinSpikeRequests    int64   `json:"min_spike_requests"`
+	MinBaselineDays     int     `json:"min_baseline_days"`
+	MinBaselineRequests int64   `json:"min_baseline_requests"`
+	MaxEvidence         int     `json:"max_evidence"`
+}
+
+// DefaultAnomalyPolicy returns the default anomaly detection configuration
+func DefaultAnomalyPolicy() AnomalyPolicy {
+	return AnomalyPolicy{
+		Enabled:             true,
+		Smoothing:           0.3,
+		SpikeSigmas:         4,
+		MinSpikeRequests:    100,
+		MinBaselineDays:     3,
+		MinBaselineRequests: 1000,
+		MaxEvidence:         20,
+	}
+}
+
+// withDefaults fills unset fields from DefaultAnomalyPolicy
+func (p AnomalyPolicy) withDefaults() AnomalyPolicy {
+	defaults := DefaultAnomalyPolicy()
+	if p.Smoothing <= 0 || p.Smoothing > 1 {
+		p.Smoothing = defaults.Smoothing
+	}
+	if p.SpikeSigmas <= 0 {
+		p.SpikeSigmas = defaults.SpikeSigmas
+	}
+	if p.MinSpikeRequests <= 0 {
+		p.MinSpikeRequests = defaults.MinSpikeRequests
+	}
+	if p.MinBaselineDays <= 0 {
+		p.MinBaselineDays = defaults.MinBaselineDays
+	}
+	if p.MinBaselineRequests <= 0 {
+		p.MinBaselineRequests = defaults.MinBaselineRequests
+	}
+	if p.MaxEvidence <= 0 {
+		p.MaxEvidence = defaults.MaxEvidence
+	}
+	return p
+}
+
+// usageBaseline is the expected number of requests in a window
+type usageBaseline struct {
+	Mean   float64
+	StdDev float64
+	Days   int // fewest days of history behind any hour of the window
+}
+
+// seasonalBaseline computes the expected requests in [windowStart, windowEnd)
+// from the hourly usage since baselineStart. Every UTC hour the window
+// overlaps contributes the EWMA of that hour of the day on earlier days,
+// weighted by the fraction of the hour inside the window. Hours missing from
+// hourly had no requests.
+func seasonalBaseline(hourly []*repositories.HourlyUsage, baselineStart, windowStart, windowEnd time.Time, alpha float64) usageBaseline {
+	requests := make(map[int64]int64, len(hourly))
+	for _, hour := range hourly {
+		requests[hour.Hour.Unix()] = hour.TotalRequests
+	}
+
+	baseline := usageBaseline{Days: -1}
+	var variance float64
+	for hour := windowStart.UTC().Truncate(time.Hour); hour.Before(windowEnd); hour = hour.Add(time.Hour) {
+		from, to := maxTime(hour, windowStart), minTime(hour.Add(time.Hour), windowEnd)
+		weight := to.Sub(from).Hours()
+
+		// Oldest day first, so the newest days weigh the most
+		days := 0
+		for day := hour.AddDate(0, 0, -1); !day.Before(baselineStart); day = day.AddDate(0, 0, -1) {
+			days++
+		}
+		var mean, dayVariance float64
+		for i := days; i >= 1; i-- {
+			x := float64(requests[hour.AddDate(0, 0, -i).Unix()])
+			if i == days {
+				mean = x
+				continue
+			}
+			diff := x - mean
+			mean += alpha * diff
+			dayVariance = (1 - alpha) * (dayVariance + alpha*diff*diff)
+		}
+
+		baseline.Mean += weight * mean
+		variance += weight * dayVariance
+		if baseline.Days < 0 || days < baseline.Days {
+			baseline.Days = days
+		}
+	}
+	if baseline.Days < 0 {
+		baseline.Days = 0
+	}
+
+	// Request counts are at least as noisy as a Poisson process
+	baseline.StdDev = math.Sqrt(math.Max(variance, math.Max(baseline.Mean, 1)))
+	return baseline
+}
+
+// isSpike returns true if observed requests are anomalous for baseline
+func (p AnomalyPolicy) isSpike(observed int64, baseline usageBaseline, multiplier float64) bool {
+	if baseline.Days < p.MinBaselineDays || observed < p.MinSpikeRequests {
+		return false
+	}
+	value := float64(observed)
+	return value > baseline.Mean*multiplier && value > baseline.Mean+p.SpikeSigmas*baseline.StdDev
+}
+
+// detectSpike compares the requests of an API key in [windowStart, windowEnd)
+// with its seasonal baseline. It returns the alert message and evidence when
+// the window is a spike.
+func (s *alertService) detectSpike(ctx context.Context, apiKey *models.APIKey, condition *models.SpikeCondition, windowStart, windowEnd time.Time) (bool, string, map[string]interface{}, error) {
+	// Days before the key existed are not zero-traffic days
+	baselineStart := windowStart.UTC().Truncate(time.Hour).Add(-time.Duration(condition.BaselineMinutes) * time.Minute)
+	if created := apiKey.CreatedAt.UTC().Truncate(time.Hour); created.After(baselineStart) {
+		baselineStart = created
+	}
+	hourly, err := s.usageRepo.GetHourlyUsage(ctx, apiKey.ID, baselineStart, windowStart.UTC().Truncate(time.Hour))
+	if err != nil {
+		return false, "", nil, err
+	}
+	baseline := seasonalBaseline(hourly, baselineStart, windowStart, windowEnd, s.anomaly.Smoothing)
+
+	observed, err := s.usageRepo.CountRequests(ctx, apiKey.ID, windowStart, windowEnd)
+	if err != nil {
+		return false, "", nil, err
+	}
+
+	multiplier := condition.Multiplier
+	if multiplier <= 0 {
+		multiplier = 1
+	}
+	if !s.anomaly.isSpike(observed, baseline, multiplier) {
+		return false, "", nil, nil
+	}
+
+	message := fmt.Sprintf("Unusual traffic spike: %d requests between %s and %s, %.1f expected",
+		observed, windowStart.UTC().Format(time.RFC3339), windowEnd.UTC().Format(time.RFC3339), baseline.Mean)
+	metadata := map[string]interface{}{
+		"detector":          "seasonal_ewma",
+		"window_start":      windowStart.UTC(),
+		"window_end":        windowEnd.UTC(),
+		"observed_requests": observed,
+		"expected_requests": baseline.Mean,
+		"baseline_stddev":   baseline.StdDev,
+		"baseline_days":     baseline.Days,
+		"z_score":           (float64(observed) - baseline.Mean) / baseline.StdDev,
+		"multiplier":        multiplier,
+	}
+	return true, message, metadata, nil
+}
+
+// detectNewAccess looks for countries, IP addresses and user agents an API key
+// used in [windowStart, windowEnd) but not during the baseline. It returns the
+// alert message and evidence when any attribute reaches its threshold.
+func (s *alertService) detectNewAccess(ctx context.Context, apiKeyID uuid.UUID, condition *models.NewAccessCondition, windowStart, windowEnd time.Time) (bool, string, map[string]interface{}, error) {
+	baselineStart := windowStart.Add(-time.Duration(condition.BaselineMinutes) * time.Minute)
+	baselineRequests, err := s.usageRepo.CountRequests(ctx, apiKeyID, baselineStart, windowStart)
+	if err != nil {
+		return false, "", nil, err
+	}
+	if baselineRequests < s.anomaly.MinBaselineRequests {
+		return false, "", nil, nil
+	}
+
+	checks := []struct {
+		attribute repositories.UsageAttribute
+		threshold int
+		label     string
+		key       string
+	}{
+		{repositories.UsageAttributeCountry, condition.MinNewCountries, "countries", "new_countries"},
+		{repositories.UsageAttributeIPAddress, condition.MinNewIPs, "IP addresses", "new_ip_addresses"},
+		{repositories.UsageAttributeUserAgent, condition.MinNewUserAgents, "user agents", "new_user_agents"},
+	}
+
+	metadata := map[string]interface{}{
+		"detector":       "new_access",
+		"baseline_start": baselineStart.UTC(),
+		"window_start":   windowStart.UTC(),
+		"window_end":     windowEnd.UTC(),
+	}
+	var findings []string
+	for _, check := range checks {
+		if check.threshold <= 0 {
+			continue
+		}
+		values, err := s.usageRepo.GetNewAttributeValues(ctx, apiKeyID, check.attribute, baselineStart, windowStart, windowEnd, s.anomaly.MaxEvidence)
+		if err != nil {
+			return false, "", nil, err
+		}
+		if values.Total < int64(check.threshold) {
+			continue
+		}
+		metadata[check.key] = values
+		findings = append(findings, fmt.Sprintf("%d new %s", values.Total, check.label))
+	}
+	if len(findings) == 0 {
+		return false, "", nil, nil
+	}
+
+	message := fmt.Sprintf("Possible credential abuse: %s in the last %s",
+		joinFindings(findings), windowEnd.Sub(windowStart).Round(time.Minute))
+	return true, message, metadata, nil
+}
+
+// joinFindings lists findings as "a, b and c"
+func joinFindings(findings []string) string {
+	if len(findings) == 1 {
+		return findings[0]
+	}
+	result := findings[0]
+	for _, finding := range findings[1 : len(findings)-1] {
+		result += ", " + finding
+	}
+	return result + " and " + findings[len(findings)-1]
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeAnomalyUsageRepository serves the usage the anomaly detector reads
+type fakeAnomalyUsageRepository struct {
+	repositories.UsageLogRepository
+	hourly    []*repositories.HourlyUsage
+	requests  map[time.Time]int64 // requests counted from each window start
+	newValues map[repositories.UsageAttribute]*repositories.NewAttributeValues
+	stats     repositories.UsageStats
+}
+
+func (r *fakeAnomalyUsageRepository) GetHourlyUsage(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*repositories.HourlyUsage, error) {
+	var usage []*repositories.HourlyUsage
+	for _, hour := range r.hourly {
+		if !hour.Hour.Before(startTime) && hour.Hour.Before(endTime) {
+			usage = append(usage, hour)
+		}
+	}
+	return usage, nil
+}
+
+func (r *fakeAnomalyUsageRepository) CountRequests(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (int64, error) {
+	return r.requests[startTime], nil
+}
+
+func (r *fakeAnomalyUsageRepository) GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*repositories.UsageStats, error) {
+	stats := r.stats
+	return &stats, nil
+}
+
+func (r *fakeAnomalyUsageRepository) GetNewAttributeValues(ctx context.Context, apiKeyID uuid.UUID, attribute repositories.UsageAttribute, baselineStart, since, until time.Time, limit int) (*repositories.NewAttributeValues, error) {
+	if values, ok := r.newValues[attribute]; ok {
+		return values, nil
+	}
+	return &repositories.NewAttributeValues{}, nil
+}
+
+type fakeAlertRepository struct {
+	repositories.AlertRepository
+	mu      sync.Mutex
+	created []*models.Alert
+	updates int
+}
+
+func (r *fakeAlertRepository) Create(ctx context.Context, alert *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.created = append(r.created, alert)
+	return nil
+}
+
+// List counts the created alerts raised by filter.RuleID, enough for cooldowns
+func (r *fakeAlertRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, alert := range r.created {
+		if alert.ID == id {
+			return alert, nil
+		}
+	}
+	return nil, errors.New("alert not found")
+}
+
+// UpdateState counts updates; the alerts are stored by pointer, so they already
+// hold the new state
+func (r *fakeAlertRepository) UpdateState(ctx context.Context, alert *models.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.updates++
+	return nil
+}
+
+func (r *fakeAlertRepository) GetUnresolvedByAPIKey(ctx context.Context, apiKeyID uuid.UUID) ([]*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alerts []*models.Alert
+	for _, alert := range r.created {
+		if alert.APIKeyID == apiKeyID && alert.Status != models.AlertStatusResolved {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (r *fakeAlertRepository) GetUnresolvedByDedupKey(ctx context.Context, dedupKey string) (*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, alert := range r.created {
+		if alert.DedupKey != nil && *alert.DedupKey == dedupKey && alert.Status != models.AlertStatusResolved {
+			return alert, nil
+		}
+	}
+	return nil, errors.New("alert not found")
+}
+
+func (r *fakeAlertRepository) GetByStatus(ctx context.Context, status models.AlertStatus) ([]*models.Alert, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var alerts []*models.Alert
+	for _, alert := range r.created {
+		if alert.Status == status {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func (r *fakeAlertRepository) List(ctx context.Context, filter *repositories.AlertFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	result := &repositories.PaginatedResult{}
+	for _, alert := range r.created {
+		if filter.StartTime != nil && alert.CreatedAt.Before(*filter.StartTime) {
+			continue
+		}
+		if filter.RuleID != nil && alert.RuleID != nil && *alert.RuleID == *filter.RuleID {
+			result.Total++
+		}
+	}
+	return result, nil
+}
+
+type fakeAlertAPIKeyRepository struct {
+	repositories.APIKeyRepository
+	key *models.APIKey
+}
+
+func (r *fakeAlertAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	return r.key, nil
+}
+
+func (r *fakeAlertAPIKeyRepository) GetActiveByTier(ctx context.Context, tier models.APIKeyTier) ([]*models.APIKey, error) {
+	return []*models.APIKey{r.key}, nil
+}
+
+type fakeAlertViolationRepository struct {
+	repositories.RateLimitViolationRepository
+}
+
+func (r *fakeAlertViolationRepository) CountRecentViolations(ctx context.Context, apiKeyID uuid.UUID, minutes int) (int64, error) {
+	return 0, nil
+}
+
+// anomalyRules returns the spike and new access rules seeded by migration 008
+func anomalyRules() []*models.AlertRule {
+	return []*models.AlertRule{
+		{
+			ID:              uuid.New(),
+			Name:            "Unusual Traffic Spike",
+			Type:            models.AlertTypeAbnormalUsage,
+			Severity:        models.AlertSeverityMedium,
+			MatchMode:       models.AlertMatchAll,
+			Enabled:         true,
+			CooldownMinutes: 60,
+			Conditions: models.AlertConditions{
+				UnusualTrafficSpike: &models.SpikeCondition{Multiplier: 3, BaselineMinutes: 14 * 24 * 60},
+				TimeWindowMinutes:   15,
+			},
+		},
+		{
+			ID:              uuid.New(),
+			Name:            "New Access Pattern",
+			Type:            models.AlertTypeSecurityIssue,
+			Severity:        models.AlertSeverityHigh,
+			MatchMode:       models.AlertMatchAll,
+			Enabled:         true,
+			CooldownMinutes: 60,
+			Conditions: models.AlertConditions{
+				NewAccessPattern: &models.NewAccessCondition{
+					BaselineMinutes:  7 * 24 * 60,
+					MinNewCountries:  1,
+					MinNewIPs:        10,
+					MinNewUserAgents: 3,
+				},
+				TimeWindowMinutes: 60,
+			},
+		},
+	}
+}
+
+// steadyHourly returns days of hourly usage before end with requests in every hour
+func steadyHourly(end time.Time, days int, requests func(hour time.Time) int64) []*repositories.HourlyUsage {
+	var usage []*repositories.HourlyUsage
+	for hour := end.Add(-time.Duration(days) * 24 * time.Hour); hour.Before(end); hour = hour.Add(time.Hour) {
+		usage = append(usage, &repositories.HourlyUsage{Hour: hour, TotalRequests: requests(hour)})
+	}
+	return usage
+}
+
+func TestSeasonalBaselineFollowsHourOfDay(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+	// Busy afternoons, quiet nights
+	hourly := steadyHourly(now, 7, func(hour time.Time) int64 {
+		if hour.Hour() == 14 {
+			return 600
+		}
+		return 60
+	})
+	start := now.AddDate(0, 0, -7)
+
+	afternoon := seasonalBaseline(hourly, start, now, now.Add(time.Hour), 0.3)
+	assert.InDelta(t, 600, afternoon.Mean, 0.001)
+	assert.Equal(t, 7, afternoon.Days)
+
+	// A window across 13:30-14:30 expects half of each hour
+	straddling := seasonalBaseline(hourly, start, now.Add(-30*time.Minute), now.Add(30*time.Minute), 0.3)
+	assert.InDelta(t, 330, straddling.Mean, 0.001)
+
+	policy := DefaultAnomalyPolicy()
+	assert.False(t, policy.isSpike(650, afternoon, 3), "normal afternoon traffic")
+	assert.True(t, policy.isSpike(2000, afternoon, 3))
+
+	night := seasonalBaseline(hourly, start, now.Add(-4*time.Hour), now.Add(-3*time.Hour), 0.3)
+	assert.True(t, policy.isSpike(600, night, 3), "afternoon traffic at night")
+
+	// Too little history to judge
+	short := seasonalBaseline(hourly, now.AddDate(0, 0, -2), now, now.Add(time.Hour), 0.3)
+	assert.False(t, policy.isSpike(10000, short, 3))
+}
+
+func TestCheckAndCreateAlertsRaisesAnomalies(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	key := &models.APIKey{ID: uuid.New(), CreatedAt: now.AddDate(0, -1, 0)}
+
+	usageRepo := &fakeAnomalyUsageRepository{
+		hourly: steadyHourly(now.Truncate(time.Hour), 14, func(time.Time) int64 { return 400 }),
+		requests: map[time.Time]int64{
+			now.Add(-15 * time.Minute):            1500, // spike window, 100 expected
+			now.Add(-time.Hour):                   400,  // new access window
+			now.Add(-time.Hour).AddDate(0, 0, -7): 60000,
+		},
+		newValues: map[repositories.UsageAttribute]*repositories.NewAttributeValues{
+			repositories.UsageAttributeCountry: {Total: 2, Values: []*repositories.NewAttributeValue{
+				{Value: "KP", Requests: 300, FirstSeen: now.Add(-40 * time.Minute)},
+				{Value: "RU", Requests: 20, FirstSeen: now.Add(-10 * time.Minute)},
+			}},
+			repositories.UsageAttributeIPAddress: {Total: 3},
+		},
+	}
+	alertRepo := &fakeAlertRepository{}
+	service := NewAlertService(alertRepo, &fakeAlertRuleRepository{rules: anomalyRules()}, &fakeAlertAPIKeyRepository{key: key}, usageRepo, &fakeAlertViolationRepository{},
+		nil, AnomalyPolicy{Enabled: true}, ratelimit.NewFakeClock(now))
+
+	require.NoError(t, service.CheckAndCreateAlerts(context.Background(), key.ID))
+
+	alerts := make(map[models.AlertType]*models.Alert)
+	for _, alert := range alertRepo.created {
+		alerts[alert.Type] = alert
+	}
+	require.Len(t, alerts, 2)
+
+	spike := alerts[models.AlertTypeAbnormalUsage]
+	require.NotNil(t, spike)
+	assert.Equal(t, int64(1500), spike.Metadata["observed_requests"])
+	assert.InDelta(t, 100, spike.Metadata["expected_requests"], 0.001)
+	assert.Equal(t, 14, spike.Metadata["baseline_days"])
+
+	security := alerts[models.AlertTypeSecurityIssue]
+	require.NotNil(t, security)
+	assert.Equal(t, models.AlertSeverityHigh, security.Severity)
+	assert.Contains(t, security.Message, "2 new countries")
+	countries := security.Metadata["new_countries"].(*repositories.NewAttributeValues)
+	assert.Equal(t, "KP", countries.Values[0].Value)
+	// Three new IP addresses are below the threshold of ten
+	assert.NotContains(t, security.Metadata, "new_ip_addresses")
+}
+
+func TestAnomaliesIgnoreNewKeys(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	key := &models.APIKey{ID: uuid.New(), CreatedAt: now.Add(-2 * time.Hour)}
+
+	usageRepo := &fakeAnomalyUsageRepository{
+		requests: map[time.Time]int64{now.Add(-15 * time.Minute): 5000},
+		newValues: map[repositories.UsageAttribute]*repositories.NewAttributeValues{
+			repositories.UsageAttributeCountry: {Total: 4},
+		},
+	}
+	alertRepo := &fakeAlertRepository{}
+	service := NewAlertService(alertRepo, &fakeAlertRuleRepository{rules: anomalyRules()}, &fakeAlertAPIKeyRepository{key: key}, usageRepo, &fakeAlertViolationRepository{},
+		nil, AnomalyPolicy{Enabled: true}, ratelimit.NewFakeClock(now))
+
+	require.NoError(t, service.CheckAndCreateAlerts(context.Background(), key.ID))
+	assert.Empty(t, alertRepo.created)
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// autoResolvedBy is recorded as the resolver of alerts whose condition cleared
+const autoResolvedBy = "system:auto-resolve"
+
+// ruleDedupKey is the dedup key of the alerts a rule raises for an API key
+func ruleDedupKey(ruleID, apiKeyID uuid.UUID) string {
+	return "rule:" + ruleID.String() + ":" + apiKeyID.String()
+}
+
+// AcknowledgeAlert marks an alert as being handled, which stops its escalation
+func (s *alertService) AcknowledgeAlert(ctx context.Context, id uuid.UUID, acknowledgedBy string) (*AlertResponse, error) {
+	alert, err := s.alertRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == models.AlertStatusResolved {
+		return nil, fmt.Errorf("alert is already resolved")
+	}
+
+	now := s.clock.Now()
+	alert.Acknowledge(acknowledgedBy, now)
+	alert.UpdatedAt = now
+	if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+		return nil, err
+	}
+	return s.modelToResponse(alert), nil
+}
+
+// SnoozeAlert silences an alert until the given time, when it opens again and
+// is notified anew
+func (s *alertService) SnoozeAlert(ctx context.Context, id uuid.UUID, until time.Time) (*AlertResponse, error) {
+	alert, err := s.alertRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if alert.Status == models.AlertStatusResolved {
+		return nil, fmt.Errorf("alert is already resolved")
+	}
+
+	now := s.clock.Now()
+	if !until.After(now) {
+		return nil, fmt.Errorf("snooze time must be in the future")
+	}
+
+	alert.Snooze(until)
+	alert.UpdatedAt = now
+	if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+		return nil, err
+	}
+	return s.modelToResponse(alert), nil
+}
+
+// recordOccurrence updates an unresolved alert with a repeat of its
+// condition. The alert keeps the higher of its severity and the repeat's, so
+// an escalated alert stays escalated.
+func (s *alertService) recordOccurrence(ctx context.Context, alert *models.Alert, req *CreateAlertRequest, now time.Time) error {
+	alert.Occurrences++
+	alert.LastSeenAt = &now
+	alert.Message = req.Message
+	if req.Metadata != nil {
+		alert.Metadata = req.Metadata
+	}
+	if req.Severity.Rank() > alert.Severity.Rank() {
+		alert.Severity = req.Severity
+	}
+	alert.UpdatedAt = now
+
+	if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+		return fmt.Errorf("failed to update alert: %w", err)
+	}
+	return nil
+}
+
+// autoResolve resolves an alert whose condition cleared
+func (s *alertService) autoResolve(ctx context.Context, alert *models.Alert, now time.Time) {
+	alert.ResolveAt(autoResolvedBy, now)
+	alert.UpdatedAt = now
+	if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+		fmt.Printf("Failed to auto-resolve alert %s: %v\n", alert.ID, err)
+	}
+}
+
+// escalateAlerts raises the severity of open alerts whose rule's escalation
+// policy has a step due and notifies them again. An alert that has reached
+// several steps since the last run skips to the last of them. Alerts of
+// disabled or deleted rules do not escalate.
+func (s *alertService) escalateAlerts(ctx context.Context, rules []*models.AlertRule) {
+	policies := make(map[uuid.UUID]*models.AlertEscalationPolicy)
+	for _, rule := range rules {
+		if rule.Escalation != nil && len(rule.Escalation.Steps) > 0 {
+			policies[rule.ID] = rule.Escalation
+		}
+	}
+	if len(policies) == 0 {
+		return
+	}
+
+	open, err := s.alertRepo.GetByStatus(ctx, models.AlertStatusOpen)
+	if err != nil {
+		fmt.Printf("Failed to get open alerts: %v\n", err)
+		return
+	}
+
+	now := s.clock.Now()
+	for _, alert := range open {
+		if alert.RuleID == nil || policies[*alert.RuleID] == nil {
+			continue
+		}
+		policy := policies[*alert.RuleID]
+
+		escalated := false
+		for {
+			step, due := policy.Due(alert.EscalationLevel, now.Sub(alert.CreatedAt))
+			if !due {
+				break
+			}
+			alert.EscalationLevel++
+			if step.Severity.Rank() > alert.Severity.Rank() {
+				alert.Severity = step.Severity
+			}
+			escalated = true
+		}
+		if !escalated {
+			continue
+		}
+
+		alert.EscalatedAt = &now
+		alert.UpdatedAt = now
+		if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+			fmt.Printf("Failed to escalate alert %s: %v\n", alert.ID, err)
+			continue
+		}
+		if err := s.NotifyAlert(ctx, alert); err != nil {
+			fmt.Printf("Failed to send escalated alert notification: %v\n", err)
+		}
+	}
+}
+
+// wakeSnoozedAlerts opens snoozed alerts whose snooze has ended and notifies
+// them again
+func (s *alertService) wakeSnoozedAlerts(ctx context.Context) {
+	snoozed, err := s.alertRepo.GetByStatus(ctx, models.AlertStatusSnoozed)
+	if err != nil {
+		fmt.Printf("Failed to get snoozed alerts: %v\n", err)
+		return
+	}
+
+	now := s.clock.Now()
+	for _, alert := range snoozed {
+		if alert.SnoozedUntil != nil && now.Before(*alert.SnoozedUntil) {
+			continue
+		}
+
+		alert.Reopen()
+		alert.UpdatedAt = now
+		if err := s.alertRepo.UpdateState(ctx, alert); err != nil {
+			fmt.Printf("Failed to wake alert %s: %v\n", alert.ID, err)
+			continue
+		}
+		if err := s.NotifyAlert(ctx, alert); err != nil {
+			fmt.Printf("Failed to send woken alert notification: %v\n", err)
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeAlertNotifier records the severity of every alert notified
+type fakeAlertNotifier struct {
+	NotificationService
+	mu         sync.Mutex
+	severities []models.AlertSeverity
+}
+
+func (n *fakeAlertNotifier) SendAlertNotification(ctx context.Context, alert *models.Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.severities = append(n.severities, alert.Severity)
+	return nil
+}
+
+func (n *fakeAlertNotifier) notified() []models.AlertSeverity {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]models.AlertSeverity(nil), n.severities...)
+}
+
+// newLifecycleTestService returns a service evaluating a slow response time
+// rule against one key, and the repository, notifier and usage it works with
+func newLifecycleTestService(rule *models.AlertRule, clock *ratelimit.FakeClock) (AlertService, *fakeAlertRepository, *fakeAlertNotifier, *fakeRuleUsageRepository, *models.APIKey) {
+	key := &models.APIKey{ID: uuid.New(), Tier: models.APIKeyTierPro}
+	usageRepo := &fakeRuleUsageRepository{stats: repositories.UsageStats{TotalRequests: 10, AvgResponseTime: 900}}
+	alertRepo := &fakeAlertRepository{}
+	notifier := &fakeAlertNotifier{}
+	service := NewAlertService(alertRepo, &fakeAlertRuleRepository{rules: []*models.AlertRule{rule}}, &fakeAlertAPIKeyRepository{key: key},
+		usageRepo, &fakeAlertViolationRepository{}, notifier, AnomalyPolicy{}, clock)
+	return service, alertRepo, notifier, usageRepo, key
+}
+
+func slowResponseRule() *models.AlertRule {
+	return &models.AlertRule{ID: uuid.New(), Name: "slow", Type: models.AlertTypeSystemError, Severity: models.AlertSeverityMedium,
+		MatchMode: models.AlertMatchAll, Enabled: true, CooldownMinutes: 30, Conditions: models.AlertConditions{
+			ResponseTimeMs:    &models.ThresholdCondition{Operator: "gt", Value: 500},
+			TimeWindowMinutes: 5,
+		}}
+}
+
+func TestAlertDeduplicationAndAutoResolve(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC))
+	rule := slowResponseRule()
+	service, alertRepo, _, usageRepo, _ := newLifecycleTestService(rule, clock)
+
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	require.Len(t, alertRepo.created, 1)
+	alert := alertRepo.created[0]
+	assert.Equal(t, models.AlertStatusOpen, alert.Status)
+	require.NotNil(t, alert.DedupKey)
+	assert.Equal(t, 1, alert.Occurrences)
+
+	// The condition holds again and updates the open alert
+	clock.Advance(5 * time.Minute)
+	usageRepo.stats.AvgResponseTime = 1200
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	require.Len(t, alertRepo.created, 1)
+	assert.Equal(t, 2, alert.Occurrences)
+	assert.Equal(t, clock.Now(), *alert.LastSeenAt)
+	assert.Contains(t, alert.Message, "1200.00ms")
+
+	// The condition cleared
+	clock.Advance(5 * time.Minute)
+	usageRepo.stats.AvgResponseTime = 100
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, models.AlertStatusResolved, alert.Status)
+	assert.True(t, alert.Resolved)
+	assert.Equal(t, autoResolvedBy, *alert.ResolvedBy)
+
+	// A relapse within the cooldown raises nothing; after it, a new alert
+	usageRepo.stats.AvgResponseTime = 900
+	clock.Advance(5 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Len(t, alertRepo.created, 1)
+
+	clock.Advance(30 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	require.Len(t, alertRepo.created, 2)
+	assert.Equal(t, *alert.DedupKey, *alertRepo.created[1].DedupKey)
+	assert.Equal(t, models.AlertStatusOpen, alertRepo.created[1].Status)
+}
+
+func TestCreateAlertDeduplicatesByKey(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC))
+	service, alertRepo, notifier, _, key := newLifecycleTestService(slowResponseRule(), clock)
+
+	req := &CreateAlertRequest{APIKeyID: key.ID, Type: models.AlertTypeSecurityAlert, Severity: models.AlertSeverityLow,
+		Message: "Login from a new country", DedupKey: "login:new-country"}
+	first, err := service.CreateAlert(ctx, req)
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return len(notifier.notified()) == 1 }, time.Second, time.Millisecond)
+
+	req.Severity = models.AlertSeverityHigh
+	second, err := service.CreateAlert(ctx, req)
+	require.NoError(t, err)
+
+	assert.Equal(t, first.ID, second.ID)
+	assert.Equal(t, 2, second.Occurrences)
+	assert.Equal(t, models.AlertSeverityHigh, second.Severity)
+	assert.Len(t, alertRepo.created, 1)
+
+	// Resolved alerts are not updated
+	alertRepo.created[0].ResolveAt("ops", clock.Now())
+	third, err := service.CreateAlert(ctx, req)
+	require.NoError(t, err)
+	assert.NotEqual(t, first.ID, third.ID)
+	require.Eventually(t, func() bool { return len(notifier.notified()) == 2 }, time.Second, time.Millisecond)
+}
+
+func TestAlertEscalation(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC)
+	clock := ratelimit.NewFakeClock(start)
+	rule := slowResponseRule()
+	rule.Escalation = &models.AlertEscalationPolicy{Steps: []models.AlertEscalationStep{
+		{AfterMinutes: 15, Severity: models.AlertSeverityHigh},
+		{AfterMinutes: 30, Severity: models.AlertSeverityHigh},
+		{AfterMinutes: 60, Severity: models.AlertSeverityCritical},
+	}}
+	service, alertRepo, notifier, _, _ := newLifecycleTestService(rule, clock)
+
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	require.Len(t, alertRepo.created, 1)
+	alert := alertRepo.created[0]
+	require.Eventually(t, func() bool { return len(notifier.notified()) == 1 }, time.Second, time.Millisecond)
+
+	clock.Advance(10 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, 0, alert.EscalationLevel)
+
+	// Two steps are due; the alert is notified once at the later one
+	clock.Advance(25 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, 2, alert.EscalationLevel)
+	assert.Equal(t, models.AlertSeverityHigh, alert.Severity)
+	assert.Equal(t, clock.Now(), *alert.EscalatedAt)
+	assert.Equal(t, []models.AlertSeverity{models.AlertSeverityMedium, models.AlertSeverityHigh}, notifier.notified())
+
+	// Acknowledged alerts stop escalating
+	_, err := service.AcknowledgeAlert(ctx, alert.ID, "oncall@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusAcknowledged, alert.Status)
+
+	clock.Advance(time.Hour)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, 2, alert.EscalationLevel)
+	assert.Len(t, notifier.notified(), 2)
+	assert.Equal(t, 4, alert.Occurrences, "acknowledged alerts still deduplicate")
+}
+
+func TestSnoozeAlert(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2025, 3, 10, 14, 0, 0, 0, time.UTC))
+	service, alertRepo, notifier, _, _ := newLifecycleTestService(slowResponseRule(), clock)
+
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	require.Len(t, alertRepo.created, 1)
+	alert := alertRepo.created[0]
+	require.Eventually(t, func() bool { return len(notifier.notified()) == 1 }, time.Second, time.Millisecond)
+
+	_, err := service.SnoozeAlert(ctx, alert.ID, clock.Now().Add(-time.Minute))
+	assert.Error(t, err)
+
+	snoozed, err := service.SnoozeAlert(ctx, alert.ID, clock.Now().Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusSnoozed, snoozed.Status)
+
+	clock.Advance(30 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, models.AlertStatusSnoozed, alert.Status)
+	assert.Len(t, notifier.notified(), 1)
+
+	clock.Advance(31 * time.Minute)
+	require.NoError(t, service.ProcessAlertRules(ctx))
+	assert.Equal(t, models.AlertStatusOpen, alert.Status)
+	assert.Nil(t, alert.SnoozedUntil)
+	assert.Len(t, notifier.notified(), 2)
+
+	// Resolved alerts cannot be acknowledged or snoozed
+	alert.ResolveAt("ops", clock.Now())
+	_, err = service.AcknowledgeAlert(ctx, alert.ID, "ops")
+	assert.Error(t, err)
+	_, err = service.SnoozeAlert(ctx, alert.ID, clock.Now().Add(time.Hour))
+	assert.Error(t, err)
+}
+
+func TestValidateEscalation(t *testing.T) {
+	step := func(after int, severity models.AlertSeverity) models.AlertEscalationStep {
+		return models.AlertEscalationStep{AfterMinutes: after, Severity: severity}
+	}
+	tests := []struct {
+		name    string
+		steps   []models.AlertEscalationStep
+		wantErr bool
+	}{
+		{"valid", []models.AlertEscalationStep{step(15, models.AlertSeverityHigh), step(60, models.AlertSeverityCritical)}, false},
+		{"same severity", []models.AlertEscalationStep{step(15, models.AlertSeverityMedium)}, false},
+		{"no delay", []models.AlertEscalationStep{step(0, models.AlertSeverityHigh)}, true},
+		{"out of order", []models.AlertEscalationStep{step(30, models.AlertSeverityHigh), step(15, models.AlertSeverityCritical)}, true},
+		{"lower than rule", []models.AlertEscalationStep{step(15, models.AlertSeverityLow)}, true},
+		{"de-escalates", []models.AlertEscalationStep{step(15, models.AlertSeverityCritical), step(30, models.AlertSeverityHigh)}, true},
+		{"unknown severity", []models.AlertEscalationStep{step(15, "urgent")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateEscalation(&models.AlertEscalationPolicy{Steps: tt.steps}, models.AlertSeverityMedium)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
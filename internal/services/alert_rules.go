@@ -0,0 +1,501 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// maxAlertRuleWindowMinutes bounds the time window of a rule to a week, the
+// longest range usage rollups answer cheaply
+const maxAlertRuleWindowMinutes = 7 * 24 * 60
+
+// CreateAlertRuleRequest contains data for creating an alert rule
+type CreateAlertRuleRequest struct {
+	Name            string                        `json:"name"`
+	Description     string                        `json:"description"`
+	Type            models.AlertType              `json:"type"`
+	Severity        models.AlertSeverity          `json:"severity"`
+	Conditions      models.AlertConditions        `json:"conditions"`
+	MatchMode       models.AlertMatchMode         `json:"match_mode"` // defaults to all
+	Scope           models.AlertRuleScope         `json:"scope"`
+	Enabled         *bool                         `json:"enabled"` // defaults to true
+	CooldownMinutes int                           `json:"cooldown_minutes"`
+	Escalation      *models.AlertEscalationPolicy `json:"escalation"`
+	CreatedBy       string                        `json:"created_by"`
+}
+
+// UpdateAlertRuleRequest contains data for updating an alert rule. Conditions
+// and Scope replace the rule's conditions and scope as a whole.
+type UpdateAlertRuleRequest struct {
+	Name            *string                       `json:"name"`
+	Description     *string                       `json:"description"`
+	Type            *models.AlertType             `json:"type"`
+	Severity        *models.AlertSeverity         `json:"severity"`
+	Conditions      *models.AlertConditions       `json:"conditions"`
+	MatchMode       *models.AlertMatchMode        `json:"match_mode"`
+	Scope           *models.AlertRuleScope        `json:"scope"`
+	Enabled         *bool                         `json:"enabled"`
+	CooldownMinutes *int                          `json:"cooldown_minutes"`
+	Escalation      *models.AlertEscalationPolicy `json:"escalation"` // an empty policy removes escalation
+}
+
+// CreateRule validates and stores a new alert rule
+func (s *alertService) CreateRule(ctx context.Context, req *CreateAlertRuleRequest) (*models.AlertRule, error) {
+	rule := &models.AlertRule{
+		Name:            strings.TrimSpace(req.Name),
+		Description:     req.Description,
+		Type:            req.Type,
+		Severity:        req.Severity,
+		Conditions:      req.Conditions,
+		MatchMode:       req.MatchMode,
+		Scope:           req.Scope,
+		Enabled:         true,
+		CooldownMinutes: req.CooldownMinutes,
+		Escalation:      req.Escalation,
+		CreatedBy:       req.CreatedBy,
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if rule.MatchMode == "" {
+		rule.MatchMode = models.AlertMatchAll
+	}
+
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Create(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// GetRule retrieves an alert rule by ID
+func (s *alertService) GetRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error) {
+	return s.ruleRepo.GetByID(ctx, id)
+}
+
+// UpdateRule validates and applies changes to an alert rule
+func (s *alertService) UpdateRule(ctx context.Context, id uuid.UUID, req *UpdateAlertRuleRequest) (*models.AlertRule, error) {
+	rule, err := s.ruleRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.Type != nil {
+		rule.Type = *req.Type
+	}
+	if req.Severity != nil {
+		rule.Severity = *req.Severity
+	}
+	if req.Conditions != nil {
+		rule.Conditions = *req.Conditions
+	}
+	if req.MatchMode != nil {
+		rule.MatchMode = *req.MatchMode
+	}
+	if req.Scope != nil {
+		rule.Scope = *req.Scope
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if req.Escalation != nil {
+		rule.Escalation = req.Escalation
+		if len(rule.Escalation.Steps) == 0 {
+			rule.Escalation = nil
+		}
+	}
+
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	if err := s.ruleRepo.Update(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// DeleteRule deletes an alert rule. Alerts it raised are kept.
+func (s *alertService) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	return s.ruleRepo.Delete(ctx, id)
+}
+
+// ListRules retrieves alert rules with filtering and pagination
+func (s *alertService) ListRules(ctx context.Context, filter *repositories.AlertRuleFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	if pagination == nil {
+		pagination = repositories.DefaultPagination()
+	}
+	return s.ruleRepo.List(ctx, filter, pagination)
+}
+
+// validateRule checks that an alert rule can be evaluated
+func (s *alertService) validateRule(ctx context.Context, rule *models.AlertRule) error {
+	if rule.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len(rule.Name) > 255 {
+		return fmt.Errorf("name must be at most 255 characters")
+	}
+	if !rule.Type.IsValid() {
+		return fmt.Errorf("invalid alert type: %s", rule.Type)
+	}
+	if !rule.Severity.IsValid() {
+		return fmt.Errorf("invalid severity: %s", rule.Severity)
+	}
+	if !rule.MatchMode.IsValid() {
+		return fmt.Errorf("invalid match mode: %s", rule.MatchMode)
+	}
+	if rule.CooldownMinutes < 0 {
+		return fmt.Errorf("cooldown_minutes must not be negative")
+	}
+
+	if err := validateConditions(rule.Conditions); err != nil {
+		return err
+	}
+	if err := validateEscalation(rule.Escalation, rule.Severity); err != nil {
+		return err
+	}
+
+	scope := rule.Scope
+	if scope.APIKeyID != nil {
+		if _, err := s.apiKeyRepo.GetByID(ctx, *scope.APIKeyID); err != nil {
+			return fmt.Errorf("invalid api key: %w", err)
+		}
+	}
+	if scope.Tier != nil {
+		switch *scope.Tier {
+		case models.APIKeyTierFree, models.APIKeyTierPro, models.APIKeyTierEnterprise, models.APIKeyTierAll:
+		default:
+			return fmt.Errorf("invalid tier: %s", *scope.Tier)
+		}
+	}
+	return nil
+}
+
+// validateConditions checks every condition of a rule and its time window
+func validateConditions(c models.AlertConditions) error {
+	if c.IsEmpty() {
+		return fmt.Errorf("at least one condition is required")
+	}
+
+	thresholds := []struct {
+		name      string
+		condition *models.ThresholdCondition
+	}{
+		{"rate_limit_violations", c.RateLimitViolations},
+		{"quota_usage_percent", c.QuotaUsagePercent},
+		{"error_rate_percent", c.ErrorRatePercent},
+		{"response_time_ms", c.ResponseTimeMs},
+	}
+	for _, threshold := range thresholds {
+		if threshold.condition != nil && !threshold.condition.IsValid() {
+			return fmt.Errorf("invalid operator for %s: %q", threshold.name, threshold.condition.Operator)
+		}
+	}
+
+	if spike := c.UnusualTrafficSpike; spike != nil {
+		if spike.Multiplier < 1 {
+			return fmt.Errorf("unusual_traffic_spike multiplier must be at least 1")
+		}
+		if spike.BaselineMinutes < 24*60 {
+			return fmt.Errorf("unusual_traffic_spike baseline_minutes must cover at least one day")
+		}
+	}
+	if access := c.NewAccessPattern; access != nil {
+		if access.BaselineMinutes <= 0 {
+			return fmt.Errorf("new_access_pattern baseline_minutes must be positive")
+		}
+		if access.MinNewCountries < 0 || access.MinNewIPs < 0 || access.MinNewUserAgents < 0 {
+			return fmt.Errorf("new_access_pattern minimums must not be negative")
+		}
+		if access.MinNewCountries == 0 && access.MinNewIPs == 0 && access.MinNewUserAgents == 0 {
+			return fmt.Errorf("new_access_pattern needs a minimum for countries, IP addresses or user agents")
+		}
+	}
+	if failures := c.ConsecutiveFailures; failures != nil && failures.Count <= 0 {
+		return fmt.Errorf("consecutive_failures count must be positive")
+	}
+
+	// Every condition but quota usage looks at the time window
+	windowed := c.RateLimitViolations != nil || c.ErrorRatePercent != nil || c.ResponseTimeMs != nil ||
+		c.UnusualTrafficSpike != nil || c.NewAccessPattern != nil || c.ConsecutiveFailures != nil
+	if c.TimeWindowMinutes < 0 || (windowed && c.TimeWindowMinutes == 0) {
+		return fmt.Errorf("time_window_minutes must be positive")
+	}
+	if c.TimeWindowMinutes > maxAlertRuleWindowMinutes {
+		return fmt.Errorf("time_window_minutes must be at most %d", maxAlertRuleWindowMinutes)
+	}
+	return nil
+}
+
+// validateEscalation checks that escalation steps come later and are at
+// least as severe as the ones before them, starting from the rule's severity
+func validateEscalation(policy *models.AlertEscalationPolicy, severity models.AlertSeverity) error {
+	if policy == nil {
+		return nil
+	}
+
+	afterMinutes := 0
+	for i, step := range policy.Steps {
+		if step.AfterMinutes <= afterMinutes {
+			return fmt.Errorf("escalation step %d must come after %d minutes", i+1, afterMinutes)
+		}
+		if !step.Severity.IsValid() {
+			return fmt.Errorf("invalid severity for escalation step %d: %s", i+1, step.Severity)
+		}
+		if !step.Severity.AtLeast(severity) {
+			return fmt.Errorf("escalation step %d must be at least %s", i+1, severity)
+		}
+		afterMinutes = step.AfterMinutes
+		severity = step.Severity
+	}
+	return nil
+}
+
+// checkRules evaluates alert rules for an API key. A rule that triggers
+// updates the alert it raised for the key while that is unresolved, or raises
+// a new one unless in cooldown; a rule that no longer triggers resolves it.
+func (s *alertService) checkRules(ctx context.Context, apiKey *models.APIKey, rules []*models.AlertRule) {
+	now := s.clock.Now()
+
+	// Alerts the rules raised earlier, by dedup key
+	unresolved, err := s.alertRepo.GetUnresolvedByAPIKey(ctx, apiKey.ID)
+	if err != nil {
+		fmt.Printf("Failed to get unresolved alerts for api key %s: %v\n", apiKey.ID, err)
+		return
+	}
+	raised := make(map[string]*models.Alert, len(unresolved))
+	for _, alert := range unresolved {
+		if alert.DedupKey != nil {
+			raised[*alert.DedupKey] = alert
+		}
+	}
+
+	for _, rule := range rules {
+		if !rule.Enabled || !rule.AppliesTo(apiKey) {
+			continue
+		}
+		dedupKey := ruleDedupKey(rule.ID, apiKey.ID)
+		existing := raised[dedupKey]
+
+		// Evaluate rule conditions
+		triggered, message, metadata, err := s.evaluateRule(ctx, apiKey, rule, now)
+		if err != nil {
+			fmt.Printf("Error evaluating rule %s: %v\n", rule.Name, err)
+			continue
+		}
+		if !triggered {
+			// The condition cleared
+			if existing != nil {
+				s.autoResolve(ctx, existing, now)
+			}
+			continue
+		}
+
+		metadata["rule_name"] = rule.Name
+		req := &CreateAlertRequest{
+			APIKeyID: apiKey.ID,
+			Type:     rule.Type,
+			Severity: rule.Severity,
+			Message:  message,
+			Metadata: metadata,
+			DedupKey: dedupKey,
+			RuleID:   &rule.ID,
+		}
+
+		if existing != nil {
+			if err := s.recordOccurrence(ctx, existing, req, now); err != nil {
+				fmt.Printf("Failed to update alert for rule %s: %v\n", rule.Name, err)
+			}
+			continue
+		}
+
+		// Check if we're in cooldown period
+		if s.isInCooldown(ctx, apiKey.ID, rule, now) {
+			continue
+		}
+
+		if _, err := s.CreateAlert(ctx, req); err != nil {
+			fmt.Printf("Failed to create alert for rule %s: %v\n", rule.Name, err)
+		}
+	}
+}
+
+// conditionCheck evaluates one condition of a rule. It returns the alert
+// message and evidence when the condition holds.
+type conditionCheck func() (bool, string, map[string]interface{}, error)
+
+// evaluateRule evaluates the conditions of a rule over its time window ending
+// at now. A rule matching all conditions triggers when every condition holds;
+// a rule matching any triggers when one does. The alert message and evidence
+// combine those of every condition that held.
+func (s *alertService) evaluateRule(ctx context.Context, apiKey *models.APIKey, rule *models.AlertRule, now time.Time) (bool, string, map[string]interface{}, error) {
+	checks := s.conditionChecks(ctx, apiKey, rule.Conditions, now)
+	if len(checks) == 0 {
+		return false, "", nil, nil
+	}
+
+	var messages []string
+	metadata := make(map[string]interface{})
+	for _, check := range checks {
+		triggered, message, evidence, err := check()
+		if err != nil {
+			return false, "", nil, err
+		}
+		if !triggered {
+			if rule.MatchMode != models.AlertMatchAny {
+				return false, "", nil, nil
+			}
+			continue
+		}
+		messages = append(messages, message)
+		for key, value := range evidence {
+			metadata[key] = value
+		}
+	}
+	if len(messages) == 0 {
+		return false, "", nil, nil
+	}
+
+	return true, strings.Join(messages, "; "), metadata, nil
+}
+
+// conditionChecks returns a check for every condition set in conditions,
+// cheapest first so rules matching all conditions stop early
+func (s *alertService) conditionChecks(ctx context.Context, apiKey *models.APIKey, conditions models.AlertConditions, now time.Time) []conditionCheck {
+	windowMinutes := conditions.TimeWindowMinutes
+	startTime := now.Add(-time.Duration(windowMinutes) * time.Minute)
+
+	// Error rate and response time share one stats query
+	var stats *repositories.UsageStats
+	usageStats := func() (*repositories.UsageStats, error) {
+		if stats != nil {
+			return stats, nil
+		}
+		var err error
+		stats, err = s.usageRepo.GetUsageStats(ctx, apiKey.ID, startTime, now)
+		return stats, err
+	}
+
+	var checks []conditionCheck
+
+	// Check quota usage
+	if condition := conditions.QuotaUsagePercent; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			if apiKey.QuotaLimit <= 0 {
+				return false, "", nil, nil
+			}
+			usagePercent := (float64(apiKey.TotalUsage) / float64(apiKey.QuotaLimit)) * 100
+			if !condition.Evaluate(usagePercent) {
+				return false, "", nil, nil
+			}
+			message := fmt.Sprintf("Quota usage exceeded threshold: %.2f%% used", usagePercent)
+			return true, message, map[string]interface{}{"quota_usage_percent": usagePercent}, nil
+		})
+	}
+
+	// Check rate limit violations
+	if condition := conditions.RateLimitViolations; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			count, err := s.violationRepo.CountRecentViolations(ctx, apiKey.ID, windowMinutes)
+			if err != nil {
+				return false, "", nil, err
+			}
+			if !condition.Evaluate(float64(count)) {
+				return false, "", nil, nil
+			}
+			message := fmt.Sprintf("Rate limit violations exceeded threshold: %d violations in %d minutes",
+				count, windowMinutes)
+			return true, message, map[string]interface{}{"violation_count": count}, nil
+		})
+	}
+
+	// Check error rate
+	if condition := conditions.ErrorRatePercent; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			stats, err := usageStats()
+			if err != nil {
+				return false, "", nil, err
+			}
+			if stats.TotalRequests == 0 {
+				return false, "", nil, nil
+			}
+			errorRate := (float64(stats.FailedRequests) / float64(stats.TotalRequests)) * 100
+			if !condition.Evaluate(errorRate) {
+				return false, "", nil, nil
+			}
+			message := fmt.Sprintf("Error rate exceeded threshold: %.2f%% in %d minutes", errorRate, windowMinutes)
+			return true, message, map[string]interface{}{"error_rate_percent": errorRate}, nil
+		})
+	}
+
+	// Check response time
+	if condition := conditions.ResponseTimeMs; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			stats, err := usageStats()
+			if err != nil {
+				return false, "", nil, err
+			}
+			if stats.TotalRequests == 0 || !condition.Evaluate(stats.AvgResponseTime) {
+				return false, "", nil, nil
+			}
+			message := fmt.Sprintf("Average response time exceeded threshold: %.2fms in %d minutes",
+				stats.AvgResponseTime, windowMinutes)
+			return true, message, map[string]interface{}{"avg_response_time_ms": stats.AvgResponseTime}, nil
+		})
+	}
+
+	// Check for a run of failed requests
+	if condition := conditions.ConsecutiveFailures; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			count, err := s.usageRepo.CountConsecutiveFailures(ctx, apiKey.ID, startTime)
+			if err != nil {
+				return false, "", nil, err
+			}
+			if count < int64(condition.Count) {
+				return false, "", nil, nil
+			}
+			message := fmt.Sprintf("Consecutive failures exceeded threshold: %d failed requests in a row", count)
+			return true, message, map[string]interface{}{"consecutive_failures": count}, nil
+		})
+	}
+
+	// Check traffic against the key's seasonal baseline
+	if condition := conditions.UnusualTrafficSpike; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			if !s.anomaly.Enabled {
+				return false, "", nil, nil
+			}
+			return s.detectSpike(ctx, apiKey, condition, startTime, now)
+		})
+	}
+
+	// Check for countries, IP addresses and user agents the key never used
+	if condition := conditions.NewAccessPattern; condition != nil {
+		checks = append(checks, func() (bool, string, map[string]interface{}, error) {
+			if !s.anomaly.Enabled {
+				return false, "", nil, nil
+			}
+			return s.detectNewAccess(ctx, apiKey.ID, condition, startTime, now)
+		})
+	}
+
+	return checks
+}
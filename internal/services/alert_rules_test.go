@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+type fakeAlertRuleRepository struct {
+	repositories.AlertRuleRepository
+	rules []*models.AlertRule
+}
+
+func (r *fakeAlertRuleRepository) GetEnabled(ctx context.Context) ([]*models.AlertRule, error) {
+	var enabled []*models.AlertRule
+	for _, rule := range r.rules {
+		if rule.Enabled {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled, nil
+}
+
+// fakeRuleUsageRepository serves fixed usage and records the windows queried
+type fakeRuleUsageRepository struct {
+	repositories.UsageLogRepository
+	stats       repositories.UsageStats
+	failures    int64
+	statsStarts []time.Time
+}
+
+func (r *fakeRuleUsageRepository) GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*repositories.UsageStats, error) {
+	r.statsStarts = append(r.statsStarts, startTime)
+	stats := r.stats
+	return &stats, nil
+}
+
+func (r *fakeRuleUsageRepository) CountConsecutiveFailures(ctx context.Context, apiKeyID uuid.UUID, since time.Time) (int64, error) {
+	return r.failures, nil
+}
+
+func newRuleTestService(key *models.APIKey, rules []*models.AlertRule, usageRepo repositories.UsageLogRepository, now time.Time) (AlertService, *fakeAlertRepository) {
+	alertRepo := &fakeAlertRepository{}
+	service := NewAlertService(alertRepo, &fakeAlertRuleRepository{rules: rules}, &fakeAlertAPIKeyRepository{key: key},
+		usageRepo, &fakeAlertViolationRepository{}, nil, AnomalyPolicy{Enabled: true}, ratelimit.NewFakeClock(now))
+	return service, alertRepo
+}
+
+func TestAlertRuleMatchModes(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	key := &models.APIKey{ID: uuid.New(), Tier: models.APIKeyTierPro}
+	// A 50% error rate, but only three failures in a row
+	usageRepo := &fakeRuleUsageRepository{
+		stats:    repositories.UsageStats{TotalRequests: 100, FailedRequests: 50},
+		failures: 3,
+	}
+	conditions := models.AlertConditions{
+		ErrorRatePercent:    &models.ThresholdCondition{Operator: "gte", Value: 20},
+		ConsecutiveFailures: &models.CountCondition{Count: 10},
+		TimeWindowMinutes:   15,
+	}
+	allRule := &models.AlertRule{ID: uuid.New(), Name: "all", Type: models.AlertTypeSystemError, Severity: models.AlertSeverityHigh,
+		MatchMode: models.AlertMatchAll, Enabled: true, CooldownMinutes: 30, Conditions: conditions}
+	anyRule := &models.AlertRule{ID: uuid.New(), Name: "any", Type: models.AlertTypeSystemError, Severity: models.AlertSeverityLow,
+		MatchMode: models.AlertMatchAny, Enabled: true, CooldownMinutes: 30, Conditions: conditions}
+
+	service, alertRepo := newRuleTestService(key, []*models.AlertRule{allRule, anyRule}, usageRepo, now)
+	require.NoError(t, service.CheckAndCreateAlerts(context.Background(), key.ID))
+
+	require.Len(t, alertRepo.created, 1)
+	alert := alertRepo.created[0]
+	assert.Equal(t, anyRule.ID, *alert.RuleID)
+	assert.Equal(t, "any", alert.Metadata["rule_name"])
+	assert.InDelta(t, 50, alert.Metadata["error_rate_percent"], 0.001)
+	assert.NotContains(t, alert.Metadata, "consecutive_failures")
+
+	// Both conditions hold now, and the "any" rule updates the alert it raised
+	usageRepo.failures = 12
+	require.NoError(t, service.CheckAndCreateAlerts(context.Background(), key.ID))
+
+	require.Len(t, alertRepo.created, 2)
+	alert = alertRepo.created[1]
+	assert.Equal(t, allRule.ID, *alert.RuleID)
+	assert.Equal(t, int64(12), alert.Metadata["consecutive_failures"])
+	assert.Contains(t, alert.Message, "Error rate exceeded threshold")
+	assert.Contains(t, alert.Message, "12 failed requests in a row")
+}
+
+func TestAlertRuleScopeAndWindows(t *testing.T) {
+	now := time.Date(2025, 3, 10, 14, 30, 0, 0, time.UTC)
+	team := uuid.New()
+	key := &models.APIKey{ID: uuid.New(), Tier: models.APIKeyTierFree, TeamID: &team}
+	usageRepo := &fakeRuleUsageRepository{stats: repositories.UsageStats{TotalRequests: 10, AvgResponseTime: 900}}
+
+	slow := func(name string, window int, scope models.AlertRuleScope) *models.AlertRule {
+		return &models.AlertRule{ID: uuid.New(), Name: name, Type: models.AlertTypeSystemError, Severity: models.AlertSeverityMedium,
+			MatchMode: models.AlertMatchAll, Enabled: true, Scope: scope, Conditions: models.AlertConditions{
+				ResponseTimeMs:    &models.ThresholdCondition{Operator: "gt", Value: 500},
+				TimeWindowMinutes: window,
+			}}
+	}
+	pro := models.APIKeyTierPro
+	free := models.APIKeyTierFree
+	otherTeam := uuid.New()
+	otherKey := uuid.New()
+	rules := []*models.AlertRule{
+		slow("global", 5, models.AlertRuleScope{}),
+		slow("team", 60, models.AlertRuleScope{TeamID: &team, Tier: &free}),
+		slow("pro tier", 10, models.AlertRuleScope{Tier: &pro}),
+		slow("other team", 10, models.AlertRuleScope{TeamID: &otherTeam}),
+		slow("other key", 10, models.AlertRuleScope{APIKeyID: &otherKey}),
+	}
+	disabled := slow("disabled", 10, models.AlertRuleScope{})
+	disabled.Enabled = false
+	rules = append(rules, disabled)
+
+	service, alertRepo := newRuleTestService(key, rules, usageRepo, now)
+	require.NoError(t, service.CheckAndCreateAlerts(context.Background(), key.ID))
+
+	var raised []string
+	for _, alert := range alertRepo.created {
+		raised = append(raised, alert.Metadata["rule_name"].(string))
+	}
+	assert.ElementsMatch(t, []string{"global", "team"}, raised)
+	// Each rule looks at its own window
+	assert.Equal(t, []time.Time{now.Add(-5 * time.Minute), now.Add(-60 * time.Minute)}, usageRepo.statsStarts)
+}
+
+func TestValidateAlertConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		conditions models.AlertConditions
+		valid      bool
+	}{
+		{"empty", models.AlertConditions{TimeWindowMinutes: 5}, false},
+		{"quota needs no window", models.AlertConditions{QuotaUsagePercent: &models.ThresholdCondition{Operator: "gte", Value: 90}}, true},
+		{"bad operator", models.AlertConditions{QuotaUsagePercent: &models.ThresholdCondition{Operator: ">=", Value: 90}}, false},
+		{"missing window", models.AlertConditions{ConsecutiveFailures: &models.CountCondition{Count: 5}}, false},
+		{"zero count", models.AlertConditions{ConsecutiveFailures: &models.CountCondition{}, TimeWindowMinutes: 5}, false},
+		{"window too long", models.AlertConditions{ConsecutiveFailures: &models.CountCondition{Count: 5}, TimeWindowMinutes: 8 * 24 * 60}, false},
+		{"spike", models.AlertConditions{UnusualTrafficSpike: &models.SpikeCondition{Multiplier: 3, BaselineMinutes: 7 * 24 * 60}, TimeWindowMinutes: 15}, true},
+		{"spike without baseline", models.AlertConditions{UnusualTrafficSpike: &models.SpikeCondition{Multiplier: 3}, TimeWindowMinutes: 15}, false},
+		{"new access without minimums", models.AlertConditions{NewAccessPattern: &models.NewAccessCondition{BaselineMinutes: 60}, TimeWindowMinutes: 15}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateConditions(tt.conditions)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
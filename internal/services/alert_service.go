@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 // AlertService defines the interface for alert management business logic
@@ -19,11 +20,21 @@ type AlertService interface {
 	ListAlerts(ctx context.Context, filter *repositories.AlertFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error)
 	ResolveAlert(ctx context.Context, id uuid.UUID, resolvedBy string) error
 	ResolveAlerts(ctx context.Context, ids []uuid.UUID, resolvedBy string) error
+	AcknowledgeAlert(ctx context.Context, id uuid.UUID, acknowledgedBy string) (*AlertResponse, error)
+	SnoozeAlert(ctx context.Context, id uuid.UUID, until time.Time) (*AlertResponse, error)
 	GetAlertsSummary(ctx context.Context, hours int) (*repositories.AlertsSummary, error)
 	CheckAndCreateAlerts(ctx context.Context, apiKeyID uuid.UUID) error
 	ProcessAlertRules(ctx context.Context) error
 	GetUnresolvedAlerts(ctx context.Context, apiKeyID uuid.UUID) ([]*models.Alert, error)
 	NotifyAlert(ctx context.Context, alert *models.Alert) error
+	GetPendingAlerts(ctx context.Context) ([]*models.Alert, error)
+	MarkAlertSent(ctx context.Context, alertID string) error
+	CleanupOldAlerts(ctx context.Context, before time.Time) (int64, error)
+	CreateRule(ctx context.Context, req *CreateAlertRuleRequest) (*models.AlertRule, error)
+	GetRule(ctx context.Context, id uuid.UUID) (*models.AlertRule, error)
+	UpdateRule(ctx context.Context, id uuid.UUID, req *UpdateAlertRuleRequest) (*models.AlertRule, error)
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	ListRules(ctx context.Context, filter *repositories.AlertRuleFilter, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error)
 }
 
 // CreateAlertRequest contains data for creating a new alert
@@ -33,6 +44,8 @@ type CreateAlertRequest struct {
 	Severity  models.AlertSeverity `json:"severity" validate:"required"`
 	Message   string               `json:"message" validate:"required,min=1,max=500"`
 	Metadata  map[string]interface{} `json:"metadata"`
+	DedupKey  string               `json:"dedup_key" validate:"max=255"` // updates the unresolved alert with this key instead of raising another
+	RuleID    *uuid.UUID           `json:"-"` // set for alerts raised by a rule
 }
 
 // UpdateAlertRequest contains data for updating an alert
@@ -46,87 +59,65 @@ type UpdateAlertRequest struct {
 type AlertResponse struct {
 	ID         uuid.UUID            `json:"id"`
 	APIKeyID   uuid.UUID            `json:"api_key_id"`
+	RuleID     *uuid.UUID           `json:"rule_id,omitempty"`
 	Type       models.AlertType     `json:"type"`
 	Severity   models.AlertSeverity `json:"severity"`
 	Message    string               `json:"message"`
 	Metadata   map[string]interface{} `json:"metadata"`
+	Status     models.AlertStatus   `json:"status"`
+	DedupKey   *string              `json:"dedup_key,omitempty"`
+	Occurrences int                 `json:"occurrences"`
+	LastSeenAt *time.Time           `json:"last_seen_at,omitempty"`
+	AcknowledgedAt *time.Time       `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy *string          `json:"acknowledged_by,omitempty"`
+	SnoozedUntil *time.Time         `json:"snoozed_until,omitempty"`
+	EscalationLevel int             `json:"escalation_level"`
+	EscalatedAt *time.Time          `json:"escalated_at,omitempty"`
 	Resolved   bool                 `json:"resolved"`
 	ResolvedAt *time.Time           `json:"resolved_at"`
 	ResolvedBy *string              `json:"resolved_by"`
+	Deliveries map[string]*models.AlertDelivery `json:"deliveries,omitempty"`
 	CreatedAt  time.Time            `json:"created_at"`
 	UpdatedAt  time.Time            `json:"updated_at"`
 }
 
-// AlertRule defines rules for automatic alert creation
-type AlertRule struct {
-	ID              uuid.UUID            `json:"id"`
-	Name            string               `json:"name"`
-	Type            models.AlertType     `json:"type"`
-	Severity        models.AlertSeverity `json:"severity"`
-	Conditions      AlertConditions      `json:"conditions"`
-	Enabled         bool                 `json:"enabled"`
-	CooldownMinutes int                  `json:"cooldown_minutes"`
-}
-
-// AlertConditions defines conditions that trigger alerts
-type AlertConditions struct {
-	RateLimitViolations    *ThresholdCondition `json:"rate_limit_violations"`
-	QuotaUsagePercent      *ThresholdCondition `json:"quota_usage_percent"`
-	ErrorRatePercent       *ThresholdCondition `json:"error_rate_percent"`
-	ResponseTimeMs         *ThresholdCondition `json:"response_time_ms"`
-	UnusualTrafficSpike    *SpikeCondition     `json:"unusual_traffic_spike"`
-	ConsecutiveFailures    *CountCondition     `json:"consecutive_failures"`
-	TimeWindowMinutes      int                 `json:"time_window_minutes"`
-}
-
-// ThresholdCondition defines a threshold-based condition
-type ThresholdCondition struct {
-	Operator string  `json:"operator"` // "gt", "gte", "lt", "lte", "eq"
-	Value    float64 `json:"value"`
-}
-
-// SpikeCondition defines a traffic spike condition
-type SpikeCondition struct {
-	Multiplier float64 `json:"multiplier"` // e.g., 3.0 for 3x normal traffic
-	BaselineMinutes int `json:"baseline_minutes"`
-}
-
-// CountCondition defines a count-based condition
-type CountCondition struct {
-	Count int `json:"count"`
-}
-
 // alertService implements AlertService interface
 type alertService struct {
 	alertRepo     repositories.AlertRepository
+	ruleRepo      repositories.AlertRuleRepository
 	apiKeyRepo    repositories.APIKeyRepository
 	usageRepo     repositories.UsageLogRepository
 	violationRepo repositories.RateLimitViolationRepository
 	notificationService NotificationService
-	alertRules    []AlertRule
+	anomaly       AnomalyPolicy
+	clock         ratelimit.Clock
 }
 
 // NewAlertService creates a new alert service
 func NewAlertService(
 	alertRepo repositories.AlertRepository,
+	ruleRepo repositories.AlertRuleRepository,
 	apiKeyRepo repositories.APIKeyRepository,
 	usageRepo repositories.UsageLogRepository,
 	violationRepo repositories.RateLimitViolationRepository,
 	notificationService NotificationService,
+	anomaly AnomalyPolicy,
+	clock ratelimit.Clock,
 ) AlertService {
-	service := &alertService{
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &alertService{
 		alertRepo:           alertRepo,
+		ruleRepo:            ruleRepo,
 		apiKeyRepo:          apiKeyRepo,
 		usageRepo:           usageRepo,
 		violationRepo:       violationRepo,
 		notificationService: notificationService,
-		alertRules:          make([]AlertRule, 0),
+		anomaly:             anomaly.withDefaults(),
+		clock:               clock,
 	}
-
-	// Initialize default alert rules
-	service.initializeDefaultRules()
-
-	return service
 }
 
 // CreateAlert creates a new alert
@@ -136,17 +127,40 @@ func (s *alertService) CreateAlert(ctx context.Context, req *CreateAlertRequest)
 		return nil, fmt.Errorf("invalid api key: %w", err)
 	}
 
+	now := s.clock.Now()
+
+	// Repeated conditions update the alert they raised while it is unresolved
+	var dedupKey *string
+	if req.DedupKey != "" {
+		existing, err := s.alertRepo.GetUnresolvedByDedupKey(ctx, req.DedupKey)
+		if err == nil {
+			if err := s.recordOccurrence(ctx, existing, req, now); err != nil {
+				return nil, err
+			}
+			return s.modelToResponse(existing), nil
+		}
+		if err.Error() != "alert not found" {
+			return nil, err
+		}
+		dedupKey = &req.DedupKey
+	}
+
 	// Create alert model
 	alert := &models.Alert{
-		ID:        uuid.New(),
-		APIKeyID:  req.APIKeyID,
-		Type:      req.Type,
-		Severity:  req.Severity,
-		Message:   req.Message,
-		Metadata:  req.Metadata,
-		Resolved:  false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		ID:          uuid.New(),
+		APIKeyID:    req.APIKeyID,
+		RuleID:      req.RuleID,
+		Type:        req.Type,
+		Severity:    req.Severity,
+		Message:     req.Message,
+		Metadata:    req.Metadata,
+		Status:      models.AlertStatusOpen,
+		DedupKey:    dedupKey,
+		Occurrences: 1,
+		LastSeenAt:  &now,
+		Resolved:    false,
+		CreatedAt:   now,
+		UpdatedAt:   now,
 	}
 
 	// Create in repository
@@ -233,6 +247,14 @@ func (s *alertService) ListAlerts(ctx context.Context, filter *repositories.Aler
 
 // ResolveAlert marks an alert as resolved
 func (s *alertService) ResolveAlert(ctx context.Context, id uuid.UUID, resolvedBy string) error {
+	alert, err := s.alertRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if alert.Status == models.AlertStatusResolved {
+		return fmt.Errorf("alert is already resolved")
+	}
+
 	return s.alertRepo.ResolveAlert(ctx, id, resolvedBy)
 }
 
@@ -243,11 +265,12 @@ func (s *alertService) ResolveAlerts(ctx context.Context, ids []uuid.UUID, resol
 
 // GetAlertsSummary retrieves alert summary statistics
 func (s *alertService) GetAlertsSummary(ctx context.Context, hours int) (*repositories.AlertsSummary, error) {
-	startTime := time.Now().Add(time.Duration(-hours) * time.Hour)
+	startTime := s.clock.Now().Add(time.Duration(-hours) * time.Hour)
 	return s.alertRepo.GetAlertsSummary(ctx, startTime)
 }
 
-// CheckAndCreateAlerts checks conditions and creates alerts for an API key
+// CheckAndCreateAlerts evaluates every enabled alert rule that applies to an
+// API key and creates an alert for each rule whose conditions hold
 func (s *alertService) CheckAndCreateAlerts(ctx context.Context, apiKeyID uuid.UUID) error {
 	// Get API key
 	apiKey, err := s.apiKeyRepo.GetByID(ctx, apiKeyID)
@@ -255,58 +278,43 @@ func (s *alertService) CheckAndCreateAlerts(ctx context.Context, apiKeyID uuid.U
 		return fmt.Errorf("failed to get api key: %w", err)
 	}
 
-	// Check each alert rule
-	for _, rule := range s.alertRules {
-		if !rule.Enabled {
-			continue
-		}
-
-		// Check if we're in cooldown period
-		if s.isInCooldown(ctx, apiKeyID, rule.Type, rule.CooldownMinutes) {
-			continue
-		}
-
-		// Evaluate rule conditions
-		triggered, message, metadata, err := s.evaluateRuleConditions(ctx, apiKey, rule)
-		if err != nil {
-			fmt.Printf("Error evaluating rule %s: %v\n", rule.Name, err)
-			continue
-		}
-
-		if triggered {
-			// Create alert
-			req := &CreateAlertRequest{
-				APIKeyID: apiKeyID,
-				Type:     rule.Type,
-				Severity: rule.Severity,
-				Message:  message,
-				Metadata: metadata,
-			}
-
-			if _, err := s.CreateAlert(ctx, req); err != nil {
-				fmt.Printf("Failed to create alert for rule %s: %v\n", rule.Name, err)
-			}
-		}
+	rules, err := s.ruleRepo.GetEnabled(ctx)
+	if err != nil {
+		return err
 	}
 
+	s.checkRules(ctx, apiKey, rules)
 	return nil
 }
 
-// ProcessAlertRules processes alert rules for all active API keys
+// ProcessAlertRules processes alert rules for all active API keys, then
+// escalates open alerts and wakes snoozed ones
 func (s *alertService) ProcessAlertRules(ctx context.Context) error {
-	// Get all active API keys
-	activeKeys, err := s.apiKeyRepo.GetActiveByTier(ctx, models.APIKeyTierAll) // Assuming this gets all active keys
+	rules, err := s.ruleRepo.GetEnabled(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to get active api keys: %w", err)
+		return err
 	}
 
-	// Process each API key
-	for _, apiKey := range activeKeys {
-		if err := s.CheckAndCreateAlerts(ctx, apiKey.ID); err != nil {
-			fmt.Printf("Failed to check alerts for API key %s: %v\n", apiKey.ID, err)
+	if len(rules) > 0 {
+		// Get all active API keys
+		activeKeys, err := s.apiKeyRepo.GetActiveByTier(ctx, models.APIKeyTierAll)
+		if err != nil {
+			return fmt.Errorf("failed to get active api keys: %w", err)
+		}
+
+		// Process each API key
+		for _, apiKey := range activeKeys {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			s.checkRules(ctx, apiKey, rules)
 		}
 	}
 
+	// Escalate before waking snoozed alerts, so a woken alert is notified once per run
+	s.escalateAlerts(ctx, rules)
+	s.wakeSnoozedAlerts(ctx)
+
 	return nil
 }
 
@@ -324,20 +332,22 @@ func (s *alertService) NotifyAlert(ctx context.Context, alert *models.Alert) err
 	return s.notificationService.SendAlertNotification(ctx, alert)
 }
 
-// isInCooldown checks if an alert type is in cooldown period
-func (s *alertService) isInCooldown(ctx context.Context, apiKeyID uuid.UUID, alertType models.AlertType, cooldownMinutes int) bool {
-	if cooldownMinutes <= 0 {
+// isInCooldown checks if a rule raised an alert for an API key within its
+// cooldown period. Unresolved alerts are updated rather than raised again, so
+// the cooldown keeps a condition that clears and returns from raising a new
+// alert every run.
+func (s *alertService) isInCooldown(ctx context.Context, apiKeyID uuid.UUID, rule *models.AlertRule, now time.Time) bool {
+	if rule.CooldownMinutes <= 0 {
 		return false
 	}
 
-	// Check for recent alerts of the same type
+	// Check for recent alerts raised by the same rule
 	filter := &repositories.AlertFilter{
 		APIKeyID: &apiKeyID,
-		Type:     &alertType,
-		Resolved: boolPtr(false),
+		RuleID:   &rule.ID,
 	}
 
-	startTime := time.Now().Add(time.Duration(-cooldownMinutes) * time.Minute)
+	startTime := now.Add(time.Duration(-rule.CooldownMinutes) * time.Minute)
 	filter.StartTime = &startTime
 
 	result, err := s.alertRepo.List(ctx, filter, &repositories.PaginationParams{Page: 1, PageSize: 1})
@@ -348,150 +358,6 @@ func (s *alertService) isInCooldown(ctx context.Context, apiKeyID uuid.UUID, ale
 	return result.Total > 0
 }
 
-// evaluateRuleConditions evaluates whether a rule's conditions are met
-func (s *alertService) evaluateRuleConditions(ctx context.Context, apiKey *models.APIKey, rule AlertRule) (bool, string, map[string]interface{}, error) {
-	conditions := rule.Conditions
-	timeWindow := time.Duration(conditions.TimeWindowMinutes) * time.Minute
-	startTime := time.Now().Add(-timeWindow)
-	endTime := time.Now()
-
-	metadata := make(map[string]interface{})
-
-	// Check rate limit violations
-	if conditions.RateLimitViolations != nil {
-		count, err := s.violationRepo.CountRecentViolations(ctx, apiKey.ID, conditions.TimeWindowMinutes)
-		if err != nil {
-			return false, "", nil, err
-		}
-
-		if s.evaluateThreshold(float64(count), conditions.RateLimitViolations) {
-			message := fmt.Sprintf("Rate limit violations exceeded threshold: %d violations in %d minutes",
-				count, conditions.TimeWindowMinutes)
-			metadata["violation_count"] = count
-			return true, message, metadata, nil
-		}
-	}
-
-	// Check quota usage
-	if conditions.QuotaUsagePercent != nil && apiKey.QuotaLimit > 0 {
-		usagePercent := (float64(apiKey.TotalUsage) / float64(apiKey.QuotaLimit)) * 100
-
-		if s.evaluateThreshold(usagePercent, conditions.QuotaUsagePercent) {
-			message := fmt.Sprintf("Quota usage exceeded threshold: %.2f%% used", usagePercent)
-			metadata["quota_usage_percent"] = usagePercent
-			return true, message, metadata, nil
-		}
-	}
-
-	// Check error rate
-	if conditions.ErrorRatePercent != nil {
-		stats, err := s.usageRepo.GetUsageStats(ctx, apiKey.ID, startTime, endTime)
-		if err != nil {
-			return false, "", nil, err
-		}
-
-		if stats.TotalRequests > 0 {
-			errorRate := (float64(stats.FailedRequests) / float64(stats.TotalRequests)) * 100
-
-			if s.evaluateThreshold(errorRate, conditions.ErrorRatePercent) {
-				message := fmt.Sprintf("Error rate exceeded threshold: %.2f%% in %d minutes",
-					errorRate, conditions.TimeWindowMinutes)
-				metadata["error_rate_percent"] = errorRate
-				return true, message, metadata, nil
-			}
-		}
-	}
-
-	// Check response time
-	if conditions.ResponseTimeMs != nil {
-		stats, err := s.usageRepo.GetUsageStats(ctx, apiKey.ID, startTime, endTime)
-		if err != nil {
-			return false, "", nil, err
-		}
-
-		if s.evaluateThreshold(stats.AvgResponseTime, conditions.ResponseTimeMs) {
-			message := fmt.Sprintf("Average response time exceeded threshold: %.2fms in %d minutes",
-				stats.AvgResponseTime, conditions.TimeWindowMinutes)
-			metadata["avg_response_time_ms"] = stats.AvgResponseTime
-			return true, message, metadata, nil
-		}
-	}
-
-	return false, "", nil, nil
-}
-
-// evaluateThreshold evaluates a threshold condition
-func (s *alertService) evaluateThreshold(value float64, condition *ThresholdCondition) bool {
-	switch condition.Operator {
-	case "gt":
-		return value > condition.Value
-	case "gte":
-		return value >= condition.Value
-	case "lt":
-		return value < condition.Value
-	case "lte":
-		return value <= condition.Value
-	case "eq":
-		return value == condition.Value
-	default:
-		return false
-	}
-}
-
-// initializeDefaultRules sets up default alert rules
-func (s *alertService) initializeDefaultRules() {
-	s.alertRules = []AlertRule{
-		{
-			ID:              uuid.New(),
-			Name:            "High Rate Limit Violations",
-			Type:            models.AlertTypeRateLimit,
-			Severity:        models.AlertSeverityHigh,
-			Enabled:         true,
-			CooldownMinutes: 15,
-			Conditions: AlertConditions{
-				RateLimitViolations: &ThresholdCondition{Operator: "gte", Value: 10},
-				TimeWindowMinutes:   10,
-			},
-		},
-		{
-			ID:              uuid.New(),
-			Name:            "Quota Nearly Exhausted",
-			Type:            models.AlertTypeQuotaExceeded,
-			Severity:        models.AlertSeverityMedium,
-			Enabled:         true,
-			CooldownMinutes: 60,
-			Conditions: AlertConditions{
-				QuotaUsagePercent: &ThresholdCondition{Operator: "gte", Value: 90},
-				TimeWindowMinutes: 5,
-			},
-		},
-		{
-			ID:              uuid.New(),
-			Name:            "High Error Rate",
-			Type:            models.AlertTypeSystemError,
-			Severity:        models.AlertSeverityHigh,
-			Enabled:         true,
-			CooldownMinutes: 30,
-			Conditions: AlertConditions{
-				ErrorRatePercent:  &ThresholdCondition{Operator: "gte", Value: 20},
-				TimeWindowMinutes: 15,
-			},
-		},
-		{
-			ID:              uuid.New(),
-			Name:            "Slow Response Time",
-			Type:            models.AlertTypeSystemError,
-			Severity:        models.AlertSeverityMedium,
-			Enabled:         true,
-			CooldownMinutes: 30,
-			Conditions: AlertConditions{
-				ResponseTimeMs:    &ThresholdCondition{Operator: "gte", Value: 5000}, // 5 seconds
-				TimeWindowMinutes: 10,
-			},
-		},
-	}
-}
-
 // GetPendingAlerts retrieves all pending alerts
 func (s *alertService) GetPendingAlerts(ctx context.Context) ([]*models.Alert, error) {
 	// Simple implementation - get all alerts for now
@@ -515,13 +381,24 @@ func (s *alertService) modelToResponse(alert *models.Alert) *AlertResponse {
 	return &AlertResponse{
 		ID:         alert.ID,
 		APIKeyID:   alert.APIKeyID,
+		RuleID:     alert.RuleID,
 		Type:       alert.Type,
 		Severity:   alert.Severity,
 		Message:    alert.Message,
 		Metadata:   alert.Metadata,
+		Status:     alert.Status,
+		DedupKey:   alert.DedupKey,
+		Occurrences: alert.Occurrences,
+		LastSeenAt: alert.LastSeenAt,
+		AcknowledgedAt: alert.AcknowledgedAt,
+		AcknowledgedBy: alert.AcknowledgedBy,
+		SnoozedUntil: alert.SnoozedUntil,
+		EscalationLevel: alert.EscalationLevel,
+		EscalatedAt: alert.EscalatedAt,
 		Resolved:   alert.Resolved,
 		ResolvedAt: alert.ResolvedAt,
 		ResolvedBy: alert.ResolvedBy,
+		Deliveries: alert.Deliveries,
 		CreatedAt:  alert.CreatedAt,
 		UpdatedAt:  alert.UpdatedAt,
 	}
@@ -531,8 +408,3 @@ func (s *alertService) modelToResponse(alert *models.Alert) *AlertResponse {
 func boolPtr(b bool) *bool {
 	return &b
 }
-
-// NotificationService defines the interface for sending notifications
-type NotificationService interface {
-	SendAlertNotification(ctx context.Context, alert *models.Alert) error
-}
\ No newline at end of file
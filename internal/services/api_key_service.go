@@ -37,9 +37,11 @@ type CreateAPIKeyRequest struct {
 	Name         string            `json:"name" validate:"required,min=1,max=100"`
 	Description  string            `json:"description" validate:"max=500"`
 	Tier         models.APIKeyTier `json:"tier" validate:"required"`
+	OrganizationID *uuid.UUID      `json:"organization_id"` // set to the caller's organization unless the caller is a platform admin
 	UserID       uuid.UUID         `json:"user_id" validate:"required"`
 	TeamID       *uuid.UUID        `json:"team_id"`
 	Tags         []string          `json:"tags"`
+	Scopes       []string          `json:"scopes"` // defaults to models.DefaultScopes when omitted
 	ExpiresAt    *time.Time        `json:"expires_at"`
 	RateLimit    int               `json:"rate_limit" validate:"min=1"`
 	QuotaLimit   int64             `json:"quota_limit" validate:"min=1"`
@@ -50,6 +52,7 @@ type UpdateAPIKeyRequest struct {
 	Name        *string           `json:"name" validate:"omitempty,min=1,max=100"`
 	Description *string           `json:"description" validate:"omitempty,max=500"`
 	Status      *models.APIKeyStatus `json:"status"`
+	Tier        *models.APIKeyTier `json:"tier"` // changes are recorded for prorated billing
 	Tags        []string          `json:"tags"`
 	ExpiresAt   *time.Time        `json:"expires_at"`
 	RateLimit   *int              `json:"rate_limit" validate:"omitempty,min=1"`
@@ -63,9 +66,11 @@ type APIKeyResponse struct {
 	Description  string            `json:"description"`
 	Status       models.APIKeyStatus `json:"status"`
 	Tier         models.APIKeyTier `json:"tier"`
+	OrganizationID *uuid.UUID      `json:"organization_id,omitempty"`
 	UserID       uuid.UUID         `json:"user_id"`
 	TeamID       *uuid.UUID        `json:"team_id"`
 	Tags         []string          `json:"tags"`
+	Scopes       []string          `json:"scopes"`
 	RateLimit    int               `json:"rate_limit"`
 	QuotaLimit   int64             `json:"quota_limit"`
 	TotalUsage   int64             `json:"total_usage"`
@@ -119,6 +124,11 @@ func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReque
 		return nil, fmt.Errorf("failed to generate api key: %w", err)
 	}
 
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = models.DefaultScopes()
+	}
+
 	// Create API key model
 	apiKey := &models.APIKey{
 		ID:          uuid.New(),
@@ -127,9 +137,11 @@ func (s *apiKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyReque
 		KeyHash:     keyHash,
 		Status:      models.APIKeyStatusActive,
 		Tier:        req.Tier,
+		OrganizationID: req.OrganizationID,
 		UserID:      &req.UserID,
 		TeamID:      req.TeamID,
 		Tags:        req.Tags,
+		Scopes:      scopes,
 		RateLimit:   req.RateLimit,
 		QuotaLimit:  req.QuotaLimit,
 		TotalUsage:  0,
@@ -188,6 +200,12 @@ func (s *apiKeyService) UpdateAPIKey(ctx context.Context, id uuid.UUID, req *Upd
 	if req.Status != nil {
 		apiKey.Status = *req.Status
 	}
+	if req.Tier != nil {
+		if !req.Tier.IsValid() {
+			return nil, fmt.Errorf("invalid tier: %s", *req.Tier)
+		}
+		apiKey.Tier = *req.Tier
+	}
 	if req.Tags != nil {
 		apiKey.Tags = req.Tags
 	}
@@ -287,7 +305,8 @@ func (s *apiKeyService) ValidateAPIKey(ctx context.Context, key string) (*models
 	return apiKey, nil
 }
 
-// RotateAPIKey generates a new key for an existing API key
+// RotateAPIKey generates a new key for an existing API key. Only the secret
+// changes; the key keeps its ID, scopes, limits and owner.
 func (s *apiKeyService) RotateAPIKey(ctx context.Context, id uuid.UUID) (*APIKeyResponse, error) {
 	// Get existing API key
 	apiKey, err := s.apiKeyRepo.GetByID(ctx, id)
@@ -352,7 +371,7 @@ func (s *apiKeyService) GetAPIKeyStats(ctx context.Context, id uuid.UUID) (*APIK
 
 	// Get top endpoints (last 30 days)
 	thirtyDaysAgo := now.AddDate(0, 0, -30)
-	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, thirtyDaysAgo, now)
+	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, &id, thirtyDaysAgo, now)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
 	}
@@ -433,9 +452,11 @@ func (s *apiKeyService) modelToResponse(apiKey *models.APIKey) *APIKeyResponse {
 		Description: apiKey.Description,
 		Status:      apiKey.Status,
 		Tier:        apiKey.Tier,
+		OrganizationID: apiKey.OrganizationID,
 		UserID:      userID,
 		TeamID:      apiKey.TeamID,
 		Tags:        apiKey.Tags,
+		Scopes:      apiKey.Scopes,
 		RateLimit:   apiKey.RateLimit,
 		QuotaLimit:  apiKey.QuotaLimit,
 		TotalUsage:  apiKey.TotalUsage,
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+func TestAPIKeyService_CreateAPIKeyScopes(t *testing.T) {
+	repo := newFakeAPIKeyRepository()
+	service := NewAPIKeyService(repo, nil)
+	ctx := context.Background()
+
+	created, err := service.CreateAPIKey(ctx, &CreateAPIKeyRequest{Name: "default", UserID: uuid.New()})
+	require.NoError(t, err)
+	assert.Equal(t, models.DefaultScopes(), created.Scopes)
+
+	created, err = service.CreateAPIKey(ctx, &CreateAPIKeyRequest{Name: "checker", UserID: uuid.New(), Scopes: []string{models.ScopeRateLimitCheck}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.ScopeRateLimitCheck}, created.Scopes)
+
+	// An explicit empty list grants nothing
+	created, err = service.CreateAPIKey(ctx, &CreateAPIKeyRequest{Name: "none", UserID: uuid.New(), Scopes: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, created.Scopes)
+}
+
+func TestAPIKeyService_RotateAPIKeyKeepsScopes(t *testing.T) {
+	repo := newFakeAPIKeyRepository()
+	service := NewAPIKeyService(repo, nil)
+	ctx := context.Background()
+
+	scopes := []string{models.ScopeKeysRead, models.ScopeKeysWrite}
+	created, err := service.CreateAPIKey(ctx, &CreateAPIKeyRequest{Name: "ci", UserID: uuid.New(), Scopes: scopes})
+	require.NoError(t, err)
+	oldHash := repo.apiKeys[created.ID].KeyHash
+
+	rotated, err := service.RotateAPIKey(ctx, created.ID)
+	require.NoError(t, err)
+	require.NotNil(t, rotated.Key)
+	assert.NotEqual(t, *created.Key, *rotated.Key)
+	assert.NotEqual(t, oldHash, repo.apiKeys[created.ID].KeyHash)
+	assert.Equal(t, scopes, rotated.Scopes)
+	assert.Equal(t, scopes, repo.apiKeys[created.ID].Scopes)
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// ErrForbidden is returned when the caller's role does not allow an action
+var ErrForbidden = errors.New("forbidden")
+
+// Principal is the caller of an API request: the API key it authenticated
+// with and the user the key belongs to
+type Principal struct {
+	APIKey *models.APIKey
+
+	// User is nil for keys that do not belong to a user, which may only
+	// read and use themselves
+	User *models.User
+
+	// Platform is set for operator keys, which run the service across
+	// organizations
+	Platform bool
+}
+
+// OrganizationID returns the caller's organization, or nil if it has none
+func (p *Principal) OrganizationID() *uuid.UUID {
+	if p.User == nil {
+		return nil
+	}
+	return &p.User.OrganizationID
+}
+
+// Role returns the caller's role in its organization, empty if it has none
+func (p *Principal) Role() models.UserRole {
+	if p.User == nil {
+		return ""
+	}
+	return p.User.Role
+}
+
+// KeyAction is something a caller does to an API key
+type KeyAction string
+
+const (
+	// KeyActionRead views a key, its usage, limits and violations
+	KeyActionRead KeyAction = "read"
+	// KeyActionUse checks rate limits for a key
+	KeyActionUse KeyAction = "use"
+	// KeyActionWrite renames, rotates or deletes a key
+	KeyActionWrite KeyAction = "write"
+	// KeyActionManage changes a key's limits, tier or status or resets its
+	// counters
+	KeyActionManage KeyAction = "manage"
+)
+
+// KeyActionForUpdate returns the action an API key update performs. Changing
+// limits, tier or status manages the key; anything else writes it.
+func KeyActionForUpdate(req *UpdateAPIKeyRequest) KeyAction {
+	if req.Status != nil || req.Tier != nil || req.RateLimit != nil || req.QuotaLimit != nil {
+		return KeyActionManage
+	}
+	return KeyActionWrite
+}
+
+// AuthorizationService decides what callers may do. Members of an
+// organization act within it according to their role:
+//
+//   - viewers read keys, usage and limits
+//   - developers also create keys, check rate limits, and write the keys
+//     they own or that belong to their teams
+//   - admins also write every key, manage limits, users and teams
+//   - owners also manage other owners and admins
+//
+// Keys of other organizations are reported as not found.
+type AuthorizationService struct {
+	orgRepo    repositories.OrganizationRepository
+	apiKeyRepo repositories.APIKeyRepository
+}
+
+// NewAuthorizationService creates a new authorization service
+func NewAuthorizationService(orgRepo repositories.OrganizationRepository, apiKeyRepo repositories.APIKeyRepository) *AuthorizationService {
+	return &AuthorizationService{
+		orgRepo:    orgRepo,
+		apiKeyRepo: apiKeyRepo,
+	}
+}
+
+// ResolvePrincipal returns the caller authenticated by an API key
+func (s *AuthorizationService) ResolvePrincipal(ctx context.Context, apiKey *models.APIKey) (*Principal, error) {
+	principal := &Principal{
+		APIKey:   apiKey,
+		Platform: apiKey.IsOperator,
+	}
+	if apiKey.UserID == nil {
+		return principal, nil
+	}
+
+	user, err := s.orgRepo.GetUser(ctx, *apiKey.UserID)
+	if err != nil {
+		// Keys created before users existed name users that were never
+		// stored; they keep acting as themselves only
+		if err.Error() == "user not found" {
+			return principal, nil
+		}
+		return nil, err
+	}
+	principal.User = user
+	return principal, nil
+}
+
+// AuthorizeKey checks that the caller may perform action on an API key and
+// returns the key
+func (s *AuthorizationService) AuthorizeKey(ctx context.Context, p *Principal, apiKeyID uuid.UUID, action KeyAction) (*models.APIKey, error) {
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	if p.Platform {
+		return apiKey, nil
+	}
+
+	// A key may always look at and use itself
+	self := apiKey.ID == p.APIKey.ID
+	if self && (action == KeyActionRead || action == KeyActionUse) {
+		return apiKey, nil
+	}
+
+	orgID := p.OrganizationID()
+	if orgID == nil || apiKey.OrganizationID == nil || *apiKey.OrganizationID != *orgID {
+		if self {
+			return nil, fmt.Errorf("%w: the API key does not belong to a user", ErrForbidden)
+		}
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	role := p.Role()
+	switch action {
+	case KeyActionRead:
+		return apiKey, requireRole(role, models.UserRoleViewer)
+	case KeyActionUse:
+		return apiKey, requireRole(role, models.UserRoleDeveloper)
+	case KeyActionWrite:
+		if role.AtLeast(models.UserRoleAdmin) {
+			return apiKey, nil
+		}
+		if err := requireRole(role, models.UserRoleDeveloper); err != nil {
+			return nil, err
+		}
+		owns, err := s.ownsKey(ctx, p.User, apiKey)
+		if err != nil {
+			return nil, err
+		}
+		if !owns {
+			return nil, fmt.Errorf("%w: developers may only change their own and their teams' API keys", ErrForbidden)
+		}
+		return apiKey, nil
+	default:
+		return apiKey, requireRole(role, models.UserRoleAdmin)
+	}
+}
+
+// AuthorizeCreateKey checks that the caller may create an API key as
+// requested and fills in its organization and owner. The owner defaults to
+// the caller; only admins may create keys for other users. A key cannot grant
+// scopes it does not hold itself.
+func (s *AuthorizationService) AuthorizeCreateKey(ctx context.Context, p *Principal, req *CreateAPIKeyRequest) error {
+	scopes := req.Scopes
+	if scopes == nil {
+		scopes = models.DefaultScopes()
+	}
+	for _, scope := range scopes {
+		if !p.APIKey.HasScope(scope) {
+			return fmt.Errorf("%w: cannot grant the %s scope without holding it", ErrForbidden, scope)
+		}
+	}
+
+	if p.Platform {
+		// Keys created for a user join the user's organization
+		if req.OrganizationID == nil && req.UserID != uuid.Nil {
+			if user, err := s.orgRepo.GetUser(ctx, req.UserID); err == nil {
+				req.OrganizationID = &user.OrganizationID
+			}
+		}
+		return nil
+	}
+
+	if err := s.RequireRole(p, models.UserRoleDeveloper); err != nil {
+		return err
+	}
+	orgID := p.OrganizationID()
+	if req.OrganizationID != nil && *req.OrganizationID != *orgID {
+		return fmt.Errorf("%w: API keys can only be created in your own organization", ErrForbidden)
+	}
+	req.OrganizationID = orgID
+
+	if req.UserID == uuid.Nil {
+		req.UserID = p.User.ID
+	}
+	if req.UserID != p.User.ID {
+		if err := s.RequireRole(p, models.UserRoleAdmin); err != nil {
+			return err
+		}
+		if _, err := s.GetOrgUser(ctx, p, req.UserID); err != nil {
+			return err
+		}
+	}
+
+	if req.TeamID != nil {
+		team, err := s.GetOrgTeam(ctx, p, *req.TeamID)
+		if err != nil {
+			return err
+		}
+		if !p.Role().AtLeast(models.UserRoleAdmin) {
+			member, err := s.orgRepo.IsTeamMember(ctx, team.ID, p.User.ID)
+			if err != nil {
+				return err
+			}
+			if !member {
+				return fmt.Errorf("%w: developers may only create API keys for their own teams", ErrForbidden)
+			}
+		}
+	}
+	return nil
+}
+
+// ScopeAPIKeyFilter limits an API key listing to the caller's organization
+func (s *AuthorizationService) ScopeAPIKeyFilter(p *Principal, filter *repositories.APIKeyFilter) error {
+	if p.Platform {
+		return nil
+	}
+	if err := s.RequireRole(p, models.UserRoleViewer); err != nil {
+		return err
+	}
+	filter.OrganizationID = p.OrganizationID()
+	return nil
+}
+
+// RequireRole checks that the caller has at least a role in its
+// organization. Platform callers have every role.
+func (s *AuthorizationService) RequireRole(p *Principal, min models.UserRole) error {
+	if p.Platform {
+		return nil
+	}
+	if p.User == nil {
+		return fmt.Errorf("%w: the API key does not belong to a user", ErrForbidden)
+	}
+	return requireRole(p.Role(), min)
+}
+
+// RequirePlatform checks that the caller operates the platform, for data
+// spanning every organization
+func (s *AuthorizationService) RequirePlatform(p *Principal) error {
+	if !p.Platform {
+		return fmt.Errorf("%w: requires an operator API key", ErrForbidden)
+	}
+	return nil
+}
+
+// GetOrgUser retrieves a user of the caller's organization
+func (s *AuthorizationService) GetOrgUser(ctx context.Context, p *Principal, id uuid.UUID) (*models.User, error) {
+	user, err := s.orgRepo.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Platform && (p.User == nil || user.OrganizationID != p.User.OrganizationID) {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+// GetOrgTeam retrieves a team of the caller's organization
+func (s *AuthorizationService) GetOrgTeam(ctx context.Context, p *Principal, id uuid.UUID) (*models.Team, error) {
+	team, err := s.orgRepo.GetTeam(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Platform && (p.User == nil || team.OrganizationID != p.User.OrganizationID) {
+		return nil, fmt.Errorf("team not found")
+	}
+	return team, nil
+}
+
+// ownsKey returns true if a user owns an API key or belongs to its team
+func (s *AuthorizationService) ownsKey(ctx context.Context, user *models.User, apiKey *models.APIKey) (bool, error) {
+	if apiKey.UserID != nil && *apiKey.UserID == user.ID {
+		return true, nil
+	}
+	if apiKey.TeamID == nil {
+		return false, nil
+	}
+	return s.orgRepo.IsTeamMember(ctx, *apiKey.TeamID, user.ID)
+}
+
+// requireRole checks that role is at least min
+func requireRole(role, min models.UserRole) error {
+	if !role.AtLeast(min) {
+		return fmt.Errorf("%w: requires the %s role or higher", ErrForbidden, min)
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// authzFixture is an organization with a user of every role, a team the
+// developer belongs to, and a second organization
+type authzFixture struct {
+	service  *AuthorizationService
+	orgRepo  *fakeOrganizationRepository
+	apiKeys  *fakeAPIKeyRepository
+	orgID    uuid.UUID
+	otherOrg uuid.UUID
+	users    map[models.UserRole]*models.User
+	team     *models.Team
+}
+
+func newAuthzFixture(t *testing.T) *authzFixture {
+	t.Helper()
+
+	f := &authzFixture{
+		orgRepo:  newFakeOrganizationRepository(),
+		apiKeys:  newFakeAPIKeyRepository(),
+		orgID:    uuid.New(),
+		otherOrg: uuid.New(),
+		users:    make(map[models.UserRole]*models.User),
+	}
+	ctx := context.Background()
+	for _, role := range []models.UserRole{models.UserRoleOwner, models.UserRoleAdmin, models.UserRoleDeveloper, models.UserRoleViewer} {
+		user := &models.User{OrganizationID: f.orgID, Email: string(role) + "@example.com", Role: role}
+		require.NoError(t, f.orgRepo.CreateUser(ctx, user))
+		f.users[role] = user
+	}
+	f.team = &models.Team{OrganizationID: f.orgID, Name: "payments"}
+	require.NoError(t, f.orgRepo.CreateTeam(ctx, f.team))
+	require.NoError(t, f.orgRepo.AddTeamMember(ctx, &models.TeamMember{TeamID: f.team.ID, UserID: f.users[models.UserRoleDeveloper].ID}))
+
+	f.service = NewAuthorizationService(f.orgRepo, f.apiKeys)
+	return f
+}
+
+// addKey stores an API key of a user, or of no user if user is nil
+func (f *authzFixture) addKey(user *models.User, orgID *uuid.UUID, scopes ...string) *models.APIKey {
+	apiKey := &models.APIKey{ID: uuid.New(), OrganizationID: orgID, Scopes: scopes}
+	if user != nil {
+		apiKey.UserID = &user.ID
+	}
+	f.apiKeys.apiKeys[apiKey.ID] = apiKey
+	return apiKey
+}
+
+// addOperator stores an operator key with the admin scope
+func (f *authzFixture) addOperator() *models.APIKey {
+	apiKey := f.addKey(nil, nil, models.ScopeAdmin)
+	apiKey.IsOperator = true
+	return apiKey
+}
+
+// principal resolves the caller of an API key
+func (f *authzFixture) principal(t *testing.T, apiKey *models.APIKey) *Principal {
+	t.Helper()
+	principal, err := f.service.ResolvePrincipal(context.Background(), apiKey)
+	require.NoError(t, err)
+	return principal
+}
+
+func TestAuthorizationService_ResolvePrincipal(t *testing.T) {
+	f := newAuthzFixture(t)
+
+	admin := f.users[models.UserRoleAdmin]
+	principal := f.principal(t, f.addKey(admin, &f.orgID))
+	assert.False(t, principal.Platform)
+	assert.Equal(t, models.UserRoleAdmin, principal.Role())
+	assert.Equal(t, f.orgID, *principal.OrganizationID())
+
+	// Legacy keys name users that were never stored
+	missing := &models.User{ID: uuid.New()}
+	operator := f.addKey(missing, nil, models.ScopeAdmin)
+	operator.IsOperator = true
+	principal = f.principal(t, operator)
+	assert.True(t, principal.Platform)
+	assert.Nil(t, principal.User)
+	assert.Nil(t, principal.OrganizationID())
+
+	// Only operator keys operate the platform, whatever their scopes
+	principal = f.principal(t, f.addKey(admin, &f.orgID, models.ScopeAdmin))
+	assert.False(t, principal.Platform)
+	assert.ErrorIs(t, f.service.RequirePlatform(principal), ErrForbidden)
+}
+
+func TestAuthorizationService_AuthorizeKey(t *testing.T) {
+	f := newAuthzFixture(t)
+
+	developer := f.users[models.UserRoleDeveloper]
+	ownKey := f.addKey(developer, &f.orgID)
+	teamKey := f.addKey(f.users[models.UserRoleOwner], &f.orgID)
+	teamKey.TeamID = &f.team.ID
+	colleagueKey := f.addKey(f.users[models.UserRoleAdmin], &f.orgID)
+	foreignKey := f.addKey(nil, &f.otherOrg)
+
+	tests := []struct {
+		name    string
+		caller  *models.APIKey
+		target  *models.APIKey
+		action  KeyAction
+		wantErr string
+	}{
+		{"viewer cannot check limits", f.addKey(f.users[models.UserRoleViewer], &f.orgID), colleagueKey, KeyActionUse, "forbidden: requires the developer role or higher"},
+		{"developer writes team key", ownKey, teamKey, KeyActionWrite, ""},
+		{"developer cannot write colleague key", ownKey, colleagueKey, KeyActionWrite, "forbidden: developers may only change their own and their teams' API keys"},
+		{"admin manages limits", colleagueKey, teamKey, KeyActionManage, ""},
+		{"other organization is not found", colleagueKey, foreignKey, KeyActionRead, "api key not found"},
+		{"platform key manages any key", f.addOperator(), foreignKey, KeyActionManage, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKey, err := f.service.AuthorizeKey(context.Background(), f.principal(t, tt.caller), tt.target.ID, tt.action)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.target.ID, apiKey.ID)
+		})
+	}
+}
+
+func TestAuthorizationService_AuthorizeCreateKey(t *testing.T) {
+	f := newAuthzFixture(t)
+	ctx := context.Background()
+
+	manageScopes := []string{models.ScopeRateLimitCheck, models.ScopeKeysRead, models.ScopeKeysWrite}
+	developer := f.principal(t, f.addKey(f.users[models.UserRoleDeveloper], &f.orgID, manageScopes...))
+	req := &CreateAPIKeyRequest{Name: "ci", TeamID: &f.team.ID}
+	require.NoError(t, f.service.AuthorizeCreateKey(ctx, developer, req))
+	assert.Equal(t, f.orgID, *req.OrganizationID)
+	assert.Equal(t, f.users[models.UserRoleDeveloper].ID, req.UserID)
+
+	req = &CreateAPIKeyRequest{Name: "ci", UserID: f.users[models.UserRoleViewer].ID}
+	assert.ErrorIs(t, f.service.AuthorizeCreateKey(ctx, developer, req), ErrForbidden)
+
+	// Keys cannot grant scopes they do not hold
+	req = &CreateAPIKeyRequest{Name: "ci", Scopes: []string{models.ScopeAdmin}}
+	assert.EqualError(t, f.service.AuthorizeCreateKey(ctx, developer, req), "forbidden: cannot grant the admin scope without holding it")
+
+	admin := f.principal(t, f.addKey(f.users[models.UserRoleAdmin], &f.orgID, manageScopes...))
+	req = &CreateAPIKeyRequest{Name: "ci", UserID: f.users[models.UserRoleViewer].ID}
+	require.NoError(t, f.service.AuthorizeCreateKey(ctx, admin, req))
+	assert.Equal(t, f.orgID, *req.OrganizationID)
+}
+
+func TestAuthorizationService_ScopeAPIKeyFilter(t *testing.T) {
+	f := newAuthzFixture(t)
+
+	filter := &repositories.APIKeyFilter{}
+	require.NoError(t, f.service.ScopeAPIKeyFilter(f.principal(t, f.addKey(f.users[models.UserRoleViewer], &f.orgID)), filter))
+	assert.Equal(t, f.orgID, *filter.OrganizationID)
+
+	filter = &repositories.APIKeyFilter{}
+	require.NoError(t, f.service.ScopeAPIKeyFilter(f.principal(t, f.addOperator()), filter))
+	assert.Nil(t, filter.OrganizationID)
+
+	assert.ErrorIs(t, f.service.ScopeAPIKeyFilter(f.principal(t, f.addKey(nil, nil)), &repositories.APIKeyFilter{}), ErrForbidden)
+}
+
+func TestKeyActionForUpdate(t *testing.T) {
+	name := "renamed"
+	limit := 500
+	assert.Equal(t, KeyActionWrite, KeyActionForUpdate(&UpdateAPIKeyRequest{Name: &name}))
+	assert.Equal(t, KeyActionManage, KeyActionForUpdate(&UpdateAPIKeyRequest{Name: &name, RateLimit: &limit}))
+}
@@ -0,0 +1,380 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// billingSegment is a part of a billing period during which an API key stayed
+// on one tier
+type billingSegment struct {
+	Tier  models.APIKeyTier
+	Start time.Time
+	End   time.Time
+}
+
+// billingSegments splits [start, end) at every tier change within it.
+// tierAtStart is the key's tier when the changes begin; changes are oldest
+// first, and those at or before start only set the starting tier.
+func billingSegments(tierAtStart models.APIKeyTier, changes []*models.APIKeyTierChange, start, end time.Time) []billingSegment {
+	if !end.After(start) {
+		return nil
+	}
+
+	var segments []billingSegment
+	current := billingSegment{Tier: tierAtStart, Start: start}
+	for _, change := range changes {
+		if !change.ChangedAt.Before(end) {
+			break
+		}
+		if !change.ChangedAt.After(current.Start) {
+			current.Tier = change.ToTier
+			continue
+		}
+		current.End = change.ChangedAt
+		segments = append(segments, current)
+		current = billingSegment{Tier: change.ToTier, Start: change.ChangedAt}
+	}
+	current.End = end
+	return append(segments, current)
+}
+
+// activeSpan is a part of a billing period during which an API key was active
+type activeSpan struct {
+	Start time.Time
+	End   time.Time
+}
+
+// activeSpans returns the parts of [start, end) during which an API key was
+// active. statusAtStart is the key's status when the changes begin; changes
+// are oldest first, and those at or before start only set the starting
+// status.
+func activeSpans(statusAtStart models.APIKeyStatus, changes []*models.APIKeyStatusChange, start, end time.Time) []activeSpan {
+	if !end.After(start) {
+		return nil
+	}
+
+	var spans []activeSpan
+	status, from := statusAtStart, start
+	for _, change := range changes {
+		if !change.ChangedAt.Before(end) {
+			break
+		}
+		if !change.ChangedAt.After(start) {
+			status = change.ToStatus
+			continue
+		}
+		if status == models.APIKeyStatusActive && change.ToStatus != models.APIKeyStatusActive {
+			spans = append(spans, activeSpan{Start: from, End: change.ChangedAt})
+		}
+		if status != models.APIKeyStatusActive && change.ToStatus == models.APIKeyStatusActive {
+			from = change.ChangedAt
+		}
+		status = change.ToStatus
+	}
+	if status == models.APIKeyStatusActive {
+		spans = append(spans, activeSpan{Start: from, End: end})
+	}
+	return spans
+}
+
+// billingMonth returns the monthly billing period, in UTC, that t falls in
+func billingMonth(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(0, 1, 0)
+}
+
+// plansByTier loads the pricing plan of every tier
+func (s *BillingService) plansByTier(ctx context.Context) (map[models.APIKeyTier]*models.PricingPlan, error) {
+	plans, err := s.pricingRepo.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byTier := make(map[models.APIKeyTier]*models.PricingPlan, len(plans))
+	for _, plan := range plans {
+		byTier[plan.Tier] = plan
+	}
+	return byTier, nil
+}
+
+// billableKeys returns the API keys to bill for [periodStart, periodEnd):
+// keys active now that existed during the period, keys that changed status or
+// were deleted in it, and keys with usage in it, deleted ones included
+func (s *BillingService) billableKeys(ctx context.Context, periodStart, periodEnd time.Time) ([]*models.APIKey, error) {
+	active, err := s.apiKeyRepo.GetActiveByTier(ctx, models.APIKeyTierAll)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active api keys: %w", err)
+	}
+
+	seen := make(map[uuid.UUID]bool, len(active))
+	var apiKeys []*models.APIKey
+	for _, apiKey := range active {
+		seen[apiKey.ID] = true
+		if apiKey.CreatedAt.Before(periodEnd) {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+
+	// A key suspended, revoked or deleted during the period owes the part
+	// of it that the key was active, whether or not it was used
+	changed, err := s.apiKeyRepo.GetStatusChangedBetween(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, apiKey := range changed {
+		if !seen[apiKey.ID] && apiKey.CreatedAt.Before(periodEnd) {
+			seen[apiKey.ID] = true
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+
+	usage, err := s.usageLogRepo.GetTopAPIKeys(ctx, periodStart, periodEnd, 0)
+	if err != nil {
+		return nil, err
+	}
+	var missing []uuid.UUID
+	for _, keyUsage := range usage {
+		if !seen[keyUsage.APIKeyID] {
+			missing = append(missing, keyUsage.APIKeyID)
+		}
+	}
+
+	others, err := s.apiKeyRepo.GetByIDsWithDeleted(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	for _, apiKey := range others {
+		if apiKey.DeletedAt.Valid && !apiKey.DeletedAt.Time.After(periodStart) {
+			continue
+		}
+		apiKeys = append(apiKeys, apiKey)
+	}
+	return apiKeys, nil
+}
+
+// calculateRecord prices the usage of an API key in [periodStart, periodEnd).
+// The period is split wherever the key changed tier, and each part is priced
+// with its tier's plan prorated to the part's share of the period. A key is
+// billed only for the time it existed and was active, so one created,
+// deleted, suspended or revoked during the period pays for part of it.
+func (s *BillingService) calculateRecord(
+	ctx context.Context,
+	apiKey *models.APIKey,
+	plans map[models.APIKeyTier]*models.PricingPlan,
+	periodStart, periodEnd time.Time,
+) (*models.BillingRecord, error) {
+	start, end := periodStart, periodEnd
+	if apiKey.CreatedAt.After(start) {
+		start = apiKey.CreatedAt
+	}
+	if apiKey.DeletedAt.Valid && apiKey.DeletedAt.Time.Before(end) {
+		end = apiKey.DeletedAt.Time
+	}
+
+	changes, err := s.apiKeyRepo.GetTierChanges(ctx, apiKey.ID, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	tierAtStart := apiKey.Tier
+	if len(changes) > 0 {
+		tierAtStart = changes[0].FromTier
+	}
+
+	statusChanges, err := s.apiKeyRepo.GetStatusChanges(ctx, apiKey.ID, periodStart)
+	if err != nil {
+		return nil, err
+	}
+	statusAtStart := apiKey.Status
+	if len(statusChanges) > 0 {
+		statusAtStart = statusChanges[0].FromStatus
+	}
+	var segments []billingSegment
+	for _, span := range activeSpans(statusAtStart, statusChanges, start, end) {
+		segments = append(segments, billingSegments(tierAtStart, changes, span.Start, span.End)...)
+	}
+
+	now := s.clock.Now()
+	record := &models.BillingRecord{
+		APIKeyID:    apiKey.ID,
+		PeriodStart: periodStart,
+		PeriodEnd:   periodEnd,
+		Status:      models.BillingPeriodStatusActive,
+		TierAtStart: string(tierAtStart),
+		TierAtEnd:   string(tierAtStart),
+		LineItems:   []models.BillingLineItem{},
+	}
+	if !now.Before(periodEnd) {
+		record.Status = models.BillingPeriodStatusCompleted
+	}
+
+	period := periodEnd.Sub(periodStart).Seconds()
+	for _, segment := range segments {
+		plan, ok := plans[segment.Tier]
+		if !ok {
+			return nil, fmt.Errorf("no pricing plan for tier %s", segment.Tier)
+		}
+		if record.Currency == "" {
+			record.Currency = plan.Currency
+		} else if record.Currency != plan.Currency {
+			return nil, fmt.Errorf("pricing plan of tier %s is in %s, not %s", segment.Tier, plan.Currency, record.Currency)
+		}
+
+		stats, err := s.usageLogRepo.GetUsageStats(ctx, apiKey.ID, segment.Start, segment.End)
+		if err != nil {
+			return nil, err
+		}
+
+		fraction := segment.End.Sub(segment.Start).Seconds() / period
+		charge := plan.Prorate(fraction).Charge(stats.TotalRequests, stats.TotalBandwidth)
+
+		record.TotalRequests += stats.TotalRequests
+		record.SuccessRequests += stats.SuccessfulRequests
+		record.ErrorRequests += stats.FailedRequests
+		record.RateLimitHits += stats.RateLimitedRequests
+		record.TotalBandwidth += stats.TotalBandwidth
+		record.OverageRequests += charge.OverageRequests
+		record.TierAtEnd = string(segment.Tier)
+
+		for _, item := range segmentLineItems(plan, segment, fraction, charge) {
+			if item.Kind == models.BillingLineItemBaseFee {
+				record.BaseAmount += item.Amount
+			} else {
+				record.OverageAmount += item.Amount
+			}
+			record.LineItems = append(record.LineItems, item)
+		}
+	}
+
+	if record.Currency == "" {
+		record.Currency = "USD"
+	}
+	record.BaseAmount = roundAmount(record.BaseAmount)
+	record.OverageAmount = roundAmount(record.OverageAmount)
+	record.CalculateTotalAmount()
+	record.CalculatedAt = &now
+	return record, nil
+}
+
+// segmentLineItems itemizes the charge of one billing segment. Usage the plan
+// does not price is left out. Amounts are rounded per item, so the items of a
+// record add up to its total.
+func segmentLineItems(plan *models.PricingPlan, segment billingSegment, fraction float64, charge models.PlanCharge) []models.BillingLineItem {
+	description := plan.Name + " plan"
+	if fraction < 1 {
+		description = fmt.Sprintf("%s plan, prorated %s to %s", plan.Name,
+			segment.Start.UTC().Format("2006-01-02 15:04"), segment.End.UTC().Format("2006-01-02 15:04"))
+	}
+
+	items := []models.BillingLineItem{{
+		Kind:        models.BillingLineItemBaseFee,
+		Description: description,
+		Tier:        segment.Tier,
+		PeriodStart: segment.Start,
+		PeriodEnd:   segment.End,
+		Quantity:    math.Round(fraction*10000) / 10000,
+		Amount:      roundAmount(charge.BaseFee),
+	}}
+
+	if charge.OverageRequests > 0 && len(plan.OverageTiers) > 0 {
+		items = append(items, models.BillingLineItem{
+			Kind:        models.BillingLineItemOverage,
+			Description: fmt.Sprintf("%s plan requests beyond the included %d", plan.Name, plan.Prorate(fraction).IncludedRequests),
+			Tier:        segment.Tier,
+			PeriodStart: segment.Start,
+			PeriodEnd:   segment.End,
+			Quantity:    float64(charge.OverageRequests),
+			Amount:      roundAmount(charge.OverageAmount),
+		})
+	}
+
+	if charge.BillableBandwidth > 0 && plan.BandwidthPricePerGB > 0 {
+		items = append(items, models.BillingLineItem{
+			Kind:        models.BillingLineItemBandwidth,
+			Description: plan.Name + " plan bandwidth beyond the included amount",
+			Tier:        segment.Tier,
+			PeriodStart: segment.Start,
+			PeriodEnd:   segment.End,
+			Quantity:    math.Round(float64(charge.BillableBandwidth)/models.BytesPerGB*10000) / 10000,
+			Amount:      roundAmount(charge.BandwidthAmount),
+		})
+	}
+
+	return items
+}
+
+// saveRecord stores a calculated billing record, replacing the record of the
+// same key and period unless that one is already paid or refunded. Payment
+// state of a replaced record is kept.
+func (s *BillingService) saveRecord(ctx context.Context, record *models.BillingRecord) (*models.BillingRecord, error) {
+	existing, err := s.billingRepo.GetByPeriod(ctx, record.APIKeyID, record.PeriodStart, record.PeriodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	if existing == nil {
+		record.PaymentStatus = models.PaymentStatusPending
+		if err := s.billingRepo.Create(ctx, record); err != nil {
+			return nil, err
+		}
+		return record, nil
+	}
+
+	if existing.PaymentStatus == models.PaymentStatusPaid || existing.PaymentStatus == models.PaymentStatusRefunded {
+		return existing, nil
+	}
+
+	record.ID = existing.ID
+	record.CreatedAt = existing.CreatedAt
+	record.UpdatedAt = s.clock.Now()
+	record.PaymentStatus = existing.PaymentStatus
+	record.PaidAt = existing.PaidAt
+	record.Metadata = existing.Metadata
+	if err := s.billingRepo.Update(ctx, record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// attachAPIKeys sets the APIKey relation of billing records, loading deleted
+// keys too
+func (s *BillingService) attachAPIKeys(ctx context.Context, records []*models.BillingRecord) error {
+	seen := make(map[uuid.UUID]bool, len(records))
+	var ids []uuid.UUID
+	for _, record := range records {
+		if !seen[record.APIKeyID] {
+			seen[record.APIKeyID] = true
+			ids = append(ids, record.APIKeyID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	apiKeys, err := s.apiKeyRepo.GetByIDsWithDeleted(ctx, ids)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uuid.UUID]*models.APIKey, len(apiKeys))
+	for _, apiKey := range apiKeys {
+		byID[apiKey.ID] = apiKey
+	}
+	for _, record := range records {
+		if apiKey, ok := byID[record.APIKeyID]; ok {
+			record.APIKey = *apiKey
+		}
+	}
+	return nil
+}
+
+// roundAmount rounds a money amount to the four decimal places it is stored with
+func roundAmount(amount float64) float64 {
+	return math.Round(amount*10000) / 10000
+}
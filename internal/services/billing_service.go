@@ -2,70 +2,324 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 // BillingService handles billing-related operations
 type BillingService struct {
-	billingRepo  *repositories.BillingRecordRepository
-	apiKeyRepo   *repositories.APIKeyRepository
-	usageLogRepo *repositories.UsageLogRepository
+	billingRepo  repositories.BillingRecordRepository
+	pricingRepo  repositories.PricingPlanRepository
+	apiKeyRepo   repositories.APIKeyRepository
+	usageLogRepo repositories.UsageLogRepository
+	clock        ratelimit.Clock
 }
 
 // NewBillingService creates a new billing service
 func NewBillingService(
-	billingRepo *repositories.BillingRecordRepository,
-	apiKeyRepo *repositories.APIKeyRepository,
-	usageLogRepo *repositories.UsageLogRepository,
+	billingRepo repositories.BillingRecordRepository,
+	pricingRepo repositories.PricingPlanRepository,
+	apiKeyRepo repositories.APIKeyRepository,
+	usageLogRepo repositories.UsageLogRepository,
+	clock ratelimit.Clock,
 ) *BillingService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
 	return &BillingService{
 		billingRepo:  billingRepo,
+		pricingRepo:  pricingRepo,
 		apiKeyRepo:   apiKeyRepo,
 		usageLogRepo: usageLogRepo,
+		clock:        clock,
 	}
 }
 
-// GenerateBillingRecords generates billing records for a given period
+// GenerateBillingRecords computes and saves the billing record of every API
+// key billable in [periodStart, periodEnd): keys active now, keys that changed
+// status or were deleted in the period, and keys with usage in it. Generating a period again recalculates its records,
+// except those already paid or refunded. A key that cannot be billed does not
+// stop the others; their errors are returned together.
 func (s *BillingService) GenerateBillingRecords(ctx context.Context, periodStart, periodEnd time.Time) ([]*models.BillingRecord, error) {
-	// TODO: Implement billing record generation
-	// For now, return empty slice to allow API to start
-	return []*models.BillingRecord{}, nil
+	if !periodEnd.After(periodStart) {
+		return nil, fmt.Errorf("billing period end must be after its start")
+	}
+
+	plans, err := s.plansByTier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKeys, err := s.billableKeys(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]*models.BillingRecord, 0, len(apiKeys))
+	var errs []error
+	for _, apiKey := range apiKeys {
+		if ctx.Err() != nil {
+			return records, ctx.Err()
+		}
+
+		record, err := s.calculateRecord(ctx, apiKey, plans, periodStart, periodEnd)
+		if err == nil {
+			record, err = s.saveRecord(ctx, record)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("api key %s: %w", apiKey.ID, err))
+			continue
+		}
+		records = append(records, record)
+	}
+
+	return records, errors.Join(errs...)
 }
 
-// GetBillingRecord retrieves a billing record by ID
-func (s *BillingService) GetBillingRecord(ctx context.Context, recordID string) (*models.BillingRecord, error) {
-	// TODO: Implement when billing repository interface is completed
-	return nil, fmt.Errorf("billing record retrieval not implemented yet")
+// CalculateBillingRecord computes the billing record of an API key for
+// [periodStart, periodEnd) without saving it
+func (s *BillingService) CalculateBillingRecord(ctx context.Context, apiKeyID uuid.UUID, periodStart, periodEnd time.Time) (*models.BillingRecord, error) {
+	if !periodEnd.After(periodStart) {
+		return nil, fmt.Errorf("billing period end must be after its start")
+	}
+
+	apiKeys, err := s.apiKeyRepo.GetByIDsWithDeleted(ctx, []uuid.UUID{apiKeyID})
+	if err != nil {
+		return nil, err
+	}
+	if len(apiKeys) == 0 {
+		return nil, fmt.Errorf("api key not found")
+	}
+
+	plans, err := s.plansByTier(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.calculateRecord(ctx, apiKeys[0], plans, periodStart, periodEnd)
 }
 
-// GetBillingHistory retrieves billing history for an API key
-func (s *BillingService) GetBillingHistory(ctx context.Context, apiKeyID string, limit, offset int) ([]*models.BillingRecord, error) {
-	// TODO: Implement when billing repository interface is completed
-	return nil, fmt.Errorf("billing history retrieval not implemented yet")
+// ListPricingPlans retrieves the pricing plan of every tier
+func (s *BillingService) ListPricingPlans(ctx context.Context) ([]*models.PricingPlan, error) {
+	return s.pricingRepo.GetAll(ctx)
 }
 
-// GetCurrentBillingPeriod gets the current billing period usage
-func (s *BillingService) GetCurrentBillingPeriod(ctx context.Context, apiKeyID string) (*models.BillingRecord, error) {
-	// TODO: Implement when billing repository interface is completed
-	return nil, fmt.Errorf("current billing period retrieval not implemented yet")
+// SavePricingPlan validates a pricing plan and stores it as the plan of its
+// tier, replacing the plan the tier had
+func (s *BillingService) SavePricingPlan(ctx context.Context, plan *models.PricingPlan) (*models.PricingPlan, error) {
+	if !plan.Tier.IsValid() {
+		return nil, fmt.Errorf("invalid tier: %s", plan.Tier)
+	}
+	if plan.Currency == "" {
+		plan.Currency = "USD"
+	}
+	if plan.OverageMode == "" {
+		plan.OverageMode = models.OverageGraduated
+	}
+	if plan.OverageTiers == nil {
+		plan.OverageTiers = []models.PriceTier{}
+	}
+	if err := plan.Validate(); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.pricingRepo.GetByTier(ctx, plan.Tier)
+	if err != nil {
+		if err.Error() != "pricing plan not found" {
+			return nil, err
+		}
+		if err := s.pricingRepo.Create(ctx, plan); err != nil {
+			return nil, err
+		}
+		return plan, nil
+	}
+
+	plan.ID = existing.ID
+	plan.CreatedAt = existing.CreatedAt
+	plan.UpdatedAt = s.clock.Now()
+	if err := s.pricingRepo.Update(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// BillingHistory is a page of an API key's billing records with the amount
+// the key owes across all of its records
+type BillingHistory struct {
+	APIKeyID  uuid.UUID                     `json:"api_key_id"`
+	TotalOwed float64                       `json:"total_owed"`
+	Records   *repositories.PaginatedResult `json:"records"`
 }
 
-// UpdateBillingStatus updates the status of a billing record
-func (s *BillingService) UpdateBillingStatus(ctx context.Context, recordID string, status models.PaymentStatus) error {
-	// TODO: Implement when billing repository interface is completed
-	return fmt.Errorf("billing status update not implemented yet")
+// BillingSummary summarizes the revenue of the billing records within a range
+// and the API keys bringing in the most
+type BillingSummary struct {
+	PeriodStart *time.Time                      `json:"period_start,omitempty"`
+	PeriodEnd   *time.Time                      `json:"period_end,omitempty"`
+	Revenue     *repositories.RevenueSummary    `json:"revenue"`
+	TopAPIKeys  []*repositories.RevenueByAPIKey `json:"top_api_keys"`
 }
 
-// GetBillingSummary gets a summary of billing for all API keys
-func (s *BillingService) GetBillingSummary(ctx context.Context, periodStart, periodEnd time.Time) (map[string]interface{}, error) {
-	// TODO: Implement when billing repository interface is completed
-	return map[string]interface{}{
-		"message": "Billing summary not implemented yet",
-		"period_start": periodStart,
-		"period_end": periodEnd,
+// OverdueBilling lists the unpaid billing records overdue by at least a
+// number of days
+type OverdueBilling struct {
+	DaysOverdue  int                     `json:"days_overdue"`
+	TotalOverdue float64                 `json:"total_overdue"`
+	Records      []*models.BillingRecord `json:"records"`
+}
+
+// GetBillingRecord retrieves a billing record by ID with its API key, even a
+// deleted one
+func (s *BillingService) GetBillingRecord(ctx context.Context, recordID uuid.UUID) (*models.BillingRecord, error) {
+	record, err := s.billingRepo.GetByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachAPIKeys(ctx, []*models.BillingRecord{record}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// GetBillingHistory retrieves a page of an API key's billing records and the
+// total the key owes
+func (s *BillingService) GetBillingHistory(ctx context.Context, apiKeyID uuid.UUID, filter *repositories.BillingFilter, pagination *repositories.PaginationParams) (*BillingHistory, error) {
+	if filter == nil {
+		filter = &repositories.BillingFilter{}
+	}
+	filter.APIKeyID = &apiKeyID
+
+	records, err := s.billingRepo.List(ctx, filter, pagination)
+	if err != nil {
+		return nil, err
+	}
+
+	owed, err := s.billingRepo.CalculateTotalOwed(ctx, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BillingHistory{
+		APIKeyID:  apiKeyID,
+		TotalOwed: roundAmount(owed),
+		Records:   records,
 	}, nil
-}
\ No newline at end of file
+}
+
+// GetCurrentBillingPeriod estimates the billing record of an API key for the
+// current period from its usage so far. The estimate is not saved.
+func (s *BillingService) GetCurrentBillingPeriod(ctx context.Context, apiKeyID uuid.UUID) (*models.BillingRecord, error) {
+	periodStart, periodEnd := billingMonth(s.clock.Now())
+	return s.CalculateBillingRecord(ctx, apiKeyID, periodStart, periodEnd)
+}
+
+// GetBillingSummary summarizes the revenue of the billing records within
+// [periodStart, periodEnd] and lists the top API keys by revenue. A zero time
+// leaves that side of the range open.
+func (s *BillingService) GetBillingSummary(ctx context.Context, periodStart, periodEnd time.Time, topKeys int) (*BillingSummary, error) {
+	revenue, err := s.billingRepo.GetRevenueSummary(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	top, err := s.billingRepo.GetTopRevenue(ctx, periodStart, periodEnd, topKeys)
+	if err != nil {
+		return nil, err
+	}
+	if top == nil {
+		top = []*repositories.RevenueByAPIKey{}
+	}
+
+	summary := &BillingSummary{Revenue: revenue, TopAPIKeys: top}
+	if !periodStart.IsZero() {
+		summary.PeriodStart = &periodStart
+	}
+	if !periodEnd.IsZero() {
+		summary.PeriodEnd = &periodEnd
+	}
+	return summary, nil
+}
+
+// GetOverdueBilling lists the unpaid billing records whose period ended more
+// than daysOverdue days ago, marking pending ones overdue
+func (s *BillingService) GetOverdueBilling(ctx context.Context, daysOverdue int) (*OverdueBilling, error) {
+	records, err := s.billingRepo.GetOverdueRecords(ctx, daysOverdue)
+	if err != nil {
+		return nil, err
+	}
+
+	overdue := &OverdueBilling{
+		DaysOverdue: daysOverdue,
+		Records:     records,
+	}
+	if overdue.Records == nil {
+		overdue.Records = []*models.BillingRecord{}
+	}
+	for _, record := range records {
+		overdue.TotalOverdue += record.TotalAmount
+	}
+	overdue.TotalOverdue = roundAmount(overdue.TotalOverdue)
+	return overdue, nil
+}
+
+// GetPeriodRecords retrieves the billing records of every API key whose
+// period lies within [periodStart, periodEnd), with their API keys
+func (s *BillingService) GetPeriodRecords(ctx context.Context, periodStart, periodEnd time.Time) ([]*models.BillingRecord, error) {
+	if !periodEnd.After(periodStart) {
+		return nil, fmt.Errorf("billing period end must be after its start")
+	}
+
+	records, err := s.billingRepo.GetAllInPeriod(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.attachAPIKeys(ctx, records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// UpdateBillingStatus sets the payment status of a billing record by hand,
+// such as for an invoice paid by bank transfer. Marking a record paid stamps
+// the payment time; moving it back to unpaid clears it.
+func (s *BillingService) UpdateBillingStatus(ctx context.Context, recordID uuid.UUID, status models.PaymentStatus) (*models.BillingRecord, error) {
+	if !status.IsValid() {
+		return nil, fmt.Errorf("invalid payment status: %s", status)
+	}
+
+	record, err := s.billingRepo.GetByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.PaymentStatus == status {
+		return record, nil
+	}
+
+	previous := record.PaymentStatus
+	record.PaymentStatus = status
+	switch {
+	case status == models.PaymentStatusPaid:
+		now := s.clock.Now()
+		record.PaidAt = &now
+		record.PaymentFailureReason = ""
+	case status.IsUnpaid():
+		record.PaidAt = nil
+	}
+
+	updated, err := s.billingRepo.UpdatePayment(ctx, record, []models.PaymentStatus{previous})
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("billing record payment status changed concurrently")
+	}
+	return record, nil
+}
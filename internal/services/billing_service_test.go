@@ -0,0 +1,362 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+type fakePricingPlanRepository struct {
+	repositories.PricingPlanRepository
+	plans []*models.PricingPlan
+}
+
+func (r *fakePricingPlanRepository) GetAll(ctx context.Context) ([]*models.PricingPlan, error) {
+	return r.plans, nil
+}
+
+func (r *fakePricingPlanRepository) GetByTier(ctx context.Context, tier models.APIKeyTier) (*models.PricingPlan, error) {
+	for _, plan := range r.plans {
+		if plan.Tier == tier {
+			return plan, nil
+		}
+	}
+	return nil, fmt.Errorf("pricing plan not found")
+}
+
+func (r *fakePricingPlanRepository) Create(ctx context.Context, plan *models.PricingPlan) error {
+	plan.ID = uuid.New()
+	r.plans = append(r.plans, plan)
+	return nil
+}
+
+func (r *fakePricingPlanRepository) Update(ctx context.Context, plan *models.PricingPlan) error {
+	for i, existing := range r.plans {
+		if existing.ID == plan.ID {
+			r.plans[i] = plan
+			return nil
+		}
+	}
+	return fmt.Errorf("pricing plan not found")
+}
+
+// fakeBillingUsageRepository serves usage by API key and range start
+type fakeBillingUsageRepository struct {
+	repositories.UsageLogRepository
+	usage map[uuid.UUID]map[time.Time]repositories.UsageStats
+}
+
+func (r *fakeBillingUsageRepository) GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*repositories.UsageStats, error) {
+	stats := r.usage[apiKeyID][startTime]
+	return &stats, nil
+}
+
+func (r *fakeBillingUsageRepository) GetTopAPIKeys(ctx context.Context, startTime, endTime time.Time, limit int) ([]*repositories.APIKeyUsage, error) {
+	var usage []*repositories.APIKeyUsage
+	for apiKeyID := range r.usage {
+		usage = append(usage, &repositories.APIKeyUsage{APIKeyID: apiKeyID})
+	}
+	return usage, nil
+}
+
+func testBillingPlans() []*models.PricingPlan {
+	return []*models.PricingPlan{
+		{
+			Tier: models.APIKeyTierPro, Name: "Pro", Currency: "USD",
+			BaseFee: 30, IncludedRequests: 300, OverageMode: models.OverageGraduated,
+			OverageTiers: []models.PriceTier{{UpTo: 0, UnitPrice: 10}},
+		},
+		{
+			Tier: models.APIKeyTierEnterprise, Name: "Enterprise", Currency: "USD",
+			BaseFee: 90, IncludedRequests: 3000, OverageMode: models.OverageVolume,
+			OverageTiers: []models.PriceTier{{UpTo: 0, UnitPrice: 5}},
+		},
+	}
+}
+
+func TestBillingSegments(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	change := func(at time.Time, from, to models.APIKeyTier) *models.APIKeyTierChange {
+		return &models.APIKeyTierChange{FromTier: from, ToTier: to, ChangedAt: at}
+	}
+
+	segments := billingSegments(models.APIKeyTierFree, nil, start, end)
+	assert.Equal(t, []billingSegment{{Tier: models.APIKeyTierFree, Start: start, End: end}}, segments)
+
+	mid := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC)
+	segments = billingSegments(models.APIKeyTierFree, []*models.APIKeyTierChange{
+		change(start, models.APIKeyTierFree, models.APIKeyTierPro), // at the start: only sets the tier
+		change(mid, models.APIKeyTierPro, models.APIKeyTierEnterprise),
+		change(late, models.APIKeyTierEnterprise, models.APIKeyTierPro),
+		change(end, models.APIKeyTierPro, models.APIKeyTierFree), // after the period
+	}, start, end)
+	assert.Equal(t, []billingSegment{
+		{Tier: models.APIKeyTierPro, Start: start, End: mid},
+		{Tier: models.APIKeyTierEnterprise, Start: mid, End: late},
+		{Tier: models.APIKeyTierPro, Start: late, End: end},
+	}, segments)
+
+	assert.Empty(t, billingSegments(models.APIKeyTierFree, nil, end, start))
+}
+
+func TestGenerateBillingRecords_ProratesTierChange(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	changedAt := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC) // a third of the way through
+
+	key := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierEnterprise, CreatedAt: start.AddDate(0, -1, 0)}
+	apiKeyRepo := newFakeAPIKeyRepository(key)
+	apiKeyRepo.tiers[key.ID] = []*models.APIKeyTierChange{
+		{APIKeyID: key.ID, FromTier: models.APIKeyTierPro, ToTier: models.APIKeyTierEnterprise, ChangedAt: changedAt},
+	}
+	usageRepo := &fakeBillingUsageRepository{usage: map[uuid.UUID]map[time.Time]repositories.UsageStats{
+		key.ID: {
+			start:     {TotalRequests: 600, SuccessfulRequests: 550, FailedRequests: 50},
+			changedAt: {TotalRequests: 2000, SuccessfulRequests: 2000, TotalBandwidth: 4096},
+		},
+	}}
+	billingRepo := &fakeBillingRecordRepository{}
+	service := NewBillingService(billingRepo, &fakePricingPlanRepository{plans: testBillingPlans()}, apiKeyRepo, usageRepo,
+		ratelimit.NewFakeClock(end.Add(time.Hour)))
+
+	records, err := service.GenerateBillingRecords(context.Background(), start, end)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+
+	record := records[0]
+	assert.Equal(t, "pro", record.TierAtStart)
+	assert.Equal(t, "enterprise", record.TierAtEnd)
+	assert.Equal(t, models.BillingPeriodStatusCompleted, record.Status)
+	assert.Equal(t, models.PaymentStatusPending, record.PaymentStatus)
+	assert.Equal(t, "USD", record.Currency)
+	assert.Equal(t, int64(2600), record.TotalRequests)
+	assert.Equal(t, int64(2550), record.SuccessRequests)
+	assert.Equal(t, int64(4096), record.TotalBandwidth)
+
+	// Pro for a third: 10 base, 100 included, 500 overage at 10 per thousand.
+	// Enterprise for two thirds: 60 base, 2,000 included, no overage.
+	assert.Equal(t, int64(500), record.OverageRequests)
+	assert.InDelta(t, 70, record.BaseAmount, 1e-9)
+	assert.InDelta(t, 5, record.OverageAmount, 1e-9)
+	assert.InDelta(t, 75, record.TotalAmount, 1e-9)
+
+	require.Len(t, record.LineItems, 3)
+	assert.Equal(t, models.BillingLineItemBaseFee, record.LineItems[0].Kind)
+	assert.Equal(t, models.APIKeyTierPro, record.LineItems[0].Tier)
+	assert.InDelta(t, 0.3333, record.LineItems[0].Quantity, 1e-9)
+	assert.Equal(t, models.BillingLineItemOverage, record.LineItems[1].Kind)
+	assert.Equal(t, float64(500), record.LineItems[1].Quantity)
+	assert.Equal(t, models.APIKeyTierEnterprise, record.LineItems[2].Tier)
+	assert.Equal(t, changedAt, record.LineItems[2].PeriodStart)
+
+	var sum float64
+	for _, item := range record.LineItems {
+		sum += item.Amount
+	}
+	assert.InDelta(t, record.TotalAmount, sum, 1e-9)
+}
+
+func TestGenerateBillingRecords_KeyLifetime(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	half := time.Date(2025, 4, 16, 0, 0, 0, 0, time.UTC)
+
+	created := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: half}
+	future := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: end}
+	deleted := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: start.AddDate(0, -1, 0)}
+	deleted.DeletedAt.Time = half
+	deleted.DeletedAt.Valid = true
+
+	apiKeyRepo := newFakeAPIKeyRepository(created, future, deleted)
+	usageRepo := &fakeBillingUsageRepository{usage: map[uuid.UUID]map[time.Time]repositories.UsageStats{
+		deleted.ID: {start: {TotalRequests: 10}},
+	}}
+	service := NewBillingService(&fakeBillingRecordRepository{}, &fakePricingPlanRepository{plans: testBillingPlans()}, apiKeyRepo, usageRepo,
+		ratelimit.NewFakeClock(half))
+
+	records, err := service.GenerateBillingRecords(context.Background(), start, end)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	byKey := map[uuid.UUID]*models.BillingRecord{}
+	for _, record := range records {
+		byKey[record.APIKeyID] = record
+	}
+	assert.NotContains(t, byKey, future.ID)
+
+	// Created halfway through: half the base fee, and the period is still open
+	require.Contains(t, byKey, created.ID)
+	assert.InDelta(t, 15, byKey[created.ID].TotalAmount, 1e-9)
+	assert.Equal(t, models.BillingPeriodStatusActive, byKey[created.ID].Status)
+
+	// Deleted halfway through, but billed for its usage until then
+	require.Contains(t, byKey, deleted.ID)
+	assert.InDelta(t, 15, byKey[deleted.ID].BaseAmount, 1e-9)
+	assert.Equal(t, int64(10), byKey[deleted.ID].TotalRequests)
+}
+
+func TestGenerateBillingRecords_StatusChanges(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+	third := time.Date(2025, 4, 11, 0, 0, 0, 0, time.UTC)
+	twoThirds := time.Date(2025, 4, 21, 0, 0, 0, 0, time.UTC)
+
+	revoked := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusRevoked, Tier: models.APIKeyTierPro, CreatedAt: start.AddDate(0, -1, 0)}
+	suspended := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: start.AddDate(0, -1, 0)}
+	apiKeyRepo := newFakeAPIKeyRepository(revoked, suspended)
+	apiKeyRepo.statuses[revoked.ID] = []*models.APIKeyStatusChange{
+		{FromStatus: models.APIKeyStatusActive, ToStatus: models.APIKeyStatusRevoked, ChangedAt: third},
+	}
+	apiKeyRepo.statuses[suspended.ID] = []*models.APIKeyStatusChange{
+		{FromStatus: models.APIKeyStatusActive, ToStatus: models.APIKeyStatusSuspended, ChangedAt: third},
+		{FromStatus: models.APIKeyStatusSuspended, ToStatus: models.APIKeyStatusActive, ChangedAt: twoThirds},
+	}
+	service := NewBillingService(&fakeBillingRecordRepository{}, &fakePricingPlanRepository{plans: testBillingPlans()}, apiKeyRepo,
+		&fakeBillingUsageRepository{}, ratelimit.NewFakeClock(end))
+
+	records, err := service.GenerateBillingRecords(context.Background(), start, end)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	byKey := map[uuid.UUID]*models.BillingRecord{}
+	for _, record := range records {
+		byKey[record.APIKeyID] = record
+	}
+
+	// Revoked a third of the way through without usage: a third of the base fee
+	require.Contains(t, byKey, revoked.ID)
+	assert.InDelta(t, 10, byKey[revoked.ID].TotalAmount, 1e-9)
+
+	// Suspended for the middle third: billed for the other two
+	require.Contains(t, byKey, suspended.ID)
+	assert.InDelta(t, 20, byKey[suspended.ID].TotalAmount, 1e-9)
+	assert.Len(t, byKey[suspended.ID].LineItems, 2)
+}
+
+func TestGenerateBillingRecords_Regenerate(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	pending := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: start}
+	paid := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: start}
+	unpriced := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierFree, CreatedAt: start}
+
+	paidAt := end.Add(time.Hour)
+	billingRepo := &fakeBillingRecordRepository{records: []*models.BillingRecord{
+		{ID: uuid.New(), APIKeyID: pending.ID, PeriodStart: start, PeriodEnd: end, TotalAmount: 1, PaymentStatus: models.PaymentStatusOverdue},
+		{ID: uuid.New(), APIKeyID: paid.ID, PeriodStart: start, PeriodEnd: end, TotalAmount: 1, PaymentStatus: models.PaymentStatusPaid, PaidAt: &paidAt},
+	}}
+	service := NewBillingService(billingRepo, &fakePricingPlanRepository{plans: testBillingPlans()},
+		newFakeAPIKeyRepository(pending, paid, unpriced),
+		&fakeBillingUsageRepository{}, ratelimit.NewFakeClock(end.Add(24*time.Hour)))
+
+	records, err := service.GenerateBillingRecords(context.Background(), start, end)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no pricing plan for tier free")
+	require.Len(t, records, 2)
+
+	// The unpaid record is recalculated in place and keeps its payment state
+	require.Len(t, billingRepo.records, 2)
+	assert.Equal(t, 1, billingRepo.updates)
+	assert.InDelta(t, 30, billingRepo.records[0].TotalAmount, 1e-9)
+	assert.Equal(t, models.PaymentStatusOverdue, billingRepo.records[0].PaymentStatus)
+
+	// The paid record is left alone
+	assert.InDelta(t, 1, billingRepo.records[1].TotalAmount, 1e-9)
+}
+
+func TestSavePricingPlan(t *testing.T) {
+	pricingRepo := &fakePricingPlanRepository{plans: testBillingPlans()}
+	pricingRepo.plans[0].ID = uuid.New()
+	service := NewBillingService(&fakeBillingRecordRepository{}, pricingRepo, newFakeAPIKeyRepository(),
+		&fakeBillingUsageRepository{}, ratelimit.NewFakeClock(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)))
+	ctx := context.Background()
+
+	// Replaces the plan of the tier
+	plan, err := service.SavePricingPlan(ctx, &models.PricingPlan{Tier: models.APIKeyTierPro, Name: "Pro", BaseFee: 39})
+	require.NoError(t, err)
+	assert.Equal(t, pricingRepo.plans[0].ID, plan.ID)
+	assert.Equal(t, "USD", plan.Currency)
+	assert.Equal(t, models.OverageGraduated, plan.OverageMode)
+	assert.Len(t, pricingRepo.plans, 2)
+
+	// Creates the plan of a tier without one
+	_, err = service.SavePricingPlan(ctx, &models.PricingPlan{Tier: models.APIKeyTierFree, Name: "Free"})
+	require.NoError(t, err)
+	assert.Len(t, pricingRepo.plans, 3)
+
+	_, err = service.SavePricingPlan(ctx, &models.PricingPlan{Tier: models.APIKeyTierAll, Name: "All"})
+	assert.Error(t, err)
+	_, err = service.SavePricingPlan(ctx, &models.PricingPlan{Tier: models.APIKeyTierPro, Name: "Pro", BaseFee: -1})
+	assert.Error(t, err)
+}
+
+func TestGetCurrentBillingPeriod(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	key := &models.APIKey{ID: uuid.New(), Status: models.APIKeyStatusActive, Tier: models.APIKeyTierPro, CreatedAt: start.AddDate(0, -1, 0)}
+	usageRepo := &fakeBillingUsageRepository{usage: map[uuid.UUID]map[time.Time]repositories.UsageStats{
+		key.ID: {start: {TotalRequests: 500}},
+	}}
+	billingRepo := &fakeBillingRecordRepository{}
+	service := NewBillingService(billingRepo, &fakePricingPlanRepository{plans: testBillingPlans()},
+		newFakeAPIKeyRepository(key), usageRepo,
+		ratelimit.NewFakeClock(time.Date(2025, 4, 20, 15, 0, 0, 0, time.UTC)))
+
+	// The whole month's base fee and the usage so far, unsaved
+	record, err := service.GetCurrentBillingPeriod(context.Background(), key.ID)
+	require.NoError(t, err)
+	assert.Equal(t, start, record.PeriodStart)
+	assert.Equal(t, time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC), record.PeriodEnd)
+	assert.Equal(t, models.BillingPeriodStatusActive, record.Status)
+	assert.InDelta(t, 32, record.TotalAmount, 1e-9)
+	assert.Empty(t, billingRepo.records)
+
+	_, err = service.GetCurrentBillingPeriod(context.Background(), uuid.New())
+	assert.EqualError(t, err, "api key not found")
+}
+
+func TestGetBillingRecords_AttachAPIKeys(t *testing.T) {
+	start := time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	active := &models.APIKey{ID: uuid.New(), Name: "active"}
+	deleted := &models.APIKey{ID: uuid.New(), Name: "deleted"}
+	deleted.DeletedAt.Time, deleted.DeletedAt.Valid = start, true
+
+	billingRepo := &fakeBillingRecordRepository{records: []*models.BillingRecord{
+		{ID: uuid.New(), APIKeyID: active.ID, PeriodStart: start, PeriodEnd: end},
+		{ID: uuid.New(), APIKeyID: deleted.ID, PeriodStart: start, PeriodEnd: end},
+		{ID: uuid.New(), APIKeyID: active.ID, PeriodStart: end, PeriodEnd: end.AddDate(0, 1, 0)},
+	}}
+	service := NewBillingService(billingRepo, &fakePricingPlanRepository{},
+		newFakeAPIKeyRepository(active, deleted),
+		&fakeBillingUsageRepository{}, ratelimit.NewFakeClock(end))
+	ctx := context.Background()
+
+	record, err := service.GetBillingRecord(ctx, billingRepo.records[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, "deleted", record.APIKey.Name)
+
+	_, err = service.GetBillingRecord(ctx, uuid.New())
+	assert.EqualError(t, err, "billing record not found")
+
+	records, err := service.GetPeriodRecords(ctx, start, end)
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "active", records[0].APIKey.Name)
+	assert.Equal(t, "deleted", records[1].APIKey.Name)
+
+	_, err = service.GetPeriodRecords(ctx, end, start)
+	assert.Error(t, err)
+}
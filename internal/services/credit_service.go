@@ -0,0 +1,440 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// ErrCreditsDisabled is returned when prepaid credits are not enabled
+var ErrCreditsDisabled = errors.New("prepaid credits are not enabled")
+
+const (
+	// creditExpiryBatchSize bounds the top-ups expired by one run
+	creditExpiryBatchSize = 500
+
+	// creditOwnerRefreshInterval bounds how long a new account can go
+	// uncharged on instances that did not create it
+	creditOwnerRefreshInterval = 30 * time.Second
+)
+
+// CreditPolicy configures prepaid credits. Each request costs RequestCost
+// credits, or the cost of its key's tier in TierRequestCosts. Billing overage
+// alerts are raised as a balance falls to each of AlertThresholds, unless the
+// account sets its own.
+type CreditPolicy struct {
+	Enabled          bool
+	RequestCost      float64
+	TierRequestCosts map[string]float64
+	AlertThresholds  []float64
+}
+
+// requestCost returns the cost of one request of a key on tier
+func (p CreditPolicy) requestCost(tier models.APIKeyTier) float64 {
+	if cost, ok := p.TierRequestCosts[string(tier)]; ok {
+		return cost
+	}
+	return p.RequestCost
+}
+
+// CreditCharge is the outcome of charging a request to a credit account
+type CreditCharge struct {
+	AccountID       uuid.UUID `json:"account_id"`
+	Cost            float64   `json:"cost"`
+	Balance         float64   `json:"balance"`
+	Allowed         bool      `json:"allowed"`
+	SpendCapReached bool      `json:"spend_cap_reached"`
+}
+
+// CreateCreditAccountRequest contains data for opening a credit account for
+// an API key or a team
+type CreateCreditAccountRequest struct {
+	APIKeyID        *uuid.UUID `json:"api_key_id"`
+	TeamID          *uuid.UUID `json:"team_id"`
+	MonthlySpendCap *float64   `json:"monthly_spend_cap"`
+	AlertThresholds []float64  `json:"alert_thresholds"`
+}
+
+// UpdateCreditAccountRequest replaces the spend cap and alert thresholds of a
+// credit account. A nil cap removes it; no thresholds use the configured ones.
+type UpdateCreditAccountRequest struct {
+	MonthlySpendCap *float64  `json:"monthly_spend_cap"`
+	AlertThresholds []float64 `json:"alert_thresholds"`
+}
+
+// TopUpCreditsRequest contains data for adding purchased credits to an
+// account. Reference identifies the purchase, so retrying a top-up does not
+// add it twice.
+type TopUpCreditsRequest struct {
+	Amount      float64    `json:"amount" binding:"required,gt=0"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	Reference   string     `json:"reference" binding:"max=255"`
+	Description string     `json:"description" binding:"max=500"`
+}
+
+// CreditExpiryResult reports a run expiring top-ups
+type CreditExpiryResult struct {
+	Expired int     `json:"expired"`
+	Amount  float64 `json:"amount"`
+}
+
+// CreditService manages prepaid credit accounts and charges requests to them
+type CreditService struct {
+	creditRepo   repositories.CreditRepository
+	apiKeyRepo   repositories.APIKeyRepository
+	alertService AlertService
+	policy       CreditPolicy
+	clock        ratelimit.Clock
+
+	// owners is an in-memory snapshot of the API keys and teams with an
+	// account, so requests of keys without one are not charged a database
+	// lookup. It is reloaded every creditOwnerRefreshInterval and after every
+	// local account creation.
+	mu             sync.RWMutex
+	owners         map[uuid.UUID]bool
+	ownersLoadedAt time.Time
+	ownersLoaded   bool
+}
+
+// NewCreditService creates a new credit service. alertService may be nil to
+// disable balance alerts.
+func NewCreditService(
+	creditRepo repositories.CreditRepository,
+	apiKeyRepo repositories.APIKeyRepository,
+	alertService AlertService,
+	policy CreditPolicy,
+	clock ratelimit.Clock,
+) *CreditService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &CreditService{
+		creditRepo:   creditRepo,
+		apiKeyRepo:   apiKeyRepo,
+		alertService: alertService,
+		policy:       policy,
+		clock:        clock,
+	}
+}
+
+// CreateAccount opens a credit account with a zero balance for an API key or
+// a team
+func (s *CreditService) CreateAccount(ctx context.Context, req *CreateCreditAccountRequest) (*models.CreditAccount, error) {
+	if !s.policy.Enabled {
+		return nil, ErrCreditsDisabled
+	}
+	if (req.APIKeyID == nil) == (req.TeamID == nil) {
+		return nil, fmt.Errorf("exactly one of api_key_id and team_id is required")
+	}
+	if err := validateCreditSettings(req.MonthlySpendCap, req.AlertThresholds); err != nil {
+		return nil, err
+	}
+	if req.APIKeyID != nil {
+		if _, err := s.apiKeyRepo.GetByID(ctx, *req.APIKeyID); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.creditRepo.GetAccountForOwner(ctx, req.APIKeyID, req.TeamID); err == nil {
+		return nil, fmt.Errorf("credit account already exists")
+	} else if err.Error() != "credit account not found" {
+		return nil, err
+	}
+
+	month, _ := billingMonth(s.clock.Now())
+	account := &models.CreditAccount{
+		APIKeyID:        req.APIKeyID,
+		TeamID:          req.TeamID,
+		MonthlySpendCap: req.MonthlySpendCap,
+		PeriodStart:     month,
+		AlertThresholds: sortedThresholds(req.AlertThresholds),
+	}
+	if err := s.creditRepo.CreateAccount(ctx, account); err != nil {
+		return nil, err
+	}
+
+	// Charge the new account's requests from now on
+	s.mu.Lock()
+	s.ownersLoaded = false
+	s.mu.Unlock()
+	return account, nil
+}
+
+// GetAccount retrieves a credit account
+func (s *CreditService) GetAccount(ctx context.Context, id uuid.UUID) (*models.CreditAccount, error) {
+	return s.creditRepo.GetAccount(ctx, id)
+}
+
+// GetAccountForKey retrieves the account an API key's requests are charged
+// to: its own, or else its team's
+func (s *CreditService) GetAccountForKey(ctx context.Context, apiKeyID uuid.UUID) (*models.CreditAccount, error) {
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, apiKeyID)
+	if err != nil {
+		return nil, err
+	}
+	return s.creditRepo.GetAccountForOwner(ctx, &apiKey.ID, apiKey.TeamID)
+}
+
+// UpdateAccount replaces the spend cap and alert thresholds of an account
+func (s *CreditService) UpdateAccount(ctx context.Context, id uuid.UUID, req *UpdateCreditAccountRequest) (*models.CreditAccount, error) {
+	if err := validateCreditSettings(req.MonthlySpendCap, req.AlertThresholds); err != nil {
+		return nil, err
+	}
+
+	account, err := s.creditRepo.GetAccount(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	account.MonthlySpendCap = req.MonthlySpendCap
+	account.AlertThresholds = sortedThresholds(req.AlertThresholds)
+	account.UpdatedAt = s.clock.Now()
+	if err := s.creditRepo.UpdateAccountSettings(ctx, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// TopUp adds purchased credits to an account. It returns the account and
+// whether the top-up was applied, which it is not when its reference was
+// already used.
+func (s *CreditService) TopUp(ctx context.Context, accountID uuid.UUID, req *TopUpCreditsRequest) (*models.CreditAccount, bool, error) {
+	if !s.policy.Enabled {
+		return nil, false, ErrCreditsDisabled
+	}
+	if req.Amount <= 0 {
+		return nil, false, fmt.Errorf("amount must be positive")
+	}
+	now := s.clock.Now()
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(now) {
+		return nil, false, fmt.Errorf("expires_at must be in the future")
+	}
+
+	return s.creditRepo.TopUp(ctx, &models.CreditLedgerEntry{
+		AccountID:   accountID,
+		Type:        models.CreditEntryTopUp,
+		Amount:      math.Round(req.Amount*1e6) / 1e6,
+		Description: req.Description,
+		Reference:   req.Reference,
+		ExpiresAt:   req.ExpiresAt,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+}
+
+// GetLedger retrieves the ledger entries of an account, newest first
+func (s *CreditService) GetLedger(ctx context.Context, accountID uuid.UUID, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	if _, err := s.creditRepo.GetAccount(ctx, accountID); err != nil {
+		return nil, err
+	}
+	return s.creditRepo.GetEntries(ctx, accountID, pagination)
+}
+
+// Charge debits the cost of one request from the credit account of an API
+// key, or of its team. It returns nil when credits are disabled or the key
+// has no account, in which case the request is not limited by credits. A
+// request the balance or the monthly spend cap cannot cover is not charged
+// and not allowed.
+func (s *CreditService) Charge(ctx context.Context, apiKey *models.APIKey, now time.Time) (*CreditCharge, error) {
+	if s == nil || !s.policy.Enabled {
+		return nil, nil
+	}
+
+	owners, err := s.accountOwners(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	if !owners[apiKey.ID] && (apiKey.TeamID == nil || !owners[*apiKey.TeamID]) {
+		return nil, nil
+	}
+
+	account, err := s.creditRepo.GetAccountForOwner(ctx, &apiKey.ID, apiKey.TeamID)
+	if err != nil {
+		if err.Error() == "credit account not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	cost := s.policy.requestCost(apiKey.Tier)
+	month, _ := billingMonth(now)
+	debit, err := s.creditRepo.Debit(ctx, account.ID, cost, now.UTC().Truncate(time.Hour), month, now)
+	if err != nil {
+		return nil, err
+	}
+
+	charge := &CreditCharge{
+		AccountID: account.ID,
+		Cost:      cost,
+		Balance:   debit.Balance,
+		Allowed:   debit.Charged,
+	}
+	if !debit.Charged {
+		charge.SpendCapReached = debit.SpendCapReached
+		s.alertRejected(ctx, apiKey, account, charge)
+		return charge, nil
+	}
+
+	s.alertThresholds(ctx, apiKey, account, debit.Balance+cost, debit.Balance)
+	return charge, nil
+}
+
+// accountOwners returns the IDs of the API keys and teams with an account.
+// A snapshot that cannot be refreshed keeps being used.
+func (s *CreditService) accountOwners(ctx context.Context, now time.Time) (map[uuid.UUID]bool, error) {
+	s.mu.RLock()
+	owners, loaded, loadedAt := s.owners, s.ownersLoaded, s.ownersLoadedAt
+	s.mu.RUnlock()
+
+	if loaded && now.Sub(loadedAt) < creditOwnerRefreshInterval {
+		return owners, nil
+	}
+
+	ids, err := s.creditRepo.ListAccountOwners(ctx)
+	if err != nil {
+		if owners != nil {
+			return owners, nil
+		}
+		return nil, fmt.Errorf("failed to load credit account owners: %w", err)
+	}
+
+	fresh := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		fresh[id] = true
+	}
+
+	s.mu.Lock()
+	s.owners = fresh
+	s.ownersLoadedAt = now
+	s.ownersLoaded = true
+	s.mu.Unlock()
+
+	return fresh, nil
+}
+
+// ExpireCredits removes the unspent part of every top-up past its expiry
+func (s *CreditService) ExpireCredits(ctx context.Context) (*CreditExpiryResult, error) {
+	result := &CreditExpiryResult{}
+	if !s.policy.Enabled {
+		return result, nil
+	}
+
+	now := s.clock.Now()
+	topUps, err := s.creditRepo.GetExpiringTopUps(ctx, now, creditExpiryBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, topUp := range topUps {
+		expiry, err := s.creditRepo.ExpireTopUp(ctx, topUp.ID, now)
+		if err != nil {
+			return result, err
+		}
+		if expiry == nil {
+			continue
+		}
+		result.Expired++
+		result.Amount = math.Round((result.Amount-expiry.Amount)*1e6) / 1e6
+	}
+	return result, nil
+}
+
+// alertThresholds raises a billing overage alert for every threshold a debit
+// took the balance from above to at or below
+func (s *CreditService) alertThresholds(ctx context.Context, apiKey *models.APIKey, account *models.CreditAccount, before, after float64) {
+	if s.alertService == nil {
+		return
+	}
+
+	thresholds := account.AlertThresholds
+	if len(thresholds) == 0 {
+		thresholds = s.policy.AlertThresholds
+	}
+	for _, threshold := range thresholds {
+		if before <= threshold || after > threshold {
+			continue
+		}
+
+		severity := models.AlertSeverityMedium
+		if threshold <= 0 {
+			severity = models.AlertSeverityHigh
+		}
+		s.raiseAlert(ctx, apiKey, account, &CreateAlertRequest{
+			Severity: severity,
+			Message:  fmt.Sprintf("Prepaid credit balance fell to %.2f, at or below the alert threshold of %.2f", after, threshold),
+			DedupKey: fmt.Sprintf("credits:%s:%g", account.ID, threshold),
+			Metadata: map[string]interface{}{
+				"threshold": threshold,
+				"balance":   after,
+			},
+		})
+	}
+}
+
+// alertRejected raises a critical billing overage alert for a request the
+// account could not pay for. Repeated rejections update the same alert.
+func (s *CreditService) alertRejected(ctx context.Context, apiKey *models.APIKey, account *models.CreditAccount, charge *CreditCharge) {
+	if s.alertService == nil {
+		return
+	}
+
+	message := fmt.Sprintf("Prepaid credits exhausted: requests are rejected until the account is topped up (balance %.2f)", charge.Balance)
+	reason := "exhausted"
+	if charge.SpendCapReached {
+		message = fmt.Sprintf("Monthly spend cap of %.2f reached: requests are rejected until next month or until the cap is raised", *account.MonthlySpendCap)
+		reason = "spend_cap"
+	}
+	s.raiseAlert(ctx, apiKey, account, &CreateAlertRequest{
+		Severity: models.AlertSeverityCritical,
+		Message:  message,
+		DedupKey: fmt.Sprintf("credits:%s:%s", account.ID, reason),
+		Metadata: map[string]interface{}{
+			"reason":  reason,
+			"balance": charge.Balance,
+		},
+	})
+}
+
+// raiseAlert creates a billing overage alert for the key whose request
+// triggered it. Failures are logged rather than failing the request.
+func (s *CreditService) raiseAlert(ctx context.Context, apiKey *models.APIKey, account *models.CreditAccount, req *CreateAlertRequest) {
+	req.APIKeyID = apiKey.ID
+	req.Type = models.AlertTypeBillingOverage
+	req.Metadata["credit_account_id"] = account.ID.String()
+	if account.TeamID != nil {
+		req.Metadata["team_id"] = account.TeamID.String()
+	}
+
+	if _, err := s.alertService.CreateAlert(ctx, req); err != nil {
+		fmt.Printf("Failed to raise credit alert: %v\n", err)
+	}
+}
+
+// validateCreditSettings checks a spend cap and alert thresholds
+func validateCreditSettings(spendCap *float64, thresholds []float64) error {
+	if spendCap != nil && *spendCap < 0 {
+		return fmt.Errorf("monthly_spend_cap must not be negative")
+	}
+	for _, threshold := range thresholds {
+		if threshold < 0 {
+			return fmt.Errorf("alert thresholds must not be negative")
+		}
+	}
+	return nil
+}
+
+// sortedThresholds returns a copy of thresholds, highest first
+func sortedThresholds(thresholds []float64) []float64 {
+	sorted := append([]float64{}, thresholds...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+	return sorted
+}
@@ -0,0 +1,507 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeCreditRepository keeps credit accounts and their ledger in memory
+type fakeCreditRepository struct {
+	accounts map[uuid.UUID]*models.CreditAccount
+	entries  []*models.CreditLedgerEntry
+
+	// ownerLookups counts GetAccountForOwner calls; err, when set, fails
+	// the calls made while charging a request
+	ownerLookups int
+	err          error
+}
+
+func newFakeCreditRepository() *fakeCreditRepository {
+	return &fakeCreditRepository{accounts: make(map[uuid.UUID]*models.CreditAccount)}
+}
+
+func (r *fakeCreditRepository) CreateAccount(ctx context.Context, account *models.CreditAccount) error {
+	account.BeforeCreate(nil)
+	r.accounts[account.ID] = account
+	return nil
+}
+
+func (r *fakeCreditRepository) GetAccount(ctx context.Context, id uuid.UUID) (*models.CreditAccount, error) {
+	account, ok := r.accounts[id]
+	if !ok {
+		return nil, fmt.Errorf("credit account not found")
+	}
+	copied := *account
+	return &copied, nil
+}
+
+func (r *fakeCreditRepository) ListAccountOwners(ctx context.Context) ([]uuid.UUID, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	var owners []uuid.UUID
+	for _, account := range r.accounts {
+		if account.APIKeyID != nil {
+			owners = append(owners, *account.APIKeyID)
+		}
+		if account.TeamID != nil {
+			owners = append(owners, *account.TeamID)
+		}
+	}
+	return owners, nil
+}
+
+func (r *fakeCreditRepository) GetAccountForOwner(ctx context.Context, apiKeyID, teamID *uuid.UUID) (*models.CreditAccount, error) {
+	r.ownerLookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	var teamAccount *models.CreditAccount
+	for _, account := range r.accounts {
+		if apiKeyID != nil && account.APIKeyID != nil && *account.APIKeyID == *apiKeyID {
+			return r.GetAccount(ctx, account.ID)
+		}
+		if teamID != nil && account.TeamID != nil && *account.TeamID == *teamID {
+			teamAccount = account
+		}
+	}
+	if teamAccount == nil {
+		return nil, fmt.Errorf("credit account not found")
+	}
+	return r.GetAccount(ctx, teamAccount.ID)
+}
+
+func (r *fakeCreditRepository) UpdateAccountSettings(ctx context.Context, account *models.CreditAccount) error {
+	stored, ok := r.accounts[account.ID]
+	if !ok {
+		return fmt.Errorf("credit account not found")
+	}
+	stored.MonthlySpendCap = account.MonthlySpendCap
+	stored.AlertThresholds = account.AlertThresholds
+	return nil
+}
+
+func (r *fakeCreditRepository) TopUp(ctx context.Context, entry *models.CreditLedgerEntry) (*models.CreditAccount, bool, error) {
+	account, ok := r.accounts[entry.AccountID]
+	if !ok {
+		return nil, false, fmt.Errorf("credit account not found")
+	}
+	for _, existing := range r.entries {
+		if entry.Reference != "" && existing.AccountID == entry.AccountID &&
+			existing.Type == models.CreditEntryTopUp && existing.Reference == entry.Reference {
+			copied := *account
+			return &copied, false, nil
+		}
+	}
+
+	entry.BeforeCreate(nil)
+	r.entries = append(r.entries, entry)
+	account.Balance += entry.Amount
+	copied := *account
+	return &copied, true, nil
+}
+
+func (r *fakeCreditRepository) Debit(ctx context.Context, accountID uuid.UUID, amount float64, hour, month, now time.Time) (*repositories.CreditDebit, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	account, ok := r.accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("credit account not found")
+	}
+
+	spend := account.PeriodSpend
+	if !account.PeriodStart.Equal(month) {
+		spend = 0
+	}
+	if account.Balance < amount {
+		return &repositories.CreditDebit{Balance: account.Balance, PeriodSpend: spend, CreditsExhausted: true}, nil
+	}
+	if account.MonthlySpendCap != nil && spend+amount > *account.MonthlySpendCap {
+		return &repositories.CreditDebit{Balance: account.Balance, PeriodSpend: spend, SpendCapReached: true}, nil
+	}
+
+	account.Balance = math.Round((account.Balance-amount)*1e6) / 1e6
+	account.PeriodSpend = spend + amount
+	account.PeriodStart = month
+
+	for _, entry := range r.entries {
+		if entry.AccountID == accountID && entry.Type == models.CreditEntryDebit && entry.PeriodStart.Equal(hour) {
+			entry.Amount -= amount
+			entry.Requests++
+			entry.UpdatedAt = now
+			return &repositories.CreditDebit{Charged: true, Balance: account.Balance, PeriodSpend: account.PeriodSpend}, nil
+		}
+	}
+	r.entries = append(r.entries, &models.CreditLedgerEntry{
+		ID:          uuid.New(),
+		AccountID:   accountID,
+		Type:        models.CreditEntryDebit,
+		Amount:      -amount,
+		PeriodStart: &hour,
+		Requests:    1,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	})
+	return &repositories.CreditDebit{Charged: true, Balance: account.Balance, PeriodSpend: account.PeriodSpend}, nil
+}
+
+func (r *fakeCreditRepository) GetEntries(ctx context.Context, accountID uuid.UUID, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	var entries []*models.CreditLedgerEntry
+	for _, entry := range r.entries {
+		if entry.AccountID == accountID {
+			entries = append(entries, entry)
+		}
+	}
+	return repositories.NewPaginatedResult(entries, int64(len(entries)), pagination), nil
+}
+
+func (r *fakeCreditRepository) GetExpiringTopUps(ctx context.Context, before time.Time, limit int) ([]*models.CreditLedgerEntry, error) {
+	var entries []*models.CreditLedgerEntry
+	for _, entry := range r.entries {
+		if entry.Type == models.CreditEntryTopUp && entry.ExpiresAt != nil && !entry.ExpiresAt.After(before) && entry.ExpiredAt == nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+func (r *fakeCreditRepository) ExpireTopUp(ctx context.Context, topUpID uuid.UUID, now time.Time) (*models.CreditLedgerEntry, error) {
+	var topUp *models.CreditLedgerEntry
+	for _, entry := range r.entries {
+		if entry.ID == topUpID {
+			topUp = entry
+		}
+	}
+	if topUp.ExpiredAt != nil {
+		return nil, nil
+	}
+
+	var later float64
+	for _, entry := range r.entries {
+		if entry.AccountID == topUp.AccountID && entry.Type == models.CreditEntryTopUp && entry.ID != topUp.ID &&
+			entry.ExpiredAt == nil && (entry.ExpiresAt == nil || entry.ExpiresAt.After(*topUp.ExpiresAt)) {
+			later += entry.Amount
+		}
+	}
+	account := r.accounts[topUp.AccountID]
+	unspent := math.Min(topUp.Amount, math.Max(0, account.Balance-later))
+
+	expiry := &models.CreditLedgerEntry{
+		ID:        uuid.New(),
+		AccountID: topUp.AccountID,
+		Type:      models.CreditEntryExpiry,
+		Amount:    -unspent,
+		TopUpID:   &topUp.ID,
+	}
+	r.entries = append(r.entries, expiry)
+	account.Balance -= unspent
+	topUp.ExpiredAt = &now
+	return expiry, nil
+}
+
+// recordingAlertService records the alerts created through it, deduplicated
+// by key like the real service
+type recordingAlertService struct {
+	AlertService
+	alerts []*CreateAlertRequest
+}
+
+func (s *recordingAlertService) CreateAlert(ctx context.Context, req *CreateAlertRequest) (*AlertResponse, error) {
+	for _, alert := range s.alerts {
+		if alert.DedupKey == req.DedupKey {
+			return &AlertResponse{}, nil
+		}
+	}
+	s.alerts = append(s.alerts, req)
+	return &AlertResponse{}, nil
+}
+
+type creditFixture struct {
+	service    *CreditService
+	creditRepo *fakeCreditRepository
+	apiKeyRepo *fakeAPIKeyRepository
+	alerts     *recordingAlertService
+	clock      *ratelimit.FakeClock
+	apiKey     *models.APIKey
+	teamKey    *models.APIKey
+	teamID     uuid.UUID
+}
+
+func newCreditFixture(t *testing.T, policy CreditPolicy) *creditFixture {
+	t.Helper()
+
+	teamID := uuid.New()
+	f := &creditFixture{
+		creditRepo: newFakeCreditRepository(),
+		alerts:     &recordingAlertService{},
+		clock:      ratelimit.NewFakeClock(time.Date(2026, 3, 14, 10, 30, 0, 0, time.UTC)),
+		apiKey:     &models.APIKey{ID: uuid.New(), Tier: models.APIKeyTierFree, Status: models.APIKeyStatusActive, RateLimit: 10},
+		teamKey:    &models.APIKey{ID: uuid.New(), Tier: models.APIKeyTierPro, Status: models.APIKeyStatusActive, TeamID: &teamID},
+		teamID:     teamID,
+	}
+	f.apiKeyRepo = newFakeAPIKeyRepository(f.apiKey, f.teamKey)
+	f.service = NewCreditService(f.creditRepo, f.apiKeyRepo, f.alerts, policy, f.clock)
+	return f
+}
+
+// openAccount opens a credit account holding amount for the given owner
+func (f *creditFixture) openAccount(t *testing.T, apiKeyID, teamID *uuid.UUID, amount float64) *models.CreditAccount {
+	t.Helper()
+	account, err := f.service.CreateAccount(context.Background(), &CreateCreditAccountRequest{APIKeyID: apiKeyID, TeamID: teamID})
+	require.NoError(t, err)
+	if amount > 0 {
+		f.topUp(t, account.ID, amount, nil)
+	}
+	return account
+}
+
+func (f *creditFixture) topUp(t *testing.T, accountID uuid.UUID, amount float64, expiresAt *time.Time) {
+	t.Helper()
+	_, applied, err := f.service.TopUp(context.Background(), accountID, &TopUpCreditsRequest{Amount: amount, ExpiresAt: expiresAt})
+	require.NoError(t, err)
+	require.True(t, applied)
+}
+
+// rateLimiter returns a rate limit service charging the fixture's credits
+func (f *creditFixture) rateLimiter(cache *fakeCacheService) *rateLimitService {
+	return &rateLimitService{
+		apiKeyRepo:   f.apiKeyRepo,
+		cacheService: cache,
+		credits:      f.service,
+		windowSize:   time.Hour,
+		clock:        f.clock,
+	}
+}
+
+func testCreditPolicy() CreditPolicy {
+	return CreditPolicy{
+		Enabled:          true,
+		RequestCost:      1,
+		TierRequestCosts: map[string]float64{"pro": 0.5},
+		AlertThresholds:  []float64{5, 0},
+	}
+}
+
+func TestCreditService_ChargeSkipsKeysWithoutAccount(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		charge, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+		require.NoError(t, err)
+		assert.Nil(t, charge, "keys without an account are not limited by credits")
+	}
+	assert.Equal(t, 0, f.creditRepo.ownerLookups, "keys without an account are not looked up")
+
+	// An account opened here is charged at once
+	f.openAccount(t, &f.apiKey.ID, nil, 5)
+	charge, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+	require.NoError(t, err)
+	require.NotNil(t, charge)
+	assert.Equal(t, 4.0, charge.Balance)
+
+	// One opened elsewhere is charged once the owners are reloaded
+	teamAccount := &models.CreditAccount{TeamID: &f.teamID, Balance: 5}
+	require.NoError(t, f.creditRepo.CreateAccount(ctx, teamAccount))
+	charge, err = f.service.Charge(ctx, f.teamKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.Nil(t, charge)
+
+	f.clock.Advance(creditOwnerRefreshInterval)
+	charge, err = f.service.Charge(ctx, f.teamKey, f.clock.Now())
+	require.NoError(t, err)
+	require.NotNil(t, charge)
+	assert.Equal(t, teamAccount.ID, charge.AccountID)
+}
+
+func TestCreditService_ChargeUntilExhausted(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	account := f.openAccount(t, &f.apiKey.ID, nil, 7)
+
+	for i := 0; i < 7; i++ {
+		charge, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+		require.NoError(t, err)
+		assert.True(t, charge.Allowed)
+	}
+	charge, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.False(t, charge.Allowed, "an empty balance rejects the request")
+	assert.False(t, charge.SpendCapReached)
+
+	// Crossing 5, reaching zero and the rejection each raise one alert
+	require.Len(t, f.alerts.alerts, 3)
+	assert.Equal(t, models.AlertSeverityMedium, f.alerts.alerts[0].Severity)
+	assert.Equal(t, models.AlertSeverityCritical, f.alerts.alerts[2].Severity)
+
+	// Requests are debited into one entry per hour
+	ledger, err := f.service.GetLedger(ctx, account.ID, repositories.DefaultPagination())
+	require.NoError(t, err)
+	entries := ledger.Data.([]*models.CreditLedgerEntry)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(7), entries[1].Requests)
+	assert.Equal(t, -7.0, entries[1].Amount)
+}
+
+func TestCreditService_SpendCap(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	account := f.openAccount(t, &f.apiKey.ID, nil, 100)
+
+	spendCap := 2.0
+	_, err := f.service.UpdateAccount(ctx, account.ID, &UpdateCreditAccountRequest{MonthlySpendCap: &spendCap})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+		require.NoError(t, err)
+	}
+	charge, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.False(t, charge.Allowed)
+	assert.True(t, charge.SpendCapReached)
+
+	// The cap applies per month
+	f.clock.Set(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC))
+	charge, err = f.service.Charge(ctx, f.apiKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.True(t, charge.Allowed)
+}
+
+// refusingCreditRepository refuses every debit with a fixed outcome
+type refusingCreditRepository struct {
+	*fakeCreditRepository
+	debit *repositories.CreditDebit
+}
+
+func (r *refusingCreditRepository) Debit(ctx context.Context, accountID uuid.UUID, amount float64, hour, month, now time.Time) (*repositories.CreditDebit, error) {
+	return r.debit, nil
+}
+
+func TestCreditService_RefusalReasonComesFromDebit(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	f.openAccount(t, &f.apiKey.ID, nil, 0)
+
+	// The balance seen after a refusal may cover the request if it changed
+	// concurrently; only the debit knows why it was refused
+	repo := &refusingCreditRepository{
+		fakeCreditRepository: f.creditRepo,
+		debit:                &repositories.CreditDebit{Balance: 50, CreditsExhausted: true},
+	}
+	service := NewCreditService(repo, f.apiKeyRepo, nil, testCreditPolicy(), f.clock)
+
+	charge, err := service.Charge(context.Background(), f.apiKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.False(t, charge.Allowed)
+	assert.False(t, charge.SpendCapReached)
+}
+
+func TestCreditService_TeamAccount(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	teamAccount := f.openAccount(t, nil, &f.teamID, 10)
+
+	// The team's keys are charged their tier's cost
+	charge, err := f.service.Charge(ctx, f.teamKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.Equal(t, teamAccount.ID, charge.AccountID)
+	assert.Equal(t, 9.5, charge.Balance)
+
+	// A key's own account is charged before its team's
+	keyAccount := f.openAccount(t, &f.teamKey.ID, nil, 0)
+	charge, err = f.service.Charge(ctx, f.teamKey, f.clock.Now())
+	require.NoError(t, err)
+	assert.Equal(t, keyAccount.ID, charge.AccountID)
+
+	_, err = f.service.CreateAccount(ctx, &CreateCreditAccountRequest{TeamID: &f.teamID})
+	assert.EqualError(t, err, "credit account already exists")
+}
+
+func TestCreditService_TopUpIsIdempotent(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	account := f.openAccount(t, &f.apiKey.ID, nil, 0)
+
+	req := &TopUpCreditsRequest{Amount: 25, Reference: "inv_1001"}
+	_, applied, err := f.service.TopUp(ctx, account.ID, req)
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	updated, applied, err := f.service.TopUp(ctx, account.ID, req)
+	require.NoError(t, err)
+	assert.False(t, applied)
+	assert.Equal(t, 25.0, updated.Balance)
+}
+
+func TestCreditService_ExpireCredits(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	account := f.openAccount(t, &f.apiKey.ID, nil, 5)
+
+	soon := f.clock.Now().Add(24 * time.Hour)
+	later := f.clock.Now().Add(30 * 24 * time.Hour)
+	f.topUp(t, account.ID, 10, &soon)
+	f.topUp(t, account.ID, 10, &later)
+
+	// Spending 4 draws on the credits that expire soonest
+	for i := 0; i < 4; i++ {
+		_, err := f.service.Charge(ctx, f.apiKey, f.clock.Now())
+		require.NoError(t, err)
+	}
+
+	f.clock.Advance(48 * time.Hour)
+	result, err := f.service.ExpireCredits(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Expired)
+	assert.Equal(t, 6.0, result.Amount)
+
+	updated, err := f.service.GetAccount(ctx, account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, updated.Balance)
+}
+
+func TestRateLimitService_CreditErrorsFailOpen(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	account := f.openAccount(t, &f.apiKey.ID, nil, 5)
+	service := f.rateLimiter(newFakeCacheService())
+	cacheKey := fmt.Sprintf("rate_limit:%s:%d", f.apiKey.ID, f.clock.Now().Truncate(time.Hour).Unix())
+	require.NoError(t, service.cacheService.SetCounter(ctx, cacheKey, 0, time.Hour))
+
+	// An unavailable credit store lets requests through uncharged
+	f.creditRepo.err = fmt.Errorf("connection refused")
+	result, err := service.CheckRateLimit(ctx, &RateLimitRequest{APIKeyID: f.apiKey.ID})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Nil(t, result.CreditBalance)
+	assert.Equal(t, 5.0, f.creditRepo.accounts[account.ID].Balance)
+}
+
+func TestRateLimitService_ChargeCreditsSkipsTheCounter(t *testing.T) {
+	f := newCreditFixture(t, testCreditPolicy())
+	ctx := context.Background()
+	f.openAccount(t, &f.apiKey.ID, nil, 1)
+	cache := newFakeCacheService()
+	service := f.rateLimiter(cache)
+
+	result, err := service.ChargeCredits(ctx, &RateLimitRequest{APIKeyID: f.apiKey.ID})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+
+	result, err = service.ChargeCredits(ctx, &RateLimitRequest{APIKeyID: f.apiKey.ID})
+	require.NoError(t, err)
+	assert.True(t, result.CreditsExhausted)
+	assert.Empty(t, cache.counters, "charging alone does not count towards the limit")
+}
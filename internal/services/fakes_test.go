@@ -0,0 +1,564 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeAPIKeyRepository keeps API keys, deleted ones included, and their tier
+// and status history in memory
+type fakeAPIKeyRepository struct {
+	repositories.APIKeyRepository
+	apiKeys  map[uuid.UUID]*models.APIKey
+	tiers    map[uuid.UUID][]*models.APIKeyTierChange
+	statuses map[uuid.UUID][]*models.APIKeyStatusChange
+}
+
+func newFakeAPIKeyRepository(apiKeys ...*models.APIKey) *fakeAPIKeyRepository {
+	r := &fakeAPIKeyRepository{
+		apiKeys:  make(map[uuid.UUID]*models.APIKey),
+		tiers:    make(map[uuid.UUID][]*models.APIKeyTierChange),
+		statuses: make(map[uuid.UUID][]*models.APIKeyStatusChange),
+	}
+	for _, apiKey := range apiKeys {
+		r.apiKeys[apiKey.ID] = apiKey
+	}
+	return r
+}
+
+func (r *fakeAPIKeyRepository) Create(ctx context.Context, apiKey *models.APIKey) error {
+	r.apiKeys[apiKey.ID] = apiKey
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) Update(ctx context.Context, apiKey *models.APIKey) error {
+	r.apiKeys[apiKey.ID] = apiKey
+	return nil
+}
+
+func (r *fakeAPIKeyRepository) GetByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	for _, apiKey := range r.apiKeys {
+		if apiKey.KeyHash == keyHash && !apiKey.DeletedAt.Valid {
+			copied := *apiKey
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("api key not found")
+}
+
+func (r *fakeAPIKeyRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	apiKey, ok := r.apiKeys[id]
+	if !ok || apiKey.DeletedAt.Valid {
+		return nil, fmt.Errorf("api key not found")
+	}
+	copied := *apiKey
+	return &copied, nil
+}
+
+func (r *fakeAPIKeyRepository) GetActiveByTier(ctx context.Context, tier models.APIKeyTier) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	for _, apiKey := range r.apiKeys {
+		if apiKey.IsActive() && !apiKey.DeletedAt.Valid && (tier == models.APIKeyTierAll || apiKey.Tier == tier) {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, nil
+}
+
+func (r *fakeAPIKeyRepository) GetByIDsWithDeleted(ctx context.Context, ids []uuid.UUID) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	for _, id := range ids {
+		if apiKey, ok := r.apiKeys[id]; ok {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, nil
+}
+
+func (r *fakeAPIKeyRepository) Suspend(ctx context.Context, ids []uuid.UUID, reason string) (int64, error) {
+	var suspended int64
+	for _, id := range ids {
+		if apiKey, ok := r.apiKeys[id]; ok && apiKey.Status == models.APIKeyStatusActive {
+			apiKey.Status = models.APIKeyStatusSuspended
+			apiKey.SuspendedReason = reason
+			suspended++
+		}
+	}
+	return suspended, nil
+}
+
+func (r *fakeAPIKeyRepository) Reinstate(ctx context.Context, ids []uuid.UUID, reason string) (int64, error) {
+	var reinstated int64
+	for _, id := range ids {
+		if apiKey, ok := r.apiKeys[id]; ok && apiKey.Status == models.APIKeyStatusSuspended && apiKey.SuspendedReason == reason {
+			apiKey.Status = models.APIKeyStatusActive
+			apiKey.SuspendedReason = ""
+			reinstated++
+		}
+	}
+	return reinstated, nil
+}
+
+func (r *fakeAPIKeyRepository) GetSuspended(ctx context.Context, reason string) ([]*models.APIKey, error) {
+	var apiKeys []*models.APIKey
+	for _, apiKey := range r.apiKeys {
+		if apiKey.Status == models.APIKeyStatusSuspended && apiKey.SuspendedReason == reason {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, nil
+}
+
+func (r *fakeAPIKeyRepository) GetTierChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyTierChange, error) {
+	var changes []*models.APIKeyTierChange
+	for _, change := range r.tiers[apiKeyID] {
+		if !change.ChangedAt.Before(since) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (r *fakeAPIKeyRepository) GetStatusChanges(ctx context.Context, apiKeyID uuid.UUID, since time.Time) ([]*models.APIKeyStatusChange, error) {
+	var changes []*models.APIKeyStatusChange
+	for _, change := range r.statuses[apiKeyID] {
+		if !change.ChangedAt.Before(since) {
+			changes = append(changes, change)
+		}
+	}
+	return changes, nil
+}
+
+func (r *fakeAPIKeyRepository) GetStatusChangedBetween(ctx context.Context, start, end time.Time) ([]*models.APIKey, error) {
+	within := func(t time.Time) bool { return !t.Before(start) && t.Before(end) }
+
+	var apiKeys []*models.APIKey
+	for _, apiKey := range r.apiKeys {
+		changed := apiKey.DeletedAt.Valid && within(apiKey.DeletedAt.Time)
+		for _, change := range r.statuses[apiKey.ID] {
+			changed = changed || within(change.ChangedAt)
+		}
+		if changed {
+			apiKeys = append(apiKeys, apiKey)
+		}
+	}
+	return apiKeys, nil
+}
+
+// fakeBillingRecordRepository keeps billing records in memory and counts
+// updates. Records end up overdue relative to clock.
+type fakeBillingRecordRepository struct {
+	repositories.BillingRecordRepository
+	records []*models.BillingRecord
+	updates int
+	clock   ratelimit.Clock
+}
+
+func (r *fakeBillingRecordRepository) GetByPeriod(ctx context.Context, apiKeyID uuid.UUID, periodStart, periodEnd time.Time) (*models.BillingRecord, error) {
+	for _, record := range r.records {
+		if record.APIKeyID == apiKeyID && record.PeriodStart.Equal(periodStart) && record.PeriodEnd.Equal(periodEnd) {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *fakeBillingRecordRepository) Create(ctx context.Context, record *models.BillingRecord) error {
+	record.ID = uuid.New()
+	r.records = append(r.records, record)
+	return nil
+}
+
+func (r *fakeBillingRecordRepository) Update(ctx context.Context, record *models.BillingRecord) error {
+	for i, existing := range r.records {
+		if existing.ID == record.ID {
+			r.records[i] = record
+			r.updates++
+			return nil
+		}
+	}
+	return fmt.Errorf("billing record not found")
+}
+
+func (r *fakeBillingRecordRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.BillingRecord, error) {
+	for _, record := range r.records {
+		if record.ID == id {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("billing record not found")
+}
+
+func (r *fakeBillingRecordRepository) GetAllInPeriod(ctx context.Context, startDate, endDate time.Time) ([]*models.BillingRecord, error) {
+	var records []*models.BillingRecord
+	for _, record := range r.records {
+		if !record.PeriodStart.Before(startDate) && !record.PeriodEnd.After(endDate) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *fakeBillingRecordRepository) UpdatePayment(ctx context.Context, record *models.BillingRecord, from []models.PaymentStatus) (bool, error) {
+	for i, existing := range r.records {
+		if existing.ID != record.ID {
+			continue
+		}
+		for _, status := range from {
+			if existing.PaymentStatus == status {
+				updated := *record
+				r.records[i] = &updated
+				r.updates++
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return false, fmt.Errorf("billing record not found")
+}
+
+func (r *fakeBillingRecordRepository) GetByChargeID(ctx context.Context, provider, chargeID string) (*models.BillingRecord, error) {
+	for _, record := range r.records {
+		if record.PaymentProvider == provider && record.PaymentChargeID == chargeID {
+			return record, nil
+		}
+	}
+	return nil, fmt.Errorf("billing record not found")
+}
+
+func (r *fakeBillingRecordRepository) GetUnpaidRecords(ctx context.Context, apiKeyID uuid.UUID) ([]*models.BillingRecord, error) {
+	var records []*models.BillingRecord
+	for _, record := range r.records {
+		if record.APIKeyID == apiKeyID && record.PaymentStatus.IsUnpaid() {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *fakeBillingRecordRepository) GetUnpaidEndedBefore(ctx context.Context, cutoff time.Time) ([]*models.BillingRecord, error) {
+	var records []*models.BillingRecord
+	for _, record := range r.records {
+		if record.PaymentStatus.IsUnpaid() && record.PeriodEnd.Before(cutoff) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+func (r *fakeBillingRecordRepository) GetOverdueRecords(ctx context.Context, daysOverdue int) ([]*models.BillingRecord, error) {
+	cutoff := r.clock.Now().AddDate(0, 0, -daysOverdue)
+	var records []*models.BillingRecord
+	for _, record := range r.records {
+		if (record.PaymentStatus == models.PaymentStatusPending || record.PaymentStatus == models.PaymentStatusOverdue) &&
+			record.PeriodEnd.Before(cutoff) {
+			record.PaymentStatus = models.PaymentStatusOverdue
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// rollupCall records one Rollup invocation
+type rollupCall struct {
+	granularity models.RollupGranularity
+	from, to    time.Time
+}
+
+// fakeUsageRollupRepository records rollups and keeps checkpoints in memory
+type fakeUsageRollupRepository struct {
+	checkpoints map[models.RollupGranularity]time.Time
+	earliest    map[models.RollupGranularity]time.Time
+	calls       []rollupCall
+	deleted     map[models.RollupGranularity]time.Time
+
+	// logs are the times of stored usage events; minutes counts them per
+	// minute bucket as rolled up
+	logs    []time.Time
+	minutes map[time.Time]int64
+}
+
+func newFakeUsageRollupRepository() *fakeUsageRollupRepository {
+	return &fakeUsageRollupRepository{
+		checkpoints: make(map[models.RollupGranularity]time.Time),
+		earliest:    make(map[models.RollupGranularity]time.Time),
+		deleted:     make(map[models.RollupGranularity]time.Time),
+		minutes:     make(map[time.Time]int64),
+	}
+}
+
+func (r *fakeUsageRollupRepository) Rollup(ctx context.Context, granularity models.RollupGranularity, from, to time.Time) (int64, error) {
+	r.calls = append(r.calls, rollupCall{granularity: granularity, from: from, to: to})
+	if granularity == models.RollupMinute {
+		for bucket := range r.minutes {
+			if !bucket.Before(from) && bucket.Before(to) {
+				delete(r.minutes, bucket)
+			}
+		}
+		for _, logged := range r.logs {
+			if !logged.Before(from) && logged.Before(to) {
+				r.minutes[logged.Truncate(time.Minute)]++
+			}
+		}
+	}
+	return 1, nil
+}
+
+func (r *fakeUsageRollupRepository) GetCheckpoints(ctx context.Context) (map[models.RollupGranularity]time.Time, error) {
+	result := make(map[models.RollupGranularity]time.Time, len(r.checkpoints))
+	for g, t := range r.checkpoints {
+		result[g] = t
+	}
+	return result, nil
+}
+
+func (r *fakeUsageRollupRepository) SetCheckpoint(ctx context.Context, granularity models.RollupGranularity, rolledUpTo time.Time) error {
+	r.checkpoints[granularity] = rolledUpTo
+	return nil
+}
+
+func (r *fakeUsageRollupRepository) GetEarliestSourceTime(ctx context.Context, granularity models.RollupGranularity) (*time.Time, error) {
+	earliest, ok := r.earliest[granularity]
+	if !ok {
+		return nil, nil
+	}
+	return &earliest, nil
+}
+
+func (r *fakeUsageRollupRepository) DeleteOldRollups(ctx context.Context, granularity models.RollupGranularity, before time.Time) (int64, error) {
+	r.deleted[granularity] = before
+	return 0, nil
+}
+
+// fakeCacheService is an in-memory CacheService for tests. Expirations are
+// ignored except for window counters, which expire by clock when one is set.
+type fakeCacheService struct {
+	mu       sync.Mutex
+	clock    ratelimit.Clock
+	counters map[string]int64
+	values   map[string]string
+	expires  map[string]time.Time
+}
+
+func newFakeCacheService() *fakeCacheService {
+	return &fakeCacheService{
+		counters: make(map[string]int64),
+		values:   make(map[string]string),
+		expires:  make(map[string]time.Time),
+	}
+}
+
+func (c *fakeCacheService) GetCounter(ctx context.Context, key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.counters[key]
+	if !ok {
+		return 0, fmt.Errorf("counter not found")
+	}
+	return value, nil
+}
+
+func (c *fakeCacheService) SetCounter(ctx context.Context, key string, value int64, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key] = value
+	return nil
+}
+
+func (c *fakeCacheService) IncrementCounter(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counters[key] += delta
+	return c.counters[key], nil
+}
+
+func (c *fakeCacheService) IncrementWindowCounter(ctx context.Context, key string, delta int64, window time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.clock != nil {
+		now := c.clock.Now()
+		if expires, ok := c.expires[key]; ok && !now.Before(expires) {
+			delete(c.counters, key)
+			delete(c.expires, key)
+		}
+		if _, ok := c.expires[key]; !ok {
+			c.expires[key] = now.Add(window)
+		}
+	}
+	c.counters[key] += delta
+	return c.counters[key], nil
+}
+
+func (c *fakeCacheService) DeleteKey(ctx context.Context, key string) error {
+	return c.Delete(ctx, key)
+}
+
+func (c *fakeCacheService) Get(ctx context.Context, key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.values[key]
+	if !ok {
+		return "", fmt.Errorf("key not found")
+	}
+	return value, nil
+}
+
+func (c *fakeCacheService) Set(ctx context.Context, key string, value string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.values[key] = value
+	return nil
+}
+
+func (c *fakeCacheService) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.counters, key)
+	delete(c.values, key)
+	delete(c.expires, key)
+	return nil
+}
+
+func (c *fakeCacheService) Exists(ctx context.Context, key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, counter := c.counters[key]
+	_, value := c.values[key]
+	return counter || value, nil
+}
+
+// fakeOrganizationRepository stores organizations, users and teams in memory
+type fakeOrganizationRepository struct {
+	repositories.OrganizationRepository
+	orgs    map[uuid.UUID]*models.Organization
+	users   map[uuid.UUID]*models.User
+	teams   map[uuid.UUID]*models.Team
+	members map[uuid.UUID]map[uuid.UUID]bool
+}
+
+func newFakeOrganizationRepository() *fakeOrganizationRepository {
+	return &fakeOrganizationRepository{
+		orgs:    make(map[uuid.UUID]*models.Organization),
+		users:   make(map[uuid.UUID]*models.User),
+		teams:   make(map[uuid.UUID]*models.Team),
+		members: make(map[uuid.UUID]map[uuid.UUID]bool),
+	}
+}
+
+func (r *fakeOrganizationRepository) Create(ctx context.Context, org *models.Organization, owner *models.User) error {
+	org.ID = uuid.New()
+	r.orgs[org.ID] = org
+	owner.OrganizationID = org.ID
+	owner.Role = models.UserRoleOwner
+	return r.CreateUser(ctx, owner)
+}
+
+func (r *fakeOrganizationRepository) GetBySlug(ctx context.Context, slug string) (*models.Organization, error) {
+	for _, org := range r.orgs {
+		if org.Slug == slug {
+			return org, nil
+		}
+	}
+	return nil, fmt.Errorf("organization not found")
+}
+
+func (r *fakeOrganizationRepository) CreateUser(ctx context.Context, user *models.User) error {
+	if user.ID == uuid.Nil {
+		user.ID = uuid.New()
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeOrganizationRepository) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return nil, fmt.Errorf("user not found")
+	}
+	return user, nil
+}
+
+func (r *fakeOrganizationRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found")
+}
+
+func (r *fakeOrganizationRepository) UpdateUser(ctx context.Context, user *models.User) error {
+	if user.Role != models.UserRoleOwner && r.isLastOwner(user.ID) {
+		return fmt.Errorf("organization must keep at least one owner")
+	}
+	r.users[user.ID] = user
+	return nil
+}
+
+func (r *fakeOrganizationRepository) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if r.isLastOwner(id) {
+		return fmt.Errorf("organization must keep at least one owner")
+	}
+	delete(r.users, id)
+	return nil
+}
+
+// isLastOwner reports whether the stored user is the only owner of their organization
+func (r *fakeOrganizationRepository) isLastOwner(id uuid.UUID) bool {
+	user, ok := r.users[id]
+	if !ok || user.Role != models.UserRoleOwner {
+		return false
+	}
+	for _, other := range r.users {
+		if other.ID != id && other.OrganizationID == user.OrganizationID && other.Role == models.UserRoleOwner {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *fakeOrganizationRepository) CreateTeam(ctx context.Context, team *models.Team) error {
+	if team.ID == uuid.Nil {
+		team.ID = uuid.New()
+	}
+	r.teams[team.ID] = team
+	return nil
+}
+
+func (r *fakeOrganizationRepository) GetTeam(ctx context.Context, id uuid.UUID) (*models.Team, error) {
+	team, ok := r.teams[id]
+	if !ok {
+		return nil, fmt.Errorf("team not found")
+	}
+	return team, nil
+}
+
+func (r *fakeOrganizationRepository) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*models.Team, error) {
+	var teams []*models.Team
+	for _, team := range r.teams {
+		if team.OrganizationID == orgID {
+			teams = append(teams, team)
+		}
+	}
+	return teams, nil
+}
+
+func (r *fakeOrganizationRepository) AddTeamMember(ctx context.Context, member *models.TeamMember) error {
+	if r.members[member.TeamID] == nil {
+		r.members[member.TeamID] = make(map[uuid.UUID]bool)
+	}
+	r.members[member.TeamID][member.UserID] = true
+	return nil
+}
+
+func (r *fakeOrganizationRepository) IsTeamMember(ctx context.Context, teamID, userID uuid.UUID) (bool, error) {
+	return r.members[teamID][userID], nil
+}
@@ -0,0 +1,436 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/metering"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+const (
+	defaultMeteringBatchSize      = 500
+	defaultMeteringMaxHoursPerRun = 168
+)
+
+// ErrMeteringDisabled is returned when the metering event stream is not
+// enabled
+var ErrMeteringDisabled = errors.New("metering is not enabled")
+
+// MeteringPolicy configures the metering event stream. Records are signed
+// with SigningSecret and sent to sinks in batches of up to BatchSize. One run
+// generates, and delivers to each sink, at most MaxHoursPerRun hours.
+type MeteringPolicy struct {
+	Enabled        bool
+	SigningSecret  string
+	BatchSize      int
+	MaxHoursPerRun int
+}
+
+// MeteringSinkStatus reports how far a sink has been delivered. Delivered
+// and Error are only set by a run.
+type MeteringSinkStatus struct {
+	Name      string     `json:"name"`
+	Through   *time.Time `json:"through,omitempty"`
+	Delivered int        `json:"delivered,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// MeteringStatus reports how far metering records have been generated and
+// delivered to each sink. Every hour before a checkpoint is done.
+type MeteringStatus struct {
+	Enabled          bool                  `json:"enabled"`
+	Generated        int64                 `json:"generated,omitempty"`
+	GeneratedThrough *time.Time            `json:"generated_through,omitempty"`
+	Sinks            []*MeteringSinkStatus `json:"sinks"`
+}
+
+// MeteringMetricReconciliation compares the quantity of a metric emitted for
+// an API key with the quantity its billing records were charged for
+type MeteringMetricReconciliation struct {
+	Metric     models.MeteringMetric `json:"metric"`
+	Emitted    int64                 `json:"emitted"`
+	Billed     int64                 `json:"billed"`
+	Difference int64                 `json:"difference"`
+}
+
+// MeteringKeyReconciliation compares the emitted and billed usage of an API
+// key
+type MeteringKeyReconciliation struct {
+	APIKeyID         uuid.UUID                      `json:"api_key_id"`
+	BillingRecordIDs []uuid.UUID                    `json:"billing_record_ids"`
+	Metrics          []MeteringMetricReconciliation `json:"metrics"`
+	Matched          bool                           `json:"matched"`
+}
+
+// MeteringReconciliation compares the metering records emitted for a period
+// with the billing records of the period. It is Complete once records have
+// been generated through the end of the period; SinksBehind lists the sinks
+// that have not yet received all of them.
+type MeteringReconciliation struct {
+	PeriodStart      time.Time                    `json:"period_start"`
+	PeriodEnd        time.Time                    `json:"period_end"`
+	Complete         bool                         `json:"complete"`
+	GeneratedThrough *time.Time                   `json:"generated_through,omitempty"`
+	SinksBehind      []string                     `json:"sinks_behind"`
+	Matched          int                          `json:"matched"`
+	Mismatched       int                          `json:"mismatched"`
+	Keys             []*MeteringKeyReconciliation `json:"keys"`
+}
+
+// MeteringService exports hourly usage to external billing systems. Records
+// are generated once from completed hourly rollups and delivered to every
+// sink from the sink's own checkpoint, so a failing sink holds up only
+// itself and can be replayed from any earlier hour.
+type MeteringService struct {
+	meteringRepo repositories.MeteringRepository
+	rollupRepo   repositories.UsageRollupRepository
+	billingRepo  repositories.BillingRecordRepository
+	sinks        []metering.Sink
+	policy       MeteringPolicy
+}
+
+// NewMeteringService creates a new metering service
+func NewMeteringService(
+	meteringRepo repositories.MeteringRepository,
+	rollupRepo repositories.UsageRollupRepository,
+	billingRepo repositories.BillingRecordRepository,
+	sinks []metering.Sink,
+	policy MeteringPolicy,
+) *MeteringService {
+	if policy.BatchSize <= 0 {
+		policy.BatchSize = defaultMeteringBatchSize
+	}
+	if policy.MaxHoursPerRun <= 0 {
+		policy.MaxHoursPerRun = defaultMeteringMaxHoursPerRun
+	}
+
+	return &MeteringService{
+		meteringRepo: meteringRepo,
+		rollupRepo:   rollupRepo,
+		billingRepo:  billingRepo,
+		sinks:        sinks,
+		policy:       policy,
+	}
+}
+
+// Run generates records for the hours the hourly rollups have completed
+// since the last run, then delivers undelivered records to every sink. A
+// sink that fails keeps its checkpoint and is retried on the next run; its
+// error is reported in the result.
+func (s *MeteringService) Run(ctx context.Context) (*MeteringStatus, error) {
+	if !s.policy.Enabled {
+		return nil, ErrMeteringDisabled
+	}
+
+	generated, through, err := s.generate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &MeteringStatus{
+		Enabled:          true,
+		Generated:        generated,
+		GeneratedThrough: through,
+		Sinks:            []*MeteringSinkStatus{},
+	}
+	if through == nil {
+		return result, nil
+	}
+
+	checkpoints, err := s.meteringRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, sink := range s.sinks {
+		status := &MeteringSinkStatus{Name: sink.Name()}
+		delivered, at, err := s.deliver(ctx, sink, checkpoints[sink.Name()], *through)
+		status.Delivered = delivered
+		if !at.IsZero() {
+			status.Through = &at
+		}
+		if err != nil {
+			status.Error = err.Error()
+		}
+		result.Sinks = append(result.Sinks, status)
+	}
+	return result, nil
+}
+
+// Status reports the generation and sink checkpoints
+func (s *MeteringService) Status(ctx context.Context) (*MeteringStatus, error) {
+	checkpoints, err := s.meteringRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MeteringStatus{
+		Enabled: s.policy.Enabled,
+		Sinks:   make([]*MeteringSinkStatus, 0, len(s.sinks)),
+	}
+	if through, ok := checkpoints[models.MeteringGeneratedCheckpoint]; ok {
+		status.GeneratedThrough = &through
+	}
+	for _, sink := range s.sinks {
+		sinkStatus := &MeteringSinkStatus{Name: sink.Name()}
+		if through, ok := checkpoints[sink.Name()]; ok {
+			sinkStatus.Through = &through
+		}
+		status.Sinks = append(status.Sinks, sinkStatus)
+	}
+	return status, nil
+}
+
+// Replay moves the checkpoint of a sink back to the hour containing from, so
+// the next run delivers every record from that hour on again. Receivers can
+// recognize the records they already have by their IDs.
+func (s *MeteringService) Replay(ctx context.Context, sinkName string, from time.Time) (*MeteringSinkStatus, error) {
+	if !s.policy.Enabled {
+		return nil, ErrMeteringDisabled
+	}
+
+	found := false
+	for _, sink := range s.sinks {
+		found = found || sink.Name() == sinkName
+	}
+	if !found {
+		return nil, fmt.Errorf("metering sink not found")
+	}
+
+	checkpoints, err := s.meteringRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+	from = models.RollupHour.Truncate(from)
+	current, ok := checkpoints[sinkName]
+	if !ok || from.After(current) {
+		return nil, fmt.Errorf("replay must start at or before the sink checkpoint")
+	}
+
+	if err := s.meteringRepo.SetCheckpoint(ctx, sinkName, from); err != nil {
+		return nil, err
+	}
+	return &MeteringSinkStatus{Name: sinkName, Through: &from}, nil
+}
+
+// Reconcile compares the records emitted for hours in [periodStart,
+// periodEnd) with the billing records of the period, by API key and metric.
+// Mismatched keys are listed first.
+func (s *MeteringService) Reconcile(ctx context.Context, periodStart, periodEnd time.Time) (*MeteringReconciliation, error) {
+	status, err := s.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.meteringRepo.GetTotals(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+	records, err := s.billingRepo.GetAllInPeriod(ctx, periodStart, periodEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &MeteringReconciliation{
+		PeriodStart:      periodStart,
+		PeriodEnd:        periodEnd,
+		GeneratedThrough: status.GeneratedThrough,
+		Complete:         status.GeneratedThrough != nil && !status.GeneratedThrough.Before(periodEnd),
+		SinksBehind:      []string{},
+		Keys:             []*MeteringKeyReconciliation{},
+	}
+	for _, sink := range status.Sinks {
+		if sink.Through == nil || sink.Through.Before(periodEnd) {
+			report.SinksBehind = append(report.SinksBehind, sink.Name)
+		}
+	}
+
+	emitted := make(map[uuid.UUID]map[models.MeteringMetric]int64)
+	billed := make(map[uuid.UUID]map[models.MeteringMetric]int64)
+	recordIDs := make(map[uuid.UUID][]uuid.UUID)
+	add := func(quantities map[uuid.UUID]map[models.MeteringMetric]int64, apiKeyID uuid.UUID, metric models.MeteringMetric, quantity int64) {
+		if quantities[apiKeyID] == nil {
+			quantities[apiKeyID] = make(map[models.MeteringMetric]int64)
+		}
+		quantities[apiKeyID][metric] += quantity
+	}
+	for _, total := range totals {
+		add(emitted, total.APIKeyID, total.Metric, total.Quantity)
+	}
+	for _, record := range records {
+		add(billed, record.APIKeyID, models.MeteringMetricRequests, record.TotalRequests)
+		add(billed, record.APIKeyID, models.MeteringMetricBandwidth, record.TotalBandwidth)
+		add(billed, record.APIKeyID, models.MeteringMetricRateLimited, record.RateLimitHits)
+		recordIDs[record.APIKeyID] = append(recordIDs[record.APIKeyID], record.ID)
+	}
+
+	keys := make(map[uuid.UUID]bool)
+	for apiKeyID := range emitted {
+		keys[apiKeyID] = true
+	}
+	for apiKeyID := range billed {
+		keys[apiKeyID] = true
+	}
+	for apiKeyID := range keys {
+		key := &MeteringKeyReconciliation{
+			APIKeyID:         apiKeyID,
+			BillingRecordIDs: recordIDs[apiKeyID],
+			Matched:          true,
+		}
+		if key.BillingRecordIDs == nil {
+			key.BillingRecordIDs = []uuid.UUID{}
+		}
+		for _, metric := range models.MeteringMetrics {
+			line := MeteringMetricReconciliation{
+				Metric:  metric,
+				Emitted: emitted[apiKeyID][metric],
+				Billed:  billed[apiKeyID][metric],
+			}
+			line.Difference = line.Emitted - line.Billed
+			key.Matched = key.Matched && line.Difference == 0
+			key.Metrics = append(key.Metrics, line)
+		}
+		if key.Matched {
+			report.Matched++
+		} else {
+			report.Mismatched++
+		}
+		report.Keys = append(report.Keys, key)
+	}
+
+	sort.Slice(report.Keys, func(i, j int) bool {
+		if report.Keys[i].Matched != report.Keys[j].Matched {
+			return !report.Keys[i].Matched
+		}
+		return report.Keys[i].APIKeyID.String() < report.Keys[j].APIKeyID.String()
+	})
+	return report, nil
+}
+
+// generate creates the records of the hours completed by the hourly rollups
+// since the generation checkpoint, starting with the current billing month
+// on the first run. It returns how many records were created and the new
+// checkpoint, or nil when the hourly rollups have never run.
+func (s *MeteringService) generate(ctx context.Context) (int64, *time.Time, error) {
+	rollups, err := s.rollupRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+	checkpoints, err := s.meteringRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	from, ok := checkpoints[models.MeteringGeneratedCheckpoint]
+	completed, rolledUp := rollups[models.RollupHour]
+	if !rolledUp {
+		if ok {
+			return 0, &from, nil
+		}
+		return 0, nil, nil
+	}
+	if !ok {
+		from, _ = billingMonth(completed)
+	}
+
+	to := completed
+	if maxTo := from.Add(time.Duration(s.policy.MaxHoursPerRun) * time.Hour); to.After(maxTo) {
+		to = maxTo
+	}
+	if !to.After(from) {
+		if !ok {
+			if err := s.meteringRepo.SetCheckpoint(ctx, models.MeteringGeneratedCheckpoint, from); err != nil {
+				return 0, nil, err
+			}
+		}
+		return 0, &from, nil
+	}
+
+	usage, err := s.meteringRepo.GetHourlyUsage(ctx, from, to)
+	if err != nil {
+		return 0, nil, err
+	}
+	var records []*models.MeteringRecord
+	for _, hour := range usage {
+		quantities := map[models.MeteringMetric]int64{
+			models.MeteringMetricRequests:    hour.Requests,
+			models.MeteringMetricBandwidth:   hour.Bandwidth,
+			models.MeteringMetricRateLimited: hour.RateLimitedRequests,
+		}
+		start := hour.Hour.UTC()
+		for _, metric := range models.MeteringMetrics {
+			if quantities[metric] == 0 {
+				continue
+			}
+			records = append(records, &models.MeteringRecord{
+				ID:          models.MeteringRecordID(hour.APIKeyID, metric, start),
+				APIKeyID:    hour.APIKeyID,
+				Metric:      metric,
+				Quantity:    quantities[metric],
+				PeriodStart: start,
+				PeriodEnd:   start.Add(time.Hour),
+			})
+		}
+	}
+
+	created, err := s.meteringRepo.CreateRecords(ctx, records)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := s.meteringRepo.SetCheckpoint(ctx, models.MeteringGeneratedCheckpoint, to); err != nil {
+		return 0, nil, err
+	}
+	return created, &to, nil
+}
+
+// deliver sends a sink the records of every hour from its checkpoint up to
+// generated, one hour at a time, moving the checkpoint past each hour once
+// all of its batches are sent. A zero checkpoint delivers every record. It
+// returns how many records were delivered and the sink's new checkpoint.
+func (s *MeteringService) deliver(ctx context.Context, sink metering.Sink, from, generated time.Time) (int, time.Time, error) {
+	delivered := 0
+	for hours := 0; from.Before(generated) && hours < s.policy.MaxHoursPerRun; hours++ {
+		next, err := s.meteringRepo.GetNextRecordTime(ctx, from)
+		if err != nil {
+			return delivered, from, err
+		}
+		if next == nil || !next.Before(generated) {
+			// Nothing left to deliver; the hours in between had no usage
+			if err := s.meteringRepo.SetCheckpoint(ctx, sink.Name(), generated); err != nil {
+				return delivered, from, err
+			}
+			return delivered, generated, nil
+		}
+
+		hour := models.RollupHour.Truncate(*next)
+		records, err := s.meteringRepo.GetRecords(ctx, hour, hour.Add(time.Hour))
+		if err != nil {
+			return delivered, from, err
+		}
+		for start := 0; start < len(records); start += s.policy.BatchSize {
+			end := start + s.policy.BatchSize
+			if end > len(records) {
+				end = len(records)
+			}
+			events := make([]*metering.Event, 0, end-start)
+			for _, record := range records[start:end] {
+				events = append(events, metering.NewEvent(record, s.policy.SigningSecret))
+			}
+			if err := sink.Send(ctx, metering.NewBatch(events)); err != nil {
+				return delivered, from, err
+			}
+		}
+
+		from = hour.Add(time.Hour)
+		if err := s.meteringRepo.SetCheckpoint(ctx, sink.Name(), from); err != nil {
+			return delivered, from, err
+		}
+		delivered += len(records)
+	}
+	return delivered, from, nil
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/metering"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// fakeMeteringRepository keeps records and checkpoints in memory and serves
+// hourly usage from a fixed list
+type fakeMeteringRepository struct {
+	usage       []*repositories.MeteredUsage
+	records     map[string]*models.MeteringRecord
+	checkpoints map[string]time.Time
+}
+
+func newFakeMeteringRepository() *fakeMeteringRepository {
+	return &fakeMeteringRepository{
+		records:     make(map[string]*models.MeteringRecord),
+		checkpoints: make(map[string]time.Time),
+	}
+}
+
+func (r *fakeMeteringRepository) GetHourlyUsage(ctx context.Context, from, to time.Time) ([]*repositories.MeteredUsage, error) {
+	var usage []*repositories.MeteredUsage
+	for _, hour := range r.usage {
+		if !hour.Hour.Before(from) && hour.Hour.Before(to) {
+			usage = append(usage, hour)
+		}
+	}
+	return usage, nil
+}
+
+func (r *fakeMeteringRepository) CreateRecords(ctx context.Context, records []*models.MeteringRecord) (int64, error) {
+	var created int64
+	for _, record := range records {
+		if _, ok := r.records[record.ID]; !ok {
+			r.records[record.ID] = record
+			created++
+		}
+	}
+	return created, nil
+}
+
+func (r *fakeMeteringRepository) GetRecords(ctx context.Context, from, to time.Time) ([]*models.MeteringRecord, error) {
+	var records []*models.MeteringRecord
+	for _, record := range r.records {
+		if !record.PeriodStart.Before(from) && record.PeriodStart.Before(to) {
+			records = append(records, record)
+		}
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].PeriodStart.Equal(records[j].PeriodStart) {
+			return records[i].PeriodStart.Before(records[j].PeriodStart)
+		}
+		return records[i].ID < records[j].ID
+	})
+	return records, nil
+}
+
+func (r *fakeMeteringRepository) GetNextRecordTime(ctx context.Context, from time.Time) (*time.Time, error) {
+	var next *time.Time
+	for _, record := range r.records {
+		start := record.PeriodStart
+		if !start.Before(from) && (next == nil || start.Before(*next)) {
+			next = &start
+		}
+	}
+	return next, nil
+}
+
+func (r *fakeMeteringRepository) GetTotals(ctx context.Context, from, to time.Time) ([]*repositories.MeteringTotal, error) {
+	sums := make(map[uuid.UUID]map[models.MeteringMetric]int64)
+	for _, record := range r.records {
+		if record.PeriodStart.Before(from) || !record.PeriodStart.Before(to) {
+			continue
+		}
+		if sums[record.APIKeyID] == nil {
+			sums[record.APIKeyID] = make(map[models.MeteringMetric]int64)
+		}
+		sums[record.APIKeyID][record.Metric] += record.Quantity
+	}
+
+	var totals []*repositories.MeteringTotal
+	for apiKeyID, metrics := range sums {
+		for metric, quantity := range metrics {
+			totals = append(totals, &repositories.MeteringTotal{APIKeyID: apiKeyID, Metric: metric, Quantity: quantity})
+		}
+	}
+	return totals, nil
+}
+
+func (r *fakeMeteringRepository) GetCheckpoints(ctx context.Context) (map[string]time.Time, error) {
+	result := make(map[string]time.Time, len(r.checkpoints))
+	for name, through := range r.checkpoints {
+		result[name] = through
+	}
+	return result, nil
+}
+
+func (r *fakeMeteringRepository) SetCheckpoint(ctx context.Context, name string, through time.Time) error {
+	r.checkpoints[name] = through
+	return nil
+}
+
+// recordingMeteringSink records the batches it receives, or fails every send
+type recordingMeteringSink struct {
+	name    string
+	batches []*metering.Batch
+	err     error
+}
+
+func (s *recordingMeteringSink) Name() string {
+	return s.name
+}
+
+func (s *recordingMeteringSink) Send(ctx context.Context, batch *metering.Batch) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *recordingMeteringSink) events() []*metering.Event {
+	var events []*metering.Event
+	for _, batch := range s.batches {
+		events = append(events, batch.Events...)
+	}
+	return events
+}
+
+// meteringFixture has usage for one key in the first two hours of March
+// 2026, with the hourly rollups completed through 02:00
+type meteringFixture struct {
+	service      *MeteringService
+	meteringRepo *fakeMeteringRepository
+	billingRepo  *fakeBillingRecordRepository
+	good         *recordingMeteringSink
+	broken       *recordingMeteringSink
+	apiKeyID     uuid.UUID
+	month        time.Time
+}
+
+func newMeteringFixture(t *testing.T, policy MeteringPolicy) *meteringFixture {
+	t.Helper()
+
+	f := &meteringFixture{
+		meteringRepo: newFakeMeteringRepository(),
+		billingRepo:  &fakeBillingRecordRepository{},
+		good:         &recordingMeteringSink{name: "files"},
+		broken:       &recordingMeteringSink{name: "billing", err: fmt.Errorf("connection refused")},
+		apiKeyID:     uuid.New(),
+		month:        time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+	}
+	f.meteringRepo.usage = []*repositories.MeteredUsage{
+		{APIKeyID: f.apiKeyID, Hour: f.month, Requests: 100, Bandwidth: 2048, RateLimitedRequests: 5},
+		{APIKeyID: f.apiKeyID, Hour: f.month.Add(time.Hour), Requests: 40, Bandwidth: 1024},
+	}
+	rollupRepo := newFakeUsageRollupRepository()
+	rollupRepo.checkpoints[models.RollupHour] = f.month.Add(2 * time.Hour)
+
+	policy.Enabled = true
+	policy.SigningSecret = "s3cret"
+	f.service = NewMeteringService(f.meteringRepo, rollupRepo, f.billingRepo, []metering.Sink{f.good, f.broken}, policy)
+	return f
+}
+
+func TestMeteringService_Run(t *testing.T) {
+	f := newMeteringFixture(t, MeteringPolicy{BatchSize: 2})
+	ctx := context.Background()
+
+	result, err := f.service.Run(ctx)
+	require.NoError(t, err)
+
+	// The second hour has no rate limited requests, so it has no record for them
+	assert.Equal(t, int64(5), result.Generated)
+	assert.Equal(t, f.month.Add(2*time.Hour), *result.GeneratedThrough)
+	require.Len(t, result.Sinks, 2)
+	assert.Equal(t, 5, result.Sinks[0].Delivered)
+	assert.Equal(t, f.month.Add(2*time.Hour), f.meteringRepo.checkpoints["files"])
+
+	// Batches never span hours, so the first hour's three records take two
+	// batches and the second hour's two records take one
+	require.Len(t, f.good.batches, 3)
+	for _, event := range f.good.events() {
+		assert.True(t, metering.VerifyEvent("s3cret", event))
+	}
+
+	// The failing sink reports its error and keeps no checkpoint
+	assert.Contains(t, result.Sinks[1].Error, "connection refused")
+	assert.Nil(t, result.Sinks[1].Through)
+
+	// Once it recovers it receives the whole backlog
+	f.broken.err = nil
+	result, err = f.service.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), result.Generated)
+	assert.Equal(t, 0, result.Sinks[0].Delivered)
+	assert.Equal(t, 5, result.Sinks[1].Delivered)
+}
+
+func TestMeteringService_RunLimitsHoursPerRun(t *testing.T) {
+	f := newMeteringFixture(t, MeteringPolicy{MaxHoursPerRun: 1})
+
+	result, err := f.service.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Generated)
+	assert.Equal(t, f.month.Add(time.Hour), *result.GeneratedThrough)
+}
+
+func TestMeteringService_Disabled(t *testing.T) {
+	f := newMeteringFixture(t, MeteringPolicy{})
+	f.service.policy.Enabled = false
+
+	_, err := f.service.Run(context.Background())
+	assert.ErrorIs(t, err, ErrMeteringDisabled)
+}
+
+func TestMeteringService_Replay(t *testing.T) {
+	f := newMeteringFixture(t, MeteringPolicy{})
+	ctx := context.Background()
+
+	_, err := f.service.Run(ctx)
+	require.NoError(t, err)
+
+	_, err = f.service.Replay(ctx, "billing", f.month)
+	assert.EqualError(t, err, "replay must start at or before the sink checkpoint")
+
+	status, err := f.service.Replay(ctx, "files", f.month.Add(time.Hour+30*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, f.month.Add(time.Hour), *status.Through)
+
+	// Only the second hour is sent again, with the same record IDs
+	result, err := f.service.Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Sinks[0].Delivered)
+	events := f.good.events()
+	require.Len(t, events, 7)
+	assert.Equal(t, events[3].ID, events[5].ID)
+}
+
+func TestMeteringService_Reconcile(t *testing.T) {
+	f := newMeteringFixture(t, MeteringPolicy{})
+	ctx := context.Background()
+
+	_, err := f.service.Run(ctx)
+	require.NoError(t, err)
+
+	otherKey := uuid.New()
+	f.billingRepo.records = []*models.BillingRecord{
+		{ID: uuid.New(), APIKeyID: f.apiKeyID, PeriodStart: f.month, PeriodEnd: f.month.AddDate(0, 1, 0),
+			TotalRequests: 140, TotalBandwidth: 3072, RateLimitHits: 5},
+		{ID: uuid.New(), APIKeyID: otherKey, PeriodStart: f.month, PeriodEnd: f.month.AddDate(0, 1, 0),
+			TotalRequests: 10},
+	}
+
+	report, err := f.service.Reconcile(ctx, f.month, f.month.AddDate(0, 1, 0))
+	require.NoError(t, err)
+
+	// Generation has only reached 02:00, so the month is not complete yet
+	assert.False(t, report.Complete)
+	assert.Equal(t, 1, report.Matched)
+	assert.Equal(t, 1, report.Mismatched)
+	require.Len(t, report.Keys, 2)
+	assert.Equal(t, otherKey, report.Keys[0].APIKeyID)
+	assert.Equal(t, int64(-10), report.Keys[0].Metrics[0].Difference)
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// NotificationService defines the interface for sending notifications
+type NotificationService interface {
+	// SendAlertNotification routes an alert to every channel that accepts
+	// its severity
+	SendAlertNotification(ctx context.Context, alert *models.Alert) error
+	// DeliverNotification makes one attempt to deliver an alert on a channel.
+	// final is true for the last attempt, after which a failure is recorded
+	// as given up.
+	DeliverNotification(ctx context.Context, alertID uuid.UUID, channel string, final bool) error
+}
+
+// NotificationEnqueuer hands alert notifications to the worker, which calls
+// DeliverNotification until delivery succeeds or retries run out
+type NotificationEnqueuer interface {
+	EnqueueNotification(ctx context.Context, alertID uuid.UUID, channel string) error
+}
+
+// notificationService implements NotificationService interface
+type notificationService struct {
+	alertRepo repositories.AlertRepository
+	routes    []notify.Route
+	renderer  *notify.Renderer
+	enqueuer  NotificationEnqueuer
+	clock     ratelimit.Clock
+}
+
+// NewNotificationService creates a new notification service. Without an
+// enqueuer every notification is attempted once before SendAlertNotification
+// returns.
+func NewNotificationService(
+	alertRepo repositories.AlertRepository,
+	routes []notify.Route,
+	renderer *notify.Renderer,
+	enqueuer NotificationEnqueuer,
+	clock ratelimit.Clock,
+) NotificationService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &notificationService{
+		alertRepo: alertRepo,
+		routes:    routes,
+		renderer:  renderer,
+		enqueuer:  enqueuer,
+		clock:     clock,
+	}
+}
+
+// SendAlertNotification records a pending delivery for every channel routed
+// the alert and enqueues it
+func (s *notificationService) SendAlertNotification(ctx context.Context, alert *models.Alert) error {
+	var firstErr error
+	for _, route := range s.routes {
+		if !route.Accepts(alert.Severity) {
+			continue
+		}
+		channel := route.Channel.Name()
+
+		if s.enqueuer == nil {
+			if err := s.DeliverNotification(ctx, alert.ID, channel, true); err != nil && firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		pending := &models.AlertDelivery{Status: models.AlertDeliveryPending}
+		if err := s.alertRepo.UpdateDelivery(ctx, alert.ID, channel, pending); err != nil {
+			return err
+		}
+		if err := s.enqueuer.EnqueueNotification(ctx, alert.ID, channel); err != nil {
+			// Nothing will retry a delivery that never made it into the queue
+			failed := &models.AlertDelivery{Status: models.AlertDeliveryFailed, LastError: err.Error()}
+			if updateErr := s.alertRepo.UpdateDelivery(ctx, alert.ID, channel, failed); updateErr != nil {
+				fmt.Printf("Failed to record %s delivery of alert %s: %v\n", channel, alert.ID, updateErr)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// DeliverNotification renders the alert and sends it on the channel, then
+// records the outcome. Deliveries already sent are not repeated.
+func (s *notificationService) DeliverNotification(ctx context.Context, alertID uuid.UUID, channel string, final bool) error {
+	route, ok := s.route(channel)
+	if !ok {
+		return &notify.PermanentError{Err: fmt.Errorf("unknown notification channel: %s", channel)}
+	}
+
+	alert, err := s.alertRepo.GetByID(ctx, alertID)
+	if err != nil {
+		return err
+	}
+
+	delivery := alert.Deliveries[channel]
+	if delivery == nil {
+		delivery = &models.AlertDelivery{Status: models.AlertDeliveryPending}
+	}
+	if delivery.Status == models.AlertDeliverySent {
+		return nil
+	}
+
+	msg, err := s.renderer.Render(alert)
+	if err != nil {
+		err = &notify.PermanentError{Err: err}
+	} else {
+		err = route.Channel.Send(ctx, msg)
+	}
+
+	s.record(ctx, alertID, channel, delivery, err, final || notify.IsPermanent(err))
+	return err
+}
+
+// record stores the outcome of a delivery attempt. A failed attempt is
+// recorded as given up when final is true.
+func (s *notificationService) record(ctx context.Context, alertID uuid.UUID, channel string, delivery *models.AlertDelivery, sendErr error, final bool) {
+	now := s.clock.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.AlertDeliverySent
+		delivery.SentAt = &now
+		delivery.LastError = ""
+	case final:
+		delivery.Status = models.AlertDeliveryFailed
+		delivery.LastError = sendErr.Error()
+	default:
+		delivery.Status = models.AlertDeliveryRetrying
+		delivery.LastError = sendErr.Error()
+	}
+
+	if err := s.alertRepo.UpdateDelivery(ctx, alertID, channel, delivery); err != nil {
+		fmt.Printf("Failed to record %s delivery of alert %s: %v\n", channel, alertID, err)
+	}
+}
+
+// route returns the route of the named channel
+func (s *notificationService) route(channel string) (notify.Route, bool) {
+	for _, route := range s.routes {
+		if route.Channel.Name() == channel {
+			return route, true
+		}
+	}
+	return notify.Route{}, false
+}
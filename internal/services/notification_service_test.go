@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/notify"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeDeliveryAlertRepository stores the delivery records of one alert
+type fakeDeliveryAlertRepository struct {
+	repositories.AlertRepository
+	alert *models.Alert
+}
+
+func (r *fakeDeliveryAlertRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Alert, error) {
+	if id != r.alert.ID {
+		return nil, errors.New("alert not found")
+	}
+	// Return a copy like the database would, so the service cannot change the stored records
+	alert := *r.alert
+	alert.Deliveries = make(map[string]*models.AlertDelivery)
+	for channel, delivery := range r.alert.Deliveries {
+		copied := *delivery
+		alert.Deliveries[channel] = &copied
+	}
+	return &alert, nil
+}
+
+func (r *fakeDeliveryAlertRepository) UpdateDelivery(ctx context.Context, id uuid.UUID, channel string, delivery *models.AlertDelivery) error {
+	if r.alert.Deliveries == nil {
+		r.alert.Deliveries = make(map[string]*models.AlertDelivery)
+	}
+	copied := *delivery
+	r.alert.Deliveries[channel] = &copied
+	return nil
+}
+
+// fakeChannel records the messages sent on it and fails with err
+type fakeChannel struct {
+	name string
+	err  error
+	sent []*notify.Message
+}
+
+func (c *fakeChannel) Name() string {
+	return c.name
+}
+
+func (c *fakeChannel) Send(ctx context.Context, msg *notify.Message) error {
+	c.sent = append(c.sent, msg)
+	return c.err
+}
+
+type fakeNotificationEnqueuer struct {
+	err      error
+	enqueued []string
+}
+
+func (e *fakeNotificationEnqueuer) EnqueueNotification(ctx context.Context, alertID uuid.UUID, channel string) error {
+	e.enqueued = append(e.enqueued, channel)
+	return e.err
+}
+
+func newNotificationTestService(t *testing.T, severity models.AlertSeverity, enqueuer NotificationEnqueuer, channels ...*fakeChannel) (NotificationService, *fakeDeliveryAlertRepository) {
+	repo := &fakeDeliveryAlertRepository{alert: &models.Alert{
+		ID:        uuid.New(),
+		APIKeyID:  uuid.New(),
+		Type:      models.AlertTypeRateLimitExceeded,
+		Severity:  severity,
+		Message:   "Rate limit exceeded",
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+	}}
+
+	minSeverities := []models.AlertSeverity{models.AlertSeverityInfo, models.AlertSeverityMedium, models.AlertSeverityCritical}
+	var routes []notify.Route
+	for i, channel := range channels {
+		routes = append(routes, notify.Route{Channel: channel, MinSeverity: minSeverities[i]})
+	}
+
+	renderer, err := notify.NewRenderer(notify.Templates{})
+	require.NoError(t, err)
+
+	service := NewNotificationService(repo, routes, renderer, enqueuer,
+		ratelimit.NewFakeClock(time.Date(2026, 3, 1, 12, 0, 1, 0, time.UTC)))
+	return service, repo
+}
+
+func TestSendAlertNotificationRoutesBySeverity(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook"}
+	slack := &fakeChannel{name: "slack"}
+	incident := &fakeChannel{name: "incident"}
+	enqueuer := &fakeNotificationEnqueuer{}
+	service, repo := newNotificationTestService(t, models.AlertSeverityHigh, enqueuer, webhook, slack, incident)
+
+	require.NoError(t, service.SendAlertNotification(context.Background(), repo.alert))
+
+	assert.Equal(t, []string{"webhook", "slack"}, enqueuer.enqueued)
+	assert.Len(t, repo.alert.Deliveries, 2)
+	assert.Equal(t, models.AlertDeliveryPending, repo.alert.Deliveries["webhook"].Status)
+	assert.Equal(t, models.AlertDeliveryPending, repo.alert.Deliveries["slack"].Status)
+	assert.Empty(t, webhook.sent, "queued deliveries are left to the worker")
+}
+
+func TestSendAlertNotificationEnqueueFailure(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook"}
+	enqueuer := &fakeNotificationEnqueuer{err: errors.New("redis unavailable")}
+	service, repo := newNotificationTestService(t, models.AlertSeverityHigh, enqueuer, webhook)
+
+	err := service.SendAlertNotification(context.Background(), repo.alert)
+	require.Error(t, err)
+
+	delivery := repo.alert.Deliveries["webhook"]
+	assert.Equal(t, models.AlertDeliveryFailed, delivery.Status)
+	assert.Equal(t, 0, delivery.Attempts)
+	assert.Equal(t, "redis unavailable", delivery.LastError)
+}
+
+func TestSendAlertNotificationInline(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook"}
+	slack := &fakeChannel{name: "slack", err: errors.New("connection refused")}
+	service, repo := newNotificationTestService(t, models.AlertSeverityMedium, nil, webhook, slack)
+
+	err := service.SendAlertNotification(context.Background(), repo.alert)
+	require.Error(t, err)
+
+	require.Len(t, webhook.sent, 1)
+	assert.Equal(t, models.AlertDeliverySent, repo.alert.Deliveries["webhook"].Status)
+	assert.NotNil(t, repo.alert.Deliveries["webhook"].SentAt)
+
+	// Without a queue nothing retries, so the only attempt is final
+	assert.Equal(t, models.AlertDeliveryFailed, repo.alert.Deliveries["slack"].Status)
+	assert.Equal(t, "connection refused", repo.alert.Deliveries["slack"].LastError)
+}
+
+func TestDeliverNotificationRetriesUntilFinal(t *testing.T) {
+	ctx := context.Background()
+	webhook := &fakeChannel{name: "webhook", err: errors.New("unexpected status 503")}
+	service, repo := newNotificationTestService(t, models.AlertSeverityHigh, &fakeNotificationEnqueuer{}, webhook)
+	require.NoError(t, service.SendAlertNotification(ctx, repo.alert))
+
+	require.Error(t, service.DeliverNotification(ctx, repo.alert.ID, "webhook", false))
+	delivery := repo.alert.Deliveries["webhook"]
+	assert.Equal(t, models.AlertDeliveryRetrying, delivery.Status)
+	assert.Equal(t, 1, delivery.Attempts)
+	assert.NotNil(t, delivery.LastAttemptAt)
+
+	require.Error(t, service.DeliverNotification(ctx, repo.alert.ID, "webhook", true))
+	delivery = repo.alert.Deliveries["webhook"]
+	assert.Equal(t, models.AlertDeliveryFailed, delivery.Status)
+	assert.Equal(t, 2, delivery.Attempts)
+	assert.Equal(t, "unexpected status 503", delivery.LastError)
+}
+
+func TestDeliverNotificationPermanentFailure(t *testing.T) {
+	webhook := &fakeChannel{name: "webhook", err: &notify.PermanentError{Err: errors.New("unexpected status 404")}}
+	service, repo := newNotificationTestService(t, models.AlertSeverityHigh, &fakeNotificationEnqueuer{}, webhook)
+
+	err := service.DeliverNotification(context.Background(), repo.alert.ID, "webhook", false)
+	require.Error(t, err)
+	assert.True(t, notify.IsPermanent(err))
+	assert.Equal(t, models.AlertDeliveryFailed, repo.alert.Deliveries["webhook"].Status)
+
+	err = service.DeliverNotification(context.Background(), repo.alert.ID, "pager", false)
+	assert.True(t, notify.IsPermanent(err))
+}
+
+func TestDeliverNotificationSkipsSentDeliveries(t *testing.T) {
+	ctx := context.Background()
+	webhook := &fakeChannel{name: "webhook"}
+	service, repo := newNotificationTestService(t, models.AlertSeverityHigh, &fakeNotificationEnqueuer{}, webhook)
+
+	require.NoError(t, service.DeliverNotification(ctx, repo.alert.ID, "webhook", false))
+	require.NoError(t, service.DeliverNotification(ctx, repo.alert.ID, "webhook", false))
+
+	assert.Len(t, webhook.sent, 1)
+	assert.Equal(t, 1, repo.alert.Deliveries["webhook"].Attempts)
+	assert.Equal(t, models.AlertDeliverySent, repo.alert.Deliveries["webhook"].Status)
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+)
+
+// organizationSlugPattern matches lowercase slugs such as "acme-corp"
+var organizationSlugPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// CreateOrganizationRequest contains data for creating an organization and
+// its first owner
+type CreateOrganizationRequest struct {
+	Name       string `json:"name" binding:"required,max=255"`
+	Slug       string `json:"slug" binding:"required,max=100"`
+	OwnerEmail string `json:"owner_email" binding:"required,email,max=255"`
+	OwnerName  string `json:"owner_name" binding:"max=255"`
+}
+
+// OrganizationResponse is an organization with its first owner, returned when
+// it is created
+type OrganizationResponse struct {
+	*models.Organization
+	Owner *models.User `json:"owner,omitempty"`
+}
+
+// CreateUserRequest contains data for adding a user to an organization
+type CreateUserRequest struct {
+	Email string          `json:"email" binding:"required,email,max=255"`
+	Name  string          `json:"name" binding:"max=255"`
+	Role  models.UserRole `json:"role"`
+}
+
+// UpdateUserRequest contains data for updating a user
+type UpdateUserRequest struct {
+	Name *string          `json:"name" binding:"omitempty,max=255"`
+	Role *models.UserRole `json:"role"`
+}
+
+// CreateTeamRequest contains data for creating a team
+type CreateTeamRequest struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// TeamResponse is a team with its members
+type TeamResponse struct {
+	*models.Team
+	Members []*models.User `json:"members"`
+}
+
+// OrganizationService manages organizations and their users and teams.
+// Callers are authorized by AuthorizationService; the service enforces the
+// rules on roles themselves: only owners grant or revoke the owner role.
+// The repository keeps the last owner of an organization from being demoted
+// or removed.
+type OrganizationService struct {
+	orgRepo repositories.OrganizationRepository
+}
+
+// NewOrganizationService creates a new organization service
+func NewOrganizationService(orgRepo repositories.OrganizationRepository) *OrganizationService {
+	return &OrganizationService{
+		orgRepo: orgRepo,
+	}
+}
+
+// CreateOrganization creates an organization with its first owner
+func (s *OrganizationService) CreateOrganization(ctx context.Context, req *CreateOrganizationRequest) (*OrganizationResponse, error) {
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !organizationSlugPattern.MatchString(slug) {
+		return nil, fmt.Errorf("slug must contain only lowercase letters, digits and hyphens")
+	}
+	if _, err := s.orgRepo.GetBySlug(ctx, slug); err == nil {
+		return nil, fmt.Errorf("organization already exists")
+	} else if err.Error() != "organization not found" {
+		return nil, err
+	}
+	if err := s.checkEmailAvailable(ctx, req.OwnerEmail); err != nil {
+		return nil, err
+	}
+
+	org := &models.Organization{
+		Name: req.Name,
+		Slug: slug,
+	}
+	owner := &models.User{
+		Email: strings.ToLower(req.OwnerEmail),
+		Name:  req.OwnerName,
+	}
+	if err := s.orgRepo.Create(ctx, org, owner); err != nil {
+		return nil, err
+	}
+	return &OrganizationResponse{Organization: org, Owner: owner}, nil
+}
+
+// GetOrganization retrieves an organization
+func (s *OrganizationService) GetOrganization(ctx context.Context, id uuid.UUID) (*models.Organization, error) {
+	return s.orgRepo.GetByID(ctx, id)
+}
+
+// ListOrganizations lists every organization
+func (s *OrganizationService) ListOrganizations(ctx context.Context, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	return s.orgRepo.List(ctx, pagination)
+}
+
+// ListUsers lists the users of an organization
+func (s *OrganizationService) ListUsers(ctx context.Context, orgID uuid.UUID, pagination *repositories.PaginationParams) (*repositories.PaginatedResult, error) {
+	return s.orgRepo.ListUsers(ctx, orgID, pagination)
+}
+
+// CreateUser adds a user to an organization. The role defaults to viewer;
+// only an owner may add another owner.
+func (s *OrganizationService) CreateUser(ctx context.Context, orgID uuid.UUID, actor models.UserRole, req *CreateUserRequest) (*models.User, error) {
+	role := req.Role
+	if role == "" {
+		role = models.UserRoleViewer
+	}
+	if !role.IsValid() {
+		return nil, fmt.Errorf("role must be owner, admin, developer or viewer")
+	}
+	if role == models.UserRoleOwner && actor != models.UserRoleOwner {
+		return nil, fmt.Errorf("%w: only owners may grant the owner role", ErrForbidden)
+	}
+	if err := s.checkEmailAvailable(ctx, req.Email); err != nil {
+		return nil, err
+	}
+
+	user := &models.User{
+		OrganizationID: orgID,
+		Email:          strings.ToLower(req.Email),
+		Name:           req.Name,
+		Role:           role,
+	}
+	if err := s.orgRepo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateUser renames a user or changes their role. Only owners may change
+// the role of an owner or grant the owner role, and the last owner cannot be
+// demoted.
+func (s *OrganizationService) UpdateUser(ctx context.Context, actor models.UserRole, user *models.User, req *UpdateUserRequest) (*models.User, error) {
+	updated := *user
+	if req.Name != nil {
+		updated.Name = *req.Name
+	}
+	if req.Role != nil && *req.Role != user.Role {
+		if !req.Role.IsValid() {
+			return nil, fmt.Errorf("role must be owner, admin, developer or viewer")
+		}
+		if (*req.Role == models.UserRoleOwner || user.Role == models.UserRoleOwner) && actor != models.UserRoleOwner {
+			return nil, fmt.Errorf("%w: only owners may grant or revoke the owner role", ErrForbidden)
+		}
+		updated.Role = *req.Role
+	}
+
+	if err := s.orgRepo.UpdateUser(ctx, &updated); err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// DeleteUser removes a user from their organization. Only owners may remove
+// an owner, and the last owner cannot be removed.
+func (s *OrganizationService) DeleteUser(ctx context.Context, actor models.UserRole, user *models.User) error {
+	if user.Role == models.UserRoleOwner && actor != models.UserRoleOwner {
+		return fmt.Errorf("%w: only owners may remove an owner", ErrForbidden)
+	}
+	return s.orgRepo.DeleteUser(ctx, user.ID)
+}
+
+// ListTeams lists the teams of an organization
+func (s *OrganizationService) ListTeams(ctx context.Context, orgID uuid.UUID) ([]*models.Team, error) {
+	return s.orgRepo.ListTeams(ctx, orgID)
+}
+
+// CreateTeam creates a team in an organization
+func (s *OrganizationService) CreateTeam(ctx context.Context, orgID uuid.UUID, req *CreateTeamRequest) (*models.Team, error) {
+	teams, err := s.orgRepo.ListTeams(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, team := range teams {
+		if strings.EqualFold(team.Name, req.Name) {
+			return nil, fmt.Errorf("team already exists")
+		}
+	}
+
+	team := &models.Team{
+		OrganizationID: orgID,
+		Name:           req.Name,
+		Description:    req.Description,
+	}
+	if err := s.orgRepo.CreateTeam(ctx, team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+// GetTeam returns a team with its members
+func (s *OrganizationService) GetTeam(ctx context.Context, team *models.Team) (*TeamResponse, error) {
+	members, err := s.orgRepo.ListTeamMembers(ctx, team.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &TeamResponse{Team: team, Members: members}, nil
+}
+
+// DeleteTeam deletes a team. Its API keys keep working but are no longer
+// managed by the team's members.
+func (s *OrganizationService) DeleteTeam(ctx context.Context, team *models.Team) error {
+	return s.orgRepo.DeleteTeam(ctx, team.ID)
+}
+
+// AddTeamMember adds a user to a team of the same organization
+func (s *OrganizationService) AddTeamMember(ctx context.Context, team *models.Team, user *models.User) error {
+	if user.OrganizationID != team.OrganizationID {
+		return fmt.Errorf("user not found")
+	}
+	return s.orgRepo.AddTeamMember(ctx, &models.TeamMember{TeamID: team.ID, UserID: user.ID})
+}
+
+// RemoveTeamMember removes a user from a team
+func (s *OrganizationService) RemoveTeamMember(ctx context.Context, team *models.Team, userID uuid.UUID) error {
+	return s.orgRepo.RemoveTeamMember(ctx, team.ID, userID)
+}
+
+// checkEmailAvailable returns an error if a user already has the email
+func (s *OrganizationService) checkEmailAvailable(ctx context.Context, email string) error {
+	_, err := s.orgRepo.GetUserByEmail(ctx, strings.ToLower(email))
+	if err == nil {
+		return fmt.Errorf("user already exists")
+	}
+	if err.Error() != "user not found" {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+func TestOrganizationService_CreateOrganization(t *testing.T) {
+	orgRepo := newFakeOrganizationRepository()
+	service := NewOrganizationService(orgRepo)
+	ctx := context.Background()
+
+	org, err := service.CreateOrganization(ctx, &CreateOrganizationRequest{
+		Name:       "Acme",
+		Slug:       "Acme-Corp",
+		OwnerEmail: "Founder@Acme.test",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "acme-corp", org.Slug)
+	assert.Equal(t, "founder@acme.test", org.Owner.Email)
+	assert.Equal(t, models.UserRoleOwner, org.Owner.Role)
+	assert.Equal(t, org.ID, org.Owner.OrganizationID)
+
+	_, err = service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Acme", Slug: "acme-corp", OwnerEmail: "other@acme.test"})
+	assert.EqualError(t, err, "organization already exists")
+
+	_, err = service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Acme", Slug: "acme-2", OwnerEmail: "founder@acme.test"})
+	assert.EqualError(t, err, "user already exists")
+
+	_, err = service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Acme", Slug: "acme corp", OwnerEmail: "x@acme.test"})
+	assert.EqualError(t, err, "slug must contain only lowercase letters, digits and hyphens")
+}
+
+func TestOrganizationService_Roles(t *testing.T) {
+	orgRepo := newFakeOrganizationRepository()
+	service := NewOrganizationService(orgRepo)
+	ctx := context.Background()
+
+	org, err := service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Acme", Slug: "acme", OwnerEmail: "owner@acme.test"})
+	require.NoError(t, err)
+	owner := org.Owner
+
+	// New users default to viewer; only owners add owners
+	viewer, err := service.CreateUser(ctx, org.ID, models.UserRoleAdmin, &CreateUserRequest{Email: "viewer@acme.test"})
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleViewer, viewer.Role)
+
+	_, err = service.CreateUser(ctx, org.ID, models.UserRoleAdmin, &CreateUserRequest{Email: "owner2@acme.test", Role: models.UserRoleOwner})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = service.CreateUser(ctx, org.ID, models.UserRoleAdmin, &CreateUserRequest{Email: "x@acme.test", Role: "superuser"})
+	assert.EqualError(t, err, "role must be owner, admin, developer or viewer")
+
+	developer := models.UserRoleDeveloper
+	viewer, err = service.UpdateUser(ctx, models.UserRoleAdmin, viewer, &UpdateUserRequest{Role: &developer})
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleDeveloper, viewer.Role)
+
+	// Admins cannot touch owners, and the last owner stays
+	admin := models.UserRoleAdmin
+	_, err = service.UpdateUser(ctx, models.UserRoleAdmin, owner, &UpdateUserRequest{Role: &admin})
+	assert.ErrorIs(t, err, ErrForbidden)
+	assert.ErrorIs(t, service.DeleteUser(ctx, models.UserRoleAdmin, owner), ErrForbidden)
+
+	_, err = service.UpdateUser(ctx, models.UserRoleOwner, owner, &UpdateUserRequest{Role: &admin})
+	assert.EqualError(t, err, "organization must keep at least one owner")
+	assert.Equal(t, models.UserRoleOwner, owner.Role)
+	assert.EqualError(t, service.DeleteUser(ctx, models.UserRoleOwner, owner), "organization must keep at least one owner")
+
+	// With a second owner the first can step down
+	ownerRole := models.UserRoleOwner
+	_, err = service.UpdateUser(ctx, models.UserRoleOwner, viewer, &UpdateUserRequest{Role: &ownerRole})
+	require.NoError(t, err)
+	owner, err = service.UpdateUser(ctx, models.UserRoleOwner, owner, &UpdateUserRequest{Role: &admin})
+	require.NoError(t, err)
+	assert.Equal(t, models.UserRoleAdmin, owner.Role)
+}
+
+func TestOrganizationService_CreateTeam(t *testing.T) {
+	orgRepo := newFakeOrganizationRepository()
+	service := NewOrganizationService(orgRepo)
+	ctx := context.Background()
+
+	org, err := service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Acme", Slug: "acme", OwnerEmail: "owner@acme.test"})
+	require.NoError(t, err)
+
+	team, err := service.CreateTeam(ctx, org.ID, &CreateTeamRequest{Name: "Payments"})
+	require.NoError(t, err)
+	assert.Equal(t, org.ID, team.OrganizationID)
+
+	_, err = service.CreateTeam(ctx, org.ID, &CreateTeamRequest{Name: "payments"})
+	assert.EqualError(t, err, "team already exists")
+
+	// Users of another organization cannot join
+	other, err := service.CreateOrganization(ctx, &CreateOrganizationRequest{Name: "Other", Slug: "other", OwnerEmail: "owner@other.test"})
+	require.NoError(t, err)
+	assert.EqualError(t, service.AddTeamMember(ctx, team, other.Owner), "user not found")
+	require.NoError(t, service.AddTeamMember(ctx, team, org.Owner))
+
+	member, err := orgRepo.IsTeamMember(ctx, team.ID, org.Owner.ID)
+	require.NoError(t, err)
+	assert.True(t, member)
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// Partition retention actions
+const (
+	PartitionRetentionDrop    = "drop"
+	PartitionRetentionArchive = "archive"
+)
+
+// Partition health states, from best to worst
+const (
+	PartitionStatusHealthy       = "healthy"
+	PartitionStatusUnpartitioned = "unpartitioned"
+	PartitionStatusDegraded      = "degraded"
+	PartitionStatusUnhealthy     = "unhealthy"
+)
+
+// PartitionPolicy configures monthly partition maintenance. Partitions are
+// created from the current month through PremakeMonths ahead. Once a partition
+// ends more than the table's retention period ago it is detached and dropped,
+// or moved to ArchiveSchema when RetentionAction is "archive". Only tables
+// listed in RetentionDays are managed.
+type PartitionPolicy struct {
+	Enabled         bool           `json:"enabled"`
+	PremakeMonths   int            `json:"premake_months"`
+	RetentionAction string         `json:"retention_action"`
+	ArchiveSchema   string         `json:"archive_schema"`
+	RetentionDays   map[string]int `json:"retention_days"`
+}
+
+// DefaultPartitionPolicy returns the default partition configuration
+func DefaultPartitionPolicy() PartitionPolicy {
+	return PartitionPolicy{
+		Enabled:         true,
+		PremakeMonths:   3,
+		RetentionAction: PartitionRetentionDrop,
+		ArchiveSchema:   "archive",
+		RetentionDays: map[string]int{
+			"usage_logs":            90,
+			"rate_limit_violations": 180,
+		},
+	}
+}
+
+// withDefaults fills unset fields from DefaultPartitionPolicy
+func (p PartitionPolicy) withDefaults() PartitionPolicy {
+	defaults := DefaultPartitionPolicy()
+	if p.PremakeMonths <= 0 {
+		p.PremakeMonths = defaults.PremakeMonths
+	}
+	if p.RetentionAction != PartitionRetentionArchive {
+		p.RetentionAction = PartitionRetentionDrop
+	}
+	if p.ArchiveSchema == "" {
+		p.ArchiveSchema = defaults.ArchiveSchema
+	}
+	if len(p.RetentionDays) == 0 {
+		p.RetentionDays = defaults.RetentionDays
+	}
+	return p
+}
+
+// PartitionManager defines the interface for maintaining monthly partitions
+type PartitionManager interface {
+	Maintain(ctx context.Context) (*PartitionMaintenanceResult, error)
+	Status(ctx context.Context) (*PartitionStatus, error)
+	ManagesRetention(ctx context.Context, table string) (bool, error)
+}
+
+// PartitionMaintenanceResult contains the outcome of a maintenance run
+type PartitionMaintenanceResult struct {
+	Created  []string `json:"created"`
+	Dropped  []string `json:"dropped"`
+	Archived []string `json:"archived"`
+	Skipped  []string `json:"skipped"` // managed tables that are not partitioned
+}
+
+// PartitionStatus reports the partition state of every managed table
+type PartitionStatus struct {
+	Status    string                  `json:"status"`
+	CheckedAt time.Time               `json:"checked_at"`
+	Tables    []*TablePartitionStatus `json:"tables"`
+}
+
+// TablePartitionStatus reports the partition state of one table.
+// MissingMonths lists months (YYYY-MM) between the current month and the
+// premake horizon that have no partition; a missing current or next month
+// makes the table unhealthy because inserts for it will fail.
+type TablePartitionStatus struct {
+	Table         string              `json:"table"`
+	Status        string              `json:"status"`
+	RetentionDays int                 `json:"retention_days"`
+	CoveredUntil  *time.Time          `json:"covered_until,omitempty"`
+	MissingMonths []string            `json:"missing_months,omitempty"`
+	Expired       []string            `json:"expired,omitempty"`
+	Partitions    []*MonthlyPartition `json:"partitions"`
+}
+
+// MonthlyPartition describes a partition and the month it covers. From and To
+// are nil for partitions not named <table>_yYYYYmMM, such as a default partition.
+type MonthlyPartition struct {
+	*repositories.PartitionInfo
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+}
+
+// partitionManager implements PartitionManager interface
+type partitionManager struct {
+	partitionRepo repositories.PartitionRepository
+	policy        PartitionPolicy
+	clock         ratelimit.Clock
+}
+
+// NewPartitionManager creates a new partition manager
+func NewPartitionManager(
+	partitionRepo repositories.PartitionRepository,
+	policy PartitionPolicy,
+	clock ratelimit.Clock,
+) PartitionManager {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &partitionManager{
+		partitionRepo: partitionRepo,
+		policy:        policy.withDefaults(),
+		clock:         clock,
+	}
+}
+
+// Maintain creates upcoming monthly partitions and retires expired ones for
+// every managed table. A failure on one table does not stop the others.
+func (m *partitionManager) Maintain(ctx context.Context) (*PartitionMaintenanceResult, error) {
+	result := &PartitionMaintenanceResult{}
+	if !m.policy.Enabled {
+		return result, nil
+	}
+
+	now := m.clock.Now()
+	var errs []error
+	for _, table := range m.tables() {
+		partitioned, err := m.partitionRepo.IsPartitioned(ctx, table)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !partitioned {
+			result.Skipped = append(result.Skipped, table)
+			continue
+		}
+
+		partitions, err := m.listMonthlyPartitions(ctx, table)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		existing := make(map[string]bool, len(partitions))
+		for _, partition := range partitions {
+			existing[partition.Name] = true
+		}
+
+		for _, month := range m.premakeMonths(now) {
+			name := partitionName(table, month)
+			if existing[name] {
+				continue
+			}
+			if err := m.partitionRepo.CreatePartition(ctx, table, name, month, month.AddDate(0, 1, 0)); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Created = append(result.Created, name)
+		}
+
+		for _, partition := range m.expiredPartitions(table, partitions, now) {
+			if m.policy.RetentionAction == PartitionRetentionArchive {
+				if err := m.partitionRepo.ArchivePartition(ctx, table, partition.Name, m.policy.ArchiveSchema); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				result.Archived = append(result.Archived, partition.Name)
+				continue
+			}
+
+			if err := m.partitionRepo.DropPartition(ctx, table, partition.Name); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			result.Dropped = append(result.Dropped, partition.Name)
+		}
+	}
+
+	return result, errors.Join(errs...)
+}
+
+// Status reports partition coverage and pending retention for every managed table
+func (m *partitionManager) Status(ctx context.Context) (*PartitionStatus, error) {
+	now := m.clock.Now()
+	status := &PartitionStatus{
+		Status:    PartitionStatusHealthy,
+		CheckedAt: now,
+	}
+
+	for _, table := range m.tables() {
+		tableStatus, err := m.tableStatus(ctx, table, now)
+		if err != nil {
+			return nil, err
+		}
+		status.Tables = append(status.Tables, tableStatus)
+		status.Status = worsePartitionStatus(status.Status, tableStatus.Status)
+	}
+
+	return status, nil
+}
+
+// ManagesRetention returns true if expired rows of table are removed by
+// retiring partitions, so row-by-row cleanup is unnecessary
+func (m *partitionManager) ManagesRetention(ctx context.Context, table string) (bool, error) {
+	if _, ok := m.policy.RetentionDays[table]; !ok || !m.policy.Enabled {
+		return false, nil
+	}
+	return m.partitionRepo.IsPartitioned(ctx, table)
+}
+
+// tableStatus builds the partition status of one table
+func (m *partitionManager) tableStatus(ctx context.Context, table string, now time.Time) (*TablePartitionStatus, error) {
+	status := &TablePartitionStatus{
+		Table:         table,
+		Status:        PartitionStatusHealthy,
+		RetentionDays: m.policy.RetentionDays[table],
+		Partitions:    []*MonthlyPartition{},
+	}
+
+	partitioned, err := m.partitionRepo.IsPartitioned(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	if !partitioned {
+		status.Status = PartitionStatusUnpartitioned
+		return status, nil
+	}
+
+	partitions, err := m.listMonthlyPartitions(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+	status.Partitions = partitions
+
+	covered := make(map[time.Time]bool, len(partitions))
+	for _, partition := range partitions {
+		if partition.From != nil {
+			covered[*partition.From] = true
+		}
+	}
+
+	contiguous := true
+	for i, month := range m.premakeMonths(now) {
+		if covered[month] {
+			if contiguous {
+				until := month.AddDate(0, 1, 0)
+				status.CoveredUntil = &until
+			}
+			continue
+		}
+
+		contiguous = false
+		status.MissingMonths = append(status.MissingMonths, month.Format("2006-01"))
+		if i <= 1 {
+			status.Status = PartitionStatusUnhealthy
+		} else {
+			status.Status = worsePartitionStatus(status.Status, PartitionStatusDegraded)
+		}
+	}
+
+	for _, partition := range m.expiredPartitions(table, partitions, now) {
+		status.Expired = append(status.Expired, partition.Name)
+		status.Status = worsePartitionStatus(status.Status, PartitionStatusDegraded)
+	}
+
+	return status, nil
+}
+
+// listMonthlyPartitions lists the partitions of table with the month each covers
+func (m *partitionManager) listMonthlyPartitions(ctx context.Context, table string) ([]*MonthlyPartition, error) {
+	infos, err := m.partitionRepo.ListPartitions(ctx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	partitions := make([]*MonthlyPartition, 0, len(infos))
+	for _, info := range infos {
+		partition := &MonthlyPartition{PartitionInfo: info}
+		if from, ok := parsePartitionMonth(table, info.Name); ok {
+			to := from.AddDate(0, 1, 0)
+			partition.From = &from
+			partition.To = &to
+		}
+		partitions = append(partitions, partition)
+	}
+	return partitions, nil
+}
+
+// expiredPartitions returns the monthly partitions of table whose every row is
+// past the retention period
+func (m *partitionManager) expiredPartitions(table string, partitions []*MonthlyPartition, now time.Time) []*MonthlyPartition {
+	retentionDays, ok := m.policy.RetentionDays[table]
+	if !ok || retentionDays <= 0 {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -retentionDays)
+	var expired []*MonthlyPartition
+	for _, partition := range partitions {
+		if partition.To != nil && !partition.To.After(cutoff) {
+			expired = append(expired, partition)
+		}
+	}
+	return expired
+}
+
+// premakeMonths returns the first instant of the current month and each of the
+// following PremakeMonths months
+func (m *partitionManager) premakeMonths(now time.Time) []time.Time {
+	now = now.UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	months := make([]time.Time, 0, m.policy.PremakeMonths+1)
+	for i := 0; i <= m.policy.PremakeMonths; i++ {
+		months = append(months, current.AddDate(0, i, 0))
+	}
+	return months
+}
+
+// tables returns the managed tables in a stable order
+func (m *partitionManager) tables() []string {
+	tables := make([]string, 0, len(m.policy.RetentionDays))
+	for table := range m.policy.RetentionDays {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// partitionName returns the name of the partition of table covering month,
+// following the <table>_yYYYYmMM convention of the initial schema
+func partitionName(table string, month time.Time) string {
+	return fmt.Sprintf("%s_y%04dm%02d", table, month.Year(), int(month.Month()))
+}
+
+var partitionSuffixPattern = regexp.MustCompile(`_y(\d{4})m(\d{2})$`)
+
+// parsePartitionMonth returns the month covered by a partition of table named
+// by partitionName
+func parsePartitionMonth(table, name string) (time.Time, bool) {
+	matches := partitionSuffixPattern.FindStringSubmatch(name)
+	if matches == nil || name[:len(name)-len(matches[0])] != table {
+		return time.Time{}, false
+	}
+
+	year, _ := strconv.Atoi(matches[1])
+	month, _ := strconv.Atoi(matches[2])
+	if month < 1 || month > 12 {
+		return time.Time{}, false
+	}
+	return time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// partitionStatusRank orders partition health states from best to worst
+var partitionStatusRank = map[string]int{
+	PartitionStatusHealthy:       0,
+	PartitionStatusUnpartitioned: 1,
+	PartitionStatusDegraded:      2,
+	PartitionStatusUnhealthy:     3,
+}
+
+// worsePartitionStatus returns the worse of two partition health states
+func worsePartitionStatus(a, b string) string {
+	if partitionStatusRank[b] > partitionStatusRank[a] {
+		return b
+	}
+	return a
+}
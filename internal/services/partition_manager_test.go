@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakePartitionRepository keeps partitioned tables and their partitions in memory
+type fakePartitionRepository struct {
+	partitions map[string][]string // partitioned table -> partition names
+	archived   map[string]string   // partition -> schema
+	dropped    []string
+}
+
+func newFakePartitionRepository() *fakePartitionRepository {
+	return &fakePartitionRepository{
+		partitions: make(map[string][]string),
+		archived:   make(map[string]string),
+	}
+}
+
+func (r *fakePartitionRepository) IsPartitioned(ctx context.Context, table string) (bool, error) {
+	_, ok := r.partitions[table]
+	return ok, nil
+}
+
+func (r *fakePartitionRepository) ListPartitions(ctx context.Context, table string) ([]*repositories.PartitionInfo, error) {
+	names := append([]string(nil), r.partitions[table]...)
+	sort.Strings(names)
+
+	infos := make([]*repositories.PartitionInfo, 0, len(names))
+	for _, name := range names {
+		infos = append(infos, &repositories.PartitionInfo{Name: name})
+	}
+	return infos, nil
+}
+
+func (r *fakePartitionRepository) CreatePartition(ctx context.Context, table, partition string, from, to time.Time) error {
+	r.partitions[table] = append(r.partitions[table], partition)
+	return nil
+}
+
+func (r *fakePartitionRepository) DropPartition(ctx context.Context, table, partition string) error {
+	r.detach(table, partition)
+	r.dropped = append(r.dropped, partition)
+	return nil
+}
+
+func (r *fakePartitionRepository) ArchivePartition(ctx context.Context, table, partition, schema string) error {
+	r.detach(table, partition)
+	r.archived[partition] = schema
+	return nil
+}
+
+func (r *fakePartitionRepository) detach(table, partition string) {
+	names := r.partitions[table][:0]
+	for _, name := range r.partitions[table] {
+		if name != partition {
+			names = append(names, name)
+		}
+	}
+	r.partitions[table] = names
+}
+
+func TestPartitionManager_Maintain(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakePartitionRepository()
+	repo.partitions["usage_logs"] = []string{
+		"usage_logs_y2024m09",
+		"usage_logs_y2024m10",
+		"usage_logs_y2024m11",
+		"usage_logs_y2024m12",
+		"usage_logs_y2025m01",
+		"usage_logs_default",
+	}
+
+	manager := NewPartitionManager(repo, PartitionPolicy{
+		Enabled:       true,
+		PremakeMonths: 2,
+		RetentionDays: map[string]int{
+			"usage_logs":            90,
+			"rate_limit_violations": 180,
+		},
+	}, ratelimit.NewFakeClock(now))
+
+	result, err := manager.Maintain(ctx)
+	require.NoError(t, err)
+
+	// Only partitions ending on or before 2024-10-17 are past retention
+	assert.Equal(t, []string{"usage_logs_y2025m02", "usage_logs_y2025m03"}, result.Created)
+	assert.Equal(t, []string{"usage_logs_y2024m09"}, result.Dropped)
+	assert.Empty(t, result.Archived)
+	assert.Equal(t, []string{"rate_limit_violations"}, result.Skipped)
+
+	// A second run has nothing to do
+	result, err = manager.Maintain(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, result.Dropped)
+}
+
+func TestPartitionManager_MaintainArchives(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	repo := newFakePartitionRepository()
+	repo.partitions["rate_limit_violations"] = []string{
+		"rate_limit_violations_y2024m08",
+		"rate_limit_violations_y2024m09",
+	}
+
+	manager := NewPartitionManager(repo, PartitionPolicy{
+		Enabled:         true,
+		PremakeMonths:   1,
+		RetentionAction: PartitionRetentionArchive,
+		ArchiveSchema:   "cold",
+		RetentionDays:   map[string]int{"rate_limit_violations": 180},
+	}, ratelimit.NewFakeClock(now))
+
+	result, err := manager.Maintain(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"rate_limit_violations_y2024m08"}, result.Archived)
+	assert.Equal(t, map[string]string{"rate_limit_violations_y2024m08": "cold"}, repo.archived)
+	assert.Empty(t, repo.dropped)
+	assert.Equal(t, []string{"rate_limit_violations_y2025m03", "rate_limit_violations_y2025m04"}, result.Created)
+}
+
+func TestPartitionManager_MaintainDisabled(t *testing.T) {
+	repo := newFakePartitionRepository()
+	repo.partitions["usage_logs"] = nil
+
+	manager := NewPartitionManager(repo, PartitionPolicy{
+		RetentionDays: map[string]int{"usage_logs": 90},
+	}, ratelimit.NewFakeClock(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	result, err := manager.Maintain(context.Background())
+	require.NoError(t, err)
+	assert.Empty(t, result.Created)
+	assert.Empty(t, repo.partitions["usage_logs"])
+
+	manages, err := manager.ManagesRetention(context.Background(), "usage_logs")
+	require.NoError(t, err)
+	assert.False(t, manages)
+}
+
+func TestPartitionManager_Status(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2025, 1, 15, 12, 0, 0, 0, time.UTC)
+	policy := PartitionPolicy{
+		Enabled:       true,
+		PremakeMonths: 3,
+		RetentionDays: map[string]int{"usage_logs": 90},
+	}
+
+	tests := []struct {
+		name         string
+		partitions   []string
+		partitioned  bool
+		wantStatus   string
+		wantCovered  *time.Time
+		wantMissing  []string
+		wantExpired  []string
+		wantManaging bool
+	}{
+		{
+			name: "fully covered",
+			partitions: []string{
+				"usage_logs_y2025m01", "usage_logs_y2025m02", "usage_logs_y2025m03", "usage_logs_y2025m04",
+			},
+			partitioned:  true,
+			wantStatus:   PartitionStatusHealthy,
+			wantCovered:  timePtr(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)),
+			wantManaging: true,
+		},
+		{
+			name:         "premake horizon short",
+			partitions:   []string{"usage_logs_y2025m01", "usage_logs_y2025m02", "usage_logs_y2025m04"},
+			partitioned:  true,
+			wantStatus:   PartitionStatusDegraded,
+			wantCovered:  timePtr(time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)),
+			wantMissing:  []string{"2025-03"},
+			wantManaging: true,
+		},
+		{
+			name:         "schema hardcoded to 2024",
+			partitions:   []string{"usage_logs_y2024m11", "usage_logs_y2024m12"},
+			partitioned:  true,
+			wantStatus:   PartitionStatusUnhealthy,
+			wantMissing:  []string{"2025-01", "2025-02", "2025-03", "2025-04"},
+			wantManaging: true,
+		},
+		{
+			name: "expired partitions still attached",
+			partitions: []string{
+				"usage_logs_y2024m09", "usage_logs_y2025m01", "usage_logs_y2025m02", "usage_logs_y2025m03", "usage_logs_y2025m04",
+			},
+			partitioned:  true,
+			wantStatus:   PartitionStatusDegraded,
+			wantCovered:  timePtr(time.Date(2025, 5, 1, 0, 0, 0, 0, time.UTC)),
+			wantExpired:  []string{"usage_logs_y2024m09"},
+			wantManaging: true,
+		},
+		{
+			name:        "plain table",
+			partitioned: false,
+			wantStatus:  PartitionStatusUnpartitioned,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newFakePartitionRepository()
+			if tt.partitioned {
+				repo.partitions["usage_logs"] = tt.partitions
+			}
+			manager := NewPartitionManager(repo, policy, ratelimit.NewFakeClock(now))
+
+			status, err := manager.Status(ctx)
+			require.NoError(t, err)
+			require.Len(t, status.Tables, 1)
+
+			table := status.Tables[0]
+			assert.Equal(t, tt.wantStatus, status.Status)
+			assert.Equal(t, tt.wantStatus, table.Status)
+			assert.Equal(t, tt.wantCovered, table.CoveredUntil)
+			assert.Equal(t, tt.wantMissing, table.MissingMonths)
+			assert.Equal(t, tt.wantExpired, table.Expired)
+			assert.Len(t, table.Partitions, len(tt.partitions))
+
+			manages, err := manager.ManagesRetention(ctx, "usage_logs")
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantManaging, manages)
+		})
+	}
+}
+
+func TestParsePartitionMonth(t *testing.T) {
+	month, ok := parsePartitionMonth("usage_logs", "usage_logs_y2025m02")
+	require.True(t, ok)
+	assert.Equal(t, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "usage_logs_y2025m02", partitionName("usage_logs", month))
+
+	for _, name := range []string{"usage_logs_default", "usage_logs_y2025m13", "other_usage_logs_y2025m02"} {
+		_, ok := parsePartitionMonth("usage_logs", name)
+		assert.False(t, ok, name)
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
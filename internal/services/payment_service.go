@@ -0,0 +1,420 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/invoice"
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// ErrPaymentsDisabled is returned when no payment provider is configured
+var ErrPaymentsDisabled = errors.New("payments are not configured")
+
+// DunningPolicy configures the handling of unpaid invoices. Invoices are due
+// PaymentTermsDays after their period ends. API keys are suspended once an
+// invoice is SuspendAfterDays past due; zero never suspends.
+type DunningPolicy struct {
+	PaymentTermsDays int
+	SuspendAfterDays int
+}
+
+// DunningResult reports a dunning run
+type DunningResult struct {
+	Overdue    int `json:"overdue"`
+	Suspended  int `json:"suspended"`
+	Reinstated int `json:"reinstated"`
+}
+
+// PaymentService collects billing record payments through a payment provider
+// and suspends API keys whose invoices stay unpaid
+type PaymentService struct {
+	billingRepo repositories.BillingRecordRepository
+	paymentRepo repositories.PaymentRepository
+	apiKeyRepo  repositories.APIKeyRepository
+	provider    payment.Provider
+	policy      DunningPolicy
+	clock       ratelimit.Clock
+}
+
+// NewPaymentService creates a new payment service. A nil provider disables
+// charges, refunds and webhooks; dunning still runs.
+func NewPaymentService(
+	billingRepo repositories.BillingRecordRepository,
+	paymentRepo repositories.PaymentRepository,
+	apiKeyRepo repositories.APIKeyRepository,
+	provider payment.Provider,
+	policy DunningPolicy,
+	clock ratelimit.Clock,
+) *PaymentService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &PaymentService{
+		billingRepo: billingRepo,
+		paymentRepo: paymentRepo,
+		apiKeyRepo:  apiKeyRepo,
+		provider:    provider,
+		policy:      policy,
+		clock:       clock,
+	}
+}
+
+// ChargeBillingRecord charges the invoice of an unpaid billing record to its
+// API key's customer, creating the customer first if needed. Records with
+// nothing to pay are marked paid without a charge. A declined charge marks
+// the record failed; a pending one is settled by a later webhook.
+func (s *PaymentService) ChargeBillingRecord(ctx context.Context, recordID uuid.UUID) (*models.BillingRecord, error) {
+	if s.provider == nil {
+		return nil, ErrPaymentsDisabled
+	}
+
+	record, err := s.billingRepo.GetByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if !record.PaymentStatus.IsUnpaid() {
+		return nil, fmt.Errorf("billing record is already settled")
+	}
+
+	if roundAmount(record.TotalAmount) <= 0 {
+		return s.settle(ctx, record, models.PaymentStatusPaid, "", "")
+	}
+
+	customerID, err := s.customerID(ctx, record.APIKeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Retrying after a failed charge needs a new key, but retrying after an
+	// error that left no charge must not charge twice
+	charge, err := s.provider.ChargeInvoice(ctx, &payment.ChargeRequest{
+		CustomerID:     customerID,
+		RecordID:       record.ID,
+		InvoiceNumber:  invoice.Number(record),
+		Amount:         roundAmount(record.TotalAmount),
+		Currency:       record.Currency,
+		IdempotencyKey: fmt.Sprintf("invoice-%s-%s", record.ID, record.PaymentChargeID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch charge.Status {
+	case payment.ChargeStatusSucceeded:
+		return s.settle(ctx, record, models.PaymentStatusPaid, charge.ID, "")
+	case payment.ChargeStatusFailed:
+		return s.settle(ctx, record, models.PaymentStatusFailed, charge.ID, charge.FailureReason)
+	}
+
+	record.PaymentProvider = s.provider.Name()
+	record.PaymentChargeID = charge.ID
+	if _, err := s.billingRepo.UpdatePayment(ctx, record, []models.PaymentStatus{record.PaymentStatus}); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// RefundBillingRecord refunds an amount of a paid billing record's charge.
+// A zero amount refunds the whole charge, which marks the record refunded.
+func (s *PaymentService) RefundBillingRecord(ctx context.Context, recordID uuid.UUID, amount float64) (*models.BillingRecord, error) {
+	if s.provider == nil {
+		return nil, ErrPaymentsDisabled
+	}
+
+	record, err := s.billingRepo.GetByID(ctx, recordID)
+	if err != nil {
+		return nil, err
+	}
+	if record.PaymentStatus != models.PaymentStatusPaid {
+		return nil, fmt.Errorf("billing record is not paid")
+	}
+	if record.PaymentChargeID == "" || record.PaymentProvider != s.provider.Name() {
+		return nil, fmt.Errorf("billing record has no provider charge")
+	}
+
+	total := roundAmount(record.TotalAmount)
+	if amount == 0 {
+		amount = total
+	}
+	amount = roundAmount(amount)
+	if amount <= 0 || amount > total {
+		return nil, fmt.Errorf("invalid refund amount")
+	}
+
+	if _, err := s.provider.Refund(ctx, record.PaymentChargeID, amount, record.Currency); err != nil {
+		return nil, err
+	}
+	if amount < total {
+		return record, nil
+	}
+	return s.settle(ctx, record, models.PaymentStatusRefunded, record.PaymentChargeID, "")
+}
+
+// HandleWebhook verifies and applies a payment provider webhook. Every event
+// is applied once; redeliveries and events about unknown charges are
+// acknowledged without effect. It returns true if the event changed a
+// billing record.
+func (s *PaymentService) HandleWebhook(ctx context.Context, payload []byte, header http.Header) (bool, error) {
+	if s.provider == nil {
+		return false, ErrPaymentsDisabled
+	}
+
+	event, err := s.provider.ParseWebhook(payload, header)
+	if err != nil {
+		return false, err
+	}
+
+	seen, err := s.paymentRepo.HasEvent(ctx, s.provider.Name(), event.ID)
+	if err != nil || seen {
+		return false, err
+	}
+
+	record, err := s.eventRecord(ctx, event)
+	if err != nil {
+		return false, err
+	}
+
+	applied := false
+	if record != nil {
+		before := record.PaymentStatus
+		switch event.Type {
+		case payment.EventChargeSucceeded:
+			record, err = s.settle(ctx, record, models.PaymentStatusPaid, event.ChargeID, "")
+		case payment.EventChargeFailed:
+			record, err = s.settle(ctx, record, models.PaymentStatusFailed, event.ChargeID, event.FailureReason)
+		case payment.EventChargeRefunded:
+			if event.FullyRefunded() {
+				record, err = s.settle(ctx, record, models.PaymentStatusRefunded, event.ChargeID, "")
+			}
+		}
+		if err != nil {
+			return false, err
+		}
+		applied = record.PaymentStatus != before
+	}
+
+	// Recorded last, so an event that failed to apply is applied on redelivery
+	logged := &models.PaymentEvent{
+		Provider:   s.provider.Name(),
+		EventID:    event.ID,
+		Type:       string(event.Type),
+		ChargeID:   event.ChargeID,
+		ReceivedAt: s.clock.Now(),
+	}
+	if record != nil {
+		logged.BillingRecordID = &record.ID
+	}
+	if _, err := s.paymentRepo.RecordEvent(ctx, logged); err != nil {
+		return applied, err
+	}
+	return applied, nil
+}
+
+// RunDunning marks pending invoices past their due date overdue, suspends
+// the API keys of invoices unpaid SuspendAfterDays past due, and reinstates
+// keys it suspended whose invoices have since been settled
+func (s *PaymentService) RunDunning(ctx context.Context) (*DunningResult, error) {
+	result := &DunningResult{}
+
+	overdue, err := s.billingRepo.GetOverdueRecords(ctx, s.policy.PaymentTermsDays)
+	if err != nil {
+		return nil, err
+	}
+	result.Overdue = len(overdue)
+
+	delinquent := make(map[uuid.UUID]bool)
+	if s.policy.SuspendAfterDays > 0 {
+		records, err := s.billingRepo.GetUnpaidEndedBefore(ctx, s.suspensionCutoff())
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []uuid.UUID
+		for _, record := range records {
+			if !delinquent[record.APIKeyID] {
+				delinquent[record.APIKeyID] = true
+				ids = append(ids, record.APIKeyID)
+			}
+		}
+		suspended, err := s.apiKeyRepo.Suspend(ctx, ids, models.SuspendedReasonBilling)
+		if err != nil {
+			return nil, err
+		}
+		result.Suspended = int(suspended)
+	}
+
+	suspended, err := s.apiKeyRepo.GetSuspended(ctx, models.SuspendedReasonBilling)
+	if err != nil {
+		return nil, err
+	}
+	var settled []uuid.UUID
+	for _, apiKey := range suspended {
+		if !delinquent[apiKey.ID] {
+			settled = append(settled, apiKey.ID)
+		}
+	}
+	reinstated, err := s.apiKeyRepo.Reinstate(ctx, settled, models.SuspendedReasonBilling)
+	if err != nil {
+		return nil, err
+	}
+	result.Reinstated = int(reinstated)
+
+	return result, nil
+}
+
+// ReinstateIfSettled reactivates an API key suspended by dunning once none of
+// its invoices is unpaid beyond the suspension threshold. It returns true if
+// the key was reactivated.
+func (s *PaymentService) ReinstateIfSettled(ctx context.Context, apiKeyID uuid.UUID) (bool, error) {
+	if s.policy.SuspendAfterDays > 0 {
+		unpaid, err := s.billingRepo.GetUnpaidRecords(ctx, apiKeyID)
+		if err != nil {
+			return false, err
+		}
+		cutoff := s.suspensionCutoff()
+		for _, record := range unpaid {
+			if record.PeriodEnd.Before(cutoff) {
+				return false, nil
+			}
+		}
+	}
+
+	reinstated, err := s.apiKeyRepo.Reinstate(ctx, []uuid.UUID{apiKeyID}, models.SuspendedReasonBilling)
+	if err != nil {
+		return false, err
+	}
+	return reinstated > 0, nil
+}
+
+// suspensionCutoff returns the period end before which unpaid invoices get
+// their API keys suspended
+func (s *PaymentService) suspensionCutoff() time.Time {
+	return s.clock.Now().AddDate(0, 0, -(s.policy.PaymentTermsDays + s.policy.SuspendAfterDays))
+}
+
+// settle moves a billing record to a payment status reached through a
+// charge. Transitions are one-way: a paid record only becomes refunded, a
+// refunded one never changes, and a failure never overrides a payment. A
+// record already in the status is returned unchanged, so repeated outcomes
+// are harmless.
+func (s *PaymentService) settle(ctx context.Context, record *models.BillingRecord, status models.PaymentStatus, chargeID, failureReason string) (*models.BillingRecord, error) {
+	var from []models.PaymentStatus
+	switch status {
+	case models.PaymentStatusPaid:
+		from = []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusOverdue, models.PaymentStatusFailed}
+	case models.PaymentStatusFailed:
+		// A failed record only takes the reason of a newer failed charge
+		from = []models.PaymentStatus{models.PaymentStatusPending, models.PaymentStatusOverdue}
+		if record.PaymentStatus == models.PaymentStatusFailed && record.PaymentChargeID != chargeID {
+			from = append(from, models.PaymentStatusFailed)
+		}
+	case models.PaymentStatusRefunded:
+		from = []models.PaymentStatus{models.PaymentStatusPaid}
+	default:
+		return nil, fmt.Errorf("payment status %s is not reached through a charge", status)
+	}
+
+	allowed := false
+	for _, candidate := range from {
+		allowed = allowed || record.PaymentStatus == candidate
+	}
+	if !allowed {
+		return record, nil
+	}
+
+	updated := *record
+	updated.PaymentStatus = status
+	updated.PaymentFailureReason = failureReason
+	if chargeID != "" {
+		updated.PaymentProvider = s.provider.Name()
+		updated.PaymentChargeID = chargeID
+	}
+	if status == models.PaymentStatusPaid {
+		now := s.clock.Now()
+		updated.PaidAt = &now
+	}
+
+	ok, err := s.billingRepo.UpdatePayment(ctx, &updated, []models.PaymentStatus{record.PaymentStatus})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Another update won; report the record as it is now
+		return s.billingRepo.GetByID(ctx, record.ID)
+	}
+
+	if status == models.PaymentStatusPaid {
+		if _, err := s.ReinstateIfSettled(ctx, updated.APIKeyID); err != nil {
+			return nil, err
+		}
+	}
+	return &updated, nil
+}
+
+// customerID returns the provider customer of an API key, creating it on
+// first use
+func (s *PaymentService) customerID(ctx context.Context, apiKeyID uuid.UUID) (string, error) {
+	customer, err := s.paymentRepo.GetCustomer(ctx, apiKeyID, s.provider.Name())
+	if err == nil {
+		return customer.CustomerID, nil
+	}
+	if err.Error() != "payment customer not found" {
+		return "", err
+	}
+
+	apiKeys, err := s.apiKeyRepo.GetByIDsWithDeleted(ctx, []uuid.UUID{apiKeyID})
+	if err != nil {
+		return "", err
+	}
+	if len(apiKeys) == 0 {
+		return "", fmt.Errorf("api key not found")
+	}
+
+	id, err := s.provider.CreateCustomer(ctx, &payment.Customer{APIKeyID: apiKeyID, Name: apiKeys[0].Name})
+	if err != nil {
+		return "", err
+	}
+	customer, err = s.paymentRepo.CreateCustomer(ctx, &models.PaymentCustomer{
+		APIKeyID:   apiKeyID,
+		Provider:   s.provider.Name(),
+		CustomerID: id,
+		CreatedAt:  s.clock.Now(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return customer.CustomerID, nil
+}
+
+// eventRecord finds the billing record a webhook event is about, or nil when
+// the charge was not made for one
+func (s *PaymentService) eventRecord(ctx context.Context, event *payment.Event) (*models.BillingRecord, error) {
+	if event.ChargeID == "" {
+		return nil, nil
+	}
+
+	var record *models.BillingRecord
+	var err error
+	if event.RecordID != uuid.Nil {
+		record, err = s.billingRepo.GetByID(ctx, event.RecordID)
+	} else {
+		record, err = s.billingRepo.GetByChargeID(ctx, s.provider.Name(), event.ChargeID)
+	}
+	if err != nil {
+		if err.Error() == "billing record not found" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/payment"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+type fakePaymentRepository struct {
+	repositories.PaymentRepository
+	customers []*models.PaymentCustomer
+	events    map[string]*models.PaymentEvent
+}
+
+func (r *fakePaymentRepository) GetCustomer(ctx context.Context, apiKeyID uuid.UUID, provider string) (*models.PaymentCustomer, error) {
+	for _, customer := range r.customers {
+		if customer.APIKeyID == apiKeyID && customer.Provider == provider {
+			return customer, nil
+		}
+	}
+	return nil, fmt.Errorf("payment customer not found")
+}
+
+func (r *fakePaymentRepository) CreateCustomer(ctx context.Context, customer *models.PaymentCustomer) (*models.PaymentCustomer, error) {
+	customer.ID = uuid.New()
+	r.customers = append(r.customers, customer)
+	return customer, nil
+}
+
+func (r *fakePaymentRepository) HasEvent(ctx context.Context, provider, eventID string) (bool, error) {
+	_, ok := r.events[provider+"/"+eventID]
+	return ok, nil
+}
+
+func (r *fakePaymentRepository) RecordEvent(ctx context.Context, event *models.PaymentEvent) (bool, error) {
+	key := event.Provider + "/" + event.EventID
+	if _, ok := r.events[key]; ok {
+		return false, nil
+	}
+	r.events[key] = event
+	return true, nil
+}
+
+type paymentFixture struct {
+	service  *PaymentService
+	provider *payment.FakeProvider
+	clock    *ratelimit.FakeClock
+	billing  *fakeBillingRecordRepository
+	payments *fakePaymentRepository
+	apiKeys  *fakeAPIKeyRepository
+}
+
+func newPaymentFixture(t *testing.T) *paymentFixture {
+	t.Helper()
+
+	clock := ratelimit.NewFakeClock(time.Date(2024, 4, 20, 12, 0, 0, 0, time.UTC))
+	provider := payment.NewFakeProvider("whsec_test")
+	provider.SetClock(clock.Now)
+
+	f := &paymentFixture{
+		provider: provider,
+		clock:    clock,
+		billing:  &fakeBillingRecordRepository{clock: clock},
+		payments: &fakePaymentRepository{events: make(map[string]*models.PaymentEvent)},
+		apiKeys:  newFakeAPIKeyRepository(),
+	}
+	f.service = NewPaymentService(f.billing, f.payments, f.apiKeys, provider,
+		DunningPolicy{PaymentTermsDays: 30, SuspendAfterDays: 15}, clock)
+	return f
+}
+
+func (f *paymentFixture) addKey(status models.APIKeyStatus, reason string) *models.APIKey {
+	apiKey := &models.APIKey{ID: uuid.New(), Name: "Test key", Status: status, SuspendedReason: reason}
+	f.apiKeys.apiKeys[apiKey.ID] = apiKey
+	return apiKey
+}
+
+func (f *paymentFixture) addRecord(apiKeyID uuid.UUID, periodEnd time.Time, total float64) *models.BillingRecord {
+	record := &models.BillingRecord{
+		ID:            uuid.New(),
+		APIKeyID:      apiKeyID,
+		PeriodStart:   periodEnd.AddDate(0, -1, 0),
+		PeriodEnd:     periodEnd,
+		TotalAmount:   total,
+		Currency:      "USD",
+		PaymentStatus: models.PaymentStatusPending,
+	}
+	f.billing.records = append(f.billing.records, record)
+	return record
+}
+
+func (f *paymentFixture) record(t *testing.T, id uuid.UUID) *models.BillingRecord {
+	t.Helper()
+	record, err := f.billing.GetByID(context.Background(), id)
+	require.NoError(t, err)
+	return record
+}
+
+func (f *paymentFixture) deliver(t *testing.T, event *payment.Event) bool {
+	t.Helper()
+	payload, header, err := f.provider.Webhook(event)
+	require.NoError(t, err)
+	applied, err := f.service.HandleWebhook(context.Background(), payload, header)
+	require.NoError(t, err)
+	return applied
+}
+
+func TestPaymentService_ChargeBillingRecord(t *testing.T) {
+	ctx := context.Background()
+	f := newPaymentFixture(t)
+	apiKey := f.addKey(models.APIKeyStatusActive, "")
+	first := f.addRecord(apiKey.ID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 49.99)
+	second := f.addRecord(apiKey.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 20)
+	free := f.addRecord(apiKey.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 0)
+
+	record, err := f.service.ChargeBillingRecord(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, record.PaymentStatus)
+	assert.NotEmpty(t, record.PaymentChargeID)
+	require.NotNil(t, record.PaidAt)
+	assert.Equal(t, f.clock.Now(), *record.PaidAt)
+
+	_, err = f.service.ChargeBillingRecord(ctx, second.ID)
+	require.NoError(t, err)
+	assert.Len(t, f.payments.customers, 1, "the customer is created once per API key")
+
+	_, err = f.service.ChargeBillingRecord(ctx, first.ID)
+	assert.EqualError(t, err, "billing record is already settled")
+
+	// Nothing to pay settles the record without a charge
+	record, err = f.service.ChargeBillingRecord(ctx, free.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, record.PaymentStatus)
+	assert.Len(t, f.provider.Charges(), 2)
+}
+
+func TestPaymentService_ChargeBillingRecord_Declined(t *testing.T) {
+	ctx := context.Background()
+	f := newPaymentFixture(t)
+	apiKey := f.addKey(models.APIKeyStatusActive, "")
+	pending := f.addRecord(apiKey.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 25)
+
+	customerID, err := f.service.customerID(ctx, apiKey.ID)
+	require.NoError(t, err)
+	f.provider.Decline(customerID, "Your card was declined.")
+
+	record, err := f.service.ChargeBillingRecord(ctx, pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusFailed, record.PaymentStatus)
+	assert.Equal(t, "Your card was declined.", record.PaymentFailureReason)
+	failedCharge := record.PaymentChargeID
+
+	// A retry makes a new charge rather than replaying the declined one
+	f.provider.Decline(customerID, "")
+	record, err = f.service.ChargeBillingRecord(ctx, pending.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, record.PaymentStatus)
+	assert.NotEqual(t, failedCharge, record.PaymentChargeID)
+}
+
+func TestPaymentService_Disabled(t *testing.T) {
+	ctx := context.Background()
+	service := NewPaymentService(&fakeBillingRecordRepository{}, &fakePaymentRepository{}, newFakeAPIKeyRepository(), nil, DunningPolicy{}, nil)
+
+	_, err := service.ChargeBillingRecord(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrPaymentsDisabled)
+	_, err = service.HandleWebhook(ctx, []byte("{}"), nil)
+	assert.ErrorIs(t, err, ErrPaymentsDisabled)
+}
+
+func TestPaymentService_HandleWebhook(t *testing.T) {
+	ctx := context.Background()
+	f := newPaymentFixture(t)
+	apiKey := f.addKey(models.APIKeyStatusActive, "")
+	pending := f.addRecord(apiKey.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 30)
+
+	succeeded := &payment.Event{
+		ID:        "evt_1",
+		Type:      payment.EventChargeSucceeded,
+		ChargeID:  "ch_1",
+		RecordID:  pending.ID,
+		Amount:    30,
+		Currency:  "USD",
+		CreatedAt: f.clock.Now(),
+	}
+	assert.True(t, f.deliver(t, succeeded))
+	assert.Equal(t, models.PaymentStatusPaid, f.record(t, pending.ID).PaymentStatus)
+
+	// Redelivery is acknowledged without effect
+	updates := f.billing.updates
+	assert.False(t, f.deliver(t, succeeded))
+	assert.Equal(t, updates, f.billing.updates)
+
+	// A failure arriving after the payment does not override it
+	assert.False(t, f.deliver(t, &payment.Event{
+		ID: "evt_2", Type: payment.EventChargeFailed, ChargeID: "ch_0", RecordID: pending.ID,
+		Amount: 30, Currency: "USD", FailureReason: "insufficient funds", CreatedAt: f.clock.Now(),
+	}))
+	assert.Equal(t, models.PaymentStatusPaid, f.record(t, pending.ID).PaymentStatus)
+
+	// Refunding the whole charge refunds the record
+	assert.True(t, f.deliver(t, &payment.Event{
+		ID: "evt_3", Type: payment.EventChargeRefunded, ChargeID: "ch_1",
+		Amount: 30, AmountRefunded: 30, Currency: "USD", CreatedAt: f.clock.Now(),
+	}))
+	assert.Equal(t, models.PaymentStatusRefunded, f.record(t, pending.ID).PaymentStatus)
+
+	payload, header, err := f.provider.Webhook(succeeded)
+	require.NoError(t, err)
+	header.Set(payment.StripeSignatureHeader, payment.SignStripePayload("wrong", f.clock.Now(), payload))
+	_, err = f.service.HandleWebhook(ctx, payload, header)
+	assert.ErrorIs(t, err, payment.ErrInvalidSignature)
+}
+
+func TestPaymentService_RefundBillingRecord(t *testing.T) {
+	ctx := context.Background()
+	f := newPaymentFixture(t)
+	apiKey := f.addKey(models.APIKeyStatusActive, "")
+	record := f.addRecord(apiKey.ID, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC), 40)
+
+	_, err := f.service.RefundBillingRecord(ctx, record.ID, 0)
+	assert.EqualError(t, err, "billing record is not paid")
+
+	_, err = f.service.ChargeBillingRecord(ctx, record.ID)
+	require.NoError(t, err)
+	_, err = f.service.RefundBillingRecord(ctx, record.ID, 41)
+	assert.EqualError(t, err, "invalid refund amount")
+
+	// A partial refund keeps the record paid
+	refunded, err := f.service.RefundBillingRecord(ctx, record.ID, 15)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, refunded.PaymentStatus)
+
+	assert.Equal(t, 15.0, f.provider.Charges()[0].AmountRefunded)
+
+	// Refunding the whole amount refunds the record
+	other := f.addRecord(apiKey.ID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 10)
+	_, err = f.service.ChargeBillingRecord(ctx, other.ID)
+	require.NoError(t, err)
+	refunded, err = f.service.RefundBillingRecord(ctx, other.ID, 0)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusRefunded, refunded.PaymentStatus)
+}
+func TestPaymentService_RunDunning(t *testing.T) {
+	ctx := context.Background()
+	f := newPaymentFixture(t)
+
+	// Now is 2024-04-20: invoices for periods ending before 2024-03-21 are
+	// overdue, and before 2024-03-06 suspend their key
+	delinquent := f.addKey(models.APIKeyStatusActive, "")
+	late := f.addKey(models.APIKeyStatusActive, "")
+	settled := f.addKey(models.APIKeyStatusSuspended, models.SuspendedReasonBilling)
+	manual := f.addKey(models.APIKeyStatusSuspended, "")
+
+	unpaid := f.addRecord(delinquent.ID, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), 10)
+	f.addRecord(late.ID, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), 10)
+	f.addRecord(late.ID, time.Date(2024, 4, 15, 0, 0, 0, 0, time.UTC), 10)
+	paid := f.addRecord(settled.ID, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 10)
+	paid.PaymentStatus = models.PaymentStatusPaid
+	f.addRecord(manual.ID, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), 10)
+
+	result, err := f.service.RunDunning(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &DunningResult{Overdue: 3, Suspended: 1, Reinstated: 1}, result)
+
+	assert.Equal(t, models.APIKeyStatusSuspended, delinquent.Status)
+	assert.Equal(t, models.SuspendedReasonBilling, delinquent.SuspendedReason)
+	assert.Equal(t, models.APIKeyStatusActive, late.Status)
+	assert.Equal(t, models.APIKeyStatusActive, settled.Status)
+	assert.Equal(t, models.APIKeyStatusSuspended, manual.Status, "keys suspended by hand are left alone")
+	assert.Empty(t, manual.SuspendedReason)
+
+	// Paying the invoice reinstates the key straight away
+	record, err := f.service.ChargeBillingRecord(ctx, unpaid.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PaymentStatusPaid, record.PaymentStatus)
+	assert.Equal(t, models.APIKeyStatusActive, delinquent.Status)
+	assert.Empty(t, delinquent.SuspendedReason)
+
+	result, err = f.service.RunDunning(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Suspended)
+	assert.Equal(t, 0, result.Reinstated)
+}
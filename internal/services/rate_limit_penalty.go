@@ -0,0 +1,243 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PenaltyPolicy configures escalating penalties for API keys that keep
+// exceeding their rate limit. Every denied request is a strike; when
+// ViolationThreshold strikes land within ViolationWindow of the first one the
+// key moves up one penalty level. Each level imposes a cooldown during which all
+// requests are rejected and shrinks the effective limit. Levels decay one at a
+// time after DecayInterval passes without a new escalation.
+type PenaltyPolicy struct {
+	Enabled            bool          `json:"enabled"`
+	ViolationThreshold int           `json:"violation_threshold"`
+	ViolationWindow    time.Duration `json:"violation_window"`
+	BaseCooldown       time.Duration `json:"base_cooldown"`
+	MaxCooldown        time.Duration `json:"max_cooldown"`
+	Multiplier         float64       `json:"multiplier"`   // cooldown growth per level
+	LimitFactor        float64       `json:"limit_factor"` // fraction of the limit kept per level
+	MaxLevel           int           `json:"max_level"`
+	DecayInterval      time.Duration `json:"decay_interval"`
+}
+
+// DefaultPenaltyPolicy returns the default penalty configuration
+func DefaultPenaltyPolicy() PenaltyPolicy {
+	return PenaltyPolicy{
+		Enabled:            true,
+		ViolationThreshold: 5,
+		ViolationWindow:    10 * time.Minute,
+		BaseCooldown:       time.Minute,
+		MaxCooldown:        time.Hour,
+		Multiplier:         2,
+		LimitFactor:        0.5,
+		MaxLevel:           5,
+		DecayInterval:      time.Hour,
+	}
+}
+
+// withDefaults fills unset fields from DefaultPenaltyPolicy
+func (p PenaltyPolicy) withDefaults() PenaltyPolicy {
+	defaults := DefaultPenaltyPolicy()
+	if p.ViolationThreshold <= 0 {
+		p.ViolationThreshold = defaults.ViolationThreshold
+	}
+	if p.ViolationWindow <= 0 {
+		p.ViolationWindow = defaults.ViolationWindow
+	}
+	if p.BaseCooldown <= 0 {
+		p.BaseCooldown = defaults.BaseCooldown
+	}
+	if p.MaxCooldown < p.BaseCooldown {
+		p.MaxCooldown = p.BaseCooldown
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = defaults.Multiplier
+	}
+	if p.LimitFactor <= 0 || p.LimitFactor > 1 {
+		p.LimitFactor = defaults.LimitFactor
+	}
+	if p.MaxLevel <= 0 {
+		p.MaxLevel = defaults.MaxLevel
+	}
+	if p.DecayInterval <= 0 {
+		p.DecayInterval = defaults.DecayInterval
+	}
+	return p
+}
+
+// Cooldown returns the cooldown imposed when a key reaches level
+func (p PenaltyPolicy) Cooldown(level int) time.Duration {
+	if level <= 0 {
+		return 0
+	}
+	cooldown := float64(p.BaseCooldown) * math.Pow(p.Multiplier, float64(level-1))
+	if cooldown > float64(p.MaxCooldown) {
+		return p.MaxCooldown
+	}
+	return time.Duration(cooldown)
+}
+
+// EffectiveLimit returns the limit that applies to a key at level
+func (p PenaltyPolicy) EffectiveLimit(limit, level int) int {
+	if level <= 0 {
+		return limit
+	}
+	effective := int(float64(limit) * math.Pow(p.LimitFactor, float64(level)))
+	if effective < 1 {
+		return 1
+	}
+	return effective
+}
+
+// PenaltyStatus describes the penalty currently applied to an API key
+type PenaltyStatus struct {
+	Level            int        `json:"level"`
+	EffectiveLimit   int        `json:"effective_limit"`
+	CooldownUntil    *time.Time `json:"cooldown_until,omitempty"`
+	RetryAfter       int        `json:"retry_after"` // seconds until the cooldown ends
+	RecentViolations int64      `json:"recent_violations"`
+	NextDecayAt      *time.Time `json:"next_decay_at,omitempty"`
+}
+
+// penaltyState is the penalty record stored in Redis for an API key
+type penaltyState struct {
+	Level         int       `json:"level"`
+	CooldownUntil time.Time `json:"cooldown_until"`
+	DecayFrom     time.Time `json:"decay_from"` // levels decay in DecayInterval steps from here
+}
+
+// inCooldown returns true if requests are currently being rejected
+func (p *penaltyState) inCooldown(now time.Time) bool {
+	return now.Before(p.CooldownUntil)
+}
+
+// decay drops one level for every full DecayInterval since DecayFrom
+func (p *penaltyState) decay(now time.Time, interval time.Duration) {
+	if p.Level == 0 || !now.After(p.DecayFrom) {
+		return
+	}
+
+	steps := int(now.Sub(p.DecayFrom) / interval)
+	if steps >= p.Level {
+		*p = penaltyState{}
+		return
+	}
+	p.Level -= steps
+	p.DecayFrom = p.DecayFrom.Add(time.Duration(steps) * interval)
+}
+
+// penaltyKey returns the cache key holding the penalty state of an API key
+func penaltyKey(apiKeyID uuid.UUID) string {
+	return fmt.Sprintf("rate_limit_penalty:%s", apiKeyID.String())
+}
+
+// strikesKey returns the cache key counting recent violations of an API key
+func strikesKey(apiKeyID uuid.UUID) string {
+	return fmt.Sprintf("rate_limit_strikes:%s", apiKeyID.String())
+}
+
+// loadPenalty reads the penalty state of an API key with decay applied.
+// Missing or unreadable state means no penalty.
+func (s *rateLimitService) loadPenalty(ctx context.Context, apiKeyID uuid.UUID, now time.Time) penaltyState {
+	var state penaltyState
+	if !s.penalty.Enabled {
+		return state
+	}
+
+	raw, err := s.cacheService.Get(ctx, penaltyKey(apiKeyID))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return penaltyState{}
+	}
+
+	state.decay(now, s.penalty.DecayInterval)
+	return state
+}
+
+// savePenalty stores the penalty state until it has fully decayed
+func (s *rateLimitService) savePenalty(ctx context.Context, apiKeyID uuid.UUID, state penaltyState, now time.Time) error {
+	if state.Level == 0 {
+		return s.cacheService.Delete(ctx, penaltyKey(apiKeyID))
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode penalty state: %w", err)
+	}
+
+	expiration := state.DecayFrom.Add(time.Duration(state.Level) * s.penalty.DecayInterval).Sub(now)
+	return s.cacheService.Set(ctx, penaltyKey(apiKeyID), string(data), expiration)
+}
+
+// recordStrike counts a violation against an API key and escalates its penalty
+// once enough strikes accumulate. It returns the resulting state and whether
+// the key was escalated.
+func (s *rateLimitService) recordStrike(ctx context.Context, apiKeyID uuid.UUID, state penaltyState, now time.Time) (penaltyState, bool) {
+	if !s.penalty.Enabled {
+		return state, false
+	}
+
+	// The window starts at the first strike and is not extended by later ones
+	strikes, err := s.cacheService.IncrementWindowCounter(ctx, strikesKey(apiKeyID), 1, s.penalty.ViolationWindow)
+	if err != nil || strikes < int64(s.penalty.ViolationThreshold) {
+		return state, false
+	}
+
+	// Start counting afresh at the new level
+	s.cacheService.DeleteKey(ctx, strikesKey(apiKeyID))
+
+	if state.Level < s.penalty.MaxLevel {
+		state.Level++
+	}
+	state.CooldownUntil = now.Add(s.penalty.Cooldown(state.Level))
+	state.DecayFrom = state.CooldownUntil
+
+	if err := s.savePenalty(ctx, apiKeyID, state, now); err != nil {
+		// Log error but don't fail the rate limit check
+		fmt.Printf("Failed to save penalty state: %v\n", err)
+	}
+
+	return state, true
+}
+
+// penaltyStatus summarises the penalty state of an API key for reporting
+func (s *rateLimitService) penaltyStatus(ctx context.Context, apiKeyID uuid.UUID, limit int, now time.Time) *PenaltyStatus {
+	state := s.loadPenalty(ctx, apiKeyID, now)
+
+	status := &PenaltyStatus{
+		Level:          state.Level,
+		EffectiveLimit: s.penalty.EffectiveLimit(limit, state.Level),
+	}
+	if strikes, err := s.cacheService.GetCounter(ctx, strikesKey(apiKeyID)); err == nil {
+		status.RecentViolations = strikes
+	}
+	if state.inCooldown(now) {
+		cooldownUntil := state.CooldownUntil
+		status.CooldownUntil = &cooldownUntil
+		status.RetryAfter = retryAfterSeconds(cooldownUntil.Sub(now))
+	}
+	if state.Level > 0 {
+		nextDecay := state.DecayFrom.Add(s.penalty.DecayInterval)
+		status.NextDecayAt = &nextDecay
+	}
+
+	return status
+}
+
+// retryAfterSeconds rounds a wait up to whole seconds
+func retryAfterSeconds(d time.Duration) int {
+	if d <= 0 {
+		return 0
+	}
+	return int((d + time.Second - 1) / time.Second)
+}
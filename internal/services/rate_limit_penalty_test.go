@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+func TestPenaltyPolicy_CooldownAndLimit(t *testing.T) {
+	policy := DefaultPenaltyPolicy()
+
+	assert.Equal(t, time.Duration(0), policy.Cooldown(0))
+	assert.Equal(t, time.Minute, policy.Cooldown(1))
+	assert.Equal(t, 2*time.Minute, policy.Cooldown(2))
+	assert.Equal(t, 16*time.Minute, policy.Cooldown(5))
+	assert.Equal(t, time.Hour, policy.Cooldown(10))
+
+	assert.Equal(t, 1000, policy.EffectiveLimit(1000, 0))
+	assert.Equal(t, 500, policy.EffectiveLimit(1000, 1))
+	assert.Equal(t, 125, policy.EffectiveLimit(1000, 3))
+	assert.Equal(t, 1, policy.EffectiveLimit(3, 5))
+}
+
+func TestRateLimitService_PenaltyEscalatesAndDecays(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	policy := DefaultPenaltyPolicy()
+	policy.ViolationThreshold = 3
+
+	service := &rateLimitService{
+		cacheService: newFakeCacheService(),
+		penalty:      policy,
+		clock:        clock,
+	}
+	apiKeyID := uuid.New()
+
+	escalate := func() penaltyState {
+		t.Helper()
+		now := clock.Now()
+		state := service.loadPenalty(ctx, apiKeyID, now)
+		for i := 0; i < policy.ViolationThreshold-1; i++ {
+			_, escalated := service.recordStrike(ctx, apiKeyID, state, now)
+			require.False(t, escalated)
+		}
+		state, escalated := service.recordStrike(ctx, apiKeyID, state, now)
+		require.True(t, escalated)
+		return state
+	}
+
+	// Each escalation doubles the cooldown
+	state := escalate()
+	assert.Equal(t, 1, state.Level)
+	assert.Equal(t, clock.Now().Add(time.Minute), state.CooldownUntil)
+
+	clock.Advance(time.Minute)
+	state = escalate()
+	assert.Equal(t, 2, state.Level)
+	assert.Equal(t, clock.Now().Add(2*time.Minute), state.CooldownUntil)
+
+	status := service.penaltyStatus(ctx, apiKeyID, 1000, clock.Now())
+	assert.Equal(t, 2, status.Level)
+	assert.Equal(t, 250, status.EffectiveLimit)
+	assert.Equal(t, 120, status.RetryAfter)
+
+	// One level decays per interval once the cooldown has ended
+	clock.Advance(2*time.Minute + policy.DecayInterval)
+	assert.Equal(t, 1, service.loadPenalty(ctx, apiKeyID, clock.Now()).Level)
+
+	clock.Advance(policy.DecayInterval)
+	assert.Equal(t, 0, service.loadPenalty(ctx, apiKeyID, clock.Now()).Level)
+}
+
+func TestRateLimitService_StrikeWindowIsFixed(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	policy := DefaultPenaltyPolicy()
+	policy.ViolationThreshold = 3
+
+	cache := newFakeCacheService()
+	cache.clock = clock
+	service := &rateLimitService{
+		cacheService: cache,
+		penalty:      policy,
+		clock:        clock,
+	}
+	apiKeyID := uuid.New()
+
+	// Later strikes don't extend the window opened by the first one
+	for i := 0; i < policy.ViolationThreshold-1; i++ {
+		_, escalated := service.recordStrike(ctx, apiKeyID, penaltyState{}, clock.Now())
+		require.False(t, escalated)
+		clock.Advance(policy.ViolationWindow / 2)
+	}
+
+	clock.Advance(time.Second)
+	_, escalated := service.recordStrike(ctx, apiKeyID, penaltyState{}, clock.Now())
+	assert.False(t, escalated)
+}
+
+func TestRateLimitService_PenaltyDisabled(t *testing.T) {
+	ctx := context.Background()
+	service := &rateLimitService{
+		cacheService: newFakeCacheService(),
+		clock:        ratelimit.NewSystemClock(),
+	}
+
+	for i := 0; i < 100; i++ {
+		_, escalated := service.recordStrike(ctx, uuid.New(), penaltyState{}, time.Now())
+		assert.False(t, escalated)
+	}
+	assert.Equal(t, 1000, service.penalty.EffectiveLimit(1000, 0))
+}
@@ -8,17 +8,20 @@ import (
 	"github.com/google/uuid"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 // RateLimitService defines the interface for rate limiting business logic
 type RateLimitService interface {
 	CheckRateLimit(ctx context.Context, req *RateLimitRequest) (*RateLimitResult, error)
+	ChargeCredits(ctx context.Context, req *RateLimitRequest) (*RateLimitResult, error)
 	GetRateLimitInfo(ctx context.Context, apiKeyID uuid.UUID) (*RateLimitInfo, error)
 	ResetRateLimit(ctx context.Context, apiKeyID uuid.UUID) error
 	UpdateRateLimit(ctx context.Context, apiKeyID uuid.UUID, newLimit int) error
 	GetViolationHistory(ctx context.Context, apiKeyID uuid.UUID, hours int) ([]*models.RateLimitViolation, error)
 	RecordViolation(ctx context.Context, req *RateLimitRequest, attemptedRequests int) error
 	GetCurrentWindowUsage(ctx context.Context, apiKeyID uuid.UUID) (int64, error)
+	CleanupOldViolations(ctx context.Context, retentionDays int) (int64, error)
 }
 
 // RateLimitRequest contains data for rate limit checks
@@ -42,6 +45,13 @@ type RateLimitResult struct {
 	WindowEnd         time.Time `json:"window_end"`
 	RetryAfter        int       `json:"retry_after"` // seconds
 	ViolationRecorded bool      `json:"violation_recorded"`
+	PenaltyLevel      int        `json:"penalty_level"`
+	CooldownUntil     *time.Time `json:"cooldown_until,omitempty"`
+
+	// Prepaid credits, set when the key's requests are charged to an account
+	CreditBalance    *float64 `json:"credit_balance,omitempty"`
+	CreditsExhausted bool     `json:"credits_exhausted,omitempty"`
+	SpendCapReached  bool     `json:"spend_cap_reached,omitempty"`
 }
 
 // RateLimitInfo contains detailed rate limit information
@@ -55,6 +65,7 @@ type RateLimitInfo struct {
 	RecentViolations int64              `json:"recent_violations"`
 	Tier            models.APIKeyTier   `json:"tier"`
 	Status          models.APIKeyStatus `json:"status"`
+	Penalty         *PenaltyStatus      `json:"penalty,omitempty"`
 }
 
 // rateLimitService implements RateLimitService interface
@@ -63,7 +74,11 @@ type rateLimitService struct {
 	violationRepo repositories.RateLimitViolationRepository
 	usageRepo     repositories.UsageLogRepository
 	cacheService  CacheService // Redis-based cache service
+	accessControl AccessControlService // optional, bans keys on critical violations
+	credits       *CreditService       // optional, charges requests to prepaid credits
+	penalty       PenaltyPolicy
 	windowSize    time.Duration
+	clock         ratelimit.Clock
 }
 
 // NewRateLimitService creates a new rate limit service
@@ -72,13 +87,28 @@ func NewRateLimitService(
 	violationRepo repositories.RateLimitViolationRepository,
 	usageRepo repositories.UsageLogRepository,
 	cacheService CacheService,
+	accessControl AccessControlService,
+	credits *CreditService,
+	penalty PenaltyPolicy,
+	clock ratelimit.Clock,
 ) RateLimitService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+	if penalty.Enabled {
+		penalty = penalty.withDefaults()
+	}
+
 	return &rateLimitService{
 		apiKeyRepo:    apiKeyRepo,
 		violationRepo: violationRepo,
 		usageRepo:     usageRepo,
 		cacheService:  cacheService,
+		accessControl: accessControl,
+		credits:       credits,
+		penalty:       penalty,
 		windowSize:    time.Hour, // 1 hour sliding window
+		clock:         clock,
 	}
 }
 
@@ -90,71 +120,122 @@ func (s *rateLimitService) CheckRateLimit(ctx context.Context, req *RateLimitReq
 		return nil, fmt.Errorf("failed to get api key: %w", err)
 	}
 
+	// Calculate window boundaries
+	now := req.Timestamp
+	if now.IsZero() {
+		now = s.clock.Now()
+	}
+
 	// Check if API key is active
 	if apiKey.Status != models.APIKeyStatusActive {
 		return &RateLimitResult{
 			Allowed:           false,
 			Limit:             apiKey.RateLimit,
 			Remaining:         0,
-			ResetTime:         time.Now().Add(s.windowSize),
-			WindowStart:       time.Now(),
-			WindowEnd:         time.Now().Add(s.windowSize),
+			ResetTime:         now.Add(s.windowSize),
+			WindowStart:       now,
+			WindowEnd:         now.Add(s.windowSize),
 			RetryAfter:        int(s.windowSize.Seconds()),
 			ViolationRecorded: false,
 		}, nil
 	}
 
-	// Calculate window boundaries
-	now := req.Timestamp
-	if now.IsZero() {
-		now = time.Now()
-	}
-
 	windowStart := now.Truncate(s.windowSize)
 	windowEnd := windowStart.Add(s.windowSize)
 
+	// Repeat violators are rejected outright while cooling down and get a
+	// reduced limit until their penalty decays
+	penalty := s.loadPenalty(ctx, req.APIKeyID, now)
+	limit := s.penalty.EffectiveLimit(apiKey.RateLimit, penalty.Level)
+
+	if penalty.inCooldown(now) {
+		cooldownUntil := penalty.CooldownUntil
+		return &RateLimitResult{
+			Allowed:           false,
+			Limit:             limit,
+			Remaining:         0,
+			ResetTime:         windowEnd,
+			WindowStart:       windowStart,
+			WindowEnd:         windowEnd,
+			RetryAfter:        retryAfterSeconds(cooldownUntil.Sub(now)),
+			ViolationRecorded: false,
+			PenaltyLevel:      penalty.Level,
+			CooldownUntil:     &cooldownUntil,
+		}, nil
+	}
+
 	// Get current usage in the window using cache first, then fallback to database
 	cacheKey := fmt.Sprintf("rate_limit:%s:%d", req.APIKeyID.String(), windowStart.Unix())
 	currentUsage, err := s.cacheService.GetCounter(ctx, cacheKey)
 	if err != nil {
 		// Fallback to database query
-		logs, err := s.usageRepo.GetUsageByAPIKey(ctx, req.APIKeyID, windowStart, windowEnd)
+		currentUsage, err = s.usageRepo.CountRequests(ctx, req.APIKeyID, windowStart, windowEnd)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get usage logs: %w", err)
+			return nil, fmt.Errorf("failed to count usage: %w", err)
 		}
-		currentUsage = int64(len(logs))
 
 		// Update cache
 		s.cacheService.SetCounter(ctx, cacheKey, currentUsage, s.windowSize)
 	}
 
 	// Check if limit is exceeded
-	allowed := currentUsage < int64(apiKey.RateLimit)
-	remaining := int64(apiKey.RateLimit) - currentUsage
+	allowed := currentUsage < int64(limit)
+	remaining := int64(limit) - currentUsage
 	if remaining < 0 {
 		remaining = 0
 	}
 
 	result := &RateLimitResult{
 		Allowed:           allowed,
-		Limit:             apiKey.RateLimit,
+		Limit:             limit,
 		Remaining:         int(remaining),
 		ResetTime:         windowEnd,
 		WindowStart:       windowStart,
 		WindowEnd:         windowEnd,
 		RetryAfter:        int(windowEnd.Sub(now).Seconds()),
 		ViolationRecorded: false,
+		PenaltyLevel:      penalty.Level,
 	}
 
 	// If not allowed, record violation
 	if !allowed {
-		if err := s.RecordViolation(ctx, req, 1); err != nil {
+		// Denied requests are not part of the usage counter, so track them
+		// separately to know how far past the limit the key has gone
+		deniedKey := fmt.Sprintf("rate_limit_denied:%s:%d", req.APIKeyID.String(), windowStart.Unix())
+		denied, err := s.cacheService.IncrementCounter(ctx, deniedKey, 1, s.windowSize)
+		if err != nil {
+			denied = 1
+		}
+
+		violation := s.newViolation(req, 1)
+		violation.LimitValue = limit
+		violation.WindowSeconds = int(s.windowSize.Seconds())
+		violation.CurrentCount = int(currentUsage + denied)
+		violation.TierType = string(apiKey.Tier)
+		violation.IsRepeated = denied > 1
+
+		if err := s.violationRepo.Create(ctx, violation); err != nil {
 			// Log error but don't fail the rate limit check
 			fmt.Printf("Failed to record violation: %v\n", err)
 		} else {
 			result.ViolationRecorded = true
+			s.banIfCritical(ctx, violation)
+		}
+
+		// Escalate repeat violators into a cooldown
+		if escalatedState, escalated := s.recordStrike(ctx, req.APIKeyID, penalty, now); escalated {
+			cooldownUntil := escalatedState.CooldownUntil
+			result.PenaltyLevel = escalatedState.Level
+			result.CooldownUntil = &cooldownUntil
+			result.RetryAfter = retryAfterSeconds(cooldownUntil.Sub(now))
 		}
 	} else {
+		// Requests the key's prepaid credits cannot pay for are rejected
+		// without counting towards the limit
+		if !s.chargeCredits(ctx, apiKey, now, result) {
+			return result, nil
+		}
+
 		// Increment usage counter in cache
 		s.cacheService.IncrementCounter(ctx, cacheKey, 1, s.windowSize)
 	}
@@ -162,6 +243,50 @@ func (s *rateLimitService) CheckRateLimit(ctx context.Context, req *RateLimitReq
 	return result, nil
 }
 
+// ChargeCredits charges a request that skips the rate limit, such as an
+// allowlisted one, to the key's prepaid credits. The result is allowed unless
+// the credits cannot pay for the request, and carries no rate limit counters.
+func (s *rateLimitService) ChargeCredits(ctx context.Context, req *RateLimitRequest) (*RateLimitResult, error) {
+	apiKey, err := s.apiKeyRepo.GetByID(ctx, req.APIKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+
+	now := req.Timestamp
+	if now.IsZero() {
+		now = s.clock.Now()
+	}
+
+	result := &RateLimitResult{Allowed: true}
+	s.chargeCredits(ctx, apiKey, now, result)
+	return result, nil
+}
+
+// chargeCredits charges a request to the key's prepaid credits and records
+// the outcome in result. It returns false when the credits cannot pay for the
+// request. Credits fail open: when the credit store is unavailable the
+// request is let through uncharged rather than failing every check.
+func (s *rateLimitService) chargeCredits(ctx context.Context, apiKey *models.APIKey, now time.Time, result *RateLimitResult) bool {
+	charge, err := s.credits.Charge(ctx, apiKey, now)
+	if err != nil {
+		fmt.Printf("Failed to charge credits: %v\n", err)
+		return true
+	}
+	if charge == nil {
+		return true
+	}
+
+	balance := charge.Balance
+	result.CreditBalance = &balance
+	if !charge.Allowed {
+		result.Allowed = false
+		result.CreditsExhausted = !charge.SpendCapReached
+		result.SpendCapReached = charge.SpendCapReached
+		return false
+	}
+	return true
+}
+
 // GetRateLimitInfo retrieves detailed rate limit information
 func (s *rateLimitService) GetRateLimitInfo(ctx context.Context, apiKeyID uuid.UUID) (*RateLimitInfo, error) {
 	// Get API key
@@ -170,7 +295,7 @@ func (s *rateLimitService) GetRateLimitInfo(ctx context.Context, apiKeyID uuid.U
 		return nil, err
 	}
 
-	now := time.Now()
+	now := s.clock.Now()
 	windowStart := now.Truncate(s.windowSize)
 	windowEnd := windowStart.Add(s.windowSize)
 
@@ -180,6 +305,12 @@ func (s *rateLimitService) GetRateLimitInfo(ctx context.Context, apiKeyID uuid.U
 		return nil, fmt.Errorf("failed to get current usage: %w", err)
 	}
 
+	// Report escalation state for repeat violators
+	var penalty *PenaltyStatus
+	if s.penalty.Enabled {
+		penalty = s.penaltyStatus(ctx, apiKeyID, apiKey.RateLimit, now)
+	}
+
 	// Get recent violations (last 24 hours)
 	recentViolations, err := s.violationRepo.CountRecentViolations(ctx, apiKeyID, 24*60) // 24 hours in minutes
 	if err != nil {
@@ -196,15 +327,23 @@ func (s *rateLimitService) GetRateLimitInfo(ctx context.Context, apiKeyID uuid.U
 		RecentViolations: recentViolations,
 		Tier:             apiKey.Tier,
 		Status:           apiKey.Status,
+		Penalty:          penalty,
 	}, nil
 }
 
 // ResetRateLimit resets the rate limit counter for an API key
 func (s *rateLimitService) ResetRateLimit(ctx context.Context, apiKeyID uuid.UUID) error {
-	now := time.Now()
+	now := s.clock.Now()
 	windowStart := now.Truncate(s.windowSize)
 	cacheKey := fmt.Sprintf("rate_limit:%s:%d", apiKeyID.String(), windowStart.Unix())
+	deniedKey := fmt.Sprintf("rate_limit_denied:%s:%d", apiKeyID.String(), windowStart.Unix())
 
+	// Resetting also lifts any penalty
+	for _, key := range []string{deniedKey, penaltyKey(apiKeyID), strikesKey(apiKeyID)} {
+		if err := s.cacheService.DeleteKey(ctx, key); err != nil {
+			return err
+		}
+	}
 	return s.cacheService.DeleteKey(ctx, cacheKey)
 }
 
@@ -218,21 +357,26 @@ func (s *rateLimitService) UpdateRateLimit(ctx context.Context, apiKeyID uuid.UU
 
 	// Update rate limit
 	apiKey.RateLimit = newLimit
-	apiKey.UpdatedAt = time.Now()
+	apiKey.UpdatedAt = s.clock.Now()
 
 	return s.apiKeyRepo.Update(ctx, apiKey)
 }
 
 // GetViolationHistory retrieves violation history for an API key
 func (s *rateLimitService) GetViolationHistory(ctx context.Context, apiKeyID uuid.UUID, hours int) ([]*models.RateLimitViolation, error) {
-	startTime := time.Now().Add(time.Duration(-hours) * time.Hour)
-	endTime := time.Now()
+	endTime := s.clock.Now()
+	startTime := endTime.Add(time.Duration(-hours) * time.Hour)
 
 	return s.violationRepo.GetByAPIKey(ctx, apiKeyID, startTime, endTime)
 }
 
 // RecordViolation records a rate limit violation
 func (s *rateLimitService) RecordViolation(ctx context.Context, req *RateLimitRequest, attemptedRequests int) error {
+	return s.violationRepo.Create(ctx, s.newViolation(req, attemptedRequests))
+}
+
+// newViolation builds a violation record from the request details
+func (s *rateLimitService) newViolation(req *RateLimitRequest, attemptedRequests int) *models.RateLimitViolation {
 	violation := &models.RateLimitViolation{
 		APIKeyID:       req.APIKeyID,
 		Endpoint:       req.Endpoint,
@@ -245,15 +389,27 @@ func (s *rateLimitService) RecordViolation(ctx context.Context, req *RateLimitRe
 	}
 
 	if violation.Timestamp.IsZero() {
-		violation.Timestamp = time.Now()
+		violation.Timestamp = s.clock.Now()
 	}
 
-	return s.violationRepo.Create(ctx, violation)
+	return violation
+}
+
+// banIfCritical temporarily bans the API key behind a critical violation
+func (s *rateLimitService) banIfCritical(ctx context.Context, violation *models.RateLimitViolation) {
+	if s.accessControl == nil {
+		return
+	}
+
+	if _, err := s.accessControl.BanForViolation(ctx, violation); err != nil {
+		// Log error but don't fail the rate limit check
+		fmt.Printf("Failed to ban API key %s: %v\n", violation.APIKeyID, err)
+	}
 }
 
 // GetCurrentWindowUsage gets the current usage in the rate limit window
 func (s *rateLimitService) GetCurrentWindowUsage(ctx context.Context, apiKeyID uuid.UUID) (int64, error) {
-	now := time.Now()
+	now := s.clock.Now()
 	windowStart := now.Truncate(s.windowSize)
 	windowEnd := windowStart.Add(s.windowSize)
 
@@ -265,24 +421,28 @@ func (s *rateLimitService) GetCurrentWindowUsage(ctx context.Context, apiKeyID u
 	}
 
 	// Fallback to database
-	logs, err := s.usageRepo.GetUsageByAPIKey(ctx, apiKeyID, windowStart, windowEnd)
+	currentUsage, err = s.usageRepo.CountRequests(ctx, apiKeyID, windowStart, windowEnd)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get usage logs: %w", err)
+		return 0, fmt.Errorf("failed to count usage: %w", err)
 	}
 
-	currentUsage = int64(len(logs))
-
 	// Update cache for future requests
 	s.cacheService.SetCounter(ctx, cacheKey, currentUsage, s.windowSize)
 
 	return currentUsage, nil
 }
 
+// CleanupOldViolations removes violations older than the retention period
+func (s *rateLimitService) CleanupOldViolations(ctx context.Context, retentionDays int) (int64, error) {
+	return s.violationRepo.DeleteOldViolations(ctx, retentionDays)
+}
+
 // CacheService defines the interface for cache operations
 type CacheService interface {
 	GetCounter(ctx context.Context, key string) (int64, error)
 	SetCounter(ctx context.Context, key string, value int64, expiration time.Duration) error
 	IncrementCounter(ctx context.Context, key string, delta int64, expiration time.Duration) (int64, error)
+	IncrementWindowCounter(ctx context.Context, key string, delta int64, window time.Duration) (int64, error)
 	DeleteKey(ctx context.Context, key string) error
 	Get(ctx context.Context, key string) (string, error)
 	Set(ctx context.Context, key string, value string, expiration time.Duration) error
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// maxExportErrorLength is the size of the usage_exports.error column
+const maxExportErrorLength = 1000
+
+// UsageExportPolicy configures usage exports. Exports spanning more than
+// SyncMaxRange, or matching more than SyncMaxRecords usage logs, are handed to
+// the worker; smaller ones are written before CreateExport returns. Logs are
+// read from the database BatchSize at a time.
+type UsageExportPolicy struct {
+	SyncMaxRange   time.Duration `json:"sync_max_range"`
+	SyncMaxRecords int64         `json:"sync_max_records"`
+	BatchSize      int           `json:"batch_size"`
+}
+
+// DefaultUsageExportPolicy returns the default export configuration
+func DefaultUsageExportPolicy() UsageExportPolicy {
+	return UsageExportPolicy{
+		SyncMaxRange:   24 * time.Hour,
+		SyncMaxRecords: 50000,
+		BatchSize:      1000,
+	}
+}
+
+// withDefaults fills unset fields from DefaultUsageExportPolicy
+func (p UsageExportPolicy) withDefaults() UsageExportPolicy {
+	defaults := DefaultUsageExportPolicy()
+	if p.SyncMaxRange <= 0 {
+		p.SyncMaxRange = defaults.SyncMaxRange
+	}
+	if p.SyncMaxRecords <= 0 {
+		p.SyncMaxRecords = defaults.SyncMaxRecords
+	}
+	if p.BatchSize <= 0 {
+		p.BatchSize = defaults.BatchSize
+	}
+	return p
+}
+
+// BlobStore stores export files
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// ExportEnqueuer hands an export to the worker, which calls RunExport
+type ExportEnqueuer interface {
+	EnqueueExport(ctx context.Context, exportID uuid.UUID) error
+}
+
+// UsageExportService defines the interface for exporting usage logs to files
+type UsageExportService interface {
+	CreateExport(ctx context.Context, req *ExportRequest) (*ExportResult, error)
+	RunExport(ctx context.Context, id uuid.UUID) (*ExportResult, error)
+	GetExport(ctx context.Context, id uuid.UUID) (*ExportResult, error)
+	OpenExport(ctx context.Context, id uuid.UUID) (*models.UsageExport, io.ReadCloser, error)
+}
+
+// ExportRequest contains parameters for exporting usage data. Filters takes
+// the UsageLogFilter fields other than the API key and time range, e.g.
+// {"endpoint": "/api/v1/users", "status_code": 429}.
+type ExportRequest struct {
+	APIKeyID  uuid.UUID              `json:"api_key_id"`
+	StartTime time.Time              `json:"start_time"`
+	EndTime   time.Time              `json:"end_time"`
+	Format    models.ExportFormat    `json:"format"` // csv, ndjson, parquet
+	Filters   map[string]interface{} `json:"filters"`
+}
+
+// ExportResult contains the state of a data export. DownloadURL is set once
+// the export has completed.
+type ExportResult struct {
+	*models.UsageExport
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// usageExportService implements UsageExportService interface
+type usageExportService struct {
+	exportRepo repositories.UsageExportRepository
+	usageRepo  repositories.UsageLogRepository
+	store      BlobStore
+	enqueuer   ExportEnqueuer
+	policy     UsageExportPolicy
+	clock      ratelimit.Clock
+}
+
+// NewUsageExportService creates a new usage export service. Without an
+// enqueuer every export is written before CreateExport returns.
+func NewUsageExportService(
+	exportRepo repositories.UsageExportRepository,
+	usageRepo repositories.UsageLogRepository,
+	store BlobStore,
+	enqueuer ExportEnqueuer,
+	policy UsageExportPolicy,
+	clock ratelimit.Clock,
+) UsageExportService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &usageExportService{
+		exportRepo: exportRepo,
+		usageRepo:  usageRepo,
+		store:      store,
+		enqueuer:   enqueuer,
+		policy:     policy.withDefaults(),
+		clock:      clock,
+	}
+}
+
+// CreateExport records an export and either writes it straight away or, for
+// large exports, enqueues it for the worker
+func (s *usageExportService) CreateExport(ctx context.Context, req *ExportRequest) (*ExportResult, error) {
+	if req.APIKeyID == uuid.Nil {
+		return nil, fmt.Errorf("api key id is required")
+	}
+	if req.Format == "" {
+		req.Format = models.ExportFormatCSV
+	}
+	if !req.Format.IsValid() {
+		return nil, fmt.Errorf("invalid export format: %s", req.Format)
+	}
+	if req.StartTime.IsZero() || req.EndTime.IsZero() {
+		return nil, fmt.Errorf("start time and end time are required")
+	}
+	if !req.EndTime.After(req.StartTime) {
+		return nil, fmt.Errorf("end time must be after start time")
+	}
+
+	export := &models.UsageExport{
+		APIKeyID:  req.APIKeyID,
+		Format:    req.Format,
+		Status:    models.ExportStatusPending,
+		StartTime: req.StartTime.UTC(),
+		EndTime:   req.EndTime.UTC(),
+		Filters:   req.Filters,
+	}
+
+	filter, err := exportFilter(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.exportRepo.Create(ctx, export); err != nil {
+		return nil, err
+	}
+
+	large, err := s.isLarge(ctx, export, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !large {
+		return s.RunExport(ctx, export.ID)
+	}
+
+	if err := s.enqueuer.EnqueueExport(ctx, export.ID); err != nil {
+		s.fail(ctx, export, err)
+		return nil, err
+	}
+	return s.result(export), nil
+}
+
+// RunExport streams the logs of an export into the blob store. Completed
+// exports are returned as they are; pending, running and failed ones are
+// (re)written, so a retried task picks up where a crashed worker left off.
+func (s *usageExportService) RunExport(ctx context.Context, id uuid.UUID) (*ExportResult, error) {
+	export, err := s.exportRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if export.Status == models.ExportStatusCompleted {
+		return s.result(export), nil
+	}
+
+	filter, err := exportFilter(export)
+	if err != nil {
+		s.fail(ctx, export, err)
+		return nil, err
+	}
+
+	export.Status = models.ExportStatusRunning
+	export.Error = ""
+	if err := s.exportRepo.Update(ctx, export); err != nil {
+		return nil, err
+	}
+
+	key := fmt.Sprintf("usage-exports/%s/%s.%s", export.APIKeyID, export.ID, export.Format)
+	records, size, err := s.write(ctx, key, export.Format, filter)
+	if err != nil {
+		s.store.Delete(ctx, key)
+		s.fail(ctx, export, err)
+		return nil, err
+	}
+
+	completedAt := s.clock.Now()
+	export.Status = models.ExportStatusCompleted
+	export.FileName = fmt.Sprintf("usage_%s_%s_%s.%s",
+		export.APIKeyID.String()[:8],
+		export.StartTime.Format("20060102T150405Z"),
+		export.EndTime.Format("20060102T150405Z"),
+		export.Format)
+	export.StorageKey = key
+	export.RecordCount = records
+	export.FileSize = size
+	export.CompletedAt = &completedAt
+	if err := s.exportRepo.Update(ctx, export); err != nil {
+		return nil, err
+	}
+
+	return s.result(export), nil
+}
+
+// GetExport returns the state of an export
+func (s *usageExportService) GetExport(ctx context.Context, id uuid.UUID) (*ExportResult, error) {
+	export, err := s.exportRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.result(export), nil
+}
+
+// OpenExport returns a completed export and a reader for its file
+func (s *usageExportService) OpenExport(ctx context.Context, id uuid.UUID) (*models.UsageExport, io.ReadCloser, error) {
+	export, err := s.exportRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if export.Status != models.ExportStatusCompleted {
+		return nil, nil, fmt.Errorf("usage export not ready")
+	}
+
+	file, err := s.store.Open(ctx, export.StorageKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return export, file, nil
+}
+
+// isLarge returns true if an export should run on the worker
+func (s *usageExportService) isLarge(ctx context.Context, export *models.UsageExport, filter *repositories.UsageLogFilter) (bool, error) {
+	if s.enqueuer == nil {
+		return false, nil
+	}
+	if export.EndTime.Sub(export.StartTime) > s.policy.SyncMaxRange {
+		return true, nil
+	}
+
+	count, err := s.usageRepo.CountFiltered(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+	return count > s.policy.SyncMaxRecords, nil
+}
+
+// write encodes the logs matching filter and stores them under key. The file
+// is piped into the blob store as it is encoded rather than built in memory.
+func (s *usageExportService) write(ctx context.Context, key string, format models.ExportFormat, filter *repositories.UsageLogFilter) (int64, int64, error) {
+	reader, writer := io.Pipe()
+
+	var records int64
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		writer.CloseWithError(s.encode(ctx, format, filter, writer, &records))
+	}()
+
+	size, err := s.store.Put(ctx, key, reader)
+	// Unblock the encoder if the store stopped reading early
+	reader.CloseWithError(io.ErrClosedPipe)
+	<-done
+	if err != nil {
+		return 0, 0, err
+	}
+	return records, size, nil
+}
+
+// encode writes the logs matching filter to w in format, counting them in records
+func (s *usageExportService) encode(ctx context.Context, format models.ExportFormat, filter *repositories.UsageLogFilter, w io.Writer, records *int64) error {
+	encoder, err := newUsageExportWriter(format, w)
+	if err != nil {
+		return err
+	}
+
+	err = s.usageRepo.StreamUsageLogs(ctx, filter, s.policy.BatchSize, func(logs []*models.UsageLog) error {
+		*records += int64(len(logs))
+		return encoder.Write(logs)
+	})
+	if err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// fail records err on the export. A failure to save it is not reported, as
+// the caller already returns the more useful original error.
+func (s *usageExportService) fail(ctx context.Context, export *models.UsageExport, err error) {
+	message := err.Error()
+	if len(message) > maxExportErrorLength {
+		message = message[:maxExportErrorLength]
+	}
+
+	completedAt := s.clock.Now()
+	export.Status = models.ExportStatusFailed
+	export.Error = message
+	export.CompletedAt = &completedAt
+	s.exportRepo.Update(context.WithoutCancel(ctx), export)
+}
+
+// result wraps export with its download URL
+func (s *usageExportService) result(export *models.UsageExport) *ExportResult {
+	result := &ExportResult{UsageExport: export}
+	if export.Status == models.ExportStatusCompleted {
+		result.DownloadURL = fmt.Sprintf("/api/v1/exports/%s/download", export.ID)
+	}
+	return result
+}
+
+// exportFilter builds the usage log filter of an export from its API key,
+// time range and Filters. Filters use the UsageLogFilter JSON field names;
+// unknown fields, and the fields set from the export itself, are rejected.
+func exportFilter(export *models.UsageExport) (*repositories.UsageLogFilter, error) {
+	filter := &repositories.UsageLogFilter{}
+	if len(export.Filters) > 0 {
+		for _, reserved := range []string{"api_key_id", "start_time", "end_time"} {
+			if _, ok := export.Filters[reserved]; ok {
+				return nil, fmt.Errorf("invalid export filters: %s is set by the export itself", reserved)
+			}
+		}
+
+		data, err := json.Marshal(export.Filters)
+		if err != nil {
+			return nil, fmt.Errorf("invalid export filters: %w", err)
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(filter); err != nil {
+			return nil, fmt.Errorf("invalid export filters: %w", err)
+		}
+	}
+
+	apiKeyID := export.APIKeyID
+	startTime := export.StartTime
+	endTime := export.EndTime
+	filter.APIKeyID = &apiKeyID
+	filter.StartTime = &startTime
+	filter.EndTime = &endTime
+	return filter, nil
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeUsageExportRepository keeps usage exports in memory
+type fakeUsageExportRepository struct {
+	exports map[uuid.UUID]models.UsageExport
+}
+
+func newFakeUsageExportRepository() *fakeUsageExportRepository {
+	return &fakeUsageExportRepository{exports: make(map[uuid.UUID]models.UsageExport)}
+}
+
+func (r *fakeUsageExportRepository) Create(ctx context.Context, export *models.UsageExport) error {
+	export.ID = uuid.New()
+	r.exports[export.ID] = *export
+	return nil
+}
+
+func (r *fakeUsageExportRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.UsageExport, error) {
+	export, ok := r.exports[id]
+	if !ok {
+		return nil, fmt.Errorf("usage export not found")
+	}
+	return &export, nil
+}
+
+func (r *fakeUsageExportRepository) Update(ctx context.Context, export *models.UsageExport) error {
+	r.exports[export.ID] = *export
+	return nil
+}
+
+// fakeExportUsageLogRepository serves usage logs from memory. Only the methods
+// used by exports are implemented.
+type fakeExportUsageLogRepository struct {
+	repositories.UsageLogRepository
+	logs      []*models.UsageLog
+	streamErr error
+}
+
+func (r *fakeExportUsageLogRepository) matching(filter *repositories.UsageLogFilter) []*models.UsageLog {
+	var matched []*models.UsageLog
+	for _, log := range r.logs {
+		if filter.APIKeyID != nil && log.APIKeyID != *filter.APIKeyID {
+			continue
+		}
+		if filter.StartTime != nil && log.Timestamp.Before(*filter.StartTime) {
+			continue
+		}
+		if filter.EndTime != nil && log.Timestamp.After(*filter.EndTime) {
+			continue
+		}
+		if filter.Endpoint != "" && log.Endpoint != filter.Endpoint {
+			continue
+		}
+		if filter.StatusCode != nil && log.StatusCode != *filter.StatusCode {
+			continue
+		}
+		matched = append(matched, log)
+	}
+	return matched
+}
+
+func (r *fakeExportUsageLogRepository) CountFiltered(ctx context.Context, filter *repositories.UsageLogFilter) (int64, error) {
+	return int64(len(r.matching(filter))), nil
+}
+
+func (r *fakeExportUsageLogRepository) StreamUsageLogs(ctx context.Context, filter *repositories.UsageLogFilter, batchSize int, fn func(logs []*models.UsageLog) error) error {
+	matched := r.matching(filter)
+	for start := 0; start < len(matched); start += batchSize {
+		end := start + batchSize
+		if end > len(matched) {
+			end = len(matched)
+		}
+		if err := fn(matched[start:end]); err != nil {
+			return err
+		}
+	}
+	return r.streamErr
+}
+
+// memoryBlobStore keeps blobs in memory
+type memoryBlobStore struct {
+	blobs map[string][]byte
+}
+
+func newMemoryBlobStore() *memoryBlobStore {
+	return &memoryBlobStore{blobs: make(map[string][]byte)}
+}
+
+func (s *memoryBlobStore) Put(ctx context.Context, key string, r io.Reader) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	s.blobs[key] = data
+	return int64(len(data)), nil
+}
+
+func (s *memoryBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	data, ok := s.blobs[key]
+	if !ok {
+		return nil, fmt.Errorf("blob not found")
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s *memoryBlobStore) Delete(ctx context.Context, key string) error {
+	delete(s.blobs, key)
+	return nil
+}
+
+// recordingEnqueuer records the exports handed to the worker
+type recordingEnqueuer struct {
+	enqueued []uuid.UUID
+}
+
+func (e *recordingEnqueuer) EnqueueExport(ctx context.Context, exportID uuid.UUID) error {
+	e.enqueued = append(e.enqueued, exportID)
+	return nil
+}
+
+type exportFixture struct {
+	service  UsageExportService
+	exports  *fakeUsageExportRepository
+	logs     *fakeExportUsageLogRepository
+	store    *memoryBlobStore
+	enqueuer *recordingEnqueuer
+	apiKeyID uuid.UUID
+	start    time.Time
+}
+
+// newExportFixture logs three requests of one key an hour apart, and one of
+// another key
+func newExportFixture(t *testing.T) *exportFixture {
+	f := &exportFixture{
+		exports:  newFakeUsageExportRepository(),
+		logs:     &fakeExportUsageLogRepository{},
+		store:    newMemoryBlobStore(),
+		enqueuer: &recordingEnqueuer{},
+		apiKeyID: uuid.New(),
+		start:    time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC),
+	}
+
+	for i, endpoint := range []string{"/api/v1/users", "/api/v1/orders", "/api/v1/users"} {
+		f.logs.logs = append(f.logs.logs, &models.UsageLog{
+			ID:         uint64(i + 1),
+			APIKeyID:   f.apiKeyID,
+			Endpoint:   endpoint,
+			Method:     "GET",
+			StatusCode: 200,
+			UserAgent:  `curl/8.0 "quoted", with comma`,
+			Metadata:   map[string]interface{}{"attempt": i},
+			Timestamp:  f.start.Add(time.Duration(i) * time.Hour),
+		})
+	}
+	f.logs.logs = append(f.logs.logs, &models.UsageLog{ID: 99, APIKeyID: uuid.New(), Endpoint: "/api/v1/users", Timestamp: f.start})
+
+	clock := ratelimit.NewFakeClock(f.start.Add(12 * time.Hour))
+	f.service = NewUsageExportService(f.exports, f.logs, f.store, f.enqueuer, UsageExportPolicy{
+		SyncMaxRange:   24 * time.Hour,
+		SyncMaxRecords: 100,
+		BatchSize:      2,
+	}, clock)
+	return f
+}
+
+func (f *exportFixture) request(format models.ExportFormat, filters map[string]interface{}) *ExportRequest {
+	return &ExportRequest{
+		APIKeyID:  f.apiKeyID,
+		StartTime: f.start,
+		EndTime:   f.start.Add(12 * time.Hour),
+		Format:    format,
+		Filters:   filters,
+	}
+}
+
+func (f *exportFixture) download(t *testing.T, id uuid.UUID) []byte {
+	_, file, err := f.service.OpenExport(context.Background(), id)
+	require.NoError(t, err)
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	require.NoError(t, err)
+	return data
+}
+
+func TestUsageExportServiceCSV(t *testing.T) {
+	f := newExportFixture(t)
+
+	result, err := f.service.CreateExport(context.Background(), f.request(models.ExportFormatCSV, map[string]interface{}{
+		"endpoint": "/api/v1/users",
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, models.ExportStatusCompleted, result.Status)
+	assert.Equal(t, int64(2), result.RecordCount)
+	assert.Equal(t, fmt.Sprintf("/api/v1/exports/%s/download", result.ID), result.DownloadURL)
+	assert.Equal(t, fmt.Sprintf("usage_%s_20250310T000000Z_20250310T120000Z.csv", f.apiKeyID.String()[:8]), result.FileName)
+	assert.Empty(t, f.enqueuer.enqueued)
+
+	records, err := csv.NewReader(bytes.NewReader(f.download(t, result.ID))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, usageExportColumns, records[0])
+	assert.Equal(t, []string{"1", "3"}, []string{records[1][0], records[2][0]})
+	assert.Equal(t, "2025-03-10T02:00:00Z", records[2][2])
+	assert.Equal(t, `curl/8.0 "quoted", with comma`, records[1][12])
+	assert.Equal(t, `{"attempt":0}`, records[1][13])
+}
+
+func TestUsageExportServiceNDJSONAndParquet(t *testing.T) {
+	f := newExportFixture(t)
+
+	result, err := f.service.CreateExport(context.Background(), f.request(models.ExportFormatNDJSON, nil))
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(f.download(t, result.ID))), "\n")
+	require.Len(t, lines, 3)
+	var first map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	assert.Equal(t, map[string]interface{}{"attempt": float64(0)}, first["metadata"])
+
+	result, err = f.service.CreateExport(context.Background(), f.request(models.ExportFormatParquet, nil))
+	require.NoError(t, err)
+	data := f.download(t, result.ID)
+	rows, err := parquet.Read[usageExportRow](bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, "/api/v1/orders", rows[1].Endpoint)
+	assert.True(t, f.start.Add(time.Hour).Equal(rows[1].Timestamp))
+}
+
+func TestUsageExportServiceEnqueuesLargeExports(t *testing.T) {
+	ctx := context.Background()
+	f := newExportFixture(t)
+
+	req := f.request(models.ExportFormatCSV, nil)
+	req.EndTime = req.StartTime.Add(7 * 24 * time.Hour)
+
+	result, err := f.service.CreateExport(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusPending, result.Status)
+	assert.Equal(t, []uuid.UUID{result.ID}, f.enqueuer.enqueued)
+
+	_, _, err = f.service.OpenExport(ctx, result.ID)
+	assert.EqualError(t, err, "usage export not ready")
+
+	result, err = f.service.RunExport(ctx, result.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusCompleted, result.Status)
+	assert.Equal(t, int64(3), result.RecordCount)
+
+	// Too many matching records within a short range are enqueued as well
+	service := NewUsageExportService(f.exports, f.logs, f.store, f.enqueuer, UsageExportPolicy{SyncMaxRecords: 2}, nil)
+	result, err = service.CreateExport(ctx, f.request(models.ExportFormatCSV, nil))
+	require.NoError(t, err)
+	assert.Equal(t, models.ExportStatusPending, result.Status)
+}
+
+func TestUsageExportServiceRejectsInvalidRequests(t *testing.T) {
+	f := newExportFixture(t)
+
+	for name, modify := range map[string]func(req *ExportRequest){
+		"unknown format":  func(req *ExportRequest) { req.Format = "xlsx" },
+		"empty range":     func(req *ExportRequest) { req.EndTime = req.StartTime },
+		"reserved filter": func(req *ExportRequest) { req.Filters = map[string]interface{}{"api_key_id": uuid.NewString()} },
+	} {
+		req := f.request(models.ExportFormatCSV, nil)
+		modify(req)
+		_, err := f.service.CreateExport(context.Background(), req)
+		assert.Error(t, err, name)
+	}
+	assert.Empty(t, f.exports.exports)
+}
+
+func TestUsageExportServiceRecordsFailures(t *testing.T) {
+	ctx := context.Background()
+	f := newExportFixture(t)
+	f.logs.streamErr = errors.New("connection reset")
+
+	_, err := f.service.CreateExport(ctx, f.request(models.ExportFormatCSV, nil))
+	require.Error(t, err)
+	require.Len(t, f.exports.exports, 1)
+	assert.Empty(t, f.store.blobs)
+
+	for id := range f.exports.exports {
+		result, err := f.service.GetExport(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusFailed, result.Status)
+		assert.Contains(t, result.Error, "connection reset")
+
+		// A retry writes the export again
+		f.logs.streamErr = nil
+		result, err = f.service.RunExport(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, models.ExportStatusCompleted, result.Status)
+	}
+}
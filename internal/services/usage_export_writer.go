@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+)
+
+// usageExportRow is one usage log as written to an export file. The field
+// names are the CSV header and the NDJSON and Parquet column names.
+type usageExportRow struct {
+	ID           uint64    `json:"id" parquet:"id"`
+	APIKeyID     string    `json:"api_key_id" parquet:"api_key_id,dict"`
+	Timestamp    time.Time `json:"timestamp" parquet:"timestamp,timestamp(millisecond)"`
+	Endpoint     string    `json:"endpoint" parquet:"endpoint,dict"`
+	Method       string    `json:"method" parquet:"method,dict"`
+	StatusCode   int32     `json:"status_code" parquet:"status_code"`
+	ResponseTime int32     `json:"response_time" parquet:"response_time"`
+	RequestSize  int64     `json:"request_size" parquet:"request_size"`
+	ResponseSize int64     `json:"response_size" parquet:"response_size"`
+	IPAddress    string    `json:"ip_address" parquet:"ip_address"`
+	Country      string    `json:"country" parquet:"country,dict"`
+	Region       string    `json:"region" parquet:"region,dict"`
+	UserAgent    string    `json:"user_agent" parquet:"user_agent,dict"`
+	Metadata     string    `json:"metadata,omitempty" parquet:"metadata,optional"` // JSON object
+}
+
+// usageExportColumns is the CSV header, in row order
+var usageExportColumns = []string{
+	"id", "api_key_id", "timestamp", "endpoint", "method", "status_code", "response_time",
+	"request_size", "response_size", "ip_address", "country", "region", "user_agent", "metadata",
+}
+
+// newUsageExportRow converts a usage log to an export row
+func newUsageExportRow(log *models.UsageLog) (usageExportRow, error) {
+	row := usageExportRow{
+		ID:           log.ID,
+		APIKeyID:     log.APIKeyID.String(),
+		Timestamp:    log.Timestamp.UTC(),
+		Endpoint:     log.Endpoint,
+		Method:       log.Method,
+		StatusCode:   int32(log.StatusCode),
+		ResponseTime: int32(log.ResponseTime),
+		RequestSize:  log.RequestSize,
+		ResponseSize: log.ResponseSize,
+		IPAddress:    log.IPAddress,
+		Country:      log.Country,
+		Region:       log.Region,
+		UserAgent:    log.UserAgent,
+	}
+
+	if len(log.Metadata) > 0 {
+		metadata, err := json.Marshal(log.Metadata)
+		if err != nil {
+			return row, fmt.Errorf("failed to encode metadata of usage log %d: %w", log.ID, err)
+		}
+		row.Metadata = string(metadata)
+	}
+	return row, nil
+}
+
+// usageExportWriter encodes usage logs into an export file
+type usageExportWriter interface {
+	Write(logs []*models.UsageLog) error
+	// Close writes any buffered rows and the file footer. It does not close
+	// the underlying writer.
+	Close() error
+}
+
+// newUsageExportWriter creates an export writer for format writing to w
+func newUsageExportWriter(format models.ExportFormat, w io.Writer) (usageExportWriter, error) {
+	switch format {
+	case models.ExportFormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(usageExportColumns); err != nil {
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+		return &csvExportWriter{writer: writer}, nil
+	case models.ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+	case models.ExportFormatParquet:
+		return &parquetExportWriter{writer: parquet.NewGenericWriter[usageExportRow](w)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvExportWriter writes one CSV record per usage log after a header
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (w *csvExportWriter) Write(logs []*models.UsageLog) error {
+	for _, log := range logs {
+		row, err := newUsageExportRow(log)
+		if err != nil {
+			return err
+		}
+
+		err = w.writer.Write([]string{
+			strconv.FormatUint(row.ID, 10),
+			row.APIKeyID,
+			row.Timestamp.Format(time.RFC3339Nano),
+			row.Endpoint,
+			row.Method,
+			strconv.Itoa(int(row.StatusCode)),
+			strconv.Itoa(int(row.ResponseTime)),
+			strconv.FormatInt(row.RequestSize, 10),
+			strconv.FormatInt(row.ResponseSize, 10),
+			row.IPAddress,
+			row.Country,
+			row.Region,
+			row.UserAgent,
+			row.Metadata,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// ndjsonExportWriter writes one JSON object per line
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (w *ndjsonExportWriter) Write(logs []*models.UsageLog) error {
+	for _, log := range logs {
+		row, err := newUsageExportRow(log)
+		if err != nil {
+			return err
+		}
+
+		line := struct {
+			usageExportRow
+			Metadata json.RawMessage `json:"metadata,omitempty"`
+		}{usageExportRow: row}
+		if row.Metadata != "" {
+			line.Metadata = json.RawMessage(row.Metadata)
+		}
+
+		if err := w.encoder.Encode(line); err != nil {
+			return fmt.Errorf("failed to write NDJSON record: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return nil
+}
+
+// parquetExportWriter writes row groups of usage logs to a Parquet file
+type parquetExportWriter struct {
+	writer *parquet.GenericWriter[usageExportRow]
+}
+
+func (w *parquetExportWriter) Write(logs []*models.UsageLog) error {
+	rows := make([]usageExportRow, 0, len(logs))
+	for _, log := range logs {
+		row, err := newUsageExportRow(log)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+
+	if _, err := w.writer.Write(rows); err != nil {
+		return fmt.Errorf("failed to write Parquet rows: %w", err)
+	}
+	return nil
+}
+
+func (w *parquetExportWriter) Close() error {
+	if err := w.writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish Parquet file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// UsageLogOverflowPolicy decides what happens when the usage log buffer is full
+type UsageLogOverflowPolicy string
+
+const (
+	// UsageLogOverflowDrop discards new events while the buffer is full
+	UsageLogOverflowDrop UsageLogOverflowPolicy = "drop"
+
+	// UsageLogOverflowBlock makes the caller wait up to BlockTimeout for room
+	// and drops the event if none frees up
+	UsageLogOverflowBlock UsageLogOverflowPolicy = "block"
+)
+
+// Reasons reported to UsageLogBufferMetrics.RecordUsageLogDropped
+const (
+	UsageLogDropBufferFull = "buffer_full"
+	UsageLogDropClosed     = "closed"
+	UsageLogDropSinkError  = "sink_error"
+)
+
+// ErrUsageLogBufferClosed is returned by Close when called more than once
+var ErrUsageLogBufferClosed = errors.New("usage log buffer closed")
+
+// UsageLogSink receives batches of usage events from a UsageLogBuffer
+type UsageLogSink interface {
+	WriteBatch(ctx context.Context, batch []*UsageLogRequest) error
+}
+
+// UsageLogBufferMetrics receives queue depth and drop counts from a UsageLogBuffer
+type UsageLogBufferMetrics interface {
+	SetUsageLogQueueDepth(depth float64)
+	RecordUsageLogDropped(reason string, count int)
+	RecordUsageLogFlush(result string, size int)
+}
+
+// UsageLogBufferConfig configures a UsageLogBuffer
+type UsageLogBufferConfig struct {
+	Capacity      int                    `json:"capacity"`       // events held before overflow
+	BatchSize     int                    `json:"batch_size"`     // events per sink write
+	FlushInterval time.Duration          `json:"flush_interval"` // max age of a partial batch
+	FlushTimeout  time.Duration          `json:"flush_timeout"`  // deadline for a single sink write
+	Overflow      UsageLogOverflowPolicy `json:"overflow"`
+	BlockTimeout  time.Duration          `json:"block_timeout"` // only used by the block policy
+}
+
+// DefaultUsageLogBufferConfig returns the default buffer configuration
+func DefaultUsageLogBufferConfig() UsageLogBufferConfig {
+	return UsageLogBufferConfig{
+		Capacity:      10000,
+		BatchSize:     500,
+		FlushInterval: time.Second,
+		FlushTimeout:  5 * time.Second,
+		Overflow:      UsageLogOverflowDrop,
+		BlockTimeout:  50 * time.Millisecond,
+	}
+}
+
+// withDefaults fills unset fields from DefaultUsageLogBufferConfig
+func (c UsageLogBufferConfig) withDefaults() UsageLogBufferConfig {
+	defaults := DefaultUsageLogBufferConfig()
+	if c.Capacity <= 0 {
+		c.Capacity = defaults.Capacity
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaults.BatchSize
+	}
+	if c.BatchSize > c.Capacity {
+		c.BatchSize = c.Capacity
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = defaults.FlushInterval
+	}
+	if c.FlushTimeout <= 0 {
+		c.FlushTimeout = defaults.FlushTimeout
+	}
+	if c.Overflow != UsageLogOverflowBlock {
+		c.Overflow = UsageLogOverflowDrop
+	}
+	if c.BlockTimeout <= 0 {
+		c.BlockTimeout = defaults.BlockTimeout
+	}
+	return c
+}
+
+// UsageLogBuffer decouples usage logging from request handling. Events are
+// queued in a bounded channel and written to a sink in batches by a single
+// background goroutine, so a slow database never holds up requests and the
+// number of in-flight writes stays constant under load.
+type UsageLogBuffer struct {
+	sink    UsageLogSink
+	config  UsageLogBufferConfig
+	metrics UsageLogBufferMetrics
+	logger  *zap.Logger
+
+	events chan *UsageLogRequest
+	done   chan struct{}
+
+	// mu guards closed; Enqueue holds it for reading so Close cannot close
+	// the events channel while a send is in progress
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewUsageLogBuffer creates a usage log buffer and starts its flush loop.
+// metrics and logger may be nil.
+func NewUsageLogBuffer(sink UsageLogSink, config UsageLogBufferConfig, metrics UsageLogBufferMetrics, logger *zap.Logger) *UsageLogBuffer {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	config = config.withDefaults()
+	b := &UsageLogBuffer{
+		sink:    sink,
+		config:  config,
+		metrics: metrics,
+		logger:  logger,
+		events:  make(chan *UsageLogRequest, config.Capacity),
+		done:    make(chan struct{}),
+	}
+
+	go b.run()
+	return b
+}
+
+// Enqueue queues a usage event without blocking on the sink. It returns false
+// if the event was dropped because the buffer is full or closed.
+func (b *UsageLogBuffer) Enqueue(req *UsageLogRequest) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		b.recordDropped(UsageLogDropClosed, 1)
+		return false
+	}
+
+	select {
+	case b.events <- req:
+		b.updateDepth()
+		return true
+	default:
+	}
+
+	if b.config.Overflow == UsageLogOverflowBlock {
+		timer := time.NewTimer(b.config.BlockTimeout)
+		defer timer.Stop()
+
+		select {
+		case b.events <- req:
+			b.updateDepth()
+			return true
+		case <-timer.C:
+		}
+	}
+
+	b.recordDropped(UsageLogDropBufferFull, 1)
+	return false
+}
+
+// Len returns the number of events waiting to be flushed
+func (b *UsageLogBuffer) Len() int {
+	return len(b.events)
+}
+
+// Close stops accepting events and flushes everything still buffered. It
+// returns ctx.Err() if ctx ends before the final flush completes.
+func (b *UsageLogBuffer) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrUsageLogBufferClosed
+	}
+	b.closed = true
+	close(b.events)
+	b.mu.Unlock()
+
+	select {
+	case <-b.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush usage logs: %w", ctx.Err())
+	}
+}
+
+// run collects events into batches and flushes them when a batch fills up,
+// when FlushInterval passes, and once more when the buffer is closed
+func (b *UsageLogBuffer) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*UsageLogRequest, 0, b.config.BatchSize)
+	for {
+		select {
+		case req, ok := <-b.events:
+			if !ok {
+				b.flush(batch)
+				return
+			}
+			batch = append(batch, req)
+			if len(batch) >= b.config.BatchSize {
+				b.flush(batch)
+				batch = make([]*UsageLogRequest, 0, b.config.BatchSize)
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				b.flush(batch)
+				batch = make([]*UsageLogRequest, 0, b.config.BatchSize)
+			}
+		}
+	}
+}
+
+// flush writes a batch to the sink. The write gets its own deadline because
+// the requests that produced the events have long since finished.
+func (b *UsageLogBuffer) flush(batch []*UsageLogRequest) {
+	b.updateDepth()
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), b.config.FlushTimeout)
+	defer cancel()
+
+	if err := b.sink.WriteBatch(ctx, batch); err != nil {
+		b.logger.Error("Failed to flush usage logs",
+			zap.Int("batch_size", len(batch)),
+			zap.Error(err),
+		)
+		b.recordFlush("error", len(batch))
+		b.recordDropped(UsageLogDropSinkError, len(batch))
+		return
+	}
+
+	b.recordFlush("success", len(batch))
+}
+
+func (b *UsageLogBuffer) updateDepth() {
+	if b.metrics != nil {
+		b.metrics.SetUsageLogQueueDepth(float64(len(b.events)))
+	}
+}
+
+func (b *UsageLogBuffer) recordDropped(reason string, count int) {
+	if b.metrics != nil {
+		b.metrics.RecordUsageLogDropped(reason, count)
+	}
+}
+
+func (b *UsageLogBuffer) recordFlush(result string, size int) {
+	if b.metrics != nil {
+		b.metrics.RecordUsageLogFlush(result, size)
+	}
+}
+
+// usageTrackingSink writes batches straight to the database
+type usageTrackingSink struct {
+	usageService UsageTrackingService
+}
+
+// NewUsageTrackingSink creates a sink that writes batches with BatchLogUsage
+func NewUsageTrackingSink(usageService UsageTrackingService) UsageLogSink {
+	return &usageTrackingSink{usageService: usageService}
+}
+
+// WriteBatch writes a batch of usage events to the database
+func (s *usageTrackingSink) WriteBatch(ctx context.Context, batch []*UsageLogRequest) error {
+	return s.usageService.BatchLogUsage(ctx, batch)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink collects batches and can be made to block or fail
+type recordingSink struct {
+	mu      sync.Mutex
+	batches [][]*UsageLogRequest
+	release chan struct{} // when set, writes wait until it is closed
+	err     error
+}
+
+func (s *recordingSink) WriteBatch(ctx context.Context, batch []*UsageLogRequest) error {
+	if s.release != nil {
+		select {
+		case <-s.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.batches = append(s.batches, batch)
+	return nil
+}
+
+func (s *recordingSink) events() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	total := 0
+	for _, batch := range s.batches {
+		total += len(batch)
+	}
+	return total
+}
+
+// countingMetrics records what a UsageLogBuffer reports
+type countingMetrics struct {
+	mu      sync.Mutex
+	dropped map[string]int
+	flushed map[string]int
+}
+
+func newCountingMetrics() *countingMetrics {
+	return &countingMetrics{
+		dropped: make(map[string]int),
+		flushed: make(map[string]int),
+	}
+}
+
+func (m *countingMetrics) SetUsageLogQueueDepth(depth float64) {}
+
+func (m *countingMetrics) RecordUsageLogDropped(reason string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[reason] += count
+}
+
+func (m *countingMetrics) RecordUsageLogFlush(result string, size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushed[result] += size
+}
+
+func (m *countingMetrics) droppedFor(reason string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dropped[reason]
+}
+
+func newUsageEvent() *UsageLogRequest {
+	return &UsageLogRequest{APIKeyID: uuid.New(), Endpoint: "/api/v1/test", Method: "GET", StatusCode: 200}
+}
+
+func TestUsageLogBuffer_BatchesBySize(t *testing.T) {
+	sink := &recordingSink{}
+	buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+		Capacity:      100,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	}, nil, nil)
+
+	for i := 0; i < 25; i++ {
+		require.True(t, buffer.Enqueue(newUsageEvent()))
+	}
+
+	assert.Eventually(t, func() bool { return sink.events() == 20 }, time.Second, 5*time.Millisecond)
+
+	// The partial batch is flushed on close
+	require.NoError(t, buffer.Close(context.Background()))
+	assert.Equal(t, 25, sink.events())
+	assert.Len(t, sink.batches, 3)
+}
+
+func TestUsageLogBuffer_FlushesOnInterval(t *testing.T) {
+	sink := &recordingSink{}
+	buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+		Capacity:      100,
+		BatchSize:     50,
+		FlushInterval: 10 * time.Millisecond,
+	}, nil, nil)
+	defer buffer.Close(context.Background())
+
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+	assert.Eventually(t, func() bool { return sink.events() == 1 }, time.Second, 5*time.Millisecond)
+}
+
+func TestUsageLogBuffer_OverflowPolicies(t *testing.T) {
+	for _, policy := range []UsageLogOverflowPolicy{UsageLogOverflowDrop, UsageLogOverflowBlock} {
+		t.Run(string(policy), func(t *testing.T) {
+			sink := &recordingSink{release: make(chan struct{})}
+			metrics := newCountingMetrics()
+			buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+				Capacity:      2,
+				BatchSize:     1,
+				FlushInterval: time.Hour,
+				Overflow:      policy,
+				BlockTimeout:  20 * time.Millisecond,
+			}, metrics, nil)
+
+			// The flush loop takes one event and blocks in the sink, after
+			// which the channel holds Capacity more
+			require.True(t, buffer.Enqueue(newUsageEvent()))
+			assert.Eventually(t, func() bool { return buffer.Len() == 0 }, time.Second, time.Millisecond)
+			require.True(t, buffer.Enqueue(newUsageEvent()))
+			require.True(t, buffer.Enqueue(newUsageEvent()))
+
+			start := time.Now()
+			assert.False(t, buffer.Enqueue(newUsageEvent()))
+			if policy == UsageLogOverflowBlock {
+				assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+			}
+			assert.Equal(t, 1, metrics.droppedFor(UsageLogDropBufferFull))
+
+			close(sink.release)
+			require.NoError(t, buffer.Close(context.Background()))
+			assert.Equal(t, 3, sink.events())
+		})
+	}
+}
+
+func TestUsageLogBuffer_BlockWaitsForRoom(t *testing.T) {
+	sink := &recordingSink{release: make(chan struct{})}
+	buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+		Capacity:      1,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		Overflow:      UsageLogOverflowBlock,
+		BlockTimeout:  time.Second,
+	}, nil, nil)
+
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+	assert.Eventually(t, func() bool { return buffer.Len() == 0 }, time.Second, time.Millisecond)
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		close(sink.release)
+	}()
+	assert.True(t, buffer.Enqueue(newUsageEvent()))
+
+	require.NoError(t, buffer.Close(context.Background()))
+	assert.Equal(t, 3, sink.events())
+}
+
+func TestUsageLogBuffer_Close(t *testing.T) {
+	sink := &recordingSink{err: fmt.Errorf("database unavailable")}
+	metrics := newCountingMetrics()
+	buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+		Capacity:      10,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	}, metrics, nil)
+
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+	require.NoError(t, buffer.Close(context.Background()))
+
+	// Sink failures are counted as drops rather than silently lost
+	assert.Equal(t, 2, metrics.droppedFor(UsageLogDropSinkError))
+
+	// Events after close are rejected and counted
+	assert.False(t, buffer.Enqueue(newUsageEvent()))
+	assert.Equal(t, 1, metrics.droppedFor(UsageLogDropClosed))
+	assert.ErrorIs(t, buffer.Close(context.Background()), ErrUsageLogBufferClosed)
+}
+
+func TestUsageLogBuffer_CloseRespectsDeadline(t *testing.T) {
+	sink := &recordingSink{release: make(chan struct{})}
+	defer close(sink.release)
+
+	buffer := NewUsageLogBuffer(sink, UsageLogBufferConfig{
+		Capacity:      10,
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		FlushTimeout:  time.Hour,
+	}, nil, nil)
+	require.True(t, buffer.Enqueue(newUsageEvent()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, buffer.Close(ctx), context.DeadlineExceeded)
+}
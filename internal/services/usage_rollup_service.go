@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+const (
+	// defaultRollupLateness is how long a minute bucket stays open for usage
+	// events still sitting in API buffers or the task queue
+	defaultRollupLateness = 2 * time.Minute
+
+	// defaultRollupRestatement is how far back each run rolls closed buckets
+	// up again, so usage events that arrive after their bucket closed are
+	// still counted. It covers a usage batch retried on the task queue, which
+	// waits a minute longer before each of asynq's 25 attempts.
+	defaultRollupRestatement = 6 * time.Hour
+
+	// maxRollupBucketsPerRun bounds the work done by one rollup run while
+	// catching up on a backlog
+	maxRollupBucketsPerRun = 1440
+)
+
+// UsageRollupService defines the interface for maintaining usage rollups
+type UsageRollupService interface {
+	RollupUsage(ctx context.Context) (*UsageRollupResult, error)
+	CleanupOldRollups(ctx context.Context, granularity models.RollupGranularity, retentionDays int) (int64, error)
+}
+
+// UsageRollupResult contains the outcome of a rollup run
+type UsageRollupResult struct {
+	Rows        map[models.RollupGranularity]int64     `json:"rows"`
+	Checkpoints map[models.RollupGranularity]time.Time `json:"checkpoints"`
+}
+
+// usageRollupService implements UsageRollupService interface
+type usageRollupService struct {
+	rollupRepo  repositories.UsageRollupRepository
+	lateness    time.Duration
+	restatement time.Duration
+	clock       ratelimit.Clock
+}
+
+// NewUsageRollupService creates a new usage rollup service. Each run rolls up
+// buckets closed within the last restatement again to pick up late events.
+func NewUsageRollupService(
+	rollupRepo repositories.UsageRollupRepository,
+	lateness time.Duration,
+	restatement time.Duration,
+	clock ratelimit.Clock,
+) UsageRollupService {
+	if lateness <= 0 {
+		lateness = defaultRollupLateness
+	}
+	if restatement <= 0 {
+		restatement = defaultRollupRestatement
+	}
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
+	return &usageRollupService{
+		rollupRepo:  rollupRepo,
+		lateness:    lateness,
+		restatement: restatement,
+		clock:       clock,
+	}
+}
+
+// RollupUsage rolls every granularity forward to the last bucket that can no
+// longer change. Minute buckets close once the lateness allowance has passed;
+// each coarser granularity only rolls up buckets its finer level has completed.
+// Buckets closed within the restatement window are rolled up again, which
+// replaces them, so events stored late are counted on the next run.
+func (s *usageRollupService) RollupUsage(ctx context.Context) (*UsageRollupResult, error) {
+	checkpoints, err := s.rollupRepo.GetCheckpoints(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UsageRollupResult{
+		Rows:        make(map[models.RollupGranularity]int64),
+		Checkpoints: make(map[models.RollupGranularity]time.Time),
+	}
+
+	limit := s.clock.Now().Add(-s.lateness)
+	restateFrom := limit.Add(-s.restatement)
+	for _, granularity := range models.RollupGranularities {
+		closed := granularity.Truncate(limit)
+
+		from, ok := checkpoints[granularity]
+		if !ok {
+			earliest, err := s.rollupRepo.GetEarliestSourceTime(ctx, granularity)
+			if err != nil {
+				return nil, err
+			}
+			from = closed
+			if earliest != nil && earliest.Before(closed) {
+				from = granularity.Truncate(*earliest)
+			}
+		}
+
+		to := closed
+		if maxTo := from.Add(maxRollupBucketsPerRun * granularity.Duration()); to.After(maxTo) {
+			to = maxTo
+		}
+
+		start := from
+		if ok {
+			start = minTime(from, granularity.Truncate(restateFrom))
+		}
+
+		if to.After(start) {
+			rows, err := s.rollupRepo.Rollup(ctx, granularity, start, to)
+			if err != nil {
+				return nil, err
+			}
+			result.Rows[granularity] = rows
+		}
+
+		if !ok || to.After(from) {
+			if err := s.rollupRepo.SetCheckpoint(ctx, granularity, maxTime(from, to)); err != nil {
+				return nil, err
+			}
+		}
+
+		result.Checkpoints[granularity] = maxTime(from, to)
+		limit = result.Checkpoints[granularity]
+	}
+
+	return result, nil
+}
+
+// CleanupOldRollups removes buckets of a granularity past the retention period
+func (s *usageRollupService) CleanupOldRollups(ctx context.Context, granularity models.RollupGranularity, retentionDays int) (int64, error) {
+	return s.rollupRepo.DeleteOldRollups(ctx, granularity, s.clock.Now().AddDate(0, 0, -retentionDays))
+}
+
+// maxTime returns the later of two times
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// minTime returns the earlier of two times
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/models"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+func TestUsageRollupService_RollupUsage(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 3, 10, 10, 30, 20, 0, time.UTC)
+	clock := ratelimit.NewFakeClock(start)
+
+	repo := newFakeUsageRollupRepository()
+	repo.earliest[models.RollupMinute] = time.Date(2024, 3, 10, 9, 15, 42, 0, time.UTC)
+	repo.earliest[models.RollupHour] = time.Date(2024, 3, 10, 9, 15, 0, 0, time.UTC)
+	service := NewUsageRollupService(repo, 2*time.Minute, time.Hour, clock)
+
+	// First run backfills from the earliest log to the last closed minute
+	result, err := service.RollupUsage(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []rollupCall{
+		{models.RollupMinute, time.Date(2024, 3, 10, 9, 15, 0, 0, time.UTC), time.Date(2024, 3, 10, 10, 28, 0, 0, time.UTC)},
+		{models.RollupHour, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)},
+	}, repo.calls)
+
+	// Days have no source yet, so their checkpoint starts at the current day
+	assert.Equal(t, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), result.Checkpoints[models.RollupDay])
+	assert.Equal(t, time.Date(2024, 3, 10, 10, 28, 0, 0, time.UTC), repo.checkpoints[models.RollupMinute])
+
+	// Nothing new closes within the same minute, but the last hour is rolled
+	// up again
+	repo.calls = nil
+	clock.Advance(30 * time.Second)
+	_, err = service.RollupUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []rollupCall{
+		{models.RollupMinute, time.Date(2024, 3, 10, 9, 28, 0, 0, time.UTC), time.Date(2024, 3, 10, 10, 28, 0, 0, time.UTC)},
+		{models.RollupHour, time.Date(2024, 3, 10, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC)},
+	}, repo.calls)
+	assert.Equal(t, time.Date(2024, 3, 10, 10, 28, 0, 0, time.UTC), repo.checkpoints[models.RollupMinute])
+
+	// Crossing midnight closes the minute, hour and day buckets in turn
+	repo.calls = nil
+	clock.Set(time.Date(2024, 3, 11, 0, 2, 30, 0, time.UTC))
+	_, err = service.RollupUsage(ctx)
+	require.NoError(t, err)
+
+	assert.Equal(t, []rollupCall{
+		{models.RollupMinute, time.Date(2024, 3, 10, 10, 28, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{models.RollupHour, time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+		{models.RollupDay, time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)},
+	}, repo.calls)
+}
+
+func TestUsageRollupService_CatchUpIsBounded(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	repo := newFakeUsageRollupRepository()
+	repo.checkpoints[models.RollupMinute] = now.Add(-72 * time.Hour)
+	service := NewUsageRollupService(repo, time.Minute, time.Hour, ratelimit.NewFakeClock(now))
+
+	_, err := service.RollupUsage(ctx)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, repo.calls)
+	minute := repo.calls[0]
+	assert.Equal(t, models.RollupMinute, minute.granularity)
+	assert.Equal(t, maxRollupBucketsPerRun*time.Minute, minute.to.Sub(minute.from))
+}
+
+func TestUsageRollupService_CleanupOldRollupsUsesClock(t *testing.T) {
+	now := time.Date(2024, 3, 10, 10, 30, 0, 0, time.UTC)
+	repo := newFakeUsageRollupRepository()
+	service := NewUsageRollupService(repo, time.Minute, time.Hour, ratelimit.NewFakeClock(now))
+
+	_, err := service.CleanupOldRollups(context.Background(), models.RollupHour, 30)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 9, 10, 30, 0, 0, time.UTC), repo.deleted[models.RollupHour])
+}
+
+func TestUsageRollupService_CountsLateEvents(t *testing.T) {
+	ctx := context.Background()
+	clock := ratelimit.NewFakeClock(time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC))
+	bucket := time.Date(2024, 3, 10, 9, 50, 0, 0, time.UTC)
+
+	repo := newFakeUsageRollupRepository()
+	repo.logs = []time.Time{bucket.Add(10 * time.Second)}
+	repo.earliest[models.RollupMinute] = repo.logs[0]
+	service := NewUsageRollupService(repo, 2*time.Minute, time.Hour, clock)
+
+	_, err := service.RollupUsage(ctx)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), repo.minutes[bucket])
+
+	// An event retried on the queue is stored after its bucket closed
+	clock.Advance(30 * time.Minute)
+	repo.logs = append(repo.logs, bucket.Add(40*time.Second))
+
+	_, err = service.RollupUsage(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), repo.minutes[bucket])
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/rdhawladar/viva-rate-limiter/internal/models"
 	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
 )
 
 // UsageTrackingService defines the interface for usage tracking business logic
@@ -19,8 +20,11 @@ type UsageTrackingService interface {
 	GetTopEndpoints(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod, limit int) ([]*EndpointUsageStats, error)
 	GetHourlyUsage(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod) ([]*HourlyUsageStats, error)
 	GetUsageTrends(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod) (*UsageTrends, error)
+	GetUsageTimeSeries(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod, granularity UsageGranularity, loc *time.Location) ([]*UsageTimeBucket, error)
+	GetEndpointStats(ctx context.Context, period TimePeriod) ([]*EndpointUsageStats, error)
+	GetTopAPIKeys(ctx context.Context, period TimePeriod, limit int) ([]*APIKeyUsageStats, error)
+	GetSLOReport(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*SLOReport, error)
 	CleanupOldLogs(ctx context.Context, retentionDays int) (int64, error)
-	ExportUsageData(ctx context.Context, req *ExportRequest) (*ExportResult, error)
 }
 
 // UsageLogRequest contains data for logging API usage
@@ -49,6 +53,28 @@ const (
 	TimePeriodYear  TimePeriod = "year"
 )
 
+// IsValid returns true if the period is supported
+func (p TimePeriod) IsValid() bool {
+	switch p {
+	case TimePeriodHour, TimePeriodDay, TimePeriodWeek, TimePeriodMonth, TimePeriodYear:
+		return true
+	}
+	return false
+}
+
+// UsageGranularity is the bucket size of a usage time series
+type UsageGranularity string
+
+const (
+	UsageGranularityHour UsageGranularity = "hour"
+	UsageGranularityDay  UsageGranularity = "day"
+)
+
+// IsValid returns true if the granularity is supported
+func (g UsageGranularity) IsValid() bool {
+	return g == UsageGranularityHour || g == UsageGranularityDay
+}
+
 // UsageStatistics contains aggregated usage statistics
 type UsageStatistics struct {
 	Period              TimePeriod `json:"period"`
@@ -65,6 +91,11 @@ type UsageStatistics struct {
 	RequestsByCountry   map[string]int64 `json:"requests_by_country"`
 	ErrorRate           float64    `json:"error_rate"`
 	SuccessRate         float64    `json:"success_rate"`
+	MaxResponseTime     int64                           `json:"max_response_time"`
+	Latency             repositories.LatencyPercentiles `json:"latency"`
+	StatusClasses       repositories.StatusClassCounts  `json:"status_classes"`
+	RequestBytes        int64                           `json:"request_bytes"`
+	ResponseBytes       int64                           `json:"response_bytes"`
 }
 
 // EndpointUsageStats contains usage statistics for an endpoint
@@ -75,6 +106,28 @@ type EndpointUsageStats struct {
 	AvgResponseTime float64 `json:"avg_response_time"`
 	ErrorRate       float64 `json:"error_rate"`
 	TotalBandwidth  int64   `json:"total_bandwidth"`
+	MaxResponseTime int64                           `json:"max_response_time"`
+	Latency         repositories.LatencyPercentiles `json:"latency"`
+	StatusClasses   repositories.StatusClassCounts  `json:"status_classes"`
+	RequestBytes    int64                           `json:"request_bytes"`
+	ResponseBytes   int64                           `json:"response_bytes"`
+}
+
+// SLOReport contains the latency percentiles, status class breakdown and
+// bandwidth of an API key over an arbitrary time range, overall and per
+// endpoint
+type SLOReport struct {
+	APIKeyID        uuid.UUID                       `json:"api_key_id"`
+	StartTime       time.Time                       `json:"start_time"`
+	EndTime         time.Time                       `json:"end_time"`
+	TotalRequests   int64                           `json:"total_requests"`
+	AvgResponseTime float64                         `json:"avg_response_time"`
+	MaxResponseTime int64                           `json:"max_response_time"`
+	Latency         repositories.LatencyPercentiles `json:"latency"`
+	StatusClasses   repositories.StatusClassCounts  `json:"status_classes"`
+	RequestBytes    int64                           `json:"request_bytes"`
+	ResponseBytes   int64                           `json:"response_bytes"`
+	Endpoints       []*EndpointUsageStats           `json:"endpoints"`
 }
 
 // HourlyUsageStats contains hourly usage statistics
@@ -86,6 +139,26 @@ type HourlyUsageStats struct {
 	Bandwidth       int64     `json:"bandwidth"`
 }
 
+// UsageTimeBucket contains the usage of one bucket of a time series. Start is
+// in the time zone the series was requested in.
+type UsageTimeBucket struct {
+	Start               time.Time `json:"start"`
+	TotalRequests       int64     `json:"total_requests"`
+	ErrorRequests       int64     `json:"error_requests"`
+	RateLimitedRequests int64     `json:"rate_limited_requests"`
+	AvgResponseTime     float64   `json:"avg_response_time"`
+	Bandwidth           int64     `json:"bandwidth"`
+
+	totalResponseTime int64
+}
+
+// APIKeyUsageStats contains the usage of one API key
+type APIKeyUsageStats struct {
+	APIKeyID       uuid.UUID `json:"api_key_id"`
+	TotalRequests  int64     `json:"total_requests"`
+	TotalBandwidth int64     `json:"total_bandwidth"`
+}
+
 // UsageTrends contains trend analysis data
 type UsageTrends struct {
 	Period           TimePeriod        `json:"period"`
@@ -113,30 +186,12 @@ type EndpointGrowth struct {
 	Previous   int64   `json:"previous_requests"`
 }
 
-// ExportRequest contains parameters for exporting usage data
-type ExportRequest struct {
-	APIKeyID  uuid.UUID `json:"api_key_id"`
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	Format    string    `json:"format"` // csv, json, xlsx
-	Filters   map[string]interface{} `json:"filters"`
-}
-
-// ExportResult contains the result of a data export
-type ExportResult struct {
-	FileName    string    `json:"file_name"`
-	Format      string    `json:"format"`
-	RecordCount int64     `json:"record_count"`
-	FileSize    int64     `json:"file_size"`
-	GeneratedAt time.Time `json:"generated_at"`
-	DownloadURL string    `json:"download_url"`
-}
-
 // usageTrackingService implements UsageTrackingService interface
 type usageTrackingService struct {
 	usageRepo   repositories.UsageLogRepository
 	apiKeyRepo  repositories.APIKeyRepository
 	cacheService CacheService
+	clock        ratelimit.Clock
 }
 
 // NewUsageTrackingService creates a new usage tracking service
@@ -144,11 +199,17 @@ func NewUsageTrackingService(
 	usageRepo repositories.UsageLogRepository,
 	apiKeyRepo repositories.APIKeyRepository,
 	cacheService CacheService,
+	clock ratelimit.Clock,
 ) UsageTrackingService {
+	if clock == nil {
+		clock = ratelimit.NewSystemClock()
+	}
+
 	return &usageTrackingService{
 		usageRepo:   usageRepo,
 		apiKeyRepo:  apiKeyRepo,
 		cacheService: cacheService,
+		clock:        clock,
 	}
 }
 
@@ -175,7 +236,7 @@ func (s *usageTrackingService) LogUsage(ctx context.Context, req *UsageLogReques
 	}
 
 	if usageLog.Timestamp.IsZero() {
-		usageLog.Timestamp = time.Now()
+		usageLog.Timestamp = s.clock.Now()
 	}
 
 	// Create in repository
@@ -229,7 +290,7 @@ func (s *usageTrackingService) BatchLogUsage(ctx context.Context, requests []*Us
 		}
 
 		if usageLogs[i].Timestamp.IsZero() {
-			usageLogs[i].Timestamp = time.Now()
+			usageLogs[i].Timestamp = s.clock.Now()
 		}
 
 		// Track API key usage counts
@@ -262,23 +323,10 @@ func (s *usageTrackingService) GetUsageStats(ctx context.Context, apiKeyID uuid.
 		return nil, fmt.Errorf("failed to get usage stats: %w", err)
 	}
 
-	// Get additional statistics
-	logs, err := s.usageRepo.GetUsageByAPIKey(ctx, apiKeyID, startTime, endTime)
+	// Get status code, country and endpoint breakdown
+	breakdown, err := s.usageRepo.GetUsageBreakdown(ctx, apiKeyID, startTime, endTime)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get usage logs: %w", err)
-	}
-
-	// Analyze logs for additional metrics
-	statusCodes := make(map[int]int64)
-	countries := make(map[string]int64)
-	endpointsSet := make(map[string]bool)
-
-	for _, log := range logs {
-		statusCodes[log.StatusCode]++
-		if log.Country != "" {
-			countries[log.Country]++
-		}
-		endpointsSet[log.Endpoint+":"+log.Method] = true
+		return nil, fmt.Errorf("failed to get usage breakdown: %w", err)
 	}
 
 	// Calculate rates
@@ -298,11 +346,49 @@ func (s *usageTrackingService) GetUsageStats(ctx context.Context, apiKeyID uuid.
 		RateLimitedRequests: stats.RateLimitedRequests,
 		AvgResponseTime:     stats.AvgResponseTime,
 		TotalBandwidth:      stats.TotalBandwidth,
-		UniqueEndpoints:     int64(len(endpointsSet)),
-		TopStatusCodes:      statusCodes,
-		RequestsByCountry:   countries,
+		UniqueEndpoints:     breakdown.UniqueEndpoints,
+		TopStatusCodes:      breakdown.StatusCodes,
+		RequestsByCountry:   breakdown.Countries,
 		ErrorRate:           errorRate,
 		SuccessRate:         successRate,
+		MaxResponseTime:     stats.MaxResponseTime,
+		Latency:             stats.Latency,
+		StatusClasses:       stats.StatusClasses,
+		RequestBytes:        stats.RequestBytes,
+		ResponseBytes:       stats.ResponseBytes,
+	}, nil
+}
+
+// GetSLOReport retrieves the latency percentiles, status classes and bandwidth
+// of an API key in [startTime, endTime), overall and per endpoint, busiest
+// endpoint first
+func (s *usageTrackingService) GetSLOReport(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*SLOReport, error) {
+	if !startTime.Before(endTime) {
+		return nil, fmt.Errorf("start time must be before end time")
+	}
+
+	stats, err := s.usageRepo.GetUsageStats(ctx, apiKeyID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get usage stats: %w", err)
+	}
+
+	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, &apiKeyID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
+	}
+
+	return &SLOReport{
+		APIKeyID:        apiKeyID,
+		StartTime:       startTime,
+		EndTime:         endTime,
+		TotalRequests:   stats.TotalRequests,
+		AvgResponseTime: stats.AvgResponseTime,
+		MaxResponseTime: stats.MaxResponseTime,
+		Latency:         stats.Latency,
+		StatusClasses:   stats.StatusClasses,
+		RequestBytes:    stats.RequestBytes,
+		ResponseBytes:   stats.ResponseBytes,
+		Endpoints:       toEndpointUsageStats(endpointStats),
 	}, nil
 }
 
@@ -311,30 +397,53 @@ func (s *usageTrackingService) GetUsageHistory(ctx context.Context, apiKeyID uui
 	return s.usageRepo.GetUsageByAPIKey(ctx, apiKeyID, startTime, endTime)
 }
 
-// GetTopEndpoints retrieves top endpoints by usage
+// GetTopEndpoints retrieves the endpoints an API key called most. A limit of
+// zero or less returns every endpoint.
 func (s *usageTrackingService) GetTopEndpoints(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod, limit int) ([]*EndpointUsageStats, error) {
 	startTime, endTime := s.getTimePeriodBounds(period)
 
 	// Get endpoint statistics
-	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, startTime, endTime)
+	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, &apiKeyID, startTime, endTime)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
 	}
 
-	// Convert to our format and limit results
-	results := make([]*EndpointUsageStats, 0, min(limit, len(endpointStats)))
-	for i, stat := range endpointStats {
-		if i >= limit {
-			break
+	if limit > 0 && len(endpointStats) > limit {
+		endpointStats = endpointStats[:limit]
+	}
+	return toEndpointUsageStats(endpointStats), nil
+}
+
+// GetEndpointStats retrieves usage of every endpoint across all API keys,
+// busiest first
+func (s *usageTrackingService) GetEndpointStats(ctx context.Context, period TimePeriod) ([]*EndpointUsageStats, error) {
+	startTime, endTime := s.getTimePeriodBounds(period)
+
+	endpointStats, err := s.usageRepo.GetEndpointStats(ctx, nil, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get endpoint stats: %w", err)
+	}
+
+	return toEndpointUsageStats(endpointStats), nil
+}
+
+// GetTopAPIKeys retrieves the API keys with the most requests. A limit of
+// zero or less returns every API key with usage in the period.
+func (s *usageTrackingService) GetTopAPIKeys(ctx context.Context, period TimePeriod, limit int) ([]*APIKeyUsageStats, error) {
+	startTime, endTime := s.getTimePeriodBounds(period)
+
+	usage, err := s.usageRepo.GetTopAPIKeys(ctx, startTime, endTime, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top api keys: %w", err)
+	}
+
+	results := make([]*APIKeyUsageStats, len(usage))
+	for i, keyUsage := range usage {
+		results[i] = &APIKeyUsageStats{
+			APIKeyID:       keyUsage.APIKeyID,
+			TotalRequests:  keyUsage.TotalRequests,
+			TotalBandwidth: keyUsage.TotalBandwidth,
 		}
-		results = append(results, &EndpointUsageStats{
-			Endpoint:        stat.Endpoint,
-			Method:          stat.Method,
-			TotalRequests:   stat.TotalRequests,
-			AvgResponseTime: stat.AvgResponseTime,
-			ErrorRate:       stat.ErrorRate,
-			TotalBandwidth:  0, // Would need additional query to get bandwidth per endpoint
-		})
 	}
 
 	return results, nil
@@ -357,8 +466,8 @@ func (s *usageTrackingService) GetHourlyUsage(ctx context.Context, apiKeyID uuid
 			Hour:            data.Hour,
 			TotalRequests:   data.TotalRequests,
 			AvgResponseTime: data.AvgResponseTime,
-			ErrorCount:      0, // Would need additional query
-			Bandwidth:       0, // Would need additional query
+			ErrorCount:      data.ErrorRequests,
+			Bandwidth:       data.TotalBandwidth,
 		}
 	}
 
@@ -423,41 +532,100 @@ func (s *usageTrackingService) GetUsageTrends(ctx context.Context, apiKeyID uuid
 	}, nil
 }
 
+// GetUsageTimeSeries retrieves the usage of an API key in hour or day buckets
+// of the time zone loc (UTC if nil), including empty buckets. Series are built
+// from hourly rollups, so in time zones whose offset is not a whole number of
+// hours each hour is counted in the day it starts in.
+func (s *usageTrackingService) GetUsageTimeSeries(ctx context.Context, apiKeyID uuid.UUID, period TimePeriod, granularity UsageGranularity, loc *time.Location) ([]*UsageTimeBucket, error) {
+	if !granularity.IsValid() {
+		return nil, fmt.Errorf("invalid granularity: %s", granularity)
+	}
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	startTime, endTime := s.getTimePeriodBounds(period)
+	hourlyData, err := s.usageRepo.GetHourlyUsage(ctx, apiKeyID, startTime, endTime)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hourly usage: %w", err)
+	}
+
+	var buckets []*UsageTimeBucket
+	index := make(map[int64]*UsageTimeBucket)
+	for start := bucketStart(startTime, granularity, loc); start.Before(endTime); start = nextBucket(start, granularity) {
+		bucket := &UsageTimeBucket{Start: start}
+		index[start.Unix()] = bucket
+		buckets = append(buckets, bucket)
+	}
+
+	for _, data := range hourlyData {
+		bucket, ok := index[bucketStart(data.Hour, granularity, loc).Unix()]
+		if !ok {
+			continue
+		}
+		bucket.TotalRequests += data.TotalRequests
+		bucket.ErrorRequests += data.ErrorRequests
+		bucket.RateLimitedRequests += data.RateLimitedRequests
+		bucket.Bandwidth += data.TotalBandwidth
+		bucket.totalResponseTime += data.TotalResponseTime
+	}
+
+	for _, bucket := range buckets {
+		if bucket.TotalRequests > 0 {
+			bucket.AvgResponseTime = float64(bucket.totalResponseTime) / float64(bucket.TotalRequests)
+		}
+	}
+
+	return buckets, nil
+}
+
+// bucketStart returns the start of the time series bucket containing t
+func bucketStart(t time.Time, granularity UsageGranularity, loc *time.Location) time.Time {
+	t = t.In(loc)
+	if granularity == UsageGranularityDay {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+	return t.Truncate(time.Hour)
+}
+
+// nextBucket returns the start of the bucket after the one starting at start.
+// Days follow the calendar, so they are 23 or 25 hours long on DST changes.
+func nextBucket(start time.Time, granularity UsageGranularity) time.Time {
+	if granularity == UsageGranularityDay {
+		return start.AddDate(0, 0, 1)
+	}
+	return start.Add(time.Hour)
+}
+
+// toEndpointUsageStats converts repository endpoint stats
+func toEndpointUsageStats(endpointStats []*repositories.EndpointStats) []*EndpointUsageStats {
+	results := make([]*EndpointUsageStats, len(endpointStats))
+	for i, stat := range endpointStats {
+		results[i] = &EndpointUsageStats{
+			Endpoint:        stat.Endpoint,
+			Method:          stat.Method,
+			TotalRequests:   stat.TotalRequests,
+			AvgResponseTime: stat.AvgResponseTime,
+			ErrorRate:       stat.ErrorRate,
+			TotalBandwidth:  stat.TotalBandwidth,
+			MaxResponseTime: stat.MaxResponseTime,
+			Latency:         stat.Latency,
+			StatusClasses:   stat.StatusClasses,
+			RequestBytes:    stat.RequestBytes,
+			ResponseBytes:   stat.ResponseBytes,
+		}
+	}
+	return results
+}
+
 // CleanupOldLogs removes old usage logs based on retention policy
 func (s *usageTrackingService) CleanupOldLogs(ctx context.Context, retentionDays int) (int64, error) {
 	return s.usageRepo.DeleteOldLogs(ctx, retentionDays)
 }
 
-// ExportUsageData exports usage data in the requested format
-func (s *usageTrackingService) ExportUsageData(ctx context.Context, req *ExportRequest) (*ExportResult, error) {
-	// This is a placeholder implementation
-	// In production, you'd implement actual file generation and storage
-	
-	// Get usage logs for the period
-	logs, err := s.usageRepo.GetUsageByAPIKey(ctx, req.APIKeyID, req.StartTime, req.EndTime)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get usage logs for export: %w", err)
-	}
-
-	// Generate filename
-	filename := fmt.Sprintf("usage_export_%s_%s.%s", 
-		req.APIKeyID.String()[:8], 
-		time.Now().Format("20060102_150405"), 
-		req.Format)
-
-	return &ExportResult{
-		FileName:    filename,
-		Format:      req.Format,
-		RecordCount: int64(len(logs)),
-		FileSize:    0, // Would calculate based on actual file
-		GeneratedAt: time.Now(),
-		DownloadURL: fmt.Sprintf("/api/exports/%s", filename),
-	}, nil
-}
-
 // getTimePeriodBounds calculates start and end times for a given period
 func (s *usageTrackingService) getTimePeriodBounds(period TimePeriod) (time.Time, time.Time) {
-	now := time.Now()
+	now := s.clock.Now()
 	
 	switch period {
 	case TimePeriodHour:
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/rdhawladar/viva-rate-limiter/internal/repositories"
+	"github.com/rdhawladar/viva-rate-limiter/pkg/ratelimit"
+)
+
+// fakeHourlyUsageRepository serves hourly usage and endpoint stats from
+// memory. Only the methods used by the analytics queries are implemented.
+type fakeHourlyUsageRepository struct {
+	repositories.UsageLogRepository
+	hourly         []*repositories.HourlyUsage
+	endpoints      []*repositories.EndpointStats
+	endpointKeyIDs []*uuid.UUID
+	stats          *repositories.UsageStats
+}
+
+func (r *fakeHourlyUsageRepository) GetUsageStats(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) (*repositories.UsageStats, error) {
+	return r.stats, nil
+}
+
+func (r *fakeHourlyUsageRepository) GetHourlyUsage(ctx context.Context, apiKeyID uuid.UUID, startTime, endTime time.Time) ([]*repositories.HourlyUsage, error) {
+	var usage []*repositories.HourlyUsage
+	for _, hour := range r.hourly {
+		if !hour.Hour.Before(startTime.Truncate(time.Hour)) && hour.Hour.Before(endTime) {
+			usage = append(usage, hour)
+		}
+	}
+	return usage, nil
+}
+
+func (r *fakeHourlyUsageRepository) GetEndpointStats(ctx context.Context, apiKeyID *uuid.UUID, startTime, endTime time.Time) ([]*repositories.EndpointStats, error) {
+	r.endpointKeyIDs = append(r.endpointKeyIDs, apiKeyID)
+	return r.endpoints, nil
+}
+
+func TestGetUsageTimeSeriesDaysInTimeZone(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	repo := &fakeHourlyUsageRepository{hourly: []*repositories.HourlyUsage{
+		// 23:00 EST on Saturday March 8th
+		{Hour: time.Date(2025, 3, 9, 4, 0, 0, 0, time.UTC), TotalRequests: 2, TotalResponseTime: 40, ErrorRequests: 1, TotalBandwidth: 100},
+		// Midnight EST and 23:00 EDT on Sunday March 9th, when DST starts
+		{Hour: time.Date(2025, 3, 9, 5, 0, 0, 0, time.UTC), TotalRequests: 3, TotalResponseTime: 30, RateLimitedRequests: 1, TotalBandwidth: 10},
+		{Hour: time.Date(2025, 3, 10, 3, 0, 0, 0, time.UTC), TotalRequests: 1, TotalResponseTime: 50, TotalBandwidth: 5},
+	}}
+	clock := ratelimit.NewFakeClock(time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC))
+	service := NewUsageTrackingService(repo, nil, nil, clock)
+
+	buckets, err := service.GetUsageTimeSeries(context.Background(), uuid.New(), TimePeriodWeek, UsageGranularityDay, newYork)
+	require.NoError(t, err)
+
+	// March 3rd through March 10th, including days without usage
+	require.Len(t, buckets, 8)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, newYork), buckets[0].Start)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, newYork), buckets[7].Start)
+	assert.Equal(t, int64(0), buckets[0].TotalRequests)
+
+	saturday, sunday := buckets[5], buckets[6]
+	assert.Equal(t, int64(2), saturday.TotalRequests)
+	assert.Equal(t, int64(1), saturday.ErrorRequests)
+	assert.Equal(t, float64(20), saturday.AvgResponseTime)
+	assert.Equal(t, int64(4), sunday.TotalRequests)
+	assert.Equal(t, int64(1), sunday.RateLimitedRequests)
+	assert.Equal(t, int64(15), sunday.Bandwidth)
+	assert.Equal(t, float64(20), sunday.AvgResponseTime)
+	assert.Equal(t, 23*time.Hour, buckets[7].Start.Sub(sunday.Start))
+}
+
+func TestGetUsageTimeSeriesHours(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	repo := &fakeHourlyUsageRepository{hourly: []*repositories.HourlyUsage{
+		{Hour: time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC), TotalRequests: 7},
+	}}
+	clock := ratelimit.NewFakeClock(time.Date(2025, 3, 10, 12, 30, 0, 0, time.UTC))
+	service := NewUsageTrackingService(repo, nil, nil, clock)
+
+	buckets, err := service.GetUsageTimeSeries(context.Background(), uuid.New(), TimePeriodHour, UsageGranularityHour, kolkata)
+	require.NoError(t, err)
+
+	// Hour buckets follow the hourly rollups, even where the offset is not whole hours
+	require.Len(t, buckets, 2)
+	assert.Equal(t, time.Date(2025, 3, 10, 16, 30, 0, 0, kolkata), buckets[0].Start)
+	assert.Equal(t, int64(0), buckets[0].TotalRequests)
+	assert.Equal(t, int64(7), buckets[1].TotalRequests)
+
+	_, err = service.GetUsageTimeSeries(context.Background(), uuid.New(), TimePeriodHour, "minute", nil)
+	assert.Error(t, err)
+}
+
+func TestGetTopEndpointsIsScopedToAPIKey(t *testing.T) {
+	repo := &fakeHourlyUsageRepository{endpoints: []*repositories.EndpointStats{
+		{Endpoint: "/a", Method: "GET", TotalRequests: 9, TotalBandwidth: 90},
+		{Endpoint: "/b", Method: "GET", TotalRequests: 5},
+		{Endpoint: "/c", Method: "POST", TotalRequests: 1},
+	}}
+	service := NewUsageTrackingService(repo, nil, nil, nil)
+	apiKeyID := uuid.New()
+
+	top, err := service.GetTopEndpoints(context.Background(), apiKeyID, TimePeriodDay, 2)
+	require.NoError(t, err)
+	require.Len(t, top, 2)
+	assert.Equal(t, "/a", top[0].Endpoint)
+	assert.Equal(t, int64(90), top[0].TotalBandwidth)
+
+	all, err := service.GetEndpointStats(context.Background(), TimePeriodDay)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	require.Len(t, repo.endpointKeyIDs, 2)
+	require.NotNil(t, repo.endpointKeyIDs[0])
+	assert.Equal(t, apiKeyID, *repo.endpointKeyIDs[0])
+	assert.Nil(t, repo.endpointKeyIDs[1])
+}
+
+func TestGetSLOReport(t *testing.T) {
+	repo := &fakeHourlyUsageRepository{
+		stats: &repositories.UsageStats{
+			TotalRequests:   100,
+			AvgResponseTime: 12.5,
+			MaxResponseTime: 900,
+			Latency:         repositories.LatencyPercentiles{P50: 10, P95: 80, P99: 400},
+			StatusClasses:   repositories.StatusClassCounts{Success: 90, ClientError: 8, ServerError: 2},
+			RequestBytes:    1000,
+			ResponseBytes:   50000,
+		},
+		endpoints: []*repositories.EndpointStats{
+			{Endpoint: "/a", Method: "GET", TotalRequests: 100, Latency: repositories.LatencyPercentiles{P99: 400}, ResponseBytes: 50000},
+		},
+	}
+	service := NewUsageTrackingService(repo, nil, nil, nil)
+	apiKeyID := uuid.New()
+	start := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(36 * time.Hour)
+
+	report, err := service.GetSLOReport(context.Background(), apiKeyID, start, end)
+	require.NoError(t, err)
+	assert.Equal(t, apiKeyID, report.APIKeyID)
+	assert.Equal(t, float64(80), report.Latency.P95)
+	assert.Equal(t, int64(2), report.StatusClasses.ServerError)
+	assert.Equal(t, int64(1000), report.RequestBytes)
+	require.Len(t, report.Endpoints, 1)
+	assert.Equal(t, float64(400), report.Endpoints[0].Latency.P99)
+	assert.Equal(t, int64(50000), report.Endpoints[0].ResponseBytes)
+	require.Len(t, repo.endpointKeyIDs, 1)
+	assert.Equal(t, apiKeyID, *repo.endpointKeyIDs[0])
+
+	_, err = service.GetSLOReport(context.Background(), apiKeyID, end, start)
+	assert.Error(t, err)
+}
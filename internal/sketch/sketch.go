@@ -0,0 +1,106 @@
+// Package sketch implements a mergeable quantile sketch for response times.
+//
+// Values are counted in logarithmic bins in the style of DDSketch: bin i holds
+// the values in (gamma^(i-1), gamma^i]. Any quantile read back from the sketch
+// is within RelativeAccuracy of the true value, and two sketches merge by
+// adding their bin counts. Because the bin of a value is a simple expression,
+// bins can also be computed and summed in SQL, which is how usage rollups
+// store latency distributions.
+package sketch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// RelativeAccuracy is the maximum relative error of a quantile estimate
+const RelativeAccuracy = 0.01
+
+var (
+	gamma    = (1 + RelativeAccuracy) / (1 - RelativeAccuracy)
+	logGamma = math.Log(gamma)
+)
+
+// Index returns the bin holding value. Values of 1 or less, including zero
+// response times, all share bin 0.
+func Index(value float64) int {
+	if value <= 1 {
+		return 0
+	}
+	return int(math.Ceil(math.Log(value) / logGamma))
+}
+
+// Value returns the estimate reported for values in bin index
+func Value(index int) float64 {
+	return 2 * math.Pow(gamma, float64(index)) / (gamma + 1)
+}
+
+// IndexSQL returns a PostgreSQL expression computing the bin of a numeric
+// column, matching Index
+func IndexSQL(column string) string {
+	return fmt.Sprintf("CEIL(LN(GREATEST(%s, 1)) / %.17g)::INTEGER", column, logGamma)
+}
+
+// Sketch is a quantile sketch. The zero value is not usable; create sketches
+// with New.
+type Sketch struct {
+	bins  map[int]int64
+	count int64
+}
+
+// New creates an empty sketch
+func New() *Sketch {
+	return &Sketch{bins: make(map[int]int64)}
+}
+
+// Add counts one value
+func (s *Sketch) Add(value float64) {
+	s.AddBin(Index(value), 1)
+}
+
+// AddBin counts count values in bin index
+func (s *Sketch) AddBin(index int, count int64) {
+	if count <= 0 {
+		return
+	}
+	s.bins[index] += count
+	s.count += count
+}
+
+// Merge adds every value counted by other
+func (s *Sketch) Merge(other *Sketch) {
+	for index, count := range other.bins {
+		s.AddBin(index, count)
+	}
+}
+
+// Count returns the number of values counted
+func (s *Sketch) Count() int64 {
+	return s.count
+}
+
+// Quantile returns an estimate of the q-quantile, with q between 0 and 1, or 0
+// for an empty sketch
+func (s *Sketch) Quantile(q float64) float64 {
+	if s.count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+
+	indexes := make([]int, 0, len(s.bins))
+	for index := range s.bins {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	rank := q * float64(s.count-1)
+	var seen int64
+	for _, index := range indexes {
+		seen += s.bins[index]
+		if float64(seen) > rank {
+			return Value(index)
+		}
+	}
+	return Value(indexes[len(indexes)-1])
+}
@@ -0,0 +1,59 @@
+package sketch
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantileWithinRelativeAccuracy(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	values := make([]float64, 10000)
+	s := New()
+	for i := range values {
+		// Log-normal latencies between a few and a few thousand milliseconds
+		values[i] = math.Round(math.Exp(random.NormFloat64() + 4))
+		s.Add(values[i])
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.5, 0.95, 0.99} {
+		exact := values[int(q*float64(len(values)-1))]
+		assert.InEpsilon(t, exact, s.Quantile(q), RelativeAccuracy+1e-9, "q=%v", q)
+	}
+	assert.Equal(t, int64(len(values)), s.Count())
+}
+
+func TestMergeMatchesSingleSketch(t *testing.T) {
+	whole, first, second := New(), New(), New()
+	for i := 1; i <= 1000; i++ {
+		whole.Add(float64(i))
+		if i%2 == 0 {
+			first.Add(float64(i))
+		} else {
+			second.Add(float64(i))
+		}
+	}
+
+	first.Merge(second)
+	assert.Equal(t, whole.Count(), first.Count())
+	for _, q := range []float64{0, 0.5, 0.95, 0.99, 1} {
+		assert.Equal(t, whole.Quantile(q), first.Quantile(q), "q=%v", q)
+	}
+}
+
+func TestSmallValuesAndEmptySketch(t *testing.T) {
+	assert.Equal(t, 0, Index(0))
+	assert.Equal(t, 0, Index(1))
+	assert.Equal(t, 1, Index(1.01))
+	assert.Equal(t, float64(0), New().Quantile(0.5))
+
+	s := New()
+	s.AddBin(Index(250), 3)
+	s.AddBin(Index(250), 0)
+	assert.InEpsilon(t, 250, s.Quantile(0.99), RelativeAccuracy)
+	assert.Equal(t, int64(3), s.Count())
+}
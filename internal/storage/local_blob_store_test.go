@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	store, err := NewLocalBlobStore(root)
+	require.NoError(t, err)
+
+	size, err := store.Put(ctx, "exports/key/file.csv", strings.NewReader("a,b\n1,2\n"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), size)
+
+	reader, err := store.Open(ctx, "exports/key/file.csv")
+	require.NoError(t, err)
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	assert.Equal(t, "a,b\n1,2\n", string(content))
+
+	require.NoError(t, store.Delete(ctx, "exports/key/file.csv"))
+	require.NoError(t, store.Delete(ctx, "exports/key/file.csv"))
+
+	_, err = store.Open(ctx, "exports/key/file.csv")
+	assert.EqualError(t, err, "blob not found")
+}
+
+func TestLocalBlobStoreFailedPutLeavesNoFile(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	store, err := NewLocalBlobStore(root)
+	require.NoError(t, err)
+
+	_, err = store.Put(ctx, "broken.csv", io.MultiReader(
+		strings.NewReader("partial"),
+		failingReader{},
+	))
+	require.Error(t, err)
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestLocalBlobStoreRejectsEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+
+	store, err := NewLocalBlobStore(filepath.Join(root, "blobs"))
+	require.NoError(t, err)
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../outside"} {
+		_, err := store.Put(ctx, key, strings.NewReader("x"))
+		assert.Error(t, err, key)
+	}
+	_, err = os.Stat(filepath.Join(root, "outside"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
@@ -34,7 +34,7 @@ CREATE TABLE api_keys (
 
 -- Usage logs table (partitioned by month)
 CREATE TABLE usage_logs (
-    id BIGSERIAL PRIMARY KEY,
+    id BIGSERIAL,
     api_key_id UUID NOT NULL,
     endpoint VARCHAR(255) NOT NULL,
     method VARCHAR(10) NOT NULL,
@@ -47,7 +47,8 @@ CREATE TABLE usage_logs (
     request_size BIGINT,
     response_size BIGINT,
     metadata JSONB,
-    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, timestamp)
 ) PARTITION BY RANGE (timestamp);
 
 -- Create initial partitions for usage logs (current month and next month)
@@ -99,8 +100,8 @@ CREATE TABLE alerts (
 
 -- Rate limit violations table (partitioned by month)
 CREATE TABLE rate_limit_violations (
-    id BIGSERIAL PRIMARY KEY,
-    event_id UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(),
+    id BIGSERIAL,
+    event_id UUID NOT NULL DEFAULT gen_random_uuid(),
     api_key_id UUID NOT NULL,
     endpoint VARCHAR(255) NOT NULL,
     method VARCHAR(10) NOT NULL,
@@ -117,7 +118,10 @@ CREATE TABLE rate_limit_violations (
     processed_at TIMESTAMPTZ,
     metadata JSONB,
     timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- Unique constraints on a partitioned table must include the partition key
+    PRIMARY KEY (id, timestamp),
+    UNIQUE (event_id, timestamp)
 ) PARTITION BY RANGE (timestamp);
 
 -- Create initial partitions for rate limit violations
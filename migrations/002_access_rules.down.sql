@@ -0,0 +1,9 @@
+DROP TRIGGER IF EXISTS update_access_rules_updated_at ON access_rules;
+
+DROP INDEX IF EXISTS idx_access_rules_active;
+DROP INDEX IF EXISTS idx_access_rules_deleted_at;
+DROP INDEX IF EXISTS idx_access_rules_expires_at;
+DROP INDEX IF EXISTS idx_access_rules_match_type;
+DROP INDEX IF EXISTS idx_access_rules_list_type;
+
+DROP TABLE IF EXISTS access_rules;
@@ -0,0 +1,26 @@
+-- Allowlist / blocklist entries and temporary bans
+CREATE TABLE access_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    list_type VARCHAR(10) NOT NULL,
+    match_type VARCHAR(20) NOT NULL,
+    value VARCHAR(500) NOT NULL,
+    reason VARCHAR(500),
+    source VARCHAR(20) NOT NULL DEFAULT 'manual',
+    violation_id BIGINT,
+    expires_at TIMESTAMPTZ,
+    created_by VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT chk_access_rules_list_type CHECK (list_type IN ('allow', 'deny')),
+    CONSTRAINT chk_access_rules_match_type CHECK (match_type IN ('api_key', 'ip', 'cidr', 'user_agent', 'country'))
+);
+
+CREATE INDEX idx_access_rules_list_type ON access_rules (list_type);
+CREATE INDEX idx_access_rules_match_type ON access_rules (match_type);
+CREATE INDEX idx_access_rules_expires_at ON access_rules (expires_at);
+CREATE INDEX idx_access_rules_deleted_at ON access_rules (deleted_at);
+CREATE INDEX idx_access_rules_active ON access_rules (match_type, value) WHERE deleted_at IS NULL;
+
+CREATE TRIGGER update_access_rules_updated_at BEFORE UPDATE ON access_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS usage_rollup_checkpoints;
+
+DROP INDEX IF EXISTS idx_usage_rollups_day_bucket;
+DROP INDEX IF EXISTS idx_usage_rollups_hour_bucket;
+DROP INDEX IF EXISTS idx_usage_rollups_minute_bucket;
+
+DROP TABLE IF EXISTS usage_rollups_day;
+DROP TABLE IF EXISTS usage_rollups_hour;
+DROP TABLE IF EXISTS usage_rollups_minute;
@@ -0,0 +1,33 @@
+-- Pre-aggregated usage in minute, hour and day buckets. Minute rollups are
+-- built from usage_logs, hour rollups from minute rollups and day rollups from
+-- hour rollups. Bucket starts are UTC.
+CREATE TABLE usage_rollups_minute (
+    api_key_id UUID NOT NULL,
+    bucket_start TIMESTAMPTZ NOT NULL,
+    endpoint VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    status_code INTEGER NOT NULL,
+    country VARCHAR(2) NOT NULL DEFAULT '',
+    request_count BIGINT NOT NULL DEFAULT 0,
+    total_response_time BIGINT NOT NULL DEFAULT 0,
+    max_response_time INTEGER NOT NULL DEFAULT 0,
+    request_bytes BIGINT NOT NULL DEFAULT 0,
+    response_bytes BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (api_key_id, bucket_start, endpoint, method, status_code, country)
+);
+
+CREATE TABLE usage_rollups_hour (LIKE usage_rollups_minute INCLUDING ALL);
+CREATE TABLE usage_rollups_day (LIKE usage_rollups_minute INCLUDING ALL);
+
+CREATE INDEX idx_usage_rollups_minute_bucket ON usage_rollups_minute (bucket_start);
+CREATE INDEX idx_usage_rollups_hour_bucket ON usage_rollups_hour (bucket_start);
+CREATE INDEX idx_usage_rollups_day_bucket ON usage_rollups_day (bucket_start);
+
+-- How far each rollup table is complete
+CREATE TABLE usage_rollup_checkpoints (
+    granularity VARCHAR(10) PRIMARY KEY,
+    rolled_up_to TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_usage_rollup_checkpoints_granularity CHECK (granularity IN ('minute', 'hour', 'day'))
+);
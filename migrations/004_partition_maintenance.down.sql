@@ -0,0 +1,4 @@
+-- Partitions created by the up migration may already hold usage logs and
+-- violations, so they are left in place. Expired partitions are retired by the
+-- worker's partition maintenance task.
+SELECT 1;
@@ -0,0 +1,23 @@
+-- The initial schema only created partitions for 2024. Fill the gap from
+-- January 2025 through three months past the current month; the worker's
+-- partition maintenance task keeps creating months ahead from here on.
+DO $$
+DECLARE
+    parent TEXT;
+    month DATE;
+BEGIN
+    FOREACH parent IN ARRAY ARRAY['usage_logs', 'rate_limit_violations'] LOOP
+        month := DATE '2025-01-01';
+        WHILE month <= (date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '3 months')::DATE LOOP
+            EXECUTE format(
+                'CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                parent || to_char(month, '"_y"YYYY"m"MM'),
+                parent,
+                month::TIMESTAMP AT TIME ZONE 'UTC',
+                (month + INTERVAL '1 month') AT TIME ZONE 'UTC'
+            );
+            month := (month + INTERVAL '1 month')::DATE;
+        END LOOP;
+    END LOOP;
+END
+$$;
@@ -0,0 +1,44 @@
+CREATE TYPE api_key_status AS ENUM ('active', 'suspended', 'revoked');
+CREATE TYPE api_key_tier AS ENUM ('free', 'pro', 'enterprise');
+CREATE TYPE alert_type AS ENUM ('usage_threshold', 'rate_limit_exceeded', 'billing_overage', 'security_alert', 'system_health');
+CREATE TYPE alert_severity AS ENUM ('low', 'medium', 'high', 'critical');
+CREATE TYPE alert_status AS ENUM ('active', 'resolved', 'suppressed');
+CREATE TYPE billing_period_status AS ENUM ('active', 'completed', 'processing');
+
+-- Billing records
+ALTER TABLE billing_records DROP COLUMN IF EXISTS paid_at;
+ALTER TABLE billing_records DROP COLUMN IF EXISTS payment_status;
+
+ALTER TABLE billing_records ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE billing_records ALTER COLUMN status TYPE billing_period_status USING status::billing_period_status;
+ALTER TABLE billing_records ALTER COLUMN status SET DEFAULT 'active';
+
+-- Alerts; values without an enum counterpart cannot be converted back
+DROP INDEX IF EXISTS idx_alerts_resolved;
+
+ALTER TABLE alerts DROP COLUMN IF EXISTS resolved_by;
+ALTER TABLE alerts DROP COLUMN IF EXISTS resolved;
+
+UPDATE alerts SET title = '' WHERE title IS NULL;
+ALTER TABLE alerts ALTER COLUMN title SET NOT NULL;
+ALTER TABLE alerts ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE alerts ALTER COLUMN status TYPE alert_status USING status::alert_status;
+ALTER TABLE alerts ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE alerts ALTER COLUMN severity TYPE alert_severity USING severity::alert_severity;
+ALTER TABLE alerts ALTER COLUMN type TYPE alert_type USING type::alert_type;
+
+-- API keys
+DROP INDEX IF EXISTS idx_api_keys_deleted_at;
+DROP INDEX IF EXISTS idx_api_keys_status;
+
+ALTER TABLE api_keys DROP COLUMN IF EXISTS expires_at;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS quota_limit;
+
+ALTER TABLE api_keys ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN status TYPE api_key_status USING status::api_key_status;
+ALTER TABLE api_keys ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE api_keys ALTER COLUMN tier DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN tier TYPE api_key_tier USING tier::api_key_tier;
+ALTER TABLE api_keys ALTER COLUMN tier SET DEFAULT 'free';
+
+CREATE INDEX idx_api_keys_status ON api_keys (status) WHERE status = 'active';
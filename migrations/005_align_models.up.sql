@@ -0,0 +1,49 @@
+-- Bring the schema in line with the gorm models. The models store enum-like
+-- fields as VARCHAR and have more values than the original enum types, so the
+-- enum columns are converted and the types dropped.
+
+-- API keys
+DROP INDEX IF EXISTS idx_api_keys_status;
+
+ALTER TABLE api_keys ALTER COLUMN tier DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN tier TYPE VARCHAR(20) USING tier::TEXT;
+ALTER TABLE api_keys ALTER COLUMN tier SET DEFAULT 'free';
+ALTER TABLE api_keys ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE api_keys ALTER COLUMN status TYPE VARCHAR(20) USING status::TEXT;
+ALTER TABLE api_keys ALTER COLUMN status SET DEFAULT 'active';
+
+ALTER TABLE api_keys ADD COLUMN quota_limit BIGINT DEFAULT 10000;
+ALTER TABLE api_keys ADD COLUMN expires_at TIMESTAMPTZ;
+
+CREATE INDEX idx_api_keys_status ON api_keys (status) WHERE status = 'active';
+CREATE INDEX idx_api_keys_deleted_at ON api_keys (deleted_at);
+
+-- Alerts
+ALTER TABLE alerts ALTER COLUMN type TYPE VARCHAR(50) USING type::TEXT;
+ALTER TABLE alerts ALTER COLUMN severity TYPE VARCHAR(20) USING severity::TEXT;
+ALTER TABLE alerts ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE alerts ALTER COLUMN status TYPE VARCHAR(20) USING status::TEXT;
+ALTER TABLE alerts ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE alerts ALTER COLUMN title DROP NOT NULL;
+
+ALTER TABLE alerts ADD COLUMN resolved BOOLEAN DEFAULT FALSE;
+ALTER TABLE alerts ADD COLUMN resolved_by VARCHAR(255);
+
+UPDATE alerts SET resolved = TRUE WHERE resolved_at IS NOT NULL;
+
+CREATE INDEX idx_alerts_resolved ON alerts (resolved);
+
+-- Billing records
+ALTER TABLE billing_records ALTER COLUMN status DROP DEFAULT;
+ALTER TABLE billing_records ALTER COLUMN status TYPE VARCHAR(20) USING status::TEXT;
+ALTER TABLE billing_records ALTER COLUMN status SET DEFAULT 'active';
+
+ALTER TABLE billing_records ADD COLUMN payment_status VARCHAR(20) DEFAULT 'pending';
+ALTER TABLE billing_records ADD COLUMN paid_at TIMESTAMPTZ;
+
+DROP TYPE IF EXISTS billing_period_status;
+DROP TYPE IF EXISTS alert_status;
+DROP TYPE IF EXISTS alert_severity;
+DROP TYPE IF EXISTS alert_type;
+DROP TYPE IF EXISTS api_key_tier;
+DROP TYPE IF EXISTS api_key_status;
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS update_usage_exports_updated_at ON usage_exports;
+
+DROP INDEX IF EXISTS idx_usage_exports_created_at;
+DROP INDEX IF EXISTS idx_usage_exports_api_key_id;
+
+DROP TABLE IF EXISTS usage_exports;
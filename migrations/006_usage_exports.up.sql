@@ -0,0 +1,29 @@
+-- Usage log exports and the files they produced
+CREATE TABLE usage_exports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id UUID NOT NULL,
+    format VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    start_time TIMESTAMPTZ NOT NULL,
+    end_time TIMESTAMPTZ NOT NULL,
+    filters JSONB,
+    file_name VARCHAR(255),
+    storage_key VARCHAR(500),
+    record_count BIGINT NOT NULL DEFAULT 0,
+    file_size BIGINT NOT NULL DEFAULT 0,
+    error VARCHAR(1000),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    CONSTRAINT chk_usage_exports_format CHECK (format IN ('csv', 'ndjson', 'parquet')),
+    CONSTRAINT chk_usage_exports_status CHECK (status IN ('pending', 'running', 'completed', 'failed'))
+);
+
+ALTER TABLE usage_exports ADD CONSTRAINT fk_usage_exports_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_usage_exports_api_key_id ON usage_exports (api_key_id);
+CREATE INDEX idx_usage_exports_created_at ON usage_exports (created_at DESC);
+
+CREATE TRIGGER update_usage_exports_updated_at BEFORE UPDATE ON usage_exports
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_usage_latency_day_bucket;
+DROP INDEX IF EXISTS idx_usage_latency_hour_bucket;
+DROP INDEX IF EXISTS idx_usage_latency_minute_bucket;
+
+DROP TABLE IF EXISTS usage_latency_day;
+DROP TABLE IF EXISTS usage_latency_hour;
+DROP TABLE IF EXISTS usage_latency_minute;
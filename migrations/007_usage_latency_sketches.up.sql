@@ -0,0 +1,60 @@
+-- Response time distributions alongside the usage rollups, for latency
+-- percentiles. Each row counts the requests of one API key, endpoint and method
+-- in a bucket whose response time falls in one logarithmic bin; see package
+-- internal/sketch. Bins of a coarser bucket are the sums of the bins of its
+-- finer buckets, exactly like the rollups themselves.
+CREATE TABLE usage_latency_minute (
+    api_key_id UUID NOT NULL,
+    bucket_start TIMESTAMPTZ NOT NULL,
+    endpoint VARCHAR(255) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    bin INTEGER NOT NULL,
+    request_count BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (api_key_id, bucket_start, endpoint, method, bin)
+);
+
+CREATE TABLE usage_latency_hour (LIKE usage_latency_minute INCLUDING ALL);
+CREATE TABLE usage_latency_day (LIKE usage_latency_minute INCLUDING ALL);
+
+CREATE INDEX idx_usage_latency_minute_bucket ON usage_latency_minute (bucket_start);
+CREATE INDEX idx_usage_latency_hour_bucket ON usage_latency_hour (bucket_start);
+CREATE INDEX idx_usage_latency_day_bucket ON usage_latency_day (bucket_start);
+
+-- Backfill the buckets that are already rolled up. Rollups whose raw logs have
+-- expired cannot be backfilled and report no percentiles. The bin expression
+-- must match sketch.IndexSQL.
+INSERT INTO usage_latency_minute (api_key_id, bucket_start, endpoint, method, bin, request_count)
+SELECT
+    api_key_id,
+    date_trunc('minute', timestamp AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+    endpoint,
+    method,
+    CEIL(LN(GREATEST(COALESCE(response_time, 0), 1)) / 0.020000666706669435)::INTEGER,
+    COUNT(*)
+FROM usage_logs
+WHERE timestamp < (SELECT rolled_up_to FROM usage_rollup_checkpoints WHERE granularity = 'minute')
+GROUP BY 1, 2, 3, 4, 5;
+
+INSERT INTO usage_latency_hour (api_key_id, bucket_start, endpoint, method, bin, request_count)
+SELECT
+    api_key_id,
+    date_trunc('hour', bucket_start AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+    endpoint,
+    method,
+    bin,
+    SUM(request_count)
+FROM usage_latency_minute
+WHERE bucket_start < (SELECT rolled_up_to FROM usage_rollup_checkpoints WHERE granularity = 'hour')
+GROUP BY 1, 2, 3, 4, 5;
+
+INSERT INTO usage_latency_day (api_key_id, bucket_start, endpoint, method, bin, request_count)
+SELECT
+    api_key_id,
+    date_trunc('day', bucket_start AT TIME ZONE 'UTC') AT TIME ZONE 'UTC',
+    endpoint,
+    method,
+    bin,
+    SUM(request_count)
+FROM usage_latency_hour
+WHERE bucket_start < (SELECT rolled_up_to FROM usage_rollup_checkpoints WHERE granularity = 'day')
+GROUP BY 1, 2, 3, 4, 5;
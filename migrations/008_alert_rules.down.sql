@@ -0,0 +1,12 @@
+DROP INDEX IF EXISTS idx_alerts_rule_id;
+ALTER TABLE alerts DROP CONSTRAINT IF EXISTS fk_alerts_rule_id;
+ALTER TABLE alerts DROP COLUMN IF EXISTS rule_id;
+
+DROP TRIGGER IF EXISTS update_alert_rules_updated_at ON alert_rules;
+
+DROP INDEX IF EXISTS idx_alert_rules_deleted_at;
+DROP INDEX IF EXISTS idx_alert_rules_team_id;
+DROP INDEX IF EXISTS idx_alert_rules_api_key_id;
+DROP INDEX IF EXISTS idx_alert_rules_enabled;
+
+DROP TABLE IF EXISTS alert_rules;
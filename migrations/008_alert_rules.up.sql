@@ -0,0 +1,54 @@
+-- Alert rules evaluated by the alert service against every active API key in
+-- their scope. A rule without api_key_id, tier or team_id applies to all keys.
+CREATE TABLE alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    description VARCHAR(500),
+    type VARCHAR(50) NOT NULL,
+    severity VARCHAR(20) NOT NULL,
+    conditions JSONB NOT NULL,
+    match_mode VARCHAR(10) NOT NULL DEFAULT 'all',
+    api_key_id UUID,
+    tier VARCHAR(20),
+    team_id UUID,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    cooldown_minutes INTEGER NOT NULL DEFAULT 0,
+    created_by VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at TIMESTAMPTZ,
+    CONSTRAINT chk_alert_rules_match_mode CHECK (match_mode IN ('all', 'any')),
+    CONSTRAINT chk_alert_rules_cooldown CHECK (cooldown_minutes >= 0)
+);
+
+CREATE INDEX idx_alert_rules_enabled ON alert_rules (enabled) WHERE deleted_at IS NULL;
+CREATE INDEX idx_alert_rules_api_key_id ON alert_rules (api_key_id);
+CREATE INDEX idx_alert_rules_team_id ON alert_rules (team_id);
+CREATE INDEX idx_alert_rules_deleted_at ON alert_rules (deleted_at);
+
+ALTER TABLE alert_rules ADD CONSTRAINT fk_alert_rules_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+CREATE TRIGGER update_alert_rules_updated_at BEFORE UPDATE ON alert_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Alerts remember the rule that raised them, so cooldowns apply per rule
+ALTER TABLE alerts ADD COLUMN rule_id UUID;
+ALTER TABLE alerts ADD CONSTRAINT fk_alerts_rule_id
+    FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE SET NULL;
+CREATE INDEX idx_alerts_rule_id ON alerts (rule_id, created_at DESC);
+
+-- The rules previously built into the alert service
+INSERT INTO alert_rules (id, name, description, type, severity, conditions, cooldown_minutes, created_by) VALUES
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e01', 'High Rate Limit Violations', 'Ten or more rate limit violations in ten minutes',
+     'rate_limit', 'high', '{"rate_limit_violations": {"operator": "gte", "value": 10}, "time_window_minutes": 10}', 15, 'system'),
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e02', 'Quota Nearly Exhausted', 'Ninety percent of the quota used',
+     'quota_exceeded', 'medium', '{"quota_usage_percent": {"operator": "gte", "value": 90}, "time_window_minutes": 5}', 60, 'system'),
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e03', 'High Error Rate', 'Twenty percent or more failed requests in fifteen minutes',
+     'system_error', 'high', '{"error_rate_percent": {"operator": "gte", "value": 20}, "time_window_minutes": 15}', 30, 'system'),
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e04', 'Slow Response Time', 'Average response time of five seconds or more in ten minutes',
+     'system_error', 'medium', '{"response_time_ms": {"operator": "gte", "value": 5000}, "time_window_minutes": 10}', 30, 'system'),
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e05', 'Unusual Traffic Spike', 'Traffic in fifteen minutes far above the seasonal baseline of the last two weeks',
+     'abnormal_usage', 'medium', '{"unusual_traffic_spike": {"multiplier": 3, "baseline_minutes": 20160}, "time_window_minutes": 15}', 60, 'system'),
+    ('6f1d7c2e-3b0a-4c55-9e51-0a1b2c3d4e06', 'New Access Pattern', 'Countries, IP addresses or user agents not seen in the last week',
+     'security_issue', 'high', '{"new_access_pattern": {"baseline_minutes": 10080, "min_new_countries": 1, "min_new_ips": 10, "min_new_user_agents": 3}, "time_window_minutes": 60}', 60, 'system');
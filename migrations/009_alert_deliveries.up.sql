@@ -0,0 +1,3 @@
+-- Notification state of each alert by channel, e.g.
+-- {"slack": {"status": "sent", "attempts": 1, "sent_at": "..."}}
+ALTER TABLE alerts ADD COLUMN deliveries JSONB;
@@ -0,0 +1,16 @@
+ALTER TABLE alert_rules DROP COLUMN IF EXISTS escalation;
+
+DROP INDEX IF EXISTS idx_alerts_snoozed_until;
+DROP INDEX IF EXISTS idx_alerts_dedup_key;
+
+ALTER TABLE alerts DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE alerts DROP COLUMN IF EXISTS occurrences;
+ALTER TABLE alerts DROP COLUMN IF EXISTS dedup_key;
+ALTER TABLE alerts DROP COLUMN IF EXISTS escalated_at;
+ALTER TABLE alerts DROP COLUMN IF EXISTS escalation_level;
+ALTER TABLE alerts DROP COLUMN IF EXISTS snoozed_until;
+ALTER TABLE alerts DROP COLUMN IF EXISTS acknowledged_by;
+ALTER TABLE alerts DROP COLUMN IF EXISTS acknowledged_at;
+
+ALTER TABLE alerts ALTER COLUMN status SET DEFAULT 'active';
+UPDATE alerts SET status = CASE WHEN resolved THEN 'resolved' ELSE 'active' END;
@@ -0,0 +1,25 @@
+-- Alert lifecycle: alerts move from open to acknowledged, snoozed or resolved.
+-- The status column from the initial schema was never written by the models,
+-- so it is rebuilt from resolved.
+UPDATE alerts SET status = CASE WHEN resolved THEN 'resolved' ELSE 'open' END;
+ALTER TABLE alerts ALTER COLUMN status SET DEFAULT 'open';
+
+ALTER TABLE alerts ADD COLUMN acknowledged_at TIMESTAMPTZ;
+ALTER TABLE alerts ADD COLUMN acknowledged_by VARCHAR(255);
+ALTER TABLE alerts ADD COLUMN snoozed_until TIMESTAMPTZ;
+ALTER TABLE alerts ADD COLUMN escalation_level INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE alerts ADD COLUMN escalated_at TIMESTAMPTZ;
+
+-- Repeated conditions update the unresolved alert with the same dedup key
+ALTER TABLE alerts ADD COLUMN dedup_key VARCHAR(255);
+ALTER TABLE alerts ADD COLUMN occurrences INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE alerts ADD COLUMN last_seen_at TIMESTAMPTZ;
+
+UPDATE alerts SET last_seen_at = created_at;
+
+CREATE UNIQUE INDEX idx_alerts_dedup_key ON alerts (dedup_key)
+    WHERE dedup_key IS NOT NULL AND status <> 'resolved';
+CREATE INDEX idx_alerts_snoozed_until ON alerts (snoozed_until) WHERE status = 'snoozed';
+
+-- Escalation policies of alert rules
+ALTER TABLE alert_rules ADD COLUMN escalation JSONB;
@@ -0,0 +1,7 @@
+-- Scopes granted to API keys for management endpoints. Every key could use
+-- alerts before scopes existed, so existing keys keep that access. The admin
+-- scope reaches every organization's data and is only ever granted to
+-- operator keys explicitly.
+ALTER TABLE api_keys ADD COLUMN scopes JSONB NOT NULL DEFAULT '[]';
+
+UPDATE api_keys SET scopes = '["alerts:read", "alerts:write"]';
@@ -0,0 +1,8 @@
+ALTER TABLE billing_records DROP COLUMN IF EXISTS line_items;
+
+DROP TRIGGER IF EXISTS record_api_keys_tier_change ON api_keys;
+DROP FUNCTION IF EXISTS record_api_key_tier_change();
+DROP TABLE IF EXISTS api_key_tier_changes;
+
+DROP TRIGGER IF EXISTS update_pricing_plans_updated_at ON pricing_plans;
+DROP TABLE IF EXISTS pricing_plans;
@@ -0,0 +1,60 @@
+-- Pricing of each API key tier. Overage tiers bound the number of requests
+-- beyond the included ones and are priced per 1,000 requests.
+CREATE TABLE pricing_plans (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    tier VARCHAR(20) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT 'USD',
+    base_fee DECIMAL(10,4) NOT NULL DEFAULT 0,
+    included_requests BIGINT NOT NULL DEFAULT 0,
+    overage_mode VARCHAR(20) NOT NULL DEFAULT 'graduated',
+    overage_tiers JSONB NOT NULL DEFAULT '[]',
+    included_bandwidth BIGINT NOT NULL DEFAULT 0,
+    bandwidth_price_per_gb DECIMAL(10,4) NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_pricing_plans_overage_mode CHECK (overage_mode IN ('graduated', 'volume'))
+);
+
+CREATE UNIQUE INDEX idx_pricing_plans_tier ON pricing_plans (tier);
+
+CREATE TRIGGER update_pricing_plans_updated_at BEFORE UPDATE ON pricing_plans
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+INSERT INTO pricing_plans (tier, name, base_fee, included_requests, overage_mode, overage_tiers,
+                           included_bandwidth, bandwidth_price_per_gb) VALUES
+    ('free', 'Free', 0, 100000, 'graduated', '[]', 1000000000, 0),
+    ('pro', 'Pro', 49, 1000000, 'graduated',
+     '[{"up_to": 9000000, "unit_price": 0.5}, {"up_to": 0, "unit_price": 0.3}]', 50000000000, 0.09),
+    ('enterprise', 'Enterprise', 499, 20000000, 'volume',
+     '[{"up_to": 80000000, "unit_price": 0.2}, {"up_to": 0, "unit_price": 0.1}]', 500000000000, 0.05);
+
+-- Tier history of API keys, so billing can prorate mid-period tier changes
+CREATE TABLE api_key_tier_changes (
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id UUID NOT NULL,
+    from_tier VARCHAR(20) NOT NULL,
+    to_tier VARCHAR(20) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_key_tier_changes_api_key_id ON api_key_tier_changes (api_key_id, changed_at);
+
+ALTER TABLE api_key_tier_changes ADD CONSTRAINT fk_api_key_tier_changes_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION record_api_key_tier_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO api_key_tier_changes (api_key_id, from_tier, to_tier, changed_at)
+    VALUES (NEW.id, OLD.tier, NEW.tier, NOW());
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_api_keys_tier_change AFTER UPDATE OF tier ON api_keys
+    FOR EACH ROW WHEN (OLD.tier IS DISTINCT FROM NEW.tier)
+    EXECUTE FUNCTION record_api_key_tier_change();
+
+-- Charges making up each billing record
+ALTER TABLE billing_records ADD COLUMN line_items JSONB;
@@ -0,0 +1,10 @@
+ALTER TABLE api_keys DROP COLUMN IF EXISTS suspended_reason;
+
+DROP INDEX IF EXISTS idx_billing_records_payment_status;
+DROP INDEX IF EXISTS idx_billing_records_payment_charge_id;
+ALTER TABLE billing_records DROP COLUMN IF EXISTS payment_failure_reason;
+ALTER TABLE billing_records DROP COLUMN IF EXISTS payment_charge_id;
+ALTER TABLE billing_records DROP COLUMN IF EXISTS payment_provider;
+
+DROP TABLE IF EXISTS payment_events;
+DROP TABLE IF EXISTS payment_customers;
@@ -0,0 +1,38 @@
+-- Customers created at the payment provider for API keys
+CREATE TABLE payment_customers (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id UUID NOT NULL,
+    provider VARCHAR(20) NOT NULL,
+    customer_id VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_payment_customers_api_key_provider ON payment_customers (api_key_id, provider);
+
+ALTER TABLE payment_customers ADD CONSTRAINT fk_payment_customers_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+-- Provider webhook events already applied, so redeliveries are ignored
+CREATE TABLE payment_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    provider VARCHAR(20) NOT NULL,
+    event_id VARCHAR(255) NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    charge_id VARCHAR(255),
+    billing_record_id UUID,
+    received_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_payment_events_provider_event_id ON payment_events (provider, event_id);
+
+-- The charge paying each billing record
+ALTER TABLE billing_records ADD COLUMN payment_provider VARCHAR(20);
+ALTER TABLE billing_records ADD COLUMN payment_charge_id VARCHAR(255);
+ALTER TABLE billing_records ADD COLUMN payment_failure_reason VARCHAR(500);
+
+CREATE INDEX idx_billing_records_payment_charge_id ON billing_records (payment_charge_id)
+    WHERE payment_charge_id IS NOT NULL;
+CREATE INDEX idx_billing_records_payment_status ON billing_records (payment_status, period_end);
+
+-- Why an API key is suspended. Dunning only reinstates the keys it suspended.
+ALTER TABLE api_keys ADD COLUMN suspended_reason VARCHAR(20);
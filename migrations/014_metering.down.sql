@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS metering_checkpoints;
+DROP TABLE IF EXISTS metering_records;
@@ -0,0 +1,20 @@
+-- Hourly usage records exported to external billing systems. Records are
+-- generated once from completed hourly rollups and delivered to every sink.
+CREATE TABLE metering_records (
+    id VARCHAR(64) PRIMARY KEY,
+    api_key_id UUID NOT NULL,
+    metric VARCHAR(40) NOT NULL,
+    quantity BIGINT NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    period_end TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_metering_records_period_start ON metering_records (period_start);
+
+-- How far records have been generated ("generated") and delivered to each sink
+CREATE TABLE metering_checkpoints (
+    name VARCHAR(50) PRIMARY KEY,
+    through TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
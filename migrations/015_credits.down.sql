@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS credit_ledger_entries;
+DROP TABLE IF EXISTS credit_accounts;
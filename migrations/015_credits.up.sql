@@ -0,0 +1,51 @@
+-- Prepaid credits of an API key or a team
+CREATE TABLE credit_accounts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    api_key_id UUID,
+    team_id UUID,
+    balance DECIMAL(18,6) NOT NULL DEFAULT 0,
+    monthly_spend_cap DECIMAL(18,6),
+    period_start TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    period_spend DECIMAL(18,6) NOT NULL DEFAULT 0,
+    alert_thresholds JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT chk_credit_accounts_owner CHECK ((api_key_id IS NULL) <> (team_id IS NULL))
+);
+
+CREATE UNIQUE INDEX idx_credit_accounts_api_key_id ON credit_accounts (api_key_id);
+CREATE UNIQUE INDEX idx_credit_accounts_team_id ON credit_accounts (team_id);
+
+ALTER TABLE credit_accounts ADD CONSTRAINT fk_credit_accounts_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+-- Top-ups, hourly debits and expiries of credit accounts
+CREATE TABLE credit_ledger_entries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL REFERENCES credit_accounts(id) ON DELETE CASCADE,
+    type VARCHAR(20) NOT NULL,
+    amount DECIMAL(18,6) NOT NULL,
+    description VARCHAR(500),
+    reference VARCHAR(255),
+    expires_at TIMESTAMPTZ,
+    expired_at TIMESTAMPTZ,
+    period_start TIMESTAMPTZ,
+    requests BIGINT NOT NULL DEFAULT 0,
+    topup_id UUID,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_credit_ledger_entries_account_id ON credit_ledger_entries (account_id, created_at);
+
+-- One debit entry per account and hour, accumulated by every request
+CREATE UNIQUE INDEX idx_credit_ledger_entries_debit_period ON credit_ledger_entries (account_id, period_start)
+    WHERE type = 'debit';
+
+-- A purchase is credited once
+CREATE UNIQUE INDEX idx_credit_ledger_entries_reference ON credit_ledger_entries (account_id, reference)
+    WHERE type = 'topup' AND reference <> '';
+
+-- Top-ups waiting to expire
+CREATE INDEX idx_credit_ledger_entries_expiring ON credit_ledger_entries (expires_at)
+    WHERE type = 'topup' AND expires_at IS NOT NULL AND expired_at IS NULL;
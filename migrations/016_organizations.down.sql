@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_api_keys_organization_id;
+ALTER TABLE api_keys DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS team_members;
+DROP TABLE IF EXISTS teams;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS organizations;
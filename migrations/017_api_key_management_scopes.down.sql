@@ -0,0 +1,10 @@
+UPDATE api_keys k
+SET scopes = k.scopes - g.scopes
+FROM (
+    SELECT api_key_id, array_agg(scope::TEXT) AS scopes
+    FROM api_key_management_scope_grants
+    GROUP BY api_key_id
+) g
+WHERE g.api_key_id = k.id;
+
+DROP TABLE IF EXISTS api_key_management_scope_grants;
@@ -0,0 +1,26 @@
+-- Management endpoints now require scopes. Every key could use the endpoints
+-- outside /admin until now, so every key keeps that access.
+
+-- Scopes added here are recorded so the down migration removes only those
+-- and leaves the ones a key already held
+CREATE TABLE api_key_management_scope_grants (
+    api_key_id UUID NOT NULL,
+    scope VARCHAR(50) NOT NULL,
+    PRIMARY KEY (api_key_id, scope)
+);
+
+INSERT INTO api_key_management_scope_grants (api_key_id, scope)
+SELECT k.id, s.scope
+FROM api_keys k
+CROSS JOIN unnest(ARRAY['ratelimit:check', 'keys:read', 'keys:write', 'org:read', 'org:write']) AS s(scope)
+WHERE NOT k.scopes @> '["admin"]'::jsonb
+  AND NOT k.scopes @> jsonb_build_array(s.scope);
+
+-- Keys may already hold some of these scopes, so the array is rebuilt
+-- without duplicates
+UPDATE api_keys
+SET scopes = (
+    SELECT jsonb_agg(DISTINCT e)
+    FROM jsonb_array_elements(scopes || '["ratelimit:check", "keys:read", "keys:write", "org:read", "org:write"]'::jsonb) e
+)
+WHERE NOT scopes @> '["admin"]'::jsonb;
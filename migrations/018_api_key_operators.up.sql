@@ -0,0 +1,15 @@
+-- Operator keys run the service for every organization. The admin scope now
+-- only applies to them, and no key is an operator until it is marked as one:
+--   UPDATE api_keys SET is_operator = true, scopes = '["admin"]' WHERE id = '...';
+ALTER TABLE api_keys ADD COLUMN is_operator BOOLEAN NOT NULL DEFAULT false;
+
+-- Keys could be created with admin, which 017 left alone. Keys that had only
+-- admin get the scopes every other existing key has instead.
+UPDATE api_keys
+SET scopes = '["ratelimit:check", "keys:read", "keys:write", "org:read", "org:write", "alerts:read", "alerts:write"]'
+WHERE scopes = '["admin"]'::jsonb;
+
+-- Keys that were granted admin alongside other scopes keep the others
+UPDATE api_keys
+SET scopes = scopes - 'admin'
+WHERE scopes @> '["admin"]'::jsonb;
@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS record_api_keys_status_change ON api_keys;
+DROP FUNCTION IF EXISTS record_api_key_status_change();
+DROP TABLE IF EXISTS api_key_status_changes;
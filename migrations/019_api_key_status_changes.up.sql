@@ -0,0 +1,29 @@
+-- Status history of API keys, so billing can charge a key for the part of a
+-- period it was active. Keys whose status changed before this migration are
+-- treated as having had their current status all along.
+CREATE TABLE api_key_status_changes (
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id UUID NOT NULL,
+    from_status VARCHAR(20) NOT NULL,
+    to_status VARCHAR(20) NOT NULL,
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_api_key_status_changes_api_key_id ON api_key_status_changes (api_key_id, changed_at);
+CREATE INDEX idx_api_key_status_changes_changed_at ON api_key_status_changes (changed_at);
+
+ALTER TABLE api_key_status_changes ADD CONSTRAINT fk_api_key_status_changes_api_key_id
+    FOREIGN KEY (api_key_id) REFERENCES api_keys(id) ON DELETE CASCADE;
+
+CREATE OR REPLACE FUNCTION record_api_key_status_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO api_key_status_changes (api_key_id, from_status, to_status, changed_at)
+    VALUES (NEW.id, OLD.status, NEW.status, NOW());
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER record_api_keys_status_change AFTER UPDATE OF status ON api_keys
+    FOR EACH ROW WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION record_api_key_status_change();
@@ -0,0 +1,10 @@
+// Package migrations embeds the numbered SQL migrations so the binaries can
+// apply them without the files being present on disk.
+package migrations
+
+import "embed"
+
+// FS holds every <version>_<name>.up.sql and .down.sql file
+//
+//go:embed *.sql
+var FS embed.FS
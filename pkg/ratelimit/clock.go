@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock abstracts time so that window calculations, cleanup loops and
+// retry-after values can be driven deterministically in tests.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a ticker that delivers ticks every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker is the subset of time.Ticker used by the library.
+type Ticker interface {
+	// C returns the channel on which ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker.
+	Stop()
+}
+
+// NewSystemClock returns a Clock backed by the standard time package.
+func NewSystemClock() Clock {
+	return systemClock{}
+}
+
+// systemClock implements Clock using the real wall clock.
+type systemClock struct{}
+
+// Now returns time.Now().
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker wraps time.NewTicker.
+func (systemClock) NewTicker(d time.Duration) Ticker {
+	return &systemTicker{ticker: time.NewTicker(d)}
+}
+
+// systemTicker adapts *time.Ticker to the Ticker interface.
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+// C returns the underlying ticker channel.
+func (t *systemTicker) C() <-chan time.Time {
+	return t.ticker.C
+}
+
+// Stop stops the underlying ticker.
+func (t *systemTicker) Stop() {
+	t.ticker.Stop()
+}
+
+// FakeClock is a manually advanced Clock intended for tests.
+// Time only moves when Advance or Set is called, and tickers created from it
+// fire synchronously as their deadlines are crossed.
+type FakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+}
+
+// NewFakeClock creates a FakeClock starting at the given time.
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the fake current time.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker that fires when the fake time is advanced past its period.
+func (c *FakeClock) NewTicker(d time.Duration) Ticker {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{
+		clock:  c,
+		period: d,
+		next:   c.now.Add(d),
+		ch:     make(chan time.Time, 1),
+	}
+	c.tickers = append(c.tickers, t)
+	return t
+}
+
+// Advance moves the fake time forward by d and fires any tickers that became due.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	tickers := make([]*fakeTicker, len(c.tickers))
+	copy(tickers, c.tickers)
+	c.mu.Unlock()
+
+	for _, t := range tickers {
+		t.fire(now)
+	}
+}
+
+// Set moves the fake time to t. Moving backwards does not fire tickers.
+func (c *FakeClock) Set(t time.Time) {
+	c.mu.Lock()
+	forward := t.After(c.now)
+	delta := t.Sub(c.now)
+	c.mu.Unlock()
+
+	if forward {
+		c.Advance(delta)
+		return
+	}
+
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+// removeTicker detaches a stopped ticker from the clock.
+func (c *FakeClock) removeTicker(t *fakeTicker) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, existing := range c.tickers {
+		if existing == t {
+			c.tickers = append(c.tickers[:i], c.tickers[i+1:]...)
+			return
+		}
+	}
+}
+
+// fakeTicker is a Ticker driven by a FakeClock.
+type fakeTicker struct {
+	clock  *FakeClock
+	mu     sync.Mutex
+	period time.Duration
+	next   time.Time
+	ch     chan time.Time
+}
+
+// C returns the tick channel.
+func (t *fakeTicker) C() <-chan time.Time {
+	return t.ch
+}
+
+// Stop detaches the ticker from its clock.
+func (t *fakeTicker) Stop() {
+	t.clock.removeTicker(t)
+}
+
+// fire delivers a tick if the deadline has passed. Like time.Ticker, ticks are
+// dropped rather than queued when the receiver is slow.
+func (t *fakeTicker) fire(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.period <= 0 || now.Before(t.next) {
+		return
+	}
+
+	for !now.Before(t.next) {
+		t.next = t.next.Add(t.period)
+	}
+
+	select {
+	case t.ch <- now:
+	default:
+	}
+}
@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// reservedKeyPrefix follows the namespace in the backend keys a limit store
+// keeps for itself, such as the Redis override hash. Counter keys of user keys
+// starting with "_" get a second "_", so no counter key can start with the
+// namespace followed by reservedKeyPrefix.
+const reservedKeyPrefix = "_sys:"
+
+// counterKey returns the backend key that holds the counter of key
+func counterKey(namespace, key string) string {
+	if strings.HasPrefix(key, "_") {
+		return namespace + "_" + key
+	}
+	return namespace + key
+}
+
+// LimitConfig is the limit that applies to a key.
+type LimitConfig struct {
+	// Limit is the maximum number of requests allowed in the window
+	Limit int `json:"limit"`
+
+	// Window is the time window for the rate limit
+	Window time.Duration `json:"window"`
+
+	// Unlimited is set when a policy exempts the key from rate limiting.
+	// Overrides stored with SetLimit are never unlimited.
+	Unlimited bool `json:"unlimited,omitempty"`
+}
+
+// LimitStore persists per-key limit overrides.
+// Backends that implement it share overrides between every limiter using the same
+// storage, so SetLimit on one instance takes effect on all of them. Keys are scoped
+// by namespace, which the limiter sets to its KeyPrefix.
+type LimitStore interface {
+	// SaveLimit stores an override for key and notifies subscribers.
+	SaveLimit(ctx context.Context, namespace, key string, config LimitConfig) error
+
+	// LoadLimit returns the override for key. The boolean is false if none is set.
+	LoadLimit(ctx context.Context, namespace, key string) (LimitConfig, bool, error)
+
+	// DeleteLimit removes the override for key and notifies subscribers.
+	DeleteLimit(ctx context.Context, namespace, key string) error
+
+	// ListLimits returns every override in the namespace.
+	ListLimits(ctx context.Context, namespace string) (map[string]LimitConfig, error)
+
+	// SubscribeLimits calls onChange with the key whenever an override in the
+	// namespace is saved or deleted, by this or any other process.
+	// The returned function cancels the subscription.
+	SubscribeLimits(ctx context.Context, namespace string, onChange func(key string)) (func() error, error)
+}
+
+// memoryLimitStore is an in-process LimitStore. It backs MemoryBackend and is used
+// by the limiter when its backend does not implement LimitStore.
+type memoryLimitStore struct {
+	mu          sync.RWMutex
+	limits      map[string]map[string]LimitConfig
+	subscribers map[string]map[int]func(string)
+	nextID      int
+}
+
+// newMemoryLimitStore creates an empty in-process limit store.
+func newMemoryLimitStore() *memoryLimitStore {
+	return &memoryLimitStore{
+		limits:      make(map[string]map[string]LimitConfig),
+		subscribers: make(map[string]map[int]func(string)),
+	}
+}
+
+// SaveLimit stores an override for key.
+func (s *memoryLimitStore) SaveLimit(ctx context.Context, namespace, key string, config LimitConfig) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	if s.limits[namespace] == nil {
+		s.limits[namespace] = make(map[string]LimitConfig)
+	}
+	s.limits[namespace][key] = config
+	subscribers := s.subscribersLocked(namespace)
+	s.mu.Unlock()
+
+	notify(subscribers, key)
+	return nil
+}
+
+// LoadLimit returns the override for key.
+func (s *memoryLimitStore) LoadLimit(ctx context.Context, namespace, key string) (LimitConfig, bool, error) {
+	if ctx.Err() != nil {
+		return LimitConfig{}, false, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	config, ok := s.limits[namespace][key]
+	return config, ok, nil
+}
+
+// DeleteLimit removes the override for key.
+func (s *memoryLimitStore) DeleteLimit(ctx context.Context, namespace, key string) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	s.mu.Lock()
+	delete(s.limits[namespace], key)
+	subscribers := s.subscribersLocked(namespace)
+	s.mu.Unlock()
+
+	notify(subscribers, key)
+	return nil
+}
+
+// ListLimits returns a copy of every override in the namespace.
+func (s *memoryLimitStore) ListLimits(ctx context.Context, namespace string) (map[string]LimitConfig, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]LimitConfig, len(s.limits[namespace]))
+	for key, config := range s.limits[namespace] {
+		result[key] = config
+	}
+	return result, nil
+}
+
+// SubscribeLimits registers onChange for changes in the namespace.
+func (s *memoryLimitStore) SubscribeLimits(ctx context.Context, namespace string, onChange func(key string)) (func() error, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.subscribers[namespace] == nil {
+		s.subscribers[namespace] = make(map[int]func(string))
+	}
+	id := s.nextID
+	s.nextID++
+	s.subscribers[namespace][id] = onChange
+
+	return func() error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.subscribers[namespace], id)
+		return nil
+	}, nil
+}
+
+// subscribersLocked snapshots the subscribers of a namespace. Callers must hold s.mu.
+func (s *memoryLimitStore) subscribersLocked(namespace string) []func(string) {
+	subscribers := make([]func(string), 0, len(s.subscribers[namespace]))
+	for _, fn := range s.subscribers[namespace] {
+		subscribers = append(subscribers, fn)
+	}
+	return subscribers
+}
+
+// notify invokes each subscriber with the changed key.
+func notify(subscribers []func(string), key string) {
+	for _, fn := range subscribers {
+		fn(key)
+	}
+}
@@ -4,6 +4,7 @@ package ratelimit
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/rdhawladar/viva-rate-limiter/pkg/errors"
@@ -27,8 +28,19 @@ type Limiter interface {
 
 	// SetLimit dynamically updates the rate limit for a specific key.
 	// If no specific limit is set, the default limit is used.
+	// Overrides are stored in the backend and shared by every limiter using it.
 	SetLimit(ctx context.Context, key string, limit int, window time.Duration) error
 
+	// GetLimit returns the limit that applies to a key: its override if one is set,
+	// otherwise the highest-precedence matching policy, otherwise the default.
+	GetLimit(ctx context.Context, key string) (LimitConfig, error)
+
+	// DeleteLimit removes the override for a key so that the default limit applies again.
+	DeleteLimit(ctx context.Context, key string) error
+
+	// ListLimits returns every per-key override.
+	ListLimits(ctx context.Context) (map[string]LimitConfig, error)
+
 	// Close releases any resources held by the limiter.
 	Close() error
 }
@@ -61,6 +73,10 @@ type LimitInfo struct {
 
 	// RetryAfter is the duration to wait before the next request (in seconds)
 	RetryAfter time.Duration `json:"retry_after"`
+
+	// Unlimited is true when a policy exempts the key from rate limiting.
+	// Limit and Remaining are -1 in that case.
+	Unlimited bool `json:"unlimited"`
 }
 
 // Backend represents a storage backend for rate limit data.
@@ -103,14 +119,37 @@ type Options struct {
 	// OnAllow is called when a request is allowed.
 	// This can be used for logging or metrics.
 	OnAllow func(key string, remaining int, window time.Duration)
+
+	// Clock is the time source used for window and retry-after calculations.
+	// If nil, the system clock is used. It is also handed to the default
+	// in-memory backend; custom backends must be given a clock directly.
+	Clock Clock
+
+	// LimitCacheTTL is how long per-key overrides are cached locally before being
+	// re-read from the backend. Changes announced by the backend invalidate the
+	// cache immediately; the TTL bounds staleness if a notification is lost.
+	LimitCacheTTL time.Duration
+
+	// LimitCacheSize caps how many override lookups are cached locally. Lookups
+	// for keys without an override are cached too, so without a cap every
+	// distinct key ever checked would be kept. Expired entries are swept every
+	// LimitCacheTTL; when the cache is full an arbitrary entry is evicted.
+	LimitCacheSize int
+
+	// Policies assigns limits to families of keys by pattern, e.g. "user:*:upload".
+	// Keys with an exact override use the override; keys matching no policy use
+	// DefaultLimit and DefaultWindow. If nil, only overrides and defaults apply.
+	Policies *PolicyMatcher
 }
 
 // DefaultOptions returns a default configuration.
 func DefaultOptions() Options {
 	return Options{
-		DefaultLimit:  100,
-		DefaultWindow: time.Hour,
-		KeyPrefix:     "ratelimit:",
+		DefaultLimit:   100,
+		DefaultWindow:  time.Hour,
+		KeyPrefix:      "ratelimit:",
+		LimitCacheTTL:  time.Minute,
+		LimitCacheSize: defaultLimitCacheSize,
 	}
 }
 
@@ -125,25 +164,64 @@ func New(opts Options) Limiter {
 	if opts.KeyPrefix == "" {
 		opts.KeyPrefix = "ratelimit:"
 	}
+	if opts.Clock == nil {
+		opts.Clock = NewSystemClock()
+	}
 	if opts.Backend == nil {
-		opts.Backend = NewMemoryBackend()
+		opts.Backend = NewMemoryBackendWithClock(opts.Clock, defaultCleanupInterval)
+	}
+	if opts.LimitCacheTTL <= 0 {
+		opts.LimitCacheTTL = time.Minute
+	}
+	if opts.LimitCacheSize <= 0 {
+		opts.LimitCacheSize = defaultLimitCacheSize
+	}
+
+	// Fall back to process-local overrides if the backend cannot persist them
+	store, ok := opts.Backend.(LimitStore)
+	if !ok {
+		store = newMemoryLimitStore()
 	}
 
-	return &slidingWindowLimiter{
+	l := &slidingWindowLimiter{
 		backend:       opts.Backend,
+		limitStore:    store,
 		defaultLimit:  opts.DefaultLimit,
 		defaultWindow: opts.DefaultWindow,
 		keyPrefix:     opts.KeyPrefix,
 		onLimitExceeded: opts.OnLimitExceeded,
 		onAllow:       opts.OnAllow,
-		customLimits:  make(map[string]limitConfig),
+		customLimits:  make(map[string]cachedLimit),
+		limitCacheTTL: opts.LimitCacheTTL,
+		limitCacheSize: opts.LimitCacheSize,
+		stopSweep:     make(chan struct{}),
+		policies:      opts.Policies,
+		clock:         opts.Clock,
+	}
+
+	// Drop cached overrides as soon as any instance changes them. If the
+	// subscription cannot be established the cache TTL still bounds staleness.
+	unsubscribe, err := store.SubscribeLimits(context.Background(), opts.KeyPrefix, l.invalidateLimit)
+	if err == nil {
+		l.unsubscribe = unsubscribe
 	}
+
+	// The ticker is created up front so that a fake clock advanced immediately
+	// after construction is not missed.
+	go l.sweepLoop(opts.Clock.NewTicker(opts.LimitCacheTTL))
+
+	return l
 }
 
-// limitConfig holds custom limit configuration for a specific key.
-type limitConfig struct {
-	limit  int
-	window time.Duration
+// defaultLimitCacheSize is how many override lookups a limiter caches by default.
+const defaultLimitCacheSize = 10000
+
+// cachedLimit is a locally cached override lookup. found is false for keys
+// known to have no override, so misses are cached too.
+type cachedLimit struct {
+	config    LimitConfig
+	found     bool
+	expiresAt time.Time
 }
 
 // slidingWindowLimiter implements the Limiter interface using a sliding window algorithm.
@@ -154,7 +232,20 @@ type slidingWindowLimiter struct {
 	keyPrefix       string
 	onLimitExceeded func(string, int, time.Duration)
 	onAllow         func(string, int, time.Duration)
-	customLimits    map[string]limitConfig
+	clock           Clock
+
+	// limitStore persists overrides; customLimits caches them locally
+	limitStore     LimitStore
+	limitsMu       sync.RWMutex
+	customLimits   map[string]cachedLimit
+	limitCacheTTL  time.Duration
+	limitCacheSize int
+	unsubscribe    func() error
+	stopSweep      chan struct{}
+	closeOnce      sync.Once
+
+	// policies resolves keys without an override; may be nil
+	policies *PolicyMatcher
 }
 
 // Allow checks if a request for the given key is allowed.
@@ -168,8 +259,16 @@ func (l *slidingWindowLimiter) AllowN(ctx context.Context, key string, n int) bo
 		return true
 	}
 
-	limit, window := l.getLimitAndWindow(key)
-	prefixedKey := l.keyPrefix + key
+	config, err := l.resolveLimit(ctx, key)
+	if err != nil {
+		// On error, be conservative and deny the request
+		return false
+	}
+	if config.Unlimited {
+		return true
+	}
+	limit, window := config.Limit, config.Window
+	prefixedKey := counterKey(l.keyPrefix, key)
 
 	// Get current count without incrementing first
 	currentCount, _, err := l.backend.Get(ctx, prefixedKey, window)
@@ -210,8 +309,27 @@ func (l *slidingWindowLimiter) AllowN(ctx context.Context, key string, n int) bo
 
 // Info returns detailed information about the current rate limit status for a key.
 func (l *slidingWindowLimiter) Info(ctx context.Context, key string) (*LimitInfo, error) {
-	limit, window := l.getLimitAndWindow(key)
-	prefixedKey := l.keyPrefix + key
+	config, err := l.resolveLimit(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	limit, window := config.Limit, config.Window
+
+	if config.Unlimited {
+		now := l.clock.Now()
+		return &LimitInfo{
+			Key:         key,
+			Limit:       -1,
+			Remaining:   -1,
+			Window:      window,
+			WindowStart: now,
+			WindowEnd:   now.Add(window),
+			ResetTime:   now.Add(window),
+			Unlimited:   true,
+		}, nil
+	}
+
+	prefixedKey := counterKey(l.keyPrefix, key)
 
 	count, windowStart, err := l.backend.Get(ctx, prefixedKey, window)
 	if err != nil {
@@ -225,8 +343,8 @@ func (l *slidingWindowLimiter) Info(ctx context.Context, key string) (*LimitInfo
 	}
 
 	windowEnd := windowStart.Add(window)
-	now := time.Now()
-	
+	now := l.clock.Now()
+
 	var retryAfter time.Duration
 	if remaining == 0 && windowEnd.After(now) {
 		retryAfter = windowEnd.Sub(now)
@@ -247,7 +365,7 @@ func (l *slidingWindowLimiter) Info(ctx context.Context, key string) (*LimitInfo
 
 // Reset resets the rate limit counter for the given key.
 func (l *slidingWindowLimiter) Reset(ctx context.Context, key string) error {
-	prefixedKey := l.keyPrefix + key
+	prefixedKey := counterKey(l.keyPrefix, key)
 	return l.backend.Reset(ctx, prefixedKey)
 }
 
@@ -260,23 +378,135 @@ func (l *slidingWindowLimiter) SetLimit(ctx context.Context, key string, limit i
 		return errors.ErrInvalidWindow
 	}
 
-	l.customLimits[key] = limitConfig{
-		limit:  limit,
-		window: window,
+	config := LimitConfig{
+		Limit:  limit,
+		Window: window,
 	}
+	if err := l.limitStore.SaveLimit(ctx, l.keyPrefix, key, config); err != nil {
+		return err
+	}
+
+	l.cacheLimit(key, config, true)
 	return nil
 }
 
+// GetLimit returns the limit that applies to a key.
+func (l *slidingWindowLimiter) GetLimit(ctx context.Context, key string) (LimitConfig, error) {
+	return l.resolveLimit(ctx, key)
+}
+
+// DeleteLimit removes the override for a key.
+func (l *slidingWindowLimiter) DeleteLimit(ctx context.Context, key string) error {
+	if err := l.limitStore.DeleteLimit(ctx, l.keyPrefix, key); err != nil {
+		return err
+	}
+
+	l.cacheLimit(key, LimitConfig{}, false)
+	return nil
+}
+
+// ListLimits returns every per-key override.
+func (l *slidingWindowLimiter) ListLimits(ctx context.Context) (map[string]LimitConfig, error) {
+	return l.limitStore.ListLimits(ctx, l.keyPrefix)
+}
+
 // Close releases any resources held by the limiter.
 func (l *slidingWindowLimiter) Close() error {
+	l.closeOnce.Do(func() { close(l.stopSweep) })
+	if l.unsubscribe != nil {
+		l.unsubscribe()
+	}
 	return l.backend.Close()
 }
 
-// getLimitAndWindow returns the limit and window for a given key.
-// If a custom limit is set for the key, it returns that; otherwise, it returns the default.
-func (l *slidingWindowLimiter) getLimitAndWindow(key string) (int, time.Duration) {
-	if config, exists := l.customLimits[key]; exists {
-		return config.limit, config.window
+// resolveLimit returns the limit that applies to a key.
+// An exact override wins, then the highest-precedence matching policy, then the
+// default. Override lookups are served from the local cache while it is fresh.
+func (l *slidingWindowLimiter) resolveLimit(ctx context.Context, key string) (LimitConfig, error) {
+	l.limitsMu.RLock()
+	cached, exists := l.customLimits[key]
+	l.limitsMu.RUnlock()
+
+	if !exists || !l.clock.Now().Before(cached.expiresAt) {
+		config, found, err := l.limitStore.LoadLimit(ctx, l.keyPrefix, key)
+		if err != nil {
+			return LimitConfig{}, err
+		}
+		cached = l.cacheLimit(key, config, found)
+	}
+
+	if cached.found {
+		return cached.config, nil
+	}
+
+	if policy, ok := l.policies.Match(key); ok {
+		window := policy.Window
+		if window <= 0 {
+			window = l.defaultWindow
+		}
+		if policy.Unlimited {
+			return LimitConfig{Window: window, Unlimited: true}, nil
+		}
+		return LimitConfig{Limit: policy.Limit, Window: window}, nil
+	}
+
+	return LimitConfig{Limit: l.defaultLimit, Window: l.defaultWindow}, nil
+}
+
+// cacheLimit records the result of an override lookup for key.
+func (l *slidingWindowLimiter) cacheLimit(key string, config LimitConfig, found bool) cachedLimit {
+	entry := cachedLimit{
+		config:    config,
+		found:     found,
+		expiresAt: l.clock.Now().Add(l.limitCacheTTL),
 	}
-	return l.defaultLimit, l.defaultWindow
+
+	l.limitsMu.Lock()
+	if _, exists := l.customLimits[key]; !exists && len(l.customLimits) >= l.limitCacheSize {
+		// Make room by dropping an arbitrary entry, which only costs that key
+		// a reload. Expired entries are left to the sweep.
+		for evicted := range l.customLimits {
+			delete(l.customLimits, evicted)
+			break
+		}
+	}
+	l.customLimits[key] = entry
+	l.limitsMu.Unlock()
+
+	return entry
+}
+
+// sweepLoop periodically drops expired override lookups from the cache.
+func (l *slidingWindowLimiter) sweepLoop(ticker Ticker) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			l.sweepLimits()
+		case <-l.stopSweep:
+			return
+		}
+	}
+}
+
+// sweepLimits removes cached lookups that have expired.
+func (l *slidingWindowLimiter) sweepLimits() {
+	now := l.clock.Now()
+
+	l.limitsMu.Lock()
+	defer l.limitsMu.Unlock()
+
+	for key, entry := range l.customLimits {
+		if !now.Before(entry.expiresAt) {
+			delete(l.customLimits, key)
+		}
+	}
+}
+
+// invalidateLimit drops the cached override for key so the next lookup reloads it.
+func (l *slidingWindowLimiter) invalidateLimit(key string) {
+	l.limitsMu.Lock()
+	delete(l.customLimits, key)
+	l.limitsMu.Unlock()
 }
\ No newline at end of file
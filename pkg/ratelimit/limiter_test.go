@@ -0,0 +1,209 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(t *testing.T, clock *FakeClock, limit int, window time.Duration) Limiter {
+	t.Helper()
+
+	limiter := New(Options{
+		DefaultLimit:  limit,
+		DefaultWindow: window,
+		Clock:         clock,
+	})
+	t.Cleanup(func() { limiter.Close() })
+	return limiter
+}
+
+func TestSlidingWindowLimiter_WindowBoundary(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	limiter := newTestLimiter(t, clock, 2, time.Minute)
+
+	assert.True(t, limiter.Allow(ctx, "user"))
+	assert.True(t, limiter.Allow(ctx, "user"))
+	assert.False(t, limiter.Allow(ctx, "user"))
+
+	// One nanosecond before the window closes the key is still limited
+	clock.Advance(time.Minute - time.Nanosecond)
+	assert.False(t, limiter.Allow(ctx, "user"))
+
+	// Exactly at the boundary a new window starts
+	clock.Advance(time.Nanosecond)
+	assert.True(t, limiter.Allow(ctx, "user"))
+}
+
+func TestSlidingWindowLimiter_InfoRetryAfter(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	limiter := newTestLimiter(t, clock, 1, time.Minute)
+
+	require.True(t, limiter.Allow(ctx, "user"))
+	clock.Advance(20 * time.Second)
+
+	info, err := limiter.Info(ctx, "user")
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, info.Remaining)
+	assert.Equal(t, start, info.WindowStart)
+	assert.Equal(t, start.Add(time.Minute), info.ResetTime)
+	assert.Equal(t, 40*time.Second, info.RetryAfter)
+}
+
+func TestMemoryBackend_CleanupUsesClock(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	backend := NewMemoryBackendWithClock(clock, time.Hour)
+	defer backend.Close()
+
+	_, _, err := backend.Increment(ctx, "stale", time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, 1, backend.Size())
+
+	// Entries are only swept 24 hours after their last access
+	clock.Advance(25 * time.Hour)
+
+	assert.Eventually(t, func() bool {
+		return backend.Size() == 0
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestFakeClock_TickerFiresOnAdvance(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	ticker := clock.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case <-ticker.C():
+		t.Fatal("ticker fired before its period elapsed")
+	default:
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	select {
+	case tick := <-ticker.C():
+		assert.Equal(t, time.Unix(1, 0), tick)
+	default:
+		t.Fatal("ticker did not fire after its period elapsed")
+	}
+}
+
+func TestSlidingWindowLimiter_OverridesSharedThroughBackend(t *testing.T) {
+	ctx := context.Background()
+	backend := NewMemoryBackend()
+	defer backend.Close()
+
+	first := New(Options{Backend: backend, DefaultLimit: 10, DefaultWindow: time.Minute})
+	second := New(Options{Backend: backend, DefaultLimit: 10, DefaultWindow: time.Minute})
+
+	// Prime the second limiter's cache with the default
+	config, err := second.GetLimit(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, LimitConfig{Limit: 10, Window: time.Minute}, config)
+
+	require.NoError(t, first.SetLimit(ctx, "user", 1, time.Hour))
+
+	config, err = second.GetLimit(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, LimitConfig{Limit: 1, Window: time.Hour}, config)
+
+	limits, err := second.ListLimits(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]LimitConfig{"user": {Limit: 1, Window: time.Hour}}, limits)
+
+	require.NoError(t, second.DeleteLimit(ctx, "user"))
+
+	config, err = first.GetLimit(ctx, "user")
+	require.NoError(t, err)
+	assert.Equal(t, 10, config.Limit)
+}
+
+func TestCounterKeyAvoidsReservedPrefix(t *testing.T) {
+	assert.Equal(t, "ratelimit:user", counterKey("ratelimit:", "user"))
+	assert.Equal(t, "ratelimit:limits", counterKey("ratelimit:", "limits"))
+	assert.Equal(t, "ratelimit:__sys:limits", counterKey("ratelimit:", "_sys:limits"))
+
+	for _, key := range []string{"_sys:limits", "_sys:limits:changed", "__sys:limits", "_", ""} {
+		counter := counterKey("ratelimit:", key)
+		assert.NotEqual(t, limitsHashKey("ratelimit:"), counter, key)
+		assert.NotEqual(t, limitsChannel("ratelimit:"), counter, key)
+		assert.False(t, strings.HasPrefix(counter, "ratelimit:"+reservedKeyPrefix), key)
+	}
+}
+
+func TestSlidingWindowLimiter_ReservedKeysCountSeparately(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := newTestLimiter(t, clock, 1, time.Minute)
+
+	assert.True(t, limiter.Allow(ctx, "_sys:limits"))
+	assert.True(t, limiter.Allow(ctx, "sys:limits"))
+	assert.True(t, limiter.Allow(ctx, "__sys:limits"))
+	assert.False(t, limiter.Allow(ctx, "_sys:limits"))
+}
+
+func TestSlidingWindowLimiter_ConcurrentSetLimitAndAllow(t *testing.T) {
+	ctx := context.Background()
+	limiter := New(DefaultOptions())
+	defer limiter.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				limiter.SetLimit(ctx, "shared", i+j+1, time.Minute)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				limiter.Allow(ctx, "shared")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSlidingWindowLimiter_LimitCacheShrinksAfterTTL(t *testing.T) {
+	ctx := context.Background()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := New(Options{
+		DefaultLimit:   10,
+		DefaultWindow:  time.Minute,
+		Clock:          clock,
+		LimitCacheTTL:  time.Minute,
+		LimitCacheSize: 3,
+	})
+	defer limiter.Close()
+	sw := limiter.(*slidingWindowLimiter)
+
+	cached := func() int {
+		sw.limitsMu.RLock()
+		defer sw.limitsMu.RUnlock()
+		return len(sw.customLimits)
+	}
+
+	// Keys without an override are cached, but never beyond the cap
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		require.True(t, limiter.Allow(ctx, key))
+	}
+	assert.Equal(t, 3, cached())
+
+	clock.Advance(time.Minute)
+
+	assert.Eventually(t, func() bool {
+		return cached() == 0
+	}, time.Second, 5*time.Millisecond)
+}
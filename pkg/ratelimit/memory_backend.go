@@ -8,6 +8,9 @@ import (
 	"github.com/rdhawladar/viva-rate-limiter/pkg/errors"
 )
 
+// defaultCleanupInterval is how often the memory backend sweeps stale entries.
+const defaultCleanupInterval = 5 * time.Minute
+
 // memoryEntry represents a single entry in the memory backend.
 type memoryEntry struct {
 	count       int64
@@ -25,32 +28,46 @@ type MemoryBackend struct {
 	// cleanupInterval controls how often expired entries are cleaned up
 	cleanupInterval time.Duration
 	stopCleanup     chan struct{}
+
+	// clock is the time source for windows and cleanup
+	clock Clock
+
+	// limits holds per-key overrides shared by limiters using this backend
+	limits *memoryLimitStore
 }
 
 // NewMemoryBackend creates a new in-memory backend.
 func NewMemoryBackend() *MemoryBackend {
-	backend := &MemoryBackend{
-		data:            make(map[string]*memoryEntry),
-		cleanupInterval: 5 * time.Minute,
-		stopCleanup:     make(chan struct{}),
-	}
-	
-	// Start cleanup goroutine
-	go backend.cleanupLoop()
-	
-	return backend
+	return NewMemoryBackendWithClock(NewSystemClock(), defaultCleanupInterval)
 }
 
 // NewMemoryBackendWithCleanup creates a new in-memory backend with custom cleanup interval.
 func NewMemoryBackendWithCleanup(cleanupInterval time.Duration) *MemoryBackend {
+	return NewMemoryBackendWithClock(NewSystemClock(), cleanupInterval)
+}
+
+// NewMemoryBackendWithClock creates a new in-memory backend that reads time from clock.
+// A FakeClock makes window expiry and the cleanup loop fully deterministic.
+func NewMemoryBackendWithClock(clock Clock, cleanupInterval time.Duration) *MemoryBackend {
+	if clock == nil {
+		clock = NewSystemClock()
+	}
+	if cleanupInterval <= 0 {
+		cleanupInterval = defaultCleanupInterval
+	}
+
 	backend := &MemoryBackend{
 		data:            make(map[string]*memoryEntry),
 		cleanupInterval: cleanupInterval,
 		stopCleanup:     make(chan struct{}),
+		clock:           clock,
+		limits:          newMemoryLimitStore(),
 	}
-	
-	go backend.cleanupLoop()
-	
+
+	// Start cleanup goroutine. The ticker is created up front so that a fake
+	// clock advanced immediately after construction is not missed.
+	go backend.cleanupLoop(clock.NewTicker(cleanupInterval))
+
 	return backend
 }
 
@@ -67,7 +84,7 @@ func (m *MemoryBackend) Increment(ctx context.Context, key string, window time.D
 		return 0, time.Time{}, errors.ErrBackendClosed
 	}
 
-	now := time.Now()
+	now := m.clock.Now()
 	entry, exists := m.data[key]
 
 	if !exists || m.isWindowExpired(entry.windowStart, window, now) {
@@ -100,7 +117,7 @@ func (m *MemoryBackend) Get(ctx context.Context, key string, window time.Duratio
 		return 0, time.Time{}, errors.ErrBackendClosed
 	}
 
-	now := time.Now()
+	now := m.clock.Now()
 	entry, exists := m.data[key]
 
 	if !exists || m.isWindowExpired(entry.windowStart, window, now) {
@@ -143,6 +160,53 @@ func (m *MemoryBackend) Close() error {
 	return nil
 }
 
+// SaveLimit stores a per-key limit override.
+func (m *MemoryBackend) SaveLimit(ctx context.Context, namespace, key string, config LimitConfig) error {
+	if m.isClosed() {
+		return errors.ErrBackendClosed
+	}
+	return m.limits.SaveLimit(ctx, namespace, key, config)
+}
+
+// LoadLimit returns the per-key limit override for key, if any.
+func (m *MemoryBackend) LoadLimit(ctx context.Context, namespace, key string) (LimitConfig, bool, error) {
+	if m.isClosed() {
+		return LimitConfig{}, false, errors.ErrBackendClosed
+	}
+	return m.limits.LoadLimit(ctx, namespace, key)
+}
+
+// DeleteLimit removes the per-key limit override for key.
+func (m *MemoryBackend) DeleteLimit(ctx context.Context, namespace, key string) error {
+	if m.isClosed() {
+		return errors.ErrBackendClosed
+	}
+	return m.limits.DeleteLimit(ctx, namespace, key)
+}
+
+// ListLimits returns all per-key limit overrides in the namespace.
+func (m *MemoryBackend) ListLimits(ctx context.Context, namespace string) (map[string]LimitConfig, error) {
+	if m.isClosed() {
+		return nil, errors.ErrBackendClosed
+	}
+	return m.limits.ListLimits(ctx, namespace)
+}
+
+// SubscribeLimits registers a callback for override changes in the namespace.
+func (m *MemoryBackend) SubscribeLimits(ctx context.Context, namespace string, onChange func(key string)) (func() error, error) {
+	if m.isClosed() {
+		return nil, errors.ErrBackendClosed
+	}
+	return m.limits.SubscribeLimits(ctx, namespace, onChange)
+}
+
+// isClosed reports whether Close has been called.
+func (m *MemoryBackend) isClosed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.closed
+}
+
 // Size returns the number of entries currently stored.
 func (m *MemoryBackend) Size() int {
 	m.mu.RLock()
@@ -172,13 +236,12 @@ func (m *MemoryBackend) isWindowExpired(windowStart time.Time, window time.Durat
 }
 
 // cleanupLoop periodically removes expired entries to prevent memory leaks.
-func (m *MemoryBackend) cleanupLoop() {
-	ticker := time.NewTicker(m.cleanupInterval)
+func (m *MemoryBackend) cleanupLoop(ticker Ticker) {
 	defer ticker.Stop()
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			m.cleanup()
 		case <-m.stopCleanup:
 			return
@@ -195,7 +258,7 @@ func (m *MemoryBackend) cleanup() {
 		return
 	}
 
-	now := time.Now()
+	now := m.clock.Now()
 	maxAge := 24 * time.Hour // Keep entries for at most 24 hours after last access
 
 	for key, entry := range m.data {
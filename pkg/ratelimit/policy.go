@@ -0,0 +1,212 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rdhawladar/viva-rate-limiter/pkg/errors"
+)
+
+// Policy assigns a limit to every key matching a pattern.
+//
+// Patterns are matched against the whole key. A single "*" matches any run of
+// characters within one ":"-separated segment, and "**" matches any run of
+// characters including ":". For example "user:*:upload" matches
+// "user:42:upload" but not "user:42:files:upload", while "ip:10.0.*" matches
+// "ip:10.0.3.7".
+type Policy struct {
+	// Pattern is the key pattern this policy applies to.
+	Pattern string `json:"pattern"`
+
+	// Limit is the maximum number of requests allowed in the window.
+	// Ignored when Unlimited is set.
+	Limit int `json:"limit"`
+
+	// Window is the time window for the rate limit.
+	// If zero, the limiter's default window is used.
+	Window time.Duration `json:"window"`
+
+	// Unlimited exempts matching keys from rate limiting entirely.
+	Unlimited bool `json:"unlimited"`
+
+	// Priority overrides the specificity ordering. Higher values win.
+	Priority int `json:"priority"`
+}
+
+// compiledPolicy is a validated policy with its precedence attributes.
+type compiledPolicy struct {
+	Policy
+	literals  int
+	wildcards int
+	order     int
+}
+
+// PolicyMatcher selects the policy that applies to a key.
+//
+// When several policies match, precedence is decided by, in order:
+//  1. higher Priority
+//  2. more literal (non-wildcard) characters in the pattern
+//  3. fewer wildcards
+//  4. earlier insertion
+//
+// Exact per-key overrides set with Limiter.SetLimit always take precedence over
+// policies. PolicyMatcher is safe for concurrent use, so policies can be added or
+// removed while the limiter is serving requests.
+type PolicyMatcher struct {
+	mu       sync.RWMutex
+	policies []compiledPolicy
+	order    int
+}
+
+// NewPolicyMatcher creates a matcher containing the given policies.
+func NewPolicyMatcher(policies ...Policy) (*PolicyMatcher, error) {
+	m := &PolicyMatcher{}
+	for _, policy := range policies {
+		if err := m.Add(policy); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Add registers a policy, replacing any existing policy with the same pattern.
+func (m *PolicyMatcher) Add(policy Policy) error {
+	if policy.Pattern == "" {
+		return errors.ErrInvalidKey
+	}
+	if !policy.Unlimited && policy.Limit <= 0 {
+		return errors.ErrInvalidLimit
+	}
+	if policy.Window < 0 {
+		return errors.ErrInvalidWindow
+	}
+	if strings.Contains(policy.Pattern, "***") {
+		return fmt.Errorf("invalid pattern %q: use * or **", policy.Pattern)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.removeLocked(policy.Pattern)
+
+	literals, wildcards := patternSpecificity(policy.Pattern)
+	m.policies = append(m.policies, compiledPolicy{
+		Policy:    policy,
+		literals:  literals,
+		wildcards: wildcards,
+		order:     m.order,
+	})
+	m.order++
+
+	sort.SliceStable(m.policies, func(i, j int) bool {
+		a, b := m.policies[i], m.policies[j]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.literals != b.literals {
+			return a.literals > b.literals
+		}
+		if a.wildcards != b.wildcards {
+			return a.wildcards < b.wildcards
+		}
+		return a.order < b.order
+	})
+	return nil
+}
+
+// Remove deletes the policy with the given pattern. It reports whether one existed.
+func (m *PolicyMatcher) Remove(pattern string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.removeLocked(pattern)
+}
+
+// Policies returns the registered policies in precedence order.
+func (m *PolicyMatcher) Policies() []Policy {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]Policy, len(m.policies))
+	for i, policy := range m.policies {
+		result[i] = policy.Policy
+	}
+	return result
+}
+
+// Match returns the highest-precedence policy whose pattern matches key.
+func (m *PolicyMatcher) Match(key string) (Policy, bool) {
+	if m == nil {
+		return Policy{}, false
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, policy := range m.policies {
+		if matchPattern(policy.Pattern, key) {
+			return policy.Policy, true
+		}
+	}
+	return Policy{}, false
+}
+
+// removeLocked deletes a policy by pattern. Callers must hold m.mu.
+func (m *PolicyMatcher) removeLocked(pattern string) bool {
+	for i, policy := range m.policies {
+		if policy.Pattern == pattern {
+			m.policies = append(m.policies[:i], m.policies[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// patternSpecificity counts the literal characters and wildcards in a pattern.
+func patternSpecificity(pattern string) (literals, wildcards int) {
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] != '*' {
+			literals++
+			continue
+		}
+		wildcards++
+		if i+1 < len(pattern) && pattern[i+1] == '*' {
+			i++
+		}
+	}
+	return literals, wildcards
+}
+
+// matchPattern reports whether key matches pattern using the Policy wildcard rules.
+func matchPattern(pattern, key string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] != '*' {
+			if len(key) == 0 || key[0] != pattern[0] {
+				return false
+			}
+			pattern, key = pattern[1:], key[1:]
+			continue
+		}
+
+		crossSegments := strings.HasPrefix(pattern, "**")
+		if crossSegments {
+			pattern = pattern[2:]
+		} else {
+			pattern = pattern[1:]
+		}
+
+		// Try every possible length for the wildcard, shortest first
+		for i := 0; i <= len(key); i++ {
+			if matchPattern(pattern, key[i:]) {
+				return true
+			}
+			if i < len(key) && key[i] == ':' && !crossSegments {
+				return false
+			}
+		}
+		return false
+	}
+	return len(key) == 0
+}
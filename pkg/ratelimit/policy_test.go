@@ -0,0 +1,116 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		key     string
+		want    bool
+	}{
+		{"user:*:upload", "user:42:upload", true},
+		{"user:*:upload", "user::upload", true},
+		{"user:*:upload", "user:42:files:upload", false},
+		{"user:**:upload", "user:42:files:upload", true},
+		{"ip:10.0.*", "ip:10.0.3.7", true},
+		{"ip:10.0.*", "ip:10.1.3.7", false},
+		{"api:*", "api:v1:search", false},
+		{"api:**", "api:v1:search", true},
+		{"exact", "exact", true},
+		{"exact", "exactly", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"/"+tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchPattern(tt.pattern, tt.key))
+		})
+	}
+}
+
+func TestPolicyMatcher_Precedence(t *testing.T) {
+	matcher, err := NewPolicyMatcher(
+		Policy{Pattern: "**", Limit: 1000},
+		Policy{Pattern: "user:*:*", Limit: 100},
+		Policy{Pattern: "user:*:upload", Limit: 10, Window: time.Minute},
+		Policy{Pattern: "user:vip:*", Limit: 5000, Priority: 1},
+	)
+	require.NoError(t, err)
+
+	policy, ok := matcher.Match("user:42:upload")
+	require.True(t, ok)
+	assert.Equal(t, "user:*:upload", policy.Pattern)
+
+	policy, ok = matcher.Match("user:42:download")
+	require.True(t, ok)
+	assert.Equal(t, "user:*:*", policy.Pattern)
+
+	// Priority beats the more specific pattern
+	policy, ok = matcher.Match("user:vip:upload")
+	require.True(t, ok)
+	assert.Equal(t, "user:vip:*", policy.Pattern)
+
+	policy, ok = matcher.Match("anything:else")
+	require.True(t, ok)
+	assert.Equal(t, "**", policy.Pattern)
+
+	assert.True(t, matcher.Remove("**"))
+	_, ok = matcher.Match("anything:else")
+	assert.False(t, ok)
+}
+
+func TestPolicyMatcher_Validation(t *testing.T) {
+	_, err := NewPolicyMatcher(Policy{Pattern: "", Limit: 1})
+	assert.Error(t, err)
+
+	_, err = NewPolicyMatcher(Policy{Pattern: "user:*", Limit: 0})
+	assert.Error(t, err)
+
+	_, err = NewPolicyMatcher(Policy{Pattern: "ip:*", Unlimited: true})
+	assert.NoError(t, err)
+}
+
+func TestSlidingWindowLimiter_Policies(t *testing.T) {
+	ctx := context.Background()
+	policies, err := NewPolicyMatcher(
+		Policy{Pattern: "user:*:upload", Limit: 2, Window: time.Minute},
+		Policy{Pattern: "ip:10.0.*", Unlimited: true},
+	)
+	require.NoError(t, err)
+
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	limiter := New(Options{
+		DefaultLimit:  5,
+		DefaultWindow: time.Hour,
+		Clock:         clock,
+		Policies:      policies,
+	})
+	defer limiter.Close()
+
+	// Each key in the family gets its own counter with the policy limit
+	assert.True(t, limiter.AllowN(ctx, "user:1:upload", 2))
+	assert.False(t, limiter.Allow(ctx, "user:1:upload"))
+	assert.True(t, limiter.Allow(ctx, "user:2:upload"))
+
+	// Unlimited keys are never counted
+	assert.True(t, limiter.AllowN(ctx, "ip:10.0.3.7", 1000))
+	info, err := limiter.Info(ctx, "ip:10.0.3.7")
+	require.NoError(t, err)
+	assert.True(t, info.Unlimited)
+	assert.Equal(t, -1, info.Remaining)
+
+	// Keys outside every policy use the default
+	config, err := limiter.GetLimit(ctx, "ip:192.168.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, LimitConfig{Limit: 5, Window: time.Hour}, config)
+
+	// Exact overrides beat policies
+	require.NoError(t, limiter.SetLimit(ctx, "user:1:upload", 50, time.Minute))
+	assert.True(t, limiter.Allow(ctx, "user:1:upload"))
+}
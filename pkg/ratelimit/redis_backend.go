@@ -2,6 +2,7 @@ package ratelimit
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"time"
@@ -16,6 +17,7 @@ import (
 type RedisBackend struct {
 	client redis.UniversalClient
 	closed bool
+	clock  Clock
 }
 
 // RedisConfig contains configuration for Redis backend.
@@ -50,6 +52,10 @@ type RedisConfig struct {
 
 	// ClusterMode enables Redis cluster mode.
 	ClusterMode bool
+
+	// Clock is the time source used to timestamp requests (optional).
+	// Defaults to the system clock.
+	Clock Clock
 }
 
 // DefaultRedisConfig returns a default Redis configuration.
@@ -109,15 +115,24 @@ func NewRedisBackend(config RedisConfig) (*RedisBackend, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisBackend{
-		client: client,
-	}, nil
+	return NewRedisBackendFromClientWithClock(client, config.Clock), nil
 }
 
 // NewRedisBackendFromClient creates a Redis backend from an existing Redis client.
 func NewRedisBackendFromClient(client redis.UniversalClient) *RedisBackend {
+	return NewRedisBackendFromClientWithClock(client, nil)
+}
+
+// NewRedisBackendFromClientWithClock creates a Redis backend from an existing Redis client
+// that timestamps requests using clock.
+func NewRedisBackendFromClientWithClock(client redis.UniversalClient, clock Clock) *RedisBackend {
+	if clock == nil {
+		clock = NewSystemClock()
+	}
+
 	return &RedisBackend{
 		client: client,
+		clock:  clock,
 	}
 }
 
@@ -131,7 +146,7 @@ func (r *RedisBackend) Increment(ctx context.Context, key string, window time.Du
 		return 0, time.Time{}, errors.ErrBackendClosed
 	}
 
-	now := time.Now()
+	now := r.clock.Now()
 	windowStart := now
 	
 	// Use a Lua script for atomic sliding window operation
@@ -200,7 +215,7 @@ func (r *RedisBackend) Get(ctx context.Context, key string, window time.Duration
 		return 0, time.Time{}, errors.ErrBackendClosed
 	}
 
-	now := time.Now()
+	now := r.clock.Now()
 	
 	// Use a Lua script for atomic sliding window read
 	luaScript := `
@@ -275,6 +290,145 @@ func (r *RedisBackend) Close() error {
 	return r.client.Close()
 }
 
+// storedLimit is the JSON representation of a LimitConfig in Redis.
+type storedLimit struct {
+	Limit    int   `json:"limit"`
+	WindowMs int64 `json:"window_ms"`
+}
+
+// limitsHashKey returns the hash that holds the overrides of a namespace.
+// It lives under the reserved prefix so no counter key can collide with it.
+func limitsHashKey(namespace string) string {
+	return namespace + reservedKeyPrefix + "limits"
+}
+
+// limitsChannel returns the pub/sub channel used to announce override changes.
+func limitsChannel(namespace string) string {
+	return namespace + reservedKeyPrefix + "limits:changed"
+}
+
+// SaveLimit stores a per-key limit override and publishes the change.
+func (r *RedisBackend) SaveLimit(ctx context.Context, namespace, key string, config LimitConfig) error {
+	if r.closed {
+		return errors.ErrBackendClosed
+	}
+
+	data, err := json.Marshal(storedLimit{
+		Limit:    config.Limit,
+		WindowMs: config.Window.Milliseconds(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode limit: %w", err)
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, limitsHashKey(namespace), key, data)
+		pipe.Publish(ctx, limitsChannel(namespace), key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Redis save limit failed: %w", err)
+	}
+	return nil
+}
+
+// LoadLimit returns the per-key limit override for key, if any.
+func (r *RedisBackend) LoadLimit(ctx context.Context, namespace, key string) (LimitConfig, bool, error) {
+	if r.closed {
+		return LimitConfig{}, false, errors.ErrBackendClosed
+	}
+
+	data, err := r.client.HGet(ctx, limitsHashKey(namespace), key).Bytes()
+	if err == redis.Nil {
+		return LimitConfig{}, false, nil
+	}
+	if err != nil {
+		return LimitConfig{}, false, fmt.Errorf("Redis load limit failed: %w", err)
+	}
+
+	config, err := decodeLimit(data)
+	if err != nil {
+		return LimitConfig{}, false, err
+	}
+	return config, true, nil
+}
+
+// DeleteLimit removes the per-key limit override for key and publishes the change.
+func (r *RedisBackend) DeleteLimit(ctx context.Context, namespace, key string) error {
+	if r.closed {
+		return errors.ErrBackendClosed
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, limitsHashKey(namespace), key)
+		pipe.Publish(ctx, limitsChannel(namespace), key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Redis delete limit failed: %w", err)
+	}
+	return nil
+}
+
+// ListLimits returns all per-key limit overrides in the namespace.
+func (r *RedisBackend) ListLimits(ctx context.Context, namespace string) (map[string]LimitConfig, error) {
+	if r.closed {
+		return nil, errors.ErrBackendClosed
+	}
+
+	entries, err := r.client.HGetAll(ctx, limitsHashKey(namespace)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("Redis list limits failed: %w", err)
+	}
+
+	result := make(map[string]LimitConfig, len(entries))
+	for key, data := range entries {
+		config, err := decodeLimit([]byte(data))
+		if err != nil {
+			return nil, fmt.Errorf("invalid limit for key %s: %w", key, err)
+		}
+		result[key] = config
+	}
+	return result, nil
+}
+
+// SubscribeLimits listens on the namespace's pub/sub channel and calls onChange
+// with the key of every override saved or deleted by any instance.
+func (r *RedisBackend) SubscribeLimits(ctx context.Context, namespace string, onChange func(key string)) (func() error, error) {
+	if r.closed {
+		return nil, errors.ErrBackendClosed
+	}
+
+	pubsub := r.client.Subscribe(ctx, limitsChannel(namespace))
+
+	// Wait for the subscription to be confirmed so no change is missed afterwards
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return nil, fmt.Errorf("Redis subscribe failed: %w", err)
+	}
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			onChange(msg.Payload)
+		}
+	}()
+
+	return pubsub.Close, nil
+}
+
+// decodeLimit parses a stored limit.
+func decodeLimit(data []byte) (LimitConfig, error) {
+	var stored storedLimit
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return LimitConfig{}, fmt.Errorf("failed to decode limit: %w", err)
+	}
+
+	return LimitConfig{
+		Limit:  stored.Limit,
+		Window: time.Duration(stored.WindowMs) * time.Millisecond,
+	}, nil
+}
+
 // Ping tests the connection to Redis.
 func (r *RedisBackend) Ping(ctx context.Context) error {
 	if r.closed {